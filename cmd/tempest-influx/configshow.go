@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+)
+
+// runConfig handles the config subcommands; only "show" exists
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "show" {
+		log.Printf("usage: tempest-influx config show [flags]")
+		return 2
+	}
+	return configShow(config.NewLoader(getConfigDir(), configName, args[1:]), os.Stdout)
+}
+
+// configShow prints the effective configuration of loader with the source
+// of every value. An invalid configuration is printed as well, followed by
+// the validation error.
+func configShow(loader *config.Loader, w io.Writer) int {
+	_, err := loader.Load()
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+	for _, s := range loader.Settings() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Key, s.Value, s.Source)
+	}
+	tw.Flush()
+
+	if err != nil {
+		fmt.Fprintf(w, "\nconfiguration is invalid: %v\n", err)
+		return 1
+	}
+	return 0
+}
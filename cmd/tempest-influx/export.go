@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+// Export formats
+const (
+	exportCSV  = "csv"
+	exportJSON = "json"
+)
+
+// exportOptions selects the range and form of an export
+type exportOptions struct {
+	start       string // RFC 3339 or YYYY-MM-DD
+	end         string // RFC 3339 or YYYY-MM-DD, exclusive
+	station     string
+	measurement string
+	format      string
+	output      string
+}
+
+// exportQuerier runs Flux and InfluxQL queries, see influx.QueryClient
+type exportQuerier interface {
+	Query(ctx context.Context, flux string) ([]influx.Record, error)
+	QueryInfluxQL(ctx context.Context, influxql string) ([]influx.Record, error)
+}
+
+// runExport writes the data of the configured bucket in a time range as
+// CSV or JSON
+func runExport(args []string) int {
+	loader := config.NewLoader(getConfigDir(), configName, args)
+	var opts exportOptions
+	flags := loader.FlagSet()
+	flags.StringVar(&opts.start, "start", "", "Start of the range (RFC 3339 or YYYY-MM-DD)")
+	flags.StringVar(&opts.end, "end", "", "End of the range, exclusive (RFC 3339 or YYYY-MM-DD; default: now)")
+	flags.StringVar(&opts.station, "station", "", "Station serial number (all stations when empty)")
+	flags.StringVar(&opts.measurement, "measurement", "", "Measurement to export (all when empty)")
+	flags.StringVar(&opts.format, "format", exportCSV, "Output format: csv or json")
+	flags.StringVar(&opts.output, "output", "", "File to write (default: standard output)")
+
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	// Standard output is reserved for the data
+	appLogger := logger.NewWriter(cfg, os.Stderr)
+
+	client, err := influx.NewHTTPClient(cfg)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	influx.ResolveAPIPath(context.Background(), cfg, client, appLogger)
+	querier, err := influx.NewQueryClient(cfg, client)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+
+	w := io.Writer(os.Stdout)
+	if opts.output != "" {
+		f, err := os.Create(opts.output)
+		if err != nil {
+			log.Printf("%v", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	return export(cfg, appLogger, querier, opts, w)
+}
+
+// export writes the rows selected by opts to w
+func export(cfg *config.Config, appLogger *logger.AppLogger, querier exportQuerier, opts exportOptions, w io.Writer) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if opts.format == "" {
+		opts.format = exportCSV
+	}
+	if opts.format != exportCSV && opts.format != exportJSON {
+		appLogger.Error("Invalid --format, expected csv or json", slog.String("format", opts.format))
+		return 1
+	}
+
+	loc, err := cfg.Location(opts.station)
+	if err != nil {
+		appLogger.Error("Invalid time zone", slog.String("error", err.Error()))
+		return 1
+	}
+	if opts.start == "" {
+		appLogger.Error("--start is required")
+		return 1
+	}
+	start, err := parseExportTime(opts.start, loc)
+	if err != nil {
+		appLogger.Error("Invalid --start, expected RFC 3339 or YYYY-MM-DD", slog.String("start", opts.start))
+		return 1
+	}
+	end := time.Now()
+	if opts.end != "" {
+		if end, err = parseExportTime(opts.end, loc); err != nil {
+			appLogger.Error("Invalid --end, expected RFC 3339 or YYYY-MM-DD", slog.String("end", opts.end))
+			return 1
+		}
+	}
+	if !end.After(start) {
+		appLogger.Error("--end must be after --start")
+		return 1
+	}
+
+	var records []influx.Record
+	if influx.IsV1Path(cfg.Influx_API_Path) {
+		records, err = querier.QueryInfluxQL(ctx, exportInfluxQL(opts, start, end))
+	} else {
+		records, err = querier.Query(ctx, exportFlux(cfg.Influx_Bucket, opts, start, end))
+	}
+	if err != nil {
+		appLogger.Error("Failed to query InfluxDB", slog.String("error", err.Error()))
+		return 1
+	}
+
+	rows := make([]map[string]string, 0, len(records))
+	for _, record := range records {
+		rows = append(rows, exportRow(record))
+	}
+	slices.SortStableFunc(rows, func(a, b map[string]string) int {
+		ta, _ := time.Parse(time.RFC3339Nano, a["time"])
+		tb, _ := time.Parse(time.RFC3339Nano, b["time"])
+		return ta.Compare(tb)
+	})
+
+	if opts.format == exportJSON {
+		err = writeExportJSON(w, rows)
+	} else {
+		err = writeExportCSV(w, rows)
+	}
+	if err != nil {
+		appLogger.Error("Failed to write export", slog.String("error", err.Error()))
+		return 1
+	}
+	appLogger.Info("Export finished", slog.Int("rows", len(rows)))
+	return 0
+}
+
+// parseExportTime parses an RFC 3339 time, or a date starting at midnight
+// in loc
+func parseExportTime(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, loc)
+}
+
+// exportFlux returns the Flux query for an export, with one row per
+// measurement, series and time
+func exportFlux(bucket string, opts exportOptions, start, end time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "from(bucket: %q)\n", bucket)
+	fmt.Fprintf(&b, "  |> range(start: %s, stop: %s)\n", start.UTC().Format(time.RFC3339Nano), end.UTC().Format(time.RFC3339Nano))
+	if opts.measurement != "" {
+		fmt.Fprintf(&b, "  |> filter(fn: (r) => r._measurement == %q)\n", opts.measurement)
+	}
+	if opts.station != "" {
+		fmt.Fprintf(&b, "  |> filter(fn: (r) => r.station == %q)\n", opts.station)
+	}
+	b.WriteString(`  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")` + "\n")
+	b.WriteString(`  |> drop(columns: ["_start", "_stop"])` + "\n")
+	return b.String()
+}
+
+// exportInfluxQL returns the InfluxQL query for an export on InfluxDB 1.x
+func exportInfluxQL(opts exportOptions, start, end time.Time) string {
+	from := "/.*/"
+	if opts.measurement != "" {
+		from = `"` + strings.ReplaceAll(opts.measurement, `"`, `\"`) + `"`
+	}
+	q := fmt.Sprintf("SELECT * FROM %s WHERE time >= '%s' AND time < '%s'",
+		from, start.UTC().Format(time.RFC3339Nano), end.UTC().Format(time.RFC3339Nano))
+	if opts.station != "" {
+		q += ` AND "station" = '` + strings.ReplaceAll(opts.station, `'`, `\'`) + `'`
+	}
+	return q
+}
+
+// exportRow converts a Flux or InfluxQL record into a row with time and
+// measurement columns, dropping query metadata and empty values
+func exportRow(record influx.Record) map[string]string {
+	row := make(map[string]string, len(record))
+	for column, value := range record {
+		if value == "" {
+			continue
+		}
+		switch column {
+		case "result", "table", "tags":
+		case "_time":
+			row["time"] = value
+		case "_measurement", "name":
+			row["measurement"] = value
+		case "time":
+			// InfluxQL returns nanoseconds since the epoch
+			if ns, err := strconv.ParseInt(value, 10, 64); err == nil {
+				value = time.Unix(0, ns).UTC().Format(time.RFC3339Nano)
+			}
+			row["time"] = value
+		default:
+			row[column] = value
+		}
+	}
+	return row
+}
+
+// exportColumns returns time and measurement followed by all other columns
+// of rows in alphabetical order
+func exportColumns(rows []map[string]string) []string {
+	seen := map[string]bool{"time": true, "measurement": true}
+	var rest []string
+	for _, row := range rows {
+		for column := range row {
+			if !seen[column] {
+				seen[column] = true
+				rest = append(rest, column)
+			}
+		}
+	}
+	slices.Sort(rest)
+	return append([]string{"time", "measurement"}, rest...)
+}
+
+// writeExportCSV writes rows with a header of all columns
+func writeExportCSV(w io.Writer, rows []map[string]string) error {
+	columns := exportColumns(rows)
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	values := make([]string, len(columns))
+	for _, row := range rows {
+		for i, column := range columns {
+			values[i] = row[column]
+		}
+		if err := cw.Write(values); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeExportJSON writes rows as an array of objects, with numeric values
+// as JSON numbers
+func writeExportJSON(w io.Writer, rows []map[string]string) error {
+	objects := make([]map[string]any, 0, len(rows))
+	for _, row := range rows {
+		object := make(map[string]any, len(row))
+		for column, value := range row {
+			if _, err := strconv.ParseFloat(value, 64); err == nil && json.Valid([]byte(value)) {
+				object[column] = json.Number(value)
+			} else {
+				object[column] = value
+			}
+		}
+		objects = append(objects, object)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(objects)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+// exportStub returns fixed records and remembers the last query
+type exportStub struct {
+	flux     string
+	influxql string
+	records  []influx.Record
+}
+
+func (s *exportStub) Query(ctx context.Context, flux string) ([]influx.Record, error) {
+	s.flux = flux
+	return s.records, nil
+}
+
+func (s *exportStub) QueryInfluxQL(ctx context.Context, influxql string) ([]influx.Record, error) {
+	s.influxql = influxql
+	return s.records, nil
+}
+
+func TestExportCSV(t *testing.T) {
+	cfg := &config.Config{Influx_Bucket: "weather", Influx_API_Path: influx.V2WritePath, Timezone: "UTC"}
+	q := &exportStub{records: []influx.Record{
+		{"result": "_result", "table": "1", "_time": "2024-03-01T00:01:00Z", "_measurement": "weather", "station": "ST-1", "temp": "12.5"},
+		{"result": "_result", "table": "0", "_time": "2024-03-01T00:00:00Z", "_measurement": "weather", "station": "ST-1", "temp": "12", "summary": "Clear, calm"},
+	}}
+	var out bytes.Buffer
+
+	opts := exportOptions{start: "2024-03-01", end: "2024-03-02", station: "ST-1"}
+	if code := export(cfg, logger.New(&config.Config{}), q, opts, &out); code != 0 {
+		t.Fatalf("export() exit code = %d, want 0", code)
+	}
+	for _, want := range []string{`from(bucket: "weather")`, "range(start: 2024-03-01T00:00:00Z, stop: 2024-03-02T00:00:00Z)", `r.station == "ST-1"`, "pivot("} {
+		if !strings.Contains(q.flux, want) {
+			t.Errorf("Expected %q in query:\n%s", want, q.flux)
+		}
+	}
+
+	want := "time,measurement,station,summary,temp\n" +
+		"2024-03-01T00:00:00Z,weather,ST-1,\"Clear, calm\",12\n" +
+		"2024-03-01T00:01:00Z,weather,ST-1,,12.5\n"
+	if out.String() != want {
+		t.Errorf("export() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestExportJSONInfluxQL(t *testing.T) {
+	cfg := &config.Config{Influx_Bucket: "weather", Influx_API_Path: influx.V1WritePath, Timezone: "UTC"}
+	q := &exportStub{records: []influx.Record{
+		{"name": "weather", "tags": "", "time": "1709251200000000000", "station": "ST-1", "temp": "12.5"},
+	}}
+	var out bytes.Buffer
+
+	opts := exportOptions{start: "2024-03-01T00:00:00Z", end: "2024-03-02T00:00:00Z", measurement: "weather", format: exportJSON}
+	if code := export(cfg, logger.New(&config.Config{}), q, opts, &out); code != 0 {
+		t.Fatalf("export() exit code = %d, want 0", code)
+	}
+	want := `SELECT * FROM "weather" WHERE time >= '2024-03-01T00:00:00Z' AND time < '2024-03-02T00:00:00Z'`
+	if q.influxql != want {
+		t.Errorf("query = %s, want %s", q.influxql, want)
+	}
+
+	var rows []map[string]any
+	if err := json.Unmarshal(out.Bytes(), &rows); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", out.String(), err)
+	}
+	if len(rows) != 1 || rows[0]["time"] != "2024-03-01T00:00:00Z" || rows[0]["temp"] != 12.5 || rows[0]["station"] != "ST-1" {
+		t.Errorf("Unexpected rows %v", rows)
+	}
+}
+
+func TestExportOptions(t *testing.T) {
+	cfg := &config.Config{Timezone: "UTC"}
+	for _, opts := range []exportOptions{
+		{},
+		{start: "March"},
+		{start: "2024-03-02", end: "2024-03-01"},
+		{start: "2024-03-01", format: "xml"},
+	} {
+		if code := export(cfg, logger.New(&config.Config{}), &exportStub{}, opts, &bytes.Buffer{}); code == 0 {
+			t.Errorf("Expected non-zero exit code for %+v", opts)
+		}
+	}
+}
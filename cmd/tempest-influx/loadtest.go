@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/metrics"
+	flag "github.com/spf13/pflag"
+)
+
+// receivedMetric is the collector counter the load test compares against
+const receivedMetric = metrics.Namespace + "_packets_received_total"
+
+// loadtestTick is how often a batch of packets is sent; at high rates
+// sending each packet on its own tick would be limited by timer resolution
+const loadtestTick = 10 * time.Millisecond
+
+// loadtestOptions configures a load test
+type loadtestOptions struct {
+	target   string
+	rate     int
+	duration time.Duration
+	stations int
+	metrics  string
+	settle   time.Duration
+}
+
+// loadtestResult summarizes a load test
+type loadtestResult struct {
+	sent     int
+	elapsed  time.Duration
+	received float64 // -1 when not measured
+}
+
+// runLoadtest sends synthetic packets to a running collector and reports
+// the achieved rate and, with --metrics, how many packets were lost
+func runLoadtest(args []string) int {
+	opts := loadtestOptions{settle: 2 * time.Second}
+	flags := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	flags.StringVar(&opts.target, "target", "127.0.0.1:50222", "Collector UDP address")
+	flags.IntVar(&opts.rate, "rate", 100, "Packets per second")
+	flags.DurationVar(&opts.duration, "duration", 10*time.Second, "Length of the test")
+	flags.IntVar(&opts.stations, "stations", 1, "Number of simulated stations")
+	flags.StringVar(&opts.metrics, "metrics", "", "Collector metrics URL used to count received packets (e.g. http://127.0.0.1:9090/metrics)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if opts.rate <= 0 || opts.duration <= 0 || opts.stations <= 0 {
+		log.Printf("--rate, --duration and --stations must be positive")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := loadtest(ctx, opts)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	result.write(os.Stdout)
+	return 0
+}
+
+// loadtest runs the test described by opts
+func loadtest(ctx context.Context, opts loadtestOptions) (loadtestResult, error) {
+	result := loadtestResult{received: -1}
+	conn, err := net.Dial("udp", opts.target)
+	if err != nil {
+		return result, fmt.Errorf("connecting to %s: %w", opts.target, err)
+	}
+	defer conn.Close()
+
+	var before float64
+	if opts.metrics != "" {
+		if before, err = scrapeReceived(ctx, opts.metrics); err != nil {
+			return result, err
+		}
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(loadtestTick)
+	defer ticker.Stop()
+	for running := true; running; {
+		select {
+		case <-ctx.Done():
+			running = false
+		case now := <-ticker.C:
+			elapsed := min(now.Sub(start), opts.duration)
+			due := int(elapsed.Seconds() * float64(opts.rate))
+			for ; result.sent < due; result.sent++ {
+				// Errors such as ICMP port unreachable are counted as loss
+				_, _ = conn.Write(syntheticPacket(result.sent, opts.stations, now))
+			}
+			running = elapsed < opts.duration
+		}
+	}
+	result.elapsed = time.Since(start)
+
+	if opts.metrics != "" {
+		// Give the collector time to drain its queue
+		select {
+		case <-time.After(opts.settle):
+		case <-ctx.Done():
+		}
+		after, err := scrapeReceived(context.Background(), opts.metrics)
+		if err != nil {
+			return result, err
+		}
+		result.received = after - before
+	}
+	return result, nil
+}
+
+// syntheticPacket returns the i-th test packet: rapid wind reports spread
+// over the simulated stations, with a full observation every 20th packet.
+// Wind speeds cycle so that the values stay plausible.
+func syntheticPacket(i, stations int, now time.Time) []byte {
+	serial := fmt.Sprintf("ST-LOADTEST-%03d", i%stations)
+	speed := float64(i%150) / 10
+	if i%20 == 0 {
+		return fmt.Appendf(nil,
+			`{"serial_number":%q,"type":"obs_st","hub_sn":"HB-LOADTEST","obs":[[%d,%.1f,%.1f,%.1f,180,3,1013.2,21.5,60,20000,2.1,150,0,0,0,0,2.7,1]],"firmware_revision":176}`,
+			serial, now.Unix(), speed/2, speed, speed*1.5)
+	}
+	return fmt.Appendf(nil, `{"serial_number":%q,"type":"rapid_wind","hub_sn":"HB-LOADTEST","ob":[%d,%.1f,%d]}`,
+		serial, now.Unix(), speed, i%360)
+}
+
+// scrapeReceived reads the received packet counter from a metrics endpoint
+func scrapeReceived(ctx context.Context, url string) (float64, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("reading collector metrics: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("reading collector metrics: %s", resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), receivedMetric+" "); ok {
+			return strconv.ParseFloat(strings.TrimSpace(value), 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("reading collector metrics: %w", err)
+	}
+	return 0, fmt.Errorf("metric %s not found at %s", receivedMetric, url)
+}
+
+// write prints the result
+func (r loadtestResult) write(w io.Writer) {
+	rate := float64(r.sent) / r.elapsed.Seconds()
+	fmt.Fprintf(w, "sent:     %d packets in %s (%.0f/s)\n", r.sent, r.elapsed.Round(time.Millisecond), rate)
+	if r.received < 0 {
+		fmt.Fprintln(w, "received: not measured (use --metrics)")
+		return
+	}
+	var loss float64
+	if r.sent > 0 {
+		loss = 100 * (1 - r.received/float64(r.sent))
+	}
+	fmt.Fprintf(w, "received: %.0f packets (%.2f%% loss)\n", r.received, loss)
+}
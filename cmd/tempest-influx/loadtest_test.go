@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/tempest"
+)
+
+func TestSyntheticPacketsParse(t *testing.T) {
+	cfg := &config.Config{Rapid_Wind: true}
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50222}
+	for i := range 40 {
+		b := syntheticPacket(i, 3, time.Unix(1700000000, 0))
+		m, err := tempest.Parse(cfg, addr, b, len(b))
+		if err != nil || m == nil {
+			t.Fatalf("Packet %d %s did not parse: %v", i, b, err)
+		}
+		if want := fmt.Sprintf("ST-LOADTEST-%03d", i%3); m.Tags["station"] != want {
+			t.Errorf("Expected station %s, got %s", want, m.Tags["station"])
+		}
+	}
+}
+
+func TestLoadtest(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var received atomic.Int64
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			if _, _, err := conn.ReadFrom(buf); err != nil {
+				return
+			}
+			received.Add(1)
+		}
+	}()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "# TYPE %s counter\n%s %d\n", receivedMetric, receivedMetric, received.Load())
+	}))
+	defer srv.Close()
+
+	result, err := loadtest(context.Background(), loadtestOptions{
+		target:   conn.LocalAddr().String(),
+		rate:     1000,
+		duration: 200 * time.Millisecond,
+		stations: 2,
+		metrics:  srv.URL,
+		settle:   100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("loadtest() error = %v", err)
+	}
+	if result.sent != 200 {
+		t.Errorf("Expected 200 packets sent, got %d", result.sent)
+	}
+	if result.received <= 0 || result.received > float64(result.sent) {
+		t.Errorf("Expected received count between 1 and %d, got %v", result.sent, result.received)
+	}
+
+	var out bytes.Buffer
+	result.write(&out)
+	if !strings.Contains(out.String(), "loss") {
+		t.Errorf("Expected loss in output, got %q", out.String())
+	}
+}
@@ -4,19 +4,75 @@ import (
 	"context"
 	"log"
 	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"runtime/debug"
 	"syscall"
+	// Station time zones must load in minimal containers without zoneinfo
+	_ "time/tzdata"
 
+	"github.com/jacaudi/tempest-influxdb/internal/api"
 	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/debugvars"
+	"github.com/jacaudi/tempest-influxdb/internal/forecast"
+	"github.com/jacaudi/tempest-influxdb/internal/grpcapi"
+	"github.com/jacaudi/tempest-influxdb/internal/handoff"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/lifecycle"
 	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/nearcast"
+	"github.com/jacaudi/tempest-influxdb/internal/ntp"
+	"github.com/jacaudi/tempest-influxdb/internal/official"
 	"github.com/jacaudi/tempest-influxdb/internal/processor"
+	"github.com/jacaudi/tempest-influxdb/internal/registry"
+	"github.com/jacaudi/tempest-influxdb/internal/retention"
+	"github.com/jacaudi/tempest-influxdb/internal/server"
+	"github.com/jacaudi/tempest-influxdb/internal/stationmeta"
+	"github.com/jacaudi/tempest-influxdb/internal/stream"
+	"github.com/jacaudi/tempest-influxdb/internal/tempest"
+	"github.com/jacaudi/tempest-influxdb/internal/watchdog"
+	"github.com/jacaudi/tempest-influxdb/internal/weatherflow"
 	"github.com/samber/lo"
 )
 
+// configName is the base name of the YAML configuration file
+const configName = "tempest-influxdb"
+
+// version and commit identify the build; release builds set them with
+// -ldflags -X
+var (
+	version = "2.0.0"
+	commit  string
+)
+
+// subcommands maps command names to their entry points; each returns the
+// process exit code
+var subcommands = map[string]func(args []string) int{
+	"requeue":  runRequeue,
+	"report":   runReport,
+	"loadtest": runLoadtest,
+	"config":   runConfig,
+	"export":   runExport,
+}
+
+// getConfigDir returns the configuration directory
+func getConfigDir() string {
+	// Check for config path override using Lo library patterns
+	return lo.CoalesceOrEmpty(os.Getenv("TEMPEST_INFLUX_CONFIG_DIR"), "/config")
+}
+
 func main() {
 	log.SetPrefix("tempest-influxdb: ")
 
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -25,14 +81,22 @@ func main() {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
-	// Check for config path override using Lo library patterns
-	configDir := lo.CoalesceOrEmpty(os.Getenv("TEMPEST_INFLUX_CONFIG_DIR"), "/config")
+	configDir := getConfigDir()
 
-	cfg := config.Load(configDir, "tempest-influxdb")
+	cfg := config.Load(configDir, configName)
 
 	// Initialize structured logger
 	appLogger := logger.New(cfg)
 
+	// Report configuration warnings; in strict mode they already failed Load
+	report := cfg.Check()
+	for _, warning := range report.Warnings {
+		appLogger.Warn("Configuration warning", slog.String("warning", warning))
+	}
+	appLogger.Info("Configuration validated",
+		slog.Bool("strict", cfg.Strict),
+		slog.Int("warnings", len(report.Warnings)))
+
 	go func() {
 		<-sigCh
 		appLogger.Info("Received shutdown signal")
@@ -41,7 +105,7 @@ func main() {
 
 	appLogger.Info("Starting tempest-influxdb",
 		slog.String("config_dir", configDir),
-		slog.String("version", "2.0.0"))
+		slog.String("version", version))
 
 	if cfg.Debug {
 		appLogger.Debug("Configuration loaded",
@@ -62,16 +126,302 @@ func main() {
 		slog.String("influx_org", cfg.Influx_Org),
 		slog.String("bucket", cfg.Influx_Bucket),
 		slog.Bool("rapid_wind", cfg.Rapid_Wind),
-		slog.String("rapid_wind_bucket", cfg.Influx_Bucket_Rapid_Wind))
+		slog.String("rapid_wind_bucket", cfg.Influx_Bucket_Rapid_Wind),
+		slog.Int("influx_mirrors", len(cfg.Influx_Mirrors)),
+		slog.String("http_listen_address", cfg.HTTP_Listen_Address))
+
+	influxClient, err := influx.NewHTTPClient(cfg)
+	if err != nil {
+		appLogger.Error("Failed to configure InfluxDB TLS", slog.String("error", err.Error()))
+		return
+	}
+	influx.ResolveAPIPath(ctx, cfg, influxClient, appLogger)
+	influx.DefaultSchema.SetIntegerFields(cfg.Influx_Integer_Fields)
+	tempest.SetLogger(appLogger)
+
+	// Before anything reads the station locations
+	var metadata map[int]stationmeta.Metadata
+	if cfg.Station_Metadata {
+		metadata = fetchStationMetadata(ctx, cfg, appLogger)
+		stationmeta.Apply(cfg, metadata)
+	}
+
+	if cfg.Ntp_Server != "" {
+		go ntp.NewChecker(cfg, appLogger).Run(ctx)
+	}
+
+	if retention.Enabled(cfg) {
+		go retention.New(cfg, appLogger).Run(ctx)
+	}
+
+	if cfg.Nearcast_Rain {
+		if err := startNearcast(ctx, cfg, appLogger); err != nil {
+			appLogger.Error("Failed to start corrected rain sync", slog.String("error", err.Error()))
+			return
+		}
+	}
 
-	// Use the service-oriented approach
-	service, err := processor.NewWeatherService(cfg, appLogger)
+	if cfg.Forecast {
+		if err := startForecast(ctx, cfg, appLogger); err != nil {
+			appLogger.Error("Failed to start forecast polling", slog.String("error", err.Error()))
+			return
+		}
+	}
+
+	var registryPath string
+	if cfg.State_Dir != "" {
+		registryPath = filepath.Join(cfg.State_Dir, registry.FileName)
+	}
+	devices, err := registry.New(registryPath, appLogger)
 	if err != nil {
-		appLogger.Error("Failed to create weather service", slog.String("error", err.Error()))
+		appLogger.Error("Failed to load station registry", slog.String("error", err.Error()))
 		return
 	}
+	defer devices.Flush()
+	debugvars.SetRegistry(devices)
+	opts := []processor.Option{processor.WithPacketObservers(devices)}
+
+	// With graceful upgrades the sockets outlive the pipeline, so that they
+	// can be handed over to a new process
+	var sockets *handoff.Sockets
+	if cfg.Graceful_Upgrade {
+		sockets = handoff.Inherit(appLogger)
+		if cfg.Input == "" || cfg.Input == config.InputUDP {
+			conn, err := sockets.ListenUDP(cfg.Listen_Address)
+			if err != nil {
+				appLogger.Error("Failed to listen for broadcasts", slog.String("error", err.Error()))
+				return
+			}
+			opts = append(opts, processor.WithListener(conn))
+		}
+	}
+
+	if cfg.HasOfficialStations() {
+		comparer, err := startOfficial(ctx, cfg, appLogger)
+		if err != nil {
+			appLogger.Error("Failed to start official station comparison", slog.String("error", err.Error()))
+			return
+		}
+		opts = append(opts, processor.WithObservers(comparer))
+	}
+
+	var store *api.Store
+	if cfg.HTTP_Listen_Address != "" {
+		store = api.NewStore(cfg)
+		store.ServeRegistry(devices)
+		opts = append(opts, processor.WithObservers(store))
+	}
+	if cfg.GRPC_Listen_Address != "" {
+		hub := stream.NewHub("grpc", stream.DefaultBuffer)
+		opts = append(opts, processor.WithObservers(hub))
+		grpcServer := grpcapi.New(cfg, appLogger, hub)
+		if lis, err := listen(sockets, "grpc", cfg.GRPC_Listen_Address); err != nil {
+			appLogger.Error("gRPC server error", slog.String("error", err.Error()))
+		} else {
+			go func() {
+				if err := grpcServer.Serve(ctx, lis); err != nil {
+					appLogger.Error("gRPC server error", slog.String("error", err.Error()))
+				}
+			}()
+		}
+	}
+
+	if cfg.HTTP_Listen_Address != "" {
+		httpServer := server.New(cfg, appLogger)
+		httpServer.Handle(api.Prefix, store.Handler())
+		if lis, err := listen(sockets, "http", cfg.HTTP_Listen_Address); err != nil {
+			appLogger.Error("HTTP server error", slog.String("error", err.Error()))
+		} else {
+			go func() {
+				if err := httpServer.Serve(ctx, lis); err != nil {
+					appLogger.Error("HTTP server error", slog.String("error", err.Error()))
+				}
+			}()
+		}
+	}
+
+	var events *lifecycle.Recorder
+	if cfg.Lifecycle_Events {
+		if events, err = startLifecycle(cfg, appLogger); err != nil {
+			appLogger.Error("Failed to start lifecycle events", slog.String("error", err.Error()))
+			return
+		}
+	}
+	stopReason := "shutdown"
+	upgraded := make(chan struct{})
+	if sockets != nil && sockets.Inherited() {
+		events.Upgraded()
+	} else {
+		events.Started()
+	}
+	defer func() {
+		select {
+		case <-upgraded:
+			events.HandedOver()
+		default:
+			events.Stopped(stopReason)
+		}
+	}()
+	if sockets != nil {
+		// The previous process stops once this one is ready
+		sockets.Ready()
+		go sockets.Run(ctx, func() {
+			close(upgraded)
+			cancel()
+		})
+	}
+
+	for {
+		// The watchdog and remote configuration changes restart the
+		// pipeline by cancelling its context
+		pipelineCtx, restart := context.WithCancel(ctx)
+		service, err := processor.NewWeatherService(cfg, appLogger, opts...)
+		if err != nil {
+			restart()
+			appLogger.Error("Failed to create weather service", slog.String("error", err.Error()))
+			stopReason = "error: " + err.Error()
+			return
+		}
+		debugvars.SetQueue(service.Queue)
+		if cfg.WatchdogEnabled() {
+			go watchdog.New(cfg, appLogger, service.Queue, restart).Run(pipelineCtx)
+		}
+
+		var next *config.Config
+		reload := make(chan struct{})
+		go config.WatchRemote(pipelineCtx, cfg, func() bool {
+			if next = reloadConfig(configDir, appLogger); next == nil {
+				return false
+			}
+			close(reload)
+			restart()
+			return true
+		}, func(err error) {
+			appLogger.Warn("Failed to check remote configuration", slog.String("error", err.Error()))
+		})
+
+		err = service.Start(pipelineCtx)
+		restarting := ctx.Err() == nil && pipelineCtx.Err() != nil
+		restart()
+		if err != nil && err != context.Canceled {
+			appLogger.Error("Weather service error", slog.String("error", err.Error()))
+			stopReason = "error: " + err.Error()
+		}
+		if !restarting {
+			return
+		}
+		select {
+		case <-reload:
+			cfg = next
+			// TLS settings that cannot be loaded fail the next pipeline
+			if client, err := influx.NewHTTPClient(cfg); err == nil {
+				influx.ResolveAPIPath(ctx, cfg, client, appLogger)
+			}
+			stationmeta.Apply(cfg, metadata)
+			appLogger.Info("Weather service restarted with changed remote configuration")
+			events.Restarted("remote configuration changed")
+		default:
+			debug.FreeOSMemory()
+			appLogger.Warn("Weather service restarted by watchdog")
+			events.Restarted("watchdog")
+		}
+	}
+}
+
+// reloadConfig loads the configuration again after the remote configuration
+// changed, returning nil if it is invalid. The new configuration applies to
+// the pipeline; listeners and background jobs keep their settings until the
+// process restarts.
+func reloadConfig(configDir string, appLogger *logger.AppLogger) *config.Config {
+	next, err := config.NewLoader(configDir, configName, os.Args[1:]).Load()
+	if err != nil {
+		appLogger.Error("Remote configuration changed but is invalid, keeping the current configuration",
+			slog.String("error", err.Error()))
+		return nil
+	}
+	return next
+}
+
+// listen listens for the server called name on addr, taking over the
+// listener of a previous process when sockets is set
+func listen(sockets *handoff.Sockets, name, addr string) (net.Listener, error) {
+	if sockets == nil {
+		return net.Listen("tcp", addr)
+	}
+	return sockets.Listen(name, addr)
+}
+
+// fetchStationMetadata fetches the metadata of the configured stations,
+// cached in State_Dir when set
+func fetchStationMetadata(ctx context.Context, cfg *config.Config, appLogger *logger.AppLogger) map[int]stationmeta.Metadata {
+	var path string
+	if cfg.State_Dir != "" {
+		path = filepath.Join(cfg.State_Dir, stationmeta.FileName)
+	}
+	cloud := weatherflow.NewClient(cfg.WeatherFlow_Token, &http.Client{Timeout: cfg.Influx_Client_Timeout})
+	return stationmeta.Fetch(ctx, cfg, cloud, path, appLogger)
+}
+
+// startLifecycle creates the recorder of lifecycle events
+func startLifecycle(cfg *config.Config, appLogger *logger.AppLogger) (*lifecycle.Recorder, error) {
+	client, err := influx.NewHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := influx.NewWriter(cfg, client, appLogger)
+	if err != nil {
+		return nil, err
+	}
+	return lifecycle.New(cfg, appLogger, writer, version, lo.CoalesceOrEmpty(commit, lifecycle.Commit())), nil
+}
+
+// startNearcast runs the job writing Rain Check corrected rain in the
+// background
+func startNearcast(ctx context.Context, cfg *config.Config, appLogger *logger.AppLogger) error {
+	client, err := influx.NewHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+	querier, err := influx.NewQueryClient(cfg, client)
+	if err != nil {
+		return err
+	}
+	writer, err := influx.NewWriter(cfg, client, appLogger)
+	if err != nil {
+		return err
+	}
+	cloud := weatherflow.NewClient(cfg.WeatherFlow_Token, &http.Client{Timeout: cfg.Influx_Client_Timeout})
+	go nearcast.New(cfg, appLogger, cloud, querier, writer).Run(ctx)
+	return nil
+}
+
+// startForecast runs the forecast poller in the background
+func startForecast(ctx context.Context, cfg *config.Config, appLogger *logger.AppLogger) error {
+	client, err := influx.NewHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+	writer, err := influx.NewWriter(cfg, client, appLogger)
+	if err != nil {
+		return err
+	}
+	cloud := weatherflow.NewClient(cfg.WeatherFlow_Token, &http.Client{Timeout: cfg.Influx_Client_Timeout})
+	go forecast.New(cfg, appLogger, cloud, writer).Run(ctx)
+	return nil
+}
 
-	if err := service.Start(ctx); err != nil && err != context.Canceled {
-		appLogger.Error("Weather service error", slog.String("error", err.Error()))
+// startOfficial runs the comparison with official stations in the
+// background and returns it, to be fed the observations
+func startOfficial(ctx context.Context, cfg *config.Config, appLogger *logger.AppLogger) (*official.Comparer, error) {
+	influxClient, err := influx.NewHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := influx.NewWriter(cfg, influxClient, appLogger)
+	if err != nil {
+		return nil, err
 	}
+	comparer := official.New(cfg, appLogger, &http.Client{Timeout: cfg.Influx_Client_Timeout}, writer)
+	go comparer.Run(ctx)
+	return comparer, nil
 }
@@ -145,36 +145,30 @@ func TestLogPrefixSetting(t *testing.T) {
 
 // Integration test that simulates main function components
 func TestMainIntegration(t *testing.T) {
-	// Skip this test due to flag redefinition issues when config.Load is called multiple times in tests
-	t.Skip("Skipping integration test due to global flag conflicts")
-
 	// Skip this test in short mode
 	if testing.Short() {
 		t.Skip("Skipping integration test in short mode")
 	}
 
-	// Set up minimal environment
-	os.Setenv("TEMPEST_INFLUX_INFLUX_URL", "http://localhost:8086/api/v2/write")
-	os.Setenv("TEMPEST_INFLUX_INFLUX_TOKEN", "test-token")
-	os.Setenv("TEMPEST_INFLUX_INFLUX_BUCKET", "test-bucket")
-	defer func() {
-		os.Unsetenv("TEMPEST_INFLUX_INFLUX_URL")
-		os.Unsetenv("TEMPEST_INFLUX_INFLUX_TOKEN")
-		os.Unsetenv("TEMPEST_INFLUX_INFLUX_BUCKET")
-	}()
+	args := []string{
+		"--influx_url", "http://localhost:8086",
+		"--influx_org", "test-org",
+		"--influx_token", "test-token",
+		"--influx_bucket", "test-bucket",
+	}
 
 	// Test the main function components in sequence
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 	defer cancel()
 
 	// Load config
-	cfg := config.Load("/tmp", "tempest-influxdb")
-	if cfg == nil {
-		t.Fatal("Failed to load config")
+	cfg, err := config.NewLoader(t.TempDir(), "tempest-influxdb", args).Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
 	}
 
 	// Validate config
-	err := cfg.Validate()
+	err = cfg.Validate()
 	if err != nil {
 		t.Fatalf("Config validation failed: %v", err)
 	}
@@ -193,17 +187,17 @@ func TestMainIntegration(t *testing.T) {
 
 // Benchmark the main function components
 func BenchmarkConfigLoad(b *testing.B) {
-	os.Setenv("TEMPEST_INFLUX_INFLUX_URL", "http://localhost:8086/api/v2/write")
-	os.Setenv("TEMPEST_INFLUX_INFLUX_TOKEN", "test-token")
-	os.Setenv("TEMPEST_INFLUX_INFLUX_BUCKET", "test-bucket")
-	defer func() {
-		os.Unsetenv("TEMPEST_INFLUX_INFLUX_URL")
-		os.Unsetenv("TEMPEST_INFLUX_INFLUX_TOKEN")
-		os.Unsetenv("TEMPEST_INFLUX_INFLUX_BUCKET")
-	}()
+	args := []string{
+		"--influx_url", "http://localhost:8086",
+		"--influx_org", "test-org",
+		"--influx_token", "test-token",
+		"--influx_bucket", "test-bucket",
+	}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		config.Load("/tmp", "tempest-influxdb")
+		if _, err := config.NewLoader("/tmp", "tempest-influxdb", args).Load(); err != nil {
+			b.Fatal(err)
+		}
 	}
 }
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/climate"
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+// reportOptions selects the period and form of a climate report
+type reportOptions struct {
+	month   string // YYYY-MM
+	year    string // YYYY
+	station string
+	format  string
+}
+
+// runReport prints a monthly or yearly climatological summary of the data
+// in the configured bucket
+func runReport(args []string) int {
+	loader := config.NewLoader(getConfigDir(), configName, args)
+	var opts reportOptions
+	flags := loader.FlagSet()
+	flags.StringVar(&opts.month, "month", "", "Month to summarize (YYYY-MM)")
+	flags.StringVar(&opts.year, "year", "", "Year to summarize (YYYY)")
+	flags.StringVar(&opts.station, "station", "", "Station serial number (all stations when empty)")
+	flags.StringVar(&opts.format, "format", climate.FormatText, "Output format: text or html")
+
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+
+	appLogger := logger.New(cfg)
+
+	httpClient, err := influx.NewHTTPClient(cfg)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	influx.ResolveAPIPath(context.Background(), cfg, httpClient, appLogger)
+	// Query with the credentials of the bucket observations are routed to
+	queryCfg := *cfg
+	queryCfg.Influx_Bucket, _ = climate.Storage(cfg, opts.station)
+	client, err := influx.NewQueryClient(&queryCfg, httpClient)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	return report(cfg, appLogger, client, opts, os.Stdout)
+}
+
+// report writes the summary selected by opts to w
+func report(cfg *config.Config, appLogger *logger.AppLogger, querier climate.Querier, opts reportOptions, w io.Writer) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if opts.format == "" {
+		opts.format = climate.FormatText
+	}
+	if opts.format != climate.FormatText && opts.format != climate.FormatHTML {
+		appLogger.Error("Invalid --format, expected text or html", slog.String("format", opts.format))
+		return 1
+	}
+
+	loc, err := cfg.Location(opts.station)
+	if err != nil {
+		appLogger.Error("Invalid time zone", slog.String("error", err.Error()))
+		return 1
+	}
+
+	var start, end time.Time
+	switch {
+	case opts.month != "" && opts.year == "":
+		t, err := time.ParseInLocation("2006-01", opts.month, loc)
+		if err != nil {
+			appLogger.Error("Invalid --month, expected YYYY-MM", slog.String("month", opts.month))
+			return 1
+		}
+		start, end = climate.MonthRange(t)
+	case opts.year != "" && opts.month == "":
+		t, err := time.ParseInLocation("2006", opts.year, loc)
+		if err != nil {
+			appLogger.Error("Invalid --year, expected YYYY", slog.String("year", opts.year))
+			return 1
+		}
+		start, end = climate.YearRange(t)
+	default:
+		appLogger.Error("Exactly one of --month or --year is required")
+		return 1
+	}
+
+	source, err := climate.NewSource(cfg, querier, opts.station, loc)
+	if err != nil {
+		appLogger.Error("Invalid units", slog.String("error", err.Error()))
+		return 1
+	}
+	days, err := source.Days(ctx, start, end)
+	if err != nil {
+		appLogger.Error("Failed to query InfluxDB", slog.String("error", err.Error()))
+		return 1
+	}
+
+	station := opts.station
+	if station == "" {
+		station = "all stations"
+	}
+	summary := climate.Monthly(station, days)
+	if opts.year != "" {
+		summary = climate.Yearly(station, days)
+	}
+	if err := summary.Write(w, opts.format); err != nil {
+		appLogger.Error("Failed to write report", slog.String("error", err.Error()))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+type stubQuerier struct {
+	queries []string
+}
+
+func (s *stubQuerier) Query(ctx context.Context, flux string) ([]influx.Record, error) {
+	s.queries = append(s.queries, flux)
+	return []influx.Record{{"_time": "2024-02-10T00:00:00Z", "_value": "4"}}, nil
+}
+
+func (s *stubQuerier) QueryInfluxQL(ctx context.Context, influxql string) ([]influx.Record, error) {
+	s.queries = append(s.queries, influxql)
+	return nil, nil
+}
+
+func TestReport(t *testing.T) {
+	cfg := &config.Config{Influx_Bucket: "weather", Timezone: "UTC"}
+	q := &stubQuerier{}
+	var out bytes.Buffer
+
+	if code := report(cfg, logger.New(&config.Config{}), q, reportOptions{year: "2024"}, &out); code != 0 {
+		t.Fatalf("report() exit code = %d, want 0", code)
+	}
+	if len(q.queries) == 0 {
+		t.Error("Expected InfluxDB to be queried")
+	}
+	text := out.String()
+	if !strings.Contains(text, "ANNUAL CLIMATOLOGICAL SUMMARY for 2024") || !strings.Contains(text, "FEB") {
+		t.Errorf("Unexpected report:\n%s", text)
+	}
+}
+
+func TestReportStorage(t *testing.T) {
+	cfg := &config.Config{
+		Influx_Bucket:   "weather",
+		Influx_API_Path: "/write",
+		Timezone:        "UTC",
+		Influx_Routes: []config.InfluxRoute{
+			{Types: []string{"obs_st"}, Stations: []string{"ST-1"}, Bucket: "st1", Measurement: "tempest"},
+		},
+	}
+	q := &stubQuerier{}
+
+	if code := report(cfg, logger.New(&config.Config{}), q, reportOptions{month: "2024-03", station: "ST-1"}, &bytes.Buffer{}); code != 0 {
+		t.Fatalf("report() exit code = %d, want 0", code)
+	}
+	if len(q.queries) == 0 || !strings.HasPrefix(q.queries[0], `SELECT mean("temp") FROM "tempest"`) {
+		t.Errorf("Expected an InfluxQL query of the routed measurement, got %q", q.queries)
+	}
+}
+
+func TestReportOptions(t *testing.T) {
+	cfg := &config.Config{Timezone: "UTC"}
+	for _, opts := range []reportOptions{
+		{},
+		{month: "2024-03", year: "2024"},
+		{month: "March"},
+		{year: "2024", format: "pdf"},
+	} {
+		if code := report(cfg, logger.New(&config.Config{}), &stubQuerier{}, opts, &bytes.Buffer{}); code == 0 {
+			t.Errorf("Expected non-zero exit code for %+v", opts)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/quarantine"
+)
+
+// runRequeue replays quarantined points to InfluxDB, removing every entry
+// that is accepted
+func runRequeue(args []string) int {
+	cfg, err := config.NewLoader(getConfigDir(), configName, args).Load()
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	return requeue(cfg, logger.New(cfg))
+}
+
+// requeue replays the quarantine directory configured in cfg
+func requeue(cfg *config.Config, appLogger *logger.AppLogger) int {
+	if cfg.Quarantine_Dir == "" {
+		appLogger.Error("QUARANTINE_DIR is not configured")
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	store, err := quarantine.New(cfg.Quarantine_Dir)
+	if err != nil {
+		appLogger.Error("Failed to open quarantine", slog.String("error", err.Error()))
+		return 1
+	}
+
+	client, err := influx.NewHTTPClient(cfg)
+	if err != nil {
+		appLogger.Error("Failed to configure InfluxDB TLS", slog.String("error", err.Error()))
+		return 1
+	}
+	influx.ResolveAPIPath(ctx, cfg, client, appLogger)
+	influx.DefaultSchema.SetIntegerFields(cfg.Influx_Integer_Fields)
+	writer, err := influx.NewWriter(cfg, client, appLogger)
+	if err != nil {
+		appLogger.Error("Failed to create InfluxDB writer", slog.String("error", err.Error()))
+		return 1
+	}
+
+	result, err := store.Replay(ctx, writer.Write)
+	appLogger.Info("Requeue finished",
+		slog.String("quarantine_dir", store.Dir()),
+		slog.Int("replayed", result.Replayed),
+		slog.Int("failed", result.Failed),
+		slog.Int("points", result.Points))
+	if err != nil {
+		appLogger.Error("Requeue aborted", slog.String("error", err.Error()))
+		return 1
+	}
+	if result.Failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/quarantine"
+)
+
+func TestRequeue(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	store, err := quarantine.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := influx.New()
+	m.Name = "weather"
+	m.Bucket = "test-bucket"
+	m.Tags["station"] = "ST-123"
+	m.Fields["temp"] = 25.5
+	m.Timestamp = 1640995200
+	if _, err := store.Save([]*influx.Data{m}, "rejected"); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Influx_URL:      server.URL,
+		Influx_API_Path: "/api/v2/write",
+		Influx_Token:    "test-token",
+		Quarantine_Dir:  dir,
+	}
+
+	if code := requeue(cfg, logger.New(&config.Config{})); code != 0 {
+		t.Errorf("requeue() exit code = %d, want 0", code)
+	}
+	if requests != 1 {
+		t.Errorf("Expected 1 write request, got %d", requests)
+	}
+	if remaining, _ := store.List(); len(remaining) != 0 {
+		t.Errorf("Expected quarantine to be empty, got %d entries", len(remaining))
+	}
+}
+
+func TestRequeueKeepsFieldTypes(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	store, err := quarantine.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := influx.New()
+	m.Name = "weather"
+	m.Bucket = "test-bucket"
+	m.Tags["station"] = "ST-123"
+	m.Fields["temp"] = 25.0
+	m.Fields["strike_count"] = int64(3)
+	m.Fields["is_snow_likely"] = false
+	m.Timestamp = 1640995200
+	if _, err := store.Save([]*influx.Data{m}, "rejected"); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Influx_URL:      server.URL,
+		Influx_API_Path: "/api/v2/write",
+		Influx_Token:    "test-token",
+		Quarantine_Dir:  dir,
+
+		Influx_Integer_Fields: true,
+	}
+	t.Cleanup(func() { influx.DefaultSchema.SetIntegerFields(false) })
+	if code := requeue(cfg, logger.New(&config.Config{})); code != 0 {
+		t.Fatalf("requeue() exit code = %d, want 0", code)
+	}
+	if want := "weather,station=ST-123 is_snow_likely=false,strike_count=3i,temp=25 1640995200\n"; body != want {
+		t.Errorf("Replayed %q, want %q", body, want)
+	}
+}
+
+func TestRequeueRequiresDirectory(t *testing.T) {
+	if code := requeue(&config.Config{}, logger.New(&config.Config{})); code == 0 {
+		t.Error("Expected non-zero exit code without QUARANTINE_DIR")
+	}
+}
@@ -0,0 +1,164 @@
+// Package alert sends notifications to webhooks, ntfy, Pushover or Slack
+// when observations call for attention, such as a nearby lightning strike
+// or a field crossing a threshold
+package alert
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/tempest"
+)
+
+// LightningAlert is the name of lightning alerts
+const LightningAlert = "lightning"
+
+// Alert is the message sent to channels, and the JSON body and template
+// data of webhook channels
+type Alert struct {
+	Name    string        `json:"alert"`
+	Station string        `json:"station"`
+	Title   string        `json:"title"`
+	Message string        `json:"message"`
+	Time    time.Time     `json:"time"`
+	Fields  influx.Fields `json:"fields"`
+	// Resolved is set when a threshold alert is over
+	Resolved bool `json:"resolved,omitempty"`
+}
+
+// Enabled reports whether cfg configures any alert
+func Enabled(cfg *config.Config) bool {
+	return cfg.Lightning && cfg.Lightning_Alert_Distance > 0 || len(cfg.Alert_Rules) > 0
+}
+
+// Alerter is an output that sends an alert when a lightning strike is
+// within Lightning_Alert_Distance, at most once per station and
+// Lightning_Alert_Cooldown, and when a field of a station crosses the
+// threshold of an alert rule. Sending happens as points are written, so
+// alerts go out immediately.
+type Alerter struct {
+	cfg       *config.Config
+	lightning []*Channel
+	unit      string // unit symbol of strike_distance
+	now       func() time.Time
+
+	mu         sync.Mutex
+	sent       map[string]time.Time // last alert per alert name and station
+	thresholds []*threshold
+}
+
+// New creates an Alerter for the alert settings of cfg
+func New(cfg *config.Config, client HTTPClient) (*Alerter, error) {
+	a := &Alerter{
+		cfg:  cfg,
+		now:  time.Now,
+		sent: make(map[string]time.Time),
+	}
+	a.unit, _ = tempest.FieldUnit(cfg, "strike_distance")
+
+	channels := make(map[string]*Channel, len(cfg.Alert_Channels))
+	for _, ch := range cfg.Alert_Channels {
+		c, err := NewChannel(ch, client)
+		if err != nil {
+			return nil, err
+		}
+		channels[ch.Name] = c
+	}
+	pick := func(names []string) []*Channel {
+		var picked []*Channel
+		for _, ch := range cfg.AlertChannels(names) {
+			picked = append(picked, channels[ch.Name])
+		}
+		return picked
+	}
+
+	a.lightning = pick(cfg.Lightning_Alert_Channels)
+	for _, rule := range cfg.Alert_Rules {
+		a.thresholds = append(a.thresholds, newThreshold(cfg, rule, pick(rule.Channels)))
+	}
+	return a, nil
+}
+
+// Name implements processor.Output
+func (a *Alerter) Name() string { return "alert" }
+
+// Write implements processor.Output
+func (a *Alerter) Write(ctx context.Context, points []*influx.Data) error {
+	var errs []error
+	for _, p := range points {
+		if alert, ok := a.lightningAlert(p); ok {
+			errs = append(errs, a.send(ctx, a.lightning, alert))
+		}
+		for _, t := range a.thresholds {
+			a.mu.Lock()
+			alert, ok := t.evaluate(a.cfg, p)
+			a.mu.Unlock()
+			if ok {
+				errs = append(errs, a.send(ctx, t.channels, alert))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lightningAlert returns the alert for a strike within the alert distance
+// of a station out of its cooldown
+func (a *Alerter) lightningAlert(p *influx.Data) (Alert, bool) {
+	if p.ReportType != "evt_strike" || a.cfg.Lightning_Alert_Distance <= 0 {
+		return Alert{}, false
+	}
+	distance, ok := p.Float("strike_distance")
+	if !ok || distance > a.cfg.Lightning_Alert_Distance {
+		return Alert{}, false
+	}
+	station := p.Tags["station"]
+	if !a.due(LightningAlert, station, a.cfg.Lightning_Alert_Cooldown) {
+		return Alert{}, false
+	}
+
+	name := cmp.Or(a.cfg.Station(station).Name, station)
+	text := fmt.Sprintf("Lightning strike %v %s from %s", distance, a.unit, name)
+	if energy, ok := p.Fields["strike_energy"]; ok {
+		text += fmt.Sprintf(", energy %v", energy)
+	}
+	return Alert{
+		Name:    LightningAlert,
+		Station: station,
+		Title:   fmt.Sprintf("Lightning %v %s away", distance, a.unit),
+		Message: text,
+		Time:    time.Unix(p.Timestamp, p.Nanos).UTC(),
+		Fields:  p.Fields,
+	}, true
+}
+
+// due reports whether the cooldown of alert at station has passed, and
+// starts a new one if so
+func (a *Alerter) due(alert, station string, cooldown time.Duration) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := alert + "/" + station
+	now := a.now()
+	if last, ok := a.sent[key]; ok && now.Sub(last) < cooldown {
+		return false
+	}
+	a.sent[key] = now
+	return true
+}
+
+// send delivers alert to every channel, returning the errors of those
+// that failed
+func (a *Alerter) send(ctx context.Context, channels []*Channel, alert Alert) error {
+	var errs []error
+	for _, c := range channels {
+		if err := c.Send(ctx, alert); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,211 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+// request is a request received by the test server
+type request struct {
+	path   string
+	header http.Header
+	body   string
+}
+
+func newServer(t *testing.T) (*httptest.Server, func() []request) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, request{path: r.URL.Path, header: r.Header, body: string(b)})
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]request(nil), requests...)
+	}
+}
+
+func strike(station string, distance float64) *influx.Data {
+	m := influx.New()
+	m.Name = "lightning"
+	m.ReportType = "evt_strike"
+	m.Timestamp = 1700000000
+	m.Tags["station"] = station
+	m.Fields["strike_distance"] = distance
+	m.Fields["strike_energy"] = int64(3848)
+	return m
+}
+
+func TestLightningAlert(t *testing.T) {
+	srv, requests := newServer(t)
+	cfg := &config.Config{
+		Lightning:                true,
+		Lightning_Alert_Distance: 10,
+		Lightning_Alert_Cooldown: 30 * time.Minute,
+		Stations:                 map[string]config.Station{"ST-1": {Name: "Garden"}},
+		Alert_Channels: []config.AlertChannel{
+			{Name: "hook", URL: srv.URL + "/hook"},
+			{Name: "phone", Type: config.AlertNtfy, URL: srv.URL + "/storms", Token: "tk"},
+		},
+	}
+	a, err := New(cfg, srv.Client())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	if err := a.Write(ctx, []*influx.Data{strike("ST-1", 25), strike("ST-1", 8), strike("ST-1", 5)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	got := requests()
+	if len(got) != 2 {
+		t.Fatalf("Expected one alert on each channel, got %d requests", len(got))
+	}
+
+	var alert Alert
+	if err := json.Unmarshal([]byte(got[0].body), &alert); err != nil {
+		t.Fatalf("Invalid webhook body %q: %v", got[0].body, err)
+	}
+	if alert.Name != LightningAlert || alert.Station != "ST-1" || alert.Message != "Lightning strike 8 km from Garden, energy 3848" {
+		t.Errorf("Unexpected alert %+v", alert)
+	}
+	if got[1].path != "/storms" || got[1].header.Get("Title") != "Lightning 8 km away" || got[1].header.Get("Authorization") != "Bearer tk" {
+		t.Errorf("Unexpected ntfy request %+v", got[1])
+	}
+
+	// Other stations have their own cooldown, which ends after the period
+	if err := a.Write(ctx, []*influx.Data{strike("ST-2", 3)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	now = now.Add(31 * time.Minute)
+	if err := a.Write(ctx, []*influx.Data{strike("ST-1", 4)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if n := len(requests()); n != 6 {
+		t.Errorf("Expected 6 requests, got %d", n)
+	}
+}
+
+func TestChannelFormats(t *testing.T) {
+	srv, requests := newServer(t)
+	alert := Alert{Name: LightningAlert, Station: "ST-1", Title: "Lightning 8 km away", Message: "Lightning strike", Time: time.Unix(1700000000, 0)}
+
+	for _, ch := range []config.AlertChannel{
+		{Name: "pushover", Type: config.AlertPushover, URL: srv.URL, Token: "app", User: "me"},
+		{Name: "slack", Type: config.AlertSlack, URL: srv.URL},
+		{Name: "custom", URL: srv.URL, Template: `{"text": "{{.Title}} at {{.Station}}"}`, Headers: map[string]string{"X-Key": "k"}},
+	} {
+		c, err := NewChannel(ch, srv.Client())
+		if err != nil {
+			t.Fatalf("NewChannel(%s) error = %v", ch.Name, err)
+		}
+		if err := c.Send(context.Background(), alert); err != nil {
+			t.Fatalf("Send(%s) error = %v", ch.Name, err)
+		}
+	}
+
+	got := requests()
+	form, _ := url.ParseQuery(got[0].body)
+	if form.Get("token") != "app" || form.Get("user") != "me" || form.Get("title") != "Lightning 8 km away" || form.Get("timestamp") != "1700000000" {
+		t.Errorf("Unexpected Pushover form %v", form)
+	}
+	if got[1].body != `{"text":"*Lightning 8 km away*\nLightning strike"}` {
+		t.Errorf("Unexpected Slack body %s", got[1].body)
+	}
+	if got[2].body != `{"text": "Lightning 8 km away at ST-1"}` || got[2].header.Get("X-Key") != "k" {
+		t.Errorf("Unexpected templated request %q %v", got[2].body, got[2].header)
+	}
+}
+
+func TestChannelError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	c, err := NewChannel(config.AlertChannel{Name: "phone", Type: config.AlertNtfy, URL: srv.URL}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Send(context.Background(), Alert{Title: "t", Message: "m"})
+	if err == nil || !strings.Contains(err.Error(), "invalid token") {
+		t.Errorf("Expected error with response body, got %v", err)
+	}
+}
+
+func observation(station string, timestamp int64, temp float64) *influx.Data {
+	m := influx.New()
+	m.Name = "weather"
+	m.ReportType = "obs_st"
+	m.Timestamp = timestamp
+	m.Tags["station"] = station
+	m.Fields["temp"] = temp
+	return m
+}
+
+func TestThresholdAlert(t *testing.T) {
+	srv, requests := newServer(t)
+	cfg := &config.Config{
+		Alert_Channels: []config.AlertChannel{{Name: "hook", URL: srv.URL}},
+		Alert_Rules: []config.AlertRule{
+			{Name: "freeze", Field: "temp", Operator: "<", Value: 0, For: 10 * time.Minute, Hysteresis: 1},
+		},
+	}
+	a, err := New(cfg, srv.Client())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx := context.Background()
+	for _, m := range []*influx.Data{
+		observation("ST-1", 0, -1.0),
+		observation("ST-1", 300, 0.5), // above again, restarts the period
+		observation("ST-1", 360, -0.5),
+		observation("ST-1", 900, -2.0),  // below for 9 minutes
+		observation("ST-1", 960, -1.5),  // below for 10 minutes: alert
+		observation("ST-1", 1020, -3.0), // still firing
+		observation("ST-1", 1080, 0.5),  // within the hysteresis
+		observation("ST-1", 1140, -0.2),
+		observation("ST-1", 1200, 1.2), // resolved
+	} {
+		if err := a.Write(ctx, []*influx.Data{m}); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	got := requests()
+	if len(got) != 2 {
+		t.Fatalf("Expected an alert and its resolution, got %d requests", len(got))
+	}
+	var fired, resolved Alert
+	if err := json.Unmarshal([]byte(got[0].body), &fired); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(got[1].body), &resolved); err != nil {
+		t.Fatal(err)
+	}
+	if fired.Name != "freeze" || fired.Resolved || fired.Time.Unix() != 960 || fired.Message != "temp at ST-1 is -1.5 °C, below 0 °C for 10m" {
+		t.Errorf("Unexpected alert %+v", fired)
+	}
+	if !resolved.Resolved || resolved.Time.Unix() != 1200 || resolved.Title != "Resolved: temp below 0 °C" {
+		t.Errorf("Unexpected resolution %+v", resolved)
+	}
+}
@@ -0,0 +1,118 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+)
+
+// PushoverURL is the Pushover message API, used when a pushover channel
+// sets no URL
+const PushoverURL = "https://api.pushover.net/1/messages.json"
+
+// HTTPClient is the subset of http.Client used by channels
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Channel sends alerts to one configured destination
+type Channel struct {
+	cfg      config.AlertChannel
+	client   HTTPClient
+	template *template.Template
+}
+
+// NewChannel creates a Channel for cfg
+func NewChannel(cfg config.AlertChannel, client HTTPClient) (*Channel, error) {
+	c := &Channel{cfg: cfg, client: client}
+	if cfg.Type == config.AlertPushover && c.cfg.URL == "" {
+		c.cfg.URL = PushoverURL
+	}
+	if cfg.Template != "" {
+		t, err := template.New(cfg.Name).Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("parsing template of alert channel %s: %w", cfg.Name, err)
+		}
+		c.template = t
+	}
+	return c, nil
+}
+
+// Name returns the configured name of the channel
+func (c *Channel) Name() string {
+	return c.cfg.Name
+}
+
+// Send delivers a in the format of the channel's type
+func (c *Channel) Send(ctx context.Context, a Alert) error {
+	body, contentType, err := c.render(a)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request for alert channel %s: %w", c.cfg.Name, err)
+	}
+	request.Header.Set("Content-Type", contentType)
+	if c.cfg.Type == config.AlertNtfy {
+		request.Header.Set("Title", a.Title)
+		if c.cfg.Token != "" {
+			request.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+		}
+	}
+	for name, value := range c.cfg.Headers {
+		request.Header.Set(name, value)
+	}
+
+	resp, err := c.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("sending to alert channel %s: %w", c.cfg.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("alert channel %s returned %s: %s", c.cfg.Name, resp.Status, strings.TrimSpace(string(message)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// render returns the request body of a and its content type
+func (c *Channel) render(a Alert) ([]byte, string, error) {
+	switch c.cfg.Type {
+	case config.AlertNtfy:
+		return []byte(a.Message), "text/plain; charset=utf-8", nil
+	case config.AlertPushover:
+		form := url.Values{
+			"token":     {c.cfg.Token},
+			"user":      {c.cfg.User},
+			"title":     {a.Title},
+			"message":   {a.Message},
+			"timestamp": {strconv.FormatInt(a.Time.Unix(), 10)},
+		}
+		return []byte(form.Encode()), "application/x-www-form-urlencoded", nil
+	case config.AlertSlack:
+		b, err := json.Marshal(map[string]string{"text": "*" + a.Title + "*\n" + a.Message})
+		return b, "application/json", err
+	}
+
+	if c.template == nil {
+		b, err := json.Marshal(a)
+		return b, "application/json", err
+	}
+	var b bytes.Buffer
+	if err := c.template.Execute(&b, a); err != nil {
+		return nil, "", fmt.Errorf("rendering template of alert channel %s: %w", c.cfg.Name, err)
+	}
+	return b.Bytes(), "application/json", nil
+}
@@ -0,0 +1,129 @@
+package alert
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/tempest"
+)
+
+// ruleState is the state of one rule at one station
+type ruleState struct {
+	since  int64 // timestamp the threshold was first crossed, 0 when it is not
+	firing bool
+}
+
+// threshold evaluates one AlertRule for every station
+type threshold struct {
+	rule     config.AlertRule
+	name     string
+	unit     string // unit symbol of the field, with a leading space
+	channels []*Channel
+	states   map[string]*ruleState
+}
+
+func newThreshold(cfg *config.Config, rule config.AlertRule, channels []*Channel) *threshold {
+	t := &threshold{
+		rule:     rule,
+		name:     cmp.Or(rule.Name, rule.Field),
+		channels: channels,
+		states:   make(map[string]*ruleState),
+	}
+	if unit, ok := tempest.FieldUnit(cfg, rule.Field); ok {
+		t.unit = " " + unit
+	}
+	return t
+}
+
+// evaluate returns the alert raised or resolved by p, if any
+func (t *threshold) evaluate(cfg *config.Config, p *influx.Data) (Alert, bool) {
+	v, ok := p.Float(t.rule.Field)
+	if !ok {
+		return Alert{}, false
+	}
+	station := p.Tags["station"]
+	s, ok := t.states[station]
+	if !ok {
+		s = &ruleState{}
+		t.states[station] = s
+	}
+
+	below := t.rule.Operator == "<"
+	if s.firing {
+		var cleared bool
+		if below {
+			cleared = v >= t.rule.Value+t.rule.Hysteresis
+		} else {
+			cleared = v <= t.rule.Value-t.rule.Hysteresis
+		}
+		if !cleared {
+			return Alert{}, false
+		}
+		s.firing, s.since = false, 0
+		return t.alert(cfg, p, station, v, true), true
+	}
+
+	crossed := v > t.rule.Value
+	if below {
+		crossed = v < t.rule.Value
+	}
+	if !crossed {
+		s.since = 0
+		return Alert{}, false
+	}
+	if s.since == 0 {
+		s.since = p.Timestamp
+	}
+	if time.Duration(p.Timestamp-s.since)*time.Second < t.rule.For {
+		return Alert{}, false
+	}
+	s.firing = true
+	return t.alert(cfg, p, station, v, false), true
+}
+
+// alert builds the alert of the rule for value v at station
+func (t *threshold) alert(cfg *config.Config, p *influx.Data, station string, v float64, resolved bool) Alert {
+	direction := "above"
+	if t.rule.Operator == "<" {
+		direction = "below"
+	}
+	value := strconv.FormatFloat(v, 'f', -1, 64) + t.unit
+	limit := strconv.FormatFloat(t.rule.Value, 'f', -1, 64) + t.unit
+	name := cmp.Or(cfg.Station(station).Name, station)
+
+	a := Alert{
+		Name:     t.name,
+		Station:  station,
+		Time:     time.Unix(p.Timestamp, p.Nanos).UTC(),
+		Fields:   p.Fields,
+		Resolved: resolved,
+	}
+	if resolved {
+		a.Title = fmt.Sprintf("Resolved: %s %s %s", t.rule.Field, direction, limit)
+		a.Message = fmt.Sprintf("%s at %s is back at %s", t.rule.Field, name, value)
+		return a
+	}
+	a.Title = fmt.Sprintf("%s %s %s", t.rule.Field, direction, limit)
+	a.Message = fmt.Sprintf("%s at %s is %s, %s %s", t.rule.Field, name, value, direction, limit)
+	if t.rule.For > 0 {
+		a.Message += " for " + formatDuration(t.rule.For)
+	}
+	return a
+}
+
+// formatDuration formats d without trailing zero units, such as 10m or 1h
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,233 @@
+// Package api serves the latest observations of each station over a small
+// read-only REST API
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/jacaudi/tempest-influxdb/internal/conditions"
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/metar"
+	"github.com/jacaudi/tempest-influxdb/internal/registry"
+)
+
+// Prefix is the path all API routes are served under
+const Prefix = "/api/v1/"
+
+// Conditions is the JSON form of a station's current conditions
+type Conditions struct {
+	Station   string `json:"station"`
+	Timestamp int64  `json:"timestamp"`
+	Summary   string `json:"summary"`
+}
+
+// Station is the JSON form of a station in the list of stations
+type Station struct {
+	Station   string `json:"station"`
+	Name      string `json:"name,omitempty"`
+	Location  string `json:"location,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Observation is the JSON form of a station's latest observation. Fields
+// are JSON numbers, booleans and strings.
+type Observation struct {
+	Station   string            `json:"station"`
+	Timestamp int64             `json:"timestamp"`
+	Tags      map[string]string `json:"tags"`
+	Fields    map[string]any    `json:"fields"`
+}
+
+// Store keeps the latest obs_st point of every station. It is an observer
+// of the processor and serves them over HTTP.
+type Store struct {
+	cfg *config.Config
+
+	mu     sync.RWMutex
+	latest map[string]*influx.Data
+	mux    *http.ServeMux
+}
+
+// NewStore creates an empty Store for the stations of cfg
+func NewStore(cfg *config.Config) *Store {
+	s := &Store{
+		cfg:    cfg,
+		latest: make(map[string]*influx.Data),
+		mux:    http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET "+Prefix+"stations", s.handleStations)
+	s.mux.HandleFunc("GET "+Prefix+"stations/{station}/latest", s.handleLatest)
+	s.mux.HandleFunc("GET "+Prefix+"conditions", s.handleConditionsList)
+	s.mux.HandleFunc("GET "+Prefix+"conditions/{station}", s.handleConditions)
+	s.mux.HandleFunc("GET "+Prefix+"metar", s.handleMetarList)
+	s.mux.HandleFunc("GET "+Prefix+"metar/{station}", s.handleMetar)
+	return s
+}
+
+// Observe records the obs_st points among points
+func (s *Store) Observe(points []*influx.Data) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range points {
+		if p.ReportType != "obs_st" {
+			continue
+		}
+		station := p.Tags["station"]
+		if prev, ok := s.latest[station]; ok && prev.Timestamp > p.Timestamp {
+			continue
+		}
+		c := *p
+		c.Tags = maps.Clone(p.Tags)
+		c.Fields = maps.Clone(p.Fields)
+		s.latest[station] = &c
+	}
+}
+
+// Latest returns the latest observation of station
+func (s *Store) Latest(station string) (*influx.Data, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.latest[station]
+	return p, ok
+}
+
+// Stations returns the serial numbers of all stations seen, sorted
+func (s *Store) Stations() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Sorted(maps.Keys(s.latest))
+}
+
+// Handler returns the handler for all API routes
+func (s *Store) Handler() http.Handler {
+	return s.mux
+}
+
+// ServeRegistry adds the routes listing the devices of reg
+func (s *Store) ServeRegistry(reg *registry.Registry) {
+	s.mux.HandleFunc("GET "+Prefix+"devices", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, reg.Devices())
+	})
+	s.mux.HandleFunc("GET "+Prefix+"devices/{serial}", func(w http.ResponseWriter, r *http.Request) {
+		d, ok := reg.Device(r.PathValue("serial"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown device"})
+			return
+		}
+		writeJSON(w, http.StatusOK, d)
+	})
+}
+
+// handleStations serves the stations with an observation and its time
+func (s *Store) handleStations(w http.ResponseWriter, r *http.Request) {
+	list := []Station{}
+	for _, station := range s.Stations() {
+		if p, ok := s.Latest(station); ok {
+			cfg := s.cfg.Station(station)
+			list = append(list, Station{Station: station, Name: cfg.Name, Location: cfg.Location, Timestamp: p.Timestamp})
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleLatest serves every field of a station's latest observation
+func (s *Store) handleLatest(w http.ResponseWriter, r *http.Request) {
+	station := r.PathValue("station")
+	p, ok := s.Latest(station)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown station"})
+		return
+	}
+	o := Observation{
+		Station:   station,
+		Timestamp: p.Timestamp,
+		Tags:      p.Tags,
+		Fields:    p.Fields,
+	}
+	writeJSON(w, http.StatusOK, o)
+}
+
+// conditions returns the current conditions of station
+func (s *Store) conditions(station string) (Conditions, bool) {
+	p, ok := s.Latest(station)
+	if !ok {
+		return Conditions{}, false
+	}
+	summary, ok := p.Fields[conditions.Field].(string)
+	if !ok {
+		summary = conditions.Summary(p)
+	}
+	return Conditions{Station: station, Timestamp: p.Timestamp, Summary: summary}, true
+}
+
+// handleConditionsList serves the conditions of every station
+func (s *Store) handleConditionsList(w http.ResponseWriter, r *http.Request) {
+	list := []Conditions{}
+	for _, station := range s.Stations() {
+		if c, ok := s.conditions(station); ok {
+			list = append(list, c)
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleConditions serves the conditions of a single station
+func (s *Store) handleConditions(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.conditions(r.PathValue("station"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown station"})
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// metarLine returns the pseudo-METAR of station's latest observation
+func (s *Store) metarLine(station string) (string, bool) {
+	p, ok := s.Latest(station)
+	if !ok {
+		return "", false
+	}
+	return metar.Encode(station, p, s.cfg.Station(station).Elevation), true
+}
+
+// handleMetarList serves one pseudo-METAR line per station
+func (s *Store) handleMetarList(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	for _, station := range s.Stations() {
+		if line, ok := s.metarLine(station); ok {
+			b.WriteString(line + "\n")
+		}
+	}
+	writeText(w, http.StatusOK, b.String())
+}
+
+// handleMetar serves the pseudo-METAR of a single station
+func (s *Store) handleMetar(w http.ResponseWriter, r *http.Request) {
+	line, ok := s.metarLine(r.PathValue("station"))
+	if !ok {
+		writeText(w, http.StatusNotFound, "unknown station\n")
+		return
+	}
+	writeText(w, http.StatusOK, line+"\n")
+}
+
+// writeText writes body as a plain text response
+func writeText(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, body)
+}
+
+// writeJSON writes v as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
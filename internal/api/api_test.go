@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/registry"
+)
+
+func point(station string, timestamp int64, temp float64) *influx.Data {
+	m := influx.New()
+	m.Name = "weather"
+	m.ReportType = "obs_st"
+	m.Timestamp = timestamp
+	m.Tags["station"] = station
+	m.Fields["temp"] = temp
+	return m
+}
+
+func get(t *testing.T, s *Store, path string, v any) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("Invalid JSON %q: %v", rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestConditionsEndpoints(t *testing.T) {
+	s := NewStore(&config.Config{})
+
+	var list []Conditions
+	if code := get(t, s, "/api/v1/conditions", &list); code != http.StatusOK || len(list) != 0 {
+		t.Fatalf("Expected empty list, got %d %v", code, list)
+	}
+
+	wind := point("ST-1", 300, 1.0)
+	wind.ReportType = "rapid_wind"
+	stored := point("ST-2", 100, 8.0)
+	stored.Fields["summary"] = "Light rain, 8°C"
+	s.Observe([]*influx.Data{point("ST-1", 200, 12.0), wind, stored})
+	s.Observe([]*influx.Data{point("ST-1", 150, 30.0)})
+
+	var c Conditions
+	if code := get(t, s, "/api/v1/conditions/ST-1", &c); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if c.Timestamp != 200 || c.Summary != "12°C" {
+		t.Errorf("Expected latest obs_st summary, got %+v", c)
+	}
+
+	if code := get(t, s, "/api/v1/conditions", &list); code != http.StatusOK || len(list) != 2 {
+		t.Fatalf("Expected two stations, got %d %v", code, list)
+	}
+	if list[1].Station != "ST-2" || list[1].Summary != "Light rain, 8°C" {
+		t.Errorf("Expected stored summary field to be used, got %+v", list[1])
+	}
+
+	if code := get(t, s, "/api/v1/conditions/ST-9", &c); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown station, got %d", code)
+	}
+}
+
+func TestStationEndpoints(t *testing.T) {
+	s := NewStore(&config.Config{Stations: map[string]config.Station{"ST-2": {Name: "Garden"}}})
+
+	var list []Station
+	if code := get(t, s, "/api/v1/stations", &list); code != http.StatusOK || len(list) != 0 {
+		t.Fatalf("Expected empty list, got %d %v", code, list)
+	}
+
+	latest := point("ST-2", 200, 12.5)
+	latest.Fields["illuminance"] = int64(1200)
+	latest.Fields["summary"] = "Sunny, 12°C"
+	s.Observe([]*influx.Data{point("ST-1", 100, 8.0), latest})
+
+	if code := get(t, s, "/api/v1/stations", &list); code != http.StatusOK || len(list) != 2 {
+		t.Fatalf("Expected two stations, got %d %v", code, list)
+	}
+	if list[1].Station != "ST-2" || list[1].Name != "Garden" || list[1].Timestamp != 200 {
+		t.Errorf("Unexpected station %+v", list[1])
+	}
+
+	var o Observation
+	if code := get(t, s, "/api/v1/stations/ST-2/latest", &o); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if o.Timestamp != 200 || o.Tags["station"] != "ST-2" {
+		t.Errorf("Unexpected observation %+v", o)
+	}
+	if o.Fields["temp"] != 12.5 || o.Fields["illuminance"] != 1200.0 || o.Fields["summary"] != "Sunny, 12°C" {
+		t.Errorf("Unexpected fields %v", o.Fields)
+	}
+
+	if code := get(t, s, "/api/v1/stations/ST-9/latest", &o); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown station, got %d", code)
+	}
+}
+
+func TestMetarEndpoints(t *testing.T) {
+	s := NewStore(&config.Config{Stations: map[string]config.Station{"ST-2": {Elevation: 1609}}})
+	low := point("ST-1", 1710269700, 12.0)
+	low.Fields["p"] = 1013.55
+	high := point("ST-2", 1710269700, 8.0)
+	high.Fields["p"] = 834.6
+	s.Observe([]*influx.Data{low, high})
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/metar", nil))
+	want := "METAR ST-1 121855Z AUTO 12/ Q1013\nMETAR ST-2 121855Z AUTO 08/ Q1013\n"
+	if rec.Code != http.StatusOK || rec.Body.String() != want {
+		t.Errorf("GET /api/v1/metar = %d %q, want %q", rec.Code, rec.Body.String(), want)
+	}
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/metar/ST-9", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown station, got %d", rec.Code)
+	}
+}
+
+func TestDevicesEndpoints(t *testing.T) {
+	reg, err := registry.New("", logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.ObservePacket([]byte(`{"serial_number":"ST-1","hub_sn":"HB-1","type":"obs_st"}`))
+	s := NewStore(&config.Config{})
+	s.ServeRegistry(reg)
+
+	var list []registry.Device
+	if code := get(t, s, "/api/v1/devices", &list); code != http.StatusOK || len(list) != 1 || list[0].Hub != "HB-1" {
+		t.Errorf("Expected one device, got %d %v", code, list)
+	}
+	var d registry.Device
+	if code := get(t, s, "/api/v1/devices/ST-1", &d); code != http.StatusOK || d.Serial != "ST-1" {
+		t.Errorf("Expected device ST-1, got %d %+v", code, d)
+	}
+	if code := get(t, s, "/api/v1/devices/ST-2", &d); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown device, got %d", code)
+	}
+}
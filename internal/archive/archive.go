@@ -0,0 +1,267 @@
+// Package archive uploads observations to S3-compatible object storage as
+// compressed hourly objects, a long-term archive independent of InfluxDB
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+const (
+	// DefaultRegion signs requests when Archive_S3_Region is not set;
+	// MinIO accepts any region
+	DefaultRegion = "us-east-1"
+
+	// maxPending caps the objects kept for another upload attempt after a
+	// failure, a day of hourly objects
+	maxPending = 24
+
+	// uploadTimeout bounds one upload, and the wait for uploads on Close
+	uploadTimeout = 30 * time.Second
+)
+
+// HTTPClient sends requests, see http.Client
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// object is a finished hourly object waiting for upload
+type object struct {
+	key  string
+	body []byte
+}
+
+// Archiver collects line protocol points, or raw packets when Archive_Raw
+// is set, into one gzip-compressed object per hour of arrival and uploads
+// each object once its hour has passed. Objects are named
+// <prefix>YYYY/MM/DD/HH-<start>.<lp|jsonl>.gz, where start is the Unix time
+// the archiver started, so restarts never overwrite earlier objects.
+// Failed uploads are retried with the next object; at most a day of objects
+// is kept for retrying.
+type Archiver struct {
+	cfg    *config.Config
+	logger *logger.AppLogger
+	client HTTPClient
+	creds  credentials
+	now    func() time.Time
+
+	started time.Time
+	ext     string
+
+	mu     sync.Mutex
+	hour   time.Time
+	buf    bytes.Buffer
+	gz     *gzip.Writer
+	n      int
+	closed bool
+
+	uploads chan object
+	done    chan struct{}
+}
+
+// New creates an Archiver and starts its uploader
+func New(cfg *config.Config, client HTTPClient, appLogger *logger.AppLogger) *Archiver {
+	region := cfg.Archive_S3_Region
+	if region == "" {
+		region = DefaultRegion
+	}
+	a := &Archiver{
+		cfg:    cfg,
+		logger: appLogger,
+		client: client,
+		creds: credentials{
+			accessKey: cfg.Archive_S3_Access_Key,
+			secretKey: cfg.Archive_S3_Secret_Key,
+			region:    region,
+		},
+		now:     time.Now,
+		ext:     "lp",
+		uploads: make(chan object, maxPending),
+		done:    make(chan struct{}),
+	}
+	if cfg.Archive_Raw {
+		a.ext = "jsonl"
+	}
+	a.started = a.now()
+	go a.upload()
+	return a
+}
+
+// Name implements processor.Output
+func (a *Archiver) Name() string {
+	return "archive"
+}
+
+// Write implements processor.Output, archiving points as line protocol
+// unless raw packets are archived
+func (a *Archiver) Write(ctx context.Context, points []*influx.Data) error {
+	if a.cfg.Archive_Raw {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return nil
+	}
+	a.rotate()
+	for _, m := range points {
+		a.gz.Write([]byte(m.Marshal()))
+		a.n++
+	}
+	return nil
+}
+
+// ObservePacket implements processor.PacketObserver, archiving raw packets
+// one per line when Archive_Raw is set
+func (a *Archiver) ObservePacket(b []byte) {
+	if !a.cfg.Archive_Raw {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return
+	}
+	a.rotate()
+	a.gz.Write(bytes.TrimRight(b, "\r\n"))
+	a.gz.Write([]byte("\n"))
+	a.n++
+}
+
+// rotate finishes the object of a past hour and starts one for the current
+// hour. The caller holds mu.
+func (a *Archiver) rotate() {
+	hour := a.now().UTC().Truncate(time.Hour)
+	if a.gz != nil && hour.Equal(a.hour) {
+		return
+	}
+	a.finish()
+	a.hour = hour
+	a.buf.Reset()
+	a.gz = gzip.NewWriter(&a.buf)
+}
+
+// finish queues the current object for upload if it holds any data. The
+// caller holds mu.
+func (a *Archiver) finish() {
+	if a.gz == nil {
+		return
+	}
+	a.gz.Close()
+	if a.n > 0 {
+		o := object{key: a.key(a.hour), body: bytes.Clone(a.buf.Bytes())}
+		select {
+		case a.uploads <- o:
+		default:
+			a.logger.Error("Archive uploads are falling behind, dropping object", slog.String("key", o.key))
+		}
+	}
+	a.gz = nil
+	a.n = 0
+}
+
+// key returns the object key of hour
+func (a *Archiver) key(hour time.Time) string {
+	prefix := a.cfg.Archive_S3_Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix + hour.Format("2006/01/02/15") + "-" + strconv.FormatInt(a.started.Unix(), 10) + "." + a.ext + ".gz"
+}
+
+// upload uploads queued objects until the queue is closed, keeping failed
+// objects for another attempt with the next one
+func (a *Archiver) upload() {
+	defer close(a.done)
+	var pending []object
+	for o := range a.uploads {
+		pending = append(pending, o)
+		if len(pending) > maxPending {
+			a.logger.Error("Dropping archive object after repeated upload failures", slog.String("key", pending[0].key))
+			pending = pending[1:]
+		}
+		pending = a.put(pending)
+	}
+	if len(pending) > 0 {
+		a.logger.Error("Archive objects were not uploaded", slog.Int("objects", len(pending)))
+	}
+}
+
+// put uploads objects in order and returns those that failed
+func (a *Archiver) put(objects []object) []object {
+	var failed []object
+	for _, o := range objects {
+		ctx, cancel := context.WithTimeout(context.Background(), uploadTimeout)
+		err := a.Put(ctx, o.key, o.body)
+		cancel()
+		if err != nil {
+			a.logger.Warn("Failed to upload archive object", slog.String("key", o.key), slog.String("error", err.Error()))
+			failed = append(failed, o)
+			continue
+		}
+		a.logger.Info("Uploaded archive object", slog.String("key", o.key), slog.Int("bytes", len(o.body)))
+	}
+	return failed
+}
+
+// Put uploads body as the object key of Archive_S3_Bucket, addressed in
+// path style so that MinIO and other S3-compatible stores work without
+// DNS for each bucket
+func (a *Archiver) Put(ctx context.Context, key string, body []byte) error {
+	u, err := url.Parse(strings.TrimSuffix(a.cfg.Archive_S3_URL, "/"))
+	if err != nil {
+		return err
+	}
+	u = u.JoinPath(a.cfg.Archive_S3_Bucket, key)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	a.creds.sign(req, hashHex(body), a.now())
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// Close uploads the object of the current hour and waits for the uploads
+// to finish
+func (a *Archiver) Close() error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		a.finish()
+		close(a.uploads)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-a.done:
+		return nil
+	case <-time.After(uploadTimeout):
+		return fmt.Errorf("archive uploads did not finish within %s", uploadTimeout)
+	}
+}
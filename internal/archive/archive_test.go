@@ -0,0 +1,140 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+// fakeS3 stores the objects put to it by path
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	fail    bool
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Method != http.MethodPut || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if s.fail {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	s.objects[r.URL.Path] = body
+}
+
+func (s *fakeS3) object(t *testing.T, path string) string {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, ok := s.objects[path]
+	if !ok {
+		t.Fatalf("object %s not uploaded, have %v", path, s.objects)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, _ := io.ReadAll(zr)
+	return string(text)
+}
+
+func newTestArchiver(t *testing.T, s3 *fakeS3, raw bool, now *time.Time) *Archiver {
+	t.Helper()
+	srv := httptest.NewServer(s3)
+	t.Cleanup(srv.Close)
+	cfg := &config.Config{
+		Archive_S3_URL:        srv.URL,
+		Archive_S3_Bucket:     "tempest",
+		Archive_S3_Prefix:     "site1",
+		Archive_S3_Access_Key: "key",
+		Archive_S3_Secret_Key: "secret",
+		Archive_Raw:           raw,
+	}
+	a := New(cfg, srv.Client(), logger.New(&config.Config{}))
+	a.now = func() time.Time { return *now }
+	a.started = time.Unix(1700000000, 0)
+	return a
+}
+
+func point(temp float64) *influx.Data {
+	m := influx.New()
+	m.Name = "weather"
+	m.Timestamp = 1709251200
+	m.Tags["station"] = "ST-1"
+	m.Fields["temp"] = temp
+	return m
+}
+
+func TestArchiveHourly(t *testing.T) {
+	s3 := &fakeS3{objects: make(map[string][]byte)}
+	now := time.Date(2024, 3, 1, 13, 10, 0, 0, time.UTC)
+	a := newTestArchiver(t, s3, false, &now)
+
+	a.Write(context.Background(), []*influx.Data{point(10.5)})
+	a.Write(context.Background(), []*influx.Data{point(11.5)})
+	now = now.Add(time.Hour)
+	a.Write(context.Background(), []*influx.Data{point(12.5)})
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	first := s3.object(t, "/tempest/site1/2024/03/01/13-1700000000.lp.gz")
+	if strings.Count(first, "\n") != 2 || !strings.Contains(first, "temp=10.5") || !strings.Contains(first, "temp=11.5") {
+		t.Errorf("Unexpected first hour:\n%s", first)
+	}
+	second := s3.object(t, "/tempest/site1/2024/03/01/14-1700000000.lp.gz")
+	if !strings.Contains(second, "temp=12.5") || strings.Contains(second, "temp=10.5") {
+		t.Errorf("Unexpected second hour:\n%s", second)
+	}
+}
+
+func TestArchiveRaw(t *testing.T) {
+	s3 := &fakeS3{objects: make(map[string][]byte)}
+	now := time.Date(2024, 3, 1, 13, 10, 0, 0, time.UTC)
+	a := newTestArchiver(t, s3, true, &now)
+
+	a.ObservePacket([]byte(`{"type":"obs_st"}` + "\n"))
+	a.Write(context.Background(), []*influx.Data{point(10.5)})
+	a.Close()
+
+	if got := s3.object(t, "/tempest/site1/2024/03/01/13-1700000000.jsonl.gz"); got != `{"type":"obs_st"}`+"\n" {
+		t.Errorf("Unexpected raw object %q", got)
+	}
+}
+
+func TestArchiveRetry(t *testing.T) {
+	s3 := &fakeS3{objects: make(map[string][]byte), fail: true}
+	now := time.Date(2024, 3, 1, 13, 10, 0, 0, time.UTC)
+	a := newTestArchiver(t, s3, false, &now)
+
+	a.Write(context.Background(), []*influx.Data{point(10.5)})
+	now = now.Add(time.Hour)
+	a.Write(context.Background(), []*influx.Data{point(11.5)})
+
+	// Wait for the failed upload of the first hour
+	time.Sleep(50 * time.Millisecond)
+	s3.mu.Lock()
+	s3.fail = false
+	s3.mu.Unlock()
+	a.Close()
+
+	s3.object(t, "/tempest/site1/2024/03/01/13-1700000000.lp.gz")
+	s3.object(t, "/tempest/site1/2024/03/01/14-1700000000.lp.gz")
+}
@@ -0,0 +1,92 @@
+// Package auth protects the collector's HTTP and gRPC endpoints with a
+// bearer token or basic authentication
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// realm is announced to HTTP clients that need to authenticate
+const realm = `Basic realm="tempest-influxdb"`
+
+// Enabled reports whether cfg requires clients to authenticate
+func Enabled(cfg *config.Config) bool {
+	return cfg.API_Token != "" || cfg.API_Username != ""
+}
+
+// Check reports whether the value of an Authorization header carries the
+// configured token or credentials
+func Check(cfg *config.Config, authorization string) bool {
+	scheme, credentials, _ := strings.Cut(authorization, " ")
+	switch {
+	case strings.EqualFold(scheme, "Bearer") && cfg.API_Token != "":
+		return equal(credentials, cfg.API_Token)
+	case strings.EqualFold(scheme, "Basic") && cfg.API_Username != "":
+		decoded, err := base64.StdEncoding.DecodeString(credentials)
+		if err != nil {
+			return false
+		}
+		username, password, _ := strings.Cut(string(decoded), ":")
+		// Evaluate both to keep the comparison time independent of which
+		// one is wrong
+		userOK := equal(username, cfg.API_Username)
+		passOK := equal(password, cfg.API_Password)
+		return userOK && passOK
+	}
+	return false
+}
+
+// equal compares secrets in constant time
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// Middleware rejects HTTP requests without valid credentials. It returns
+// next unchanged when authentication is not configured.
+func Middleware(cfg *config.Config, next http.Handler) http.Handler {
+	if !Enabled(cfg) {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !Check(cfg, r.Header.Get("Authorization")) {
+			if cfg.API_Username != "" {
+				w.Header().Set("WWW-Authenticate", realm)
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// StreamInterceptor rejects gRPC streams without valid credentials in the
+// authorization metadata
+func StreamInterceptor(cfg *config.Config) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkContext(cfg, ss.Context()); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// checkContext verifies the authorization metadata of an incoming call
+func checkContext(cfg *config.Config, ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if Check(cfg, value) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid credentials")
+}
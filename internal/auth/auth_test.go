@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestMiddleware(t *testing.T) {
+	cfg := &config.Config{API_Token: "secret", API_Username: "admin", API_Password: "pw"}
+	handler := Middleware(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name string
+		set  func(r *http.Request)
+		want int
+	}{
+		{"no credentials", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"bearer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }, http.StatusOK},
+		{"wrong token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer guess") }, http.StatusUnauthorized},
+		{"basic auth", func(r *http.Request) { r.SetBasicAuth("admin", "pw") }, http.StatusOK},
+		{"wrong password", func(r *http.Request) { r.SetBasicAuth("admin", "guess") }, http.StatusUnauthorized},
+		{"token as password", func(r *http.Request) { r.SetBasicAuth("admin", "secret") }, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/conditions", nil)
+			tt.set(req)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, rec.Code)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestMiddlewareDisabled(t *testing.T) {
+	next := http.NotFoundHandler()
+	rec := httptest.NewRecorder()
+	Middleware(&config.Config{}, next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected requests to pass without configured auth, got %d", rec.Code)
+	}
+}
+
+func TestCheckContext(t *testing.T) {
+	cfg := &config.Config{API_Token: "secret"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer secret"))
+	if err := checkContext(cfg, ctx); err != nil {
+		t.Errorf("Expected valid token to pass, got %v", err)
+	}
+	if err := checkContext(cfg, context.Background()); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without metadata, got %v", err)
+	}
+}
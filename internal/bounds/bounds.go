@@ -0,0 +1,81 @@
+// Package bounds protects against implausible readings by checking fields
+// against configured ranges
+package bounds
+
+import (
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/metrics"
+)
+
+// QualityTag is the tag set on points with suspect values
+const QualityTag = "quality"
+
+// Enricher applies the configured field bounds. Clamped values are replaced
+// by the nearest bound, dropped values are removed from the point and
+// tagged points keep their value but are marked quality=suspect. Points
+// left without fields are removed.
+type Enricher struct {
+	bounds map[string]config.Bound
+	logger *logger.AppLogger
+}
+
+// NewEnricher creates an Enricher for the bounds of cfg
+func NewEnricher(cfg *config.Config, appLogger *logger.AppLogger) *Enricher {
+	return &Enricher{bounds: cfg.Field_Bounds, logger: appLogger}
+}
+
+// Enrich checks every point against the bounds
+func (e *Enricher) Enrich(points []*influx.Data) []*influx.Data {
+	kept := points[:0]
+	for _, m := range points {
+		e.check(m)
+		if len(m.Fields) > 0 {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// check applies the bounds to the fields of m
+func (e *Enricher) check(m *influx.Data) {
+	for field, bound := range e.bounds {
+		value, ok := m.Float(field)
+		if !ok {
+			continue
+		}
+
+		limit := value
+		if bound.Min != nil && value < *bound.Min {
+			limit = *bound.Min
+		}
+		if bound.Max != nil && value > *bound.Max {
+			limit = *bound.Max
+		}
+		if limit == value {
+			continue
+		}
+
+		policy := bound.Policy
+		if policy == "" {
+			policy = config.BoundClamp
+		}
+		metrics.OutOfRange.WithLabelValues(field, policy).Inc()
+		e.logger.Debug("Field value out of range",
+			"packet_id", m.ID,
+			"station", m.Tags["station"],
+			"field", field,
+			"value", value,
+			"policy", policy)
+
+		switch policy {
+		case config.BoundClamp:
+			m.SetNumber(field, limit)
+		case config.BoundDrop:
+			delete(m.Fields, field)
+		case config.BoundTag:
+			m.Tags[QualityTag] = "suspect"
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package bounds
+
+import (
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func ptr(v float64) *float64 { return &v }
+
+func TestEnricher(t *testing.T) {
+	cfg := &config.Config{Field_Bounds: map[string]config.Bound{
+		"temp":      {Min: ptr(-60), Max: ptr(60)},
+		"p":         {Min: ptr(800), Max: ptr(1100), Policy: config.BoundDrop},
+		"wind_gust": {Max: ptr(80), Policy: config.BoundTag},
+		"uv":        {Min: ptr(0), Policy: config.BoundDrop},
+	}}
+	e := NewEnricher(cfg, logger.New(&config.Config{}))
+
+	m := influx.New()
+	m.Fields["temp"] = 85.0
+	m.Fields["p"] = 12.0
+	m.Fields["wind_gust"] = 120.0
+	m.Fields["uv"] = 3.0
+	onlyBad := influx.New()
+	onlyBad.Fields["uv"] = -1.0
+
+	before := testutil.ToFloat64(metrics.OutOfRange.WithLabelValues("p", config.BoundDrop))
+	points := e.Enrich([]*influx.Data{m, onlyBad})
+
+	if len(points) != 1 || points[0] != m {
+		t.Fatalf("Expected point without fields to be removed, got %d points", len(points))
+	}
+	if m.Fields["temp"] != 60.0 {
+		t.Errorf("Expected temp clamped to 60, got %s", m.Fields["temp"])
+	}
+	if _, ok := m.Fields["p"]; ok {
+		t.Error("Expected out-of-range pressure to be dropped")
+	}
+	if m.Fields["wind_gust"] != 120.0 || m.Tags[QualityTag] != "suspect" {
+		t.Errorf("Expected gust kept and tagged, got %s, tags %v", m.Fields["wind_gust"], m.Tags)
+	}
+	if m.Fields["uv"] != 3.0 {
+		t.Errorf("Expected in-range uv unchanged, got %s", m.Fields["uv"])
+	}
+	if got := testutil.ToFloat64(metrics.OutOfRange.WithLabelValues("p", config.BoundDrop)); got != before+1 {
+		t.Errorf("Expected out-of-range metric to increase by 1, got %v", got-before)
+	}
+}
@@ -0,0 +1,108 @@
+// Package calibration corrects known sensor biases at ingest time
+package calibration
+
+import (
+	"strings"
+
+	"github.com/de-wax/go-pkg/dewpoint"
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+// groups lets one calibration entry cover several related fields
+var groups = map[string][]string{
+	"wind": {"wind_lull", "wind_avg", "wind_gust", "rapid_wind_speed"},
+}
+
+// precision is the number of decimals written for calibrated fields,
+// matching the parser
+const precision = 2
+
+// Enabled reports whether any station in cfg has calibration settings
+func Enabled(cfg *config.Config) bool {
+	for _, station := range cfg.Stations {
+		if len(station.Calibration) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Enricher applies the calibration of each point's station. It must run
+// before any enricher that derives values from the calibrated fields.
+type Enricher struct {
+	cfg *config.Config
+}
+
+// NewEnricher creates an Enricher for the stations of cfg
+func NewEnricher(cfg *config.Config) *Enricher {
+	return &Enricher{cfg: cfg}
+}
+
+// Enrich calibrates the fields of every point in place
+func (e *Enricher) Enrich(points []*influx.Data) []*influx.Data {
+	for _, m := range points {
+		calibration := e.cfg.Station(m.Tags["station"]).Calibration
+		if len(calibration) == 0 {
+			continue
+		}
+		if Apply(m, calibration) {
+			updateDewPoint(m)
+		}
+	}
+	return points
+}
+
+// Apply calibrates the fields of m and reports whether temperature or
+// humidity changed
+func Apply(m *influx.Data, calibration map[string]config.Calibration) bool {
+	var thermal bool
+	for name, cal := range calibration {
+		fields := groups[strings.ToLower(name)]
+		if fields == nil {
+			fields = []string{strings.ToLower(name)}
+		}
+		for _, field := range fields {
+			value, ok := m.Float(field)
+			if !ok {
+				continue
+			}
+			value = clamp(field, cal.Apply(value))
+			m.SetNumber(field, influx.Round(value, precision))
+			if field == "temp" || field == "relative_humidity" {
+				thermal = true
+			}
+		}
+	}
+	return thermal
+}
+
+// clamp keeps calibrated values physically possible
+func clamp(field string, value float64) float64 {
+	switch field {
+	case "relative_humidity":
+		return min(max(value, 0), 100)
+	case "wind_lull", "wind_avg", "wind_gust", "rapid_wind_speed", "p":
+		return max(value, 0)
+	}
+	return value
+}
+
+// updateDewPoint recomputes the dew point from calibrated temperature and
+// humidity
+func updateDewPoint(m *influx.Data) {
+	if _, ok := m.Fields["dew_point"]; !ok {
+		return
+	}
+	temp, ok := m.Float("temp")
+	if !ok {
+		return
+	}
+	humidity, ok := m.Float("relative_humidity")
+	if !ok {
+		return
+	}
+	if dp, err := dewpoint.Calculate(temp, humidity); err == nil {
+		m.Fields["dew_point"] = influx.Round(dp, precision)
+	}
+}
@@ -0,0 +1,70 @@
+package calibration
+
+import (
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func observation(station string) *influx.Data {
+	m := influx.New()
+	m.Name = "weather"
+	m.Tags["station"] = station
+	m.Fields["temp"] = 20.0
+	m.Fields["relative_humidity"] = 98.0
+	m.Fields["dew_point"] = 19.67
+	m.Fields["p"] = 1013.25
+	m.Fields["wind_avg"] = 2.0
+	m.Fields["wind_gust"] = 4.0
+	return m
+}
+
+func TestEnricher(t *testing.T) {
+	cfg := &config.Config{Stations: map[string]config.Station{
+		"ST-1": {Calibration: map[string]config.Calibration{
+			"temp":              {Offset: -0.5},
+			"relative_humidity": {Offset: 5},
+			"p":                 {Offset: 1.2},
+			"wind":              {Multiplier: 1.1},
+		}},
+	}}
+	if !Enabled(cfg) || Enabled(&config.Config{}) {
+		t.Fatal("Expected Enabled only with calibration settings")
+	}
+
+	calibrated, other := observation("ST-1"), observation("ST-2")
+	NewEnricher(cfg).Enrich([]*influx.Data{calibrated, other})
+
+	want := map[string]float64{
+		"temp":              19.5,
+		"relative_humidity": 100.0,
+		"p":                 1014.45,
+		"wind_avg":          2.2,
+		"wind_gust":         4.4,
+		"dew_point":         19.5,
+	}
+	for field, value := range want {
+		if calibrated.Fields[field] != value {
+			t.Errorf("%s = %v, want %v", field, calibrated.Fields[field], value)
+		}
+	}
+	if other.Fields["temp"] != 20.0 || other.Fields["dew_point"] != 19.67 {
+		t.Errorf("Expected uncalibrated station to be unchanged, got %v", other.Fields)
+	}
+}
+
+func TestApplyMissingFields(t *testing.T) {
+	m := influx.New()
+	m.Fields["rapid_wind_speed"] = 5.0
+
+	if Apply(m, map[string]config.Calibration{"wind": {Multiplier: 2}, "temp": {Offset: 1}}) {
+		t.Error("Expected no thermal change without temperature fields")
+	}
+	if m.Fields["rapid_wind_speed"] != 10.0 {
+		t.Errorf("rapid_wind_speed = %s, want 10.00", m.Fields["rapid_wind_speed"])
+	}
+	if _, ok := m.Fields["temp"]; ok {
+		t.Error("Expected calibration not to add missing fields")
+	}
+}
@@ -0,0 +1,160 @@
+package capture
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when the capture budget for the current window is spent
+var ErrRateLimited = errors.New("capture rate limit reached")
+
+// Record is the on-disk representation of a rejected packet
+type Record struct {
+	PacketID string    `json:"packet_id"`
+	Received time.Time `json:"received"`
+	Source   string    `json:"source"`
+	Error    string    `json:"error"`
+	Size     int       `json:"size"`
+	Hex      string    `json:"hex"`
+	Raw      string    `json:"raw"`
+}
+
+// Capture writes rejected packets to a directory, one file each, or
+// appends them to a single dead-letter file, at most limit per window
+type Capture struct {
+	dir     string
+	file    string // dead-letter file, when set instead of a directory
+	maxSize int64  // size at which the dead-letter file is rotated
+	limit   int
+	window  time.Duration
+	now     func() time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	count       int
+}
+
+// New creates a Capture writing to dir, keeping at most perMinute records a minute
+func New(dir string, perMinute int) (*Capture, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating capture directory: %w", err)
+	}
+	return &Capture{
+		dir:    dir,
+		limit:  perMinute,
+		window: time.Minute,
+		now:    time.Now,
+	}, nil
+}
+
+// NewFile creates a Capture appending records as JSON lines to path,
+// keeping at most perMinute records a minute. When the file would exceed
+// maxSize bytes it is renamed to path.1, replacing the previous one.
+func NewFile(path string, perMinute int, maxSize int64) (*Capture, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("creating capture directory: %w", err)
+	}
+	return &Capture{
+		dir:     filepath.Dir(path),
+		file:    path,
+		maxSize: maxSize,
+		limit:   perMinute,
+		window:  time.Minute,
+		now:     time.Now,
+	}, nil
+}
+
+// Dir returns the capture directory
+func (c *Capture) Dir() string {
+	return c.dir
+}
+
+// allow reports whether another record fits in the current window
+func (c *Capture) allow(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.windowStart) >= c.window {
+		c.windowStart = now
+		c.count = 0
+	}
+	if c.limit > 0 && c.count >= c.limit {
+		return false
+	}
+	c.count++
+	return true
+}
+
+// Save writes data received from addr and the reason it was rejected,
+// returning the path of the new capture file or of the dead-letter file
+func (c *Capture) Save(id string, addr net.Addr, data []byte, reason error) (string, error) {
+	now := c.now()
+	if !c.allow(now) {
+		return "", ErrRateLimited
+	}
+
+	record := Record{
+		PacketID: id,
+		Received: now.UTC(),
+		Size:     len(data),
+		Hex:      hex.EncodeToString(data),
+		Raw:      string(data),
+	}
+	if addr != nil {
+		record.Source = addr.String()
+	}
+	if reason != nil {
+		record.Error = reason.Error()
+	}
+
+	if c.file != "" {
+		return c.file, c.append(record)
+	}
+
+	b, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%s-%s.json", now.UTC().Format("20060102T150405.000000000Z"), id)
+	path := filepath.Join(c.dir, name)
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return "", fmt.Errorf("writing capture file: %w", err)
+	}
+	return path, nil
+}
+
+// append writes record as a line of the dead-letter file, rotating the file
+// first when the line would take it over maxSize
+func (c *Capture) append(record Record) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if info, err := os.Stat(c.file); err == nil && info.Size() > 0 && c.maxSize > 0 && info.Size()+int64(len(b)) > c.maxSize {
+		if err := os.Rename(c.file, c.file+".1"); err != nil {
+			return fmt.Errorf("rotating capture file: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(c.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening capture file: %w", err)
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("writing capture file: %w", err)
+	}
+	return f.Close()
+}
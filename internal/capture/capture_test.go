@@ -0,0 +1,127 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCaptureSave(t *testing.T) {
+	c, err := New(t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	addr := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 50222}
+	path, err := c.Save("abcd1234", addr, []byte(`{"type":"bad"`), errors.New("unexpected EOF"))
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record Record
+	if err := json.Unmarshal(b, &record); err != nil {
+		t.Fatalf("Invalid capture file: %v", err)
+	}
+
+	if record.PacketID != "abcd1234" || record.Source != "192.168.1.10:50222" {
+		t.Errorf("Unexpected record %+v", record)
+	}
+	if record.Hex != "7b2274797065223a2262616422" {
+		t.Errorf("Unexpected hex %s", record.Hex)
+	}
+	if record.Error != "unexpected EOF" {
+		t.Errorf("Unexpected error %s", record.Error)
+	}
+}
+
+func TestCaptureRateLimit(t *testing.T) {
+	c, err := New(t.TempDir(), 2)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Save("id", nil, []byte("x"), nil); err != nil {
+			t.Fatalf("Save() %d error = %v", i, err)
+		}
+		now = now.Add(time.Millisecond)
+	}
+	if _, err := c.Save("id", nil, []byte("x"), nil); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := c.Save("id", nil, []byte("x"), nil); err != nil {
+		t.Errorf("Expected capture in new window, got %v", err)
+	}
+
+	entries, _ := os.ReadDir(c.Dir())
+	if len(entries) != 3 {
+		t.Errorf("Expected 3 capture files, got %d", len(entries))
+	}
+}
+
+func TestCaptureFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+	c, err := NewFile(path, 0, 0)
+	if err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	c.now = func() time.Time { return time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC) }
+
+	addr := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 50222}
+	save := func() {
+		t.Helper()
+		got, err := c.Save("abcd1234", addr, []byte(`{"type":"bad"`), errors.New("unexpected EOF"))
+		if err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if got != path {
+			t.Errorf("Save() path = %s, want %s", got, path)
+		}
+	}
+
+	save()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Room for exactly two records, so the third rotates the file
+	c.maxSize = 2 * info.Size()
+	save()
+	save()
+
+	rotated, err := os.ReadFile(path + ".1")
+	if err != nil {
+		t.Fatalf("Expected rotated file: %v", err)
+	}
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(rotated, []byte("\n")); n != 2 {
+		t.Errorf("Expected 2 records in rotated file, got %d", n)
+	}
+	if n := bytes.Count(current, []byte("\n")); n != 1 {
+		t.Errorf("Expected 1 record in current file, got %d", n)
+	}
+
+	var record Record
+	if err := json.Unmarshal(bytes.TrimSpace(current), &record); err != nil {
+		t.Fatalf("Invalid record line: %v", err)
+	}
+	if record.Source != "192.168.1.10:50222" || record.Error != "unexpected EOF" {
+		t.Errorf("Unexpected record %+v", record)
+	}
+}
@@ -0,0 +1,241 @@
+// Package climate builds NOAA-style monthly and yearly climatological
+// summaries from the observations stored in InfluxDB
+package climate
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/daily"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/meteo"
+	"github.com/jacaudi/tempest-influxdb/internal/tempest"
+)
+
+// Querier runs Flux and InfluxQL queries, see influx.QueryClient
+type Querier interface {
+	Query(ctx context.Context, flux string) ([]influx.Record, error)
+	QueryInfluxQL(ctx context.Context, influxql string) ([]influx.Record, error)
+}
+
+// Day holds the aggregates of one local day. Values that have no data are NaN.
+type Day struct {
+	Date     time.Time
+	TempMean float64 // °C
+	TempHigh float64 // °C
+	TempLow  float64 // °C
+	Rain     float64 // mm
+	WindAvg  float64 // m/s
+	GustHigh float64 // m/s
+}
+
+// HasTemp reports whether the day has temperature data
+func (d Day) HasTemp() bool {
+	return !math.IsNaN(d.TempHigh) && !math.IsNaN(d.TempLow)
+}
+
+// DegreeDays returns heating and cooling degree days from the day's extremes
+func (d Day) DegreeDays() (heating, cooling float64) {
+	if !d.HasTemp() {
+		return math.NaN(), math.NaN()
+	}
+	s := daily.Stats{TempMin: d.TempLow, TempMax: d.TempHigh}
+	return s.DegreeDays()
+}
+
+// aggregate is one daily value queried from InfluxDB. When the query
+// returns several tables for a day, merge combines their values.
+type aggregate struct {
+	field string
+	fn    string
+	merge func(values []float64) float64
+	set   func(d *Day, v float64)
+}
+
+var aggregates = []aggregate{
+	{"temp", "mean", mean, func(d *Day, v float64) { d.TempMean = v }},
+	{"temp", "max", maximum, func(d *Day, v float64) { d.TempHigh = v }},
+	{"temp", "min", minimum, func(d *Day, v float64) { d.TempLow = v }},
+	{"precipitation", "sum", total, func(d *Day, v float64) { d.Rain = v }},
+	{"wind_avg", "mean", mean, func(d *Day, v float64) { d.WindAvg = v }},
+	{"wind_gust", "max", maximum, func(d *Day, v float64) { d.GustHigh = v }},
+}
+
+func total(values []float64) float64 {
+	var t float64
+	for _, v := range values {
+		t += v
+	}
+	return t
+}
+
+func maximum(values []float64) float64 {
+	m := math.NaN()
+	for _, v := range values {
+		m = maxValue(m, v)
+	}
+	return m
+}
+
+func minimum(values []float64) float64 {
+	m := math.NaN()
+	for _, v := range values {
+		m = minValue(m, v)
+	}
+	return m
+}
+
+// Source reads daily aggregates of one station from the measurement
+// observations are written to
+type Source struct {
+	Querier     Querier
+	Bucket      string
+	Measurement string
+	Station     string
+	Location    *time.Location
+	// InfluxQL reads from InfluxDB 1.x, whose database is the one the
+	// Querier is configured with
+	InfluxQL bool
+	// Units are the units of fields not written in metric, such as "f" for
+	// temp; their values are converted back to metric
+	Units map[string]string
+}
+
+// NewSource creates a Source reading the observations of station, or of
+// all stations when empty, as cfg writes them
+func NewSource(cfg *config.Config, querier Querier, station string, loc *time.Location) (*Source, error) {
+	units, err := tempest.FieldUnits(cfg)
+	if err != nil {
+		return nil, err
+	}
+	bucket, measurement := Storage(cfg, station)
+	return &Source{
+		Querier:     querier,
+		Bucket:      bucket,
+		Measurement: measurement,
+		Station:     station,
+		Location:    loc,
+		InfluxQL:    influx.IsV1Path(cfg.Influx_API_Path),
+		Units:       units,
+	}, nil
+}
+
+// Storage returns the bucket and measurement obs_st observations of
+// station are written to, following the Influx routes of cfg
+func Storage(cfg *config.Config, station string) (bucket, measurement string) {
+	route, _ := cfg.InfluxRoute("obs_st", station)
+	return cmp.Or(route.Bucket, cfg.Influx_Bucket), cmp.Or(route.Measurement, "weather")
+}
+
+// Days returns one Day for every local date in [start, end), in order
+func (s *Source) Days(ctx context.Context, start, end time.Time) ([]Day, error) {
+	var days []Day
+	index := make(map[string]int)
+	for t := start; t.Before(end); t = t.AddDate(0, 0, 1) {
+		index[t.Format(time.DateOnly)] = len(days)
+		nan := math.NaN()
+		days = append(days, Day{Date: t, TempMean: nan, TempHigh: nan, TempLow: nan, Rain: nan, WindAvg: nan, GustHigh: nan})
+	}
+
+	for _, a := range aggregates {
+		records, err := s.query(ctx, a, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("querying daily %s of %s: %w", a.fn, a.field, err)
+		}
+		values := make(map[int][]float64)
+		for _, r := range records {
+			t, v, ok := s.value(a, r)
+			if !ok {
+				continue
+			}
+			if unit := s.Units[a.field]; unit != "" {
+				if v, ok = meteo.ToMetric(unit, v); !ok {
+					return nil, fmt.Errorf("field %s has unknown unit %q", a.field, unit)
+				}
+			}
+			if i, ok := index[t.In(s.Location).Format(time.DateOnly)]; ok {
+				values[i] = append(values[i], v)
+			}
+		}
+		for i, v := range values {
+			a.set(&days[i], a.merge(v))
+		}
+	}
+	return days, nil
+}
+
+// query returns the records of one daily aggregate
+func (s *Source) query(ctx context.Context, a aggregate, start, end time.Time) ([]influx.Record, error) {
+	if s.InfluxQL {
+		return s.Querier.QueryInfluxQL(ctx, s.influxQL(a, start, end))
+	}
+	return s.Querier.Query(ctx, s.flux(a, start, end))
+}
+
+// value returns the window start and value of a record. Flux returns them
+// in the _time and _value columns, InfluxQL in the time column, in
+// nanoseconds since the epoch, and a column named after the function.
+func (s *Source) value(a aggregate, r influx.Record) (time.Time, float64, bool) {
+	var t time.Time
+	var raw string
+	if s.InfluxQL {
+		ns, err := strconv.ParseInt(r["time"], 10, 64)
+		if err != nil {
+			return t, 0, false
+		}
+		t, raw = time.Unix(0, ns), r[a.fn]
+	} else {
+		var err error
+		if t, err = time.Parse(time.RFC3339Nano, r["_time"]); err != nil {
+			return t, 0, false
+		}
+		raw = r["_value"]
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	return t, v, err == nil
+}
+
+// flux returns the query for one daily aggregate. Series are grouped so
+// that points with different tags, such as the delivering hub, share one
+// table per day. Windows are aligned to local midnight and labelled with
+// their start.
+func (s *Source) flux(a aggregate, start, end time.Time) string {
+	filter := fmt.Sprintf(`r._measurement == %q and r._field == %q`, s.Measurement, a.field)
+	group := `"_measurement", "_field"`
+	if s.Station != "" {
+		filter += fmt.Sprintf(` and r.station == %q`, s.Station)
+		group += `, "station"`
+	}
+	return fmt.Sprintf(`import "timezone"
+
+from(bucket: %q)
+  |> range(start: %s, stop: %s)
+  |> filter(fn: (r) => %s)
+  |> group(columns: [%s])
+  |> aggregateWindow(every: 1d, fn: %s, timeSrc: "_start", createEmpty: false, location: timezone.location(name: %q))
+`, s.Bucket, start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339), filter, group, a.fn, s.Location.String())
+}
+
+// influxQL returns the InfluxQL query for one daily aggregate. tz aligns
+// the windows to local midnight, and series are combined as the query
+// does not group by tag.
+func (s *Source) influxQL(a aggregate, start, end time.Time) string {
+	q := fmt.Sprintf(`SELECT %s(%s) FROM %s WHERE time >= '%s' AND time < '%s'`,
+		a.fn, quoteIdentifier(a.field), quoteIdentifier(s.Measurement),
+		start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+	if s.Station != "" {
+		q += ` AND "station" = '` + strings.ReplaceAll(s.Station, `'`, `\'`) + `'`
+	}
+	return q + fmt.Sprintf(` GROUP BY time(1d) fill(none) tz('%s')`, s.Location.String())
+}
+
+// quoteIdentifier returns name as a double-quoted InfluxQL identifier
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `\"`) + `"`
+}
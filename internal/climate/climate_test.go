@@ -0,0 +1,244 @@
+package climate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+// fakeQuerier answers each aggregate query with one value per listed day,
+// and the records of tables with further values
+type fakeQuerier struct {
+	queries []string
+	values  map[string]map[string]string // "field fn" -> RFC3339 time -> value
+	tables  map[string][]influx.Record   // "field fn" -> records
+}
+
+func (f *fakeQuerier) Query(ctx context.Context, flux string) ([]influx.Record, error) {
+	f.queries = append(f.queries, flux)
+	var records []influx.Record
+	for key, values := range f.values {
+		if queries(flux, key) {
+			for t, v := range values {
+				records = append(records, influx.Record{"_time": t, "_value": v})
+			}
+		}
+	}
+	for key, tables := range f.tables {
+		if queries(flux, key) {
+			records = append(records, tables...)
+		}
+	}
+	return records, nil
+}
+
+// QueryInfluxQL answers like Query, with times in nanoseconds and values in
+// a column named after the function
+func (f *fakeQuerier) QueryInfluxQL(ctx context.Context, influxql string) ([]influx.Record, error) {
+	f.queries = append(f.queries, influxql)
+	var records []influx.Record
+	for key, values := range f.values {
+		field, fn, _ := strings.Cut(key, " ")
+		if !strings.HasPrefix(influxql, fmt.Sprintf("SELECT %s(%q)", fn, field)) {
+			continue
+		}
+		for ts, v := range values {
+			t, err := time.Parse(time.RFC3339, ts)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, influx.Record{"name": "weather", "time": fmt.Sprint(t.UnixNano()), fn: v})
+		}
+	}
+	return records, nil
+}
+
+// queries reports whether flux queries the "field fn" aggregate key
+func queries(flux, key string) bool {
+	field, fn, _ := strings.Cut(key, " ")
+	return strings.Contains(flux, fmt.Sprintf("r._field == %q", field)) && strings.Contains(flux, "fn: "+fn+",")
+}
+
+func TestSourceDays(t *testing.T) {
+	denver, err := time.LoadLocation("America/Denver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := &fakeQuerier{values: map[string]map[string]string{
+		// Windows start at local midnight, 07:00 UTC in March before DST
+		"temp max":          {"2024-03-01T07:00:00Z": "15.5", "2024-03-03T07:00:00Z": "9"},
+		"temp min":          {"2024-03-01T07:00:00Z": "2.5"},
+		"precipitation sum": {"2024-03-01T07:00:00Z": "3.2"},
+		"wind_gust max":     {"2024-03-01T07:00:00Z": "10"},
+		"temp mean":         {"2024-03-01T07:00:00Z": "8.1"},
+		"wind_avg mean":     {"2024-03-01T07:00:00Z": "2.5"},
+	}}
+	s := &Source{Querier: q, Bucket: "weather", Measurement: "weather", Station: "ST-1", Location: denver}
+
+	start, end := MonthRange(time.Date(2024, 3, 15, 0, 0, 0, 0, denver))
+	days, err := s.Days(context.Background(), start, end)
+	if err != nil {
+		t.Fatalf("Days() error = %v", err)
+	}
+
+	if len(days) != 31 {
+		t.Fatalf("Expected 31 days, got %d", len(days))
+	}
+	if len(q.queries) != len(aggregates) {
+		t.Errorf("Expected one query per aggregate, got %d", len(q.queries))
+	}
+	for _, want := range []string{`from(bucket: "weather")`, `r._measurement == "weather"`, `r.station == "ST-1"`, `group(columns: ["_measurement", "_field", "station"])`, `timezone.location(name: "America/Denver")`, "range(start: 2024-03-01T07:00:00Z, stop: 2024-04-01T06:00:00Z)"} {
+		if !strings.Contains(q.queries[0], want) {
+			t.Errorf("Expected %q in query:\n%s", want, q.queries[0])
+		}
+	}
+
+	first := days[0]
+	if first.TempHigh != 15.5 || first.TempLow != 2.5 || first.Rain != 3.2 || first.GustHigh != 10 {
+		t.Errorf("Unexpected first day %+v", first)
+	}
+	if !math.IsNaN(days[1].TempHigh) {
+		t.Errorf("Expected missing day to be NaN, got %+v", days[1])
+	}
+	if days[2].TempHigh != 9 || days[2].HasTemp() {
+		t.Errorf("Expected third day with only a high, got %+v", days[2])
+	}
+}
+
+func TestSourceDaysMergesTables(t *testing.T) {
+	// Tables of two hubs that the query did not group together
+	table := func(values ...string) []influx.Record {
+		var records []influx.Record
+		for _, v := range values {
+			records = append(records, influx.Record{"_time": "2024-03-01T00:00:00Z", "_value": v})
+		}
+		return records
+	}
+	q := &fakeQuerier{tables: map[string][]influx.Record{
+		"temp mean":         table("8", "10"),
+		"temp max":          table("15", "16"),
+		"temp min":          table("3", "2"),
+		"precipitation sum": table("1.5", "2"),
+		"wind_avg mean":     table("2", "3"),
+		"wind_gust max":     table("11", "9"),
+	}}
+	s := &Source{Querier: q, Bucket: "weather", Measurement: "weather", Location: time.UTC}
+
+	start, end := MonthRange(time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+	days, err := s.Days(context.Background(), start, end)
+	if err != nil {
+		t.Fatalf("Days() error = %v", err)
+	}
+	if !strings.Contains(q.queries[0], `group(columns: ["_measurement", "_field"])`) {
+		t.Errorf("Expected series grouped by measurement and field in query:\n%s", q.queries[0])
+	}
+
+	want := Day{Date: start, TempMean: 9, TempHigh: 16, TempLow: 2, Rain: 3.5, WindAvg: 2.5, GustHigh: 11}
+	if days[0] != want {
+		t.Errorf("First day = %+v, want %+v", days[0], want)
+	}
+}
+
+func TestSourceDaysInfluxQL(t *testing.T) {
+	q := &fakeQuerier{values: map[string]map[string]string{
+		"temp max":          {"2024-03-01T00:00:00Z": "59"},
+		"precipitation sum": {"2024-03-01T00:00:00Z": "0.5"},
+	}}
+	s := &Source{
+		Querier:     q,
+		Measurement: "tempest",
+		Station:     "ST-1",
+		Location:    time.UTC,
+		InfluxQL:    true,
+		Units:       map[string]string{"temp": "f", "precipitation": "in"},
+	}
+
+	start, end := MonthRange(time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+	days, err := s.Days(context.Background(), start, end)
+	if err != nil {
+		t.Fatalf("Days() error = %v", err)
+	}
+	if want := `SELECT mean("temp") FROM "tempest" WHERE time >= '2024-03-01T00:00:00Z' AND time < '2024-04-01T00:00:00Z' AND "station" = 'ST-1' GROUP BY time(1d) fill(none) tz('UTC')`; q.queries[0] != want {
+		t.Errorf("Query = %q, want %q", q.queries[0], want)
+	}
+	if got := days[0]; math.Abs(got.TempHigh-15) > 0.001 || math.Abs(got.Rain-12.7) > 0.001 {
+		t.Errorf("Expected values converted to metric, got %+v", got)
+	}
+}
+
+func day(date time.Time, high, low, rain float64) Day {
+	return Day{Date: date, TempMean: (high + low) / 2, TempHigh: high, TempLow: low, Rain: rain, WindAvg: 5, GustHigh: 10}
+}
+
+func TestMonthly(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	nan := math.NaN()
+	days := []Day{
+		day(start, 20, 10, 1),
+		{Date: start.AddDate(0, 0, 1), TempMean: nan, TempHigh: nan, TempLow: nan, Rain: nan, WindAvg: nan, GustHigh: nan},
+		day(start.AddDate(0, 0, 2), 30, 20, 2.5),
+	}
+
+	r := Monthly("ST-1", days)
+	if r.Title != "MONTHLY CLIMATOLOGICAL SUMMARY for MAR 2024" || len(r.Rows) != 3 {
+		t.Fatalf("Unexpected report %q with %d rows", r.Title, len(r.Rows))
+	}
+	total := r.Total
+	if total.High != 30 || total.Low != 10 || total.Rain != 3.5 || total.Mean != 20 {
+		t.Errorf("Unexpected totals %+v", total)
+	}
+	if total.Heating != 3 || total.Cooling != 7 {
+		t.Errorf("Expected degree days 3/7, got %v/%v", total.Heating, total.Cooling)
+	}
+	if total.Wind != 18 || total.Gust != 36 {
+		t.Errorf("Expected wind in km/h, got %v/%v", total.Wind, total.Gust)
+	}
+
+	var b bytes.Buffer
+	if err := r.Write(&b, FormatText); err != nil {
+		t.Fatal(err)
+	}
+	text := b.String()
+	for _, want := range []string{
+		"DAY       MEAN    HIGH     LOW     HDD     CDD    RAIN    WIND    GUST",
+		"1         15.0    20.0    10.0     3.0     0.0     1.0    18.0    36.0",
+		"2          ---     ---     ---     ---     ---     ---     ---     ---",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in text report:\n%s", want, text)
+		}
+	}
+}
+
+func TestYearly(t *testing.T) {
+	var days []Day
+	for d := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); d.Year() == 2024; d = d.AddDate(0, 0, 1) {
+		days = append(days, day(d, 10, 0, 1))
+	}
+
+	r := Yearly("<ST-1>", days)
+	if len(r.Rows) != 12 || r.Rows[1].Label != "FEB" || r.Rows[1].Rain != 29 {
+		t.Fatalf("Unexpected monthly rows %+v", r.Rows[:2])
+	}
+	if r.Total.Rain != 366 {
+		t.Errorf("Expected 366 mm for the leap year, got %v", r.Total.Rain)
+	}
+
+	var b bytes.Buffer
+	if err := r.Write(&b, FormatHTML); err != nil {
+		t.Fatal(err)
+	}
+	html := b.String()
+	if !strings.Contains(html, "<td>FEB</td>") || !strings.Contains(html, "&lt;ST-1&gt;") {
+		t.Errorf("Unexpected HTML report:\n%s", html)
+	}
+	if err := r.Write(&b, "pdf"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
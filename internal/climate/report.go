@@ -0,0 +1,253 @@
+package climate
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// Output formats of a report
+const (
+	FormatText = "text"
+	FormatHTML = "html"
+)
+
+// Row is one line of a report: a day of a monthly or a month of a yearly
+// summary. Temperatures are in °C, rain in mm and wind in km/h.
+type Row struct {
+	Label   string
+	Mean    float64
+	High    float64
+	Low     float64
+	Heating float64
+	Cooling float64
+	Rain    float64
+	Wind    float64
+	Gust    float64
+}
+
+// Report is a climatological summary ready to be rendered
+type Report struct {
+	Title    string
+	Station  string
+	Timezone string
+	Unit     string // DAY or MONTH
+	Rows     []Row
+	Total    Row
+}
+
+// Monthly builds the summary of the month starting at the first entry of days
+func Monthly(station string, days []Day) Report {
+	r := Report{Station: station, Unit: "DAY"}
+	if len(days) > 0 {
+		first := days[0].Date
+		r.Title = "MONTHLY CLIMATOLOGICAL SUMMARY for " + strings.ToUpper(first.Format("Jan 2006"))
+		r.Timezone = first.Location().String()
+	}
+	for _, d := range days {
+		r.Rows = append(r.Rows, summarize(fmt.Sprint(d.Date.Day()), []Day{d}))
+	}
+	r.Total = summarize("", days)
+	return r
+}
+
+// Yearly builds the summary of the year of days, one row per month
+func Yearly(station string, days []Day) Report {
+	r := Report{Station: station, Unit: "MONTH"}
+	if len(days) == 0 {
+		return r
+	}
+	first := days[0].Date
+	r.Title = fmt.Sprintf("ANNUAL CLIMATOLOGICAL SUMMARY for %d", first.Year())
+	r.Timezone = first.Location().String()
+
+	var month []Day
+	flush := func() {
+		if len(month) > 0 {
+			label := strings.ToUpper(month[0].Date.Format("Jan"))
+			r.Rows = append(r.Rows, summarize(label, month))
+			month = nil
+		}
+	}
+	for _, d := range days {
+		if len(month) > 0 && d.Date.Month() != month[0].Date.Month() {
+			flush()
+		}
+		month = append(month, d)
+	}
+	flush()
+	r.Total = summarize("", days)
+	return r
+}
+
+// summarize aggregates days into a row, ignoring missing values
+func summarize(label string, days []Day) Row {
+	nan := math.NaN()
+	row := Row{Label: label, High: nan, Low: nan, Heating: nan, Cooling: nan, Rain: nan, Gust: nan}
+	var means, winds []float64
+	for _, d := range days {
+		means = appendValue(means, d.TempMean)
+		winds = appendValue(winds, d.WindAvg*3.6)
+		row.High = maxValue(row.High, d.TempHigh)
+		row.Low = minValue(row.Low, d.TempLow)
+		row.Gust = maxValue(row.Gust, d.GustHigh*3.6)
+		row.Rain = sumValue(row.Rain, d.Rain)
+		heating, cooling := d.DegreeDays()
+		row.Heating = sumValue(row.Heating, heating)
+		row.Cooling = sumValue(row.Cooling, cooling)
+	}
+	row.Mean = mean(means)
+	row.Wind = mean(winds)
+	return row
+}
+
+func appendValue(values []float64, v float64) []float64 {
+	if math.IsNaN(v) {
+		return values
+	}
+	return append(values, v)
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func maxValue(a, b float64) float64 {
+	if math.IsNaN(a) || b > a {
+		return b
+	}
+	return a
+}
+
+func minValue(a, b float64) float64 {
+	if math.IsNaN(a) || b < a {
+		return b
+	}
+	return a
+}
+
+func sumValue(a, b float64) float64 {
+	switch {
+	case math.IsNaN(b):
+		return a
+	case math.IsNaN(a):
+		return b
+	default:
+		return a + b
+	}
+}
+
+// value formats v with one decimal, or a dash when it is missing
+func value(v float64) string {
+	if math.IsNaN(v) {
+		return "---"
+	}
+	return fmt.Sprintf("%.1f", v)
+}
+
+// columns are the headings of the value columns of a report
+var columns = []string{"MEAN", "HIGH", "LOW", "HDD", "CDD", "RAIN", "WIND", "GUST"}
+
+// cells returns the formatted values of row in column order
+func (row Row) cells() []string {
+	return []string{
+		value(row.Mean), value(row.High), value(row.Low), value(row.Heating),
+		value(row.Cooling), value(row.Rain), value(row.Wind), value(row.Gust),
+	}
+}
+
+// WriteText renders r as a fixed-width text report
+func (r Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	line := func(label string, cells []string) {
+		fmt.Fprintf(&b, "%-6s", label)
+		for _, c := range cells {
+			fmt.Fprintf(&b, "%8s", c)
+		}
+		b.WriteString("\n")
+	}
+	rule := strings.Repeat("-", 6+8*len(columns)) + "\n"
+
+	fmt.Fprintf(&b, "%s\n\n", r.Title)
+	fmt.Fprintf(&b, "STATION: %s   TIME ZONE: %s\n", r.Station, r.Timezone)
+	b.WriteString("TEMPERATURE (°C), RAIN (mm), WIND SPEED (km/h), DEGREE DAYS BASE 18°C\n\n")
+	line(r.Unit, columns)
+	b.WriteString(rule)
+	for _, row := range r.Rows {
+		line(row.Label, row.cells())
+	}
+	b.WriteString(rule)
+	line("", r.Total.cells())
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Station: {{.Station}}, time zone: {{.Timezone}}. Temperature in °C, rain in mm, wind speed in km/h, degree days base 18 °C.</p>
+<table>
+<thead><tr><th>{{.Unit}}</th>{{range .Columns}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr><td>{{.Label}}</td>{{range .Cells}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</tbody>
+<tfoot><tr><td></td>{{range .Total}}<td>{{.}}</td>{{end}}</tr></tfoot>
+</table>
+</body>
+</html>
+`))
+
+// WriteHTML renders r as an HTML page
+func (r Report) WriteHTML(w io.Writer) error {
+	type htmlRow struct {
+		Label string
+		Cells []string
+	}
+	data := struct {
+		Report
+		Columns []string
+		Rows    []htmlRow
+		Total   []string
+	}{Report: r, Columns: columns, Total: r.Total.cells()}
+	for _, row := range r.Rows {
+		data.Rows = append(data.Rows, htmlRow{Label: row.Label, Cells: row.cells()})
+	}
+	return htmlReport.Execute(w, data)
+}
+
+// Write renders r in format
+func (r Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatText, "":
+		return r.WriteText(w)
+	case FormatHTML:
+		return r.WriteHTML(w)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+// MonthRange returns the local start and end of the month of t
+func MonthRange(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 1, 0)
+}
+
+// YearRange returns the local start and end of the year of t
+func YearRange(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(1, 0, 0)
+}
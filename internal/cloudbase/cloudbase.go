@@ -0,0 +1,37 @@
+// Package cloudbase estimates the height of the cloud base from the spread
+// between temperature and dew point
+package cloudbase
+
+import (
+	"math"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/meteo"
+)
+
+// Field is the name of the cloud base field added to obs_st points
+const Field = "cloud_base_height"
+
+// Enricher adds the cloud_base_height field, in meters above the station,
+// to obs_st points
+type Enricher struct{}
+
+// Enrich adds the cloud base to every obs_st point with a temperature and
+// dew point
+func (Enricher) Enrich(points []*influx.Data) []*influx.Data {
+	for _, m := range points {
+		if m.ReportType != "obs_st" {
+			continue
+		}
+		temp, ok := m.Float("temp")
+		if !ok {
+			continue
+		}
+		dewPoint, ok := m.Float("dew_point")
+		if !ok {
+			continue
+		}
+		m.Fields[Field] = math.Round(meteo.CloudBase(temp, dewPoint))
+	}
+	return points
+}
@@ -0,0 +1,43 @@
+package cloudbase
+
+import (
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func TestEnrich(t *testing.T) {
+	obs := influx.New()
+	obs.ReportType = "obs_st"
+	obs.Fields["temp"] = 20.0
+	obs.Fields["dew_point"] = 12.0
+
+	saturated := influx.New()
+	saturated.ReportType = "obs_st"
+	saturated.Fields["temp"] = 5.0
+	saturated.Fields["dew_point"] = 5.1
+
+	wind := influx.New()
+	wind.ReportType = "rapid_wind"
+	wind.Fields["temp"] = 20.0
+	wind.Fields["dew_point"] = 12.0
+
+	missing := influx.New()
+	missing.ReportType = "obs_st"
+	missing.Fields["temp"] = 20.0
+
+	Enricher{}.Enrich([]*influx.Data{obs, saturated, wind, missing})
+
+	if got := obs.Fields[Field]; got != 1000.0 {
+		t.Errorf("%s = %v, want 1000", Field, got)
+	}
+	if got := saturated.Fields[Field]; got != 0.0 {
+		t.Errorf("%s in fog = %v, want 0", Field, got)
+	}
+	if _, ok := wind.Fields[Field]; ok {
+		t.Error("Expected other report types to be left alone")
+	}
+	if _, ok := missing.Fields[Field]; ok {
+		t.Error("Expected no cloud base without a dew point")
+	}
+}
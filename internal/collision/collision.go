@@ -0,0 +1,126 @@
+// Package collision keeps InfluxDB from merging different points that share
+// a series and timestamp, e.g. a lightning event and an observation of the
+// same station in the same second
+package collision
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/metrics"
+)
+
+// SeqTag numbers the colliding points of a series and timestamp
+const SeqTag = "seq"
+
+// Window is how far, in seconds of observation time, collisions are looked
+// for behind the newest point
+const Window = 10 * 60
+
+// key identifies a series and timestamp, which InfluxDB stores only once
+type key struct {
+	series    string
+	timestamp int64
+	nanos     int64
+}
+
+// Enricher tells apart points that would overwrite each other. The first
+// point of a series and timestamp is left alone; every later point with
+// different fields is either tagged with seq=1, 2, ... or moved by as many
+// nanoseconds, depending on Timestamp_Collisions. Identical copies are left
+// alone, as overwriting them loses nothing.
+type Enricher struct {
+	mode string
+
+	mu     sync.Mutex
+	seen   map[key][]string
+	newest int64
+	pruned int64
+}
+
+// NewEnricher creates an Enricher for the Timestamp_Collisions mode of cfg
+func NewEnricher(cfg *config.Config) *Enricher {
+	return &Enricher{mode: cfg.Timestamp_Collisions, seen: make(map[key][]string)}
+}
+
+// Enrich tags or moves colliding points
+func (e *Enricher) Enrich(points []*influx.Data) []*influx.Data {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, m := range points {
+		k := key{series(m), m.Timestamp, m.Nanos}
+		fields := signature(m.Fields)
+		if slices.Contains(e.seen[k], fields) {
+			continue
+		}
+		seq := len(e.seen[k])
+		e.seen[k] = append(e.seen[k], fields)
+		e.newest = max(e.newest, m.Timestamp)
+		if seq == 0 {
+			continue
+		}
+
+		metrics.TimestampCollisions.WithLabelValues(m.Name).Inc()
+		switch e.mode {
+		case config.CollisionTag:
+			m.Tags[SeqTag] = strconv.Itoa(seq)
+		case config.CollisionOffset:
+			m.Nanos += int64(seq)
+		}
+	}
+	e.prune()
+	return points
+}
+
+// prune forgets timestamps older than Window. It runs at most once per
+// Window so that the cost stays proportional to the number of points.
+func (e *Enricher) prune() {
+	if e.newest-e.pruned < Window {
+		return
+	}
+	for k := range e.seen {
+		if e.newest-k.timestamp > Window {
+			delete(e.seen, k)
+		}
+	}
+	e.pruned = e.newest
+}
+
+// series identifies the series of a point: bucket, measurement and tags
+func series(m *influx.Data) string {
+	var b strings.Builder
+	b.WriteString(m.Bucket)
+	b.WriteByte(0)
+	b.WriteString(m.Name)
+	for _, tag := range sortedKeys(m.Tags) {
+		b.WriteByte(0)
+		b.WriteString(tag + "=" + m.Tags[tag])
+	}
+	return b.String()
+}
+
+// signature identifies the field values of a point
+func signature(fields influx.Fields) string {
+	var b strings.Builder
+	for _, name := range sortedKeys(fields) {
+		fmt.Fprintf(&b, "%s=%v", name, fields[name])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
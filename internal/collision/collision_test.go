@@ -0,0 +1,71 @@
+package collision
+
+import (
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func point(timestamp int64, field string, value any) *influx.Data {
+	m := influx.New()
+	m.Name = "weather"
+	m.Bucket = "weather"
+	m.Timestamp = timestamp
+	m.Tags["station"] = "ST-1"
+	m.Fields[field] = value
+	return m
+}
+
+func TestTag(t *testing.T) {
+	e := NewEnricher(&config.Config{Timestamp_Collisions: config.CollisionTag})
+	first := point(100, "temp", 10.0)
+	copied := point(100, "temp", 10.0)
+	second := point(100, "rapid_wind_speed", 2.0)
+	third := point(100, "strike_distance", int64(5))
+	other := point(101, "temp", 11.0)
+
+	e.Enrich([]*influx.Data{first, copied})
+	e.Enrich([]*influx.Data{second, third, other})
+
+	for _, tt := range []struct {
+		name string
+		m    *influx.Data
+		seq  string
+	}{
+		{"first", first, ""},
+		{"identical copy", copied, ""},
+		{"second", second, "1"},
+		{"third", third, "2"},
+		{"next second", other, ""},
+	} {
+		if tt.m.Tags[SeqTag] != tt.seq {
+			t.Errorf("%s: seq = %q, want %q", tt.name, tt.m.Tags[SeqTag], tt.seq)
+		}
+	}
+}
+
+func TestOffset(t *testing.T) {
+	e := NewEnricher(&config.Config{Timestamp_Collisions: config.CollisionOffset})
+	first := point(100, "temp", 10.0)
+	second := point(100, "rapid_wind_speed", 2.0)
+	otherStation := point(100, "rapid_wind_speed", 3.0)
+	otherStation.Tags["station"] = "ST-2"
+
+	e.Enrich([]*influx.Data{first, second, otherStation})
+	if first.Nanos != 0 || second.Nanos != 1 || otherStation.Nanos != 0 {
+		t.Errorf("Nanos = %d, %d, %d; want 0, 1, 0", first.Nanos, second.Nanos, otherStation.Nanos)
+	}
+	if _, ok := second.Tags[SeqTag]; ok {
+		t.Error("Expected no seq tag in offset mode")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	e := NewEnricher(&config.Config{Timestamp_Collisions: config.CollisionTag})
+	e.Enrich([]*influx.Data{point(100, "temp", 10.0)})
+	e.Enrich([]*influx.Data{point(100+Window+1, "temp", 10.0)})
+	if len(e.seen) != 1 {
+		t.Errorf("Expected timestamps older than the window to be forgotten, got %d", len(e.seen))
+	}
+}
@@ -0,0 +1,58 @@
+// Package comfort derives apparent temperature indices, how warm or cold
+// the air feels, from the obs_st fields
+package comfort
+
+import (
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/meteo"
+)
+
+// Names of the index fields
+const (
+	HumidexField   = "humidex"
+	HeatIndexField = "heat_index"
+	WindChillField = "wind_chill"
+	FeelsLikeField = "feels_like"
+)
+
+// Enabled reports whether cfg enables any index
+func Enabled(cfg *config.Config) bool {
+	return cfg.Humidex || cfg.Feels_Like
+}
+
+// Enricher adds the enabled indices to obs_st points. It runs after
+// calibration, so the indices use the corrected temperature and dew point.
+type Enricher struct {
+	cfg *config.Config
+}
+
+// NewEnricher creates an Enricher for the indices enabled in cfg
+func NewEnricher(cfg *config.Config) *Enricher {
+	return &Enricher{cfg: cfg}
+}
+
+// Enrich adds the indices to every obs_st point with the fields they need
+func (e *Enricher) Enrich(points []*influx.Data) []*influx.Data {
+	for _, m := range points {
+		if m.ReportType != "obs_st" {
+			continue
+		}
+		temp, okTemp := m.Float("temp")
+		dewPoint, okDewPoint := m.Float("dew_point")
+		if e.cfg.Humidex && okTemp && okDewPoint {
+			m.Fields[HumidexField] = influx.Round(meteo.Humidex(temp, dewPoint), 2)
+		}
+
+		humidity, okHumidity := m.Float("relative_humidity")
+		wind, okWind := m.Float("wind_avg")
+		if e.cfg.Feels_Like && okTemp && okHumidity && okWind {
+			chill, _ := meteo.WindChill(temp, wind)
+			index, _ := meteo.HeatIndex(temp, humidity)
+			m.Fields[HeatIndexField] = influx.Round(index, 2)
+			m.Fields[WindChillField] = influx.Round(chill, 2)
+			m.Fields[FeelsLikeField] = influx.Round(meteo.FeelsLike(temp, humidity, wind), 2)
+		}
+	}
+	return points
+}
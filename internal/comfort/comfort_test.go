@@ -0,0 +1,57 @@
+package comfort
+
+import (
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func point(reportType string, fields influx.Fields) *influx.Data {
+	m := influx.New()
+	m.ReportType = reportType
+	m.Fields = fields
+	return m
+}
+
+func TestHumidex(t *testing.T) {
+	obs := point("obs_st", influx.Fields{"temp": 30.0, "dew_point": 15.0})
+	noDewPoint := point("obs_st", influx.Fields{"temp": 30.0})
+	wind := point("rapid_wind", influx.Fields{"temp": 30.0, "dew_point": 15.0})
+
+	NewEnricher(&config.Config{Humidex: true}).Enrich([]*influx.Data{obs, noDewPoint, wind})
+	if obs.Fields[HumidexField] != 33.97 {
+		t.Errorf("%s = %v, want 33.97", HumidexField, obs.Fields[HumidexField])
+	}
+	if _, ok := noDewPoint.Fields[HumidexField]; ok {
+		t.Error("Expected no humidex without a dew point")
+	}
+	if _, ok := wind.Fields[HumidexField]; ok {
+		t.Error("Expected no humidex on rapid_wind")
+	}
+}
+
+func TestFeelsLike(t *testing.T) {
+	cold := point("obs_st", influx.Fields{"temp": -5.0, "relative_humidity": 80.0, "wind_avg": 8.0})
+	hot := point("obs_st", influx.Fields{"temp": 32.2, "relative_humidity": 50.0, "wind_avg": 2.0})
+	noWind := point("obs_st", influx.Fields{"temp": 32.2, "relative_humidity": 50.0})
+
+	NewEnricher(&config.Config{Feels_Like: true}).Enrich([]*influx.Data{cold, hot, noWind})
+	if cold.Fields[FeelsLikeField] != cold.Fields[WindChillField] || cold.Fields[WindChillField] != -12.85 || cold.Fields[HeatIndexField] != -5.0 {
+		t.Errorf("Expected the wind chill to be felt in the cold, got %v", cold.Fields)
+	}
+	if hot.Fields[FeelsLikeField] != hot.Fields[HeatIndexField] || hot.Fields[WindChillField] != 32.2 {
+		t.Errorf("Expected the heat index to be felt in the heat, got %v", hot.Fields)
+	}
+	if _, ok := noWind.Fields[FeelsLikeField]; ok {
+		t.Error("Expected no feels-like temperature without wind")
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	obs := point("obs_st", influx.Fields{"temp": 30.0, "dew_point": 15.0})
+	NewEnricher(&config.Config{}).Enrich([]*influx.Data{obs})
+	if _, ok := obs.Fields[HumidexField]; ok {
+		t.Error("Expected no humidex unless enabled")
+	}
+}
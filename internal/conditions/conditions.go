@@ -0,0 +1,124 @@
+// Package conditions describes an observation in a short line of text, e.g.
+// "Light rain, 12°C, wind NW 15 km/h gusting 30"
+package conditions
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/meteo"
+)
+
+// Field is the name of the optional summary field
+const Field = "summary"
+
+// calmWind is the average wind speed in m/s below which the wind is calm
+const calmWind = 0.5
+
+// compass names the 16 compass points clockwise from north
+var compass = []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+
+// Summary describes the obs_st fields of m. Parts whose fields are
+// missing are left out, so a summary may be empty.
+func Summary(m *influx.Data) string {
+	var parts []string
+	if sky := sky(m); sky != "" {
+		parts = append(parts, sky)
+	}
+	if temp, ok := m.Float("temp"); ok {
+		parts = append(parts, fmt.Sprintf("%.0f°C", temp))
+	}
+	if wind := wind(m); wind != "" {
+		parts = append(parts, wind)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sky describes precipitation, or the cloudiness when it is dry and the
+// clear-sky ratio is known
+func sky(m *influx.Data) string {
+	// obs_st reports the precipitation of its one-minute interval
+	rain, _ := m.Float("precipitation")
+	if rain > 0 {
+		kind := "rain"
+		switch t, _ := m.Float("precipitation_type"); t {
+		case 2:
+			kind = "hail"
+		case 3:
+			kind = "rain and hail"
+		}
+		// Set by the snow enricher, as the sensor cannot detect snow
+		if m.Fields["is_snow_likely"] == true {
+			kind = "snow"
+		}
+		switch rate := rain * 60; {
+		case rate >= meteo.HeavyRain:
+			return "Heavy " + kind
+		case rate >= meteo.ModerateRain:
+			return "Moderate " + kind
+		default:
+			return "Light " + kind
+		}
+	}
+
+	ratio, ok := m.Float("clear_sky_ratio")
+	if !ok || m.Fields["is_daytime"] != true {
+		return ""
+	}
+	switch {
+	case ratio >= 0.8:
+		return "Sunny"
+	case ratio >= 0.4:
+		return "Partly cloudy"
+	default:
+		return "Cloudy"
+	}
+}
+
+// wind describes direction, average speed and gusts in km/h
+func wind(m *influx.Data) string {
+	avg, ok := m.Float("wind_avg")
+	if !ok {
+		return ""
+	}
+	if avg < calmWind {
+		return "wind calm"
+	}
+
+	s := "wind"
+	if dir, ok := m.Float("wind_direction"); ok {
+		s += " " + Compass(dir)
+	}
+	s += fmt.Sprintf(" %.0f km/h", avg*3.6)
+	if gust, ok := m.Float("wind_gust"); ok && math.Round(gust*3.6) > math.Round(avg*3.6) {
+		s += fmt.Sprintf(" gusting %.0f", gust*3.6)
+	}
+	return s
+}
+
+// Compass returns the 16-point compass name of a direction in degrees
+func Compass(degrees float64) string {
+	i := int(math.Round(math.Mod(degrees, 360)/22.5)) % len(compass)
+	if i < 0 {
+		i += len(compass)
+	}
+	return compass[i]
+}
+
+// Enricher adds the summary as a string field to obs_st points
+type Enricher struct{}
+
+// Enrich adds the summary field to every obs_st point
+func (Enricher) Enrich(points []*influx.Data) []*influx.Data {
+	for _, m := range points {
+		if m.ReportType != "obs_st" {
+			continue
+		}
+		if summary := Summary(m); summary != "" {
+			m.Fields[Field] = summary
+		}
+	}
+	return points
+}
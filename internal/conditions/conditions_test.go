@@ -0,0 +1,82 @@
+package conditions
+
+import (
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func TestSummary(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields influx.Fields
+		want   string
+	}{
+		{
+			"light rain",
+			influx.Fields{"temp": 12.3, "precipitation": 0.02, "precipitation_type": int64(1), "wind_avg": 4.17, "wind_gust": 8.33, "wind_direction": int64(315)},
+			"Light rain, 12°C, wind NW 15 km/h gusting 30",
+		},
+		{
+			"heavy hail",
+			influx.Fields{"temp": 18.0, "precipitation": 0.2, "precipitation_type": int64(2)},
+			"Heavy hail, 18°C",
+		},
+		{
+			"snow likely",
+			influx.Fields{"temp": -1.0, "precipitation": 0.01, "precipitation_type": int64(1), "is_snow_likely": true},
+			"Light snow, -1°C",
+		},
+		{
+			"sunny and calm",
+			influx.Fields{"temp": 25.5, "precipitation": 0.0, "is_daytime": true, "clear_sky_ratio": 0.95, "wind_avg": 0.2},
+			"Sunny, 26°C, wind calm",
+		},
+		{
+			"cloudy night is not reported",
+			influx.Fields{"temp": -3.0, "is_daytime": false, "clear_sky_ratio": 0.1},
+			"-3°C",
+		},
+		{
+			"no gust above average",
+			influx.Fields{"wind_avg": 5.0, "wind_gust": 5.05, "wind_direction": int64(90)},
+			"wind E 18 km/h",
+		},
+		{"nothing known", influx.Fields{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Summary(&influx.Data{Fields: tt.fields}); got != tt.want {
+				t.Errorf("Summary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompass(t *testing.T) {
+	tests := map[float64]string{0: "N", 11: "N", 12: "NNE", 90: "E", 200: "SSW", 350: "N", 360: "N", -90: "W"}
+	for degrees, want := range tests {
+		if got := Compass(degrees); got != want {
+			t.Errorf("Compass(%v) = %s, want %s", degrees, got, want)
+		}
+	}
+}
+
+func TestEnricher(t *testing.T) {
+	obs := influx.New()
+	obs.ReportType = "obs_st"
+	obs.Fields["temp"] = 12.0
+	wind := influx.New()
+	wind.ReportType = "rapid_wind"
+	wind.Fields["rapid_wind_speed"] = 3.0
+
+	Enricher{}.Enrich([]*influx.Data{obs, wind})
+
+	if obs.Fields[Field] != "12°C" {
+		t.Errorf("Expected summary on obs_st, got %q", obs.Fields[Field])
+	}
+	if _, ok := wind.Fields[Field]; ok {
+		t.Error("Expected rapid_wind to be left alone")
+	}
+}
@@ -1,12 +1,20 @@
 package config
 
 import (
+	"cmp"
 	"fmt"
 	"log"
+	"maps"
+	"net"
 	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/samber/lo"
+	"github.com/jacaudi/tempest-influxdb/internal/meteo"
 	"github.com/spf13/viper"
 
 	flag "github.com/spf13/pflag"
@@ -14,74 +22,1354 @@ import (
 
 // Config holds all configuration settings for the tempest influx application
 type Config struct {
-	Config_Dir               string `mapstructure:"CONFIG_DIR"`
-	Listen_Address           string `mapstructure:"LISTEN_ADDRESS"`
-	Influx_URL               string `mapstructure:"INFLUX_URL"`
-	Influx_API_Path          string `mapstructure:"INFLUX_API_PATH"`
-	Influx_Org               string `mapstructure:"INFLUX_ORG"`
-	Influx_Token             string `mapstructure:"INFLUX_TOKEN"`
-	Influx_Bucket            string `mapstructure:"INFLUX_BUCKET"`
-	Influx_Bucket_Rapid_Wind string `mapstructure:"INFLUX_BUCKET_RAPID_WIND"`
-	Buffer                   int
-	Verbose                  bool
-	Debug                    bool
-	Raw_UDP                  bool `mapstructure:"RAW_UDP"`
-	Noop                     bool
-	Rapid_Wind               bool `mapstructure:"RAPID_WIND"`
+	Config_Dir                   string             `mapstructure:"CONFIG_DIR"`
+	Input                        string             `mapstructure:"INPUT"`
+	Listen_Address               string             `mapstructure:"LISTEN_ADDRESS"`
+	HTTP_Listen_Address          string             `mapstructure:"HTTP_LISTEN_ADDRESS"`
+	HTTP_TLS_Cert                string             `mapstructure:"HTTP_TLS_CERT"`
+	HTTP_TLS_Key                 string             `mapstructure:"HTTP_TLS_KEY"`
+	GRPC_Listen_Address          string             `mapstructure:"GRPC_LISTEN_ADDRESS"`
+	HTTP_CORS_Origins            []string           `mapstructure:"HTTP_CORS_ORIGINS"`
+	API_Token                    string             `mapstructure:"API_TOKEN"`
+	API_Username                 string             `mapstructure:"API_USERNAME"`
+	API_Password                 string             `mapstructure:"API_PASSWORD"`
+	Influx_URL                   string             `mapstructure:"INFLUX_URL"`
+	Influx_TLS_CA_File           string             `mapstructure:"INFLUX_TLS_CA_FILE"`
+	Influx_TLS_Cert_File         string             `mapstructure:"INFLUX_TLS_CERT_FILE"`
+	Influx_TLS_Key_File          string             `mapstructure:"INFLUX_TLS_KEY_FILE"`
+	Influx_API_Path              string             `mapstructure:"INFLUX_API_PATH"`
+	Influx_Version               string             `mapstructure:"INFLUX_VERSION"`
+	Influx_Username              string             `mapstructure:"INFLUX_USERNAME"`
+	Influx_Password              string             `mapstructure:"INFLUX_PASSWORD"`
+	Influx_Retention_Policy      string             `mapstructure:"INFLUX_RETENTION_POLICY"`
+	Influx_Org                   string             `mapstructure:"INFLUX_ORG"`
+	Influx_Token                 string             `mapstructure:"INFLUX_TOKEN"`
+	Influx_Bucket                string             `mapstructure:"INFLUX_BUCKET"`
+	Influx_Bucket_Rapid_Wind     string             `mapstructure:"INFLUX_BUCKET_RAPID_WIND"`
+	Influx_Write_Timeout         time.Duration      `mapstructure:"INFLUX_WRITE_TIMEOUT"`
+	Influx_Client_Timeout        time.Duration      `mapstructure:"INFLUX_CLIENT_TIMEOUT"`
+	Influx_Rate_Limit_Max_Wait   time.Duration      `mapstructure:"INFLUX_RATE_LIMIT_MAX_WAIT"`
+	Influx_Max_Concurrent_Writes int                `mapstructure:"INFLUX_MAX_CONCURRENT_WRITES"`
+	Influx_Retry_Attempts        int                `mapstructure:"INFLUX_RETRY_ATTEMPTS"`
+	Influx_Retry_Backoff         time.Duration      `mapstructure:"INFLUX_RETRY_BACKOFF"`
+	Influx_Retry_Max_Backoff     time.Duration      `mapstructure:"INFLUX_RETRY_MAX_BACKOFF"`
+	Influx_Max_Batch_Lines       int                `mapstructure:"INFLUX_MAX_BATCH_LINES"`
+	Influx_Max_Batch_Bytes       int                `mapstructure:"INFLUX_MAX_BATCH_BYTES"`
+	Influx_Flush_Interval        time.Duration      `mapstructure:"INFLUX_FLUSH_INTERVAL"`
+	Influx_Flush_Size            int                `mapstructure:"INFLUX_FLUSH_SIZE"`
+	Influx_Max_Idle_Conns        int                `mapstructure:"INFLUX_MAX_IDLE_CONNS"`
+	Influx_Max_Conns_Per_Host    int                `mapstructure:"INFLUX_MAX_CONNS_PER_HOST"`
+	Influx_Idle_Conn_Timeout     time.Duration      `mapstructure:"INFLUX_IDLE_CONN_TIMEOUT"`
+	Summary_Interval             time.Duration      `mapstructure:"SUMMARY_INTERVAL"`
+	Rapid_Wind_Every             int                `mapstructure:"RAPID_WIND_EVERY"`
+	Rapid_Wind_Interval          time.Duration      `mapstructure:"RAPID_WIND_INTERVAL"`
+	Watchdog_Interval            time.Duration      `mapstructure:"WATCHDOG_INTERVAL"`
+	Ntp_Server                   string             `mapstructure:"NTP_SERVER"`
+	WeatherFlow_Token            string             `mapstructure:"WEATHERFLOW_TOKEN"`
+	Nearcast_Interval            time.Duration      `mapstructure:"NEARCAST_INTERVAL"`
+	Poll_Fallback_After          time.Duration      `mapstructure:"POLL_FALLBACK_AFTER"`
+	Health_Max_Silence           time.Duration      `mapstructure:"HEALTH_MAX_SILENCE"`
+	Forecast_Interval            time.Duration      `mapstructure:"FORECAST_INTERVAL"`
+	Official_Station             string             `mapstructure:"OFFICIAL_STATION"`
+	Official_Interval            time.Duration      `mapstructure:"OFFICIAL_INTERVAL"`
+	Ntp_Interval                 time.Duration      `mapstructure:"NTP_INTERVAL"`
+	Ntp_Max_Offset               time.Duration      `mapstructure:"NTP_MAX_OFFSET"`
+	Ntp_Correct                  bool               `mapstructure:"NTP_CORRECT"`
+	Watchdog_Max_Goroutines      int                `mapstructure:"WATCHDOG_MAX_GOROUTINES"`
+	Watchdog_Max_Heap_MB         int                `mapstructure:"WATCHDOG_MAX_HEAP_MB"`
+	Watchdog_Max_Queue           int                `mapstructure:"WATCHDOG_MAX_QUEUE"`
+	Summary_Bucket               string             `mapstructure:"SUMMARY_BUCKET"`
+	Capture_Dir                  string             `mapstructure:"CAPTURE_DIR"`
+	Capture_Rate                 int                `mapstructure:"CAPTURE_RATE"`
+	Capture_File                 string             `mapstructure:"CAPTURE_FILE"`
+	Capture_Max_Size_MB          int                `mapstructure:"CAPTURE_MAX_SIZE_MB"`
+	Mqtt_Broker                  string             `mapstructure:"MQTT_BROKER"`
+	Mqtt_Topic                   string             `mapstructure:"MQTT_TOPIC"`
+	Mqtt_Client_ID               string             `mapstructure:"MQTT_CLIENT_ID"`
+	Mqtt_Username                string             `mapstructure:"MQTT_USERNAME"`
+	Mqtt_Password                string             `mapstructure:"MQTT_PASSWORD"`
+	Mqtt_QoS                     int                `mapstructure:"MQTT_QOS"`
+	Mqtt_Publish_Topic           string             `mapstructure:"MQTT_PUBLISH_TOPIC"`
+	Mqtt_Encoding                string             `mapstructure:"MQTT_ENCODING"`
+	Mqtt_HA_Discovery_Prefix     string             `mapstructure:"MQTT_HA_DISCOVERY_PREFIX"`
+	Schema_Registry_URL          string             `mapstructure:"SCHEMA_REGISTRY_URL"`
+	Schema_Registry_Subject      string             `mapstructure:"SCHEMA_REGISTRY_SUBJECT"`
+	Schema_Registry_Username     string             `mapstructure:"SCHEMA_REGISTRY_USERNAME"`
+	Schema_Registry_Password     string             `mapstructure:"SCHEMA_REGISTRY_PASSWORD"`
+	Telegraf_Address             string             `mapstructure:"TELEGRAF_ADDRESS"`
+	Zabbix_Server                string             `mapstructure:"ZABBIX_SERVER"`
+	Zabbix_Key_Prefix            string             `mapstructure:"ZABBIX_KEY_PREFIX"`
+	Pushgateway_URL              string             `mapstructure:"PUSHGATEWAY_URL"`
+	Archive_S3_URL               string             `mapstructure:"ARCHIVE_S3_URL"`
+	Archive_S3_Region            string             `mapstructure:"ARCHIVE_S3_REGION"`
+	Archive_S3_Bucket            string             `mapstructure:"ARCHIVE_S3_BUCKET"`
+	Archive_S3_Prefix            string             `mapstructure:"ARCHIVE_S3_PREFIX"`
+	Archive_S3_Access_Key        string             `mapstructure:"ARCHIVE_S3_ACCESS_KEY"`
+	Archive_S3_Secret_Key        string             `mapstructure:"ARCHIVE_S3_SECRET_KEY"`
+	Pushgateway_Job              string             `mapstructure:"PUSHGATEWAY_JOB"`
+	Pushgateway_Instance         string             `mapstructure:"PUSHGATEWAY_INSTANCE"`
+	Webhook_URL                  string             `mapstructure:"WEBHOOK_URL"`
+	Webhook_Template             string             `mapstructure:"WEBHOOK_TEMPLATE"`
+	Webhook_Headers              map[string]string  `mapstructure:"WEBHOOK_HEADERS"`
+	Webhook_Username             string             `mapstructure:"WEBHOOK_USERNAME"`
+	Webhook_Password             string             `mapstructure:"WEBHOOK_PASSWORD"`
+	Webhook_Report_Types         []string           `mapstructure:"WEBHOOK_REPORT_TYPES"`
+	Report_Types                 []string           `mapstructure:"REPORT_TYPES"`
+	Exclude_Report_Types         []string           `mapstructure:"EXCLUDE_REPORT_TYPES"`
+	Webhook_Batch                bool               `mapstructure:"WEBHOOK_BATCH"`
+	Relay_To                     []string           `mapstructure:"RELAY_TO"`
+	Relay                        []RelayRule        `mapstructure:"RELAY"`
+	Quarantine_Dir               string             `mapstructure:"QUARANTINE_DIR"`
+	Spool_Dir                    string             `mapstructure:"SPOOL_DIR"`
+	Spool_Max_Size_MB            int                `mapstructure:"SPOOL_MAX_SIZE_MB"`
+	Spool_Max_Age                time.Duration      `mapstructure:"SPOOL_MAX_AGE"`
+	Spool_Retry_Interval         time.Duration      `mapstructure:"SPOOL_RETRY_INTERVAL"`
+	Retention_Max_Age            time.Duration      `mapstructure:"RETENTION_MAX_AGE"`
+	Retention_Max_Size_MB        int                `mapstructure:"RETENTION_MAX_SIZE_MB"`
+	Retention_Interval           time.Duration      `mapstructure:"RETENTION_INTERVAL"`
+	State_Dir                    string             `mapstructure:"STATE_DIR"`
+	Timestamp_Collisions         string             `mapstructure:"TIMESTAMP_COLLISIONS"`
+	Units                        string             `mapstructure:"UNITS"`
+	Field_Units                  map[string]string  `mapstructure:"FIELD_UNITS"`
+	Remote_Config_URL            string             `mapstructure:"REMOTE_CONFIG_URL"`
+	Remote_Config_Token          string             `mapstructure:"REMOTE_CONFIG_TOKEN"`
+	Remote_Config_Interval       time.Duration      `mapstructure:"REMOTE_CONFIG_INTERVAL"`
+	Timezone                     string             `mapstructure:"TIMEZONE"`
+	Stations                     map[string]Station `mapstructure:"STATIONS"`
+	Field_Bounds                 map[string]Bound   `mapstructure:"FIELD_BOUNDS"`
+	Station_Elevation            float64            `mapstructure:"STATION_ELEVATION"`
+	Wind_Smoothing_Alpha         float64            `mapstructure:"WIND_SMOOTHING_ALPHA"`
+	Pressure_Filter_Size         int                `mapstructure:"PRESSURE_FILTER_SIZE"`
+	Lightning_Min_Energy         float64            `mapstructure:"LIGHTNING_MIN_ENERGY"`
+	Lightning_Min_Distance       float64            `mapstructure:"LIGHTNING_MIN_DISTANCE"`
+	Lightning_Min_Strikes        int                `mapstructure:"LIGHTNING_MIN_STRIKES"`
+	Lightning_Window             time.Duration      `mapstructure:"LIGHTNING_WINDOW"`
+	Lightning_Alert_Distance     float64            `mapstructure:"LIGHTNING_ALERT_DISTANCE"`
+	Lightning_Alert_Cooldown     time.Duration      `mapstructure:"LIGHTNING_ALERT_COOLDOWN"`
+	Lightning_Alert_Channels     []string           `mapstructure:"LIGHTNING_ALERT_CHANNELS"`
+	Buffer                       int
+	Verbose                      bool
+	Debug                        bool
+	Raw_UDP                      bool `mapstructure:"RAW_UDP"`
+	Noop                         bool
+	Influx_HTTP2                 bool `mapstructure:"INFLUX_HTTP2"`
+	Rapid_Wind                   bool `mapstructure:"RAPID_WIND"`
+	Rapid_Wind_Subsecond         bool `mapstructure:"RAPID_WIND_SUBSECOND"`
+	Lightning                    bool `mapstructure:"LIGHTNING"`
+	Archive_Raw                  bool `mapstructure:"ARCHIVE_RAW"`
+	Strict                       bool
+	Strict_Schema                bool `mapstructure:"STRICT_SCHEMA"`
+	Extra_Obs_Fields             bool `mapstructure:"EXTRA_OBS_FIELDS"`
+	Raw_Unknown_Types            bool `mapstructure:"RAW_UNKNOWN_TYPES"`
+	Daily_Stats                  bool `mapstructure:"DAILY_STATS"`
+	Rain_Rate                    bool `mapstructure:"RAIN_RATE"`
+	ET0                          bool `mapstructure:"ET0"`
+	Conditions_Summary           bool `mapstructure:"CONDITIONS_SUMMARY"`
+	Snow_Likely                  bool `mapstructure:"SNOW_LIKELY"`
+	Cloud_Base                   bool `mapstructure:"CLOUD_BASE"`
+	Humidex                      bool `mapstructure:"HUMIDEX"`
+	Feels_Like                   bool `mapstructure:"FEELS_LIKE"`
+	Sea_Level_Pressure           bool `mapstructure:"SEA_LEVEL_PRESSURE"`
+	Pressure_Trend               bool `mapstructure:"PRESSURE_TREND"`
+	Influx_Gzip                  bool `mapstructure:"INFLUX_GZIP"`
+	Influx_Integer_Fields        bool `mapstructure:"INFLUX_INTEGER_FIELDS"`
+	Dedupe_Hubs                  bool `mapstructure:"DEDUPE_HUBS"`
+	HTTP_TLS_Self_Signed         bool `mapstructure:"HTTP_TLS_SELF_SIGNED"`
+	HTTP_Expvar                  bool `mapstructure:"HTTP_EXPVAR"`
+	HTTP_Health                  bool `mapstructure:"HTTP_HEALTH"`
+	Nearcast_Rain                bool `mapstructure:"NEARCAST_RAIN"`
+	Forecast                     bool `mapstructure:"FORECAST"`
+	Station_Metadata             bool `mapstructure:"STATION_METADATA"`
+	Lifecycle_Events             bool `mapstructure:"LIFECYCLE_EVENTS"`
+	Watchdog_Restart             bool `mapstructure:"WATCHDOG_RESTART"`
+	Graceful_Upgrade             bool `mapstructure:"GRACEFUL_UPGRADE"`
+	Mqtt_HA_Discovery            bool `mapstructure:"MQTT_HA_DISCOVERY"`
+
+	// Field_Mappings replaces the built-in layout of the observation array
+	// of a report type
+	Field_Mappings map[string][]FieldMapping `mapstructure:"FIELD_MAPPINGS"`
+	// Influx_Credentials overrides Influx_Org and Influx_Token per bucket
+	Influx_Credentials map[string]InfluxCredentials `mapstructure:"INFLUX_CREDENTIALS"`
+	// Influx_Mirrors are further InfluxDB instances every point is written to
+	Influx_Mirrors []InfluxMirror `mapstructure:"INFLUX_MIRRORS"`
+	// Influx_TLS_Insecure_Skip_Verify disables verification of the
+	// InfluxDB server certificate
+	Influx_TLS_Insecure_Skip_Verify bool `mapstructure:"INFLUX_TLS_INSECURE_SKIP_VERIFY"`
+	// Field_Filters keeps or drops fields per measurement
+	Field_Filters map[string]FieldFilter `mapstructure:"FIELD_FILTERS"`
+	// Influx_Routes sends the points of some report types or stations to
+	// other buckets or measurements
+	Influx_Routes []InfluxRoute `mapstructure:"INFLUX_ROUTES"`
+	// Alert_Channels are the destinations alerts are sent to
+	Alert_Channels []AlertChannel `mapstructure:"ALERT_CHANNELS"`
+	// Alert_Rules alert when a field crosses a threshold
+	Alert_Rules []AlertRule `mapstructure:"ALERT_RULES"`
+
+	// remote is the remote configuration document the Config was loaded
+	// from, compared against by WatchRemote
+	remote []byte
+}
+
+// Station holds settings for a single device, keyed by its serial number in
+// Config.Stations
+type Station struct {
+	// Name and Location are written as the station_name and location tags
+	// of the device's points, for dashboards that should not be keyed on
+	// serial numbers
+	Name     string `mapstructure:"name"`
+	Location string `mapstructure:"location"`
+	// Timezone is the IANA zone whose midnight starts the device's day;
+	// empty uses Config.Timezone
+	Timezone string `mapstructure:"timezone"`
+	// Latitude and Longitude locate the device in decimal degrees, north
+	// and east positive
+	Latitude  float64 `mapstructure:"latitude"`
+	Longitude float64 `mapstructure:"longitude"`
+	// Elevation of the device above sea level in meters
+	Elevation float64 `mapstructure:"elevation"`
+	// ZabbixHost is the Zabbix host receiving the device's items; empty
+	// uses the serial number
+	ZabbixHost string `mapstructure:"zabbix_host"`
+	// OfficialStation is the ICAO identifier of the METAR station the
+	// device is compared with; empty uses Config.Official_Station
+	OfficialStation string `mapstructure:"official_station"`
+	// StationID is the WeatherFlow cloud ID of the station the device
+	// belongs to, used by features that call the cloud API
+	StationID int `mapstructure:"station_id"`
+	// Calibration corrects known sensor biases, keyed by field name or
+	// by "wind" for all wind speed fields
+	Calibration map[string]Calibration `mapstructure:"calibration"`
+}
+
+// Calibration corrects a field as value*Multiplier + Offset. A zero
+// Multiplier leaves the value unscaled.
+type Calibration struct {
+	Offset     float64 `mapstructure:"offset"`
+	Multiplier float64 `mapstructure:"multiplier"`
+}
+
+// Apply returns the calibrated value
+func (c Calibration) Apply(value float64) float64 {
+	if c.Multiplier != 0 {
+		value *= c.Multiplier
+	}
+	return value + c.Offset
+}
+
+// HasCoordinates reports whether the station's location is configured
+func (s Station) HasCoordinates() bool {
+	return s.Latitude != 0 || s.Longitude != 0
+}
+
+// Station returns the settings for the device with serial. Keys are matched
+// case-insensitively because the config file loader lower-cases them.
+func (c *Config) Station(serial string) Station {
+	if s, ok := c.Stations[serial]; ok {
+		return s
+	}
+	for key, s := range c.Stations {
+		if strings.EqualFold(key, serial) {
+			return s
+		}
+	}
+	return Station{}
+}
+
+// Elevation returns the elevation in meters of the device with serial,
+// falling back to Station_Elevation for devices without one
+func (c *Config) Elevation(serial string) float64 {
+	if e := c.Station(serial).Elevation; e != 0 {
+		return e
+	}
+	return c.Station_Elevation
+}
+
+// Location returns the time zone used for the daily and hourly boundaries of
+// the device with serial
+func (c *Config) Location(serial string) (*time.Location, error) {
+	name := c.Timezone
+	if tz := c.Station(serial).Timezone; tz != "" {
+		name = tz
+	}
+	return time.LoadLocation(name)
+}
+
+// ReportTypeForwarded reports whether reports of reportType are written:
+// it is listed in Report_Types, or Report_Types is empty, and it is not
+// listed in Exclude_Report_Types
+func (c *Config) ReportTypeForwarded(reportType string) bool {
+	if len(c.Report_Types) > 0 && !slices.Contains(c.Report_Types, reportType) {
+		return false
+	}
+	return !slices.Contains(c.Exclude_Report_Types, reportType)
+}
+
+// HasStationIDs reports whether any station has a WeatherFlow cloud ID
+func (c *Config) HasStationIDs() bool {
+	for _, s := range c.Stations {
+		if s.StationID != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// OfficialStation returns the METAR station that device serial is
+// compared with, or "" for none
+func (c *Config) OfficialStation(serial string) string {
+	if station := c.Station(serial).OfficialStation; station != "" {
+		return station
+	}
+	return c.Official_Station
+}
+
+// HasOfficialStations reports whether any device is compared with a METAR
+// station
+func (c *Config) HasOfficialStations() bool {
+	if c.Official_Station != "" {
+		return true
+	}
+	for _, s := range c.Stations {
+		if s.OfficialStation != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// InfluxCredentials are the organisation and token used to write to one
+// bucket; empty values fall back to Influx_Org and Influx_Token
+type InfluxCredentials struct {
+	Org   string `mapstructure:"org"`
+	Token string `mapstructure:"token"`
+}
+
+// influxCredentials returns the credentials configured for bucket. Keys are
+// matched case-insensitively because the config file loader lower-cases
+// them.
+func (c *Config) influxCredentials(bucket string) InfluxCredentials {
+	if creds, ok := c.Influx_Credentials[bucket]; ok {
+		return creds
+	}
+	for key, creds := range c.Influx_Credentials {
+		if strings.EqualFold(key, bucket) {
+			return creds
+		}
+	}
+	return InfluxCredentials{}
 }
 
+// InfluxOrg returns the organisation that owns bucket: the org of its
+// credentials, else of a route to it
+func (c *Config) InfluxOrg(bucket string) string {
+	if org := c.influxCredentials(bucket).Org; org != "" {
+		return org
+	}
+	for _, r := range c.Influx_Routes {
+		if r.Org != "" && strings.EqualFold(r.Bucket, bucket) {
+			return r.Org
+		}
+	}
+	return c.Influx_Org
+}
+
+// InfluxRoute sends the points of matching reports to Bucket, owned by Org,
+// and names them Measurement; empty settings keep the defaults. Types are
+// path.Match patterns such as "evt_*" and Stations serial numbers; an empty
+// list matches all.
+type InfluxRoute struct {
+	Types       []string `mapstructure:"types"`
+	Stations    []string `mapstructure:"stations"`
+	Bucket      string   `mapstructure:"bucket"`
+	Org         string   `mapstructure:"org"`
+	Measurement string   `mapstructure:"measurement"`
+}
+
+// Matches reports whether reports of reportType from station take r
+func (r InfluxRoute) Matches(reportType, station string) bool {
+	if len(r.Stations) > 0 && !slices.ContainsFunc(r.Stations, func(s string) bool { return strings.EqualFold(s, station) }) {
+		return false
+	}
+	if len(r.Types) == 0 {
+		return true
+	}
+	for _, pattern := range r.Types {
+		if ok, _ := path.Match(pattern, reportType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// InfluxRoutes returns the configured routes followed by one sending
+// rapid_wind to Influx_Bucket_Rapid_Wind when set
+func (c *Config) InfluxRoutes() []InfluxRoute {
+	routes := slices.Clone(c.Influx_Routes)
+	if c.Influx_Bucket_Rapid_Wind != "" {
+		routes = append(routes, InfluxRoute{Types: []string{"rapid_wind"}, Bucket: c.Influx_Bucket_Rapid_Wind})
+	}
+	return routes
+}
+
+// InfluxRoute returns the first route reports of reportType from station
+// take, if any
+func (c *Config) InfluxRoute(reportType, station string) (InfluxRoute, bool) {
+	for _, r := range c.InfluxRoutes() {
+		if r.Matches(reportType, station) {
+			return r, true
+		}
+	}
+	return InfluxRoute{}, false
+}
+
+// InfluxToken returns the token used to access bucket
+func (c *Config) InfluxToken(bucket string) string {
+	if token := c.influxCredentials(bucket).Token; token != "" {
+		return token
+	}
+	return c.Influx_Token
+}
+
+// InfluxMirror is an additional InfluxDB instance that receives every
+// point written to the main one. Empty settings fall back to the main
+// Influx_* settings, except the credentials, which are the mirror's own.
+type InfluxMirror struct {
+	// Name identifies the mirror in logs and metrics; defaults to its
+	// position in the list
+	Name             string `mapstructure:"name"`
+	URL              string `mapstructure:"url"`
+	API_Path         string `mapstructure:"api_path"`
+	Version          string `mapstructure:"version"`
+	Org              string `mapstructure:"org"`
+	Token            string `mapstructure:"token"`
+	Username         string `mapstructure:"username"`
+	Password         string `mapstructure:"password"`
+	Retention_Policy string `mapstructure:"retention_policy"`
+	// Bucket and Bucket_Rapid_Wind replace Influx_Bucket and
+	// Influx_Bucket_Rapid_Wind on this mirror; rapid wind goes to Bucket
+	// when only that is set
+	Bucket            string `mapstructure:"bucket"`
+	Bucket_Rapid_Wind string `mapstructure:"bucket_rapid_wind"`
+	// Route_Buckets replaces the buckets of Influx_Routes on this mirror,
+	// keyed by the main bucket name; routes to other buckets keep them
+	Route_Buckets map[string]string `mapstructure:"route_buckets"`
+	// TLS_CA_File is a PEM file of certificate authorities trusted in
+	// addition to the system ones, for instances with a private CA.
+	// TLS_Cert_File and TLS_Key_File are a PEM client certificate and key
+	// for instances that require mutual TLS.
+	TLS_CA_File              string `mapstructure:"tls_ca_file"`
+	TLS_Cert_File            string `mapstructure:"tls_cert_file"`
+	TLS_Key_File             string `mapstructure:"tls_key_file"`
+	TLS_Insecure_Skip_Verify bool   `mapstructure:"tls_insecure_skip_verify"`
+}
+
+// MirrorName returns the name of the mirror at index i
+func (c *Config) MirrorName(i int) string {
+	if name := c.Influx_Mirrors[i].Name; name != "" {
+		return name
+	}
+	return strconv.Itoa(i + 1)
+}
+
+// RouteBucket returns the bucket points routed to bucket on the main
+// instance are written to on the mirror
+func (m InfluxMirror) RouteBucket(bucket string) string {
+	if renamed, ok := m.Route_Buckets[bucket]; ok {
+		return renamed
+	}
+	for key, renamed := range m.Route_Buckets {
+		if strings.EqualFold(key, bucket) {
+			return renamed
+		}
+	}
+	return bucket
+}
+
+// ForMirror returns a copy of the configuration that writes to the mirror
+// at index i instead of the main InfluxDB
+func (c *Config) ForMirror(i int) *Config {
+	m := c.Influx_Mirrors[i]
+	mirror := *c
+	mirror.Influx_URL = m.URL
+	mirror.Influx_API_Path = cmp.Or(m.API_Path, DefaultInfluxAPIPath)
+	mirror.Influx_Version = m.Version
+	mirror.Influx_Org = m.Org
+	mirror.Influx_Token = m.Token
+	mirror.Influx_Username = m.Username
+	mirror.Influx_Password = m.Password
+	mirror.Influx_Retention_Policy = m.Retention_Policy
+	mirror.Influx_Bucket = cmp.Or(m.Bucket, c.Influx_Bucket)
+	mirror.Influx_Bucket_Rapid_Wind = cmp.Or(m.Bucket_Rapid_Wind, m.Bucket, c.Influx_Bucket_Rapid_Wind)
+	mirror.Influx_Credentials = nil
+	// Route orgs belong to the main instance; the mirror's org owns all
+	// its buckets
+	mirror.Influx_Routes = make([]InfluxRoute, len(c.Influx_Routes))
+	for j, r := range c.Influx_Routes {
+		r.Bucket = m.RouteBucket(r.Bucket)
+		r.Org = ""
+		mirror.Influx_Routes[j] = r
+	}
+	mirror.Influx_TLS_CA_File = m.TLS_CA_File
+	mirror.Influx_TLS_Cert_File = m.TLS_Cert_File
+	mirror.Influx_TLS_Key_File = m.TLS_Key_File
+	mirror.Influx_TLS_Insecure_Skip_Verify = m.TLS_Insecure_Skip_Verify
+	mirror.Influx_Mirrors = nil
+	return &mirror
+}
+
+// WatchdogEnabled reports whether any resource limit is configured
+func (c *Config) WatchdogEnabled() bool {
+	return c.Watchdog_Max_Goroutines > 0 || c.Watchdog_Max_Heap_MB > 0 || c.Watchdog_Max_Queue > 0
+}
+
+// Bound is the plausible range of a field. Values outside it are handled
+// according to Policy; a nil Min or Max leaves that side open.
+type Bound struct {
+	Min    *float64 `mapstructure:"min"`
+	Max    *float64 `mapstructure:"max"`
+	Policy string   `mapstructure:"policy"`
+}
+
+// FieldMapping names the value at one index of an observation array. An
+// empty Name skips the value; Unit, if set, is converted to metric.
+type FieldMapping struct {
+	Name string `mapstructure:"name"`
+	Unit string `mapstructure:"unit"`
+}
+
+// FieldFilter limits the fields of a measurement to Keep, when set, and
+// removes those in Drop
+type FieldFilter struct {
+	Keep []string `mapstructure:"keep"`
+	Drop []string `mapstructure:"drop"`
+}
+
+// FieldFilter returns the filter of measurement. Keys are matched
+// case-insensitively because the config file loader lower-cases them.
+func (c *Config) FieldFilter(measurement string) (FieldFilter, bool) {
+	if f, ok := c.Field_Filters[measurement]; ok {
+		return f, true
+	}
+	for key, f := range c.Field_Filters {
+		if strings.EqualFold(key, measurement) {
+			return f, true
+		}
+	}
+	return FieldFilter{}, false
+}
+
+// Types of alert channels
+const (
+	AlertWebhook  = "webhook"
+	AlertNtfy     = "ntfy"
+	AlertPushover = "pushover"
+	AlertSlack    = "slack"
+)
+
+// AlertChannel is a destination of alerts. A webhook receives the alert as
+// JSON, or rendered from Template; ntfy, Pushover and Slack get a message
+// in their own format.
+type AlertChannel struct {
+	Name string `mapstructure:"name"`
+	// Type is webhook, ntfy, pushover or slack; webhook when empty
+	Type string `mapstructure:"type"`
+	// URL is the webhook, ntfy topic or Slack incoming webhook URL, and
+	// defaults to the Pushover API for pushover
+	URL string `mapstructure:"url"`
+	// Token is the Pushover application token, or an ntfy access token
+	Token string `mapstructure:"token"`
+	// User is the Pushover user or group key
+	User     string            `mapstructure:"user"`
+	Headers  map[string]string `mapstructure:"headers"`
+	Template string            `mapstructure:"template"`
+}
+
+// AlertRule alerts when Field of a station stays below or above Value for
+// For. The alert is resolved once the field is back across Value by
+// Hysteresis, so a value hovering at the threshold alerts only once.
+type AlertRule struct {
+	// Name identifies the rule in alerts; Field when empty
+	Name  string `mapstructure:"name"`
+	Field string `mapstructure:"field"`
+	// Operator is "<" or ">"
+	Operator   string        `mapstructure:"operator"`
+	Value      float64       `mapstructure:"value"`
+	For        time.Duration `mapstructure:"for"`
+	Hysteresis float64       `mapstructure:"hysteresis"`
+	// Channels are the names of the channels the rule alerts; all when empty
+	Channels []string `mapstructure:"channels"`
+}
+
+// AlertChannels returns the channels named in names, or all channels when
+// names is empty
+func (c *Config) AlertChannels(names []string) []AlertChannel {
+	if len(names) == 0 {
+		return c.Alert_Channels
+	}
+	var channels []AlertChannel
+	for _, ch := range c.Alert_Channels {
+		if slices.Contains(names, ch.Name) {
+			channels = append(channels, ch)
+		}
+	}
+	return channels
+}
+
+// Policies for values outside their Bound
+const (
+	BoundClamp = "clamp"
+	BoundDrop  = "drop"
+	BoundTag   = "tag"
+)
+
+// RelayRule forwards raw datagrams whose report type matches one of Types
+// to Destination. Types are path.Match patterns such as "evt_*"; an empty
+// list forwards every datagram.
+type RelayRule struct {
+	Destination string   `mapstructure:"destination"`
+	Types       []string `mapstructure:"types"`
+}
+
+// RelayRules returns the configured relay rules, with every RELAY_TO
+// destination as an unfiltered rule
+func (c *Config) RelayRules() []RelayRule {
+	rules := make([]RelayRule, 0, len(c.Relay_To)+len(c.Relay))
+	for _, dest := range c.Relay_To {
+		rules = append(rules, RelayRule{Destination: dest})
+	}
+	return append(rules, c.Relay...)
+}
+
+// Versions of the InfluxDB write API
+const (
+	InfluxVersion1 = "1"
+	InfluxVersion2 = "2"
+)
+
+// Input sources
+const (
+	InputUDP       = "udp"
+	InputMQTT      = "mqtt"
+	InputStdin     = "stdin"
+	InputWebSocket = "websocket"
+)
+
+// Message encodings for broker outputs
+const (
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+	EncodingAvro     = "avro"
+)
+
+// Ways to tell apart different points sharing a series and timestamp
+const (
+	CollisionTag    = "tag"
+	CollisionOffset = "offset"
+)
+
+// Unit systems fields are written in
+const (
+	UnitsMetric   = "metric"
+	UnitsImperial = "imperial"
+	UnitsCustom   = "custom"
+)
+
 // Default configuration values
 const (
+	DefaultInput         = InputUDP
 	DefaultListenAddress = ":50222"
 	DefaultInfluxURL     = "https://localhost:8086"
 	DefaultInfluxAPIPath = "/api/v2/write"
 	DefaultBuffer        = 10240
 	DefaultTimeout       = 10 // seconds
 
-	// HTTP client optimization constants
+	// DefaultMqttClientID identifies the collector to MQTT brokers
+	DefaultMqttClientID = "tempest-influxdb"
+
+	// DefaultHADiscoveryPrefix is the topic prefix Home Assistant watches
+	// for MQTT discovery messages
+	DefaultHADiscoveryPrefix = "homeassistant"
+
+	// DefaultSchemaRegistrySubject is the subject the Avro schema is registered under
+	DefaultSchemaRegistrySubject = "tempest-observation-value"
+
+	// DefaultWriteTimeout bounds a single write request, independently of the
+	// overall HTTP client timeout
+	DefaultWriteTimeout = 5 * time.Second
+
+	// DefaultRateLimitMaxWait bounds how long a write honors Retry-After
+	DefaultRateLimitMaxWait = 5 * time.Minute
+
+	// DefaultRetryAttempts is how often a write failing transiently is
+	// repeated
+	DefaultRetryAttempts = 3
+
+	// DefaultRetryBackoff is the pause before the first repeat of a write
+	DefaultRetryBackoff = time.Second
+
+	// DefaultRetryMaxBackoff caps the pause between repeats of a write
+	DefaultRetryMaxBackoff = 30 * time.Second
+
+	// DefaultWatchdogInterval is how often the watchdog samples resource usage
+	DefaultWatchdogInterval = time.Minute
+
+	// DefaultZabbixKeyPrefix is prepended to field names to form item keys
+	DefaultZabbixKeyPrefix = "tempest."
+
+	// DefaultPushgatewayJob is the job label of metrics pushed to a Pushgateway
+	DefaultPushgatewayJob = "tempest"
+
+	// DefaultLightningWindow is the window in which LIGHTNING_MIN_STRIKES
+	// strikes must occur
+	DefaultLightningWindow = 15 * time.Minute
+
+	// DefaultLightningAlertCooldown is the minimum time between two
+	// lightning alerts of a station
+	DefaultLightningAlertCooldown = 30 * time.Minute
+
+	// DefaultNearcastInterval is how often corrected rain is checked for
+	DefaultNearcastInterval = time.Hour
+
+	// DefaultForecastInterval is how often the forecast is fetched
+	DefaultForecastInterval = 30 * time.Minute
+
+	// DefaultOfficialInterval is how often official observations are fetched
+	DefaultOfficialInterval = 20 * time.Minute
+
+	// DefaultRetentionInterval is how often old local files are removed
+	DefaultRetentionInterval = time.Hour
+
+	// DefaultNtpInterval is how often the host clock is checked
+	DefaultNtpInterval = time.Hour
+
+	// DefaultNtpMaxOffset is the clock offset tolerated without a warning
+	DefaultNtpMaxOffset = 2 * time.Second
+
+	// DefaultSpoolMaxSizeMB caps the spool
+	DefaultSpoolMaxSizeMB = 100
+
+	// DefaultSpoolMaxAge is how long spooled points are kept
+	DefaultSpoolMaxAge = 7 * 24 * time.Hour
+
+	// DefaultSpoolRetryInterval is how often spooled points are written again
+	DefaultSpoolRetryInterval = 30 * time.Second
+
+	// DefaultCaptureRate is the maximum number of rejected packets captured per minute
+	DefaultCaptureRate = 10
+
+	// DefaultCaptureMaxSizeMB is the size at which the dead-letter file is rotated
+	DefaultCaptureMaxSizeMB = 10
+
+	// DefaultTimezone is used for daily boundaries of stations without their own timezone
+	DefaultTimezone = "UTC"
+
+	// DefaultMaxConcurrentWrites caps in-flight write requests per target
+	DefaultMaxConcurrentWrites = 4
+
+	// DefaultMaxBatchLines caps the lines of one write request, as
+	// recommended for InfluxDB
+	DefaultMaxBatchLines = 5000
+
+	// DefaultMaxBatchBytes caps the body of one write request well below the
+	// request size limit of InfluxDB Cloud
+	DefaultMaxBatchBytes = 10 << 20
+
+	// DefaultFlushSize is the number of buffered points that triggers a
+	// write before the flush interval has passed
+	DefaultFlushSize = 1000
+
+	// MinRecommendedBuffer is the smallest buffer that comfortably holds every
+	// Tempest broadcast message
+	MinRecommendedBuffer = 1024
+
+	// HTTP client defaults, used when the corresponding INFLUX_ setting is 0
 	HTTPMaxIdleConns    = 100
 	HTTPMaxConnsPerHost = 10
 	HTTPIdleConnTimeout = 90 // seconds
 )
 
-// Validate validates the configuration and returns an error if invalid
-func (c *Config) Validate() error {
-	var validationErrors []string
+// ValidationReport separates configuration problems that prevent startup from
+// settings that work but are probably not what the user intended
+type ValidationReport struct {
+	Errors   []string
+	Warnings []string
+}
+
+// Check validates the configuration and returns all errors and warnings found
+func (c *Config) Check() ValidationReport {
+	var report ValidationReport
 
 	// Validate required fields
 	if c.Influx_URL == "" {
-		validationErrors = append(validationErrors, "INFLUX_URL is required")
+		report.Errors = append(report.Errors, "INFLUX_URL is required")
 	}
 
-	if c.Influx_Org == "" {
-		validationErrors = append(validationErrors, "INFLUX_ORG is required")
+	// The main bucket may carry its own credentials instead. InfluxDB 1.x
+	// has no organisations and may run without authentication.
+	switch c.Influx_Version {
+	case "", InfluxVersion2:
+		if c.InfluxOrg(c.Influx_Bucket) == "" {
+			report.Errors = append(report.Errors, "INFLUX_ORG is required")
+		}
+		if c.InfluxToken(c.Influx_Bucket) == "" {
+			report.Errors = append(report.Errors, "INFLUX_TOKEN is required")
+		}
+		if c.Influx_Username != "" || c.Influx_Retention_Policy != "" {
+			report.Warnings = append(report.Warnings, "INFLUX_USERNAME, INFLUX_PASSWORD and INFLUX_RETENTION_POLICY apply to INFLUX_VERSION 1 only")
+		}
+	case InfluxVersion1:
+		if c.Influx_Username != "" && c.Influx_Password == "" {
+			report.Warnings = append(report.Warnings, "INFLUX_USERNAME is set without INFLUX_PASSWORD")
+		}
+	default:
+		report.Errors = append(report.Errors, fmt.Sprintf("INFLUX_VERSION %q is not one of 1, 2", c.Influx_Version))
 	}
-
-	if c.Influx_Token == "" {
-		validationErrors = append(validationErrors, "INFLUX_TOKEN is required")
+	for bucket, creds := range c.Influx_Credentials {
+		if creds.Org == "" && creds.Token == "" {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("influx_credentials of bucket %s set neither org nor token", bucket))
+		}
 	}
 
 	if c.Influx_Bucket == "" {
-		validationErrors = append(validationErrors, "INFLUX_BUCKET is required")
+		report.Errors = append(report.Errors, "INFLUX_BUCKET is required")
+	}
+	names := make(map[string]bool, len(c.Influx_Mirrors))
+	for i, m := range c.Influx_Mirrors {
+		name := c.MirrorName(i)
+		if names[name] {
+			report.Errors = append(report.Errors, fmt.Sprintf("influx_mirrors name %s is used more than once", name))
+		}
+		names[name] = true
+		if m.URL == "" {
+			report.Errors = append(report.Errors, fmt.Sprintf("influx_mirrors %s has no url", name))
+		} else if u, err := url.Parse(m.URL); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("influx_mirrors %s url is not a valid URL: %v", name, err))
+		} else if u.RawQuery != "" {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("influx_mirrors %s url contains a query string, which is removed together with the write path; org, bucket and precision are added from the mirror settings", name))
+		}
+		switch m.Version {
+		case "", InfluxVersion2:
+			if m.Org == "" || m.Token == "" {
+				report.Errors = append(report.Errors, fmt.Sprintf("influx_mirrors %s requires org and token", name))
+			}
+		case InfluxVersion1:
+		default:
+			report.Errors = append(report.Errors, fmt.Sprintf("influx_mirrors %s version %q is not one of 1, 2", name, m.Version))
+		}
+		if m.TLS_Insecure_Skip_Verify {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("influx_mirrors %s does not verify the server certificate", name))
+		}
+		if (m.TLS_Cert_File == "") != (m.TLS_Key_File == "") {
+			report.Errors = append(report.Errors, fmt.Sprintf("influx_mirrors %s tls_cert_file and tls_key_file must be set together", name))
+		}
+		for bucket, renamed := range m.Route_Buckets {
+			if renamed == "" {
+				report.Errors = append(report.Errors, fmt.Sprintf("influx_mirrors %s route_buckets maps %s to an empty bucket", name, bucket))
+			} else if !slices.ContainsFunc(c.Influx_Routes, func(r InfluxRoute) bool { return strings.EqualFold(r.Bucket, bucket) }) {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("influx_mirrors %s route_buckets names %s, which no route writes to", name, bucket))
+			}
+		}
+	}
+
+	if (c.Influx_TLS_Cert_File == "") != (c.Influx_TLS_Key_File == "") {
+		report.Errors = append(report.Errors, "INFLUX_TLS_CERT_FILE and INFLUX_TLS_KEY_FILE must be set together")
+	}
+	if c.Influx_TLS_Insecure_Skip_Verify {
+		report.Warnings = append(report.Warnings, "INFLUX_TLS_INSECURE_SKIP_VERIFY disables verification of the InfluxDB server certificate")
 	}
 
 	// Validate URL format
 	if c.Influx_URL != "" {
-		if _, err := url.Parse(c.Influx_URL); err != nil {
-			validationErrors = append(validationErrors, fmt.Sprintf("INFLUX_URL is not a valid URL: %v", err))
+		if u, err := url.Parse(c.Influx_URL); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("INFLUX_URL is not a valid URL: %v", err))
+		} else {
+			if u.RawQuery != "" {
+				report.Warnings = append(report.Warnings, "INFLUX_URL contains a query string, which is removed together with the write path; org, bucket and precision are added from INFLUX_ORG and INFLUX_BUCKET")
+			}
+			if u.Scheme == "http" && !isLoopbackHost(u.Hostname()) {
+				report.Warnings = append(report.Warnings, "INFLUX_URL uses unencrypted HTTP to a remote host; the token is sent in clear text")
+			}
+		}
+	}
+
+	switch c.Input {
+	case "", InputUDP, InputStdin:
+	case InputMQTT:
+		if c.Mqtt_Broker == "" {
+			report.Errors = append(report.Errors, "MQTT_BROKER is required for the mqtt input")
+		}
+		if c.Mqtt_Topic == "" {
+			report.Errors = append(report.Errors, "MQTT_TOPIC is required for the mqtt input")
+		}
+	case InputWebSocket:
+		if c.WeatherFlow_Token == "" {
+			report.Errors = append(report.Errors, "WEATHERFLOW_TOKEN is required for the websocket input")
+		}
+		if !c.HasStationIDs() {
+			report.Errors = append(report.Errors, "the websocket input requires a station_id in the stations section")
+		}
+	default:
+		report.Errors = append(report.Errors, fmt.Sprintf("INPUT %q is not one of udp, mqtt, stdin, websocket", c.Input))
+	}
+	if c.Mqtt_Publish_Topic != "" && c.Mqtt_Broker == "" {
+		report.Errors = append(report.Errors, "MQTT_BROKER is required for MQTT_PUBLISH_TOPIC")
+	}
+	switch c.Mqtt_Encoding {
+	case "", EncodingJSON, EncodingProtobuf:
+	case EncodingAvro:
+		if c.Schema_Registry_URL == "" {
+			report.Errors = append(report.Errors, "SCHEMA_REGISTRY_URL is required for the avro encoding")
+		}
+	default:
+		report.Errors = append(report.Errors, fmt.Sprintf("MQTT_ENCODING %q is not one of json, protobuf, avro", c.Mqtt_Encoding))
+	}
+	if c.Mqtt_HA_Discovery {
+		if c.Mqtt_Publish_Topic == "" {
+			report.Errors = append(report.Errors, "MQTT_PUBLISH_TOPIC is required for MQTT_HA_DISCOVERY")
+		}
+		if c.Mqtt_Encoding != "" && c.Mqtt_Encoding != EncodingJSON {
+			report.Errors = append(report.Errors, "MQTT_HA_DISCOVERY requires the json MQTT_ENCODING")
+		}
+	}
+	if c.Mqtt_QoS < 0 || c.Mqtt_QoS > 2 {
+		report.Errors = append(report.Errors, "MQTT_QOS must be 0, 1 or 2")
+	}
+
+	if c.Webhook_URL != "" {
+		if u, err := url.Parse(c.Webhook_URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			report.Errors = append(report.Errors, "WEBHOOK_URL must be an http or https URL")
+		}
+	} else if c.Webhook_Template != "" || len(c.Webhook_Headers) > 0 {
+		report.Warnings = append(report.Warnings, "webhook settings are ignored without WEBHOOK_URL")
+	}
+
+	if c.Remote_Config_URL != "" && c.Remote_Config_Token != "" {
+		if u, err := url.Parse(c.Remote_Config_URL); err == nil && !isLoopbackHost(u.Hostname()) &&
+			(u.Scheme == "http" || u.Scheme == SchemeConsul || u.Scheme == SchemeEtcd) {
+			report.Warnings = append(report.Warnings, "REMOTE_CONFIG_URL uses unencrypted HTTP to a remote host; the token is sent in clear text")
+		}
+	}
+
+	if c.Archive_S3_Bucket != "" {
+		if u, err := url.Parse(c.Archive_S3_URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			report.Errors = append(report.Errors, "ARCHIVE_S3_URL must be an http or https URL")
+		}
+		if c.Archive_S3_Access_Key == "" || c.Archive_S3_Secret_Key == "" {
+			report.Errors = append(report.Errors, "ARCHIVE_S3_ACCESS_KEY and ARCHIVE_S3_SECRET_KEY are required for the archive")
+		}
+	}
+
+	if c.Pushgateway_URL != "" {
+		if u, err := url.Parse(c.Pushgateway_URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			report.Errors = append(report.Errors, "PUSHGATEWAY_URL must be an http or https URL")
+		}
+	}
+
+	if c.Telegraf_Address != "" {
+		if u, err := url.Parse(c.Telegraf_Address); err != nil || (u.Scheme != "udp" && u.Scheme != "tcp") || u.Port() == "" {
+			report.Errors = append(report.Errors, "TELEGRAF_ADDRESS must be udp://host:port or tcp://host:port")
 		}
 	}
 
 	// Validate listen address format
 	if c.Listen_Address != "" {
 		if !strings.Contains(c.Listen_Address, ":") {
-			validationErrors = append(validationErrors, "LISTEN_ADDRESS must include port (e.g., ':50222')")
+			report.Errors = append(report.Errors, "LISTEN_ADDRESS must include port (e.g., ':50222')")
+		}
+	}
+
+	if c.HTTP_Listen_Address != "" && !strings.Contains(c.HTTP_Listen_Address, ":") {
+		report.Errors = append(report.Errors, "HTTP_LISTEN_ADDRESS must include port (e.g., ':9090')")
+	}
+
+	if (c.HTTP_TLS_Cert == "") != (c.HTTP_TLS_Key == "") {
+		report.Errors = append(report.Errors, "HTTP_TLS_CERT and HTTP_TLS_KEY must be set together")
+	}
+	if c.HTTP_TLS_Cert != "" && c.HTTP_TLS_Self_Signed {
+		report.Warnings = append(report.Warnings, "HTTP_TLS_SELF_SIGNED is ignored because HTTP_TLS_CERT is set")
+	}
+	if c.HTTP_Health && c.HTTP_Listen_Address == "" {
+		report.Warnings = append(report.Warnings, "HTTP_HEALTH has no effect without HTTP_LISTEN_ADDRESS")
+	}
+	if c.Health_Max_Silence < 0 {
+		report.Errors = append(report.Errors, "HEALTH_MAX_SILENCE must not be negative")
+	} else if c.Health_Max_Silence > 0 && !c.HTTP_Health {
+		report.Warnings = append(report.Warnings, "HEALTH_MAX_SILENCE has no effect without HTTP_HEALTH")
+	}
+
+	for _, origin := range c.HTTP_CORS_Origins {
+		if u, err := url.Parse(origin); origin != "*" && (err != nil || u.Scheme == "" || u.Host == "" || u.Path != "") {
+			report.Errors = append(report.Errors, fmt.Sprintf("CORS origin %q must be \"*\" or scheme://host[:port]", origin))
+		}
+	}
+
+	if c.API_Password != "" && c.API_Username == "" {
+		report.Errors = append(report.Errors, "API_USERNAME is required with API_PASSWORD")
+	}
+	if c.API_Username != "" && c.API_Password == "" {
+		report.Warnings = append(report.Warnings, "API_PASSWORD is empty; basic authentication accepts the username alone")
+	}
+	if (c.API_Token != "" || c.API_Username != "") && c.HTTP_TLS_Cert == "" && !c.HTTP_TLS_Self_Signed && c.HTTP_Listen_Address != "" {
+		report.Warnings = append(report.Warnings, "API credentials are sent in clear text without HTTP TLS")
+	}
+
+	if c.GRPC_Listen_Address != "" && !strings.Contains(c.GRPC_Listen_Address, ":") {
+		report.Errors = append(report.Errors, "GRPC_LISTEN_ADDRESS must include port (e.g., ':9091')")
+	}
+
+	for measurement, f := range c.Field_Filters {
+		if len(f.Keep) == 0 && len(f.Drop) == 0 {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("field_filters of measurement %s set neither keep nor drop", measurement))
+		}
+	}
+
+	for i, r := range c.Influx_Routes {
+		if r.Bucket == "" && r.Measurement == "" {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("influx_routes entry %d sets neither bucket nor measurement", i))
+		}
+		if r.Org != "" && r.Bucket == "" {
+			report.Errors = append(report.Errors, fmt.Sprintf("influx_routes entry %d sets org without bucket", i))
+		}
+		for _, pattern := range r.Types {
+			if _, err := path.Match(pattern, ""); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("influx_routes type pattern %q is invalid: %v", pattern, err))
+			}
+		}
+	}
+
+	channels := make(map[string]bool, len(c.Alert_Channels))
+	for i, ch := range c.Alert_Channels {
+		if ch.Name == "" {
+			report.Errors = append(report.Errors, fmt.Sprintf("alert_channels entry %d has no name", i))
+		} else if channels[ch.Name] {
+			report.Errors = append(report.Errors, fmt.Sprintf("alert channel %s is defined twice", ch.Name))
+		}
+		channels[ch.Name] = true
+		switch ch.Type {
+		case "", AlertWebhook, AlertNtfy, AlertSlack:
+			if u, err := url.Parse(ch.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				report.Errors = append(report.Errors, fmt.Sprintf("alert channel %s needs an http or https url", ch.Name))
+			}
+		case AlertPushover:
+			if ch.Token == "" || ch.User == "" {
+				report.Errors = append(report.Errors, fmt.Sprintf("alert channel %s needs the Pushover token and user", ch.Name))
+			}
+		default:
+			report.Errors = append(report.Errors, fmt.Sprintf("alert channel %s has unknown type %q (valid: webhook, ntfy, pushover, slack)", ch.Name, ch.Type))
+		}
+	}
+	for _, name := range c.Lightning_Alert_Channels {
+		if !channels[name] {
+			report.Errors = append(report.Errors, fmt.Sprintf("LIGHTNING_ALERT_CHANNELS names unknown alert channel %s", name))
+		}
+	}
+	for i, r := range c.Alert_Rules {
+		if r.Field == "" {
+			report.Errors = append(report.Errors, fmt.Sprintf("alert_rules entry %d has no field", i))
+		}
+		if r.Operator != "<" && r.Operator != ">" {
+			report.Errors = append(report.Errors, fmt.Sprintf("alert_rules entry %d has operator %q (valid: <, >)", i, r.Operator))
+		}
+		if r.For < 0 || r.Hysteresis < 0 {
+			report.Errors = append(report.Errors, fmt.Sprintf("alert_rules entry %d must not have a negative for or hysteresis", i))
+		}
+		for _, name := range r.Channels {
+			if !channels[name] {
+				report.Errors = append(report.Errors, fmt.Sprintf("alert_rules entry %d names unknown alert channel %s", i, name))
+			}
+		}
+	}
+	if len(c.Alert_Rules) > 0 && len(c.Alert_Channels) == 0 {
+		report.Errors = append(report.Errors, "alert_rules need at least one entry in alert_channels")
+	}
+	if c.Lightning_Alert_Distance < 0 || c.Lightning_Alert_Cooldown < 0 {
+		report.Errors = append(report.Errors, "LIGHTNING_ALERT_DISTANCE and LIGHTNING_ALERT_COOLDOWN must not be negative")
+	}
+	if c.Lightning_Alert_Distance > 0 {
+		if len(c.Alert_Channels) == 0 {
+			report.Errors = append(report.Errors, "LIGHTNING_ALERT_DISTANCE needs at least one entry in alert_channels")
+		}
+		if !c.Lightning {
+			report.Warnings = append(report.Warnings, "LIGHTNING_ALERT_DISTANCE has no effect without LIGHTNING")
+		}
+	}
+
+	for _, rule := range c.RelayRules() {
+		if _, _, err := net.SplitHostPort(rule.Destination); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("relay destination %q must be host:port", rule.Destination))
+		}
+		for _, pattern := range rule.Types {
+			if _, err := path.Match(pattern, ""); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("relay type pattern %q for %s is invalid: %v", pattern, rule.Destination, err))
+			}
+		}
+	}
+
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("TIMEZONE %q is not a valid IANA time zone", c.Timezone))
+	}
+	for serial, station := range c.Stations {
+		if _, err := time.LoadLocation(station.Timezone); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("timezone %q of station %s is not a valid IANA time zone", station.Timezone, serial))
+		}
+		if station.Latitude < -90 || station.Latitude > 90 || station.Longitude < -180 || station.Longitude > 180 {
+			report.Errors = append(report.Errors, fmt.Sprintf("coordinates of station %s are out of range", serial))
+		}
+		for field, cal := range station.Calibration {
+			if cal.Multiplier < 0 {
+				report.Errors = append(report.Errors, fmt.Sprintf("calibration multiplier of %s for station %s must not be negative", field, serial))
+			}
+		}
+	}
+
+	if c.Wind_Smoothing_Alpha < 0 || c.Wind_Smoothing_Alpha > 1 {
+		report.Errors = append(report.Errors, "WIND_SMOOTHING_ALPHA must be between 0 and 1")
+	}
+
+	if c.Retention_Max_Age < 0 || c.Retention_Max_Size_MB < 0 {
+		report.Errors = append(report.Errors, "RETENTION_MAX_AGE and RETENTION_MAX_SIZE_MB must not be negative")
+	}
+	if (c.Retention_Max_Age > 0 || c.Retention_Max_Size_MB > 0) && c.Retention_Interval <= 0 {
+		report.Errors = append(report.Errors, "RETENTION_INTERVAL must be positive")
+	}
+
+	if c.Watchdog_Max_Goroutines < 0 || c.Watchdog_Max_Heap_MB < 0 || c.Watchdog_Max_Queue < 0 {
+		report.Errors = append(report.Errors, "watchdog limits must not be negative")
+	}
+	if c.WatchdogEnabled() && c.Watchdog_Interval <= 0 {
+		report.Errors = append(report.Errors, "WATCHDOG_INTERVAL must be positive")
+	}
+	if c.Watchdog_Restart && !c.WatchdogEnabled() {
+		report.Warnings = append(report.Warnings, "WATCHDOG_RESTART has no effect without a watchdog limit")
+	}
+
+	if c.Nearcast_Rain {
+		if c.WeatherFlow_Token == "" {
+			report.Errors = append(report.Errors, "WEATHERFLOW_TOKEN is required for NEARCAST_RAIN")
+		}
+		if c.Nearcast_Interval <= 0 {
+			report.Errors = append(report.Errors, "NEARCAST_INTERVAL must be positive")
+		}
+		if !c.HasStationIDs() {
+			report.Warnings = append(report.Warnings, "NEARCAST_RAIN has no effect without a station_id in the stations section")
+		}
+	}
+
+	if c.Forecast {
+		if c.WeatherFlow_Token == "" {
+			report.Errors = append(report.Errors, "WEATHERFLOW_TOKEN is required for FORECAST")
+		}
+		if c.Forecast_Interval < time.Minute {
+			report.Errors = append(report.Errors, "FORECAST_INTERVAL must be at least 1m")
+		}
+		if !c.HasStationIDs() {
+			report.Warnings = append(report.Warnings, "FORECAST has no effect without a station_id in the stations section")
+		}
+	}
+
+	if c.Poll_Fallback_After != 0 {
+		if c.WeatherFlow_Token == "" {
+			report.Errors = append(report.Errors, "WEATHERFLOW_TOKEN is required for POLL_FALLBACK_AFTER")
+		}
+		if c.Poll_Fallback_After < time.Minute {
+			report.Errors = append(report.Errors, "POLL_FALLBACK_AFTER must be at least 1m")
+		}
+		if !c.HasStationIDs() {
+			report.Warnings = append(report.Warnings, "POLL_FALLBACK_AFTER has no effect without a station_id in the stations section")
+		}
+	}
+
+	if c.Station_Metadata {
+		if c.WeatherFlow_Token == "" {
+			report.Errors = append(report.Errors, "WEATHERFLOW_TOKEN is required for STATION_METADATA")
+		}
+		if !c.HasStationIDs() {
+			report.Warnings = append(report.Warnings, "STATION_METADATA has no effect without a station_id in the stations section")
 		}
 	}
 
+	if c.HasOfficialStations() && c.Official_Interval < time.Minute {
+		report.Errors = append(report.Errors, "OFFICIAL_INTERVAL must be at least 1m")
+	}
+
+	if c.Ntp_Server != "" && (c.Ntp_Interval <= 0 || c.Ntp_Max_Offset <= 0) {
+		report.Errors = append(report.Errors, "NTP_INTERVAL and NTP_MAX_OFFSET must be positive")
+	}
+	if c.Ntp_Correct && c.Ntp_Server == "" {
+		report.Warnings = append(report.Warnings, "NTP_CORRECT is ignored without NTP_SERVER")
+	}
+
+	if c.Summary_Interval < 0 {
+		report.Errors = append(report.Errors, "SUMMARY_INTERVAL must not be negative")
+	} else if c.Summary_Interval > 0 && (c.Summary_Interval < time.Minute || c.Summary_Interval%time.Minute != 0) {
+		report.Errors = append(report.Errors, "SUMMARY_INTERVAL must be a whole number of minutes")
+	}
+	if c.ET0 && !slices.ContainsFunc(slices.Collect(maps.Values(c.Stations)), Station.HasCoordinates) {
+		report.Warnings = append(report.Warnings, "ET0 has no effect without a station with latitude and longitude")
+	}
+	if c.Rapid_Wind_Every < 0 {
+		report.Errors = append(report.Errors, "RAPID_WIND_EVERY must not be negative")
+	}
+	if c.Rapid_Wind_Interval < 0 {
+		report.Errors = append(report.Errors, "RAPID_WIND_INTERVAL must not be negative")
+	} else if c.Rapid_Wind_Interval > 0 && (c.Rapid_Wind_Interval < time.Second || c.Rapid_Wind_Interval%time.Second != 0) {
+		report.Errors = append(report.Errors, "RAPID_WIND_INTERVAL must be a whole number of seconds")
+	}
+	if c.Rapid_Wind_Every > 1 && c.Rapid_Wind_Interval > 0 {
+		report.Errors = append(report.Errors, "RAPID_WIND_EVERY and RAPID_WIND_INTERVAL cannot both be set")
+	}
+	if !c.Rapid_Wind && (c.Rapid_Wind_Every > 1 || c.Rapid_Wind_Interval > 0) {
+		report.Warnings = append(report.Warnings, "RAPID_WIND_EVERY and RAPID_WIND_INTERVAL have no effect without RAPID_WIND")
+	}
+	if c.Summary_Bucket != "" && c.Summary_Interval == 0 {
+		report.Warnings = append(report.Warnings, "SUMMARY_BUCKET is set but SUMMARY_INTERVAL is 0; no summaries are written")
+	}
+
+	if c.Pressure_Filter_Size < 0 {
+		report.Errors = append(report.Errors, "PRESSURE_FILTER_SIZE must not be negative")
+	} else if c.Pressure_Filter_Size > 0 && c.Pressure_Filter_Size%2 == 0 {
+		report.Warnings = append(report.Warnings, "PRESSURE_FILTER_SIZE is even; an odd size always reports an actual reading")
+	}
+
+	if c.Lightning_Min_Energy < 0 || c.Lightning_Min_Distance < 0 || c.Lightning_Min_Strikes < 0 {
+		report.Errors = append(report.Errors, "lightning filter limits must not be negative")
+	}
+	if c.Lightning_Min_Strikes > 1 && c.Lightning_Window <= 0 {
+		report.Errors = append(report.Errors, "LIGHTNING_WINDOW must be positive when LIGHTNING_MIN_STRIKES is set")
+	}
+	if !c.Lightning && (c.Lightning_Min_Energy > 0 || c.Lightning_Min_Distance > 0 || c.Lightning_Min_Strikes > 1) {
+		report.Warnings = append(report.Warnings, "lightning filters have no effect without LIGHTNING")
+	}
+
+	switch c.Timestamp_Collisions {
+	case "", CollisionTag, CollisionOffset:
+	default:
+		report.Errors = append(report.Errors, fmt.Sprintf("TIMESTAMP_COLLISIONS %q is not one of tag, offset", c.Timestamp_Collisions))
+	}
+
+	switch c.Units {
+	case "", UnitsMetric, UnitsImperial:
+	case UnitsCustom:
+		if len(c.Field_Units) == 0 {
+			report.Errors = append(report.Errors, "UNITS custom requires FIELD_UNITS")
+		}
+	default:
+		report.Errors = append(report.Errors, fmt.Sprintf("UNITS %q is not one of metric, imperial, custom", c.Units))
+	}
+
+	for field, bound := range c.Field_Bounds {
+		switch bound.Policy {
+		case "", BoundClamp, BoundDrop, BoundTag:
+		default:
+			report.Errors = append(report.Errors, fmt.Sprintf("policy %q of field bound %s is not one of clamp, drop, tag", bound.Policy, field))
+		}
+		if bound.Min != nil && bound.Max != nil && *bound.Min > *bound.Max {
+			report.Errors = append(report.Errors, fmt.Sprintf("field bound %s has min above max", field))
+		}
+	}
+
+	for reportType, mapping := range c.Field_Mappings {
+		names := make(map[string]bool, len(mapping))
+		for i, f := range mapping {
+			if f.Name == "" {
+				continue
+			}
+			if names[f.Name] {
+				report.Errors = append(report.Errors, fmt.Sprintf("field mapping of %s names %s twice", reportType, f.Name))
+			}
+			names[f.Name] = true
+			if _, ok := meteo.ToMetric(f.Unit, 0); f.Unit != "" && !ok {
+				report.Errors = append(report.Errors, fmt.Sprintf("unit %q of %s (index %d) in the field mapping of %s is not one of f, inhg, mph, km/h, kn, in, mi", f.Unit, f.Name, i, reportType))
+			}
+		}
+		if !names["timestamp"] {
+			report.Errors = append(report.Errors, fmt.Sprintf("field mapping of %s has no timestamp", reportType))
+		}
+	}
+
+	if c.Capture_Rate < 0 {
+		report.Errors = append(report.Errors, "CAPTURE_RATE must not be negative")
+	}
+	if c.Capture_Dir != "" && c.Capture_File != "" {
+		report.Errors = append(report.Errors, "CAPTURE_DIR and CAPTURE_FILE cannot both be set")
+	}
+	if c.Capture_Max_Size_MB < 0 {
+		report.Errors = append(report.Errors, "CAPTURE_MAX_SIZE_MB must not be negative")
+	}
+
 	// Validate buffer size
 	if c.Buffer <= 0 {
-		validationErrors = append(validationErrors, "Buffer size must be greater than 0")
+		report.Errors = append(report.Errors, "Buffer size must be greater than 0")
+	} else if c.Buffer < MinRecommendedBuffer {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("Buffer size %d is below the recommended minimum of %d; larger packets will be truncated", c.Buffer, MinRecommendedBuffer))
+	}
+
+	// Validate timeouts
+	if c.Influx_Write_Timeout < 0 {
+		report.Errors = append(report.Errors, "INFLUX_WRITE_TIMEOUT must not be negative")
+	}
+	if c.Influx_Client_Timeout < 0 {
+		report.Errors = append(report.Errors, "INFLUX_CLIENT_TIMEOUT must not be negative")
+	}
+	if c.Influx_Rate_Limit_Max_Wait < 0 {
+		report.Errors = append(report.Errors, "INFLUX_RATE_LIMIT_MAX_WAIT must not be negative")
+	}
+	if c.Influx_Retry_Attempts < 0 || c.Influx_Retry_Backoff < 0 || c.Influx_Retry_Max_Backoff < 0 {
+		report.Errors = append(report.Errors, "INFLUX_RETRY_ATTEMPTS, INFLUX_RETRY_BACKOFF and INFLUX_RETRY_MAX_BACKOFF must not be negative")
+	}
+	if c.Influx_Max_Concurrent_Writes < 0 {
+		report.Errors = append(report.Errors, "INFLUX_MAX_CONCURRENT_WRITES must not be negative")
+	}
+	if c.Influx_Max_Idle_Conns < 0 || c.Influx_Max_Conns_Per_Host < 0 || c.Influx_Idle_Conn_Timeout < 0 {
+		report.Errors = append(report.Errors, "INFLUX_MAX_IDLE_CONNS, INFLUX_MAX_CONNS_PER_HOST and INFLUX_IDLE_CONN_TIMEOUT must not be negative")
+	}
+	if c.Influx_Max_Batch_Lines < 0 || c.Influx_Max_Batch_Bytes < 0 {
+		report.Errors = append(report.Errors, "INFLUX_MAX_BATCH_LINES and INFLUX_MAX_BATCH_BYTES must not be negative")
+	}
+	if c.Spool_Dir != "" {
+		if c.Spool_Max_Size_MB < 0 || c.Spool_Max_Age < 0 {
+			report.Errors = append(report.Errors, "SPOOL_MAX_SIZE_MB and SPOOL_MAX_AGE must not be negative")
+		}
+		if c.Spool_Retry_Interval <= 0 {
+			report.Errors = append(report.Errors, "SPOOL_RETRY_INTERVAL must be positive")
+		}
+		if c.Spool_Dir == c.Quarantine_Dir || c.Spool_Dir == c.Capture_Dir {
+			report.Errors = append(report.Errors, "SPOOL_DIR must differ from QUARANTINE_DIR and CAPTURE_DIR")
+		}
+	}
+	if c.Influx_Flush_Interval < 0 || c.Influx_Flush_Size < 0 {
+		report.Errors = append(report.Errors, "INFLUX_FLUSH_INTERVAL and INFLUX_FLUSH_SIZE must not be negative")
+	}
+	if c.Influx_Write_Timeout > 0 && c.Influx_Client_Timeout > 0 && c.Influx_Write_Timeout > c.Influx_Client_Timeout {
+		report.Warnings = append(report.Warnings, "INFLUX_WRITE_TIMEOUT exceeds INFLUX_CLIENT_TIMEOUT; the client timeout will cancel writes first")
+	}
+
+	if c.Rapid_Wind && c.Influx_Bucket_Rapid_Wind == "" {
+		report.Warnings = append(report.Warnings, "RAPID_WIND is enabled without INFLUX_BUCKET_RAPID_WIND; rapid wind reports will share INFLUX_BUCKET")
+	}
+	for _, t := range c.Report_Types {
+		if slices.Contains(c.Exclude_Report_Types, t) {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("report type %q is in both REPORT_TYPES and EXCLUDE_REPORT_TYPES; it is excluded", t))
+		}
+	}
+	if c.Rapid_Wind && !c.ReportTypeForwarded("rapid_wind") {
+		report.Warnings = append(report.Warnings, "RAPID_WIND has no effect because rapid_wind is filtered by REPORT_TYPES or EXCLUDE_REPORT_TYPES")
+	}
+	if c.Lightning && !c.ReportTypeForwarded("evt_strike") {
+		report.Warnings = append(report.Warnings, "LIGHTNING has no effect because evt_strike is filtered by REPORT_TYPES or EXCLUDE_REPORT_TYPES")
+	}
+
+	return report
+}
+
+// Validate validates the configuration and returns an error if invalid. In
+// strict mode warnings are treated as errors.
+func (c *Config) Validate() error {
+	report := c.Check()
+
+	validationErrors := report.Errors
+	if c.Strict {
+		validationErrors = append(validationErrors, report.Warnings...)
 	}
 
 	if len(validationErrors) > 0 {
@@ -91,62 +1379,306 @@ func (c *Config) Validate() error {
 	return nil
 }
 
-// Load loads configuration from file, environment variables, and command line flags
-func Load(path string, name string) *Config {
-	config_file := name + ".yml"
+// isLoopbackHost reports whether host refers to the local machine
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Loader loads configuration from a config file, environment variables and
+// command line flags. Each Loader owns its viper instance and flag set, so
+// configuration can be loaded any number of times within one process.
+type Loader struct {
+	path  string
+	name  string
+	args  []string
+	viper *viper.Viper
+	flags *flag.FlagSet
+
+	// remoteKeys are the settings provided by the remote configuration
+	// and not by the local file
+	remoteKeys map[string]bool
+}
+
+// NewLoader creates a Loader reading <name>.yml from path and parsing args
+// (typically os.Args[1:]) as command line flags
+func NewLoader(path string, name string, args []string) *Loader {
+	l := &Loader{
+		path:  path,
+		name:  name,
+		args:  args,
+		viper: viper.New(),
+		flags: flag.NewFlagSet(name, flag.ContinueOnError),
+	}
+	l.registerFlags()
+	return l
+}
+
+// registerFlags declares all command line flags on the loader's flag set
+func (l *Loader) registerFlags() {
+	l.flags.String("listen_address", "", "Address to listen for UDP Broadcasts")
+	l.flags.String("influx_url", "", "InfluxDB base URL (without /api/v2/write)")
+	l.flags.String("influx_api_path", "", "InfluxDB API path, or auto to detect it (default: /api/v2/write)")
+	l.flags.String("influx_org", "", "InfluxDB organization name")
+	l.flags.String("influx_token", "", "Authentication token for Influx")
+	l.flags.String("influx_version", "", "InfluxDB write API: 1 for /write with database and retention policy, 2 for /api/v2/write (default: from influx_api_path)")
+	l.flags.String("influx_username", "", "InfluxDB 1.x username")
+	l.flags.String("influx_password", "", "InfluxDB 1.x password")
+	l.flags.String("influx_retention_policy", "", "InfluxDB 1.x retention policy to write to (default: the database default)")
+	l.flags.String("influx_bucket", "", "InfluxDB bucket name")
+	l.flags.String("influx_bucket_rapid_wind", "", "InfluxDB bucket name for rapid wind reports")
+	l.flags.Duration("influx_write_timeout", 0, "Timeout for a single InfluxDB write request (default: 5s)")
+	l.flags.Duration("influx_client_timeout", 0, "Overall HTTP client timeout for InfluxDB requests (default: 10s)")
+	l.flags.Duration("influx_rate_limit_max_wait", 0, "Longest total wait on InfluxDB rate limiting per write (default: 5m)")
+	l.flags.Int("influx_max_concurrent_writes", 0, "Maximum in-flight write requests per InfluxDB target (default: 4)")
+	l.flags.Int("influx_retry_attempts", 0, "How often a write failing with a server error, timeout or connection error is repeated (default: 3)")
+	l.flags.Duration("influx_retry_backoff", 0, "Pause before the first repeat of a failed write, doubled for every further repeat (default: 1s)")
+	l.flags.Duration("influx_retry_max_backoff", 0, "Longest pause between repeats of a failed write (default: 30s)")
+	l.flags.Int("influx_max_idle_conns", 0, "Maximum idle connections kept open to InfluxDB (default: 100)")
+	l.flags.Int("influx_max_conns_per_host", 0, "Maximum connections per InfluxDB host (default: 10)")
+	l.flags.Duration("influx_idle_conn_timeout", 0, "How long an idle connection to InfluxDB is kept open (default: 90s)")
+	l.flags.Bool("influx_http2", false, "Attempt HTTP/2 for InfluxDB requests over TLS")
+	l.flags.String("influx_tls_ca_file", "", "PEM file of CAs trusted for InfluxDB in addition to the system ones")
+	l.flags.String("influx_tls_cert_file", "", "PEM client certificate for InfluxDB (mutual TLS)")
+	l.flags.String("influx_tls_key_file", "", "PEM key of the InfluxDB client certificate")
+	l.flags.Bool("influx_tls_insecure_skip_verify", false, "Do not verify the InfluxDB server certificate")
+	l.flags.Int("influx_max_batch_lines", 0, "Maximum lines per InfluxDB write request (default: 5000)")
+	l.flags.Int("influx_max_batch_bytes", 0, "Maximum body size in bytes per InfluxDB write request (default: 10485760)")
+	l.flags.Duration("influx_flush_interval", 0, "Buffer points and write them to InfluxDB at this interval (0 writes every packet immediately)")
+	l.flags.Int("influx_flush_size", 0, "Buffered points that trigger a write before the flush interval has passed (default: 1000)")
+	l.flags.Bool("influx_gzip", false, "Compress InfluxDB write requests with gzip")
+	l.flags.Bool("influx_integer_fields", false, "Write counts and codes such as strike_count as integers (for new buckets)")
+	l.flags.String("http_listen_address", "", "Address for the HTTP server exposing /metrics (disabled when empty)")
+	l.flags.Bool("http_expvar", false, "Serve pipeline state with expvar at /debug/vars")
+	l.flags.Bool("http_health", false, "Serve health probes at /healthz and /readyz")
+	l.flags.Duration("health_max_silence", 0, "Report not ready after this long without packets (disabled when 0)")
+	l.flags.String("http_tls_cert", "", "PEM certificate for serving the HTTP endpoints over TLS")
+	l.flags.String("http_tls_key", "", "PEM private key for http_tls_cert")
+	l.flags.StringSlice("http_cors_origins", nil, "Origins allowed to call the HTTP endpoints from a browser (\"*\" for any)")
+	l.flags.String("api_token", "", "Bearer token required by the HTTP and gRPC endpoints")
+	l.flags.String("api_username", "", "Basic auth username required by the HTTP and gRPC endpoints")
+	l.flags.String("api_password", "", "Basic auth password for api_username")
+	l.flags.Bool("http_tls_self_signed", false, "Serve the HTTP endpoints over TLS with a certificate generated at startup")
+	l.flags.String("grpc_listen_address", "", "Address for the gRPC observation stream (disabled when empty)")
+	l.flags.Duration("summary_interval", 0, "Write avg/min/max summary points for every interval of this length (disabled when 0)")
+	l.flags.String("summary_bucket", "", "InfluxDB bucket for summary points (default: influx_bucket)")
+	l.flags.Duration("watchdog_interval", DefaultWatchdogInterval, "How often the watchdog checks resource usage")
+	l.flags.Int("watchdog_max_goroutines", 0, "Goroutine count the watchdog warns about (disabled when 0)")
+	l.flags.Int("watchdog_max_heap_mb", 0, "Heap size in MiB the watchdog warns about (disabled when 0)")
+	l.flags.Int("watchdog_max_queue", 0, "Packets waiting for processing the watchdog warns about (disabled when 0)")
+	l.flags.Bool("watchdog_restart", false, "Restart the pipeline when a watchdog limit stays exceeded")
+	l.flags.String("weatherflow_token", "", "WeatherFlow personal access token for cloud API features")
+	l.flags.Bool("nearcast_rain", false, "Write Rain Check corrected daily rain fetched from the WeatherFlow cloud")
+	l.flags.Duration("nearcast_interval", DefaultNearcastInterval, "How often corrected rain is checked for")
+	l.flags.Bool("forecast", false, "Write the WeatherFlow station forecast to the forecast measurement")
+	l.flags.Duration("forecast_interval", DefaultForecastInterval, "How often the forecast is fetched")
+	l.flags.Bool("lifecycle_events", false, "Write start, stop and restart events of the service to the service measurement")
+	l.flags.Bool("graceful_upgrade", false, "Hand the listening sockets over to a new process of the binary on SIGUSR2")
+	l.flags.Duration("poll_fallback_after", 0, "Poll observations from the WeatherFlow cloud after this long without packets (disabled when 0)")
+	l.flags.Bool("station_metadata", false, "Fetch missing coordinates, elevation and time zone of stations from the WeatherFlow cloud at startup")
+	l.flags.String("official_station", "", "ICAO identifier of a METAR station to compare observations with (disabled when empty)")
+	l.flags.Duration("official_interval", DefaultOfficialInterval, "How often official observations are fetched")
+	l.flags.String("ntp_server", "", "NTP server used to check the host clock (disabled when empty)")
+	l.flags.Duration("ntp_interval", DefaultNtpInterval, "How often the host clock is checked")
+	l.flags.Duration("ntp_max_offset", DefaultNtpMaxOffset, "Host clock offset that is logged as a warning")
+	l.flags.Bool("ntp_correct", false, "Correct timestamps taken from the host clock by the offset measured with ntp_server")
+	l.flags.String("capture_dir", "", "Directory for raw captures of rejected packets (disabled when empty)")
+	l.flags.Int("capture_rate", 0, "Maximum rejected packets captured per minute (default: 10)")
+	l.flags.String("capture_file", "", "Dead-letter file appended with rejected packets as JSON lines (disabled when empty)")
+	l.flags.Int("capture_max_size_mb", 0, "Size at which the dead-letter file is rotated (default: 10)")
+	l.flags.String("quarantine_dir", "", "Directory for points rejected by every output (disabled when empty)")
+	l.flags.String("spool_dir", "", "Directory keeping points while InfluxDB is unavailable (disabled when empty)")
+	l.flags.Int("spool_max_size_mb", 0, "Maximum size of the spool in MB; the oldest points are dropped beyond it (default: 100, 0 is unlimited)")
+	l.flags.Duration("spool_max_age", 0, "Spooled points older than this are dropped (default: 168h, 0 keeps them)")
+	l.flags.Duration("spool_retry_interval", 0, "How often spooled points are written again (default: 30s)")
+	l.flags.String("remote_config_url", "", "Load configuration from an http(s)://, consul:// or etcd:// URL")
+	l.flags.String("remote_config_token", "", "Token for the remote configuration")
+	l.flags.Duration("remote_config_interval", DefaultRemoteConfigInterval, "How often the remote configuration is checked for changes (0 disables)")
+	l.flags.Duration("retention_max_age", 0, "Remove captured and quarantined files older than this (disabled when 0)")
+	l.flags.Int("retention_max_size_mb", 0, "Remove the oldest files once a local directory exceeds this size in MiB (disabled when 0)")
+	l.flags.Duration("retention_interval", DefaultRetentionInterval, "How often old local files are removed")
+	l.flags.String("state_dir", "", "Directory for state kept across restarts, such as the station registry (disabled when empty)")
+	l.flags.String("input", DefaultInput, "Packet source: udp, mqtt, stdin or websocket")
+	l.flags.String("mqtt_broker", "", "MQTT broker URL (e.g. tcp://broker:1883)")
+	l.flags.String("mqtt_topic", "", "MQTT topic carrying Tempest UDP packets")
+	l.flags.String("mqtt_client_id", DefaultMqttClientID, "MQTT client ID")
+	l.flags.String("mqtt_username", "", "MQTT username")
+	l.flags.String("mqtt_password", "", "MQTT password")
+	l.flags.Int("mqtt_qos", 0, "MQTT QoS (0-2) for subscribing and publishing")
+	l.flags.String("mqtt_publish_topic", "", "Publish parsed observations below this MQTT topic (disabled when empty)")
+	l.flags.String("mqtt_encoding", EncodingJSON, "Encoding of published MQTT messages: json, protobuf or avro")
+	l.flags.Bool("mqtt_ha_discovery", false, "Announce published sensors to Home Assistant through MQTT discovery")
+	l.flags.String("mqtt_ha_discovery_prefix", DefaultHADiscoveryPrefix, "Home Assistant MQTT discovery topic prefix")
+	l.flags.String("schema_registry_url", "", "Confluent-compatible Schema Registry URL for the avro encoding")
+	l.flags.String("schema_registry_subject", DefaultSchemaRegistrySubject, "Schema Registry subject for the Avro schema")
+	l.flags.String("schema_registry_username", "", "Schema Registry username")
+	l.flags.String("schema_registry_password", "", "Schema Registry password")
+	l.flags.String("telegraf_address", "", "Send line protocol to a Telegraf socket_listener at udp://host:port or tcp://host:port")
+	l.flags.String("zabbix_server", "", "Send fields to this Zabbix server or proxy as trapper items (disabled when empty)")
+	l.flags.String("zabbix_key_prefix", DefaultZabbixKeyPrefix, "Prefix of the Zabbix item keys")
+	l.flags.String("archive_s3_url", "", "S3 endpoint for the archive, e.g. https://s3.eu-west-1.amazonaws.com")
+	l.flags.String("archive_s3_region", "", "S3 region for request signing (default: us-east-1)")
+	l.flags.String("archive_s3_bucket", "", "S3 bucket to archive hourly objects to (disabled when empty)")
+	l.flags.String("archive_s3_prefix", "", "Key prefix of archive objects")
+	l.flags.String("archive_s3_access_key", "", "S3 access key ID")
+	l.flags.String("archive_s3_secret_key", "", "S3 secret access key")
+	l.flags.Bool("archive_raw", false, "Archive raw packets instead of line protocol")
+	l.flags.String("pushgateway_url", "", "Push the latest values to this Prometheus Pushgateway (disabled when empty)")
+	l.flags.String("pushgateway_job", DefaultPushgatewayJob, "Job label of pushed metrics")
+	l.flags.String("pushgateway_instance", "", "Instance label of pushed metrics (default: host name)")
+	l.flags.String("webhook_url", "", "POST observations to this URL (disabled when empty)")
+	l.flags.String("webhook_template", "", "Go template for the webhook request body (default: JSON observation)")
+	l.flags.String("webhook_username", "", "Webhook basic auth username")
+	l.flags.String("webhook_password", "", "Webhook basic auth password")
+	l.flags.StringSlice("webhook_report_types", []string{"obs_st"}, "Report types posted to the webhook (all when empty)")
+	l.flags.Bool("webhook_batch", false, "Post all points of a packet in one webhook request")
+	l.flags.StringSlice("relay_to", nil, "Re-broadcast raw datagrams to these host:port destinations")
+	l.flags.Int("buffer", 0, "Max buffer size for the socket io")
+	l.flags.BoolP("verbose", "v", false, "Verbose logging")
+	l.flags.BoolP("debug", "d", false, "Debug logging")
+	l.flags.Bool("raw_udp", false, "Show raw UDP packet data in hex format")
+	l.flags.BoolP("noop", "n", false, "Don't post to influx")
+	l.flags.Bool("rapid_wind", false, "Send rapid wind reports")
+	l.flags.StringSlice("report_types", nil, "Report types written (all when empty)")
+	l.flags.StringSlice("exclude_report_types", nil, "Report types never written")
+	l.flags.Int("rapid_wind_every", 0, "Forward only every Nth rapid wind report per station (disabled when 0 or 1)")
+	l.flags.Duration("rapid_wind_interval", 0, "Aggregate rapid wind reports into one min/avg/max point per interval (disabled when 0)")
+	l.flags.Bool("rapid_wind_subsecond", false, "Offset rapid wind timestamps by a per-device sub-second amount")
+	l.flags.Bool("strict", false, "Treat configuration warnings as errors")
+	l.flags.String("timezone", "", "IANA time zone for daily boundaries of stations without their own (default: UTC)")
+	l.flags.Bool("daily_stats", false, "Add since-midnight rain, temperature extremes and degree days to obs_st points")
+	l.flags.Bool("et0", false, "Add FAO-56 reference evapotranspiration fields for stations with coordinates")
+	l.flags.Bool("rain_rate", false, "Add the rain rate and the rain of the last hour to obs_st points")
+	l.flags.Float64("wind_smoothing_alpha", 0, "Add EWMA-smoothed wind speed fields with this smoothing factor (disabled when 0)")
+	l.flags.Bool("lightning", false, "Write lightning strike events to the lightning measurement")
+	l.flags.Float64("lightning_min_energy", 0, "Drop strikes with a lower energy")
+	l.flags.Float64("lightning_min_distance", 0, "Drop strikes closer than this many km")
+	l.flags.Int("lightning_min_strikes", 0, "Only write strikes once this many occurred within lightning_window")
+	l.flags.Duration("lightning_window", DefaultLightningWindow, "Window for lightning_min_strikes")
+	l.flags.Float64("lightning_alert_distance", 0, "Alert on strikes within this distance, in the unit of strike_distance (disabled when 0)")
+	l.flags.Duration("lightning_alert_cooldown", DefaultLightningAlertCooldown, "Minimum time between lightning alerts of a station")
+	l.flags.StringSlice("lightning_alert_channels", nil, "Alert channels lightning alerts are sent to (all when empty)")
+	l.flags.Int("pressure_filter_size", 0, "Replace pressure with the median of this many recent readings (disabled when 0)")
+	l.flags.String("units", UnitsMetric, "Units fields are written in: metric, imperial or custom (with field_units)")
+	l.flags.String("timestamp_collisions", "", "Keep different points of a series and timestamp apart with a seq tag (tag) or a nanosecond offset (offset)")
+	l.flags.Bool("dedupe_hubs", false, "Tag points with the delivering hub and drop copies received via another hub")
+	l.flags.Bool("conditions_summary", false, "Add a short text summary of the conditions to obs_st points")
+	l.flags.Bool("humidex", false, "Add the Canadian humidex to obs_st points")
+	l.flags.Bool("pressure_trend", false, "Add the 3-hour pressure change and a rising/steady/falling tag to points with a station pressure")
+	l.flags.Bool("sea_level_pressure", false, "Add the sea-level pressure to points with a station pressure")
+	l.flags.Float64("station_elevation", 0, "Elevation in meters of stations without one in stations")
+	l.flags.Bool("feels_like", false, "Add the NWS heat index, wind chill and feels-like temperature to obs_st points")
+	l.flags.Bool("cloud_base", false, "Add an estimated cloud_base_height field to obs_st points from the dew point spread")
+	l.flags.Bool("snow_likely", false, "Add an is_snow_likely field to obs_st points from precipitation, temperature and dew point")
+	l.flags.Bool("extra_obs_fields", false, "Store obs_st values beyond the known 18 as obs_<index> fields")
+	l.flags.Bool("raw_unknown_types", false, "Store report types without a known layout as raw_tempest points instead of dropping them")
+	l.flags.Bool("strict_schema", false, "Reject packets that do not match the documented schema of their report type")
+}
+
+// FlagSet returns the flag set used by the loader
+func (l *Loader) FlagSet() *flag.FlagSet {
+	return l.flags
+}
+
+// Load merges defaults, config file, environment variables and flags into a
+// validated Config
+func (l *Loader) Load() (*Config, error) {
+	v := l.viper
 
 	// Set defaults
-	viper.SetDefault("Listen_Address", DefaultListenAddress)
-	viper.SetDefault("Influx_URL", DefaultInfluxURL)
-	viper.SetDefault("Influx_API_Path", DefaultInfluxAPIPath)
-	viper.SetDefault("Buffer", DefaultBuffer)
-
-	flag.String("listen_address", "", "Address to listen for UDP Broadcasts")
-	flag.String("influx_url", "", "InfluxDB base URL (without /api/v2/write)")
-	flag.String("influx_api_path", "", "InfluxDB API path (default: /api/v2/write)")
-	flag.String("influx_org", "", "InfluxDB organization name")
-	flag.String("influx_token", "", "Authentication token for Influx")
-	flag.String("influx_bucket", "", "InfluxDB bucket name")
-	flag.String("influx_bucket_rapid_wind", "", "InfluxDB bucket name for rapid wind reports")
-	flag.Int("buffer", 0, "Max buffer size for the socket io")
-	flag.BoolP("verbose", "v", false, "Verbose logging")
-	flag.BoolP("debug", "d", false, "Debug logging")
-	flag.Bool("raw_udp", false, "Show raw UDP packet data in hex format")
-	flag.BoolP("noop", "n", false, "Don't post to influx")
-	flag.Bool("rapid_wind", false, "Send rapid wind reports")
-
-	viper.AddConfigPath(path)
-
-	viper.SetConfigName(config_file)
-	viper.SetConfigType("yaml")
+	v.SetDefault("Input", DefaultInput)
+	v.SetDefault("Listen_Address", DefaultListenAddress)
+	v.SetDefault("Mqtt_Client_ID", DefaultMqttClientID)
+	v.SetDefault("Mqtt_Encoding", EncodingJSON)
+	v.SetDefault("Units", UnitsMetric)
+	v.SetDefault("Mqtt_HA_Discovery_Prefix", DefaultHADiscoveryPrefix)
+	v.SetDefault("Schema_Registry_Subject", DefaultSchemaRegistrySubject)
+	v.SetDefault("Influx_URL", DefaultInfluxURL)
+	v.SetDefault("Influx_API_Path", DefaultInfluxAPIPath)
+	v.SetDefault("Buffer", DefaultBuffer)
+	v.SetDefault("Influx_Write_Timeout", DefaultWriteTimeout)
+	v.SetDefault("Influx_Client_Timeout", time.Duration(DefaultTimeout)*time.Second)
+	v.SetDefault("Influx_Rate_Limit_Max_Wait", DefaultRateLimitMaxWait)
+	v.SetDefault("Influx_Max_Concurrent_Writes", DefaultMaxConcurrentWrites)
+	v.SetDefault("Influx_Retry_Attempts", DefaultRetryAttempts)
+	v.SetDefault("Influx_Retry_Backoff", DefaultRetryBackoff)
+	v.SetDefault("Influx_Retry_Max_Backoff", DefaultRetryMaxBackoff)
+	v.SetDefault("Influx_Max_Batch_Lines", DefaultMaxBatchLines)
+	v.SetDefault("Influx_Max_Idle_Conns", HTTPMaxIdleConns)
+	v.SetDefault("Influx_Max_Conns_Per_Host", HTTPMaxConnsPerHost)
+	v.SetDefault("Influx_Idle_Conn_Timeout", HTTPIdleConnTimeout*time.Second)
+	v.SetDefault("Influx_Max_Batch_Bytes", DefaultMaxBatchBytes)
+	v.SetDefault("Influx_Flush_Size", DefaultFlushSize)
+	v.SetDefault("Capture_Rate", DefaultCaptureRate)
+	v.SetDefault("Capture_Max_Size_MB", DefaultCaptureMaxSizeMB)
+	v.SetDefault("Spool_Max_Size_MB", DefaultSpoolMaxSizeMB)
+	v.SetDefault("Spool_Max_Age", DefaultSpoolMaxAge)
+	v.SetDefault("Spool_Retry_Interval", DefaultSpoolRetryInterval)
+	v.SetDefault("Watchdog_Interval", DefaultWatchdogInterval)
+	v.SetDefault("Ntp_Interval", DefaultNtpInterval)
+	v.SetDefault("Nearcast_Interval", DefaultNearcastInterval)
+	v.SetDefault("Lightning_Window", DefaultLightningWindow)
+	v.SetDefault("Lightning_Alert_Cooldown", DefaultLightningAlertCooldown)
+	v.SetDefault("Zabbix_Key_Prefix", DefaultZabbixKeyPrefix)
+	v.SetDefault("Pushgateway_Job", DefaultPushgatewayJob)
+	v.SetDefault("Forecast_Interval", DefaultForecastInterval)
+	v.SetDefault("Official_Interval", DefaultOfficialInterval)
+	v.SetDefault("Retention_Interval", DefaultRetentionInterval)
+	v.SetDefault("Ntp_Max_Offset", DefaultNtpMaxOffset)
+	v.SetDefault("Timezone", DefaultTimezone)
+	v.SetDefault("Remote_Config_Interval", DefaultRemoteConfigInterval)
+
+	v.AddConfigPath(l.path)
+	v.SetConfigName(l.name + ".yml")
+	v.SetConfigType("yaml")
 
 	// Removed env prefix so INFLUX_TOKEN and INFLUX_BUCKET are read directly
-	viper.AutomaticEnv()
+	v.AutomaticEnv()
 
-	flag.Parse()
-	viper.BindPFlags(flag.CommandLine)
-	if viper.GetBool("debug") {
-		viper.Set("verbose", true)
+	if err := l.flags.Parse(l.args); err != nil {
+		return nil, err
+	}
+	if err := v.BindPFlags(l.flags); err != nil {
+		return nil, fmt.Errorf("binding flags: %w", err)
+	}
+	if v.GetBool("debug") {
+		v.Set("verbose", true)
 	}
 
-	err := viper.ReadInConfig()
-	if err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-		} else {
-			log.Fatalf("%v", err)
+	if err := v.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return nil, err
 		}
 	}
 
-	var config *Config
-	err = viper.Unmarshal(&config)
+	remote, err := l.readRemote()
 	if err != nil {
-		log.Fatalf("Failed to unmarshal config: %v", err)
+		return nil, err
+	}
+
+	var config *Config
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	config.remote = remote
+
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
 
-	// Debug print to help diagnose missing env vars
-	fmt.Printf("DEBUG: INFLUX_TOKEN=\"%s\" INFLUX_BUCKET=\"%s\"\n", config.Influx_Token, config.Influx_Bucket)
-	// Validate configuration using Lo library patterns
-	lo.Must0(config.Validate())
+	return config, nil
+}
+
+// Load loads configuration from file, environment variables and the process
+// command line flags, exiting on any error
+func Load(path string, name string) *Config {
+	config, err := NewLoader(path, name, os.Args[1:]).Load()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
 
 	return config
 }
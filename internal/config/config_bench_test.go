@@ -1,25 +1,23 @@
 package config
 
 import (
-	"os"
 	"testing"
 )
 
 // Benchmark tests for configuration loading and validation
 func BenchmarkLoadConfig(b *testing.B) {
-	// Set up environment variables for consistent testing
-	os.Setenv("TEMPEST_INFLUX_INFLUX_URL", "http://localhost:8086/api/v2/write")
-	os.Setenv("TEMPEST_INFLUX_INFLUX_TOKEN", "test-token")
-	os.Setenv("TEMPEST_INFLUX_INFLUX_BUCKET", "test-bucket")
-	defer func() {
-		os.Unsetenv("TEMPEST_INFLUX_INFLUX_URL")
-		os.Unsetenv("TEMPEST_INFLUX_INFLUX_TOKEN")
-		os.Unsetenv("TEMPEST_INFLUX_INFLUX_BUCKET")
-	}()
+	args := []string{
+		"--influx_url", "http://localhost:8086",
+		"--influx_org", "test-org",
+		"--influx_token", "test-token",
+		"--influx_bucket", "test-bucket",
+	}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Load("/tmp", "tempest_influx")
+		if _, err := NewLoader("/tmp", "tempest_influx", args).Load(); err != nil {
+			b.Fatal(err)
+		}
 	}
 }
 
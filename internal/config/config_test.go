@@ -1,7 +1,10 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 // Test configuration validation
@@ -45,6 +48,23 @@ func TestConfigValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "lightning alert to unknown channel",
+			config: &Config{
+				Influx_URL:               "http://localhost:8086",
+				Influx_API_Path:          "/api/v2/write",
+				Influx_Org:               "test-org",
+				Influx_Token:             "test-token",
+				Influx_Bucket:            "test-bucket",
+				Listen_Address:           ":50222",
+				Buffer:                   1024,
+				Lightning:                true,
+				Lightning_Alert_Distance: 10,
+				Lightning_Alert_Channels: []string{"pager"},
+				Alert_Channels:           []AlertChannel{{Name: "phone", Type: AlertNtfy, URL: "https://ntfy.sh/storms"}},
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid buffer size",
 			config: &Config{
@@ -56,6 +76,219 @@ func TestConfigValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "relay destination without port",
+			config: &Config{
+				Influx_URL:     "http://localhost:8086",
+				Influx_Org:     "test-org",
+				Influx_Token:   "test-token",
+				Influx_Bucket:  "test-bucket",
+				Listen_Address: ":50222",
+				Buffer:         1024,
+				Relay_To:       []string{"192.168.20.255"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid relay type pattern",
+			config: &Config{
+				Influx_URL:     "http://localhost:8086",
+				Influx_Org:     "test-org",
+				Influx_Token:   "test-token",
+				Influx_Bucket:  "test-bucket",
+				Listen_Address: ":50222",
+				Buffer:         1024,
+				Relay:          []RelayRule{{Destination: "10.0.0.5:50222", Types: []string{"evt_["}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid station timezone",
+			config: &Config{
+				Influx_URL:     "http://localhost:8086",
+				Influx_Org:     "test-org",
+				Influx_Token:   "test-token",
+				Influx_Bucket:  "test-bucket",
+				Listen_Address: ":50222",
+				Buffer:         1024,
+				Stations:       map[string]Station{"ST-00012345": {Timezone: "Mars/Olympus_Mons"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown field bound policy",
+			config: &Config{
+				Influx_URL:     "http://localhost:8086",
+				Influx_Org:     "test-org",
+				Influx_Token:   "test-token",
+				Influx_Bucket:  "test-bucket",
+				Listen_Address: ":50222",
+				Buffer:         1024,
+				Field_Bounds:   map[string]Bound{"temp": {Policy: "ignore"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative calibration multiplier",
+			config: &Config{
+				Influx_URL:     "http://localhost:8086",
+				Influx_Org:     "test-org",
+				Influx_Token:   "test-token",
+				Influx_Bucket:  "test-bucket",
+				Listen_Address: ":50222",
+				Buffer:         1024,
+				Stations:       map[string]Station{"ST-00012345": {Calibration: map[string]Calibration{"wind": {Multiplier: -1}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "wind smoothing alpha above 1",
+			config: &Config{
+				Influx_URL:           "http://localhost:8086",
+				Influx_Org:           "test-org",
+				Influx_Token:         "test-token",
+				Influx_Bucket:        "test-bucket",
+				Listen_Address:       ":50222",
+				Buffer:               1024,
+				Wind_Smoothing_Alpha: 1.5,
+			},
+			wantErr: true,
+		},
+		{
+			name: "lightning strike count without window",
+			config: &Config{
+				Influx_URL:            "http://localhost:8086",
+				Influx_Org:            "test-org",
+				Influx_Token:          "test-token",
+				Influx_Bucket:         "test-bucket",
+				Listen_Address:        ":50222",
+				Buffer:                1024,
+				Lightning:             true,
+				Lightning_Min_Strikes: 3,
+			},
+			wantErr: true,
+		},
+		{
+			name: "field mapping without timestamp",
+			config: &Config{
+				Influx_URL:     "http://localhost:8086",
+				Influx_Org:     "test-org",
+				Influx_Token:   "test-token",
+				Influx_Bucket:  "test-bucket",
+				Listen_Address: ":50222",
+				Buffer:         1024,
+				Field_Mappings: map[string][]FieldMapping{"obs_air": {{Name: "p"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "field mapping with unknown unit",
+			config: &Config{
+				Influx_URL:     "http://localhost:8086",
+				Influx_Org:     "test-org",
+				Influx_Token:   "test-token",
+				Influx_Bucket:  "test-bucket",
+				Listen_Address: ":50222",
+				Buffer:         1024,
+				Field_Mappings: map[string][]FieldMapping{"obs_air": {{Name: "timestamp"}, {Name: "temp", Unit: "kelvin"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "home assistant discovery with protobuf encoding",
+			config: &Config{
+				Influx_URL:         "http://localhost:8086",
+				Influx_Org:         "test-org",
+				Influx_Token:       "test-token",
+				Influx_Bucket:      "test-bucket",
+				Listen_Address:     ":50222",
+				Buffer:             1024,
+				Mqtt_Publish_Topic: "tempest",
+				Mqtt_Encoding:      EncodingProtobuf,
+				Mqtt_HA_Discovery:  true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "custom units without field units",
+			config: &Config{
+				Influx_URL:     "http://localhost:8086",
+				Influx_Org:     "test-org",
+				Influx_Token:   "test-token",
+				Influx_Bucket:  "test-bucket",
+				Listen_Address: ":50222",
+				Buffer:         1024,
+				Units:          UnitsCustom,
+			},
+			wantErr: true,
+		},
+		{
+			name: "influxdb 1.x without org and token",
+			config: &Config{
+				Influx_URL:      "http://localhost:8086",
+				Influx_Version:  InfluxVersion1,
+				Influx_Username: "collector",
+				Influx_Password: "secret",
+				Influx_Bucket:   "weather",
+				Listen_Address:  ":50222",
+				Buffer:          1024,
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown influxdb version",
+			config: &Config{
+				Influx_URL:     "http://localhost:8086",
+				Influx_Version: "3",
+				Influx_Bucket:  "weather",
+				Listen_Address: ":50222",
+				Buffer:         1024,
+			},
+			wantErr: true,
+		},
+		{
+			name: "mirror without token",
+			config: &Config{
+				Influx_URL:     "http://localhost:8086",
+				Influx_Org:     "test-org",
+				Influx_Token:   "test-token",
+				Influx_Bucket:  "test-bucket",
+				Listen_Address: ":50222",
+				Buffer:         1024,
+				Influx_Mirrors: []InfluxMirror{{URL: "https://cloud.example.com", Org: "cloud-org"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "mirror names used twice",
+			config: &Config{
+				Influx_URL:     "http://localhost:8086",
+				Influx_Org:     "test-org",
+				Influx_Token:   "test-token",
+				Influx_Bucket:  "test-bucket",
+				Listen_Address: ":50222",
+				Buffer:         1024,
+				Influx_Mirrors: []InfluxMirror{
+					{Name: "cloud", URL: "https://a.example.com", Version: InfluxVersion1},
+					{Name: "cloud", URL: "https://b.example.com", Version: InfluxVersion1},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "mirror route bucket empty",
+			config: &Config{
+				Influx_URL:     "http://localhost:8086",
+				Influx_Org:     "test-org",
+				Influx_Token:   "test-token",
+				Influx_Bucket:  "test-bucket",
+				Listen_Address: ":50222",
+				Buffer:         1024,
+				Influx_Routes:  []InfluxRoute{{Types: []string{"evt_strike"}, Bucket: "events"}},
+				Influx_Mirrors: []InfluxMirror{{URL: "https://a.example.com", Version: InfluxVersion1, Route_Buckets: map[string]string{"events": ""}}},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -67,3 +300,309 @@ func TestConfigValidation(t *testing.T) {
 		})
 	}
 }
+
+// Test that the loader can be used repeatedly without flag redefinition
+func TestLoaderRepeatedLoad(t *testing.T) {
+	args := []string{
+		"--influx_url", "http://localhost:8086",
+		"--influx_org", "test-org",
+		"--influx_token", "test-token",
+		"--influx_bucket", "test-bucket",
+	}
+
+	for i := 0; i < 3; i++ {
+		cfg, err := NewLoader(t.TempDir(), "tempest-influxdb", args).Load()
+		if err != nil {
+			t.Fatalf("Load() iteration %d error = %v", i, err)
+		}
+		if cfg.Influx_Bucket != "test-bucket" {
+			t.Errorf("Expected bucket test-bucket, got %s", cfg.Influx_Bucket)
+		}
+		if cfg.Listen_Address != DefaultListenAddress {
+			t.Errorf("Expected default listen address %s, got %s", DefaultListenAddress, cfg.Listen_Address)
+		}
+		if cfg.Influx_Write_Timeout != DefaultWriteTimeout {
+			t.Errorf("Expected default write timeout %v, got %v", DefaultWriteTimeout, cfg.Influx_Write_Timeout)
+		}
+	}
+}
+
+func TestLoaderSources(t *testing.T) {
+	dir := t.TempDir()
+	yaml := "influx_url: http://file:8086\ninflux_org: file-org\ninflux_token: file-token\ninflux_bucket: file-bucket\nbuffer: 2048\n"
+	if err := os.WriteFile(filepath.Join(dir, "tempest-influxdb.yml"), []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("INFLUX_ORG", "env-org")
+
+	cfg, err := NewLoader(dir, "tempest-influxdb", []string{"--influx_bucket", "flag-bucket", "-d"}).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Influx_URL != "http://file:8086" {
+		t.Errorf("Expected URL from file, got %s", cfg.Influx_URL)
+	}
+	if cfg.Influx_Org != "env-org" {
+		t.Errorf("Expected org from environment, got %s", cfg.Influx_Org)
+	}
+	if cfg.Influx_Bucket != "flag-bucket" {
+		t.Errorf("Expected bucket from flag, got %s", cfg.Influx_Bucket)
+	}
+	if cfg.Buffer != 2048 {
+		t.Errorf("Expected buffer 2048, got %d", cfg.Buffer)
+	}
+	if !cfg.Debug || !cfg.Verbose {
+		t.Error("Expected debug to imply verbose")
+	}
+}
+
+func TestLoaderRelayRules(t *testing.T) {
+	dir := t.TempDir()
+	yaml := `influx_org: org
+influx_token: token
+influx_bucket: bucket
+relay:
+  - destination: 10.0.0.5:50222
+    types: [obs_st, "evt_*"]
+  - destination: 127.0.0.1:50223
+`
+	if err := os.WriteFile(filepath.Join(dir, "tempest-influxdb.yml"), []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RELAY_TO", "192.168.20.255:50222,192.168.30.255:50222")
+
+	cfg, err := NewLoader(dir, "tempest-influxdb", nil).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	rules := cfg.RelayRules()
+	if len(rules) != 4 {
+		t.Fatalf("Expected 4 relay rules, got %+v", rules)
+	}
+	if rules[0].Destination != "192.168.20.255:50222" || len(rules[0].Types) != 0 {
+		t.Errorf("Expected unfiltered RELAY_TO rule first, got %+v", rules[0])
+	}
+	if rules[2].Destination != "10.0.0.5:50222" || len(rules[2].Types) != 2 || rules[2].Types[1] != "evt_*" {
+		t.Errorf("Unexpected file rule %+v", rules[2])
+	}
+}
+
+func TestLoaderStations(t *testing.T) {
+	dir := t.TempDir()
+	yaml := `influx_org: org
+influx_token: token
+influx_bucket: bucket
+timezone: Europe/Berlin
+station_elevation: 120
+official_station: KSEA
+stations:
+  ST-00012345:
+    timezone: America/Denver
+    official_station: KDEN
+    elevation: 1609
+`
+	if err := os.WriteFile(filepath.Join(dir, "tempest-influxdb.yml"), []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewLoader(dir, "tempest-influxdb", nil).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	loc, err := cfg.Location("ST-00012345")
+	if err != nil || loc.String() != "America/Denver" {
+		t.Errorf("Location(ST-00012345) = %v, %v; want America/Denver", loc, err)
+	}
+	loc, err = cfg.Location("ST-00099999")
+	if err != nil || loc.String() != "Europe/Berlin" {
+		t.Errorf("Location(ST-00099999) = %v, %v; want Europe/Berlin", loc, err)
+	}
+	if got := cfg.OfficialStation("ST-00012345"); got != "KDEN" {
+		t.Errorf("OfficialStation(ST-00012345) = %q, want KDEN", got)
+	}
+	if got := cfg.OfficialStation("ST-00099999"); got != "KSEA" {
+		t.Errorf("OfficialStation(ST-00099999) = %q, want KSEA", got)
+	}
+	if got := cfg.Elevation("ST-00012345"); got != 1609 {
+		t.Errorf("Elevation(ST-00012345) = %v, want 1609", got)
+	}
+	if got := cfg.Elevation("ST-00099999"); got != 120 {
+		t.Errorf("Elevation(ST-00099999) = %v, want 120", got)
+	}
+}
+
+func TestLoaderInfluxCredentials(t *testing.T) {
+	dir := t.TempDir()
+	yaml := `influx_org: org
+influx_token: token
+influx_bucket: bucket
+influx_credentials:
+  Rapid_Wind:
+    token: wind-token
+  Other:
+    org: other-org
+`
+	if err := os.WriteFile(filepath.Join(dir, "tempest-influxdb.yml"), []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewLoader(dir, "tempest-influxdb", nil).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct{ bucket, org, token string }{
+		{"bucket", "org", "token"},
+		{"Rapid_Wind", "org", "wind-token"},
+		{"Other", "other-org", "token"},
+	}
+	for _, tt := range tests {
+		if org, token := cfg.InfluxOrg(tt.bucket), cfg.InfluxToken(tt.bucket); org != tt.org || token != tt.token {
+			t.Errorf("credentials of %s = %s, %s; want %s, %s", tt.bucket, org, token, tt.org, tt.token)
+		}
+	}
+}
+
+func TestLoaderInfluxMirrors(t *testing.T) {
+	dir := t.TempDir()
+	yaml := `influx_org: org
+influx_token: token
+influx_bucket: weather
+influx_bucket_rapid_wind: wind
+influx_credentials:
+  weather:
+    token: weather-token
+influx_routes:
+  - types: [evt_strike]
+    bucket: events
+    org: ops
+influx_mirrors:
+  - name: cloud
+    url: https://cloud.example.com
+    org: cloud-org
+    token: cloud-token
+    bucket: tempest
+    route_buckets:
+      events: tempest-events
+  - url: http://10.0.0.9:8086
+    version: "1"
+    username: collector
+    password: secret
+    tls_insecure_skip_verify: true
+`
+	if err := os.WriteFile(filepath.Join(dir, "tempest-influxdb.yml"), []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewLoader(dir, "tempest-influxdb", nil).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.Influx_Mirrors) != 2 {
+		t.Fatalf("Expected 2 mirrors, got %+v", cfg.Influx_Mirrors)
+	}
+
+	cloud := cfg.ForMirror(0)
+	if cfg.MirrorName(0) != "cloud" || cloud.Influx_URL != "https://cloud.example.com" || cloud.Influx_API_Path != DefaultInfluxAPIPath {
+		t.Errorf("Unexpected mirror %s: %s%s", cfg.MirrorName(0), cloud.Influx_URL, cloud.Influx_API_Path)
+	}
+	if cloud.Influx_Bucket != "tempest" || cloud.Influx_Bucket_Rapid_Wind != "tempest" {
+		t.Errorf("Expected both buckets to be tempest, got %s and %s", cloud.Influx_Bucket, cloud.Influx_Bucket_Rapid_Wind)
+	}
+	if org, token := cloud.InfluxOrg("tempest"), cloud.InfluxToken("weather"); org != "cloud-org" || token != "cloud-token" {
+		t.Errorf("Expected the mirror's own credentials, got %s, %s", org, token)
+	}
+	if len(cloud.Influx_Routes) != 1 || cloud.Influx_Routes[0].Bucket != "tempest-events" || cloud.InfluxOrg("tempest-events") != "cloud-org" {
+		t.Errorf("Expected the route bucket renamed under the mirror org, got %+v", cloud.Influx_Routes)
+	}
+
+	local := cfg.ForMirror(1)
+	if cfg.MirrorName(1) != "2" || local.Influx_Version != InfluxVersion1 || local.Influx_Username != "collector" {
+		t.Errorf("Unexpected mirror %s: %+v", cfg.MirrorName(1), cfg.Influx_Mirrors[1])
+	}
+	if local.Influx_Bucket != "weather" || local.Influx_Bucket_Rapid_Wind != "wind" {
+		t.Errorf("Expected the main buckets, got %s and %s", local.Influx_Bucket, local.Influx_Bucket_Rapid_Wind)
+	}
+	if local.Influx_Routes[0].Bucket != "events" {
+		t.Errorf("Expected the main route bucket, got %s", local.Influx_Routes[0].Bucket)
+	}
+	if cfg.Influx_URL == local.Influx_URL || cfg.Influx_Token != "token" || cfg.Influx_Routes[0].Org != "ops" {
+		t.Error("Expected ForMirror to leave the main configuration alone")
+	}
+}
+
+func TestLoaderErrors(t *testing.T) {
+	if _, err := NewLoader(t.TempDir(), "tempest-influxdb", []string{"--no-such-flag"}).Load(); err == nil {
+		t.Error("Expected error for unknown flag")
+	}
+
+	if _, err := NewLoader(t.TempDir(), "tempest-influxdb", nil).Load(); err == nil {
+		t.Error("Expected validation error when required settings are missing")
+	}
+}
+
+func TestConfigCheckWarnings(t *testing.T) {
+	base := Config{
+		Influx_URL:     "https://influx.example.com",
+		Influx_Org:     "test-org",
+		Influx_Token:   "test-token",
+		Influx_Bucket:  "test-bucket",
+		Listen_Address: ":50222",
+		Buffer:         DefaultBuffer,
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(c *Config)
+		warnings int
+	}{
+		{"clean", func(c *Config) {}, 0},
+		{"insecure remote URL", func(c *Config) { c.Influx_URL = "http://influx.example.com" }, 1},
+		{"insecure loopback URL", func(c *Config) { c.Influx_URL = "http://127.0.0.1:8086" }, 0},
+		{"URL with pre-built query string", func(c *Config) {
+			c.Influx_URL = "https://influx.example.com/api/v2/write?org=test-org&bucket=test-bucket&precision=s"
+		}, 1},
+		{"mirror URL with pre-built query string", func(c *Config) {
+			c.Influx_Mirrors = []InfluxMirror{{URL: "https://cloud.example.com/api/v2/write?org=o&bucket=b", Org: "o", Token: "t"}}
+		}, 1},
+		{"tiny buffer", func(c *Config) { c.Buffer = 256 }, 1},
+		{"rapid wind without bucket", func(c *Config) { c.Rapid_Wind = true }, 1},
+		{"write timeout above client timeout", func(c *Config) {
+			c.Influx_Write_Timeout = 30 * time.Second
+			c.Influx_Client_Timeout = 10 * time.Second
+		}, 1},
+		{"lightning filter without lightning", func(c *Config) { c.Lightning_Min_Energy = 1000 }, 1},
+		{"rapid wind with bucket", func(c *Config) {
+			c.Rapid_Wind = true
+			c.Influx_Bucket_Rapid_Wind = "rapid"
+		}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := base
+			tt.modify(&c)
+
+			report := c.Check()
+			if len(report.Errors) != 0 {
+				t.Errorf("Unexpected errors: %v", report.Errors)
+			}
+			if len(report.Warnings) != tt.warnings {
+				t.Errorf("Expected %d warnings, got %v", tt.warnings, report.Warnings)
+			}
+
+			// Lenient mode never fails on warnings
+			if err := c.Validate(); err != nil {
+				t.Errorf("Validate() lenient error = %v", err)
+			}
+
+			c.Strict = true
+			if err := c.Validate(); (err != nil) != (tt.warnings > 0) {
+				t.Errorf("Validate() strict error = %v, want error %v", err, tt.warnings > 0)
+			}
+		})
+	}
+}
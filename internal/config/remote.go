@@ -0,0 +1,223 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// DefaultRemoteConfigInterval is how often the remote configuration is
+	// checked for changes
+	DefaultRemoteConfigInterval = time.Minute
+
+	// remoteTimeout bounds one request for the remote configuration
+	remoteTimeout = 10 * time.Second
+
+	// maxRemoteConfig caps the size of a remote configuration document
+	maxRemoteConfig = 1 << 20
+)
+
+// Remote configuration URL schemes besides http and https. The +https
+// variants talk to the store over TLS.
+const (
+	SchemeConsul      = "consul"
+	SchemeConsulHTTPS = "consul+https"
+	SchemeEtcd        = "etcd"
+	SchemeEtcdHTTPS   = "etcd+https"
+)
+
+// FetchRemote returns the YAML configuration document at rawURL, which is
+// one of
+//
+//	https://host/path            fetched with GET
+//	consul://host:8500/key       a Consul KV key
+//	etcd://host:2379/key         an etcd v3 key, read through the JSON gateway
+//
+// A non-empty token is sent as a bearer token over HTTP, as X-Consul-Token to
+// Consul and as the Authorization header to etcd.
+func FetchRemote(ctx context.Context, client *http.Client, rawURL, token string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing REMOTE_CONFIG_URL: %w", err)
+	}
+
+	var req *http.Request
+	switch u.Scheme {
+	case "http", "https":
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err == nil && token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	case SchemeConsul, SchemeConsulHTTPS:
+		key := strings.TrimPrefix(u.Path, "/")
+		endpoint := storeURL(u, "/v1/kv/"+key)
+		endpoint.RawQuery = "raw"
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+		if err == nil && token != "" {
+			req.Header.Set("X-Consul-Token", token)
+		}
+	case SchemeEtcd, SchemeEtcdHTTPS:
+		return fetchEtcd(ctx, client, u, token)
+	default:
+		return nil, fmt.Errorf("REMOTE_CONFIG_URL scheme %q is not one of http, https, consul or etcd", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return readRemote(client, req)
+}
+
+// storeURL returns the HTTP endpoint path of the key/value store addressed
+// by u
+func storeURL(u *url.URL, path string) *url.URL {
+	scheme := "http"
+	if strings.HasSuffix(u.Scheme, "+https") {
+		scheme = "https"
+	}
+	return &url.URL{Scheme: scheme, Host: u.Host, Path: path}
+}
+
+// fetchEtcd reads the key at u.Path from the etcd v3 JSON gateway
+func fetchEtcd(ctx context.Context, client *http.Client, u *url.URL, token string) ([]byte, error) {
+	body, err := json.Marshal(map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(u.Path))})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, storeURL(u, "/v3/kv/range").String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	b, err := readRemote(client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Kvs []struct {
+			Value string `json:"value"`
+		} `json:"kvs"`
+	}
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return nil, fmt.Errorf("decoding etcd response: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, fmt.Errorf("etcd key %s does not exist", u.Path)
+	}
+	return base64.StdEncoding.DecodeString(resp.Kvs[0].Value)
+}
+
+// readRemote sends req and returns the body of a successful response
+func readRemote(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching remote configuration: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching remote configuration from %s: %s", req.URL.Redacted(), resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteConfig+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading remote configuration: %w", err)
+	}
+	if len(b) > maxRemoteConfig {
+		return nil, fmt.Errorf("remote configuration is larger than %d bytes", maxRemoteConfig)
+	}
+	return b, nil
+}
+
+// readRemote fetches the document at REMOTE_CONFIG_URL, which is set in the
+// local file, the environment or a flag, and layers it below the local file
+// like viper's remote providers: the remote document provides the settings
+// that the file, environment and flags do not set.
+func (l *Loader) readRemote() ([]byte, error) {
+	v := l.viper
+	rawURL := v.GetString("Remote_Config_URL")
+	if rawURL == "" {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+	client := &http.Client{Timeout: remoteTimeout}
+	remote, err := FetchRemote(ctx, client, rawURL, v.GetString("Remote_Config_Token"))
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := viper.New()
+	parsed.SetConfigType("yaml")
+	if err := parsed.ReadConfig(bytes.NewReader(remote)); err != nil {
+		return nil, fmt.Errorf("parsing remote configuration: %w", err)
+	}
+	l.remoteKeys = make(map[string]bool)
+	for _, key := range parsed.AllKeys() {
+		if !v.InConfig(key) {
+			l.remoteKeys[key] = true
+		}
+	}
+
+	// ReadConfig replaces the file's settings, which are merged back on top
+	if err := v.ReadConfig(bytes.NewReader(remote)); err != nil {
+		return nil, fmt.Errorf("parsing remote configuration: %w", err)
+	}
+	if v.ConfigFileUsed() != "" {
+		if err := v.MergeInConfig(); err != nil {
+			return nil, err
+		}
+	}
+	return remote, nil
+}
+
+// WatchRemote checks the remote configuration of cfg every
+// Remote_Config_Interval and calls changed when the document differs from
+// the one cfg was loaded from. It returns once changed accepts the change;
+// a rejected document is not reported again until it changes once more.
+// Fetch errors are passed to failed and the check is retried on the next
+// interval. WatchRemote returns immediately when no remote configuration or
+// interval is set.
+func WatchRemote(ctx context.Context, cfg *Config, changed func() bool, failed func(error)) {
+	if cfg.Remote_Config_URL == "" || cfg.Remote_Config_Interval <= 0 {
+		return
+	}
+	client := &http.Client{Timeout: remoteTimeout}
+	ticker := time.NewTicker(cfg.Remote_Config_Interval)
+	defer ticker.Stop()
+
+	last := cfg.remote
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current, err := FetchRemote(ctx, client, cfg.Remote_Config_URL, cfg.Remote_Config_Token)
+		if err != nil {
+			if ctx.Err() == nil {
+				failed(err)
+			}
+			continue
+		}
+		if bytes.Equal(current, last) {
+			continue
+		}
+		if changed() {
+			return
+		}
+		last = current
+	}
+}
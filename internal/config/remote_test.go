@@ -0,0 +1,206 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const remoteYAML = "influx_url: http://remote:8086\ninflux_org: remote-org\ninflux_token: remote-token\ninflux_bucket: remote-bucket\n"
+
+func TestFetchRemoteHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/site1.yml" || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(remoteYAML))
+	}))
+	defer srv.Close()
+
+	b, err := FetchRemote(context.Background(), srv.Client(), srv.URL+"/site1.yml", "secret")
+	if err != nil {
+		t.Fatalf("FetchRemote() error = %v", err)
+	}
+	if string(b) != remoteYAML {
+		t.Errorf("FetchRemote() = %q", b)
+	}
+
+	if _, err := FetchRemote(context.Background(), srv.Client(), srv.URL+"/other.yml", "secret"); err == nil {
+		t.Error("Expected an error for a missing document")
+	}
+}
+
+func TestFetchRemoteConsul(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/tempest/site1" || r.URL.RawQuery != "raw" || r.Header.Get("X-Consul-Token") != "secret" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(remoteYAML))
+	}))
+	defer srv.Close()
+
+	rawURL := "consul://" + strings.TrimPrefix(srv.URL, "http://") + "/tempest/site1"
+	b, err := FetchRemote(context.Background(), srv.Client(), rawURL, "secret")
+	if err != nil {
+		t.Fatalf("FetchRemote() error = %v", err)
+	}
+	if string(b) != remoteYAML {
+		t.Errorf("FetchRemote() = %q", b)
+	}
+}
+
+func TestFetchRemoteEtcd(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Key string `json:"key"`
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/v3/kv/range" || json.NewDecoder(r.Body).Decode(&req) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		key, _ := base64.StdEncoding.DecodeString(req.Key)
+		if string(key) != "/tempest/site1" {
+			w.Write([]byte(`{"header":{}}`))
+			return
+		}
+		value := base64.StdEncoding.EncodeToString([]byte(remoteYAML))
+		w.Write([]byte(`{"kvs":[{"key":"` + req.Key + `","value":"` + value + `"}]}`))
+	}))
+	defer srv.Close()
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	b, err := FetchRemote(context.Background(), srv.Client(), "etcd://"+host+"/tempest/site1", "")
+	if err != nil {
+		t.Fatalf("FetchRemote() error = %v", err)
+	}
+	if string(b) != remoteYAML {
+		t.Errorf("FetchRemote() = %q", b)
+	}
+
+	if _, err := FetchRemote(context.Background(), srv.Client(), "etcd://"+host+"/tempest/other", ""); err == nil {
+		t.Error("Expected an error for a missing key")
+	}
+}
+
+func TestFetchRemoteScheme(t *testing.T) {
+	if _, err := FetchRemote(context.Background(), http.DefaultClient, "ftp://example.com/config.yml", ""); err == nil {
+		t.Error("Expected an error for an unsupported scheme")
+	}
+}
+
+func TestLoaderRemote(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(remoteYAML + "buffer: 4096\n"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	yaml := "remote_config_url: " + srv.URL + "\ninflux_bucket: file-bucket\n"
+	if err := os.WriteFile(filepath.Join(dir, "tempest-influxdb.yml"), []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("INFLUX_ORG", "env-org")
+
+	loader := NewLoader(dir, "tempest-influxdb", nil)
+	cfg, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Influx_URL != "http://remote:8086" || cfg.Buffer != 4096 {
+		t.Errorf("Expected settings from the remote configuration, got %s and %d", cfg.Influx_URL, cfg.Buffer)
+	}
+	if cfg.Influx_Bucket != "file-bucket" {
+		t.Errorf("Expected the file to override the remote configuration, got %s", cfg.Influx_Bucket)
+	}
+	if cfg.Influx_Org != "env-org" {
+		t.Errorf("Expected the environment to override the remote configuration, got %s", cfg.Influx_Org)
+	}
+
+	sources := make(map[string]string)
+	for _, s := range loader.Settings() {
+		sources[s.Key] = s.Source
+	}
+	for key, want := range map[string]string{
+		"influx_url":        SourceRemote,
+		"influx_bucket":     SourceFile,
+		"remote_config_url": SourceFile,
+		"influx_org":        SourceEnv,
+	} {
+		if sources[key] != want {
+			t.Errorf("source of %s = %q, want %q", key, sources[key], want)
+		}
+	}
+}
+
+func TestLoaderRemoteUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	if _, err := NewLoader(t.TempDir(), "tempest-influxdb", []string{"--remote_config_url", srv.URL}).Load(); err == nil {
+		t.Error("Expected an error when the remote configuration cannot be fetched")
+	}
+}
+
+func TestWatchRemote(t *testing.T) {
+	var document atomic.Value
+	document.Store(remoteYAML)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(document.Load().(string)))
+	}))
+	defer srv.Close()
+
+	cfg, err := NewLoader(t.TempDir(), "tempest-influxdb", []string{"--remote_config_url", srv.URL}).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	cfg.Remote_Config_Interval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var calls atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		WatchRemote(ctx, cfg, func() bool {
+			// The first change is rejected and must not be reported again
+			return calls.Add(1) > 1
+		}, func(err error) {
+			t.Errorf("Unexpected fetch error: %v", err)
+		})
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if calls.Load() != 0 {
+		t.Fatal("Expected no change to be reported for an unchanged document")
+	}
+
+	document.Store(remoteYAML + "buffer: 1\n")
+	time.Sleep(100 * time.Millisecond)
+	if calls.Load() != 1 {
+		t.Fatalf("Expected one rejected change, got %d calls", calls.Load())
+	}
+
+	document.Store(remoteYAML + "buffer: 2\n")
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("WatchRemote did not return after an accepted change")
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected two changes, got %d", calls.Load())
+	}
+}
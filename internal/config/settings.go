@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Sources of a setting, in increasing order of precedence
+const (
+	SourceDefault = "default"
+	SourceRemote  = "remote"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Redacted replaces the values of secret settings
+const Redacted = "[redacted]"
+
+// secretWords mark settings whose values are redacted
+var secretWords = []string{"token", "password", "secret", "headers"}
+
+// secretURLSettings carry credentials in the path or query of their URLs,
+// such as Slack and ntfy webhooks, so only the host of those is shown
+var secretURLSettings = []string{"alert_channels", "webhook_url"}
+
+// Setting is one effective configuration value and where it came from
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// Settings returns every setting known after Load, sorted by key, with the
+// source that provided its value. Tokens, passwords, HTTP headers,
+// passwords in URLs and the paths of webhook URLs are redacted. The settings are available even when Load
+// failed validation, which is when they are most useful.
+func (l *Loader) Settings() []Setting {
+	keys := l.viper.AllKeys()
+	slices.Sort(keys)
+
+	settings := make([]Setting, 0, len(keys))
+	for _, key := range keys {
+		settings = append(settings, Setting{
+			Key:    key,
+			Value:  redact(key, l.viper.Get(key)),
+			Source: l.source(key),
+		})
+	}
+	return settings
+}
+
+// source returns where the value of key came from, following viper's order
+// of precedence
+func (l *Loader) source(key string) string {
+	if f := l.flags.Lookup(key); f != nil && f.Changed {
+		return SourceFlag
+	}
+	if _, ok := os.LookupEnv(strings.ToUpper(key)); ok && !strings.Contains(key, ".") {
+		return SourceEnv
+	}
+	if l.remoteKeys[key] {
+		return SourceRemote
+	}
+	if l.viper.InConfig(key) {
+		return SourceFile
+	}
+	return SourceDefault
+}
+
+// redact formats value for display, hiding it if key names a secret.
+// Secrets nested in lists and maps, such as the tokens of influx_mirrors,
+// are hidden by their own keys.
+func redact(key string, value any) string {
+	if value == nil {
+		return ""
+	}
+	secretURLs := slices.Contains(secretURLSettings, strings.ToLower(key))
+	s := fmt.Sprint(redactNested(value, secretURLs))
+	if s == "" {
+		return s
+	}
+	if isSecret(key) {
+		return Redacted
+	}
+	if _, ok := value.(string); ok && secretURLs {
+		return redactURL(s)
+	}
+	if u, err := url.Parse(s); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return s
+}
+
+// isSecret reports whether key names a secret
+func isSecret(key string) bool {
+	for _, word := range secretWords {
+		if strings.Contains(strings.ToLower(key), word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactNested returns value with the secrets of nested maps replaced.
+// With secretURLs, their url entries are reduced to the host.
+func redactNested(value any, secretURLs bool) any {
+	switch v := value.(type) {
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = redactNested(e, secretURLs)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			switch {
+			case isSecret(k) && fmt.Sprint(e) != "":
+				out[k] = Redacted
+			case secretURLs && strings.EqualFold(k, "url"):
+				out[k] = redactURL(fmt.Sprint(e))
+			default:
+				out[k] = redactNested(e, secretURLs)
+			}
+		}
+		return out
+	}
+	return value
+}
+
+// redactURL keeps the scheme and host of s and hides the rest
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		if s == "" {
+			return s
+		}
+		return Redacted
+	}
+	if u.User == nil && strings.Trim(u.Path, "/") == "" && u.RawQuery == "" {
+		return s
+	}
+	return u.Scheme + "://" + u.Host + "/" + Redacted
+}
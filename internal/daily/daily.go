@@ -0,0 +1,190 @@
+// Package daily keeps per-station accumulators that reset at local midnight
+package daily
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+// DegreeDayBase is the base temperature in °C for heating and cooling
+// degree days
+const DegreeDayBase = 18.0
+
+// Stats are the accumulated values of one station for one local day
+type Stats struct {
+	// Day is the local date, e.g. "2024-01-31"
+	Day     string
+	TempMin float64
+	TempMax float64
+	Rain    float64 // mm
+
+	hasTemp bool
+	// last is the timestamp of the latest observation added
+	last int64
+}
+
+// add accumulates one observation
+func (s *Stats) add(temp float64, hasTemp bool, rain float64) {
+	if hasTemp {
+		if !s.hasTemp || temp < s.TempMin {
+			s.TempMin = temp
+		}
+		if !s.hasTemp || temp > s.TempMax {
+			s.TempMax = temp
+		}
+		s.hasTemp = true
+	}
+	s.Rain += rain
+}
+
+// DegreeDays returns the heating and cooling degree days of the day so far,
+// using the mean of the temperature extremes
+func (s *Stats) DegreeDays() (heating, cooling float64) {
+	mean := (s.TempMin + s.TempMax) / 2
+	return max(DegreeDayBase-mean, 0), max(mean-DegreeDayBase, 0)
+}
+
+// Accumulator adds since-midnight totals and extremes to obs_st points. Each
+// station's day starts at midnight in the time zone configured for it.
+type Accumulator struct {
+	cfg    *config.Config
+	logger *logger.AppLogger
+
+	mu        sync.Mutex
+	stats     map[string]*Stats
+	locations map[string]*time.Location
+}
+
+// New creates an Accumulator using the station time zones of cfg
+func New(cfg *config.Config, appLogger *logger.AppLogger) *Accumulator {
+	return &Accumulator{
+		cfg:       cfg,
+		logger:    appLogger,
+		stats:     make(map[string]*Stats),
+		locations: make(map[string]*time.Location),
+	}
+}
+
+// Enrich adds the daily fields to every obs_st point
+func (a *Accumulator) Enrich(points []*influx.Data) []*influx.Data {
+	for _, m := range points {
+		if m.ReportType != "obs_st" {
+			continue
+		}
+		a.enrich(m)
+	}
+	return points
+}
+
+// enrich updates the station's stats with m and adds them as fields
+func (a *Accumulator) enrich(m *influx.Data) {
+	serial := m.Tags["station"]
+	day := time.Unix(m.Timestamp, 0).In(a.location(serial)).Format(time.DateOnly)
+
+	temp, hasTemp := m.Float("temp")
+	rain, _ := m.Float("precipitation")
+
+	a.mu.Lock()
+	s, ok := a.stats[serial]
+	switch {
+	case !ok || day > s.Day:
+		s = &Stats{Day: day}
+		a.stats[serial] = s
+	case day < s.Day:
+		// A late observation from a day that is already closed
+		a.mu.Unlock()
+		return
+	}
+	// Observations sent again, e.g. by a second hub, are not added twice
+	if m.Timestamp > s.last {
+		s.add(temp, hasTemp, rain)
+		s.last = m.Timestamp
+	}
+	stats := *s
+	a.mu.Unlock()
+
+	m.Fields["rain_today"] = influx.Round(stats.Rain, 2)
+	if stats.hasTemp {
+		heating, cooling := stats.DegreeDays()
+		m.Fields["temp_min_today"] = influx.Round(stats.TempMin, 2)
+		m.Fields["temp_max_today"] = influx.Round(stats.TempMax, 2)
+		m.Fields["heating_degree_days"] = influx.Round(heating, 2)
+		m.Fields["cooling_degree_days"] = influx.Round(cooling, 2)
+	}
+}
+
+// savedStats is the persisted form of Stats
+type savedStats struct {
+	Day     string  `json:"day"`
+	TempMin float64 `json:"temp_min"`
+	TempMax float64 `json:"temp_max"`
+	Rain    float64 `json:"rain"`
+	HasTemp bool    `json:"has_temp"`
+	Last    int64   `json:"last,omitempty"`
+}
+
+// StateKey implements state.Persistent
+func (a *Accumulator) StateKey() string {
+	return "daily"
+}
+
+// MarshalState implements state.Persistent
+func (a *Accumulator) MarshalState() (json.RawMessage, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	saved := make(map[string]savedStats, len(a.stats))
+	for station, s := range a.stats {
+		saved[station] = savedStats{s.Day, s.TempMin, s.TempMax, s.Rain, s.hasTemp, s.last}
+	}
+	return json.Marshal(saved)
+}
+
+// UnmarshalState implements state.Persistent. Stats of a day that has
+// ended since are replaced by the next observation as usual.
+func (a *Accumulator) UnmarshalState(b json.RawMessage) error {
+	var saved map[string]savedStats
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for station, s := range saved {
+		a.stats[station] = &Stats{Day: s.Day, TempMin: s.TempMin, TempMax: s.TempMax, Rain: s.Rain, hasTemp: s.HasTemp, last: s.Last}
+	}
+	return nil
+}
+
+// Stats returns a copy of the current stats of station
+func (a *Accumulator) Stats(station string) (Stats, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.stats[station]
+	if !ok {
+		return Stats{}, false
+	}
+	return *s, true
+}
+
+// location returns the cached time zone of station, falling back to UTC
+// when it cannot be loaded
+func (a *Accumulator) location(station string) *time.Location {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if loc, ok := a.locations[station]; ok {
+		return loc
+	}
+	loc, err := a.cfg.Location(station)
+	if err != nil {
+		a.logger.Warn("Invalid station time zone, using UTC",
+			"station", station,
+			"error", err.Error())
+		loc = time.UTC
+	}
+	a.locations[station] = loc
+	return loc
+}
@@ -0,0 +1,130 @@
+package daily
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+func observation(station string, at time.Time, temp, rain float64) *influx.Data {
+	m := influx.New()
+	m.Name = "weather"
+	m.ReportType = "obs_st"
+	m.Timestamp = at.Unix()
+	m.Tags["station"] = station
+	m.Fields["temp"] = temp
+	m.Fields["precipitation"] = rain
+	return m
+}
+
+func TestAccumulatorResetsAtLocalMidnight(t *testing.T) {
+	cfg := &config.Config{
+		Timezone: "UTC",
+		Stations: map[string]config.Station{"ST-1": {Timezone: "America/New_York"}},
+	}
+	a := New(cfg, logger.New(&config.Config{}))
+
+	// 23:30 and 00:30 New York time (04:30 and 05:30 UTC on the same UTC day)
+	evening := time.Date(2024, 1, 31, 4, 30, 0, 0, time.UTC)
+	night := evening.Add(time.Hour)
+
+	a.Enrich([]*influx.Data{observation("ST-1", evening, 5.0, 1.0)})
+	a.Enrich([]*influx.Data{observation("ST-2", evening, 5.0, 1.0)})
+
+	local := observation("ST-1", night, 3.0, 0.5)
+	utc := observation("ST-2", night, 3.0, 0.5)
+	a.Enrich([]*influx.Data{local, utc})
+
+	if got := local.Fields["rain_today"]; got != 0.5 {
+		t.Errorf("Expected New York station to reset at local midnight, rain_today = %v", got)
+	}
+	if got := local.Fields["temp_max_today"]; got != 3.0 {
+		t.Errorf("Expected temp_max_today=3.00 after reset, got %v", got)
+	}
+	if got := utc.Fields["rain_today"]; got != 1.5 {
+		t.Errorf("Expected UTC station to keep accumulating, rain_today = %v", got)
+	}
+	if got := utc.Fields["temp_min_today"]; got != 3.0 {
+		t.Errorf("Expected temp_min_today=3.00, got %v", got)
+	}
+	if got := utc.Fields["temp_max_today"]; got != 5.0 {
+		t.Errorf("Expected temp_max_today=5.00, got %v", got)
+	}
+
+	stats, ok := a.Stats("ST-1")
+	if !ok || stats.Day != "2024-01-31" {
+		t.Errorf("Expected ST-1 local day 2024-01-31, got %+v", stats)
+	}
+}
+
+func TestAccumulatorIgnoresLateObservations(t *testing.T) {
+	a := New(&config.Config{}, logger.New(&config.Config{}))
+	today := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	a.Enrich([]*influx.Data{observation("ST-1", today, 5.0, 1.0)})
+	late := observation("ST-1", today.Add(-24*time.Hour), -10.0, 9.0)
+	a.Enrich([]*influx.Data{late})
+
+	if _, ok := late.Fields["rain_today"]; ok {
+		t.Error("Expected no daily fields on an observation from a previous day")
+	}
+	if stats, _ := a.Stats("ST-1"); stats.Rain != 1 || stats.TempMin != 5 {
+		t.Errorf("Expected late observation not to change today's stats, got %+v", stats)
+	}
+}
+
+func TestAccumulatorIgnoresRepeatedObservations(t *testing.T) {
+	a := New(&config.Config{}, logger.New(&config.Config{}))
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	a.Enrich([]*influx.Data{observation("ST-1", now, 5.0, 1.0)})
+	// The same observation relayed by a second hub, and an older one
+	repeated := observation("ST-1", now, 5.0, 1.0)
+	older := observation("ST-1", now.Add(-time.Minute), 4.0, 1.0)
+	a.Enrich([]*influx.Data{repeated, older})
+
+	for _, m := range []*influx.Data{repeated, older} {
+		if got := m.Fields["rain_today"]; got != 1.0 {
+			t.Errorf("Expected rain_today=1 on a repeated observation, got %v", got)
+		}
+	}
+	if stats, _ := a.Stats("ST-1"); stats.Rain != 1 || stats.TempMin != 5 {
+		t.Errorf("Expected repeated observations not to change today's stats, got %+v", stats)
+	}
+}
+
+func TestAccumulatorSkipsOtherReports(t *testing.T) {
+	a := New(&config.Config{}, logger.New(&config.Config{}))
+	m := observation("ST-1", time.Now(), 5.0, 1.0)
+	m.ReportType = "rapid_wind"
+	a.Enrich([]*influx.Data{m})
+
+	if _, ok := m.Fields["rain_today"]; ok {
+		t.Error("Expected rapid_wind point to be left alone")
+	}
+}
+
+func TestStatsDegreeDays(t *testing.T) {
+	tests := []struct {
+		name             string
+		min, max         float64
+		heating, cooling float64
+	}{
+		{"cold", 0, 10, 13, 0},
+		{"hot", 20, 30, 0, 7},
+		{"base", 16, 20, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Stats{TempMin: tt.min, TempMax: tt.max}
+			heating, cooling := s.DegreeDays()
+			if heating != tt.heating || cooling != tt.cooling {
+				t.Errorf("DegreeDays() = %v, %v; want %v, %v", heating, cooling, tt.heating, tt.cooling)
+			}
+		})
+	}
+}
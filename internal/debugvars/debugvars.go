@@ -0,0 +1,81 @@
+// Package debugvars publishes the state of the pipeline with expvar, for
+// users who want to inspect a running collector without Prometheus
+package debugvars
+
+import (
+	"expvar"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/registry"
+)
+
+// Path is where the server serves the variables
+const Path = "/debug/vars"
+
+var (
+	// writes holds, per output, the number of successful and failed write
+	// calls and the points they carried
+	writes   = expvar.NewMap("writes")
+	writesMu sync.Mutex
+
+	queue   atomic.Pointer[func() int]
+	devices atomic.Pointer[registry.Registry]
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() any {
+		return runtime.NumGoroutine()
+	}))
+	expvar.Publish("queued_packets", expvar.Func(func() any {
+		if f := queue.Load(); f != nil {
+			return (*f)()
+		}
+		return 0
+	}))
+	expvar.Publish("last_packet", expvar.Func(lastPacket))
+}
+
+// SetQueue sets the function reporting the packets waiting to be processed.
+// It is called again when the pipeline is rebuilt.
+func SetQueue(f func() int) {
+	queue.Store(&f)
+}
+
+// SetRegistry publishes the time every device in reg was last heard from
+func SetRegistry(reg *registry.Registry) {
+	devices.Store(reg)
+}
+
+// RecordWrite counts one write call of output with n points
+func RecordWrite(output string, n int, err error) {
+	writesMu.Lock()
+	stats, ok := writes.Get(output).(*expvar.Map)
+	if !ok {
+		stats = new(expvar.Map)
+		writes.Set(output, stats)
+	}
+	writesMu.Unlock()
+	if err != nil {
+		stats.Add("failed", 1)
+		stats.Add("failed_points", int64(n))
+		return
+	}
+	stats.Add("ok", 1)
+	stats.Add("points", int64(n))
+}
+
+// lastPacket maps device serial numbers to the time of their last packet
+func lastPacket() any {
+	reg := devices.Load()
+	if reg == nil {
+		return map[string]string{}
+	}
+	last := make(map[string]string)
+	for _, d := range reg.Devices() {
+		last[d.Serial] = d.LastSeen.UTC().Format(time.RFC3339)
+	}
+	return last
+}
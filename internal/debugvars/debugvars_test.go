@@ -0,0 +1,49 @@
+package debugvars
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/registry"
+)
+
+// get decodes the published value of name
+func get(t *testing.T, name string, v any) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), v); err != nil {
+		t.Fatalf("Decoding %s: %v", name, err)
+	}
+}
+
+func TestVars(t *testing.T) {
+	SetQueue(func() int { return 7 })
+	var queued int
+	get(t, "queued_packets", &queued)
+	if queued != 7 {
+		t.Errorf("Expected 7 queued packets, got %d", queued)
+	}
+
+	reg, err := registry.New("", logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.ObservePacket([]byte(`{"serial_number":"ST-1","hub_sn":"HB-1","type":"obs_st","obs":[[1]]}`))
+	SetRegistry(reg)
+	var last map[string]string
+	get(t, "last_packet", &last)
+	if last["ST-1"] == "" {
+		t.Errorf("Expected the last packet time of ST-1, got %v", last)
+	}
+
+	RecordWrite("test", 3, nil)
+	RecordWrite("test", 2, errors.New("down"))
+	var stats map[string]map[string]int
+	get(t, "writes", &stats)
+	if s := stats["test"]; s["ok"] != 1 || s["points"] != 3 || s["failed"] != 1 || s["failed_points"] != 2 {
+		t.Errorf("Unexpected write stats %v", s)
+	}
+}
@@ -0,0 +1,75 @@
+// Package dedupe drops observations that arrive more than once, e.g. when a
+// station is heard by two hubs or over two network paths
+package dedupe
+
+import (
+	"sync"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/metrics"
+)
+
+// HubTag is the tag naming the hub that delivered a point
+const HubTag = "hub"
+
+// Window is how far, in seconds of observation time, duplicates are looked
+// for behind the newest point of a station
+const Window = 10 * 60
+
+// key identifies one observation regardless of the hub that relayed it
+type key struct {
+	station    string
+	reportType string
+	timestamp  int64
+	nanos      int64
+}
+
+// Enricher keeps the first point of every (station, report type, timestamp)
+// and drops later copies. The hub tag of the kept point records which hub
+// delivered it. It must run before any enricher that accumulates values, so
+// that duplicates are not counted twice.
+type Enricher struct {
+	mu     sync.Mutex
+	seen   map[key]struct{}
+	newest int64
+	pruned int64
+}
+
+// NewEnricher creates an empty Enricher
+func NewEnricher() *Enricher {
+	return &Enricher{seen: make(map[key]struct{})}
+}
+
+// Enrich removes points that were already seen
+func (e *Enricher) Enrich(points []*influx.Data) []*influx.Data {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	kept := points[:0]
+	for _, m := range points {
+		k := key{m.Tags["station"], m.ReportType, m.Timestamp, m.Nanos}
+		if _, ok := e.seen[k]; ok {
+			metrics.Duplicates.WithLabelValues(m.Tags[HubTag]).Inc()
+			continue
+		}
+		e.seen[k] = struct{}{}
+		e.newest = max(e.newest, m.Timestamp)
+		kept = append(kept, m)
+	}
+	e.prune()
+	return kept
+}
+
+// prune forgets observations older than Window. It runs at most once per
+// Window so that the cost stays proportional to the number of points.
+func (e *Enricher) prune() {
+	if e.newest-e.pruned < Window {
+		return
+	}
+	for k := range e.seen {
+		if e.newest-k.timestamp > Window {
+			delete(e.seen, k)
+		}
+	}
+	e.pruned = e.newest
+}
@@ -0,0 +1,54 @@
+package dedupe
+
+import (
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func observation(hub string, timestamp int64) *influx.Data {
+	m := influx.New()
+	m.ReportType = "obs_st"
+	m.Timestamp = timestamp
+	m.Tags["station"] = "ST-1"
+	m.Tags[HubTag] = hub
+	m.Fields["temp"] = 20.0
+	return m
+}
+
+func TestEnricher(t *testing.T) {
+	e := NewEnricher()
+	before := testutil.ToFloat64(metrics.Duplicates.WithLabelValues("HB-2"))
+
+	first := observation("HB-1", 1000)
+	if got := e.Enrich([]*influx.Data{first}); len(got) != 1 || got[0].Tags[HubTag] != "HB-1" {
+		t.Fatalf("Expected first point to be kept, got %v", got)
+	}
+	if got := e.Enrich([]*influx.Data{observation("HB-2", 1000)}); len(got) != 0 {
+		t.Errorf("Expected copy from second hub to be dropped, got %d points", len(got))
+	}
+	if got := e.Enrich([]*influx.Data{observation("HB-2", 1060)}); len(got) != 1 {
+		t.Errorf("Expected next observation to be kept, got %d points", len(got))
+	}
+
+	rapid := observation("HB-2", 1000)
+	rapid.ReportType = "rapid_wind"
+	if got := e.Enrich([]*influx.Data{rapid}); len(got) != 1 {
+		t.Errorf("Expected other report type with same timestamp to be kept, got %d points", len(got))
+	}
+
+	if got := testutil.ToFloat64(metrics.Duplicates.WithLabelValues("HB-2")); got != before+1 {
+		t.Errorf("Expected one duplicate counted, got %v", got-before)
+	}
+}
+
+func TestEnricherPrunes(t *testing.T) {
+	e := NewEnricher()
+	e.Enrich([]*influx.Data{observation("HB-1", 1000)})
+	e.Enrich([]*influx.Data{observation("HB-1", 1000+Window+1)})
+	if len(e.seen) != 1 {
+		t.Errorf("Expected old observations to be forgotten, %d remembered", len(e.seen))
+	}
+}
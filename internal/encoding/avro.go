@@ -0,0 +1,103 @@
+package encoding
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+// AvroSchema is the Avro equivalent of tempest.v1.Observation. Fields added
+// later have defaults so that the registry accepts the schema as a
+// compatible change.
+const AvroSchema = `{"type":"record","name":"Observation","namespace":"tempest.v1","fields":[` +
+	`{"name":"packet_id","type":"string"},` +
+	`{"name":"station","type":"string"},` +
+	`{"name":"report_type","type":"string"},` +
+	`{"name":"timestamp","type":"long","doc":"seconds since the Unix epoch"},` +
+	`{"name":"measurement","type":"string"},` +
+	`{"name":"tags","type":{"type":"map","values":"string"}},` +
+	`{"name":"fields","type":{"type":"map","values":"double"}},` +
+	`{"name":"bool_fields","type":{"type":"map","values":"boolean"},"default":{}},` +
+	`{"name":"string_fields","type":{"type":"map","values":"string"},"default":{}}]}`
+
+// registerTimeout bounds schema registration during Encode
+const registerTimeout = 10 * time.Second
+
+// avroEncoder writes Avro binary in the Confluent wire format: a zero magic
+// byte, the 4-byte big-endian schema ID, then the record
+type avroEncoder struct {
+	registry *Registry
+}
+
+func (avroEncoder) Name() string { return "avro" }
+
+func (e avroEncoder) Encode(p *influx.Data) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), registerTimeout)
+	defer cancel()
+	id, err := e.registry.Register(ctx, AvroSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	o := Observation(p)
+	var b bytes.Buffer
+	b.WriteByte(0)
+	b.Write(binary.BigEndian.AppendUint32(nil, uint32(id)))
+	avroString(&b, o.GetPacketId())
+	avroString(&b, o.GetStation())
+	avroString(&b, o.GetReportType())
+	avroLong(&b, o.GetTimestamp())
+	avroString(&b, o.GetMeasurement())
+
+	avroMap(&b, o.GetTags(), avroString)
+	avroMap(&b, o.GetFields(), func(b *bytes.Buffer, v float64) {
+		b.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(v)))
+	})
+	avroMap(&b, o.GetBoolFields(), avroBoolean)
+	avroMap(&b, o.GetStringFields(), avroString)
+	return b.Bytes(), nil
+}
+
+// avroLong writes a zig-zag varint
+func avroLong(b *bytes.Buffer, v int64) {
+	b.Write(binary.AppendVarint(nil, v))
+}
+
+// avroBoolean writes a single byte, 1 for true
+func avroBoolean(b *bytes.Buffer, v bool) {
+	if v {
+		b.WriteByte(1)
+	} else {
+		b.WriteByte(0)
+	}
+}
+
+// avroString writes a length-prefixed UTF-8 string
+func avroString(b *bytes.Buffer, s string) {
+	avroLong(b, int64(len(s)))
+	b.WriteString(s)
+}
+
+// avroMap writes m as a single block followed by the end marker, with keys
+// sorted so equal maps encode identically
+func avroMap[V any](b *bytes.Buffer, m map[string]V, value func(*bytes.Buffer, V)) {
+	if len(m) > 0 {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+
+		avroLong(b, int64(len(keys)))
+		for _, k := range keys {
+			avroString(b, k)
+			value(b, m[k])
+		}
+	}
+	avroLong(b, 0)
+}
@@ -0,0 +1,140 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+)
+
+func newTestRegistry(t *testing.T, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Method != http.MethodPost || r.URL.Path != "/subjects/tempest-observation-value/versions" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if user, pass, _ := r.BasicAuth(); user != "user" || pass != "secret" {
+			t.Errorf("Unexpected credentials %s:%s", user, pass)
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["schema"] != AvroSchema {
+			t.Errorf("Unexpected registration body %v (%v)", body, err)
+		}
+		w.Header().Set("Content-Type", registryContentType)
+		w.Write([]byte(`{"id":7}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAvroEncode(t *testing.T) {
+	var calls atomic.Int32
+	srv := newTestRegistry(t, &calls)
+
+	enc, err := New("avro", &config.Config{
+		Schema_Registry_URL:      srv.URL + "/",
+		Schema_Registry_Subject:  "tempest-observation-value",
+		Schema_Registry_Username: "user",
+		Schema_Registry_Password: "secret",
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	p := rapidWind()
+	p.Fields["is_daytime"] = true
+	p.Fields["conditions"] = "Windy"
+	b, err := enc.Encode(p)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if _, err := enc.Encode(p); err != nil {
+		t.Fatalf("second Encode() error = %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected the schema ID to be cached, got %d registrations", calls.Load())
+	}
+
+	// Confluent wire format header
+	if !bytes.Equal(b[:5], []byte{0, 0, 0, 0, 7}) {
+		t.Fatalf("Unexpected header % x", b[:5])
+	}
+
+	r := bytes.NewReader(b[5:])
+	readLong := func() int64 {
+		v, err := binary.ReadVarint(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	readString := func() string {
+		s := make([]byte, readLong())
+		r.Read(s)
+		return string(s)
+	}
+
+	for _, want := range []string{"abcd1234", "ST-00012345", "rapid_wind"} {
+		if got := readString(); got != want {
+			t.Errorf("Decoded %q, want %q", got, want)
+		}
+	}
+	if ts := readLong(); ts != 1640995200 {
+		t.Errorf("Decoded timestamp %d", ts)
+	}
+	if m := readString(); m != "weather" {
+		t.Errorf("Decoded measurement %q", m)
+	}
+	if n := readLong(); n != 1 || readString() != "station" || readString() != "ST-00012345" || readLong() != 0 {
+		t.Error("Unexpected tags map")
+	}
+	if n := readLong(); n != 2 {
+		t.Fatalf("Expected 2 fields, got %d", n)
+	}
+	if k := readString(); k != "rapid_wind_direction" {
+		t.Errorf("Expected sorted field keys, got %q first", k)
+	}
+	var bits [8]byte
+	r.Read(bits[:])
+	if v := math.Float64frombits(binary.LittleEndian.Uint64(bits[:])); v != 270 {
+		t.Errorf("Decoded direction %v", v)
+	}
+	if k := readString(); k != "rapid_wind_speed" {
+		t.Errorf("Expected speed second, got %q", k)
+	}
+	r.Read(bits[:])
+	if readLong() != 0 {
+		t.Error("Expected the end of the fields map")
+	}
+	if n := readLong(); n != 1 || readString() != "is_daytime" {
+		t.Fatal("Unexpected boolean fields map")
+	}
+	if v, _ := r.ReadByte(); v != 1 || readLong() != 0 {
+		t.Error("Expected is_daytime true")
+	}
+	if n := readLong(); n != 1 || readString() != "conditions" || readString() != "Windy" || readLong() != 0 {
+		t.Error("Unexpected string fields map")
+	}
+	if r.Len() != 0 {
+		t.Errorf("Unexpected %d trailing bytes", r.Len())
+	}
+}
+
+func TestRegistryError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error_code":42201,"message":"Invalid schema"}`, http.StatusUnprocessableEntity)
+	}))
+	defer srv.Close()
+
+	enc, _ := New("avro", &config.Config{Schema_Registry_URL: srv.URL, Schema_Registry_Subject: "s"})
+	if _, err := enc.Encode(rapidWind()); err == nil {
+		t.Error("Expected registration error")
+	}
+}
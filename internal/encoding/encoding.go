@@ -0,0 +1,112 @@
+// Package encoding serializes parsed points for message broker outputs.
+// Every format follows the tempest.v1.Observation schema in api/.
+package encoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+
+	tempestv1 "github.com/jacaudi/tempest-influxdb/api/tempest/v1"
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"google.golang.org/protobuf/proto"
+)
+
+// Encoder serializes one point into a message payload
+type Encoder interface {
+	Name() string
+	Encode(p *influx.Data) ([]byte, error)
+}
+
+// New returns the encoder for a configured encoding name. The Avro
+// encoding registers its schema with the configured Schema Registry.
+func New(name string, cfg *config.Config) (Encoder, error) {
+	switch name {
+	case "", config.EncodingJSON:
+		return jsonEncoder{}, nil
+	case config.EncodingProtobuf:
+		return protobufEncoder{}, nil
+	case config.EncodingAvro:
+		if cfg.Schema_Registry_URL == "" {
+			return nil, fmt.Errorf("the %s encoding requires SCHEMA_REGISTRY_URL", name)
+		}
+		registry := NewRegistry(cfg.Schema_Registry_URL, cfg.Schema_Registry_Subject,
+			cfg.Schema_Registry_Username, cfg.Schema_Registry_Password, nil)
+		return avroEncoder{registry: registry}, nil
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+}
+
+// Observation converts a point into its protobuf representation. Numbers
+// are written as doubles; boolean and string fields, such as is_daytime and
+// conditions, go to their own maps.
+func Observation(p *influx.Data) *tempestv1.Observation {
+	o := &tempestv1.Observation{
+		PacketId:     p.ID,
+		Station:      p.Tags["station"],
+		ReportType:   p.ReportType,
+		Timestamp:    p.Timestamp,
+		Measurement:  p.Name,
+		Tags:         maps.Clone(p.Tags),
+		Fields:       make(map[string]float64, len(p.Fields)),
+		BoolFields:   make(map[string]bool),
+		StringFields: make(map[string]string),
+	}
+	for k, v := range p.Fields {
+		switch v := v.(type) {
+		case bool:
+			o.BoolFields[k] = v
+		case string:
+			o.StringFields[k] = v
+		default:
+			if f, ok := p.Float(k); ok {
+				o.Fields[k] = f
+			}
+		}
+	}
+	return o
+}
+
+// protobufEncoder writes the binary tempest.v1.Observation message
+type protobufEncoder struct{}
+
+func (protobufEncoder) Name() string { return config.EncodingProtobuf }
+
+func (protobufEncoder) Encode(p *influx.Data) ([]byte, error) {
+	return proto.Marshal(Observation(p))
+}
+
+// jsonObservation mirrors tempest.v1.Observation with proto field names.
+// Fields keep their types, so boolean and string fields are included.
+type jsonObservation struct {
+	PacketID    string            `json:"packet_id,omitempty"`
+	Station     string            `json:"station"`
+	ReportType  string            `json:"report_type"`
+	Timestamp   int64             `json:"timestamp"`
+	Measurement string            `json:"measurement"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Fields      map[string]any    `json:"fields"`
+}
+
+// jsonEncoder writes the Observation schema as a JSON object
+type jsonEncoder struct{}
+
+func (jsonEncoder) Name() string { return config.EncodingJSON }
+
+func (jsonEncoder) Encode(p *influx.Data) ([]byte, error) {
+	fields := p.Fields
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	return json.Marshal(jsonObservation{
+		PacketID:    p.ID,
+		Station:     p.Tags["station"],
+		ReportType:  p.ReportType,
+		Timestamp:   p.Timestamp,
+		Measurement: p.Name,
+		Tags:        p.Tags,
+		Fields:      fields,
+	})
+}
@@ -0,0 +1,116 @@
+package encoding
+
+import (
+	"encoding/json"
+	"testing"
+
+	tempestv1 "github.com/jacaudi/tempest-influxdb/api/tempest/v1"
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"google.golang.org/protobuf/proto"
+)
+
+func rapidWind() *influx.Data {
+	p := influx.New()
+	p.ID = "abcd1234"
+	p.Name = "weather"
+	p.ReportType = "rapid_wind"
+	p.Timestamp = 1640995200
+	p.Tags["station"] = "ST-00012345"
+	p.Fields["rapid_wind_speed"] = 5.5
+	p.Fields["rapid_wind_direction"] = int64(270)
+	return p
+}
+
+func TestNew(t *testing.T) {
+	cfg := &config.Config{}
+	for _, name := range []string{"", "json", "protobuf"} {
+		if _, err := New(name, cfg); err != nil {
+			t.Errorf("New(%q) error = %v", name, err)
+		}
+	}
+	if _, err := New("xml", cfg); err == nil {
+		t.Error("Expected error for unknown encoding")
+	}
+	if _, err := New("avro", cfg); err == nil {
+		t.Error("Expected error for avro without a schema registry")
+	}
+}
+
+func TestObservationTypedFields(t *testing.T) {
+	p := rapidWind()
+	p.Fields["is_daytime"] = true
+	p.Fields["conditions"] = "Windy"
+
+	o := Observation(p)
+	if o.GetFields()["rapid_wind_speed"] != 5.5 || o.GetFields()["rapid_wind_direction"] != 270 {
+		t.Errorf("Unexpected numeric fields %v", o.GetFields())
+	}
+	if len(o.GetFields()) != 2 {
+		t.Errorf("Expected only numeric fields in fields, got %v", o.GetFields())
+	}
+	if v, ok := o.GetBoolFields()["is_daytime"]; !ok || !v {
+		t.Errorf("Expected is_daytime in bool_fields, got %v", o.GetBoolFields())
+	}
+	if o.GetStringFields()["conditions"] != "Windy" {
+		t.Errorf("Expected conditions in string_fields, got %v", o.GetStringFields())
+	}
+}
+
+func TestJSONKeepsTypedFields(t *testing.T) {
+	p := rapidWind()
+	p.Fields["is_daytime"] = true
+	p.Fields["conditions"] = "Windy"
+
+	js, _ := New("json", &config.Config{})
+	b, err := js.Encode(p)
+	if err != nil {
+		t.Fatalf("json Encode() error = %v", err)
+	}
+	var decoded struct {
+		Fields map[string]any `json:"fields"`
+	}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("Invalid JSON payload: %v", err)
+	}
+	if decoded.Fields["is_daytime"] != true || decoded.Fields["conditions"] != "Windy" {
+		t.Errorf("Expected boolean and string fields, got %s", b)
+	}
+	if decoded.Fields["rapid_wind_speed"] != 5.5 || decoded.Fields["rapid_wind_direction"] != float64(270) {
+		t.Errorf("Expected numeric fields, got %s", b)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	p := rapidWind()
+
+	pb, _ := New("protobuf", &config.Config{})
+	b, err := pb.Encode(p)
+	if err != nil {
+		t.Fatalf("protobuf Encode() error = %v", err)
+	}
+	var o tempestv1.Observation
+	if err := proto.Unmarshal(b, &o); err != nil {
+		t.Fatalf("Invalid protobuf payload: %v", err)
+	}
+	if o.GetStation() != "ST-00012345" || o.GetTimestamp() != 1640995200 || o.GetFields()["rapid_wind_direction"] != 270 {
+		t.Errorf("Unexpected protobuf observation %v", &o)
+	}
+
+	js, _ := New("json", &config.Config{})
+	j, err := js.Encode(p)
+	if err != nil {
+		t.Fatalf("json Encode() error = %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(j, &decoded); err != nil {
+		t.Fatalf("Invalid JSON payload: %v", err)
+	}
+	if decoded["report_type"] != "rapid_wind" || decoded["timestamp"] != float64(1640995200) {
+		t.Errorf("Unexpected JSON observation %s", j)
+	}
+
+	if len(b) >= len(j) {
+		t.Errorf("Expected protobuf (%d bytes) to be smaller than JSON (%d bytes)", len(b), len(j))
+	}
+}
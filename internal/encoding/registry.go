@@ -0,0 +1,91 @@
+package encoding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// registryContentType is the media type of the Schema Registry REST API
+const registryContentType = "application/vnd.schemaregistry.v1+json"
+
+// Registry registers schemas with a Confluent-compatible Schema Registry
+// and caches the returned IDs
+type Registry struct {
+	url      string
+	subject  string
+	username string
+	password string
+	client   *http.Client
+
+	mu  sync.Mutex
+	ids map[string]int
+}
+
+// NewRegistry creates a Registry client for subject
+func NewRegistry(baseURL, subject, username, password string, client *http.Client) *Registry {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Registry{
+		url:      strings.TrimSuffix(baseURL, "/"),
+		subject:  subject,
+		username: username,
+		password: password,
+		client:   client,
+		ids:      make(map[string]int),
+	}
+}
+
+// Register returns the ID of schema under the registry subject, registering
+// it when the registry does not know it yet. Registering an existing schema
+// returns its ID, so this doubles as a lookup.
+func (r *Registry) Register(ctx context.Context, schema string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id, ok := r.ids[schema]; ok {
+		return id, nil
+	}
+
+	body, err := json.Marshal(map[string]string{"schema": schema})
+	if err != nil {
+		return 0, err
+	}
+	endpoint := fmt.Sprintf("%s/subjects/%s/versions", r.url, url.PathEscape(r.subject))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", registryContentType)
+	req.Header.Set("Accept", registryContentType)
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("registering schema for %s: %w", r.subject, err)
+	}
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("registering schema for %s: %s: %s", r.subject, resp.Status, strings.TrimSpace(string(b)))
+	}
+
+	var result struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return 0, fmt.Errorf("registering schema for %s: decoding response: %w", r.subject, err)
+	}
+
+	r.ids[schema] = result.ID
+	return result.ID, nil
+}
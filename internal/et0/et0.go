@@ -0,0 +1,192 @@
+// Package et0 derives the FAO-56 Penman-Monteith reference
+// evapotranspiration from obs_st, as used by irrigation controllers
+package et0
+
+import (
+	"encoding/json"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/meteo"
+	"github.com/jacaudi/tempest-influxdb/internal/solar"
+)
+
+// Names of the evapotranspiration fields
+const (
+	RateField     = "et0_rate"
+	LastHourField = "et0_last_hour"
+	TodayField    = "et0_today"
+)
+
+const (
+	// window is the span of the hourly total in seconds
+	window = 3600
+	// defaultInterval is the obs_st interval in seconds assumed for the
+	// first observation and after a gap
+	defaultInterval = 60
+	// maxInterval is the longest time in seconds between two observations
+	// that is still taken as their interval
+	maxInterval = 600
+	// nightClearSky is the clear-sky ratio assumed until a station's first
+	// daytime observation, as suggested by FAO-56 for hourly periods
+	nightClearSky = 0.8
+)
+
+// sample is the evapotranspiration of one observation in mm
+type sample struct {
+	Timestamp int64   `json:"timestamp"`
+	ET0       float64 `json:"et0"`
+}
+
+// station is the running state of one station
+type station struct {
+	History  []sample `json:"history"`
+	Day      string   `json:"day"`
+	Today    float64  `json:"today"`
+	ClearSky float64  `json:"clear_sky"` // last daytime clear-sky ratio
+}
+
+// Enricher adds the reference evapotranspiration rate in mm/h and its
+// totals over the last hour and since local midnight to obs_st points of
+// stations with coordinates. Each observation adds its rate times its
+// interval. The wind is taken as measured at the standard height of 2 m,
+// and at night the clear-sky ratio of the last daytime observation sets
+// the longwave radiation.
+type Enricher struct {
+	cfg *config.Config
+
+	mu       sync.Mutex
+	stations map[string]*station
+}
+
+// NewEnricher creates an Enricher for the stations of cfg
+func NewEnricher(cfg *config.Config) *Enricher {
+	return &Enricher{
+		cfg:      cfg,
+		stations: make(map[string]*station),
+	}
+}
+
+// Enrich adds the evapotranspiration fields to every obs_st point with the
+// values it needs
+func (e *Enricher) Enrich(points []*influx.Data) []*influx.Data {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, m := range points {
+		if m.ReportType != "obs_st" {
+			continue
+		}
+		serial := m.Tags["station"]
+		cfg := e.cfg.Station(serial)
+		if !cfg.HasCoordinates() {
+			continue
+		}
+		values, ok := parse(m, "temp", "relative_humidity", "wind_avg", "solar_radiation", "p")
+		if !ok {
+			continue
+		}
+
+		s, ok := e.stations[serial]
+		if !ok {
+			s = &station{ClearSky: nightClearSky}
+			e.stations[serial] = s
+		}
+		t := time.Unix(m.Timestamp, 0)
+		elevation, _ := solar.Position(t, cfg.Latitude, cfg.Longitude)
+		if ratio, ok := solar.ClearSkyRatio(values[3], elevation); ok {
+			s.ClearSky = ratio
+		}
+		rate := meteo.ReferenceET(values[0], values[1], values[2], values[3], values[4], s.ClearSky, elevation > 0)
+
+		loc, err := e.cfg.Location(serial)
+		if err != nil {
+			loc = time.UTC
+		}
+		lastHour, today := s.add(m.Timestamp, t.In(loc).Format(time.DateOnly), rate)
+		m.Fields[RateField] = influx.Round(rate, 3)
+		m.Fields[LastHourField] = influx.Round(lastHour, 3)
+		m.Fields[TodayField] = influx.Round(today, 2)
+	}
+	return points
+}
+
+// parse returns the values of fields of m, or false if one is missing
+func parse(m *influx.Data, fields ...string) ([]float64, bool) {
+	parsed := make([]float64, len(fields))
+	for i, field := range fields {
+		v, ok := m.Float(field)
+		if !ok {
+			return nil, false
+		}
+		parsed[i] = v
+	}
+	return parsed, true
+}
+
+// add records an observation at timestamp on local day with an ET0 rate
+// in mm/h and returns the totals of the last hour and of the day. Late and
+// repeated observations are not recorded.
+func (s *station) add(timestamp int64, day string, rate float64) (lastHour, today float64) {
+	interval := int64(defaultInterval)
+	if n := len(s.History); n > 0 {
+		last := s.History[n-1].Timestamp
+		if timestamp <= last {
+			return total(s.History, timestamp), s.Today
+		}
+		if timestamp-last <= maxInterval {
+			interval = timestamp - last
+		}
+	}
+	if day != s.Day {
+		s.Day, s.Today = day, 0
+	}
+
+	et0 := rate * float64(interval) / 3600
+	s.Today += et0
+	s.History = append(s.History, sample{Timestamp: timestamp, ET0: et0})
+	for len(s.History) > 0 && s.History[0].Timestamp <= timestamp-window {
+		s.History = s.History[1:]
+	}
+	return total(s.History, timestamp), s.Today
+}
+
+// total returns the evapotranspiration of the samples within the hour up
+// to timestamp
+func total(history []sample, timestamp int64) float64 {
+	var sum float64
+	for _, s := range history {
+		if s.Timestamp > timestamp-window && s.Timestamp <= timestamp {
+			sum += s.ET0
+		}
+	}
+	return sum
+}
+
+// StateKey implements state.Persistent
+func (e *Enricher) StateKey() string {
+	return "et0"
+}
+
+// MarshalState implements state.Persistent
+func (e *Enricher) MarshalState() (json.RawMessage, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return json.Marshal(e.stations)
+}
+
+// UnmarshalState implements state.Persistent. A day that has ended since
+// is replaced by the next observation as usual.
+func (e *Enricher) UnmarshalState(b json.RawMessage) error {
+	var stations map[string]*station
+	if err := json.Unmarshal(b, &stations); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	maps.Copy(e.stations, stations)
+	return nil
+}
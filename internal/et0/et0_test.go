@@ -0,0 +1,65 @@
+package et0
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func observation(station string, timestamp int64, radiation int64) *influx.Data {
+	m := influx.New()
+	m.ReportType = "obs_st"
+	m.Timestamp = timestamp
+	m.Tags["station"] = station
+	m.Fields["temp"] = 25.0
+	m.Fields["relative_humidity"] = 40.0
+	m.Fields["wind_avg"] = 2.0
+	m.Fields["solar_radiation"] = radiation
+	m.Fields["p"] = 1010.0
+	return m
+}
+
+func TestEnricher(t *testing.T) {
+	e := NewEnricher(&config.Config{
+		Stations: map[string]config.Station{
+			"ST-1": {Latitude: 40, Longitude: -105, Timezone: "UTC"},
+		},
+	})
+
+	// Summer midday in Colorado, one observation a minute for 90 minutes
+	start := time.Date(2024, 6, 21, 18, 0, 0, 0, time.UTC).Unix()
+	var last *influx.Data
+	for i := int64(0); i < 90; i++ {
+		last = observation("ST-1", start+i*60, 900)
+		e.Enrich([]*influx.Data{last})
+	}
+
+	rate, _ := last.Float(RateField)
+	if rate < 0.5 || rate > 1.0 {
+		t.Errorf("%s = %.3f mm/h, want a sunny-day rate", RateField, rate)
+	}
+	lastHour, _ := last.Float(LastHourField)
+	if lastHour < rate*0.95 || lastHour > rate*1.05 {
+		t.Errorf("%s = %.3f mm, want about the steady rate %.3f", LastHourField, lastHour, rate)
+	}
+	today, _ := last.Float(TodayField)
+	if today < lastHour*1.4 {
+		t.Errorf("%s = %.2f mm, want the total of 90 minutes", TodayField, today)
+	}
+
+	// The day starts over at midnight
+	next := observation("ST-1", time.Date(2024, 6, 22, 0, 1, 0, 0, time.UTC).Unix(), 0)
+	e.Enrich([]*influx.Data{next})
+	if next.Fields[TodayField] == last.Fields[TodayField] {
+		t.Errorf("Expected %s to restart, got %v", TodayField, next.Fields[TodayField])
+	}
+
+	// Stations without coordinates are skipped
+	other := observation("ST-2", start, 900)
+	e.Enrich([]*influx.Data{other})
+	if _, ok := other.Fields[RateField]; ok {
+		t.Error("Expected no fields without coordinates")
+	}
+}
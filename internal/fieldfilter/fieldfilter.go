@@ -0,0 +1,43 @@
+// Package fieldfilter keeps or drops configured fields of each measurement
+// before points are written
+package fieldfilter
+
+import (
+	"slices"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+// Enricher applies the Field_Filters of cfg. It runs after every enricher
+// that computes fields, so derived fields can use dropped ones.
+type Enricher struct {
+	cfg *config.Config
+}
+
+// NewEnricher creates an Enricher for the filters of cfg
+func NewEnricher(cfg *config.Config) *Enricher {
+	return &Enricher{cfg: cfg}
+}
+
+// Enrich removes the filtered fields of every point. Points left without
+// fields are dropped.
+func (e *Enricher) Enrich(points []*influx.Data) []*influx.Data {
+	kept := points[:0]
+	for _, m := range points {
+		filter, ok := e.cfg.FieldFilter(m.Name)
+		if !ok {
+			kept = append(kept, m)
+			continue
+		}
+		for field := range m.Fields {
+			if len(filter.Keep) > 0 && !slices.Contains(filter.Keep, field) || slices.Contains(filter.Drop, field) {
+				delete(m.Fields, field)
+			}
+		}
+		if len(m.Fields) > 0 {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
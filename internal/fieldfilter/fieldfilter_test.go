@@ -0,0 +1,49 @@
+package fieldfilter
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func point(name string, fields ...string) *influx.Data {
+	m := influx.New()
+	m.Name = name
+	for _, f := range fields {
+		m.Fields[f] = 1.0
+	}
+	return m
+}
+
+func TestEnrich(t *testing.T) {
+	cfg := &config.Config{Field_Filters: map[string]config.FieldFilter{
+		"weather":   {Drop: []string{"battery", "illuminance"}},
+		"lightning": {Keep: []string{"strike_distance"}},
+		"summary":   {Keep: []string{"temp"}},
+	}}
+	points := []*influx.Data{
+		point("weather", "temp", "battery", "illuminance"),
+		point("lightning", "strike_distance", "strike_energy"),
+		point("summary", "wind_avg"),
+		point("daily_stats", "battery"),
+	}
+
+	got := NewEnricher(cfg).Enrich(points)
+	if len(got) != 3 {
+		t.Fatalf("Enrich() returned %d points, want 3", len(got))
+	}
+	want := map[string][]string{
+		"weather":     {"temp"},
+		"lightning":   {"strike_distance"},
+		"daily_stats": {"battery"},
+	}
+	for _, m := range got {
+		fields := slices.Sorted(maps.Keys(m.Fields))
+		if !slices.Equal(fields, want[m.Name]) {
+			t.Errorf("%s fields = %v, want %v", m.Name, fields, want[m.Name])
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package forecast writes the WeatherFlow station forecast to InfluxDB so it
+// can be compared with the observations
+package forecast
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/weatherflow"
+)
+
+// Measurement is the name of forecast points
+const Measurement = "forecast"
+
+// Writer stores points, see influx.Writer
+type Writer interface {
+	Write(ctx context.Context, points []*influx.Data) error
+}
+
+// Cloud fetches forecasts, see weatherflow.Client
+type Cloud interface {
+	BetterForecast(ctx context.Context, stationID int) (*weatherflow.Forecast, error)
+}
+
+// Poller fetches the forecast of every station with a cloud station ID and
+// writes one point per forecast hour and day. Each poll overwrites the
+// points of the previous one, so the bucket holds the latest forecast for
+// every future time.
+type Poller struct {
+	cfg    *config.Config
+	logger *logger.AppLogger
+	cloud  Cloud
+	writer Writer
+}
+
+// New creates a Poller
+func New(cfg *config.Config, appLogger *logger.AppLogger, cloud Cloud, writer Writer) *Poller {
+	return &Poller{cfg: cfg, logger: appLogger, cloud: cloud, writer: writer}
+}
+
+// Run polls immediately and then every Forecast_Interval until ctx is
+// cancelled
+func (p *Poller) Run(ctx context.Context) {
+	p.Poll(ctx)
+	ticker := time.NewTicker(p.cfg.Forecast_Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Poll(ctx)
+		}
+	}
+}
+
+// Poll fetches and writes the forecast of every station once
+func (p *Poller) Poll(ctx context.Context) {
+	for serial, station := range p.cfg.Stations {
+		if station.StationID == 0 {
+			continue
+		}
+		f, err := p.cloud.BetterForecast(ctx, station.StationID)
+		if err == nil {
+			err = p.writer.Write(ctx, p.points(serial, f))
+		}
+		if err != nil {
+			p.logger.Warn("Failed to update forecast",
+				slog.String("station", serial),
+				slog.String("error", err.Error()))
+		}
+	}
+}
+
+// points converts a forecast into points
+func (p *Poller) points(serial string, f *weatherflow.Forecast) []*influx.Data {
+	var points []*influx.Data
+	for _, h := range f.Forecast.Hourly {
+		m := p.point(serial, "hourly", h.Time, h.Conditions)
+		setFloat(m, "temp", h.AirTemperature)
+		setFloat(m, "feels_like", h.FeelsLike)
+		setFloat(m, "relative_humidity", h.RelativeHumidity)
+		setFloat(m, "sea_level_pressure", h.SeaLevelPressure)
+		setFloat(m, "precipitation", h.Precip)
+		setFloat(m, "precip_probability", h.PrecipProbability)
+		setFloat(m, "wind_avg", h.WindAvg)
+		setFloat(m, "wind_direction", h.WindDirection)
+		setFloat(m, "wind_gust", h.WindGust)
+		setFloat(m, "uv", h.UV)
+		points = append(points, m)
+	}
+	for _, d := range f.Forecast.Daily {
+		m := p.point(serial, "daily", d.DayStartLocal, d.Conditions)
+		setFloat(m, "temp_max", d.AirTempHigh)
+		setFloat(m, "temp_min", d.AirTempLow)
+		setFloat(m, "precip_probability", d.PrecipProbability)
+		if d.Sunrise != 0 {
+			m.Fields["sunrise"] = d.Sunrise
+		}
+		if d.Sunset != 0 {
+			m.Fields["sunset"] = d.Sunset
+		}
+		points = append(points, m)
+	}
+	return points
+}
+
+// point creates a forecast point for one period
+func (p *Poller) point(serial, period string, timestamp int64, conditions string) *influx.Data {
+	m := influx.New()
+	m.Name = Measurement
+	m.ReportType = Measurement
+	m.Bucket = p.cfg.Influx_Bucket
+	m.Timestamp = timestamp
+	m.Tags["station"] = serial
+	m.Tags["period"] = period
+	if conditions != "" {
+		m.Fields["conditions"] = conditions
+	}
+	return m
+}
+
+// setFloat sets field to v unless the forecast omitted it
+func setFloat(m *influx.Data, field string, v *float64) {
+	if v != nil {
+		m.Fields[field] = influx.Round(*v, 2)
+	}
+}
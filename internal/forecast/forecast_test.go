@@ -0,0 +1,78 @@
+package forecast
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/weatherflow"
+)
+
+type stubCloud struct{ calls []int }
+
+func (c *stubCloud) BetterForecast(ctx context.Context, stationID int) (*weatherflow.Forecast, error) {
+	c.calls = append(c.calls, stationID)
+	if stationID != 1234 {
+		return nil, errors.New("unknown station")
+	}
+	temp, high, sunrise := 8.1, 12.5, int64(1700020000)
+	f := &weatherflow.Forecast{}
+	f.Forecast.Hourly = []weatherflow.HourlyForecast{{Time: 1700003600, Conditions: "Clear", AirTemperature: &temp}}
+	f.Forecast.Daily = []weatherflow.DailyForecast{{DayStartLocal: 1700000000, AirTempHigh: &high, Sunrise: sunrise}}
+	return f, nil
+}
+
+type recordingWriter struct{ points []*influx.Data }
+
+func (w *recordingWriter) Write(ctx context.Context, points []*influx.Data) error {
+	w.points = append(w.points, points...)
+	return nil
+}
+
+func TestPoll(t *testing.T) {
+	cfg := &config.Config{
+		Influx_Bucket: "weather",
+		Stations: map[string]config.Station{
+			"ST-1": {StationID: 1234},
+			"ST-2": {},
+		},
+	}
+	cloud := &stubCloud{}
+	writer := &recordingWriter{}
+	New(cfg, logger.New(&config.Config{}), cloud, writer).Poll(context.Background())
+
+	if len(cloud.calls) != 1 {
+		t.Fatalf("Expected only stations with a station_id to be polled, got %v", cloud.calls)
+	}
+	if len(writer.points) != 2 {
+		t.Fatalf("Expected an hourly and a daily point, got %d", len(writer.points))
+	}
+
+	hourly := writer.points[0]
+	if hourly.Name != Measurement || hourly.Tags["period"] != "hourly" || hourly.Tags["station"] != "ST-1" {
+		t.Errorf("Unexpected hourly point %+v", hourly)
+	}
+	if hourly.Timestamp != 1700003600 || hourly.Fields["temp"] != 8.1 || hourly.Fields["conditions"] != "Clear" {
+		t.Errorf("Unexpected hourly fields %v at %d", hourly.Fields, hourly.Timestamp)
+	}
+	if _, ok := hourly.Fields["wind_avg"]; ok {
+		t.Error("Expected missing values to be omitted")
+	}
+
+	daily := writer.points[1]
+	if daily.Tags["period"] != "daily" || daily.Fields["temp_max"] != 12.5 || daily.Fields["sunrise"] != int64(1700020000) {
+		t.Errorf("Unexpected daily point %+v", daily)
+	}
+}
+
+func TestPollError(t *testing.T) {
+	cfg := &config.Config{Stations: map[string]config.Station{"ST-9": {StationID: 9}}}
+	writer := &recordingWriter{}
+	New(cfg, logger.New(&config.Config{}), &stubCloud{}, writer).Poll(context.Background())
+	if len(writer.points) != 0 {
+		t.Errorf("Expected nothing written when the fetch fails, got %d points", len(writer.points))
+	}
+}
@@ -0,0 +1,112 @@
+// Package grpcapi serves the gRPC observation stream defined in
+// api/tempest/v1/tempest.proto.
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"time"
+
+	tempestv1 "github.com/jacaudi/tempest-influxdb/api/tempest/v1"
+	"github.com/jacaudi/tempest-influxdb/internal/auth"
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/encoding"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/stream"
+	"google.golang.org/grpc"
+)
+
+// shutdownTimeout bounds how long a graceful stop may take
+const shutdownTimeout = 5 * time.Second
+
+// Server implements tempestv1.ObservationServiceServer on top of a stream.Hub
+type Server struct {
+	tempestv1.UnimplementedObservationServiceServer
+
+	config *config.Config
+	logger *logger.AppLogger
+	hub    *stream.Hub
+	grpc   *grpc.Server
+	done   chan struct{}
+}
+
+// New creates a Server publishing the points observed by hub
+func New(cfg *config.Config, appLogger *logger.AppLogger, hub *stream.Hub) *Server {
+	var opts []grpc.ServerOption
+	if auth.Enabled(cfg) {
+		opts = append(opts, grpc.StreamInterceptor(auth.StreamInterceptor(cfg)))
+	}
+	s := &Server{
+		config: cfg,
+		logger: appLogger,
+		hub:    hub,
+		grpc:   grpc.NewServer(opts...),
+		done:   make(chan struct{}),
+	}
+	tempestv1.RegisterObservationServiceServer(s.grpc, s)
+	return s
+}
+
+// Subscribe implements tempestv1.ObservationServiceServer
+func (s *Server) Subscribe(req *tempestv1.SubscribeRequest, srv grpc.ServerStreamingServer[tempestv1.Observation]) error {
+	sub := s.hub.Subscribe(stream.Filter{
+		Stations:    req.GetStations(),
+		ReportTypes: req.GetReportTypes(),
+	})
+	defer func() {
+		sub.Close()
+		if dropped := sub.Dropped(); dropped > 0 {
+			s.logger.Warn("gRPC subscriber missed observations", "dropped", dropped)
+		}
+	}()
+
+	for {
+		select {
+		case <-srv.Context().Done():
+			return nil
+		case <-s.done:
+			return nil
+		case p := <-sub.C:
+			if err := srv.Send(encoding.Observation(p)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Run serves gRPC on the configured address until ctx is cancelled
+func (s *Server) Run(ctx context.Context) error {
+	lis, err := net.Listen("tcp", s.config.GRPC_Listen_Address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, lis)
+}
+
+// Serve serves gRPC on lis until ctx is cancelled
+func (s *Server) Serve(ctx context.Context, lis net.Listener) error {
+	errCh := make(chan error, 1)
+	go func() {
+		s.logger.Info("gRPC server started", "address", lis.Addr().String())
+		errCh <- s.grpc.Serve(lis)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		// End open streams so the graceful stop can complete
+		close(s.done)
+		stopped := make(chan struct{})
+		go func() {
+			s.grpc.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(shutdownTimeout):
+			s.grpc.Stop()
+		}
+		return <-errCh
+	}
+}
@@ -0,0 +1,79 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	tempestv1 "github.com/jacaudi/tempest-influxdb/api/tempest/v1"
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/stream"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func point(station string, temp float64) *influx.Data {
+	p := influx.New()
+	p.ID = "abcd1234"
+	p.Name = "weather"
+	p.ReportType = "obs_st"
+	p.Timestamp = 1640995200
+	p.Tags["station"] = station
+	p.Fields["temp"] = temp
+	p.Fields["is_daytime"] = true
+	p.Fields["conditions"] = "Calm"
+	return p
+}
+
+func TestSubscribe(t *testing.T) {
+	hub := stream.NewHub("test", stream.DefaultBuffer)
+	server := New(&config.Config{}, logger.New(&config.Config{}), hub)
+
+	lis := bufconn.Listen(1 << 20)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(ctx, lis) }()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := tempestv1.NewObservationServiceClient(conn)
+	callCtx, callCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer callCancel()
+	sub, err := client.Subscribe(callCtx, &tempestv1.SubscribeRequest{Stations: []string{"ST-2"}})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	// Wait until the server registered the subscription
+	for hub.Subscribers() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	hub.Observe([]*influx.Data{point("ST-1", 10), point("ST-2", 20)})
+
+	o, err := sub.Recv()
+	if err != nil {
+		t.Fatalf("Recv() error = %v", err)
+	}
+	if o.GetStation() != "ST-2" || o.GetFields()["temp"] != 20 || !o.GetBoolFields()["is_daytime"] || o.GetStringFields()["conditions"] != "Calm" {
+		t.Errorf("Unexpected observation %v", o)
+	}
+
+	// Shutting down ends open streams
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("Serve() error = %v", err)
+	}
+	if _, err := sub.Recv(); err == nil {
+		t.Error("Expected stream to end on shutdown")
+	}
+}
@@ -0,0 +1,300 @@
+// Package handoff passes the listening sockets of the service to a new
+// process of the binary, so that an upgrade does not drop the broadcasts
+// that arrive while the service restarts
+package handoff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+const (
+	// EnvFiles lists the names of the sockets a process inherits, in the
+	// order of their descriptors from 3
+	EnvFiles = "TEMPEST_INFLUX_HANDOFF"
+
+	// UDP names the socket the hub's broadcasts are read from
+	UDP = "udp"
+
+	// ReadyTimeout is how long a new process may take to start up before
+	// the upgrade is abandoned
+	ReadyTimeout = 30 * time.Second
+
+	// ready names the pipe a new process reports its startup on
+	ready = "ready"
+)
+
+// fileListener is a listener whose socket can be passed on, see
+// net.TCPListener
+type fileListener interface {
+	net.Listener
+	File() (*os.File, error)
+}
+
+// Sockets holds the sockets inherited from the previous process and those
+// bound since, and hands them over to the next process
+type Sockets struct {
+	logger *logger.AppLogger
+
+	// exe and args start the next process
+	exe  string
+	args []string
+
+	mu        sync.Mutex
+	inherited map[string]*os.File
+	ready     *os.File
+	udp       *net.UDPConn
+	listeners map[string]fileListener
+}
+
+// Inherit takes over the sockets listed in EnvFiles and removes the
+// variable, so that processes started later do not take it over again
+func Inherit(appLogger *logger.AppLogger) *Sockets {
+	s := &Sockets{
+		logger:    appLogger,
+		args:      os.Args[1:],
+		inherited: make(map[string]*os.File),
+		listeners: make(map[string]fileListener),
+	}
+	names := os.Getenv(EnvFiles)
+	os.Unsetenv(EnvFiles)
+	if names == "" {
+		return s
+	}
+	for i, name := range strings.Split(names, ",") {
+		f := os.NewFile(uintptr(3+i), name)
+		if name == ready {
+			s.ready = f
+			continue
+		}
+		s.inherited[name] = f
+	}
+	appLogger.Info("Inherited sockets from the previous process", slog.String("sockets", names))
+	return s
+}
+
+// Inherited reports whether the process took over from a previous one
+func (s *Sockets) Inherited() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ready != nil
+}
+
+// ListenUDP returns the inherited UDP socket, or binds addr if there is
+// none. The socket is not closed by Close of the returned listener; it is
+// kept for the pipeline restarts and upgrades of the process.
+func (s *Sockets) ListenUDP(addr string) (*Conn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.udp == nil {
+		conn, err := s.listenUDP(addr)
+		if err != nil {
+			return nil, err
+		}
+		s.udp = conn
+	}
+	return &Conn{UDPConn: s.udp}, nil
+}
+
+// listenUDP takes over the inherited UDP socket or binds addr. The caller
+// holds mu.
+func (s *Sockets) listenUDP(addr string) (*net.UDPConn, error) {
+	if f, ok := s.inherited[UDP]; ok {
+		delete(s.inherited, UDP)
+		defer f.Close()
+		conn, err := net.FilePacketConn(f)
+		if err != nil {
+			return nil, fmt.Errorf("taking over inherited UDP socket: %w", err)
+		}
+		udp, ok := conn.(*net.UDPConn)
+		if !ok {
+			conn.Close()
+			return nil, errors.New("inherited UDP socket is not a UDP socket")
+		}
+		return udp, nil
+	}
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return net.ListenUDP("udp", udpAddr)
+}
+
+// Listen returns the inherited TCP listener called name, or listens on addr
+// if there is none
+func (s *Sockets) Listen(name, addr string) (net.Listener, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var lis net.Listener
+	var err error
+	if f, ok := s.inherited[name]; ok {
+		delete(s.inherited, name)
+		lis, err = net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("taking over inherited %s listener: %w", name, err)
+		}
+	} else if lis, err = net.Listen("tcp", addr); err != nil {
+		return nil, err
+	}
+	if fl, ok := lis.(fileListener); ok {
+		s.listeners[name] = fl
+	}
+	return lis, nil
+}
+
+// Ready tells the previous process that this one has taken over, so that it
+// can stop. Inherited sockets that were not taken over are closed.
+func (s *Sockets) Ready() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, f := range s.inherited {
+		s.logger.Warn("Closing inherited socket that is no longer used", slog.String("socket", name))
+		f.Close()
+		delete(s.inherited, name)
+	}
+	if s.ready == nil {
+		return
+	}
+	if _, err := s.ready.Write([]byte{1}); err != nil {
+		s.logger.Warn("Failed to report readiness to the previous process", slog.String("error", err.Error()))
+	}
+	s.ready.Close()
+	s.ready = nil
+}
+
+// Upgrade starts a new process of the binary with the same arguments and
+// environment, passes the sockets to it and waits until it is ready. An
+// error means that the new process did not take over and was stopped; the
+// sockets remain usable by this process either way.
+func (s *Sockets) Upgrade(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exe := s.exe
+	if exe == "" {
+		var err error
+		if exe, err = os.Executable(); err != nil {
+			return err
+		}
+	}
+
+	var names []string
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	if s.udp != nil {
+		f, err := s.udp.File()
+		if err != nil {
+			return fmt.Errorf("passing UDP socket: %w", err)
+		}
+		names = append(names, UDP)
+		files = append(files, f)
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.listeners)) {
+		f, err := s.listeners[name].File()
+		if err != nil {
+			return fmt.Errorf("passing %s listener: %w", name, err)
+		}
+		names = append(names, name)
+		files = append(files, f)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	names = append(names, ready)
+	files = append(files, w)
+
+	cmd := exec.Command(exe, s.args...)
+	cmd.Env = append(os.Environ(), EnvFiles+"="+strings.Join(names, ","))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting new process: %w", err)
+	}
+	// Only the new process holds the write end now, so that the read ends
+	// when it exits
+	w.Close()
+
+	readyCh := make(chan error, 1)
+	go func() {
+		_, err := r.Read(make([]byte, 1))
+		readyCh <- err
+	}()
+
+	select {
+	case err := <-readyCh:
+		if err == nil {
+			s.logger.Info("New process took over", slog.Int("pid", cmd.Process.Pid))
+			return cmd.Process.Release()
+		}
+		err = cmd.Wait()
+		return fmt.Errorf("new process exited before it was ready: %v", err)
+	case <-time.After(ReadyTimeout):
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("new process was not ready within %s", ReadyTimeout)
+	case <-ctx.Done():
+		cmd.Process.Kill()
+		cmd.Wait()
+		return ctx.Err()
+	}
+}
+
+// Run upgrades on every upgrade signal until an upgrade succeeds, then
+// calls done, or until ctx is cancelled. Failed upgrades are logged and the
+// process keeps running.
+func (s *Sockets) Run(ctx context.Context, done func()) {
+	if upgradeSignal == nil {
+		s.logger.Warn("Graceful upgrades are not supported on this platform")
+		return
+	}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, upgradeSignal)
+	defer signal.Stop(sigCh)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigCh:
+			s.logger.Info("Received upgrade signal, starting new process")
+			if err := s.Upgrade(ctx); err != nil {
+				s.logger.Error("Upgrade failed, continuing with this process", slog.String("error", err.Error()))
+				continue
+			}
+			done()
+			return
+		}
+	}
+}
+
+// Conn is the shared UDP socket as the listener of one pipeline, which may
+// be restarted, so Close leaves the socket open
+type Conn struct {
+	*net.UDPConn
+}
+
+// Close implements processor.UDPListener without closing the socket
+func (c *Conn) Close() error {
+	return nil
+}
@@ -0,0 +1,138 @@
+//go:build unix
+
+package handoff
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+// envSuccessor makes the test binary act as the new process of an upgrade
+const envSuccessor = "TEMPEST_INFLUX_HANDOFF_TEST_SUCCESSOR"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(envSuccessor) {
+	case "ready":
+		os.Exit(successor())
+	case "fail":
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// successor takes over the sockets, reports readiness and answers one
+// datagram, as the new process of an upgrade would
+func successor() int {
+	s := Inherit(logger.New(&config.Config{}))
+	if !s.Inherited() {
+		return 2
+	}
+	conn, err := s.ListenUDP("127.0.0.1:0")
+	if err != nil {
+		return 3
+	}
+	s.Ready()
+	b := make([]byte, 64)
+	n, addr, err := conn.ReadFrom(b)
+	if err != nil {
+		return 4
+	}
+	conn.WriteTo(b[:n], addr)
+	return 0
+}
+
+func newSockets(t *testing.T, role string) *Sockets {
+	t.Helper()
+	t.Setenv(envSuccessor, role)
+	s := Inherit(logger.New(&config.Config{}))
+	s.exe = os.Args[0]
+	s.args = nil
+	return s
+}
+
+func TestUpgrade(t *testing.T) {
+	s := newSockets(t, "ready")
+	conn, err := s.ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Upgrade(context.Background()); err != nil {
+		t.Fatalf("Upgrade() error = %v", err)
+	}
+
+	// The new process reads from the same socket and echoes to the client
+	client, err := net.DialUDP("udp", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn.Close()
+	s.udp.Close()
+	if _, err := client.Write([]byte("obs_st")); err != nil {
+		t.Fatal(err)
+	}
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b := make([]byte, 64)
+	n, err := client.Read(b)
+	if err != nil {
+		t.Fatalf("Expected the new process to answer: %v", err)
+	}
+	if string(b[:n]) != "obs_st" {
+		t.Errorf("Unexpected answer %q", b[:n])
+	}
+}
+
+func TestUpgradeFails(t *testing.T) {
+	s := newSockets(t, "fail")
+	conn, err := s.ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.udp.Close()
+	if err := s.Upgrade(context.Background()); err == nil {
+		t.Fatal("Expected an error when the new process exits before it is ready")
+	}
+	// The socket stays usable
+	if err := conn.SetReadDeadline(time.Now()); err != nil {
+		t.Errorf("Expected the socket to stay open: %v", err)
+	}
+}
+
+func TestInheritWithoutHandoff(t *testing.T) {
+	s := Inherit(logger.New(&config.Config{}))
+	if s.Inherited() {
+		t.Error("Expected no previous process")
+	}
+	lis, err := s.Listen("http", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	if _, ok := s.listeners["http"]; !ok {
+		t.Error("Expected the listener to be kept for an upgrade")
+	}
+	s.Ready()
+}
+
+func TestConnCloseKeepsSocket(t *testing.T) {
+	s := Inherit(logger.New(&config.Config{}))
+	conn, err := s.ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.udp.Close()
+	conn.Close()
+	again, err := s.ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.LocalAddr().String() != conn.LocalAddr().String() {
+		t.Errorf("Expected the same socket after Close, got %s and %s", conn.LocalAddr(), again.LocalAddr())
+	}
+}
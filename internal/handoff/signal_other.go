@@ -0,0 +1,8 @@
+//go:build !unix
+
+package handoff
+
+import "os"
+
+// upgradeSignal is nil where sockets cannot be passed to a new process
+var upgradeSignal os.Signal
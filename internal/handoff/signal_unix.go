@@ -0,0 +1,11 @@
+//go:build unix
+
+package handoff
+
+import (
+	"os"
+	"syscall"
+)
+
+// upgradeSignal starts an upgrade
+var upgradeSignal os.Signal = syscall.SIGUSR2
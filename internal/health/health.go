@@ -0,0 +1,98 @@
+// Package health serves liveness and readiness probes for Docker
+// HEALTHCHECK and Kubernetes
+package health
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+)
+
+// Paths of the probes
+const (
+	LivePath  = "/healthz"
+	ReadyPath = "/readyz"
+)
+
+var (
+	listening  atomic.Bool
+	lastPacket atomic.Int64 // Unix nanoseconds, 0 before the first packet
+
+	writesMu   sync.Mutex
+	lastWrites = make(map[string]time.Time) // last successful write per InfluxDB output
+)
+
+// SetListening records whether the packet source is bound and receiving
+func SetListening(ok bool) {
+	listening.Store(ok)
+}
+
+// RecordPacket records that a packet was received at t
+func RecordPacket(t time.Time) {
+	lastPacket.Store(t.UnixNano())
+}
+
+// RecordWrite records a successful write to the InfluxDB output at t
+func RecordWrite(output string, t time.Time) {
+	writesMu.Lock()
+	defer writesMu.Unlock()
+	lastWrites[output] = t
+}
+
+// Report is the JSON body of both probes
+type Report struct {
+	Status     string               `json:"status"`
+	Listening  bool                 `json:"listening"`
+	LastPacket *time.Time           `json:"last_packet,omitempty"`
+	LastWrites map[string]time.Time `json:"last_writes"`
+}
+
+// report returns the current state and whether the collector is ready: it
+// is listening and, with Health_Max_Silence, received a packet recently
+func report(cfg *config.Config, now time.Time) (Report, bool) {
+	r := Report{Listening: listening.Load()}
+	if ns := lastPacket.Load(); ns != 0 {
+		t := time.Unix(0, ns).UTC()
+		r.LastPacket = &t
+	}
+	writesMu.Lock()
+	r.LastWrites = maps.Clone(lastWrites)
+	writesMu.Unlock()
+
+	ready := r.Listening
+	if cfg.Health_Max_Silence > 0 && (r.LastPacket == nil || now.Sub(*r.LastPacket) > cfg.Health_Max_Silence) {
+		ready = false
+	}
+	return r, ready
+}
+
+// Register adds the probes to mux. The liveness probe succeeds while the
+// process serves HTTP; the readiness probe fails until it is ready.
+func Register(mux *http.ServeMux, cfg *config.Config) {
+	mux.HandleFunc("GET "+LivePath, func(w http.ResponseWriter, r *http.Request) {
+		rep, _ := report(cfg, time.Now())
+		rep.Status = "ok"
+		writeReport(w, http.StatusOK, rep)
+	})
+	mux.HandleFunc("GET "+ReadyPath, func(w http.ResponseWriter, r *http.Request) {
+		rep, ready := report(cfg, time.Now())
+		if !ready {
+			rep.Status = "unavailable"
+			writeReport(w, http.StatusServiceUnavailable, rep)
+			return
+		}
+		rep.Status = "ok"
+		writeReport(w, http.StatusOK, rep)
+	})
+}
+
+func writeReport(w http.ResponseWriter, status int, rep Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(rep)
+}
@@ -0,0 +1,64 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+)
+
+func probe(t *testing.T, cfg *config.Config, path string) (int, Report) {
+	t.Helper()
+	mux := http.NewServeMux()
+	Register(mux, cfg)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var rep Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &rep); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, rep
+}
+
+func TestProbes(t *testing.T) {
+	cfg := &config.Config{Health_Max_Silence: time.Minute}
+	SetListening(false)
+
+	if code, rep := probe(t, cfg, LivePath); code != http.StatusOK || rep.Status != "ok" {
+		t.Errorf("%s = %d %+v, want 200 ok", LivePath, code, rep)
+	}
+	if code, rep := probe(t, cfg, ReadyPath); code != http.StatusServiceUnavailable || rep.Listening {
+		t.Errorf("%s before listening = %d %+v, want 503", ReadyPath, code, rep)
+	}
+
+	SetListening(true)
+	defer SetListening(false)
+	if code, _ := probe(t, cfg, ReadyPath); code != http.StatusServiceUnavailable {
+		t.Errorf("%s without packets = %d, want 503", ReadyPath, code)
+	}
+
+	packet := time.Now().Add(-10 * time.Second)
+	RecordPacket(packet)
+	RecordWrite("influx", packet)
+	code, rep := probe(t, cfg, ReadyPath)
+	if code != http.StatusOK || rep.Status != "ok" {
+		t.Errorf("%s = %d %+v, want 200 ok", ReadyPath, code, rep)
+	}
+	if rep.LastPacket == nil || !rep.LastPacket.Equal(packet) {
+		t.Errorf("last_packet = %v, want %v", rep.LastPacket, packet)
+	}
+	if !rep.LastWrites["influx"].Equal(packet) {
+		t.Errorf("last_writes = %v", rep.LastWrites)
+	}
+
+	RecordPacket(time.Now().Add(-2 * time.Minute))
+	if code, _ := probe(t, cfg, ReadyPath); code != http.StatusServiceUnavailable {
+		t.Errorf("%s after silence = %d, want 503", ReadyPath, code)
+	}
+	if code, _ := probe(t, &config.Config{}, ReadyPath); code != http.StatusOK {
+		t.Errorf("%s without max silence = %d, want 200", ReadyPath, code)
+	}
+}
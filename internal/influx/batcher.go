@@ -0,0 +1,160 @@
+package influx
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/metrics"
+)
+
+// PointWriter writes points to a destination, see Writer
+type PointWriter interface {
+	Name() string
+	Write(ctx context.Context, points []*Data) error
+}
+
+// Flush triggers
+const (
+	FlushSize     = "size"
+	FlushInterval = "interval"
+	FlushClose    = "close"
+)
+
+// Batcher buffers the points written to it and passes them on to the next
+// writer in a single write once Influx_Flush_Size points are buffered or
+// Influx_Flush_Interval has passed since the first of them, so that a busy
+// collector sends one request per interval instead of one per packet. Large
+// flushes are still split by the limits of Writer.
+//
+// Write returns as soon as the points are buffered; points a flush fails to
+// write are passed to the failed callback instead.
+type Batcher struct {
+	cfg    *config.Config
+	next   PointWriter
+	logger *logger.AppLogger
+	failed func(points []*Data, err error)
+
+	// ctx bounds flushes; it is cancelled when a Shutdown runs out of time
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	pending []*Data
+	timer   *time.Timer
+	closed  bool
+
+	// flushes tracks writes in progress, waited for by Close
+	flushes sync.WaitGroup
+}
+
+// NewBatcher creates a Batcher writing to next
+func NewBatcher(cfg *config.Config, next PointWriter, failed func(points []*Data, err error), appLogger *logger.AppLogger) *Batcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Batcher{
+		cfg:    cfg,
+		next:   next,
+		logger: appLogger,
+		failed: failed,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Name identifies the batcher by the writer it buffers for
+func (b *Batcher) Name() string {
+	return b.next.Name()
+}
+
+// Write buffers points. After Close points are written straight through.
+func (b *Batcher) Write(ctx context.Context, points []*Data) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return b.next.Write(ctx, points)
+	}
+	b.pending = append(b.pending, points...)
+	if b.cfg.Influx_Flush_Size > 0 && len(b.pending) >= b.cfg.Influx_Flush_Size {
+		batch := b.take()
+		b.flushes.Add(1)
+		b.mu.Unlock()
+		go func() {
+			defer b.flushes.Done()
+			b.flush(batch, FlushSize)
+		}()
+		return nil
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.cfg.Influx_Flush_Interval, b.onInterval)
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+// onInterval flushes the points buffered when the interval has passed
+func (b *Batcher) onInterval() {
+	b.mu.Lock()
+	b.timer = nil
+	batch := b.take()
+	if len(batch) == 0 || b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.flushes.Add(1)
+	b.mu.Unlock()
+	defer b.flushes.Done()
+	b.flush(batch, FlushInterval)
+}
+
+// take removes and returns the buffered points and stops the interval
+// timer. The caller holds mu.
+func (b *Batcher) take() []*Data {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	batch := b.pending
+	b.pending = nil
+	return batch
+}
+
+// flush writes batch to the next writer. Flushes outlive the packets that
+// filled them, so they are not bound to the pipeline context, only to the
+// deadline of Shutdown.
+func (b *Batcher) flush(batch []*Data, trigger string) {
+	metrics.InfluxFlushes.WithLabelValues(b.Name(), trigger).Inc()
+	if err := b.next.Write(b.ctx, batch); err != nil && b.failed != nil {
+		b.failed(batch, err)
+	}
+}
+
+// Close writes the buffered points, waits for flushes in progress and
+// closes the next writer if it is an io.Closer
+func (b *Batcher) Close() error {
+	return b.Shutdown(context.Background())
+}
+
+// Shutdown is Close bounded by ctx: once ctx is done the flushes still
+// running are cancelled, and their points go to the failed callback, or to
+// the spool when the next writer is one
+func (b *Batcher) Shutdown(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, b.cancel)
+	defer stop()
+
+	b.mu.Lock()
+	b.closed = true
+	batch := b.take()
+	b.mu.Unlock()
+
+	if len(batch) > 0 {
+		b.flush(batch, FlushClose)
+	}
+	b.flushes.Wait()
+	if c, ok := b.next.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
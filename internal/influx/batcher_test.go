@@ -0,0 +1,140 @@
+package influx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+// recordingWriter records the writes it receives
+type recordingWriter struct {
+	mu     sync.Mutex
+	writes [][]*Data
+	err    error
+	closed bool
+}
+
+func (w *recordingWriter) Name() string { return "influx" }
+
+func (w *recordingWriter) Write(ctx context.Context, points []*Data) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writes = append(w.writes, points)
+	return w.err
+}
+
+func (w *recordingWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func (w *recordingWriter) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.writes)
+}
+
+func TestBatcherFlushesOnSize(t *testing.T) {
+	next := &recordingWriter{}
+	b := NewBatcher(&config.Config{Influx_Flush_Interval: time.Hour, Influx_Flush_Size: 3}, next, nil, logger.New(&config.Config{}))
+
+	for _, temp := range []float64{1, 2, 3, 4} {
+		if err := b.Write(context.Background(), []*Data{newTestPoint("weather", temp)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.flushes.Wait()
+	if next.count() != 1 || len(next.writes[0]) != 3 {
+		t.Fatalf("Expected one write of three points, got %v", next.writes)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if next.count() != 2 || len(next.writes[1]) != 1 || next.writes[1][0].Fields["temp"] != 4.0 {
+		t.Errorf("Expected the remaining point to be written on close, got %v", next.writes)
+	}
+	if !next.closed {
+		t.Error("Expected the next writer to be closed")
+	}
+}
+
+func TestBatcherFlushesOnInterval(t *testing.T) {
+	next := &recordingWriter{}
+	b := NewBatcher(&config.Config{Influx_Flush_Interval: 10 * time.Millisecond, Influx_Flush_Size: 100}, next, nil, logger.New(&config.Config{}))
+	defer b.Close()
+
+	b.Write(context.Background(), []*Data{newTestPoint("weather", 1.0)})
+	b.Write(context.Background(), []*Data{newTestPoint("weather", 2.0)})
+	deadline := time.Now().Add(2 * time.Second)
+	for next.count() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if next.count() != 1 || len(next.writes[0]) != 2 {
+		t.Errorf("Expected one write of both points after the interval, got %v", next.writes)
+	}
+}
+
+func TestBatcherFailed(t *testing.T) {
+	next := &recordingWriter{err: errors.New("connection refused")}
+	var failed []*Data
+	b := NewBatcher(&config.Config{Influx_Flush_Interval: time.Hour, Influx_Flush_Size: 100}, next, func(points []*Data, err error) {
+		failed = points
+	}, logger.New(&config.Config{}))
+
+	if err := b.Write(context.Background(), []*Data{newTestPoint("weather", 1.0)}); err != nil {
+		t.Fatalf("Expected buffering to succeed, got %v", err)
+	}
+	b.Close()
+	if len(failed) != 1 {
+		t.Errorf("Expected the failed point to be reported, got %v", failed)
+	}
+}
+
+func TestBatcherWritesThroughAfterClose(t *testing.T) {
+	next := &recordingWriter{}
+	b := NewBatcher(&config.Config{Influx_Flush_Interval: time.Hour}, next, nil, logger.New(&config.Config{}))
+	b.Close()
+	b.Write(context.Background(), []*Data{newTestPoint("weather", 1.0)})
+	if next.count() != 1 {
+		t.Errorf("Expected a write straight through after close, got %v", next.writes)
+	}
+}
+
+// blockingWriter blocks writes until their context is done
+type blockingWriter struct{}
+
+func (blockingWriter) Name() string { return "influx" }
+
+func (blockingWriter) Write(ctx context.Context, points []*Data) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestBatcherShutdownDeadline(t *testing.T) {
+	var failed error
+	b := NewBatcher(&config.Config{Influx_Flush_Interval: time.Hour}, blockingWriter{},
+		func(points []*Data, err error) { failed = err }, logger.New(&config.Config{}))
+	b.Write(context.Background(), []*Data{newTestPoint("weather", 1.0)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		b.Shutdown(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown did not return once its context was done")
+	}
+	if !errors.Is(failed, context.Canceled) {
+		t.Errorf("Expected the cancelled flush to be reported, got %v", failed)
+	}
+}
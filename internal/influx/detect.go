@@ -0,0 +1,166 @@
+package influx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+const (
+	// AutoAPIPath as Influx_API_Path makes ResolveAPIPath detect the write
+	// endpoint
+	AutoAPIPath = "auto"
+
+	// V2WritePath is the write endpoint of InfluxDB 2.x and InfluxDB Cloud
+	V2WritePath = "/api/v2/write"
+
+	// V1WritePath is the write endpoint of InfluxDB 1.x, which takes the
+	// database in the db parameter
+	V1WritePath = "/write"
+)
+
+// IsV1Path reports whether path is a 1.x write endpoint
+func IsV1Path(path string) bool {
+	return strings.HasSuffix(path, V1WritePath) && !strings.HasSuffix(path, V2WritePath)
+}
+
+// Detect probes the InfluxDB at baseURL and returns the write path matching
+// its version, together with the version reported. It asks /ping, which
+// every version answers without authentication, and falls back to /health.
+func Detect(ctx context.Context, client HTTPClient, baseURL string) (path, version string, err error) {
+	version, err = pingVersion(ctx, client, baseURL)
+	if err != nil || version == "" {
+		version, err = healthVersion(ctx, client, baseURL)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	if strings.HasPrefix(strings.TrimPrefix(version, "v"), "1.") {
+		return V1WritePath, version, nil
+	}
+	return V2WritePath, version, nil
+}
+
+// pingVersion returns the X-Influxdb-Version header of /ping
+func pingVersion(ctx context.Context, client HTTPClient, baseURL string) (string, error) {
+	resp, err := probe(ctx, client, baseURL+"/ping")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	return resp.Header.Get("X-Influxdb-Version"), nil
+}
+
+// healthVersion returns the version field of /health
+func healthVersion(ctx context.Context, client HTTPClient, baseURL string) (string, error) {
+	resp, err := probe(ctx, client, baseURL+"/health")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var health struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&health); err != nil {
+		return "", fmt.Errorf("decoding %s/health: %w", baseURL, err)
+	}
+	if health.Version == "" {
+		return "", fmt.Errorf("%s/health reports no version", baseURL)
+	}
+	return health.Version, nil
+}
+
+// probe sends a GET request and fails on error statuses
+func probe(ctx context.Context, client HTTPClient, target string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("probing %s: %w", target, err)
+	}
+	if resp.StatusCode >= 400 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("probing %s: %s", target, resp.Status)
+	}
+	return resp, nil
+}
+
+// ResolveAPIPath replaces an Influx_API_Path of "auto" with the write path
+// detected at Influx_URL, or the path of Influx_Version when it is set. A
+// write path pasted into Influx_URL, with or without a query string, is
+// removed first. When detection fails the 2.x path is used and a warning is
+// logged, so an InfluxDB that is down at startup does not stop the
+// collector.
+func ResolveAPIPath(ctx context.Context, cfg *config.Config, client HTTPClient, appLogger *logger.AppLogger) {
+	stripWritePath(cfg)
+
+	// The default path follows the version
+	if cfg.Influx_Version == config.InfluxVersion1 && cfg.Influx_API_Path == V2WritePath {
+		cfg.Influx_API_Path = V1WritePath
+	}
+	if cfg.Influx_API_Path != AutoAPIPath {
+		return
+	}
+	base := strings.TrimRight(cfg.Influx_URL, "/")
+	cfg.Influx_URL = base
+
+	switch cfg.Influx_Version {
+	case config.InfluxVersion1:
+		cfg.Influx_API_Path = V1WritePath
+		return
+	case config.InfluxVersion2:
+		cfg.Influx_API_Path = V2WritePath
+		return
+	}
+
+	path, version, err := Detect(ctx, client, base)
+	if err != nil {
+		appLogger.Warn("Could not detect the InfluxDB version, assuming 2.x",
+			"influx_url", base,
+			"error", err.Error())
+		cfg.Influx_API_Path = V2WritePath
+		return
+	}
+	appLogger.Info("Detected InfluxDB",
+		"influx_url", base,
+		"version", version,
+		"api_path", path)
+	cfg.Influx_API_Path = path
+}
+
+// stripWritePath cuts an Influx_URL copied from a complete write request
+// back to the base URL: a trailing write path is removed, and so is a query
+// string, which the configuration check warns about. The query parameters
+// are added to every write from the settings.
+func stripWritePath(cfg *config.Config) {
+	u, err := url.Parse(cfg.Influx_URL)
+	if err != nil {
+		return
+	}
+	path := strings.TrimRight(u.Path, "/")
+	if IsV1Path(path) && cfg.Influx_API_Path == V2WritePath {
+		cfg.Influx_API_Path = V1WritePath
+	}
+	for _, suffix := range []string{V2WritePath, V1WritePath} {
+		if strings.HasSuffix(path, suffix) {
+			path = strings.TrimSuffix(path, suffix)
+			break
+		}
+	}
+	if path == strings.TrimRight(u.Path, "/") && u.RawQuery == "" {
+		return
+	}
+	u.Path, u.RawPath, u.RawQuery = path, "", ""
+	cfg.Influx_URL = u.String()
+}
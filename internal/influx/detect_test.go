@@ -0,0 +1,128 @@
+package influx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		path    string
+		version string
+		wantErr bool
+	}{
+		{"2.x ping", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/ping" {
+				w.Header().Set("X-Influxdb-Version", "v2.7.10")
+				w.WriteHeader(http.StatusNoContent)
+			}
+		}, V2WritePath, "v2.7.10", false},
+		{"1.x ping", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/ping" {
+				w.Header().Set("X-Influxdb-Version", "1.8.10")
+				w.WriteHeader(http.StatusNoContent)
+			}
+		}, V1WritePath, "1.8.10", false},
+		{"health without ping version", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/health" {
+				w.Write([]byte(`{"name":"influxdb","status":"pass","version":"2.0.0"}`))
+			}
+		}, V2WritePath, "2.0.0", false},
+		{"not influxdb", func(w http.ResponseWriter, r *http.Request) {
+			http.NotFound(w, r)
+		}, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			path, version, err := Detect(context.Background(), server.Client(), server.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Detect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if path != tt.path || version != tt.version {
+				t.Errorf("Detect() = %q, %q, want %q, %q", path, version, tt.path, tt.version)
+			}
+		})
+	}
+}
+
+func TestResolveAPIPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Influxdb-Version", "1.8.10")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	appLogger := logger.New(&config.Config{})
+
+	// A write path pasted into the URL is removed before probing
+	cfg := &config.Config{Influx_URL: server.URL + "/api/v2/write", Influx_API_Path: AutoAPIPath}
+	ResolveAPIPath(context.Background(), cfg, server.Client(), appLogger)
+	if cfg.Influx_URL != server.URL || cfg.Influx_API_Path != V1WritePath {
+		t.Errorf("Unexpected resolved URL %q and path %q", cfg.Influx_URL, cfg.Influx_API_Path)
+	}
+
+	// An explicit path is left alone
+	cfg = &config.Config{Influx_URL: server.URL, Influx_API_Path: V2WritePath}
+	ResolveAPIPath(context.Background(), cfg, server.Client(), appLogger)
+	if cfg.Influx_API_Path != V2WritePath {
+		t.Errorf("Expected the explicit path to be kept, got %q", cfg.Influx_API_Path)
+	}
+
+	// A complete write URL is cut back to the base URL
+	cfg = &config.Config{Influx_URL: "https://influx.example.com/api/v2/write?org=o&bucket=b&precision=s", Influx_API_Path: V2WritePath}
+	ResolveAPIPath(context.Background(), cfg, server.Client(), appLogger)
+	if cfg.Influx_URL != "https://influx.example.com" || cfg.Influx_API_Path != V2WritePath {
+		t.Errorf("Unexpected resolved URL %q and path %q", cfg.Influx_URL, cfg.Influx_API_Path)
+	}
+	cfg = &config.Config{Influx_URL: "http://influx.example.com:8086/write?db=weather", Influx_API_Path: V2WritePath}
+	ResolveAPIPath(context.Background(), cfg, server.Client(), appLogger)
+	if cfg.Influx_URL != "http://influx.example.com:8086" || cfg.Influx_API_Path != V1WritePath {
+		t.Errorf("Unexpected resolved URL %q and path %q", cfg.Influx_URL, cfg.Influx_API_Path)
+	}
+
+	// So is a write path without a query string
+	cfg = &config.Config{Influx_URL: "https://influx.example.com/api/v2/write", Influx_API_Path: V2WritePath}
+	ResolveAPIPath(context.Background(), cfg, server.Client(), appLogger)
+	if cfg.Influx_URL != "https://influx.example.com" || cfg.Influx_API_Path != V2WritePath {
+		t.Errorf("Unexpected resolved URL %q and path %q", cfg.Influx_URL, cfg.Influx_API_Path)
+	}
+
+	// Detection failures fall back to 2.x
+	server.Close()
+	cfg = &config.Config{Influx_URL: server.URL, Influx_API_Path: AutoAPIPath}
+	ResolveAPIPath(context.Background(), cfg, server.Client(), appLogger)
+	if cfg.Influx_API_Path != V2WritePath {
+		t.Errorf("Expected fallback to %s, got %q", V2WritePath, cfg.Influx_API_Path)
+	}
+}
+
+func TestWriterV1Database(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Path + "?" + r.URL.RawQuery
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{Influx_URL: server.URL, Influx_API_Path: V1WritePath}
+	w, err := NewWriter(cfg, server.Client(), logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	if err := w.Write(context.Background(), []*Data{newTestPoint("weather", 1.0)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if query != "/write?db=weather&org=&precision=s" {
+		t.Errorf("Unexpected request %s", query)
+	}
+}
@@ -0,0 +1,79 @@
+package influx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteError describes a write request InfluxDB answered with an error status
+type WriteError struct {
+	StatusCode int
+	Status     string
+	Message    string
+	RetryAfter time.Duration
+}
+
+// Error implements error
+func (e *WriteError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("InfluxDB returned error status %s", e.Status)
+	}
+	return fmt.Sprintf("InfluxDB returned error status %s: %s", e.Status, e.Message)
+}
+
+// RateLimited reports whether InfluxDB asked the writer to slow down
+func (e *WriteError) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// Retryable reports whether sending the same write again may succeed. Client
+// errors such as malformed line protocol or missing permissions are final.
+func (e *WriteError) Retryable() bool {
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests, e.StatusCode == http.StatusRequestTimeout:
+		return true
+	case e.StatusCode >= 400 && e.StatusCode < 500:
+		return false
+	default:
+		return true
+	}
+}
+
+// Retryable reports whether err may go away when the write is repeated.
+// Errors that do not say otherwise, such as timeouts and connection
+// failures, are assumed to be transient. That includes context.Canceled and
+// context.DeadlineExceeded: the write was abandoned, not rejected, so the
+// spool keeps it; callers stop retrying once their own context is done.
+func Retryable(err error) bool {
+	var r interface{ Retryable() bool }
+	if errors.As(err, &r) {
+		return r.Retryable()
+	}
+	return true
+}
+
+// parseRetryAfter decodes a Retry-After header given either as delay seconds
+// or as an HTTP date. It returns fallback when the header is absent or invalid.
+func parseRetryAfter(value string, now time.Time, fallback time.Duration) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fallback
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return fallback
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return fallback
+}
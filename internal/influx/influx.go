@@ -1,45 +1,307 @@
 package influx
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// ErrInvalidPoint is returned for points that cannot be marshaled into valid line protocol
+var ErrInvalidPoint = errors.New("invalid point")
+
 // Data represents data to be sent to InfluxDB
 type Data struct {
-	Timestamp int64
-	Name      string
-	Bucket    string
-	Tags      map[string]string
-	Fields    map[string]string
+	// ID correlates the point with the packet it was parsed from; it is
+	// only used for logging and never written to InfluxDB
+	ID string
+	// ReportType is the Tempest report the point was parsed from, e.g.
+	// "obs_st"; like ID it is metadata and not written
+	ReportType string
+	Timestamp  int64
+	// Nanos is a sub-second offset added to Timestamp; points with a
+	// non-zero offset are written with nanosecond precision
+	Nanos  int64
+	Name   string
+	Bucket string
+	Tags   map[string]string
+	Fields Fields
+}
+
+// Fields are the values of a point by field name. Values are float64,
+// int64, bool or string and are written as the line protocol float,
+// integer, boolean or string of the same type, except for the integers
+// DefaultSchema writes as floats.
+type Fields map[string]any
+
+// MarshalJSON encodes numbers as JSON numbers, floats with a decimal point
+// so that UnmarshalJSON restores their type
+func (f Fields) MarshalJSON() ([]byte, error) {
+	b := []byte{'{'}
+	for i, name := range sortedKeys(f) {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendQuote(b, name)
+		b = append(b, ':')
+		switch v := f[name].(type) {
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("field %s is not a finite number", name)
+			}
+			n := len(b)
+			b = strconv.AppendFloat(b, v, 'f', -1, 64)
+			if !bytes.ContainsRune(b[n:], '.') {
+				b = append(b, ".0"...)
+			}
+		case int64:
+			b = strconv.AppendInt(b, v, 10)
+		default:
+			value, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, value...)
+		}
+	}
+	return append(b, '}'), nil
+}
+
+// UnmarshalJSON decodes numbers with a decimal point or exponent as float64
+// and others as int64. Earlier versions stored every value as a string;
+// those of fields DefaultSchema defines as numbers or booleans are
+// converted back.
+func (f *Fields) UnmarshalJSON(data []byte) error {
+	var raw map[string]any
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		*f = nil
+		return nil
+	}
+
+	fields := make(Fields, len(raw))
+	for name, value := range raw {
+		switch v := value.(type) {
+		case json.Number:
+			if i, err := v.Int64(); err == nil && !strings.ContainsAny(v.String(), ".eE") {
+				fields[name] = i
+			} else if x, err := v.Float64(); err == nil {
+				fields[name] = x
+			} else {
+				return fmt.Errorf("field %s: %w", name, err)
+			}
+		case string:
+			fields[name] = v
+			if t := DefaultSchema.types[name]; t != TypeString && t != TypeUnknown {
+				if converted, err := parseLiteral(v, t); err == nil {
+					fields[name] = converted
+				}
+			}
+		case bool:
+			fields[name] = v
+		default:
+			return fmt.Errorf("field %s has unsupported value %v", name, value)
+		}
+	}
+	*f = fields
+	return nil
 }
 
 // New creates a new InfluxData struct
 func New() *Data {
 	return &Data{
 		Tags:   make(map[string]string),
-		Fields: make(map[string]string),
+		Fields: make(Fields),
 	}
 }
 
-// Marshal converts InfluxData into Influx wire protocol
-func (m *Data) Marshal() string {
-	tags := make([]string, 0, len(m.Tags))
-	for tag, value := range m.Tags {
-		tags = append(tags, tag+"="+value)
+// Float returns the numeric field name as a float64 and whether it is set
+// to a number
+func (m *Data) Float(name string) (float64, bool) {
+	switch v := m.Fields[name].(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
 	}
-	sort.Strings(tags)
+	return 0, false
+}
+
+// SetNumber sets the numeric field name to v. A field holding an integer
+// stays one, with v rounded.
+func (m *Data) SetNumber(name string, v float64) {
+	if _, ok := m.Fields[name].(int64); ok {
+		m.Fields[name] = int64(math.Round(v))
+		return
+	}
+	m.Fields[name] = v
+}
 
-	fields := make([]string, 0, len(m.Fields))
+// Round rounds v to the given number of decimals, the precision fields
+// derived from it are written with
+func Round(v float64, decimals int) float64 {
+	scale := math.Pow10(decimals)
+	return math.Round(v*scale) / scale
+}
+
+// Validate checks that the point can be marshaled into valid line protocol
+func (m *Data) Validate() error {
+	if m.Name == "" {
+		return fmt.Errorf("%w: missing measurement name", ErrInvalidPoint)
+	}
+	if strings.ContainsAny(m.Name, "\n\r") {
+		return fmt.Errorf("%w: measurement name contains a newline", ErrInvalidPoint)
+	}
+	if len(m.Fields) == 0 {
+		return fmt.Errorf("%w: %s has no fields", ErrInvalidPoint, m.Name)
+	}
 	for field, value := range m.Fields {
-		fields = append(fields, field+"="+value)
+		if field == "" || value == "" {
+			return fmt.Errorf("%w: %s has an empty field key or value", ErrInvalidPoint, m.Name)
+		}
+		if strings.ContainsAny(field, "\n\r") {
+			return fmt.Errorf("%w: %s field key %q contains a newline", ErrInvalidPoint, m.Name, field)
+		}
+		switch v := value.(type) {
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return fmt.Errorf("%w: %s field %s is not a finite number", ErrInvalidPoint, m.Name, field)
+			}
+		case int64, bool, string:
+		default:
+			return fmt.Errorf("%w: %s field %s has unsupported type %T", ErrInvalidPoint, m.Name, field, value)
+		}
+	}
+	for tag, value := range m.Tags {
+		if tag == "" || value == "" {
+			return fmt.Errorf("%w: %s has an empty tag key or value", ErrInvalidPoint, m.Name)
+		}
+		if strings.ContainsAny(tag+value, "\n\r") {
+			return fmt.Errorf("%w: %s tag %q contains a newline", ErrInvalidPoint, m.Name, tag)
+		}
+	}
+	return nil
+}
+
+// Line protocol escapers, see
+// https://docs.influxdata.com/influxdb/v2/reference/syntax/line-protocol/#special-characters
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	stringEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// isLiteral reports whether a field value is a line protocol float,
+// integer, unsigned integer or boolean literal that must not be quoted
+func isLiteral(value string) bool {
+	if isBoolean(value) {
+		return true
+	}
+	if n := len(value); n > 1 && (value[n-1] == 'i' || value[n-1] == 'u') {
+		_, err := strconv.ParseInt(value[:n-1], 10, 64)
+		if err == nil {
+			return true
+		}
+		_, err = strconv.ParseUint(value[:n-1], 10, 64)
+		return err == nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	return err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) && !strings.ContainsAny(value, "xXpP_")
+}
+
+// fieldValue returns the value of field in line protocol form. Integers
+// DefaultSchema writes as floats are written without the integer suffix.
+func fieldValue(field string, value any) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		if DefaultSchema.WriteType(field) == TypeFloat {
+			return strconv.FormatInt(v, 10)
+		}
+		return strconv.FormatInt(v, 10) + "i"
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return `"` + stringEscaper.Replace(v) + `"`
+	default:
+		return `"` + stringEscaper.Replace(fmt.Sprint(v)) + `"`
+	}
+}
+
+// Precision is the timestamp precision of marshaled points, as passed in the
+// precision parameter of the write API
+type Precision string
+
+const (
+	PrecisionSeconds     Precision = "s"
+	PrecisionNanoseconds Precision = "ns"
+)
+
+// PrecisionOf returns the precision needed to write points without losing
+// their sub-second offsets
+func PrecisionOf(points []*Data) Precision {
+	for _, m := range points {
+		if m.Nanos != 0 {
+			return PrecisionNanoseconds
+		}
+	}
+	return PrecisionSeconds
+}
+
+// Marshal converts InfluxData into Influx wire protocol with second precision
+func (m *Data) Marshal() string {
+	return m.MarshalPrecision(PrecisionSeconds)
+}
+
+// MarshalPrecision converts InfluxData into Influx wire protocol, escaping
+// special characters in the measurement, keys, tag values and string fields.
+// With PrecisionNanoseconds the timestamp includes the Nanos offset.
+func (m *Data) MarshalPrecision(precision Precision) string {
+	var b strings.Builder
+	b.WriteString(measurementEscaper.Replace(m.Name))
+
+	for _, tag := range sortedKeys(m.Tags) {
+		b.WriteByte(',')
+		b.WriteString(keyEscaper.Replace(tag))
+		b.WriteByte('=')
+		b.WriteString(keyEscaper.Replace(m.Tags[tag]))
 	}
-	sort.Strings(fields)
 
-	return fmt.Sprintf("%s,%s %s %d\n",
-		m.Name,
-		strings.Join(tags, ","),
-		strings.Join(fields, ","),
-		m.Timestamp)
+	for i, field := range sortedKeys(m.Fields) {
+		if i == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(keyEscaper.Replace(field))
+		b.WriteByte('=')
+		b.WriteString(fieldValue(field, m.Fields[field]))
+	}
+
+	if precision == PrecisionNanoseconds {
+		fmt.Fprintf(&b, " %d\n", m.Timestamp*int64(time.Second)+m.Nanos)
+	} else {
+		fmt.Fprintf(&b, " %d\n", m.Timestamp)
+	}
+	return b.String()
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
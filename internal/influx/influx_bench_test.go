@@ -10,11 +10,11 @@ func BenchmarkInfluxDataMarshal(b *testing.B) {
 	m.Name = "weather"
 	m.Tags["station"] = "ST-123456"
 	m.Tags["location"] = "backyard"
-	m.Fields["temp"] = "25.50"
-	m.Fields["humidity"] = "60.00"
-	m.Fields["pressure"] = "1013.25"
-	m.Fields["wind_speed"] = "5.50"
-	m.Fields["wind_direction"] = "180"
+	m.Fields["temp"] = 25.5
+	m.Fields["humidity"] = 60.0
+	m.Fields["pressure"] = 1013.25
+	m.Fields["wind_speed"] = 5.5
+	m.Fields["wind_direction"] = int64(180)
 	m.Timestamp = 1640995200
 
 	b.ResetTimer()
@@ -34,7 +34,7 @@ func BenchmarkInfluxDataMarshalLargeDataset(b *testing.B) {
 
 	// Add many fields
 	for i := 0; i < 20; i++ {
-		m.Fields[string(rune('A'+i))] = "123.45"
+		m.Fields[string(rune('A'+i))] = 123.45
 	}
 
 	m.Timestamp = 1640995200
@@ -56,8 +56,8 @@ func BenchmarkInfluxDataMarshalParallel(b *testing.B) {
 	m := New()
 	m.Name = "weather"
 	m.Tags["station"] = "ST-123456"
-	m.Fields["temp"] = "25.50"
-	m.Fields["humidity"] = "60.00"
+	m.Fields["temp"] = 25.5
+	m.Fields["humidity"] = 60.0
 	m.Timestamp = 1640995200
 
 	b.ResetTimer()
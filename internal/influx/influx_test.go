@@ -1,6 +1,11 @@
 package influx
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"testing"
 )
 
@@ -9,14 +14,249 @@ func TestInfluxDataMarshal(t *testing.T) {
 	m := New()
 	m.Name = "weather"
 	m.Tags["station"] = "ST-123"
-	m.Fields["temp"] = "25.5"
-	m.Fields["humidity"] = "60.0"
+	m.Fields["temp"] = 25.5
+	m.Fields["humidity"] = 60.0
 	m.Timestamp = 1640995200
 
 	line := m.Marshal()
-	expected := "weather,station=ST-123 humidity=60.0,temp=25.5 1640995200\n"
+	expected := "weather,station=ST-123 humidity=60,temp=25.5 1640995200\n"
 
 	if line != expected {
 		t.Errorf("InfluxData.Marshal() = %v, want %v", line, expected)
 	}
 }
+
+func TestInfluxDataMarshalPrecision(t *testing.T) {
+	m := New()
+	m.Name = "weather"
+	m.Fields["rapid_wind_speed"] = 5.5
+	m.Timestamp = 1640995200
+	m.Nanos = 250_000
+
+	if got := PrecisionOf([]*Data{New(), m}); got != PrecisionNanoseconds {
+		t.Errorf("PrecisionOf() = %s, want ns", got)
+	}
+	if got := PrecisionOf([]*Data{New()}); got != PrecisionSeconds {
+		t.Errorf("PrecisionOf() = %s, want s", got)
+	}
+
+	if got, want := m.MarshalPrecision(PrecisionNanoseconds), "weather rapid_wind_speed=5.5 1640995200000250000\n"; got != want {
+		t.Errorf("MarshalPrecision(ns) = %q, want %q", got, want)
+	}
+	if got, want := m.Marshal(), "weather rapid_wind_speed=5.5 1640995200\n"; got != want {
+		t.Errorf("Marshal() = %q, want %q", got, want)
+	}
+}
+
+func TestInfluxDataMarshalEscaping(t *testing.T) {
+	tests := []struct {
+		name   string
+		point  func(m *Data)
+		expect string
+	}{
+		{
+			name:   "no tags",
+			point:  func(m *Data) { delete(m.Tags, "station") },
+			expect: "weather temp=25.5 1640995200\n",
+		},
+		{
+			name:   "measurement with comma and space",
+			point:  func(m *Data) { m.Name = "my weather,v2" },
+			expect: `my\ weather\,v2,station=ST-123 temp=25.5 1640995200` + "\n",
+		},
+		{
+			name:   "equals sign in measurement is not escaped",
+			point:  func(m *Data) { m.Name = "a=b" },
+			expect: "a=b,station=ST-123 temp=25.5 1640995200\n",
+		},
+		{
+			name:   "tag value with space, comma and equals",
+			point:  func(m *Data) { m.Tags["station"] = "Back Yard, North=1" },
+			expect: `weather,station=Back\ Yard\,\ North\=1 temp=25.5 1640995200` + "\n",
+		},
+		{
+			name:   "tag and field keys",
+			point:  func(m *Data) { m.Tags["site name"] = "home"; m.Fields["wind,avg"] = 2.3 },
+			expect: `weather,site\ name=home,station=ST-123 temp=25.5,wind\,avg=2.3 1640995200` + "\n",
+		},
+		{
+			name:   "tags sorted by key",
+			point:  func(m *Data) { m.Tags["a"] = "1"; m.Tags["station_b"] = "2" },
+			expect: "weather,a=1,station=ST-123,station_b=2 temp=25.5 1640995200\n",
+		},
+		{
+			name:   "string field with quotes and backslash",
+			point:  func(m *Data) { m.Fields["note"] = `say "hi" C:\path` },
+			expect: `weather,station=ST-123 note="say \"hi\" C:\\path",temp=25.5 1640995200` + "\n",
+		},
+		{
+			name: "floats, integers and booleans are not quoted",
+			point: func(m *Data) {
+				m.Fields = Fields{"a": -1500.0, "b": int64(42), "c": 0.125, "d": true, "e": false, "f": 12.0}
+			},
+			expect: "weather,station=ST-123 a=-1500,b=42i,c=0.125,d=true,e=false,f=12 1640995200\n",
+		},
+		{
+			name:   "strings are quoted",
+			point:  func(m *Data) { m.Fields = Fields{"a": "NaN", "b": "yes", "c": "12", "d": "true"} },
+			expect: `weather,station=ST-123 a="NaN",b="yes",c="12",d="true" 1640995200` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New()
+			m.Name = "weather"
+			m.Tags["station"] = "ST-123"
+			m.Fields["temp"] = 25.5
+			m.Timestamp = 1640995200
+			tt.point(m)
+
+			if got := m.Marshal(); got != tt.expect {
+				t.Errorf("Marshal() = %q, want %q", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestInfluxDataFloat(t *testing.T) {
+	m := New()
+	m.Fields["temp"] = 25.5
+	m.Fields["summary"] = "Sunny"
+
+	if v, ok := m.Float("temp"); !ok || v != 25.5 {
+		t.Errorf("Float(temp) = %v, %v, want 25.5, true", v, ok)
+	}
+	if _, ok := m.Float("summary"); ok {
+		t.Error("Expected a string field not to be a number")
+	}
+	if _, ok := m.Float("missing"); ok {
+		t.Error("Expected a missing field not to be a number")
+	}
+}
+
+func TestInfluxDataSetNumber(t *testing.T) {
+	m := New()
+	m.Fields["temp"] = 25.5
+	m.Fields["wind_direction"] = int64(270)
+
+	m.SetNumber("temp", 26.25)
+	m.SetNumber("wind_direction", 272.6)
+	if m.Fields["temp"] != 26.25 || m.Fields["wind_direction"] != int64(273) {
+		t.Errorf("Unexpected fields %v", m.Fields)
+	}
+}
+
+func TestRound(t *testing.T) {
+	if got := Round(12.3456, 2); got != 12.35 {
+		t.Errorf("Round(12.3456, 2) = %v, want 12.35", got)
+	}
+	if got := Round(-0.125, 1); got != -0.1 {
+		t.Errorf("Round(-0.125, 1) = %v, want -0.1", got)
+	}
+}
+
+func TestFieldsJSON(t *testing.T) {
+	fields := Fields{"temp": 21.0, "strike_count": int64(3), "is_daytime": true, "summary": "Sunny"}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := `{"is_daytime":true,"strike_count":3,"summary":"Sunny","temp":21.0}`; string(b) != want {
+		t.Errorf("Marshal() = %s, want %s", b, want)
+	}
+
+	var decoded Fields
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	for k, v := range fields {
+		if decoded[k] != v {
+			t.Errorf("Field %s = %#v, want %#v", k, decoded[k], v)
+		}
+	}
+
+	// Earlier versions stored every value as a string
+	var legacy Fields
+	if err := json.Unmarshal([]byte(`{"temp":"21.50","strike_count":"3","rain_nc_analysis":"1i","obs_18":"1.5","summary":"Sunny"}`), &legacy); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := Fields{"temp": 21.5, "strike_count": int64(3), "rain_nc_analysis": int64(1), "obs_18": "1.5", "summary": "Sunny"}
+	for k, v := range want {
+		if legacy[k] != v {
+			t.Errorf("Legacy field %s = %#v, want %#v", k, legacy[k], v)
+		}
+	}
+
+	if _, err := json.Marshal(Fields{"temp": math.NaN()}); err == nil {
+		t.Error("Expected NaN not to be encoded")
+	}
+}
+
+func TestInfluxDataValidate(t *testing.T) {
+	valid := func() *Data {
+		m := New()
+		m.Name = "weather"
+		m.Tags["station"] = "ST-123"
+		m.Fields["temp"] = 25.5
+		return m
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(m *Data)
+		wantErr bool
+	}{
+		{"valid", func(m *Data) {}, false},
+		{"missing name", func(m *Data) { m.Name = "" }, true},
+		{"no fields", func(m *Data) { m.Fields = Fields{} }, true},
+		{"empty field value", func(m *Data) { m.Fields["temp"] = "" }, true},
+		{"empty tag value", func(m *Data) { m.Tags["station"] = "" }, true},
+		{"newline in measurement", func(m *Data) { m.Name = "weather\nevil" }, true},
+		{"newline in tag value", func(m *Data) { m.Tags["station"] = "ST\n123" }, true},
+		{"newline in field key", func(m *Data) { m.Fields["te\nmp"] = 1.0 }, true},
+		{"infinite field value", func(m *Data) { m.Fields["temp"] = math.Inf(1) }, true},
+		{"unsupported field type", func(m *Data) { m.Fields["count"] = 3 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := valid()
+			tt.modify(m)
+			err := m.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidPoint) {
+				t.Errorf("Expected ErrInvalidPoint, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteErrorRetryable(t *testing.T) {
+	tests := map[int]bool{400: false, 401: false, 404: false, 408: true, 413: false, 429: true, 500: true, 503: true}
+	for code, want := range tests {
+		if got := (&WriteError{StatusCode: code}).Retryable(); got != want {
+			t.Errorf("WriteError{%d}.Retryable() = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("connection refused"), true},
+		{context.Canceled, true},
+		{fmt.Errorf("posting: %w", context.DeadlineExceeded), true},
+		{fmt.Errorf("posting: %w", &WriteError{StatusCode: 400}), false},
+		{&WriteError{StatusCode: 503}, true},
+	}
+	for _, tt := range tests {
+		if got := Retryable(tt.err); got != tt.want {
+			t.Errorf("Retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package influx
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+)
+
+const (
+	// QueryAPIPath is the InfluxDB v2 endpoint for Flux queries
+	QueryAPIPath = "/api/v2/query"
+
+	// V1QueryPath is the InfluxDB 1.x endpoint for InfluxQL queries
+	V1QueryPath = "/query"
+)
+
+// Record is one row of a query result, keyed by column name
+type Record map[string]string
+
+// QueryClient runs queries against the configured InfluxDB, with the
+// credentials of Influx_Bucket
+type QueryClient struct {
+	cfg    *config.Config
+	client HTTPClient
+	url    *url.URL
+}
+
+// NewQueryClient creates a QueryClient for the InfluxDB instance of cfg
+func NewQueryClient(cfg *config.Config, client HTTPClient) (*QueryClient, error) {
+	queryURL, err := url.Parse(cfg.Influx_URL + QueryAPIPath)
+	if err != nil {
+		return nil, err
+	}
+	query := queryURL.Query()
+	query.Set("org", cfg.InfluxOrg(cfg.Influx_Bucket))
+	queryURL.RawQuery = query.Encode()
+
+	return &QueryClient{cfg: cfg, client: client, url: queryURL}, nil
+}
+
+// Query runs flux and returns the rows of all result tables
+func (q *QueryClient) Query(ctx context.Context, flux string) ([]Record, error) {
+	request, err := http.NewRequestWithContext(ctx, "POST", q.url.String(), strings.NewReader(flux))
+	if err != nil {
+		return nil, fmt.Errorf("creating request for %s: %w", q.url.Redacted(), err)
+	}
+	request.Header.Set("Content-Type", "application/vnd.flux")
+	return q.do(request, isHeader)
+}
+
+// QueryInfluxQL runs an InfluxQL query against the InfluxDB 1.x database
+// named by Influx_Bucket and returns the rows of all series. The
+// measurement is returned in the name column and times in nanoseconds
+// since the epoch.
+func (q *QueryClient) QueryInfluxQL(ctx context.Context, influxql string) ([]Record, error) {
+	queryURL, err := url.Parse(q.cfg.Influx_URL + V1QueryPath)
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("db", q.cfg.Influx_Bucket)
+	if q.cfg.Influx_Retention_Policy != "" {
+		params.Set("rp", q.cfg.Influx_Retention_Policy)
+	}
+	params.Set("q", influxql)
+	queryURL.RawQuery = params.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, "GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request for %s: %w", queryURL.Redacted(), err)
+	}
+	return q.do(request, isV1Header)
+}
+
+// do sends a query request and parses the CSV response, whose tables start
+// with a row recognised by header
+func (q *QueryClient) do(request *http.Request, header func([]string) bool) ([]Record, error) {
+	authorize(q.cfg, request, q.cfg.Influx_Bucket)
+	request.Header.Set("Accept", "application/csv")
+
+	resp, err := q.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("querying %s: %w", q.cfg.Influx_URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &WriteError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Message:    strings.TrimSpace(string(message)),
+		}
+	}
+	return parseCSV(resp.Body, header)
+}
+
+// parseCSV reads the CSV response of a query. Each result table starts with
+// its own header row, recognised by isHeader; annotation rows are skipped.
+func parseCSV(r io.Reader, isHeader func([]string) bool) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+
+	var records []Record
+	var header []string
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading query result: %w", err)
+		}
+		if isHeader(row) {
+			header = row
+			continue
+		}
+		if header == nil {
+			return nil, errors.New("reading query result: missing header row")
+		}
+		record := make(Record, len(header))
+		for i, name := range header {
+			if i < len(row) && name != "" {
+				record[name] = row[i]
+			}
+		}
+		records = append(records, record)
+	}
+}
+
+// isHeader reports whether row names the columns of a Flux result table,
+// by its result and table columns
+func isHeader(row []string) bool {
+	return len(row) >= 3 && row[1] == "result" && row[2] == "table"
+}
+
+// isV1Header reports whether row names the columns of an InfluxQL series,
+// which start with name, tags and time
+func isV1Header(row []string) bool {
+	return len(row) >= 3 && row[0] == "name" && row[1] == "tags" && row[2] == "time"
+}
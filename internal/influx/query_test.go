@@ -0,0 +1,95 @@
+package influx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+)
+
+const testQueryResult = `#datatype,string,long,dateTime:RFC3339,double
+#group,false,false,false,false
+#default,_result,,,
+,result,table,_time,_value
+,_result,0,2024-03-01T07:00:00Z,12.5
+,_result,0,2024-03-02T07:00:00Z,13
+
+,result,table,_time,_value,station
+,_result,1,2024-03-01T07:00:00Z,4,ST-2
+`
+
+func TestQueryClient(t *testing.T) {
+	var flux string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != QueryAPIPath || r.URL.Query().Get("org") != "test-org" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		if r.Header.Get("Content-Type") != "application/vnd.flux" || r.Header.Get("Authorization") != "Token test-token" {
+			t.Errorf("Unexpected headers %v", r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		flux = string(body)
+		io.WriteString(w, testQueryResult)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{Influx_URL: server.URL, Influx_Org: "test-org", Influx_Token: "test-token"}
+	q, err := NewQueryClient(cfg, server.Client())
+	if err != nil {
+		t.Fatalf("NewQueryClient() error = %v", err)
+	}
+
+	records, err := q.Query(context.Background(), `from(bucket: "b")`)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if flux != `from(bucket: "b")` {
+		t.Errorf("Expected query as body, got %q", flux)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records from two tables, got %d: %v", len(records), records)
+	}
+	if records[1]["_value"] != "13" || records[2]["station"] != "ST-2" {
+		t.Errorf("Unexpected records %v", records)
+	}
+}
+
+func TestQueryClientError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"message":"compilation failed"}`)
+	}))
+	defer server.Close()
+
+	q, _ := NewQueryClient(&config.Config{Influx_URL: server.URL}, server.Client())
+	_, err := q.Query(context.Background(), "bad")
+	if err == nil || !strings.Contains(err.Error(), "compilation failed") {
+		t.Errorf("Expected error with InfluxDB message, got %v", err)
+	}
+}
+
+func TestQueryInfluxQL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != V1QueryPath || r.URL.Query().Get("db") != "weather" || r.URL.Query().Get("q") != "SELECT * FROM weather" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		if r.Header.Get("Accept") != "application/csv" {
+			t.Errorf("Unexpected headers %v", r.Header)
+		}
+		io.WriteString(w, "name,tags,time,station,temp\nweather,,1709276400000000000,ST-1,12.5\nweather,,1709280000000000000,ST-1,13\n")
+	}))
+	defer server.Close()
+
+	q, _ := NewQueryClient(&config.Config{Influx_URL: server.URL, Influx_Bucket: "weather"}, server.Client())
+	records, err := q.QueryInfluxQL(context.Background(), "SELECT * FROM weather")
+	if err != nil {
+		t.Fatalf("QueryInfluxQL() error = %v", err)
+	}
+	if len(records) != 2 || records[1]["temp"] != "13" || records[0]["name"] != "weather" {
+		t.Errorf("Unexpected records %v", records)
+	}
+}
@@ -0,0 +1,315 @@
+package influx
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// FieldType is the InfluxDB type of a field value
+type FieldType int
+
+const (
+	TypeUnknown FieldType = iota
+	TypeFloat
+	TypeInteger
+	TypeString
+	TypeBoolean
+)
+
+// String returns the InfluxDB name of the type
+func (t FieldType) String() string {
+	switch t {
+	case TypeFloat:
+		return "float"
+	case TypeInteger:
+		return "integer"
+	case TypeString:
+		return "string"
+	case TypeBoolean:
+		return "boolean"
+	default:
+		return "unknown"
+	}
+}
+
+// canonicalFields fixes the type of every field the collector writes.
+// Measurements are floats; counts, codes and whole-degree directions are
+// integers.
+var canonicalFields = map[string]FieldType{
+	"battery":                   TypeFloat,
+	"dew_point":                 TypeFloat,
+	"illuminance":               TypeInteger,
+	"p":                         TypeFloat,
+	"p_raw":                     TypeFloat,
+	"precipitation":             TypeFloat,
+	"precipitation_type":        TypeInteger,
+	"relative_humidity":         TypeFloat,
+	"solar_radiation":           TypeInteger,
+	"strike_count":              TypeInteger,
+	"strike_distance":           TypeFloat,
+	"strike_energy":             TypeInteger,
+	"temp":                      TypeFloat,
+	"uv":                        TypeFloat,
+	"wind_avg":                  TypeFloat,
+	"wind_direction":            TypeInteger,
+	"wind_gust":                 TypeFloat,
+	"wind_lull":                 TypeFloat,
+	"rapid_wind_speed":          TypeFloat,
+	"rapid_wind_direction":      TypeInteger,
+	"rapid_wind_speed_min":      TypeFloat,
+	"rapid_wind_speed_avg":      TypeFloat,
+	"rapid_wind_speed_max":      TypeFloat,
+	"rapid_wind_gust_direction": TypeInteger,
+	"rapid_wind_samples":        TypeInteger,
+	"wind_avg_smoothed":         TypeFloat,
+	"rapid_wind_speed_smoothed": TypeFloat,
+	"rain_today":                TypeFloat,
+	"et0_rate":                  TypeFloat,
+	"et0_last_hour":             TypeFloat,
+	"et0_today":                 TypeFloat,
+	"rain_last_hour":            TypeFloat,
+	"rain_rate_mm_h":            TypeFloat,
+	"rain_nc":                   TypeFloat,
+	"rain_udp":                  TypeFloat,
+	"rain_nc_correction":        TypeFloat,
+	"rain_nc_analysis":          TypeInteger,
+	"temp_min_today":            TypeFloat,
+	"temp_max_today":            TypeFloat,
+	"heating_degree_days":       TypeFloat,
+	"cooling_degree_days":       TypeFloat,
+	"is_daytime":                TypeBoolean,
+	"solar_elevation":           TypeFloat,
+	"solar_azimuth":             TypeFloat,
+	"clear_sky_radiation":       TypeFloat,
+	"clear_sky_ratio":           TypeFloat,
+	"cloud_base_height":         TypeFloat,
+	"summary":                   TypeString,
+	"conditions":                TypeString,
+	"feels_like":                TypeFloat,
+	"humidex":                   TypeFloat,
+	"heat_index":                TypeFloat,
+	"wind_chill":                TypeFloat,
+	"is_snow_likely":            TypeBoolean,
+	"sea_level_pressure":        TypeFloat,
+	"pressure_trend":            TypeFloat,
+	"precip_probability":        TypeFloat,
+	"temp_max":                  TypeFloat,
+	"temp_min":                  TypeFloat,
+	"sunrise":                   TypeInteger,
+	"sunset":                    TypeInteger,
+	// Annotation text of sunrise and sunset points and lifecycle events
+	"text":    TypeString,
+	"reason":  TypeString,
+	"version": TypeString,
+	"commit":  TypeString,
+	// Hubs report a string and devices an integer
+	"firmware_revision": TypeString,
+	"reset_flags":       TypeString,
+}
+
+// legacyFloatFields are the integer fields earlier versions wrote as floats.
+// They are written as floats unless enabled with SetIntegerFields, so that
+// existing buckets keep accepting them.
+var legacyFloatFields = map[string]bool{
+	"illuminance":               true,
+	"precipitation_type":        true,
+	"solar_radiation":           true,
+	"strike_count":              true,
+	"strike_energy":             true,
+	"wind_direction":            true,
+	"rapid_wind_direction":      true,
+	"rapid_wind_gust_direction": true,
+	"rapid_wind_samples":        true,
+}
+
+// fieldAliases maps names used by other report types to the canonical name
+var fieldAliases = map[string]string{
+	"air_temperature":  "temp",
+	"temperature":      "temp",
+	"station_pressure": "p",
+	"pressure":         "p",
+	"humidity":         "relative_humidity",
+	"lightning_count":  "strike_count",
+}
+
+// Schema enforces one canonical name and type per field so that report
+// types sharing a bucket never write conflicting fields. Fields it does not
+// define keep the type of the first value seen.
+type Schema struct {
+	types   map[string]FieldType
+	aliases map[string]string
+
+	mu      sync.RWMutex
+	learned map[string]FieldType
+
+	integers atomic.Bool
+}
+
+// NewSchema creates a Schema from canonical field types and aliases
+func NewSchema(types map[string]FieldType, aliases map[string]string) *Schema {
+	return &Schema{
+		types:   types,
+		aliases: aliases,
+		learned: make(map[string]FieldType),
+	}
+}
+
+// DefaultSchema is the schema of every point written by the collector
+var DefaultSchema = NewSchema(canonicalFields, fieldAliases)
+
+// SetIntegerFields writes counts and codes such as strike_count and
+// precipitation_type as integers. They are integers in Data.Fields either
+// way; by default they are written as floats, as buckets created by earlier
+// versions hold them as floats and reject integers.
+func (s *Schema) SetIntegerFields(enabled bool) {
+	s.integers.Store(enabled)
+}
+
+// WriteType returns the type field is written as, which is TypeFloat for
+// the integer fields earlier versions wrote as floats unless integer fields
+// are enabled
+func (s *Schema) WriteType(field string) FieldType {
+	if !s.integers.Load() && legacyFloatFields[field] {
+		return TypeFloat
+	}
+	return s.Type(field)
+}
+
+// Type returns the type of a canonical or previously seen field
+func (s *Schema) Type(field string) FieldType {
+	if t, ok := s.types[field]; ok {
+		return t
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.learned[field]
+}
+
+// Normalize renames aliased fields and rewrites values into the canonical
+// type. Values that cannot be converted are rejected with ErrInvalidPoint.
+func (s *Schema) Normalize(m *Data) error {
+	fields := make(Fields, len(m.Fields))
+	for name, value := range m.Fields {
+		canonical := name
+		if alias, ok := s.aliases[name]; ok {
+			canonical = alias
+		}
+
+		t := s.Type(canonical)
+		if t == TypeUnknown {
+			t = s.learn(canonical, inferType(value))
+		}
+
+		converted, err := convert(value, t)
+		if err != nil {
+			return fmt.Errorf("%w: %s field %s: %v", ErrInvalidPoint, m.Name, name, err)
+		}
+		if existing, ok := fields[canonical]; ok && existing != converted {
+			return fmt.Errorf("%w: %s fields %s and %s conflict", ErrInvalidPoint, m.Name, name, canonical)
+		}
+		fields[canonical] = converted
+	}
+	m.Fields = fields
+	return nil
+}
+
+// learn records t for field unless another type was recorded first, and
+// returns the recorded type
+func (s *Schema) learn(field string, t FieldType) FieldType {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.learned[field]; ok {
+		return existing
+	}
+	s.learned[field] = t
+	return t
+}
+
+// inferType returns the type of a value
+func inferType(value any) FieldType {
+	switch value.(type) {
+	case float64:
+		return TypeFloat
+	case int64:
+		return TypeInteger
+	case bool:
+		return TypeBoolean
+	default:
+		return TypeString
+	}
+}
+
+// convert rewrites value as a value of type t. Numbers convert between
+// floats and integers when no precision is lost, and strings holding line
+// protocol literals convert to numbers and booleans.
+func convert(value any, t FieldType) (any, error) {
+	switch v := value.(type) {
+	case float64:
+		switch t {
+		case TypeFloat:
+			return v, nil
+		case TypeInteger:
+			if v == math.Trunc(v) && math.Abs(v) < 1<<63 {
+				return int64(v), nil
+			}
+		case TypeString:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+	case int64:
+		switch t {
+		case TypeFloat:
+			return float64(v), nil
+		case TypeInteger:
+			return v, nil
+		case TypeString:
+			return strconv.FormatInt(v, 10), nil
+		}
+	case bool:
+		switch t {
+		case TypeBoolean:
+			return v, nil
+		case TypeString:
+			return strconv.FormatBool(v), nil
+		}
+	case string:
+		return parseLiteral(v, t)
+	}
+	return nil, fmt.Errorf("%v is not a valid %s", value, t)
+}
+
+// parseLiteral converts a string to a value of type t. Numbers and booleans
+// are read from line protocol literals, so "3i" is the integer 3.
+func parseLiteral(value string, t FieldType) (any, error) {
+	switch t {
+	case TypeString:
+		return value, nil
+	case TypeBoolean:
+		if isBoolean(value) {
+			return strconv.ParseBool(value)
+		}
+	case TypeFloat:
+		v := strings.TrimSuffix(strings.TrimSuffix(value, "i"), "u")
+		if !isBoolean(v) && isLiteral(v) {
+			return strconv.ParseFloat(v, 64)
+		}
+	case TypeInteger:
+		v := strings.TrimSuffix(strings.TrimSuffix(value, "i"), "u")
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return convert(f, TypeInteger)
+		}
+	}
+	return nil, fmt.Errorf("%q is not a valid %s", value, t)
+}
+
+// isBoolean reports whether value is a line protocol boolean literal
+func isBoolean(value string) bool {
+	switch value {
+	case "t", "T", "true", "True", "TRUE", "f", "F", "false", "False", "FALSE":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package influx
+
+import (
+	"errors"
+	"testing"
+)
+
+func testSchema() *Schema {
+	return NewSchema(
+		map[string]FieldType{
+			"temp":              TypeFloat,
+			"count":             TypeInteger,
+			"firmware_revision": TypeString,
+			"online":            TypeBoolean,
+		},
+		map[string]string{"air_temperature": "temp"},
+	)
+}
+
+func TestSchemaNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  Fields
+		want    Fields
+		wantErr bool
+	}{
+		{"canonical values", Fields{"temp": 25.5, "count": int64(3), "online": true}, Fields{"temp": 25.5, "count": int64(3), "online": true}, false},
+		{"alias renamed", Fields{"air_temperature": 25.5}, Fields{"temp": 25.5}, false},
+		{"integer to float", Fields{"temp": int64(25)}, Fields{"temp": 25.0}, false},
+		{"whole float to integer", Fields{"count": 3.0}, Fields{"count": int64(3)}, false},
+		{"number to string", Fields{"firmware_revision": int64(171)}, Fields{"firmware_revision": "171"}, false},
+		{"literals", Fields{"temp": "25.5", "count": "3i", "online": "true"}, Fields{"temp": 25.5, "count": int64(3), "online": true}, false},
+		{"fractional integer", Fields{"count": 3.5}, nil, true},
+		{"string as float", Fields{"temp": "warm"}, nil, true},
+		{"boolean as float", Fields{"temp": true}, nil, true},
+		{"number as boolean", Fields{"online": int64(1)}, nil, true},
+		{"alias and canonical disagree", Fields{"temp": 25.5, "air_temperature": 26.0}, nil, true},
+		{"alias and canonical agree", Fields{"temp": 25.5, "air_temperature": 25.5}, Fields{"temp": 25.5}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New()
+			m.Name = "weather"
+			m.Fields = tt.fields
+
+			err := testSchema().Normalize(m)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Normalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrInvalidPoint) {
+					t.Errorf("Expected ErrInvalidPoint, got %v", err)
+				}
+				return
+			}
+			if len(m.Fields) != len(tt.want) {
+				t.Fatalf("Fields = %v, want %v", m.Fields, tt.want)
+			}
+			for k, v := range tt.want {
+				if m.Fields[k] != v {
+					t.Errorf("Field %s = %#v, want %#v", k, m.Fields[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestSchemaLearnsUnknownFields(t *testing.T) {
+	s := testSchema()
+
+	first := New()
+	first.Name = "weather"
+	first.Fields["obs_18"] = 1.5
+	if err := s.Normalize(first); err != nil {
+		t.Fatalf("Normalize() error = %v", err)
+	}
+	if s.Type("obs_18") != TypeFloat {
+		t.Errorf("Expected obs_18 to be learned as float, got %s", s.Type("obs_18"))
+	}
+
+	conflict := New()
+	conflict.Name = "weather"
+	conflict.Fields["obs_18"] = "n/a"
+	if err := s.Normalize(conflict); err == nil {
+		t.Error("Expected later string value of a float field to be rejected")
+	}
+}
+
+func TestNormalizeQuotesStringFields(t *testing.T) {
+	m := New()
+	m.Name = "device_status"
+	m.Fields["firmware_revision"] = int64(171)
+	m.Timestamp = 1640995200
+
+	if err := DefaultSchema.Normalize(m); err != nil {
+		t.Fatalf("Normalize() error = %v", err)
+	}
+	if got, want := m.Marshal(), "device_status firmware_revision=\"171\" 1640995200\n"; got != want {
+		t.Errorf("Marshal() = %q, want %q", got, want)
+	}
+}
+
+func TestNormalizeIntegerFields(t *testing.T) {
+	m := New()
+	m.Name = "weather"
+	m.Fields["strike_count"] = 3.0
+	m.Fields["precipitation_type"] = int64(1)
+	m.Fields["temp"] = int64(21)
+	m.Fields["reset_flags"] = "BOR,PIN"
+	m.Timestamp = 1640995200
+
+	if err := DefaultSchema.Normalize(m); err != nil {
+		t.Fatalf("Normalize() error = %v", err)
+	}
+	if got := m.Fields["strike_count"]; got != int64(3) {
+		t.Errorf("strike_count = %#v, want int64(3)", got)
+	}
+
+	// Earlier versions wrote counts and codes as floats
+	if got, want := m.Marshal(), "weather precipitation_type=1,reset_flags=\"BOR,PIN\",strike_count=3,temp=21 1640995200\n"; got != want {
+		t.Errorf("Marshal() = %q, want %q", got, want)
+	}
+
+	DefaultSchema.SetIntegerFields(true)
+	defer DefaultSchema.SetIntegerFields(false)
+	if got, want := m.Marshal(), "weather precipitation_type=1i,reset_flags=\"BOR,PIN\",strike_count=3i,temp=21 1640995200\n"; got != want {
+		t.Errorf("Marshal() with integer fields = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package influx
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+)
+
+// TLSConfig returns the TLS settings for the InfluxDB of cfg: its CA file
+// trusted in addition to the system roots, its client certificate and
+// whether the server certificate is verified. It is nil when none is set.
+func TLSConfig(cfg *config.Config) (*tls.Config, error) {
+	if cfg.Influx_TLS_CA_File == "" && cfg.Influx_TLS_Cert_File == "" && !cfg.Influx_TLS_Insecure_Skip_Verify {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.Influx_TLS_Insecure_Skip_Verify,
+	}
+	if cfg.Influx_TLS_CA_File != "" {
+		pem, err := os.ReadFile(cfg.Influx_TLS_CA_File)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.Influx_TLS_CA_File)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.Influx_TLS_Cert_File != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.Influx_TLS_Cert_File, cfg.Influx_TLS_Key_File)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// NewHTTPClient returns a client for the InfluxDB of cfg with
+// Influx_Client_Timeout and its TLS settings, for requests outside the
+// pipeline
+func NewHTTPClient(cfg *config.Config) (*http.Client, error) {
+	tlsConfig, err := TLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: cfg.Influx_Client_Timeout}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		client.Transport = transport
+	}
+	return client, nil
+}
@@ -0,0 +1,470 @@
+package influx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/health"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/metrics"
+)
+
+// HTTPClient is the subset of *http.Client used by Writer
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+const (
+	// DefaultRetryAfter is the pause used when a 429 response carries no
+	// usable Retry-After header
+	DefaultRetryAfter = 5 * time.Second
+
+	// minRetryAfter keeps a server answering "Retry-After: 0" from turning
+	// the retry loop into a busy loop
+	minRetryAfter = 100 * time.Millisecond
+)
+
+// Writer posts points to an InfluxDB write endpoint, the 2.x one unless
+// Influx_API_Path is the 1.x /write
+type Writer struct {
+	cfg    *config.Config
+	client HTTPClient
+	logger *logger.AppLogger
+	url    *url.URL
+	name   string
+
+	// buckets renames the buckets of points for a mirror
+	buckets map[string]string
+
+	// slots limits concurrent requests; nil means unlimited
+	slots chan struct{}
+
+	// pausedUntil is set when InfluxDB rate limits the writer; no request is
+	// sent before that time
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+// NewWriter creates a Writer for the InfluxDB instance described by cfg
+func NewWriter(cfg *config.Config, client HTTPClient, appLogger *logger.AppLogger) (*Writer, error) {
+	// Parse Influx URL and append API path
+	writeURL, err := url.Parse(cfg.Influx_URL + cfg.Influx_API_Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set query arguments
+	query := writeURL.Query()
+	query.Set("org", cfg.Influx_Org)
+	query.Set("precision", string(PrecisionSeconds))
+	writeURL.RawQuery = query.Encode()
+
+	w := &Writer{
+		cfg:    cfg,
+		client: client,
+		logger: appLogger,
+		url:    writeURL,
+		name:   "influx",
+	}
+	if cfg.Influx_Max_Concurrent_Writes > 0 {
+		w.slots = make(chan struct{}, cfg.Influx_Max_Concurrent_Writes)
+	}
+	return w, nil
+}
+
+// NewMirrorWriter creates a Writer for the mirror at index i of
+// cfg.Influx_Mirrors, named "influx:" and the mirror name. Its API path is
+// resolved like the main one, and points are written to the mirror's
+// buckets, including its route buckets, in place of the main ones.
+func NewMirrorWriter(ctx context.Context, cfg *config.Config, i int, client HTTPClient, appLogger *logger.AppLogger) (*Writer, error) {
+	name := "influx:" + cfg.MirrorName(i)
+	mirror := cfg.ForMirror(i)
+	appLogger = appLogger.With("output", name)
+	ResolveAPIPath(ctx, mirror, client, appLogger)
+	w, err := NewWriter(mirror, client, appLogger)
+	if err != nil {
+		return nil, fmt.Errorf("mirror %s: %w", cfg.MirrorName(i), err)
+	}
+	w.name = name
+	w.buckets = make(map[string]string, len(cfg.Influx_Routes)+2)
+	for j, r := range cfg.Influx_Routes {
+		if r.Bucket != "" {
+			w.buckets[r.Bucket] = mirror.Influx_Routes[j].Bucket
+		}
+	}
+	if cfg.Influx_Bucket_Rapid_Wind != "" {
+		w.buckets[cfg.Influx_Bucket_Rapid_Wind] = mirror.Influx_Bucket_Rapid_Wind
+	}
+	w.buckets[cfg.Influx_Bucket] = mirror.Influx_Bucket
+	return w, nil
+}
+
+// Name identifies the writer in logs
+func (w *Writer) Name() string {
+	return w.name
+}
+
+// batch is the line protocol body of one write request
+type batch struct {
+	points []*Data
+	body   string
+}
+
+// Write posts points to InfluxDB, issuing one request per bucket, or more
+// when the points of a bucket exceed the configured batch limits
+func (w *Writer) Write(ctx context.Context, points []*Data) error {
+	var order []string
+	groups := make(map[string][]*Data)
+	for _, m := range points {
+		bucket := m.Bucket
+		if renamed, ok := w.buckets[bucket]; ok {
+			bucket = renamed
+		}
+		if _, ok := groups[bucket]; !ok {
+			order = append(order, bucket)
+		}
+		groups[bucket] = append(groups[bucket], m)
+	}
+
+	for _, bucket := range order {
+		precision := PrecisionOf(groups[bucket])
+		for _, b := range w.split(groups[bucket], precision) {
+			log := w.logger.With("packet_ids", PacketIDs(b.points))
+			start := time.Now()
+			if err := w.post(ctx, log, bucket, precision, b.body); err != nil {
+				return err
+			}
+			if w.cfg.Verbose && !w.cfg.Noop {
+				w.logPoints(b.points, bucket, time.Since(start))
+			}
+		}
+	}
+	health.RecordWrite(w.Name(), time.Now())
+	return nil
+}
+
+// logPoints logs every point of a successful write, including the time the
+// write took with retries
+func (w *Writer) logPoints(points []*Data, bucket string, latency time.Duration) {
+	for _, m := range points {
+		w.logger.Info("Point written",
+			"output", w.Name(),
+			"packet_id", m.ID,
+			"station", m.Tags["station"],
+			"report_type", m.ReportType,
+			"measurement", m.Name,
+			"fields", len(m.Fields),
+			"bucket", bucket,
+			"latency_ms", float64(latency.Microseconds())/1000)
+	}
+}
+
+// split marshals points into batches of at most Influx_Max_Batch_Lines
+// lines and Influx_Max_Batch_Bytes bytes; a zero limit is unlimited. A
+// single line larger than the byte limit is sent on its own.
+func (w *Writer) split(points []*Data, precision Precision) []batch {
+	maxLines, maxBytes := w.cfg.Influx_Max_Batch_Lines, w.cfg.Influx_Max_Batch_Bytes
+
+	var batches []batch
+	var current batch
+	var body strings.Builder
+	flush := func() {
+		if len(current.points) > 0 {
+			current.body = body.String()
+			batches = append(batches, current)
+		}
+		current = batch{}
+		body.Reset()
+	}
+	for _, m := range points {
+		line := m.MarshalPrecision(precision)
+		if len(current.points) > 0 &&
+			((maxLines > 0 && len(current.points) >= maxLines) ||
+				(maxBytes > 0 && body.Len()+len(line) > maxBytes)) {
+			flush()
+		}
+		current.points = append(current.points, m)
+		body.WriteString(line)
+	}
+	flush()
+	return batches
+}
+
+// PacketIDs returns the distinct correlation IDs of points in order
+func PacketIDs(points []*Data) []string {
+	ids := make([]string, 0, len(points))
+	seen := make(map[string]bool, len(points))
+	for _, m := range points {
+		if m.ID != "" && !seen[m.ID] {
+			seen[m.ID] = true
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids
+}
+
+// bucketURL returns the write URL for bucket and precision, with the org
+// that owns bucket. InfluxDB 1.x takes the bucket as database.
+func (w *Writer) bucketURL(bucket string, precision Precision) *url.URL {
+	u := *w.url
+	query := u.Query()
+	if IsV1Path(u.Path) {
+		if bucket != "" {
+			query.Set("db", bucket)
+		}
+		if w.cfg.Influx_Retention_Policy != "" {
+			query.Set("rp", w.cfg.Influx_Retention_Policy)
+		}
+	} else if bucket != "" {
+		query.Set("bucket", bucket)
+	}
+	query.Set("org", w.cfg.InfluxOrg(bucket))
+	query.Set("precision", string(precision))
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+// post sends a single line protocol body to bucket. Rate limited requests are
+// retried after the delay requested by InfluxDB, up to the configured maximum
+// total wait. Other failures that may be transient are retried up to
+// Influx_Retry_Attempts times with jittered exponential backoff.
+func (w *Writer) post(ctx context.Context, log *logger.AppLogger, bucket string, precision Precision, body string) error {
+	writeURL := w.bucketURL(bucket, precision)
+
+	if w.cfg.Verbose {
+		log.Info("Posting data to InfluxDB",
+			"data", body,
+			"url", writeURL.String())
+	}
+
+	if w.cfg.Noop {
+		log.Info("NOOP mode - not posting to InfluxDB",
+			"url", writeURL.String())
+		return nil
+	}
+
+	payload := []byte(body)
+	if w.cfg.Influx_Gzip {
+		var err error
+		if payload, err = compress(payload); err != nil {
+			return fmt.Errorf("compressing write request: %w", err)
+		}
+	}
+
+	var waited time.Duration
+	var retries int
+	for {
+		if err := w.waitForPause(ctx); err != nil {
+			return err
+		}
+
+		err := w.send(ctx, log, writeURL, bucket, body, payload)
+		if err == nil {
+			return nil
+		}
+		var writeErr *WriteError
+		if errors.As(err, &writeErr) && writeErr.RateLimited() {
+			metrics.InfluxRateLimited.WithLabelValues(w.Name()).Inc()
+			delay := max(writeErr.RetryAfter, minRetryAfter)
+			if waited+delay > w.cfg.Influx_Rate_Limit_Max_Wait {
+				return fmt.Errorf("rate limited for longer than %s: %w", w.cfg.Influx_Rate_Limit_Max_Wait, err)
+			}
+			waited += delay
+
+			log.Warn("InfluxDB rate limited write, pausing writer",
+				"retry_after", delay.String(),
+				"bucket", bucket)
+			w.pause(delay)
+			continue
+		}
+
+		if ctx.Err() != nil || !Retryable(err) || retries >= w.cfg.Influx_Retry_Attempts {
+			return err
+		}
+		retries++
+		delay := w.backoff(retries)
+		metrics.InfluxRetries.WithLabelValues(w.Name()).Inc()
+		log.Warn("InfluxDB write failed, retrying",
+			"attempt", retries,
+			"backoff", delay.String(),
+			"error", err.Error())
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// backoff returns the pause before retry number n: Influx_Retry_Backoff
+// doubled for every earlier retry, capped at Influx_Retry_Max_Backoff,
+// with a random half of it taken off so that writers retrying together
+// spread out
+func (w *Writer) backoff(n int) time.Duration {
+	d := w.cfg.Influx_Retry_Backoff
+	for i := 1; i < n && (w.cfg.Influx_Retry_Max_Backoff <= 0 || d < w.cfg.Influx_Retry_Max_Backoff); i++ {
+		d *= 2
+	}
+	if w.cfg.Influx_Retry_Max_Backoff > 0 {
+		d = min(d, w.cfg.Influx_Retry_Max_Backoff)
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// sleep waits for d or until ctx is cancelled
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// acquire reserves one of the writer's request slots
+func (w *Writer) acquire(ctx context.Context) error {
+	if w.slots == nil {
+		return nil
+	}
+	select {
+	case w.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a slot reserved by acquire
+func (w *Writer) release() {
+	if w.slots != nil {
+		<-w.slots
+	}
+}
+
+// compress returns body compressed with gzip
+func compress(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(body); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// send performs a single write request authenticated for bucket. payload is
+// body as sent, compressed with Influx_Gzip.
+func (w *Writer) send(ctx context.Context, log *logger.AppLogger, writeURL *url.URL, bucket, body string, payload []byte) error {
+	if err := w.acquire(ctx); err != nil {
+		return err
+	}
+	defer w.release()
+
+	inFlight := metrics.InfluxInFlight.WithLabelValues(w.Name())
+	inFlight.Inc()
+	defer inFlight.Dec()
+
+	// Bound this request on its own so a slow response is cancelled without
+	// waiting for the client timeout or closing idle connections
+	if w.cfg.Influx_Write_Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.cfg.Influx_Write_Timeout)
+		defer cancel()
+	}
+
+	// Create HTTP request with context
+	request, err := http.NewRequestWithContext(ctx, "POST", writeURL.String(), bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("creating request for %s: %w", writeURL.Redacted(), err)
+	}
+	authorize(w.cfg, request, bucket)
+	request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	request.Header.Set("Accept", "application/json")
+	if w.cfg.Influx_Gzip {
+		request.Header.Set("Content-Encoding", "gzip")
+	}
+
+	metrics.InfluxPayloadBytes.WithLabelValues(w.Name()).Observe(float64(len(payload)))
+	metrics.InfluxPayloadLines.WithLabelValues(w.Name()).Observe(float64(strings.Count(body, "\n")))
+	start := time.Now()
+	resp, err := w.client.Do(request)
+	metrics.InfluxWriteDuration.WithLabelValues(w.Name()).Observe(time.Since(start).Seconds())
+	if err != nil {
+		return fmt.Errorf("posting to %s: %w", w.cfg.Influx_URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &WriteError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Message:    strings.TrimSpace(string(message)),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now(), DefaultRetryAfter),
+		}
+	}
+
+	if w.cfg.Verbose {
+		log.Info("Successfully posted data to InfluxDB",
+			"status", resp.Status,
+			"status_code", resp.StatusCode)
+	}
+	return nil
+}
+
+// authorize adds the credentials for bucket to request: the 1.x username
+// and password as basic authentication when Influx_Username is set,
+// otherwise the token of bucket
+func authorize(cfg *config.Config, request *http.Request, bucket string) {
+	if cfg.Influx_Username != "" {
+		request.SetBasicAuth(cfg.Influx_Username, cfg.Influx_Password)
+		return
+	}
+	if token := cfg.InfluxToken(bucket); token != "" {
+		request.Header.Set("Authorization", "Token "+token)
+	}
+}
+
+// pause stops all requests from this writer for d
+func (w *Writer) pause(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if until := time.Now().Add(d); until.After(w.pausedUntil) {
+		w.pausedUntil = until
+	}
+}
+
+// waitForPause blocks until the writer is no longer paused
+func (w *Writer) waitForPause(ctx context.Context) error {
+	w.mu.Lock()
+	d := time.Until(w.pausedUntil)
+	w.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
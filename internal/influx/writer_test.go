@@ -0,0 +1,686 @@
+package influx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestPoint(bucket string, temp float64) *Data {
+	m := New()
+	m.Name = "weather"
+	m.Bucket = bucket
+	m.Tags["station"] = "ST-123"
+	m.Fields["temp"] = temp
+	m.Timestamp = 1640995200
+	return m
+}
+
+func TestWriterWrite(t *testing.T) {
+	var bodies []string
+	var buckets []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/write" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Token test-token" {
+			t.Errorf("Unexpected Authorization header %s", r.Header.Get("Authorization"))
+		}
+		if r.URL.Query().Get("org") != "test-org" || r.URL.Query().Get("precision") != "s" {
+			t.Errorf("Unexpected query %s", r.URL.RawQuery)
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		buckets = append(buckets, r.URL.Query().Get("bucket"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Influx_URL:      server.URL,
+		Influx_API_Path: "/api/v2/write",
+		Influx_Org:      "test-org",
+		Influx_Token:    "test-token",
+	}
+	w, err := NewWriter(cfg, server.Client(), logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+
+	points := []*Data{newTestPoint("a", 1.0), newTestPoint("b", 2.0), newTestPoint("a", 3.0)}
+	if err := w.Write(context.Background(), points); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("Expected one request per bucket, got %d", len(bodies))
+	}
+	if buckets[0] != "a" || buckets[1] != "b" {
+		t.Errorf("Unexpected bucket order %v", buckets)
+	}
+	if strings.Count(bodies[0], "\n") != 2 {
+		t.Errorf("Expected two lines for bucket a, got %q", bodies[0])
+	}
+}
+
+func TestWriterGzip(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("Unexpected Content-Encoding %q", r.Header.Get("Content-Encoding"))
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatalf("gzip.NewReader() error = %v", err)
+		}
+		b, _ := io.ReadAll(gz)
+		body = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Influx_URL:      server.URL,
+		Influx_API_Path: "/api/v2/write",
+		Influx_Gzip:     true,
+	}
+	w, err := NewWriter(cfg, server.Client(), logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	if err := w.Write(context.Background(), []*Data{newTestPoint("a", 1.0)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if !strings.HasPrefix(body, "weather,station=ST-123 temp=1 ") {
+		t.Errorf("Unexpected decompressed body %q", body)
+	}
+}
+
+func TestWriterVerbosePointLogs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Influx_URL:      server.URL,
+		Influx_API_Path: "/api/v2/write",
+		Verbose:         true,
+	}
+	var buf bytes.Buffer
+	w, err := NewWriter(cfg, server.Client(), logger.NewWriter(&config.Config{}, &buf))
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	point := newTestPoint("a", 1.0)
+	point.ReportType = "obs_st"
+	if err := w.Write(context.Background(), []*Data{point}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	var found bool
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Unexpected log line %q: %v", line, err)
+		}
+		if entry["msg"] != "Point written" {
+			continue
+		}
+		found = true
+		if entry["station"] != "ST-123" || entry["measurement"] != "weather" || entry["fields"] != float64(1) || entry["bucket"] != "a" {
+			t.Errorf("Unexpected point log %v", entry)
+		}
+		if _, ok := entry["latency_ms"].(float64); !ok {
+			t.Errorf("Expected latency_ms in %v", entry)
+		}
+	}
+	if !found {
+		t.Errorf("Expected a point log, got %q", buf.String())
+	}
+}
+
+func TestWriterBucketCredentials(t *testing.T) {
+	auth := make(map[string]string)
+	orgs := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bucket := r.URL.Query().Get("bucket")
+		auth[bucket] = r.Header.Get("Authorization")
+		orgs[bucket] = r.URL.Query().Get("org")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Influx_URL:      server.URL,
+		Influx_API_Path: "/api/v2/write",
+		Influx_Org:      "test-org",
+		Influx_Token:    "test-token",
+		Influx_Credentials: map[string]config.InfluxCredentials{
+			"wind": {Org: "wind-org", Token: "wind-token"},
+		},
+	}
+	w, err := NewWriter(cfg, server.Client(), logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	if err := w.Write(context.Background(), []*Data{newTestPoint("weather", 1.0), newTestPoint("wind", 2.0)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if auth["weather"] != "Token test-token" || orgs["weather"] != "test-org" {
+		t.Errorf("Expected the global credentials for weather, got %s, %s", auth["weather"], orgs["weather"])
+	}
+	if auth["wind"] != "Token wind-token" || orgs["wind"] != "wind-org" {
+		t.Errorf("Expected the credentials of wind, got %s, %s", auth["wind"], orgs["wind"])
+	}
+}
+
+func TestWriterV1(t *testing.T) {
+	var query map[string][]string
+	var user, password string
+	var ok bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != V1WritePath {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		query = r.URL.Query()
+		user, password, ok = r.BasicAuth()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Influx_URL:              server.URL,
+		Influx_API_Path:         V2WritePath,
+		Influx_Version:          config.InfluxVersion1,
+		Influx_Username:         "collector",
+		Influx_Password:         "secret",
+		Influx_Retention_Policy: "one_year",
+	}
+	ResolveAPIPath(context.Background(), cfg, server.Client(), logger.New(&config.Config{}))
+	w, err := NewWriter(cfg, server.Client(), logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	if err := w.Write(context.Background(), []*Data{newTestPoint("weather", 1.0)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if got := query["db"]; len(got) != 1 || got[0] != "weather" {
+		t.Errorf("Expected db=weather, got %v", got)
+	}
+	if got := query["rp"]; len(got) != 1 || got[0] != "one_year" {
+		t.Errorf("Expected rp=one_year, got %v", got)
+	}
+	if !ok || user != "collector" || password != "secret" {
+		t.Errorf("Expected basic authentication, got %q %q %v", user, password, ok)
+	}
+}
+
+func TestMirrorWriter(t *testing.T) {
+	var buckets, tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buckets = append(buckets, r.URL.Query().Get("bucket"))
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Influx_URL:               "http://localhost:8086",
+		Influx_API_Path:          V2WritePath,
+		Influx_Token:             "local-token",
+		Influx_Bucket:            "weather",
+		Influx_Bucket_Rapid_Wind: "wind",
+		Influx_Mirrors: []config.InfluxMirror{{
+			Name:   "cloud",
+			URL:    server.URL,
+			Org:    "cloud-org",
+			Token:  "cloud-token",
+			Bucket: "tempest",
+		}},
+	}
+	w, err := NewMirrorWriter(context.Background(), cfg, 0, server.Client(), logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("NewMirrorWriter() error = %v", err)
+	}
+	if w.Name() != "influx:cloud" {
+		t.Errorf("Name() = %s, want influx:cloud", w.Name())
+	}
+	points := []*Data{newTestPoint("weather", 1.0), newTestPoint("wind", 2.0), newTestPoint("other", 3.0)}
+	if err := w.Write(context.Background(), points); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	// Both main buckets map to the mirror bucket; others are kept
+	if len(buckets) != 2 || buckets[0] != "tempest" || buckets[1] != "other" {
+		t.Errorf("Unexpected buckets %v", buckets)
+	}
+	for _, token := range tokens {
+		if token != "Token cloud-token" {
+			t.Errorf("Expected the mirror token, got %q", token)
+		}
+	}
+}
+
+func TestMirrorWriterRoutes(t *testing.T) {
+	var buckets, orgs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buckets = append(buckets, r.URL.Query().Get("bucket"))
+		orgs = append(orgs, r.URL.Query().Get("org"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Influx_URL:      "http://localhost:8086",
+		Influx_API_Path: V2WritePath,
+		Influx_Bucket:   "weather",
+		Influx_Routes: []config.InfluxRoute{
+			{Types: []string{"evt_strike"}, Bucket: "events", Org: "local-ops"},
+			{Types: []string{"device_status"}, Bucket: "ops"},
+		},
+		Influx_Mirrors: []config.InfluxMirror{{
+			URL:           server.URL,
+			Org:           "cloud-org",
+			Token:         "cloud-token",
+			Route_Buckets: map[string]string{"events": "tempest-events"},
+		}},
+	}
+	w, err := NewMirrorWriter(context.Background(), cfg, 0, server.Client(), logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("NewMirrorWriter() error = %v", err)
+	}
+	points := []*Data{newTestPoint("events", 1.0), newTestPoint("ops", 2.0)}
+	if err := w.Write(context.Background(), points); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	// Mapped route buckets are renamed, others kept, all under the mirror org
+	if len(buckets) != 2 || buckets[0] != "tempest-events" || buckets[1] != "ops" {
+		t.Errorf("Unexpected buckets %v", buckets)
+	}
+	for _, org := range orgs {
+		if org != "cloud-org" {
+			t.Errorf("Expected the mirror org, got %q", org)
+		}
+	}
+}
+
+func TestWriterSplitsBatches(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	line := newTestPoint("a", 1.0).Marshal()
+	tests := []struct {
+		name     string
+		lines    int
+		bytes    int
+		requests []int
+	}{
+		{"unlimited", 0, 0, []int{5}},
+		{"by lines", 2, 0, []int{2, 2, 1}},
+		{"by bytes", 0, 3*len(line) + 1, []int{3, 2}},
+		{"line above byte limit", 0, 1, []int{1, 1, 1, 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodies = nil
+			cfg := &config.Config{
+				Influx_URL:             server.URL,
+				Influx_API_Path:        "/api/v2/write",
+				Influx_Max_Batch_Lines: tt.lines,
+				Influx_Max_Batch_Bytes: tt.bytes,
+			}
+			w, err := NewWriter(cfg, server.Client(), logger.New(&config.Config{}))
+			if err != nil {
+				t.Fatalf("NewWriter() error = %v", err)
+			}
+
+			var points []*Data
+			for range 5 {
+				points = append(points, newTestPoint("a", 1.0))
+			}
+			if err := w.Write(context.Background(), points); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+
+			if len(bodies) != len(tt.requests) {
+				t.Fatalf("Expected %d requests, got %d", len(tt.requests), len(bodies))
+			}
+			for i, want := range tt.requests {
+				if got := strings.Count(bodies[i], "\n"); got != want {
+					t.Errorf("Request %d: expected %d lines, got %d", i, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWriterSubsecondPrecision(t *testing.T) {
+	var precisions, bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		precisions = append(precisions, r.URL.Query().Get("precision"))
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{Influx_URL: server.URL, Influx_API_Path: "/api/v2/write"}
+	w, err := NewWriter(cfg, server.Client(), logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+
+	offset := newTestPoint("wind", 1.0)
+	offset.Nanos = 1000
+	points := []*Data{newTestPoint("weather", 1.0), newTestPoint("wind", 2.0), offset}
+	if err := w.Write(context.Background(), points); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if len(precisions) != 2 || precisions[0] != "s" || precisions[1] != "ns" {
+		t.Fatalf("Expected s for the plain bucket and ns for the offset bucket, got %v", precisions)
+	}
+	if !strings.Contains(bodies[1], " 1640995200000000000\n") || !strings.Contains(bodies[1], " 1640995200000001000\n") {
+		t.Errorf("Expected nanosecond timestamps, got %q", bodies[1])
+	}
+}
+
+func TestWriterErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{Influx_URL: server.URL, Influx_API_Path: "/api/v2/write"}
+	w, err := NewWriter(cfg, server.Client(), logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+
+	if err := w.Write(context.Background(), []*Data{newTestPoint("a", 1.0)}); err == nil {
+		t.Error("Expected error for 400 response")
+	}
+}
+
+func TestWriterNoop(t *testing.T) {
+	cfg := &config.Config{Influx_URL: "http://localhost:1", Influx_API_Path: "/api/v2/write", Noop: true}
+	w, err := NewWriter(cfg, http.DefaultClient, logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+
+	if err := w.Write(context.Background(), []*Data{newTestPoint("a", 1.0)}); err != nil {
+		t.Errorf("Write() in NOOP mode error = %v", err)
+	}
+}
+
+func TestWriterWriteTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	cfg := &config.Config{
+		Influx_URL:           server.URL,
+		Influx_API_Path:      "/api/v2/write",
+		Influx_Write_Timeout: 50 * time.Millisecond,
+	}
+	w, err := NewWriter(cfg, server.Client(), logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+
+	start := time.Now()
+	err = w.Write(context.Background(), []*Data{newTestPoint("a", 1.0)})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Write took %v, expected write timeout to cancel it", elapsed)
+	}
+}
+
+func TestWriterRateLimited(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Influx_URL:                 server.URL,
+		Influx_API_Path:            "/api/v2/write",
+		Influx_Rate_Limit_Max_Wait: time.Minute,
+	}
+	w, err := NewWriter(cfg, server.Client(), logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+
+	before := testutil.ToFloat64(metrics.InfluxRateLimited.WithLabelValues(w.Name()))
+	if err := w.Write(context.Background(), []*Data{newTestPoint("a", 1.0)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected rate limited request to be retried, got %d requests", requests)
+	}
+	if got := testutil.ToFloat64(metrics.InfluxRateLimited.WithLabelValues(w.Name())) - before; got != 1 {
+		t.Errorf("Expected rate limited counter to increase by 1, got %v", got)
+	}
+}
+
+func TestWriterRateLimitMaxWait(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Influx_URL:                 server.URL,
+		Influx_API_Path:            "/api/v2/write",
+		Influx_Rate_Limit_Max_Wait: time.Minute,
+	}
+	w, err := NewWriter(cfg, server.Client(), logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+
+	err = w.Write(context.Background(), []*Data{newTestPoint("a", 1.0)})
+	var writeErr *WriteError
+	if !errors.As(err, &writeErr) || !writeErr.RateLimited() {
+		t.Errorf("Expected rate limited WriteError, got %v", err)
+	}
+}
+
+func TestWriterRetries(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		status   int
+		failures int
+		want     int
+		wantErr  bool
+	}{
+		{"recovers", http.StatusServiceUnavailable, 2, 3, false},
+		{"budget exhausted", http.StatusBadGateway, 5, 3, true},
+		{"client error", http.StatusBadRequest, 1, 1, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests <= tt.failures {
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			cfg := &config.Config{
+				Influx_URL:            server.URL,
+				Influx_API_Path:       "/api/v2/write",
+				Influx_Retry_Attempts: 2,
+				Influx_Retry_Backoff:  time.Millisecond,
+			}
+			w, err := NewWriter(cfg, server.Client(), logger.New(&config.Config{}))
+			if err != nil {
+				t.Fatalf("NewWriter() error = %v", err)
+			}
+
+			err = w.Write(context.Background(), []*Data{newTestPoint("a", 1.0)})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if requests != tt.want {
+				t.Errorf("Expected %d requests, got %d", tt.want, requests)
+			}
+		})
+	}
+}
+
+func TestWriterRetriesConnectionErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	cfg := &config.Config{
+		Influx_URL:            url,
+		Influx_API_Path:       "/api/v2/write",
+		Influx_Retry_Attempts: 1,
+		Influx_Retry_Backoff:  time.Millisecond,
+	}
+	w, err := NewWriter(cfg, http.DefaultClient, logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	before := testutil.ToFloat64(metrics.InfluxRetries.WithLabelValues(w.Name()))
+	if err := w.Write(context.Background(), []*Data{newTestPoint("a", 1.0)}); err == nil {
+		t.Fatal("Expected an error when InfluxDB is unreachable")
+	}
+	if got := testutil.ToFloat64(metrics.InfluxRetries.WithLabelValues(w.Name())) - before; got != 1 {
+		t.Errorf("Expected one retry, got %v", got)
+	}
+}
+
+func TestWriterBackoff(t *testing.T) {
+	w := &Writer{cfg: &config.Config{Influx_Retry_Backoff: time.Second, Influx_Retry_Max_Backoff: 5 * time.Second}}
+	for n, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		for range 20 {
+			if d := w.backoff(n); d < want/2 || d > want {
+				t.Errorf("backoff(%d) = %v, want between %v and %v", n, d, want/2, want)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", DefaultRetryAfter},
+		{"30", 30 * time.Second},
+		{"-1", DefaultRetryAfter},
+		{"garbage", DefaultRetryAfter},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now, DefaultRetryAfter); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWriterConcurrencyLimit(t *testing.T) {
+	var mu sync.Mutex
+	active, peak := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		peak = max(peak, active)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Influx_URL:                   server.URL,
+		Influx_API_Path:              "/api/v2/write",
+		Influx_Max_Concurrent_Writes: 2,
+	}
+	w, err := NewWriter(cfg, server.Client(), logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.Write(context.Background(), []*Data{newTestPoint("a", 1.0)}); err != nil {
+				t.Errorf("Write() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("Expected at most 2 concurrent requests, got %d", peak)
+	}
+}
+
+func TestPacketIDs(t *testing.T) {
+	a, b, c := newTestPoint("x", 1.0), newTestPoint("x", 2.0), newTestPoint("x", 3.0)
+	a.ID, b.ID, c.ID = "aaaa", "bbbb", "aaaa"
+
+	ids := PacketIDs([]*Data{a, b, c, newTestPoint("x", 4.0)})
+	if len(ids) != 2 || ids[0] != "aaaa" || ids[1] != "bbbb" {
+		t.Errorf("PacketIDs() = %v, want [aaaa bbbb]", ids)
+	}
+}
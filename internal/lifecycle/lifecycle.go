@@ -0,0 +1,212 @@
+// Package lifecycle writes annotation points when the service starts,
+// stops or restarts its pipeline, so that gaps in the data can be told
+// apart from weather on Grafana dashboards
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/ntp"
+)
+
+const (
+	// Measurement is the name of lifecycle points
+	Measurement = "service"
+
+	// MarkerName is the file in State_Dir that exists while the service
+	// runs; finding it at startup means the last run did not stop cleanly
+	MarkerName = "running"
+
+	// writeTimeout bounds the write of one event, which may happen after
+	// the service context is cancelled
+	writeTimeout = 5 * time.Second
+)
+
+// Events tag the lifecycle points
+const (
+	EventStart   = "start"
+	EventStop    = "stop"
+	EventRestart = "restart"
+)
+
+// Writer stores points, see influx.Writer
+type Writer interface {
+	Write(ctx context.Context, points []*influx.Data) error
+}
+
+// Recorder writes lifecycle points tagged with the event and host, with the
+// reason, version and commit as fields and a text field suitable for a
+// Grafana annotation. A nil Recorder records nothing.
+type Recorder struct {
+	cfg     *config.Config
+	logger  *logger.AppLogger
+	writer  Writer
+	version string
+	commit  string
+	host    string
+	now     func() time.Time
+}
+
+// New creates a Recorder for the service version built from commit
+func New(cfg *config.Config, appLogger *logger.AppLogger, writer Writer, version, commit string) *Recorder {
+	host, _ := os.Hostname()
+	return &Recorder{
+		cfg:     cfg,
+		logger:  appLogger,
+		writer:  writer,
+		version: version,
+		commit:  commit,
+		host:    host,
+		now:     ntp.Now,
+	}
+}
+
+// Commit returns the VCS revision recorded by the Go toolchain, or "" when
+// the binary was built without VCS information
+func Commit() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		}
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if revision != "" && modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// Started records the start of the service. With State_Dir set, a start
+// after a run that did not stop cleanly, e.g. a crash or a kill, is
+// recorded with the reason "unclean shutdown".
+func (r *Recorder) Started() {
+	if r == nil {
+		return
+	}
+	reason := "start"
+	if marker := r.marker(); marker != "" {
+		if _, err := os.Stat(marker); err == nil {
+			reason = "unclean shutdown"
+		}
+	}
+	r.start(reason)
+}
+
+// Upgraded records the start of a service that took over from a previous
+// process, whose marker is expected to exist
+func (r *Recorder) Upgraded() {
+	if r == nil {
+		return
+	}
+	r.start("upgrade")
+}
+
+// start writes the marker of a running service and records its start
+func (r *Recorder) start(reason string) {
+	if marker := r.marker(); marker != "" {
+		err := os.MkdirAll(filepath.Dir(marker), 0o755)
+		if err == nil {
+			err = os.WriteFile(marker, []byte(r.now().UTC().Format(time.RFC3339)+"\n"), 0o644)
+		}
+		if err != nil {
+			r.logger.Warn("Failed to write running marker", slog.String("error", err.Error()))
+		}
+	}
+	r.record(EventStart, reason, fmt.Sprintf("%s started", r.name()))
+}
+
+// Restarted records a restart of the pipeline for reason, e.g. a changed
+// remote configuration
+func (r *Recorder) Restarted(reason string) {
+	if r == nil {
+		return
+	}
+	r.record(EventRestart, reason, fmt.Sprintf("%s restarted: %s", r.name(), reason))
+}
+
+// Stopped records the end of the service for reason and removes the marker
+// of a running service
+func (r *Recorder) Stopped(reason string) {
+	if r == nil {
+		return
+	}
+	r.record(EventStop, reason, fmt.Sprintf("%s stopped: %s", r.name(), reason))
+	if marker := r.marker(); marker != "" {
+		if err := os.Remove(marker); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			r.logger.Warn("Failed to remove running marker", slog.String("error", err.Error()))
+		}
+	}
+}
+
+// HandedOver records the end of a service that handed over to a new
+// process, leaving the marker to the new process
+func (r *Recorder) HandedOver() {
+	if r == nil {
+		return
+	}
+	r.record(EventStop, "upgrade", fmt.Sprintf("%s stopped: upgrade", r.name()))
+}
+
+// marker returns the path of the running marker, or "" without State_Dir
+func (r *Recorder) marker() string {
+	if r.cfg.State_Dir == "" {
+		return ""
+	}
+	return filepath.Join(r.cfg.State_Dir, MarkerName)
+}
+
+// name describes the running binary
+func (r *Recorder) name() string {
+	if r.commit == "" {
+		return "tempest-influxdb " + r.version
+	}
+	return "tempest-influxdb " + r.version + " (" + r.commit + ")"
+}
+
+// record writes one lifecycle point
+func (r *Recorder) record(event, reason, text string) {
+	m := influx.New()
+	m.Name = Measurement
+	m.ReportType = Measurement
+	m.Bucket = r.cfg.Influx_Bucket
+	m.Timestamp = r.now().Unix()
+	m.Tags["event"] = event
+	if r.host != "" {
+		m.Tags["host"] = r.host
+	}
+	m.Fields["text"] = text
+	m.Fields["reason"] = reason
+	m.Fields["version"] = r.version
+	if r.commit != "" {
+		m.Fields["commit"] = r.commit
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	if err := r.writer.Write(ctx, []*influx.Data{m}); err != nil {
+		r.logger.Warn("Failed to write lifecycle event",
+			slog.String("event", event),
+			slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,112 @@
+package lifecycle
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+type recordingWriter struct{ points []*influx.Data }
+
+func (w *recordingWriter) Write(ctx context.Context, points []*influx.Data) error {
+	w.points = append(w.points, points...)
+	return nil
+}
+
+func newRecorder(cfg *config.Config, writer Writer) *Recorder {
+	r := New(cfg, logger.New(&config.Config{}), writer, "2.0.0", "abc123")
+	r.host = "collector"
+	r.now = func() time.Time { return time.Unix(1700000000, 0) }
+	return r
+}
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+	writer := &recordingWriter{}
+	r := newRecorder(&config.Config{Influx_Bucket: "weather", State_Dir: dir}, writer)
+
+	r.Started()
+	if _, err := os.Stat(filepath.Join(dir, MarkerName)); err != nil {
+		t.Errorf("Expected a running marker: %v", err)
+	}
+	r.Restarted("remote configuration changed")
+	r.Stopped("signal")
+	if _, err := os.Stat(filepath.Join(dir, MarkerName)); !os.IsNotExist(err) {
+		t.Errorf("Expected the running marker to be removed, got %v", err)
+	}
+
+	if len(writer.points) != 3 {
+		t.Fatalf("Expected three events, got %d", len(writer.points))
+	}
+	start := writer.points[0]
+	if start.Name != Measurement || start.Bucket != "weather" || start.Timestamp != 1700000000 ||
+		start.Tags["event"] != EventStart || start.Tags["host"] != "collector" {
+		t.Errorf("Unexpected start point %+v", start)
+	}
+	for field, want := range map[string]string{
+		"text":    "tempest-influxdb 2.0.0 (abc123) started",
+		"reason":  "start",
+		"version": "2.0.0",
+		"commit":  "abc123",
+	} {
+		if start.Fields[field] != want {
+			t.Errorf("%s = %q, want %q", field, start.Fields[field], want)
+		}
+	}
+	if restart := writer.points[1]; restart.Tags["event"] != EventRestart || restart.Fields["reason"] != "remote configuration changed" {
+		t.Errorf("Unexpected restart point %+v", restart)
+	}
+	if stop := writer.points[2]; stop.Tags["event"] != EventStop || stop.Fields["text"] != "tempest-influxdb 2.0.0 (abc123) stopped: signal" {
+		t.Errorf("Unexpected stop point %+v", stop)
+	}
+}
+
+func TestRecorderUncleanShutdown(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, MarkerName), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writer := &recordingWriter{}
+	newRecorder(&config.Config{State_Dir: dir}, writer).Started()
+	if len(writer.points) != 1 || writer.points[0].Fields["reason"] != "unclean shutdown" {
+		t.Errorf("Expected a start after an unclean shutdown, got %+v", writer.points)
+	}
+}
+
+func TestRecorderUpgrade(t *testing.T) {
+	dir := t.TempDir()
+	writer := &recordingWriter{}
+	previous := newRecorder(&config.Config{State_Dir: dir}, writer)
+	previous.Started()
+	next := newRecorder(&config.Config{State_Dir: dir}, writer)
+	next.Upgraded()
+	previous.HandedOver()
+
+	if _, err := os.Stat(filepath.Join(dir, MarkerName)); err != nil {
+		t.Errorf("Expected the running marker of the new process to be kept: %v", err)
+	}
+	if len(writer.points) != 3 {
+		t.Fatalf("Expected three events, got %d", len(writer.points))
+	}
+	if start := writer.points[1]; start.Tags["event"] != EventStart || start.Fields["reason"] != "upgrade" {
+		t.Errorf("Unexpected start point %+v", start)
+	}
+	if stop := writer.points[2]; stop.Tags["event"] != EventStop || stop.Fields["reason"] != "upgrade" {
+		t.Errorf("Unexpected stop point %+v", stop)
+	}
+}
+
+func TestNilRecorder(t *testing.T) {
+	var r *Recorder
+	r.Started()
+	r.Upgraded()
+	r.Restarted("watchdog")
+	r.Stopped("signal")
+	r.HandedOver()
+}
@@ -0,0 +1,127 @@
+// Package lightning filters lightning strike events, which the AS3935 sensor
+// also reports for electrical disturbers that are not real strikes
+package lightning
+
+import (
+	"sync"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/metrics"
+)
+
+// ReportType is the report type of strike events
+const ReportType = "evt_strike"
+
+// Enabled reports whether cfg sets any strike filter
+func Enabled(cfg *config.Config) bool {
+	return cfg.Lightning_Min_Energy > 0 || cfg.Lightning_Min_Distance > 0 || cfg.Lightning_Min_Strikes > 1
+}
+
+// station holds the recent strikes of one station
+type station struct {
+	// recent are the timestamps of strikes that passed the energy and
+	// distance limits within the window
+	recent []int64
+	// pending are strikes held back until enough strikes were seen
+	pending []*influx.Data
+}
+
+// Filter drops strike events below Lightning_Min_Energy or closer than
+// Lightning_Min_Distance, and holds strikes back until
+// Lightning_Min_Strikes have occurred within Lightning_Window. Once the count
+// is reached the held strikes are released with the strike that completed
+// it, so a real storm is written in full while an isolated disturber is not.
+// Other report types pass unchanged.
+type Filter struct {
+	minEnergy   float64
+	minDistance float64
+	minStrikes  int
+	window      int64
+
+	mu       sync.Mutex
+	stations map[string]*station
+}
+
+// NewFilter creates a Filter from cfg
+func NewFilter(cfg *config.Config) *Filter {
+	return &Filter{
+		minEnergy:   cfg.Lightning_Min_Energy,
+		minDistance: cfg.Lightning_Min_Distance,
+		minStrikes:  max(cfg.Lightning_Min_Strikes, 1),
+		window:      int64(cfg.Lightning_Window.Seconds()),
+		stations:    make(map[string]*station),
+	}
+}
+
+// Enrich removes strikes that do not pass the filter
+func (f *Filter) Enrich(points []*influx.Data) []*influx.Data {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var kept []*influx.Data
+	for _, m := range points {
+		if m.ReportType != ReportType {
+			kept = append(kept, m)
+			continue
+		}
+		if reason := f.reject(m); reason != "" {
+			metrics.StrikesFiltered.WithLabelValues(reason).Inc()
+			continue
+		}
+		kept = append(kept, f.count(m)...)
+	}
+	return kept
+}
+
+// reject returns why a single strike is dropped, or "" to keep it
+func (f *Filter) reject(m *influx.Data) string {
+	if energy, ok := m.Float("strike_energy"); ok && energy < f.minEnergy {
+		return "energy"
+	}
+	if distance, ok := m.Float("strike_distance"); ok && distance < f.minDistance {
+		return "distance"
+	}
+	return ""
+}
+
+// count records a strike and returns the strikes to write: none while the
+// station has fewer than minStrikes in the window, otherwise the held ones
+// and this one
+func (f *Filter) count(m *influx.Data) []*influx.Data {
+	if f.minStrikes <= 1 {
+		return []*influx.Data{m}
+	}
+
+	s, ok := f.stations[m.Tags["station"]]
+	if !ok {
+		s = &station{}
+		f.stations[m.Tags["station"]] = s
+	}
+
+	since := m.Timestamp - f.window
+	recent := s.recent[:0]
+	for _, ts := range s.recent {
+		if ts >= since {
+			recent = append(recent, ts)
+		}
+	}
+	s.recent = append(recent, m.Timestamp)
+
+	var pending []*influx.Data
+	for _, p := range s.pending {
+		if p.Timestamp >= since {
+			pending = append(pending, p)
+		} else {
+			metrics.StrikesFiltered.WithLabelValues("isolated").Inc()
+		}
+	}
+	s.pending = append(pending, m)
+
+	if len(s.recent) < f.minStrikes {
+		return nil
+	}
+	released := s.pending
+	s.pending = nil
+	return released
+}
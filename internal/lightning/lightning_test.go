@@ -0,0 +1,71 @@
+package lightning
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func strike(station string, timestamp int64, distance float64, energy int64) *influx.Data {
+	m := influx.New()
+	m.Name = "lightning"
+	m.ReportType = ReportType
+	m.Timestamp = timestamp
+	m.Tags["station"] = station
+	m.Fields = influx.Fields{"strike_distance": distance, "strike_energy": energy}
+	return m
+}
+
+func TestFilterEnergyAndDistance(t *testing.T) {
+	f := NewFilter(&config.Config{Lightning_Min_Energy: 1000, Lightning_Min_Distance: 2})
+
+	obs := influx.New()
+	obs.ReportType = "obs_st"
+	points := []*influx.Data{
+		strike("ST-1", 100, 12, 5000),
+		strike("ST-1", 101, 12, 200),
+		strike("ST-1", 102, 1, 5000),
+		obs,
+	}
+	kept := f.Enrich(points)
+	if len(kept) != 2 || kept[0].Timestamp != 100 || kept[1] != obs {
+		t.Errorf("Expected the strong distant strike and the observation, got %v", kept)
+	}
+}
+
+func TestFilterMinStrikes(t *testing.T) {
+	f := NewFilter(&config.Config{Lightning_Min_Strikes: 3, Lightning_Window: 10 * time.Minute})
+
+	// An isolated strike is held and eventually forgotten
+	if kept := f.Enrich([]*influx.Data{strike("ST-1", 1000, 10, 5000)}); len(kept) != 0 {
+		t.Fatalf("Expected the first strike to be held, got %d", len(kept))
+	}
+	if kept := f.Enrich([]*influx.Data{strike("ST-1", 2000, 10, 5000)}); len(kept) != 0 {
+		t.Fatalf("Expected the strike after the window to be held, got %d", len(kept))
+	}
+	if kept := f.Enrich([]*influx.Data{strike("ST-2", 2010, 10, 5000)}); len(kept) != 0 {
+		t.Fatalf("Expected strikes of another station not to count, got %d", len(kept))
+	}
+
+	// The third strike within the window releases the held one
+	kept := f.Enrich([]*influx.Data{strike("ST-1", 2100, 10, 5000), strike("ST-1", 2200, 10, 5000)})
+	if len(kept) != 3 || kept[0].Timestamp != 2000 || kept[2].Timestamp != 2200 {
+		t.Fatalf("Expected three strikes from 2000, got %v", kept)
+	}
+
+	// Later strikes in the storm pass directly
+	if kept := f.Enrich([]*influx.Data{strike("ST-1", 2300, 10, 5000)}); len(kept) != 1 {
+		t.Errorf("Expected the next strike to pass, got %d", len(kept))
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	if Enabled(&config.Config{Lightning_Min_Strikes: 1}) {
+		t.Error("A minimum of one strike should not enable the filter")
+	}
+	if !Enabled(&config.Config{Lightning_Min_Distance: 1}) {
+		t.Error("Expected a minimum distance to enable the filter")
+	}
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"io"
 	"log/slog"
 	"os"
 
@@ -14,6 +15,12 @@ type AppLogger struct {
 
 // New creates a new structured logger based on configuration
 func New(cfg *config.Config) *AppLogger {
+	return NewWriter(cfg, os.Stdout)
+}
+
+// NewWriter creates a logger like New that writes to w, for commands whose
+// standard output is data
+func NewWriter(cfg *config.Config, w io.Writer) *AppLogger {
 	var handler slog.Handler
 
 	opts := &slog.HandlerOptions{
@@ -26,11 +33,16 @@ func New(cfg *config.Config) *AppLogger {
 
 	// Use JSON handler for production, text handler for development
 	if cfg.Debug {
-		handler = slog.NewTextHandler(os.Stdout, opts)
+		handler = slog.NewTextHandler(w, opts)
 	} else {
-		handler = slog.NewJSONHandler(os.Stdout, opts)
+		handler = slog.NewJSONHandler(w, opts)
 	}
 
 	logger := slog.New(handler)
 	return &AppLogger{Logger: logger}
 }
+
+// With returns a logger that includes the given attributes in every record
+func (l *AppLogger) With(args ...any) *AppLogger {
+	return &AppLogger{Logger: l.Logger.With(args...)}
+}
@@ -224,3 +224,18 @@ func BenchmarkLoggerJSON(b *testing.B) {
 		logger.Info("benchmark message", "iteration", i, "data", "test")
 	}
 }
+
+func TestLoggerWith(t *testing.T) {
+	var buf bytes.Buffer
+	base := &AppLogger{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+
+	base.With("packet_id", "abcd1234").Info("parsed")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to decode log entry: %v", err)
+	}
+	if entry["packet_id"] != "abcd1234" {
+		t.Errorf("Expected packet_id attribute, got %v", entry["packet_id"])
+	}
+}
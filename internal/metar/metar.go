@@ -0,0 +1,119 @@
+// Package metar encodes observations as pseudo-METAR reports. Visibility
+// and clouds cannot be measured by a Tempest and are omitted.
+package metar
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/meteo"
+)
+
+// variableBelow is the wind speed in knots below which the direction is
+// reported as variable
+const variableBelow = 3
+
+// Encode returns a pseudo-METAR for the obs_st point p of station, e.g.
+// "METAR ST-00012345 121855Z AUTO 31008G16KT -RA 12/07 Q1013".
+// elevation in meters is used to reduce the station pressure to QNH.
+func Encode(station string, p *influx.Data, elevation float64) string {
+	parts := []string{
+		"METAR",
+		station,
+		time.Unix(p.Timestamp, 0).UTC().Format("021504Z"),
+		"AUTO",
+	}
+	if wind := wind(p); wind != "" {
+		parts = append(parts, wind)
+	}
+	if weather := weather(p); weather != "" {
+		parts = append(parts, weather)
+	}
+	if temp := temperatures(p); temp != "" {
+		parts = append(parts, temp)
+	}
+	if pressure, ok := p.Float("p"); ok && pressure > 0 {
+		qnh := meteo.AltimeterSetting(pressure, elevation)
+		parts = append(parts, fmt.Sprintf("Q%04d", int(math.Floor(qnh))))
+	}
+	return strings.Join(parts, " ")
+}
+
+// wind encodes direction, speed and gusts in knots
+func wind(p *influx.Data) string {
+	avg, ok := p.Float("wind_avg")
+	if !ok {
+		return ""
+	}
+	speed := int(math.Round(meteo.MsToKnots(avg)))
+	if speed == 0 {
+		return "00000KT"
+	}
+
+	dir := "VRB"
+	if d, ok := p.Float("wind_direction"); ok && speed >= variableBelow {
+		tens := int(math.Round(d/10)) * 10 % 360
+		if tens == 0 {
+			tens = 360
+		}
+		dir = fmt.Sprintf("%03d", tens)
+	}
+
+	s := fmt.Sprintf("%s%02d", dir, speed)
+	if g, ok := p.Float("wind_gust"); ok {
+		// METAR only reports gusts exceeding the mean by 10 knots or more
+		if gust := int(math.Round(meteo.MsToKnots(g))); gust-speed >= 10 {
+			s += fmt.Sprintf("G%02d", gust)
+		}
+	}
+	return s + "KT"
+}
+
+// weather encodes present precipitation from the one-minute accumulation
+func weather(p *influx.Data) string {
+	rain, _ := p.Float("precipitation")
+	if rain <= 0 {
+		return ""
+	}
+	code := "RA"
+	switch t, _ := p.Float("precipitation_type"); t {
+	case 2:
+		code = "GR"
+	case 3:
+		code = "RAGR"
+	}
+	switch rate := rain * 60; {
+	case rate >= meteo.HeavyRain:
+		return "+" + code
+	case rate >= meteo.ModerateRain:
+		return code
+	default:
+		return "-" + code
+	}
+}
+
+// temperatures encodes temperature and dew point as TT/DD, with M marking
+// negative values
+func temperatures(p *influx.Data) string {
+	temp, ok := p.Float("temp")
+	if !ok {
+		return ""
+	}
+	s := celsius(temp) + "/"
+	if dew, ok := p.Float("dew_point"); ok {
+		s += celsius(dew)
+	}
+	return s
+}
+
+// celsius formats a whole-degree METAR temperature
+func celsius(v float64) string {
+	r := int(math.Round(v))
+	if r < 0 || (r == 0 && v < 0) {
+		return fmt.Sprintf("M%02d", -r)
+	}
+	return fmt.Sprintf("%02d", r)
+}
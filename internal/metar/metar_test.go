@@ -0,0 +1,67 @@
+package metar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func TestEncode(t *testing.T) {
+	at := time.Date(2024, 3, 12, 18, 55, 0, 0, time.UTC).Unix()
+
+	tests := []struct {
+		name      string
+		fields    influx.Fields
+		elevation float64
+		want      string
+	}{
+		{
+			"full report",
+			influx.Fields{"wind_avg": 4.12, "wind_gust": 9.5, "wind_direction": int64(312), "temp": 12.4, "dew_point": 6.8, "p": 1013.8, "precipitation": 0.02},
+			0,
+			"METAR ST-1 121855Z AUTO 31008G18KT -RA 12/07 Q1013",
+		},
+		{
+			"small gusts and negative temperatures",
+			influx.Fields{"wind_avg": 5.0, "wind_gust": 7.0, "wind_direction": int64(2), "temp": -0.4, "dew_point": -5.6},
+			0,
+			"METAR ST-1 121855Z AUTO 36010KT M00/M06",
+		},
+		{
+			"calm and light variable",
+			influx.Fields{"wind_avg": 0.1, "temp": 20.0},
+			0,
+			"METAR ST-1 121855Z AUTO 00000KT 20/",
+		},
+		{
+			"variable",
+			influx.Fields{"wind_avg": 1.0, "wind_direction": int64(90)},
+			0,
+			"METAR ST-1 121855Z AUTO VRB02KT",
+		},
+		{
+			"heavy hail",
+			influx.Fields{"precipitation": 0.2, "precipitation_type": int64(2)},
+			0,
+			"METAR ST-1 121855Z AUTO +GR",
+		},
+		{
+			"pressure reduced to sea level",
+			influx.Fields{"p": 834.6},
+			1609,
+			"METAR ST-1 121855Z AUTO Q1013",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := influx.New()
+			p.Timestamp = at
+			p.Fields = tt.fields
+			if got := Encode("ST-1", p, tt.elevation); got != tt.want {
+				t.Errorf("Encode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+// Package meteo holds meteorological formulas shared by the enrichers and
+// outputs
+package meteo
+
+import (
+	"math"
+	"strings"
+)
+
+// Rain rates in mm/h at which rain counts as moderate or heavy
+const (
+	ModerateRain = 2.5
+	HeavyRain    = 7.6
+)
+
+// standardPressure is the ICAO standard atmosphere sea-level pressure in hPa
+const standardPressure = 1013.25
+
+// MsToKnots converts a speed in m/s to knots
+func MsToKnots(ms float64) float64 {
+	return ms * 1.943844
+}
+
+// AltimeterSetting reduces a station pressure in hPa measured at elevation
+// meters to QNH, the sea-level pressure of the standard atmosphere used in
+// aviation (NWS altimeter setting formula)
+func AltimeterSetting(stationPressure, elevation float64) float64 {
+	const n = 0.190284
+	p := stationPressure - 0.3
+	k := math.Pow(standardPressure, n) * 0.0065 / 288.15
+	return p * math.Pow(1+k*elevation/math.Pow(p, n), 1/n)
+}
+
+// SeaLevelPressure reduces a station pressure in hPa measured at elevation
+// meters and an air temperature in °C to the mean sea-level pressure with
+// the hypsometric equation, as reported by METAR stations
+func SeaLevelPressure(stationPressure, elevation, temp float64) float64 {
+	lapse := 0.0065 * elevation
+	return stationPressure * math.Pow(1-lapse/(temp+lapse+273.15), -5.257)
+}
+
+// WetBulb estimates the wet-bulb temperature in °C from the air temperature
+// and dew point in °C with the one-third rule, which is accurate to about
+// 1 °C near freezing
+func WetBulb(temp, dewPoint float64) float64 {
+	return temp - (temp-dewPoint)/3
+}
+
+// CloudBase estimates the height in meters above the station of the base
+// of cumulus clouds, the lifted condensation level, from the air
+// temperature and dew point in °C with Espy's approximation of 125 m per
+// degree of spread
+func CloudBase(temp, dewPoint float64) float64 {
+	return max(125*(temp-dewPoint), 0)
+}
+
+// Humidex returns the Canadian humidex in °C from the air temperature and
+// dew point in °C (Masterton and Richardson, Environment Canada)
+func Humidex(temp, dewPoint float64) float64 {
+	e := 6.11 * math.Exp(5417.7530*(1/273.16-1/(273.15+dewPoint)))
+	return temp + 0.5555*(e-10)
+}
+
+// HeatIndex returns the NWS heat index in °C from the air temperature in
+// °C and relative humidity in %, using Steadman's simple formula and, when
+// that reaches 80 °F, the Rothfusz regression with its adjustments. ok is
+// false below 80 °F (26.7 °C), where the index is not defined and the
+// temperature is returned.
+func HeatIndex(temp, humidity float64) (index float64, ok bool) {
+	t := temp*9/5 + 32
+	if t < 80 {
+		return temp, false
+	}
+	hi := 0.5 * (t + 61 + (t-68)*1.2 + humidity*0.094)
+	if (hi+t)/2 >= 80 {
+		hi = -42.379 + 2.04901523*t + 10.14333127*humidity -
+			0.22475541*t*humidity - 0.00683783*t*t -
+			0.05481717*humidity*humidity + 0.00122874*t*t*humidity +
+			0.00085282*t*humidity*humidity - 0.00000199*t*t*humidity*humidity
+		switch {
+		case humidity < 13 && t >= 80 && t <= 112:
+			hi -= (13 - humidity) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+		case humidity > 85 && t >= 80 && t <= 87:
+			hi += (humidity - 85) / 10 * (87 - t) / 5
+		}
+	}
+	return (hi - 32) * 5 / 9, true
+}
+
+// WindChill returns the NWS wind chill in °C from the air temperature in
+// °C and wind speed in m/s. ok is false above 10 °C or below 1.34 m/s
+// (3 mph), where the formula is not defined and the temperature is
+// returned.
+func WindChill(temp, wind float64) (chill float64, ok bool) {
+	kmh := wind * 3.6
+	if temp > 10 || kmh < 4.828 {
+		return temp, false
+	}
+	v := math.Pow(kmh, 0.16)
+	return 13.12 + 0.6215*temp - 11.37*v + 0.3965*temp*v, true
+}
+
+// FeelsLike returns the apparent temperature in °C the NWS reports: the
+// wind chill or heat index where one is defined, and the air temperature
+// in between
+func FeelsLike(temp, humidity, wind float64) float64 {
+	if chill, ok := WindChill(temp, wind); ok {
+		return chill
+	}
+	index, _ := HeatIndex(temp, humidity)
+	return index
+}
+
+// ReferenceET returns the FAO-56 Penman-Monteith reference
+// evapotranspiration ET0 of a short grass surface in mm/h from the air
+// temperature in °C, relative humidity in %, wind speed at 2 m in m/s,
+// global solar radiation in W/m² and station pressure in hPa. clearSky is
+// the ratio of the radiation to its clear-sky value, which sets the net
+// longwave radiation; daytime selects the soil heat flux of day or night.
+// Negative values, from dew at night, are returned as 0.
+func ReferenceET(temp, humidity, wind, radiation, pressure, clearSky float64, daytime bool) float64 {
+	const (
+		albedo = 0.23
+		sigma  = 2.043e-10 // Stefan-Boltzmann constant in MJ/(m² h K⁴)
+	)
+	es := 0.6108 * math.Exp(17.27*temp/(temp+237.3)) // kPa
+	ea := es * humidity / 100
+	delta := 4098 * es / math.Pow(temp+237.3, 2)
+	gamma := 0.000665 * pressure / 10
+
+	rs := radiation * 0.0036 // MJ/(m² h)
+	ratio := math.Min(math.Max(clearSky, 0.3), 1)
+	rnl := sigma * math.Pow(temp+273.16, 4) * (0.34 - 0.14*math.Sqrt(ea)) * (1.35*ratio - 0.35)
+	rn := (1-albedo)*rs - rnl
+	g := 0.5 * rn
+	if daytime {
+		g = 0.1 * rn
+	}
+
+	et0 := (0.408*delta*(rn-g) + gamma*37/(temp+273)*wind*(es-ea)) / (delta + gamma*(1+0.34*wind))
+	return max(et0, 0)
+}
+
+// toMetric converts values from the units other devices may report in to
+// the metric units the collector stores, keyed by lower-case unit name
+var toMetric = map[string]func(float64) float64{
+	"f":    func(v float64) float64 { return (v - 32) * 5 / 9 },
+	"inhg": func(v float64) float64 { return v * 33.8639 },
+	"mph":  func(v float64) float64 { return v * 0.44704 },
+	"km/h": func(v float64) float64 { return v / 3.6 },
+	"kn":   func(v float64) float64 { return v / MsToKnots(1) },
+	"in":   func(v float64) float64 { return v * 25.4 },
+	"mi":   func(v float64) float64 { return v * 1.609344 },
+	"ft":   func(v float64) float64 { return v * 0.3048 },
+}
+
+// ToMetric converts value from unit (f, inhg, mph, km/h, kn, in, mi or ft)
+// to °C, hPa, m/s, mm, km or m. ok is false for an unknown unit.
+func ToMetric(unit string, value float64) (converted float64, ok bool) {
+	convert, ok := toMetric[strings.ToLower(unit)]
+	if !ok {
+		return value, false
+	}
+	return convert(value), true
+}
+
+// FromMetric converts value from °C, hPa, m/s, mm, km or m to unit, the
+// inverse of ToMetric. ok is false for an unknown unit.
+func FromMetric(unit string, value float64) (converted float64, ok bool) {
+	convert, ok := toMetric[strings.ToLower(unit)]
+	if !ok {
+		return value, false
+	}
+	// Every conversion is linear: convert(v) = a*v + b
+	b := convert(0)
+	a := convert(1) - b
+	return (value - b) / a, true
+}
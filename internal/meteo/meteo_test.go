@@ -0,0 +1,153 @@
+package meteo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAltimeterSetting(t *testing.T) {
+	tests := []struct {
+		name      string
+		pressure  float64
+		elevation float64
+		want      float64
+	}{
+		{"sea level", 1013.55, 0, 1013.25},
+		{"standard atmosphere at 1609 m", 834.6, 1609, 1013.25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AltimeterSetting(tt.pressure, tt.elevation); math.Abs(got-tt.want) > 0.5 {
+				t.Errorf("AltimeterSetting(%v, %v) = %.2f, want about %.2f", tt.pressure, tt.elevation, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeaLevelPressure(t *testing.T) {
+	if got := SeaLevelPressure(1013.25, 0, 15); got != 1013.25 {
+		t.Errorf("SeaLevelPressure at sea level = %.2f, want 1013.25", got)
+	}
+	// Standard atmosphere at 1609 m
+	if got := SeaLevelPressure(834.6, 1609, 4.54); math.Abs(got-1013.25) > 1 {
+		t.Errorf("SeaLevelPressure(834.6, 1609, 4.54) = %.2f, want about 1013.25", got)
+	}
+}
+
+func TestReferenceET(t *testing.T) {
+	// FAO-56 example 19: hourly ET0 at N. Iguaçu, Brazil, 2 October
+	if got := ReferenceET(38, 52, 3.3, 2450/3.6, 1012, 0.922, true); math.Abs(got-0.63) > 0.02 {
+		t.Errorf("ReferenceET by day = %.3f mm/h, want about 0.63", got)
+	}
+	if got := ReferenceET(28, 90, 1.9, 0, 1012, 0.8, false); got > 0.01 {
+		t.Errorf("ReferenceET at night = %.3f mm/h, want about 0", got)
+	}
+}
+
+func TestMsToKnots(t *testing.T) {
+	if got := MsToKnots(10); math.Abs(got-19.44) > 0.01 {
+		t.Errorf("MsToKnots(10) = %.2f, want 19.44", got)
+	}
+}
+
+func TestWetBulb(t *testing.T) {
+	if got := WetBulb(3, -3); math.Abs(got-1) > 0.01 {
+		t.Errorf("WetBulb(3, -3) = %.2f, want 1.00", got)
+	}
+	if got := WetBulb(0, 0); got != 0 {
+		t.Errorf("WetBulb(0, 0) = %.2f, want 0.00 when saturated", got)
+	}
+}
+
+func TestHumidex(t *testing.T) {
+	// Environment Canada: 30 °C with a dew point of 15 °C feels like 34
+	if got := Humidex(30, 15); math.Abs(got-34) > 0.5 {
+		t.Errorf("Humidex(30, 15) = %.2f, want about 34", got)
+	}
+	if got := Humidex(30, 25); math.Abs(got-42) > 0.5 {
+		t.Errorf("Humidex(30, 25) = %.2f, want about 42", got)
+	}
+}
+
+func TestHeatIndex(t *testing.T) {
+	tests := []struct {
+		temp, humidity, want float64
+		ok                   bool
+	}{
+		// NWS heat index chart, converted from °F
+		{32.2, 50, 35, true},   // 90 °F, 50% -> 95 °F
+		{26.7, 40, 26.7, true}, // 80 °F, 40% -> 80 °F
+		{37.8, 60, 53.9, true}, // 100 °F, 60% -> 129 °F
+		{20, 50, 20, false},    // not defined below 80 °F
+	}
+	for _, tt := range tests {
+		if got, ok := HeatIndex(tt.temp, tt.humidity); ok != tt.ok || math.Abs(got-tt.want) > 0.6 {
+			t.Errorf("HeatIndex(%v, %v) = %.2f, %v; want about %.2f, %v", tt.temp, tt.humidity, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestWindChill(t *testing.T) {
+	// NWS wind chill chart: 0 °F at 15 mph feels like -19 °F
+	if got, ok := WindChill(-17.78, 6.7056); !ok || math.Abs(got-(-28.33)) > 0.5 {
+		t.Errorf("WindChill(-17.78, 6.71) = %.2f, %v; want about -28.33", got, ok)
+	}
+	if got, ok := WindChill(15, 10); ok || got != 15 {
+		t.Errorf("WindChill(15, 10) = %.2f, %v; want the temperature above 10 °C", got, ok)
+	}
+	if _, ok := WindChill(0, 1); ok {
+		t.Error("Expected no wind chill in calm air")
+	}
+}
+
+func TestFeelsLike(t *testing.T) {
+	if got := FeelsLike(-5, 80, 10); got >= -5 {
+		t.Errorf("FeelsLike(-5, 80, 10) = %.2f, want the wind chill", got)
+	}
+	if got, _ := HeatIndex(32.2, 50); FeelsLike(32.2, 50, 3) != got {
+		t.Errorf("FeelsLike(32.2, 50, 3) = %.2f, want the heat index %.2f", FeelsLike(32.2, 50, 3), got)
+	}
+	if got := FeelsLike(18, 50, 5); got != 18 {
+		t.Errorf("FeelsLike(18, 50, 5) = %.2f, want the temperature", got)
+	}
+}
+
+func TestToMetric(t *testing.T) {
+	tests := []struct {
+		unit  string
+		value float64
+		want  float64
+	}{
+		{"F", 50, 10},
+		{"inHg", 29.92, 1013.2},
+		{"mph", 10, 4.47},
+		{"km/h", 36, 10},
+		{"kn", 19.44, 10},
+		{"in", 1, 25.4},
+		{"mi", 1, 1.61},
+	}
+	for _, tt := range tests {
+		if got, ok := ToMetric(tt.unit, tt.value); !ok || math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("ToMetric(%q, %v) = %.2f, %v; want %.2f", tt.unit, tt.value, got, ok, tt.want)
+		}
+	}
+	if _, ok := ToMetric("furlong", 1); ok {
+		t.Error("Expected an unknown unit to be rejected")
+	}
+}
+
+func TestFromMetric(t *testing.T) {
+	for _, unit := range []string{"F", "inHg", "mph", "km/h", "kn", "in", "mi"} {
+		metric, _ := ToMetric(unit, 12.5)
+		if got, ok := FromMetric(unit, metric); !ok || math.Abs(got-12.5) > 1e-9 {
+			t.Errorf("FromMetric(%q, %v) = %v, %v; want 12.5", unit, metric, got, ok)
+		}
+	}
+	if got, _ := FromMetric("f", 0); math.Abs(got-32) > 1e-9 {
+		t.Errorf("FromMetric(\"f\", 0) = %v, want 32", got)
+	}
+	if _, ok := FromMetric("furlong", 1); ok {
+		t.Error("Expected an unknown unit to be rejected")
+	}
+}
@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Namespace prefixes every metric exported by the collector
+const Namespace = "tempest_influx"
+
+// Registry holds all collector metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	// PacketsReceived counts packets taken from the input for processing
+	PacketsReceived = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "packets_received_total",
+		Help:      "Packets received from the input and handed to processing.",
+	})
+
+	// PacketsParsed counts packets decoded into a point, by report type
+	PacketsParsed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "packets_parsed_total",
+		Help:      "Packets decoded into a point, by report type.",
+	}, []string{"report_type"})
+
+	// ParseErrors counts packets that could not be decoded
+	ParseErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "parse_errors_total",
+		Help:      "Packets that could not be decoded, by reason: parse or schema.",
+	}, []string{"reason"})
+
+	// LastSeen is the time a device or hub was last heard from
+	LastSeen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "last_seen_timestamp_seconds",
+		Help:      "Unix time a packet of the station was last received.",
+	}, []string{"station"})
+
+	// OutputWrites counts writes to each output, by result
+	OutputWrites = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "output_writes_total",
+		Help:      "Writes of points to an output, by result: success or failure.",
+	}, []string{"output", "result"})
+
+	// InfluxRateLimited counts write requests rejected with 429 Too Many Requests
+	InfluxRateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "influx_rate_limited_total",
+		Help:      "Write requests rejected by InfluxDB with 429 Too Many Requests.",
+	}, []string{"target"})
+
+	// InfluxRetries counts write requests repeated after a transient failure
+	InfluxRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "influx_retries_total",
+		Help:      "Write requests repeated after a transient failure.",
+	}, []string{"target"})
+
+	// InfluxInFlight tracks write requests currently in flight
+	InfluxInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "influx_writes_in_flight",
+		Help:      "Write requests currently in flight.",
+	}, []string{"target"})
+
+	// InfluxWriteDuration observes the latency of write requests
+	InfluxWriteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "influx_write_duration_seconds",
+		Help:      "Latency of write requests to InfluxDB.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"target"})
+
+	// InfluxPayloadBytes observes the size of write request bodies
+	InfluxPayloadBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "influx_write_payload_bytes",
+		Help:      "Size of write request bodies sent to InfluxDB.",
+		Buckets:   prometheus.ExponentialBuckets(128, 2, 12),
+	}, []string{"target"})
+
+	// InfluxPayloadLines observes the number of lines in write request bodies
+	InfluxPayloadLines = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "influx_write_payload_lines",
+		Help:      "Number of line protocol lines in write requests sent to InfluxDB.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+	}, []string{"target"})
+
+	// InfluxFlushes counts writes of buffered points, by what triggered them
+	InfluxFlushes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "influx_flushes_total",
+		Help:      "Writes of buffered points, by trigger: size, interval or close.",
+	}, []string{"target", "trigger"})
+
+	// SpoolBytes tracks the size of the points spooled for InfluxDB
+	SpoolBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "spool_bytes",
+		Help:      "Size of the points spooled on disk while InfluxDB is unavailable.",
+	})
+
+	// SpoolWrites tracks the number of writes spooled for InfluxDB
+	SpoolWrites = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "spool_writes",
+		Help:      "Writes spooled on disk while InfluxDB is unavailable.",
+	})
+
+	// StreamDropped counts points not delivered to slow live subscribers
+	StreamDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "stream_dropped_total",
+		Help:      "Points dropped because a live subscriber was not keeping up.",
+	}, []string{"stream"})
+
+	// OutOfRange counts field values outside their configured bounds
+	OutOfRange = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "out_of_range_total",
+		Help:      "Field values outside their configured plausible range, by policy applied.",
+	}, []string{"field", "policy"})
+
+	// ClockOffset is the last measured offset of the host clock
+	ClockOffset = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "clock_offset_seconds",
+		Help:      "Offset of the NTP server's clock from the host clock; positive when the host is behind.",
+	})
+
+	// Duplicates counts observations dropped because they were already received
+	Duplicates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "duplicates_total",
+		Help:      "Observations dropped because another hub or path already delivered them, by hub of the copy.",
+	}, []string{"hub"})
+
+	// TimestampCollisions counts points that shared a series and timestamp
+	// with a different point
+	TimestampCollisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "timestamp_collisions_total",
+		Help:      "Points that would have overwritten a different point of the same series and timestamp, by measurement.",
+	}, []string{"measurement"})
+
+	// StrikesFiltered counts lightning strikes dropped by the strike filter
+	StrikesFiltered = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "strikes_filtered_total",
+		Help:      "Lightning strike events dropped as likely disturbers, by reason.",
+	}, []string{"reason"})
+)
+
+func init() {
+	Registry.MustRegister(
+		PacketsReceived,
+		PacketsParsed,
+		ParseErrors,
+		LastSeen,
+		OutputWrites,
+		InfluxRateLimited,
+		InfluxRetries,
+		InfluxInFlight,
+		InfluxWriteDuration,
+		InfluxPayloadBytes,
+		InfluxPayloadLines,
+		InfluxFlushes,
+		SpoolBytes,
+		SpoolWrites,
+		StreamDropped,
+		OutOfRange,
+		Duplicates,
+		TimestampCollisions,
+		ClockOffset,
+		StrikesFiltered,
+	)
+}
@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRegistryGathers(t *testing.T) {
+	InfluxRateLimited.WithLabelValues("test").Inc()
+
+	families, err := Registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	if len(families) == 0 {
+		t.Fatal("Expected registered metric families")
+	}
+
+	if got := testutil.ToFloat64(InfluxRateLimited.WithLabelValues("test")); got != 1 {
+		t.Errorf("Expected counter 1, got %v", got)
+	}
+}
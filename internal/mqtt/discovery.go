@@ -0,0 +1,144 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/tempest"
+)
+
+// haSensor describes a field announced to Home Assistant
+type haSensor struct {
+	field       string
+	reportType  string
+	name        string
+	deviceClass string
+	unit        string
+	stateClass  string
+}
+
+// haSensors lists the fields announced through Home Assistant MQTT
+// discovery with their metric units; fields converted to other units are
+// announced with those.
+var haSensors = []haSensor{
+	{"temp", "obs_st", "Temperature", "temperature", "°C", "measurement"},
+	{"dew_point", "obs_st", "Dew point", "temperature", "°C", "measurement"},
+	{"relative_humidity", "obs_st", "Humidity", "humidity", "%", "measurement"},
+	{"p", "obs_st", "Station pressure", "atmospheric_pressure", "hPa", "measurement"},
+	{"sea_level_pressure", "obs_st", "Sea-level pressure", "atmospheric_pressure", "hPa", "measurement"},
+	{"pressure_trend", "obs_st", "Pressure trend", "", "hPa", "measurement"},
+	{"wind_avg", "obs_st", "Wind speed", "wind_speed", "m/s", "measurement"},
+	{"wind_gust", "obs_st", "Wind gust", "wind_speed", "m/s", "measurement"},
+	{"wind_lull", "obs_st", "Wind lull", "wind_speed", "m/s", "measurement"},
+	{"wind_direction", "obs_st", "Wind direction", "", "°", "measurement"},
+	{"uv", "obs_st", "UV index", "", "UV index", "measurement"},
+	{"illuminance", "obs_st", "Illuminance", "illuminance", "lx", "measurement"},
+	{"solar_radiation", "obs_st", "Solar radiation", "irradiance", "W/m²", "measurement"},
+	{"precipitation", "obs_st", "Precipitation", "precipitation", "mm", "measurement"},
+	{"rain_rate_mm_h", "obs_st", "Rain rate", "precipitation_intensity", "mm/h", "measurement"},
+	{"rain_last_hour", "obs_st", "Rain last hour", "precipitation", "mm", "measurement"},
+	{"rain_today", "obs_st", "Rain today", "precipitation", "mm", "total_increasing"},
+	{"strike_count", "obs_st", "Lightning strikes", "", "", "measurement"},
+	{"strike_distance", "obs_st", "Lightning distance", "distance", "km", "measurement"},
+	{"battery", "obs_st", "Battery", "voltage", "V", "measurement"},
+	{"rapid_wind_speed", "rapid_wind", "Rapid wind speed", "wind_speed", "m/s", "measurement"},
+	{"rapid_wind_direction", "rapid_wind", "Rapid wind direction", "", "°", "measurement"},
+}
+
+// objectIDInvalid matches characters Home Assistant rejects in discovery
+// node and object IDs
+var objectIDInvalid = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// haDevice groups the sensors of one station into a Home Assistant device
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+// haConfig is the payload of a Home Assistant sensor discovery message
+type haConfig struct {
+	Name              string   `json:"name"`
+	UniqueID          string   `json:"unique_id"`
+	StateTopic        string   `json:"state_topic"`
+	ValueTemplate     string   `json:"value_template"`
+	DeviceClass       string   `json:"device_class,omitempty"`
+	UnitOfMeasurement string   `json:"unit_of_measurement,omitempty"`
+	StateClass        string   `json:"state_class,omitempty"`
+	Device            haDevice `json:"device"`
+}
+
+// discoveryMessage is a retained discovery config and the topic it is
+// published to
+type discoveryMessage struct {
+	topic   string
+	payload []byte
+}
+
+// discoveryMessages returns the discovery configs for the sensors of a
+// point that have not been announced yet and marks them as announced
+func (p *Publisher) discoveryMessages(point *influx.Data) ([]discoveryMessage, error) {
+	station := point.Tags["station"]
+	if station == "" {
+		return nil, nil
+	}
+	nodeID := "tempest_" + objectIDInvalid.ReplaceAllString(station, "_")
+	prefix := strings.TrimSuffix(p.config.Mqtt_HA_Discovery_Prefix, "/")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var msgs []discoveryMessage
+	for _, s := range haSensors {
+		if s.reportType != point.ReportType {
+			continue
+		}
+		if _, ok := point.Fields[s.field]; !ok {
+			continue
+		}
+		uniqueID := nodeID + "_" + s.field
+		if p.announced[uniqueID] {
+			continue
+		}
+
+		unit := s.unit
+		if symbol, ok := tempest.FieldUnit(p.config, s.field); ok {
+			unit = symbol
+		}
+		payload, err := json.Marshal(haConfig{
+			Name:              s.name,
+			UniqueID:          uniqueID,
+			StateTopic:        p.topic(point),
+			ValueTemplate:     "{{ value_json.fields." + s.field + " }}",
+			DeviceClass:       s.deviceClass,
+			UnitOfMeasurement: unit,
+			StateClass:        s.stateClass,
+			Device: haDevice{
+				Identifiers:  []string{nodeID},
+				Name:         "Tempest " + station,
+				Manufacturer: "WeatherFlow",
+				Model:        "Tempest",
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, discoveryMessage{
+			topic:   strings.Join([]string{prefix, "sensor", nodeID, s.field, "config"}, "/"),
+			payload: payload,
+		})
+		p.announced[uniqueID] = true
+	}
+	return msgs, nil
+}
+
+// forgetAnnounced makes the next points announce their sensors again, so
+// discovery is repeated after a reconnect
+func (p *Publisher) forgetAnnounced() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	clear(p.announced)
+}
@@ -0,0 +1,29 @@
+// Package mqtt connects the collector to an MQTT broker.
+package mqtt
+
+import (
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+)
+
+// Connection defaults
+const (
+	connectTimeout    = 10 * time.Second
+	disconnectQuiesce = 250 // milliseconds
+)
+
+// clientOptions returns paho options for the configured broker
+func clientOptions(cfg *config.Config, clientID string) *paho.ClientOptions {
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.Mqtt_Broker).
+		SetClientID(clientID).
+		SetConnectTimeout(connectTimeout).
+		SetAutoReconnect(true)
+	if cfg.Mqtt_Username != "" {
+		opts.SetUsername(cfg.Mqtt_Username)
+		opts.SetPassword(cfg.Mqtt_Password)
+	}
+	return opts
+}
@@ -0,0 +1,122 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/encoding"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+// ErrNotConnected is returned by Write while the broker is unreachable
+var ErrNotConnected = errors.New("not connected to MQTT broker")
+
+// Publisher is an output that publishes every point to
+// <Mqtt_Publish_Topic>/<station>/<report type>. With Mqtt_HA_Discovery it
+// also announces each station's sensors to Home Assistant.
+type Publisher struct {
+	config  *config.Config
+	logger  *logger.AppLogger
+	client  paho.Client
+	encoder encoding.Encoder
+
+	mu        sync.Mutex
+	announced map[string]bool // Home Assistant unique IDs already announced
+}
+
+// NewPublisher creates a Publisher and starts connecting in the background,
+// so an unavailable broker does not prevent the collector from starting
+func NewPublisher(cfg *config.Config, appLogger *logger.AppLogger) (*Publisher, error) {
+	enc, err := encoding.New(cfg.Mqtt_Encoding, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Publisher{
+		config:    cfg,
+		logger:    appLogger,
+		encoder:   enc,
+		announced: make(map[string]bool),
+	}
+
+	opts := clientOptions(cfg, cfg.Mqtt_Client_ID+"-publisher").
+		SetConnectRetry(true).
+		SetOnConnectHandler(func(paho.Client) {
+			appLogger.Info("Connected to MQTT broker", "broker", cfg.Mqtt_Broker, "encoding", enc.Name())
+			p.forgetAnnounced()
+		})
+	p.client = paho.NewClient(opts)
+	p.client.Connect()
+	return p, nil
+}
+
+// Name implements processor.Output
+func (p *Publisher) Name() string { return "mqtt" }
+
+// Write implements processor.Output
+func (p *Publisher) Write(ctx context.Context, points []*influx.Data) error {
+	if !p.client.IsConnectionOpen() {
+		return ErrNotConnected
+	}
+
+	for _, point := range points {
+		if p.config.Mqtt_HA_Discovery {
+			msgs, err := p.discoveryMessages(point)
+			if err != nil {
+				return fmt.Errorf("encoding discovery config: %w", err)
+			}
+			for _, msg := range msgs {
+				if err := p.publish(ctx, msg.topic, true, msg.payload); err != nil {
+					return err
+				}
+			}
+		}
+
+		payload, err := p.encoder.Encode(point)
+		if err != nil {
+			return fmt.Errorf("encoding %s point: %w", p.encoder.Name(), err)
+		}
+
+		if err := p.publish(ctx, p.topic(point), false, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publish sends one message and waits for the broker to accept it
+func (p *Publisher) publish(ctx context.Context, topic string, retained bool, payload []byte) error {
+	token := p.client.Publish(topic, byte(p.config.Mqtt_QoS), retained, payload)
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return fmt.Errorf("publishing to %s: %w", topic, err)
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Close disconnects from the broker
+func (p *Publisher) Close() {
+	p.client.Disconnect(disconnectQuiesce)
+}
+
+// topic returns the topic a point is published to
+func (p *Publisher) topic(point *influx.Data) string {
+	parts := []string{strings.TrimSuffix(p.config.Mqtt_Publish_Topic, "/")}
+	if station := point.Tags["station"]; station != "" {
+		parts = append(parts, station)
+	}
+	if point.ReportType != "" {
+		parts = append(parts, point.ReportType)
+	}
+	return strings.Join(parts, "/")
+}
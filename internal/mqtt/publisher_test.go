@@ -0,0 +1,96 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+func TestPublisherTopic(t *testing.T) {
+	p := &Publisher{config: &config.Config{Mqtt_Publish_Topic: "weather/tempest/"}}
+
+	point := influx.New()
+	point.ReportType = "rapid_wind"
+	point.Tags["station"] = "ST-00012345"
+
+	if got := p.topic(point); got != "weather/tempest/ST-00012345/rapid_wind" {
+		t.Errorf("topic() = %s", got)
+	}
+}
+
+func TestNewPublisherUnknownEncoding(t *testing.T) {
+	cfg := &config.Config{Mqtt_Broker: "tcp://127.0.0.1:1", Mqtt_Encoding: "xml"}
+	if _, err := NewPublisher(cfg, logger.New(&config.Config{})); err == nil {
+		t.Error("Expected error for unknown encoding")
+	}
+}
+
+func TestPublisherWriteNotConnected(t *testing.T) {
+	cfg := &config.Config{
+		Mqtt_Broker:        "tcp://127.0.0.1:1",
+		Mqtt_Client_ID:     "test",
+		Mqtt_Publish_Topic: "tempest",
+		Mqtt_Encoding:      config.EncodingProtobuf,
+	}
+	p, err := NewPublisher(cfg, logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("NewPublisher() error = %v", err)
+	}
+	defer p.Close()
+
+	if err := p.Write(context.Background(), []*influx.Data{influx.New()}); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Expected ErrNotConnected, got %v", err)
+	}
+}
+
+func TestPublisherDiscoveryMessages(t *testing.T) {
+	p := &Publisher{
+		config: &config.Config{
+			Mqtt_Publish_Topic:       "weather/tempest",
+			Mqtt_HA_Discovery_Prefix: "homeassistant/",
+		},
+		announced: make(map[string]bool),
+	}
+
+	point := influx.New()
+	point.ReportType = "obs_st"
+	point.Tags["station"] = "ST-00012345"
+	point.Fields = influx.Fields{"temp": 21.5, "uv": 3.1, "obs_18": 1.0}
+
+	msgs, err := p.discoveryMessages(point)
+	if err != nil {
+		t.Fatalf("discoveryMessages() error = %v", err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("Expected 2 discovery messages, got %d", len(msgs))
+	}
+	if msgs[0].topic != "homeassistant/sensor/tempest_ST-00012345/temp/config" {
+		t.Errorf("Unexpected topic %s", msgs[0].topic)
+	}
+
+	var cfg haConfig
+	if err := json.Unmarshal(msgs[0].payload, &cfg); err != nil {
+		t.Fatalf("Invalid payload: %v", err)
+	}
+	if cfg.StateTopic != "weather/tempest/ST-00012345/obs_st" || cfg.DeviceClass != "temperature" ||
+		cfg.UnitOfMeasurement != "°C" || cfg.ValueTemplate != "{{ value_json.fields.temp }}" {
+		t.Errorf("Unexpected config %+v", cfg)
+	}
+	if cfg.Device.Identifiers[0] != "tempest_ST-00012345" {
+		t.Errorf("Unexpected device %+v", cfg.Device)
+	}
+
+	// Sensors are announced once until the next reconnect
+	if msgs, _ := p.discoveryMessages(point); len(msgs) != 0 {
+		t.Errorf("Expected no repeated announcements, got %d", len(msgs))
+	}
+	p.forgetAnnounced()
+	if msgs, _ := p.discoveryMessages(point); len(msgs) != 2 {
+		t.Errorf("Expected announcements after reconnect, got %d", len(msgs))
+	}
+}
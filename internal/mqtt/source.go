@@ -0,0 +1,75 @@
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+// Addr identifies the broker and topic a message was received on
+type Addr struct {
+	Broker string
+	Topic  string
+}
+
+// Network implements net.Addr
+func (a Addr) Network() string { return "mqtt" }
+
+// String implements net.Addr
+func (a Addr) String() string { return a.Broker + "/" + a.Topic }
+
+// Source subscribes to a topic carrying bridged Tempest UDP packets and
+// hands every message to the processor unchanged
+type Source struct {
+	config *config.Config
+	logger *logger.AppLogger
+}
+
+// NewSource creates a Source for the configured broker and topic
+func NewSource(cfg *config.Config, appLogger *logger.AppLogger) *Source {
+	return &Source{config: cfg, logger: appLogger}
+}
+
+// Name implements processor.Source
+func (s *Source) Name() string { return "mqtt" }
+
+// Run connects to the broker and delivers messages until ctx is cancelled
+func (s *Source) Run(ctx context.Context, handle func(addr net.Addr, data []byte)) error {
+	topic := s.config.Mqtt_Topic
+	qos := byte(s.config.Mqtt_QoS)
+	onMessage := messageHandler(s.config.Mqtt_Broker, handle)
+
+	// Subscribe on every (re)connect since the session is not persisted
+	opts := clientOptions(s.config, s.config.Mqtt_Client_ID).
+		SetOnConnectHandler(func(c paho.Client) {
+			token := c.Subscribe(topic, qos, onMessage)
+			if token.Wait(); token.Error() != nil {
+				s.logger.Error("MQTT subscribe failed", "topic", topic, "error", token.Error().Error())
+				return
+			}
+			s.logger.Info("Subscribed to MQTT topic", "broker", s.config.Mqtt_Broker, "topic", topic)
+		}).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			s.logger.Warn("MQTT connection lost", "broker", s.config.Mqtt_Broker, "error", err.Error())
+		})
+
+	client := paho.NewClient(opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return fmt.Errorf("connecting to MQTT broker %s: %w", s.config.Mqtt_Broker, token.Error())
+	}
+	defer client.Disconnect(disconnectQuiesce)
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// messageHandler adapts paho messages to the processor packet handler
+func messageHandler(broker string, handle func(addr net.Addr, data []byte)) paho.MessageHandler {
+	return func(_ paho.Client, msg paho.Message) {
+		handle(Addr{Broker: broker, Topic: msg.Topic()}, msg.Payload())
+	}
+}
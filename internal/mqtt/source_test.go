@@ -0,0 +1,58 @@
+package mqtt
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+// mockMessage implements paho.Message
+type mockMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m mockMessage) Duplicate() bool   { return false }
+func (m mockMessage) Qos() byte         { return 0 }
+func (m mockMessage) Retained() bool    { return false }
+func (m mockMessage) Topic() string     { return m.topic }
+func (m mockMessage) MessageID() uint16 { return 1 }
+func (m mockMessage) Payload() []byte   { return m.payload }
+func (m mockMessage) Ack()              {}
+
+func TestMessageHandler(t *testing.T) {
+	var gotAddr net.Addr
+	var gotData []byte
+	handler := messageHandler("tcp://broker:1883", func(addr net.Addr, data []byte) {
+		gotAddr, gotData = addr, data
+	})
+
+	payload := []byte(`{"type":"rapid_wind","ob":[1640995200,5.5,270]}`)
+	handler(nil, mockMessage{topic: "tempest/udp", payload: payload})
+
+	if string(gotData) != string(payload) {
+		t.Errorf("Handler received %q, want %q", gotData, payload)
+	}
+	if gotAddr.Network() != "mqtt" || gotAddr.String() != "tcp://broker:1883/tempest/udp" {
+		t.Errorf("Unexpected address %s %s", gotAddr.Network(), gotAddr)
+	}
+}
+
+func TestSourceRunConnectError(t *testing.T) {
+	cfg := &config.Config{
+		Mqtt_Broker:    "tcp://127.0.0.1:1",
+		Mqtt_Topic:     "tempest/udp",
+		Mqtt_Client_ID: "test",
+	}
+	source := NewSource(cfg, logger.New(&config.Config{}))
+
+	err := source.Run(context.Background(), func(net.Addr, []byte) {
+		t.Error("Handler must not be called")
+	})
+	if err == nil {
+		t.Fatal("Expected connection error")
+	}
+}
@@ -0,0 +1,145 @@
+// Package nearcast writes the Rain Check corrected daily rain computed by
+// the WeatherFlow cloud next to the rain measured over UDP
+package nearcast
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/climate"
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/weatherflow"
+)
+
+// ReportType marks the corrected rain points
+const ReportType = "rain_nc"
+
+// Writer stores points, see influx.Writer
+type Writer interface {
+	Write(ctx context.Context, points []*influx.Data) error
+}
+
+// Cloud fetches station observations, see weatherflow.Client
+type Cloud interface {
+	LatestStationObservation(ctx context.Context, stationID int) (*weatherflow.StationObservations, error)
+}
+
+// Job fetches yesterday's corrected rain of every station with a cloud
+// station ID once it is final and writes it, together with the UDP total
+// of the same day, as a point at the local midnight that started the day
+type Job struct {
+	cfg     *config.Config
+	logger  *logger.AppLogger
+	cloud   Cloud
+	querier climate.Querier
+	writer  Writer
+	now     func() time.Time
+
+	// written records the last day written per station
+	written map[string]string
+}
+
+// New creates a Job
+func New(cfg *config.Config, appLogger *logger.AppLogger, cloud Cloud, querier climate.Querier, writer Writer) *Job {
+	return &Job{
+		cfg:     cfg,
+		logger:  appLogger,
+		cloud:   cloud,
+		querier: querier,
+		writer:  writer,
+		now:     time.Now,
+		written: make(map[string]string),
+	}
+}
+
+// Run syncs immediately and then every Nearcast_Interval until ctx is
+// cancelled
+func (j *Job) Run(ctx context.Context) {
+	j.Sync(ctx)
+	ticker := time.NewTicker(j.cfg.Nearcast_Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.Sync(ctx)
+		}
+	}
+}
+
+// Sync writes yesterday's corrected rain of every station not written yet
+func (j *Job) Sync(ctx context.Context) {
+	for serial, station := range j.cfg.Stations {
+		if station.StationID == 0 {
+			continue
+		}
+		if err := j.syncStation(ctx, serial, station.StationID); err != nil {
+			j.logger.Warn("Failed to sync corrected rain",
+				slog.String("station", serial),
+				slog.String("error", err.Error()))
+		}
+	}
+}
+
+// syncStation handles one station
+func (j *Job) syncStation(ctx context.Context, serial string, stationID int) error {
+	loc, err := j.cfg.Location(serial)
+	if err != nil {
+		return err
+	}
+	now := j.now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	yesterday := today.AddDate(0, 0, -1)
+	day := yesterday.Format(time.DateOnly)
+	if j.written[serial] == day {
+		return nil
+	}
+
+	result, err := j.cloud.LatestStationObservation(ctx, stationID)
+	if err != nil {
+		return err
+	}
+	if len(result.Obs) == 0 || result.Obs[0].PrecipYesterdayFinal == nil {
+		j.logger.Debug("Corrected rain not available yet", slog.String("station", serial), slog.String("day", day))
+		return nil
+	}
+	obs := result.Obs[0]
+
+	m := influx.New()
+	m.Name = "weather"
+	m.ReportType = ReportType
+	m.Bucket = j.cfg.Influx_Bucket
+	m.Timestamp = yesterday.Unix()
+	m.Tags["station"] = serial
+	m.Fields["rain_nc"] = influx.Round(*obs.PrecipYesterdayFinal, 2)
+	m.Fields["rain_nc_analysis"] = int64(obs.PrecipAnalysisYesterday)
+
+	source, err := climate.NewSource(j.cfg, j.querier, serial, loc)
+	if err != nil {
+		return err
+	}
+	days, err := source.Days(ctx, yesterday, today)
+	if err != nil {
+		return err
+	}
+	if len(days) == 1 && !math.IsNaN(days[0].Rain) {
+		m.Fields["rain_udp"] = influx.Round(days[0].Rain, 2)
+		m.Fields["rain_nc_correction"] = influx.Round(*obs.PrecipYesterdayFinal-days[0].Rain, 2)
+	}
+
+	if err := j.writer.Write(ctx, []*influx.Data{m}); err != nil {
+		return err
+	}
+	j.written[serial] = day
+	j.logger.Info("Wrote corrected rain",
+		slog.String("station", serial),
+		slog.String("day", day),
+		slog.Any("rain_nc", m.Fields["rain_nc"]),
+		slog.Any("rain_udp", m.Fields["rain_udp"]))
+	return nil
+}
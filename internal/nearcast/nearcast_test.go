@@ -0,0 +1,95 @@
+package nearcast
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/weatherflow"
+)
+
+type stubCloud struct {
+	final *float64
+	calls int
+}
+
+func (c *stubCloud) LatestStationObservation(ctx context.Context, stationID int) (*weatherflow.StationObservations, error) {
+	c.calls++
+	return &weatherflow.StationObservations{StationID: stationID, Obs: []weatherflow.StationObservation{
+		{PrecipYesterdayFinal: c.final, PrecipAnalysisYesterday: 1},
+	}}, nil
+}
+
+type stubQuerier struct{}
+
+func (stubQuerier) Query(ctx context.Context, flux string) ([]influx.Record, error) {
+	return []influx.Record{{"_time": "2024-06-01T06:00:00Z", "_value": "4.5"}}, nil
+}
+
+func (stubQuerier) QueryInfluxQL(ctx context.Context, influxql string) ([]influx.Record, error) {
+	return nil, nil
+}
+
+type recordingWriter struct{ points []*influx.Data }
+
+func (w *recordingWriter) Write(ctx context.Context, points []*influx.Data) error {
+	w.points = append(w.points, points...)
+	return nil
+}
+
+func TestSync(t *testing.T) {
+	cfg := &config.Config{
+		Influx_Bucket: "weather",
+		Timezone:      "America/Denver",
+		Stations: map[string]config.Station{
+			"ST-1": {StationID: 1234},
+			"ST-2": {},
+		},
+	}
+	cloud := &stubCloud{}
+	writer := &recordingWriter{}
+	j := New(cfg, logger.New(&config.Config{}), cloud, stubQuerier{}, writer)
+	j.now = func() time.Time { return time.Date(2024, 6, 2, 9, 0, 0, 0, time.UTC) }
+
+	// Not final yet: nothing is written and the next sync asks again
+	j.Sync(context.Background())
+	if len(writer.points) != 0 {
+		t.Fatalf("Expected nothing written before the value is final, got %d points", len(writer.points))
+	}
+
+	final := 3.2
+	cloud.final = &final
+	j.Sync(context.Background())
+	if len(writer.points) != 1 {
+		t.Fatalf("Expected one point, got %d", len(writer.points))
+	}
+	m := writer.points[0]
+	denver, _ := time.LoadLocation("America/Denver")
+	if want := time.Date(2024, 6, 1, 0, 0, 0, 0, denver).Unix(); m.Timestamp != want {
+		t.Errorf("Expected timestamp of local midnight %d, got %d", want, m.Timestamp)
+	}
+	want := influx.Fields{
+		"rain_nc":            3.2,
+		"rain_udp":           4.5,
+		"rain_nc_correction": -1.3,
+		"rain_nc_analysis":   int64(1),
+	}
+	for field, value := range want {
+		if m.Fields[field] != value {
+			t.Errorf("Expected %s = %v, got %v", field, value, m.Fields[field])
+		}
+	}
+	if m.Tags["station"] != "ST-1" || m.Bucket != "weather" {
+		t.Errorf("Unexpected point %+v", m)
+	}
+
+	// The same day is not fetched again
+	calls := cloud.calls
+	j.Sync(context.Background())
+	if cloud.calls != calls || len(writer.points) != 1 {
+		t.Errorf("Expected no further calls for a written day")
+	}
+}
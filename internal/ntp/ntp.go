@@ -0,0 +1,154 @@
+// Package ntp checks the host clock against an NTP server
+package ntp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/metrics"
+)
+
+// ntpEpochOffset is the number of seconds between 1900-01-01 and 1970-01-01
+const ntpEpochOffset = 2208988800
+
+// queryTimeout bounds a single query
+const queryTimeout = 5 * time.Second
+
+// correction is the offset Now adds to the host clock, in nanoseconds
+var correction atomic.Int64
+
+// Now returns the host time corrected by the offset last measured by a
+// Checker with Ntp_Correct, or the plain host time without one. Points
+// stamped with the receive time instead of a device timestamp use it.
+func Now() time.Time {
+	return time.Now().Add(time.Duration(correction.Load()))
+}
+
+// toNTP converts t into a 64-bit NTP timestamp
+func toNTP(t time.Time) uint64 {
+	nanos := uint64(t.UnixNano()) + ntpEpochOffset*1e9
+	seconds := nanos / 1e9
+	fraction := (nanos % 1e9) << 32 / 1e9
+	return seconds<<32 | fraction
+}
+
+// fromNTP converts a 64-bit NTP timestamp into a time
+func fromNTP(ts uint64) time.Time {
+	seconds := int64(ts>>32) - ntpEpochOffset
+	nanos := int64((ts & 0xffffffff) * 1e9 >> 32)
+	return time.Unix(seconds, nanos)
+}
+
+// Query asks server (host or host:port) for the time and returns how far
+// the local clock is behind it; a negative offset means the local clock is
+// ahead
+func Query(ctx context.Context, server string) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return 0, fmt.Errorf("connecting to NTP server %s: %w", server, err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(queryTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+
+	request := make([]byte, 48)
+	request[0] = 0x23 // leap indicator 0, version 4, mode 3 (client)
+	sent := time.Now()
+	origin := toNTP(sent)
+	binary.BigEndian.PutUint64(request[40:], origin)
+	if _, err := conn.Write(request); err != nil {
+		return 0, fmt.Errorf("querying NTP server %s: %w", server, err)
+	}
+
+	response := make([]byte, 48)
+	n, err := conn.Read(response)
+	received := time.Now()
+	if err != nil {
+		return 0, fmt.Errorf("querying NTP server %s: %w", server, err)
+	}
+	if n < 48 || response[0]&0x07 != 4 {
+		return 0, fmt.Errorf("invalid response from NTP server %s", server)
+	}
+	if response[1] == 0 {
+		return 0, fmt.Errorf("NTP server %s refused the query (kiss-o'-death %q)", server, response[12:16])
+	}
+	if binary.BigEndian.Uint64(response[24:]) != origin {
+		return 0, errors.New("NTP response does not match the request")
+	}
+
+	serverReceived := fromNTP(binary.BigEndian.Uint64(response[32:]))
+	serverSent := fromNTP(binary.BigEndian.Uint64(response[40:]))
+	return (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2, nil
+}
+
+// Checker periodically compares the host clock with Ntp_Server and warns
+// when it is off by more than Ntp_Max_Offset. With Ntp_Correct the measured
+// offset is applied by Now.
+type Checker struct {
+	cfg    *config.Config
+	logger *logger.AppLogger
+	query  func(ctx context.Context, server string) (time.Duration, error)
+}
+
+// NewChecker creates a Checker for the NTP settings of cfg
+func NewChecker(cfg *config.Config, appLogger *logger.AppLogger) *Checker {
+	return &Checker{cfg: cfg, logger: appLogger, query: Query}
+}
+
+// Run checks the clock immediately and then every Ntp_Interval until ctx is
+// cancelled
+func (c *Checker) Run(ctx context.Context) {
+	c.Check(ctx)
+	ticker := time.NewTicker(c.cfg.Ntp_Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Check(ctx)
+		}
+	}
+}
+
+// Check queries the server once and logs the result. It reports whether the
+// clock is within Ntp_Max_Offset; a failed query counts as within and keeps
+// the last correction.
+func (c *Checker) Check(ctx context.Context) bool {
+	offset, err := c.query(ctx, c.cfg.Ntp_Server)
+	if err != nil {
+		c.logger.Warn("NTP clock check failed", slog.String("error", err.Error()))
+		return true
+	}
+	metrics.ClockOffset.Set(offset.Seconds())
+	if c.cfg.Ntp_Correct {
+		correction.Store(int64(offset))
+	}
+
+	if offset.Abs() > c.cfg.Ntp_Max_Offset {
+		c.logger.Warn("Host clock is off",
+			slog.String("server", c.cfg.Ntp_Server),
+			slog.Duration("offset", offset))
+		return false
+	}
+	c.logger.Debug("Host clock checked",
+		slog.String("server", c.cfg.Ntp_Server),
+		slog.Duration("offset", offset))
+	return true
+}
@@ -0,0 +1,106 @@
+package ntp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+// fakeServer answers NTP queries with a clock shifted by skew
+func fakeServer(t *testing.T, skew time.Duration) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 48 {
+				continue
+			}
+			response := make([]byte, 48)
+			response[0] = 0x24 // version 4, mode 4 (server)
+			response[1] = 2
+			copy(response[24:32], buf[40:48])
+			now := toNTP(time.Now().Add(skew))
+			binary.BigEndian.PutUint64(response[32:], now)
+			binary.BigEndian.PutUint64(response[40:], now)
+			_, _ = conn.WriteTo(response, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestTimestampRoundTrip(t *testing.T) {
+	want := time.Date(2024, 6, 1, 12, 30, 15, 250_000_000, time.UTC)
+	if got := fromNTP(toNTP(want)); got.Sub(want).Abs() > time.Microsecond {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	server := fakeServer(t, 5*time.Second)
+	offset, err := Query(context.Background(), server)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if (offset - 5*time.Second).Abs() > 100*time.Millisecond {
+		t.Errorf("Expected offset of about 5s, got %v", offset)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	cfg := &config.Config{Ntp_Server: "pool.ntp.org", Ntp_Max_Offset: time.Second}
+	c := NewChecker(cfg, logger.New(&config.Config{}))
+
+	for _, tt := range []struct {
+		offset time.Duration
+		err    error
+		want   bool
+	}{
+		{offset: 200 * time.Millisecond, want: true},
+		{offset: -3 * time.Second, want: false},
+		{err: errors.New("timeout"), want: true},
+	} {
+		c.query = func(context.Context, string) (time.Duration, error) { return tt.offset, tt.err }
+		if got := c.Check(context.Background()); got != tt.want {
+			t.Errorf("Check() with offset %v, error %v = %v, want %v", tt.offset, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCheckCorrect(t *testing.T) {
+	t.Cleanup(func() { correction.Store(0) })
+	c := NewChecker(&config.Config{Ntp_Server: "pool.ntp.org", Ntp_Max_Offset: time.Second}, logger.New(&config.Config{}))
+	c.query = func(context.Context, string) (time.Duration, error) { return time.Hour, nil }
+
+	c.Check(context.Background())
+	if d := time.Until(Now()); d > time.Minute {
+		t.Errorf("Expected no correction without Ntp_Correct, got %v", d)
+	}
+
+	c.cfg.Ntp_Correct = true
+	c.Check(context.Background())
+	if d := time.Until(Now()); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("Expected Now to be corrected by the measured offset, got %v", d)
+	}
+
+	// A failed check keeps the last correction
+	c.query = func(context.Context, string) (time.Duration, error) { return 0, errors.New("timeout") }
+	c.Check(context.Background())
+	if d := time.Until(Now()); d < 59*time.Minute {
+		t.Errorf("Expected the correction to be kept, got %v", d)
+	}
+}
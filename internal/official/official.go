@@ -0,0 +1,290 @@
+// Package official compares the observations of each device with those of
+// an official METAR station nearby, to help derive calibration offsets
+package official
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/meteo"
+)
+
+const (
+	// Measurement is the name of comparison points
+	Measurement = "official"
+
+	// DefaultURL is the METAR endpoint of the Aviation Weather Center
+	DefaultURL = "https://aviationweather.gov/api/data/metar"
+
+	// MaxOffset is how far apart in time an official observation and the
+	// device observation it is compared with may be
+	MaxOffset = 10 * time.Minute
+
+	// history is how long device observations are kept for comparison;
+	// METARs are usually issued hourly and published with some delay
+	history = 2 * time.Hour
+)
+
+// Writer stores points, see influx.Writer
+type Writer interface {
+	Write(ctx context.Context, points []*influx.Data) error
+}
+
+// HTTPClient sends requests, see http.Client
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Observation is an official observation in metric units. Values missing
+// from the report are nil.
+type Observation struct {
+	Station   string
+	Time      time.Time
+	Temp      *float64 // °C
+	DewPoint  *float64 // °C
+	Altimeter *float64 // hPa
+	WindAvg   *float64 // m/s
+}
+
+// metar is the part of a METAR in the JSON format of the Aviation Weather
+// Center that is compared
+type metar struct {
+	ICAO    string   `json:"icaoId"`
+	ObsTime int64    `json:"obsTime"`
+	Temp    *float64 `json:"temp"`
+	Dewp    *float64 `json:"dewp"`
+	Altim   *float64 `json:"altim"`
+	Wspd    *float64 `json:"wspd"`
+}
+
+// sample is a device observation kept for comparison
+type sample struct {
+	time  time.Time
+	point *influx.Data
+}
+
+// Comparer keeps the recent obs_st observations of every device and, every
+// Official_Interval, writes a point per device with the latest official
+// observation of its METAR station next to the device observation closest
+// in time, and their differences
+type Comparer struct {
+	cfg    *config.Config
+	logger *logger.AppLogger
+	client HTTPClient
+	writer Writer
+	url    string
+
+	mu      sync.Mutex
+	samples map[string][]sample
+}
+
+// New creates a Comparer
+func New(cfg *config.Config, appLogger *logger.AppLogger, client HTTPClient, writer Writer) *Comparer {
+	return &Comparer{
+		cfg:     cfg,
+		logger:  appLogger,
+		client:  client,
+		writer:  writer,
+		url:     DefaultURL,
+		samples: make(map[string][]sample),
+	}
+}
+
+// Observe implements processor.Observer, keeping the obs_st points of
+// devices compared with an official station
+func (c *Comparer) Observe(points []*influx.Data) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range points {
+		if m.ReportType != "obs_st" {
+			continue
+		}
+		serial := m.Tags["station"]
+		if c.cfg.OfficialStation(serial) == "" {
+			continue
+		}
+		t := time.Unix(m.Timestamp, m.Nanos)
+		kept := c.samples[serial][:0]
+		for _, s := range c.samples[serial] {
+			if t.Sub(s.time) <= history {
+				kept = append(kept, s)
+			}
+		}
+		c.samples[serial] = append(kept, sample{time: t, point: &influx.Data{Fields: maps.Clone(m.Fields)}})
+	}
+}
+
+// Run compares every Official_Interval until ctx is cancelled. The first
+// comparison waits one interval, so that device observations are known.
+func (c *Comparer) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.Official_Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Compare(ctx)
+		}
+	}
+}
+
+// Compare fetches the official observations and writes the comparison of
+// every device observed recently
+func (c *Comparer) Compare(ctx context.Context) {
+	c.mu.Lock()
+	serials := make([]string, 0, len(c.samples))
+	for serial := range c.samples {
+		serials = append(serials, serial)
+	}
+	c.mu.Unlock()
+
+	fetched := make(map[string]*Observation)
+	var points []*influx.Data
+	for _, serial := range serials {
+		icao := c.cfg.OfficialStation(serial)
+		obs, ok := fetched[icao]
+		if !ok {
+			var err error
+			if obs, err = c.Latest(ctx, icao); err != nil {
+				c.logger.Warn("Failed to fetch official observation",
+					slog.String("official_station", icao),
+					slog.String("error", err.Error()))
+			}
+			fetched[icao] = obs
+		}
+		if obs == nil {
+			continue
+		}
+		if m := c.point(serial, obs); m != nil {
+			points = append(points, m)
+		}
+	}
+	if len(points) == 0 {
+		return
+	}
+	if err := c.writer.Write(ctx, points); err != nil {
+		c.logger.Warn("Failed to write official comparison", slog.String("error", err.Error()))
+	}
+}
+
+// Latest returns the latest METAR of the station with ICAO identifier icao
+func (c *Comparer) Latest(ctx context.Context, icao string) (*Observation, error) {
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = url.Values{"ids": {icao}, "format": {"json"}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	var reports []metar
+	if err := json.NewDecoder(resp.Body).Decode(&reports); err != nil {
+		return nil, fmt.Errorf("decoding METAR: %w", err)
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("no METAR for %s", icao)
+	}
+	r := reports[0]
+	obs := &Observation{
+		Station:   icao,
+		Time:      time.Unix(r.ObsTime, 0),
+		Temp:      r.Temp,
+		DewPoint:  r.Dewp,
+		Altimeter: r.Altim,
+	}
+	if r.Wspd != nil {
+		ms := *r.Wspd / meteo.MsToKnots(1)
+		obs.WindAvg = &ms
+	}
+	return obs, nil
+}
+
+// point compares obs with the device observation closest in time, or
+// returns nil if there is none within MaxOffset
+func (c *Comparer) point(serial string, obs *Observation) *influx.Data {
+	c.mu.Lock()
+	var closest *sample
+	for i, s := range c.samples[serial] {
+		if closest == nil || absDuration(s.time.Sub(obs.Time)) < absDuration(closest.time.Sub(obs.Time)) {
+			closest = &c.samples[serial][i]
+		}
+	}
+	var device *influx.Data
+	if closest != nil && absDuration(closest.time.Sub(obs.Time)) <= MaxOffset {
+		device = closest.point
+	}
+	c.mu.Unlock()
+	if device == nil {
+		return nil
+	}
+
+	m := influx.New()
+	m.Name = Measurement
+	m.ReportType = Measurement
+	m.Bucket = c.cfg.Influx_Bucket
+	m.Timestamp = obs.Time.Unix()
+	m.Tags["station"] = serial
+	m.Tags["official_station"] = obs.Station
+
+	if v, ok := device.Float("temp"); ok {
+		compare(m, "temp", v, obs.Temp)
+	}
+	if v, ok := device.Float("dew_point"); ok {
+		compare(m, "dew_point", v, obs.DewPoint)
+	}
+	if v, ok := device.Float("wind_avg"); ok {
+		compare(m, "wind_avg", v, obs.WindAvg)
+	}
+	if p, ok := device.Float("p"); ok && p > 0 {
+		compare(m, "pressure", meteo.AltimeterSetting(p, c.cfg.Elevation(serial)), obs.Altimeter)
+	}
+	if len(m.Fields) == 0 {
+		return nil
+	}
+	return m
+}
+
+// compare sets <name>, <name>_official and <name>_delta (device minus
+// official) if the official observation has the value
+func compare(m *influx.Data, name string, device float64, official *float64) {
+	if official == nil {
+		return
+	}
+	m.Fields[name] = influx.Round(device, 2)
+	m.Fields[name+"_official"] = influx.Round(*official, 2)
+	m.Fields[name+"_delta"] = influx.Round(device-*official, 2)
+}
+
+// absDuration returns the absolute value of d
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
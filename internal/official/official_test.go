@@ -0,0 +1,122 @@
+package official
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+// metarTime is the observation time of the test METAR
+const metarTime = 1700000000
+
+type recordingWriter struct{ points []*influx.Data }
+
+func (w *recordingWriter) Write(ctx context.Context, points []*influx.Data) error {
+	w.points = append(w.points, points...)
+	return nil
+}
+
+// fakeAWC serves the METAR of KSEA and counts requests
+func fakeAWC(t *testing.T, requests *int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if r.URL.Query().Get("format") != "json" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		if r.URL.Query().Get("ids") != "KSEA" {
+			io.WriteString(w, `[]`)
+			return
+		}
+		io.WriteString(w, `[{"icaoId":"KSEA","obsTime":1700000000,"temp":10,"dewp":5.5,"wdir":"VRB","wspd":10,"altim":1015.2}]`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func observation(serial string, timestamp int64, temp float64) *influx.Data {
+	m := influx.New()
+	m.Name = "weather"
+	m.ReportType = "obs_st"
+	m.Timestamp = timestamp
+	m.Tags["station"] = serial
+	m.Fields["temp"] = temp
+	m.Fields["dew_point"] = 5.0
+	m.Fields["wind_avg"] = 5.0
+	m.Fields["p"] = 1015.0
+	return m
+}
+
+func TestCompare(t *testing.T) {
+	var requests int
+	srv := fakeAWC(t, &requests)
+	cfg := &config.Config{
+		Influx_Bucket:    "weather",
+		Official_Station: "KSEA",
+		Stations: map[string]config.Station{
+			"ST-3": {OfficialStation: "EGLL"},
+		},
+	}
+	writer := &recordingWriter{}
+	c := New(cfg, logger.New(&config.Config{}), srv.Client(), writer)
+	c.url = srv.URL
+
+	c.Observe([]*influx.Data{
+		observation("ST-1", metarTime-600, 11.0),
+		observation("ST-1", metarTime+60, 11.5),
+		observation("ST-1", metarTime+900, 12.0),
+		observation("ST-2", metarTime+3600, 12.0),
+		observation("ST-3", metarTime, 12.0),
+	})
+	c.Compare(context.Background())
+
+	if requests != 2 {
+		t.Errorf("Expected one request per official station, got %d", requests)
+	}
+	if len(writer.points) != 1 {
+		t.Fatalf("Expected one comparison, got %d: %v", len(writer.points), writer.points)
+	}
+	m := writer.points[0]
+	if m.Name != Measurement || m.Timestamp != metarTime || m.Tags["station"] != "ST-1" || m.Tags["official_station"] != "KSEA" {
+		t.Errorf("Unexpected point %+v", m)
+	}
+	for field, want := range map[string]float64{
+		"temp":              11.5,
+		"temp_official":     10.0,
+		"temp_delta":        1.5,
+		"dew_point_delta":   -0.5,
+		"wind_avg_official": 5.14,
+		"pressure_official": 1015.2,
+		// QNH of 1015 hPa at sea level is 1014.7 hPa
+		"pressure_delta": -0.5,
+	} {
+		if m.Fields[field] != want {
+			t.Errorf("%s = %v, want %v", field, m.Fields[field], want)
+		}
+	}
+}
+
+func TestObserveIgnoresOtherDevices(t *testing.T) {
+	c := New(&config.Config{Stations: map[string]config.Station{"ST-1": {OfficialStation: "KSEA"}}}, logger.New(&config.Config{}), nil, nil)
+	wind := observation("ST-1", metarTime, 10)
+	wind.ReportType = "rapid_wind"
+	c.Observe([]*influx.Data{observation("ST-2", metarTime, 10), wind})
+	if len(c.samples) != 0 {
+		t.Errorf("Expected only obs_st of compared devices to be kept, got %v", c.samples)
+	}
+}
+
+func TestObserveHistory(t *testing.T) {
+	c := New(&config.Config{Official_Station: "KSEA"}, logger.New(&config.Config{}), nil, nil)
+	c.Observe([]*influx.Data{observation("ST-1", metarTime, 10)})
+	c.Observe([]*influx.Data{observation("ST-1", metarTime+int64(history.Seconds())+1, 10)})
+	if len(c.samples["ST-1"]) != 1 {
+		t.Errorf("Expected observations older than the history to be dropped, got %d", len(c.samples["ST-1"]))
+	}
+}
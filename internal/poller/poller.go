@@ -0,0 +1,190 @@
+// Package poller fills gaps in the UDP feed with observations polled from
+// the WeatherFlow cloud REST API
+package poller
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/weatherflow"
+)
+
+// Interval is how often the cloud is polled while the feed is silent. The
+// cloud updates observations once a minute.
+const Interval = time.Minute
+
+// Source delivers raw Tempest JSON packets, see processor.Source
+type Source interface {
+	Name() string
+	Run(ctx context.Context, handle func(addr net.Addr, data []byte)) error
+}
+
+// Cloud fetches stations and device observations, see weatherflow.Client
+type Cloud interface {
+	Station(ctx context.Context, stationID int) (*weatherflow.Station, error)
+	LatestDeviceObservation(ctx context.Context, deviceID int) (*weatherflow.DeviceObservation, error)
+}
+
+// apiAddr is the remote address reported for polled packets
+type apiAddr struct{}
+
+func (apiAddr) Network() string { return "weatherflow-api" }
+func (apiAddr) String() string  { return "weatherflow-api" }
+
+// Fallback wraps a source and, when it has delivered no packet for
+// Poll_Fallback_After, polls the latest observation of every station with
+// a station_id from the cloud. Polled observations are handed on as
+// packets in the UDP layout, so they are parsed and written like broadcast
+// ones.
+type Fallback struct {
+	cfg     *config.Config
+	logger  *logger.AppLogger
+	source  Source
+	cloud   Cloud
+	now     func() time.Time
+	devices map[string]int // device IDs by serial, resolved once
+
+	last atomic.Int64 // Unix nanoseconds of the last packet of source
+
+	mu     sync.Mutex
+	polled map[string]int64 // timestamp of the last observation polled per serial
+}
+
+// New creates a Fallback around source
+func New(cfg *config.Config, appLogger *logger.AppLogger, source Source, cloud Cloud) *Fallback {
+	return &Fallback{
+		cfg:     cfg,
+		logger:  appLogger,
+		source:  source,
+		cloud:   cloud,
+		now:     time.Now,
+		devices: make(map[string]int),
+		polled:  make(map[string]int64),
+	}
+}
+
+// Name implements Source
+func (f *Fallback) Name() string { return f.source.Name() }
+
+// Run implements Source. The wrapped source starts counting as silent when
+// Run starts.
+func (f *Fallback) Run(ctx context.Context, handle func(addr net.Addr, data []byte)) error {
+	f.last.Store(f.now().UnixNano())
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				f.Poll(ctx, handle)
+			}
+		}
+	}()
+
+	err := f.source.Run(ctx, func(addr net.Addr, data []byte) {
+		f.last.Store(f.now().UnixNano())
+		handle(addr, data)
+	})
+	cancel()
+	wg.Wait()
+	return err
+}
+
+// Poll hands on the latest observation of every station not handed on yet,
+// unless the wrapped source delivered a packet within Poll_Fallback_After
+func (f *Fallback) Poll(ctx context.Context, handle func(addr net.Addr, data []byte)) {
+	silent := f.now().Sub(time.Unix(0, f.last.Load()))
+	if silent < f.cfg.Poll_Fallback_After {
+		return
+	}
+
+	for serial, station := range f.cfg.Stations {
+		if station.StationID == 0 {
+			continue
+		}
+		packet, err := f.poll(ctx, serial, station.StationID)
+		if err != nil {
+			f.logger.Warn("Failed to poll WeatherFlow API",
+				slog.String("station", serial),
+				slog.String("error", err.Error()))
+			continue
+		}
+		if packet == nil {
+			continue
+		}
+		f.logger.Info("Polled observation from WeatherFlow API",
+			slog.String("station", serial),
+			slog.Duration("silent", silent.Round(time.Second)))
+		handle(apiAddr{}, packet)
+	}
+}
+
+// poll returns the latest observation of the device serial as a packet, or
+// nil if it was handed on before
+func (f *Fallback) poll(ctx context.Context, serial string, stationID int) ([]byte, error) {
+	deviceID, err := f.deviceID(ctx, serial, stationID)
+	if err != nil || deviceID == 0 {
+		return nil, err
+	}
+	obs, err := f.cloud.LatestDeviceObservation(ctx, deviceID)
+	if err != nil || len(obs.Obs) == 0 || len(obs.Obs[0]) == 0 {
+		return nil, err
+	}
+
+	latest := obs.Obs[len(obs.Obs)-1]
+	timestamp := int64(latest[0])
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if timestamp <= f.polled[serial] {
+		return nil, nil
+	}
+	f.polled[serial] = timestamp
+
+	return json.Marshal(struct {
+		Serial string      `json:"serial_number"`
+		Type   string      `json:"type"`
+		Obs    [][]float64 `json:"obs"`
+	}{strings.ToUpper(serial), obs.Type, [][]float64{latest}})
+}
+
+// deviceID returns the cloud device ID of serial, looking it up among the
+// devices of its station the first time. It is 0 when the station has no
+// such device.
+func (f *Fallback) deviceID(ctx context.Context, serial string, stationID int) (int, error) {
+	f.mu.Lock()
+	id, ok := f.devices[serial]
+	f.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	station, err := f.cloud.Station(ctx, stationID)
+	if err != nil {
+		return 0, err
+	}
+	if id = station.DeviceID(serial); id == 0 {
+		f.logger.Warn("Station has no device with this serial number",
+			slog.String("station", serial),
+			slog.Int("station_id", stationID))
+	}
+	f.mu.Lock()
+	f.devices[serial] = id
+	f.mu.Unlock()
+	return id, nil
+}
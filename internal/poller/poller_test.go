@@ -0,0 +1,92 @@
+package poller
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/weatherflow"
+)
+
+type stubCloud struct {
+	timestamp    float64
+	stationCalls int
+}
+
+func (c *stubCloud) Station(ctx context.Context, stationID int) (*weatherflow.Station, error) {
+	c.stationCalls++
+	return &weatherflow.Station{StationID: stationID, Devices: []weatherflow.Device{
+		{DeviceID: 11, SerialNumber: "HB-00000001", DeviceType: "HB"},
+		{DeviceID: 12, SerialNumber: "ST-00012345", DeviceType: "ST"},
+	}}, nil
+}
+
+func (c *stubCloud) LatestDeviceObservation(ctx context.Context, deviceID int) (*weatherflow.DeviceObservation, error) {
+	return &weatherflow.DeviceObservation{DeviceID: deviceID, Type: "obs_st", Obs: [][]float64{{c.timestamp, 0.5, 1.2}}}, nil
+}
+
+type silentSource struct{}
+
+func (silentSource) Name() string { return "udp" }
+func (silentSource) Run(ctx context.Context, handle func(addr net.Addr, data []byte)) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestPoll(t *testing.T) {
+	cfg := &config.Config{
+		Poll_Fallback_After: 5 * time.Minute,
+		Stations: map[string]config.Station{
+			"st-00012345": {StationID: 1000},
+			"ST-00099999": {},
+		},
+	}
+	cloud := &stubCloud{timestamp: 1700000000}
+	f := New(cfg, logger.New(&config.Config{}), silentSource{}, cloud)
+	now := time.Unix(1700000000, 0)
+	f.now = func() time.Time { return now }
+	f.last.Store(now.UnixNano())
+
+	var packets [][]byte
+	handle := func(addr net.Addr, data []byte) { packets = append(packets, data) }
+
+	// The feed has not been silent for long enough
+	now = now.Add(4 * time.Minute)
+	f.Poll(context.Background(), handle)
+	if len(packets) != 0 {
+		t.Fatalf("Expected no polling while the feed is recent, got %d packets", len(packets))
+	}
+
+	now = now.Add(2 * time.Minute)
+	f.Poll(context.Background(), handle)
+	if len(packets) != 1 {
+		t.Fatalf("Expected 1 polled packet, got %d", len(packets))
+	}
+	var report struct {
+		Serial string      `json:"serial_number"`
+		Type   string      `json:"type"`
+		Obs    [][]float64 `json:"obs"`
+	}
+	if err := json.Unmarshal(packets[0], &report); err != nil {
+		t.Fatalf("Invalid packet: %v", err)
+	}
+	if report.Serial != "ST-00012345" || report.Type != "obs_st" || report.Obs[0][0] != 1700000000 {
+		t.Errorf("Unexpected packet %s", packets[0])
+	}
+
+	// The same observation is not handed on twice, and devices are
+	// resolved once
+	f.Poll(context.Background(), handle)
+	cloud.timestamp += 60
+	f.Poll(context.Background(), handle)
+	if len(packets) != 2 {
+		t.Errorf("Expected 2 packets after a new observation, got %d", len(packets))
+	}
+	if cloud.stationCalls != 1 {
+		t.Errorf("Expected the device to be resolved once, got %d calls", cloud.stationCalls)
+	}
+}
@@ -0,0 +1,39 @@
+// Package pressure derives the sea-level pressure and the pressure trend
+// from the station pressure
+package pressure
+
+import (
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/meteo"
+)
+
+// SeaLevelField is the name of the sea-level pressure field
+const SeaLevelField = "sea_level_pressure"
+
+// SeaLevelEnricher adds the sea-level pressure to every point with a
+// station pressure and air temperature, such as obs_st and obs_air. It runs
+// after the pressure filter, so the filtered pressure is reduced.
+type SeaLevelEnricher struct {
+	cfg *config.Config
+}
+
+// NewSeaLevelEnricher creates a SeaLevelEnricher using the elevations of cfg
+func NewSeaLevelEnricher(cfg *config.Config) *SeaLevelEnricher {
+	return &SeaLevelEnricher{cfg: cfg}
+}
+
+// Enrich adds the sea-level pressure to the points that have the fields it
+// needs
+func (e *SeaLevelEnricher) Enrich(points []*influx.Data) []*influx.Data {
+	for _, m := range points {
+		p, okPressure := m.Float("p")
+		temp, okTemp := m.Float("temp")
+		if !okPressure || !okTemp {
+			continue
+		}
+		elevation := e.cfg.Elevation(m.Tags["station"])
+		m.Fields[SeaLevelField] = influx.Round(meteo.SeaLevelPressure(p, elevation, temp), 2)
+	}
+	return points
+}
@@ -0,0 +1,36 @@
+package pressure
+
+import (
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func point(station string, fields influx.Fields) *influx.Data {
+	m := influx.New()
+	m.Tags["station"] = station
+	m.Fields = fields
+	return m
+}
+
+func TestSeaLevelEnricher(t *testing.T) {
+	cfg := &config.Config{
+		Station_Elevation: 100,
+		Stations:          map[string]config.Station{"ST-00012345": {Elevation: 1609}},
+	}
+	mountain := point("ST-00012345", influx.Fields{"p": 834.6, "temp": 15.0})
+	valley := point("AR-00004049", influx.Fields{"p": 1001.2, "temp": 15.0})
+	wind := point("ST-00012345", influx.Fields{"rapid_wind_speed": 3.0})
+
+	NewSeaLevelEnricher(cfg).Enrich([]*influx.Data{mountain, valley, wind})
+	if got := mountain.Fields[SeaLevelField]; got != 1006.64 {
+		t.Errorf("%s at 1609 m = %v, want 1006.64", SeaLevelField, got)
+	}
+	if got := valley.Fields[SeaLevelField]; got != 1013.13 {
+		t.Errorf("%s at the default elevation = %v, want 1013.13", SeaLevelField, got)
+	}
+	if _, ok := wind.Fields[SeaLevelField]; ok {
+		t.Error("Expected no sea-level pressure without a station pressure")
+	}
+}
@@ -0,0 +1,132 @@
+package pressure
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+// Names of the pressure trend field and tag
+const (
+	TrendField = "pressure_trend"
+	TrendTag   = "pressure_tendency"
+)
+
+// Pressure tendencies
+const (
+	Rising  = "rising"
+	Steady  = "steady"
+	Falling = "falling"
+)
+
+const (
+	// trendPeriod is the span in seconds of the pressure tendency, the
+	// 3-hour period of synoptic reports
+	trendPeriod = 3 * 3600
+
+	// trendTolerance is how much shorter in seconds than trendPeriod the
+	// history may be when the trend is reported
+	trendTolerance = 10 * 60
+
+	// steadyChange is the largest change in hPa over trendPeriod that
+	// counts as steady
+	steadyChange = 1.0
+)
+
+// reading is the pressure of one point
+type reading struct {
+	Timestamp int64   `json:"timestamp"`
+	Pressure  float64 `json:"pressure"`
+}
+
+// TrendEnricher adds the 3-hour pressure change and tendency to every point
+// with a station pressure, once three hours of the station's pressure have
+// been seen. It runs after the pressure filter, so spikes do not show up as
+// a trend.
+type TrendEnricher struct {
+	mu      sync.Mutex
+	history map[string][]reading
+}
+
+// NewTrendEnricher creates a TrendEnricher with an empty history
+func NewTrendEnricher() *TrendEnricher {
+	return &TrendEnricher{history: make(map[string][]reading)}
+}
+
+// Enrich adds the trend to the points with a station pressure
+func (e *TrendEnricher) Enrich(points []*influx.Data) []*influx.Data {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, m := range points {
+		p, ok := m.Float("p")
+		if !ok {
+			continue
+		}
+		change, ok := e.add(m.Tags["station"], m.Timestamp, p)
+		if !ok {
+			continue
+		}
+		m.Fields[TrendField] = influx.Round(change, 2)
+		m.Tags[TrendTag] = Tendency(change)
+	}
+	return points
+}
+
+// add records a reading and returns the change since the oldest reading of
+// the last trendPeriod. ok is false while the history is too short. Late
+// readings are not recorded.
+func (e *TrendEnricher) add(station string, timestamp int64, p float64) (change float64, ok bool) {
+	history := e.history[station]
+	if n := len(history); n == 0 || timestamp > history[n-1].Timestamp {
+		history = append(history, reading{Timestamp: timestamp, Pressure: p})
+	}
+	for len(history) > 0 && history[0].Timestamp < timestamp-trendPeriod {
+		history = history[1:]
+	}
+	e.history[station] = history
+
+	if len(history) == 0 || timestamp-history[0].Timestamp < trendPeriod-trendTolerance {
+		return 0, false
+	}
+	return p - history[0].Pressure, true
+}
+
+// Tendency classifies a 3-hour pressure change in hPa
+func Tendency(change float64) string {
+	switch {
+	case change > steadyChange:
+		return Rising
+	case change < -steadyChange:
+		return Falling
+	default:
+		return Steady
+	}
+}
+
+// StateKey implements state.Persistent
+func (e *TrendEnricher) StateKey() string {
+	return "pressure_trend"
+}
+
+// MarshalState implements state.Persistent
+func (e *TrendEnricher) MarshalState() (json.RawMessage, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return json.Marshal(e.history)
+}
+
+// UnmarshalState implements state.Persistent
+func (e *TrendEnricher) UnmarshalState(b json.RawMessage) error {
+	var history map[string][]reading
+	if err := json.Unmarshal(b, &history); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for station, readings := range history {
+		e.history[station] = readings
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package pressure
+
+import (
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func TestTrendEnricher(t *testing.T) {
+	e := NewTrendEnricher()
+
+	tests := []struct {
+		timestamp int64
+		p         float64
+		trend     any
+		tendency  string
+	}{
+		{0, 1010.0, nil, ""},
+		{3600, 1009.0, nil, ""},
+		{10200, 1008.5, -1.5, Falling}, // 2 h 50 min of history
+		{10800, 1008.2, -1.8, Falling},
+		{14400, 1008.4, -0.6, Steady},
+		{1800, 1020.0, nil, ""}, // late readings are not recorded
+	}
+	for _, tt := range tests {
+		m := point("ST-00012345", influx.Fields{"p": tt.p})
+		m.Timestamp = tt.timestamp
+		e.Enrich([]*influx.Data{m})
+		if m.Fields[TrendField] != tt.trend || m.Tags[TrendTag] != tt.tendency {
+			t.Errorf("At %d: trend %v, tendency %q; want %v, %q",
+				tt.timestamp, m.Fields[TrendField], m.Tags[TrendTag], tt.trend, tt.tendency)
+		}
+	}
+}
+
+func TestTendency(t *testing.T) {
+	for change, want := range map[float64]string{1.6: Rising, 0.4: Steady, -1: Steady, -2.2: Falling} {
+		if got := Tendency(change); got != want {
+			t.Errorf("Tendency(%v) = %s, want %s", change, got, want)
+		}
+	}
+}
@@ -2,19 +2,59 @@ package processor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand/v2"
 	"net"
 	"net/http"
-	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/jacaudi/tempest-influxdb/internal/alert"
+	"github.com/jacaudi/tempest-influxdb/internal/archive"
+	"github.com/jacaudi/tempest-influxdb/internal/bounds"
+	"github.com/jacaudi/tempest-influxdb/internal/calibration"
+	"github.com/jacaudi/tempest-influxdb/internal/capture"
+	"github.com/jacaudi/tempest-influxdb/internal/cloudbase"
+	"github.com/jacaudi/tempest-influxdb/internal/collision"
+	"github.com/jacaudi/tempest-influxdb/internal/comfort"
+	"github.com/jacaudi/tempest-influxdb/internal/conditions"
 	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/daily"
+	"github.com/jacaudi/tempest-influxdb/internal/debugvars"
+	"github.com/jacaudi/tempest-influxdb/internal/dedupe"
+	"github.com/jacaudi/tempest-influxdb/internal/et0"
+	"github.com/jacaudi/tempest-influxdb/internal/fieldfilter"
+	"github.com/jacaudi/tempest-influxdb/internal/health"
 	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/lightning"
 	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/metrics"
+	"github.com/jacaudi/tempest-influxdb/internal/mqtt"
+	"github.com/jacaudi/tempest-influxdb/internal/poller"
+	"github.com/jacaudi/tempest-influxdb/internal/pressure"
+	"github.com/jacaudi/tempest-influxdb/internal/pushgateway"
+	"github.com/jacaudi/tempest-influxdb/internal/quarantine"
+	"github.com/jacaudi/tempest-influxdb/internal/rain"
+	"github.com/jacaudi/tempest-influxdb/internal/rapidwind"
+	"github.com/jacaudi/tempest-influxdb/internal/relay"
+	"github.com/jacaudi/tempest-influxdb/internal/smoothing"
+	"github.com/jacaudi/tempest-influxdb/internal/snow"
+	"github.com/jacaudi/tempest-influxdb/internal/solar"
+	"github.com/jacaudi/tempest-influxdb/internal/spool"
+	"github.com/jacaudi/tempest-influxdb/internal/state"
+	"github.com/jacaudi/tempest-influxdb/internal/summary"
+	"github.com/jacaudi/tempest-influxdb/internal/telegraf"
 	"github.com/jacaudi/tempest-influxdb/internal/tempest"
-	"github.com/samber/lo"
+	"github.com/jacaudi/tempest-influxdb/internal/weatherflow"
+	"github.com/jacaudi/tempest-influxdb/internal/webhook"
+	"github.com/jacaudi/tempest-influxdb/internal/websocket"
+	"github.com/jacaudi/tempest-influxdb/internal/zabbix"
 )
 
 // Buffer pool for reusing byte buffers to reduce GC pressure
@@ -25,11 +65,31 @@ var bufferPool = sync.Pool{
 }
 
 // createOptimizedHTTPClient creates an HTTP client with optimized settings
-func createOptimizedHTTPClient() *http.Client {
+func createOptimizedHTTPClient(cfg *config.Config) *http.Client {
+	timeout := cfg.Influx_Client_Timeout
+	if timeout <= 0 {
+		timeout = time.Duration(config.DefaultTimeout) * time.Second
+	}
+
+	maxIdleConns := cfg.Influx_Max_Idle_Conns
+	if maxIdleConns <= 0 {
+		maxIdleConns = config.HTTPMaxIdleConns
+	}
+	maxConnsPerHost := cfg.Influx_Max_Conns_Per_Host
+	if maxConnsPerHost <= 0 {
+		maxConnsPerHost = config.HTTPMaxConnsPerHost
+	}
+	idleConnTimeout := cfg.Influx_Idle_Conn_Timeout
+	if idleConnTimeout <= 0 {
+		idleConnTimeout = config.HTTPIdleConnTimeout * time.Second
+	}
+
 	transport := &http.Transport{
-		MaxIdleConns:          config.HTTPMaxIdleConns,
-		MaxConnsPerHost:       config.HTTPMaxConnsPerHost,
-		IdleConnTimeout:       config.HTTPIdleConnTimeout * time.Second,
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   min(maxIdleConns, maxConnsPerHost),
+		MaxConnsPerHost:       maxConnsPerHost,
+		IdleConnTimeout:       idleConnTimeout,
+		ForceAttemptHTTP2:     cfg.Influx_HTTP2,
 		ExpectContinueTimeout: 0, // Skip expect-continue for better latency
 		DialContext: (&net.Dialer{
 			Timeout:   5 * time.Second,
@@ -38,186 +98,624 @@ func createOptimizedHTTPClient() *http.Client {
 	}
 	return &http.Client{
 		Transport: transport,
-		Timeout:   time.Duration(config.DefaultTimeout) * time.Second,
+		Timeout:   timeout,
 	}
 }
 
+// createInfluxHTTPClient creates the HTTP client of the InfluxDB of cfg,
+// with its TLS settings
+func createInfluxHTTPClient(cfg *config.Config) (*http.Client, error) {
+	client := createOptimizedHTTPClient(cfg)
+	tlsConfig, err := influx.TLSConfig(cfg)
+	if err != nil || tlsConfig == nil {
+		return client, err
+	}
+	client.Transport.(*http.Transport).TLSClientConfig = tlsConfig
+	return client, nil
+}
+
+// drainTimeout bounds how long writes of packets already received may
+// continue once the input stops
+const drainTimeout = 10 * time.Second
+
+// newPacketID returns a short random ID used to correlate log lines of a packet
+func newPacketID() string {
+	return fmt.Sprintf("%08x", rand.Uint32())
+}
+
 // processPacket processes a weather data packet
-func processPacket(ctx context.Context, cfg *config.Config, logger *logger.AppLogger, influxURL *url.URL, addr *net.UDPAddr, b []byte, n int) {
+func (ws *WeatherService) processPacket(ctx context.Context, id string, addr net.Addr, b []byte, n int) {
+	log := ws.logger.With("packet_id", id)
+
 	// Add panic recovery
 	defer func() {
 		if r := recover(); r != nil {
-			logger.Error("Recovered from panic in packet processing",
-				"panic", r.(string),
+			log.Error("Recovered from panic in packet processing",
+				"panic", fmt.Sprint(r),
 				"remote_addr", addr.String())
 		}
 	}()
 
-	// Use Lo library for safer error handling
-	m, ok := lo.TryOr(func() (*influx.Data, error) {
-		return tempest.Parse(cfg, addr, b, n)
-	}, nil)
+	metrics.PacketsReceived.Inc()
+	health.RecordPacket(ws.clock.Now())
+
+	if ws.relay != nil {
+		if err := ws.relay.Forward(b[:n]); err != nil {
+			log.Warn("Failed to relay packet", "error", err.Error())
+		}
+	}
+
+	for _, observer := range ws.packets {
+		observer.ObservePacket(b[:n])
+	}
+
+	m, err := tempest.Parse(ws.config, addr, b, n)
+	if err != nil {
+		var schemaErr *tempest.SchemaError
+		if errors.As(err, &schemaErr) {
+			metrics.ParseErrors.WithLabelValues("schema").Inc()
+			log.Warn("Packet rejected by schema validation",
+				"remote_addr", addr.String(),
+				"report_type", schemaErr.ReportType,
+				"problems", schemaErr.Problems)
+		} else {
+			metrics.ParseErrors.WithLabelValues("parse").Inc()
+			log.Debug("Failed to parse packet",
+				"remote_addr", addr.String(),
+				"error", err.Error())
+		}
+		ws.captureRejected(log, id, addr, b[:n], err)
+		return
+	}
 
-	if !ok || m == nil {
+	if m == nil {
 		return
 	}
+	metrics.PacketsParsed.WithLabelValues(m.ReportType).Inc()
+	if station := m.Tags["station"]; station != "" {
+		metrics.LastSeen.WithLabelValues(station).SetToCurrentTime()
+	}
 
 	if m.Timestamp == 0 {
 		return
 	}
+	m.ID = id
 
-	if cfg.Debug {
-		logger.Debug("Processing InfluxData",
+	if ws.config.Debug {
+		log.Debug("Processing InfluxData",
 			"measurement", m.Name,
 			"timestamp", m.Timestamp,
 			"bucket", m.Bucket)
 	}
 
-	line := m.Marshal()
-	if cfg.Verbose {
-		logger.Info("Posting data to InfluxDB",
-			"data", line,
-			"url", influxURL.String())
+	points := []*influx.Data{m}
+	if err := m.Validate(); err != nil {
+		log.Error("Dropping invalid point", "error", err.Error())
+		ws.quarantinePoints(log, points, err.Error())
+		return
+	}
+	if err := influx.DefaultSchema.Normalize(m); err != nil {
+		log.Error("Dropping point with conflicting field types", "error", err.Error())
+		ws.quarantinePoints(log, points, err.Error())
+		return
+	}
+
+	for _, enricher := range ws.enrichers {
+		points = enricher.Enrich(points)
+	}
+	if len(points) == 0 {
+		return
+	}
+
+	for _, observer := range ws.observers {
+		observer.Observe(points)
 	}
 
-	if m.Bucket != "" {
-		// Set query arguments, preserving existing parameters like org
-		query := influxURL.Query()
-		query.Set("bucket", m.Bucket)
-		influxURL.RawQuery = query.Encode()
+	ws.writeOutputs(ctx, log, points)
+}
+
+// writeOutputs sends points to every output. Points rejected by all outputs
+// with errors that retrying cannot fix are quarantined.
+func (ws *WeatherService) writeOutputs(ctx context.Context, log *logger.AppLogger, points []*influx.Data) {
+	var failures []string
+	final := len(ws.outputs) > 0
+	for _, output := range ws.outputs {
+		err := output.Write(ctx, points)
+		debugvars.RecordWrite(output.Name(), len(points), err)
+		if err == nil {
+			metrics.OutputWrites.WithLabelValues(output.Name(), "success").Inc()
+			final = false
+			continue
+		}
+		metrics.OutputWrites.WithLabelValues(output.Name(), "failure").Inc()
+		log.Error("Failed to write points",
+			"output", output.Name(),
+			"error", err.Error())
+		failures = append(failures, output.Name()+": "+err.Error())
+		if influx.Retryable(err) {
+			final = false
+		}
+	}
+
+	if final {
+		ws.quarantinePoints(log, points, strings.Join(failures, "; "))
 	}
+}
+
+// flushFailed returns the handler of points a batched write to output
+// failed to deliver. With quarantine they are quarantined unless retrying
+// may succeed; mirrors do not quarantine, as requeued points go to the main
+// InfluxDB only.
+func (ws *WeatherService) flushFailed(output string, quarantine bool) func(points []*influx.Data, err error) {
+	return func(points []*influx.Data, err error) {
+		metrics.OutputWrites.WithLabelValues(output, "failure").Inc()
+		log := ws.logger.With("packet_ids", influx.PacketIDs(points))
+		log.Error("Failed to write buffered points",
+			"output", output,
+			"points", len(points),
+			"error", err.Error())
+		if quarantine && !influx.Retryable(err) {
+			ws.quarantinePoints(log, points, err.Error())
+		}
+	}
+}
 
-	// Create HTTP request with context
-	request, err := http.NewRequestWithContext(ctx, "POST", influxURL.String(), strings.NewReader(line))
+// spoolRejected quarantines spooled points InfluxDB rejected on replay
+func (ws *WeatherService) spoolRejected(points []*influx.Data, err error) {
+	ws.quarantinePoints(ws.logger.With("packet_ids", influx.PacketIDs(points)), points, err.Error())
+}
+
+// quarantinePoints stores undeliverable points when quarantine is enabled
+func (ws *WeatherService) quarantinePoints(log *logger.AppLogger, points []*influx.Data, reason string) {
+	if ws.quarantine == nil {
+		return
+	}
+	path, err := ws.quarantine.Save(points, reason)
 	if err != nil {
-		logger.Error("Failed to create HTTP request",
-			"error", err.Error(),
-			"url", influxURL.String())
+		log.Error("Failed to quarantine points", "error", err.Error())
 		return
 	}
-	request.Header.Set("Authorization", "Token "+cfg.Influx_Token)
-	request.Header.Set("Content-Type", "text/plain; charset=utf-8")
-	request.Header.Set("Accept", "application/json")
+	log.Warn("Quarantined points", "path", path, "points", len(points))
+}
 
-	if cfg.Noop {
-		logger.Info("NOOP mode - not posting to InfluxDB",
-			"url", influxURL.String())
+// captureRejected stores a rejected packet when capturing is enabled
+func (ws *WeatherService) captureRejected(log *logger.AppLogger, id string, addr net.Addr, data []byte, reason error) {
+	if ws.capture == nil {
 		return
 	}
+	path, err := ws.capture.Save(id, addr, data, reason)
+	if errors.Is(err, capture.ErrRateLimited) {
+		log.Debug("Capture rate limit reached, rejected packet not saved")
+		return
+	}
+	if err != nil {
+		log.Error("Failed to capture rejected packet", "error", err.Error())
+		return
+	}
+	log.Info("Captured rejected packet", "path", path)
+}
 
-	// Optimized HTTP client with proper transport configuration
-	client := createOptimizedHTTPClient()
+// WeatherService manages the weather data collection service
+type WeatherService struct {
+	config     *config.Config
+	logger     *logger.AppLogger
+	listener   UDPListener
+	source     Source
+	httpClient HTTPClient
+	clock      Clock
+	outputs    []Output
+	enrichers  []Enricher
+	observers  []Observer
+	packets    []PacketObserver
+	capture    *capture.Capture
+	quarantine *quarantine.Store
+	relay      *relay.Relay
+	state      *state.File
+	spool      *spool.Spool
+	inflight   sync.WaitGroup
+	queued     atomic.Int64
+}
 
-	// Use Lo library for safer HTTP request handling
-	resp, ok := lo.TryOr(func() (*http.Response, error) {
-		return client.Do(request)
-	}, nil)
+// Option configures optional WeatherService dependencies
+type Option func(*WeatherService)
 
-	if !ok || resp == nil {
-		logger.Error("Failed to post data to InfluxDB",
-			"influx_url", cfg.Influx_URL)
-		return
+// WithListener uses listener instead of binding cfg.Listen_Address
+func WithListener(listener UDPListener) Option {
+	return func(ws *WeatherService) {
+		ws.listener = listener
 	}
+}
 
-	defer resp.Body.Close()
+// WithSource reads packets from source instead of the configured input
+func WithSource(source Source) Option {
+	return func(ws *WeatherService) {
+		ws.source = source
+	}
+}
 
-	if resp.StatusCode >= 400 {
-		logger.Error("InfluxDB returned error status",
-			"status", resp.Status,
-			"status_code", resp.StatusCode)
-	} else if cfg.Verbose {
-		logger.Info("Successfully posted data to InfluxDB",
-			"status", resp.Status,
-			"status_code", resp.StatusCode)
+// WithHTTPClient uses client for requests made by the default outputs
+func WithHTTPClient(client HTTPClient) Option {
+	return func(ws *WeatherService) {
+		ws.httpClient = client
 	}
 }
 
-// WeatherService manages the weather data collection service
-type WeatherService struct {
-	config   *config.Config
-	logger   *logger.AppLogger
-	listener net.PacketConn
+// WithClock uses clock as the service time source
+func WithClock(clock Clock) Option {
+	return func(ws *WeatherService) {
+		ws.clock = clock
+	}
 }
 
-// NewWeatherService creates a new WeatherService
-func NewWeatherService(cfg *config.Config, appLogger *logger.AppLogger) (*WeatherService, error) {
-	// Create UDP listener
-	sourceAddr, err := net.ResolveUDPAddr("udp", cfg.Listen_Address)
-	if err != nil {
-		return nil, err
+// WithOutputs replaces the default InfluxDB output with outputs
+func WithOutputs(outputs ...Output) Option {
+	return func(ws *WeatherService) {
+		ws.outputs = outputs
 	}
+}
 
-	sourceConn, err := net.ListenUDP("udp", sourceAddr)
-	if err != nil {
-		return nil, err
+// WithEnrichers registers enrichers that run, in order, after the default ones
+func WithEnrichers(enrichers ...Enricher) Option {
+	return func(ws *WeatherService) {
+		ws.enrichers = append(ws.enrichers, enrichers...)
 	}
+}
 
-	return &WeatherService{
-		config:   cfg,
-		logger:   appLogger,
-		listener: sourceConn,
-	}, nil
+// WithObservers registers observers that see every valid point
+func WithObservers(observers ...Observer) Option {
+	return func(ws *WeatherService) {
+		ws.observers = append(ws.observers, observers...)
+	}
 }
 
-// Start starts the weather service
-func (ws *WeatherService) Start(ctx context.Context) error {
-	ws.logger.Info("Weather service started")
+// WithPacketObservers registers observers that see every raw packet
+func WithPacketObservers(observers ...PacketObserver) Option {
+	return func(ws *WeatherService) {
+		ws.packets = append(ws.packets, observers...)
+	}
+}
 
-	defer ws.listener.Close()
+// NewWeatherService creates a new WeatherService
+func NewWeatherService(cfg *config.Config, appLogger *logger.AppLogger, opts ...Option) (*WeatherService, error) {
+	ws := &WeatherService{
+		config: cfg,
+		logger: appLogger,
+	}
+	for _, opt := range opts {
+		opt(ws)
+	}
 
-	// Parse Influx URL and append API path
-	influxURL, err := url.Parse(ws.config.Influx_URL + ws.config.Influx_API_Path)
-	if err != nil {
-		return err
+	if ws.clock == nil {
+		ws.clock = systemClock{}
+	}
+
+	var enrichers []Enricher
+	if cfg.Dedupe_Hubs {
+		// Drop copies before anything accumulates them
+		enrichers = append(enrichers, dedupe.NewEnricher())
+	}
+	if calibration.Enabled(cfg) {
+		// Calibrate first so derived values use the corrected readings
+		enrichers = append(enrichers, calibration.NewEnricher(cfg))
+	}
+	if len(cfg.Field_Bounds) > 0 {
+		enrichers = append(enrichers, bounds.NewEnricher(cfg, appLogger))
+	}
+	if cfg.Lightning && lightning.Enabled(cfg) {
+		enrichers = append(enrichers, lightning.NewFilter(cfg))
+	}
+	if cfg.Pressure_Filter_Size > 0 {
+		enrichers = append(enrichers, smoothing.NewPressureFilter(cfg.Pressure_Filter_Size))
+	}
+	if cfg.Sea_Level_Pressure {
+		// After the pressure filter, so the filtered pressure is reduced
+		enrichers = append(enrichers, pressure.NewSeaLevelEnricher(cfg))
+	}
+	if cfg.Pressure_Trend {
+		enrichers = append(enrichers, pressure.NewTrendEnricher())
+	}
+	if cfg.Wind_Smoothing_Alpha > 0 {
+		enrichers = append(enrichers, smoothing.NewEnricher(cfg.Wind_Smoothing_Alpha))
 	}
+	if cfg.Rapid_Wind && rapidwind.Enabled(cfg) {
+		// After smoothing, which needs every reading
+		enrichers = append(enrichers, rapidwind.NewEnricher(cfg))
+	}
+	if cfg.Rain_Rate {
+		enrichers = append(enrichers, rain.NewEnricher())
+	}
+	if cfg.Daily_Stats {
+		enrichers = append(enrichers, daily.New(cfg, appLogger))
+	}
+	if solar.Enabled(cfg) {
+		enrichers = append(enrichers, solar.NewEnricher(cfg))
+	}
+	if comfort.Enabled(cfg) {
+		enrichers = append(enrichers, comfort.NewEnricher(cfg))
+	}
+	if cfg.ET0 && solar.Enabled(cfg) {
+		enrichers = append(enrichers, et0.NewEnricher(cfg))
+	}
+	if cfg.Cloud_Base {
+		enrichers = append(enrichers, cloudbase.Enricher{})
+	}
+	if cfg.Snow_Likely {
+		// Before the summary, which then reports snow
+		enrichers = append(enrichers, snow.Enricher{})
+	}
+	if cfg.Conditions_Summary {
+		enrichers = append(enrichers, conditions.Enricher{})
+	}
+	if cfg.Summary_Interval > 0 {
+		// Last, so summaries include derived fields
+		enrichers = append(enrichers, summary.New(cfg))
+	}
+	if tempest.UnitsEnabled(cfg) {
+		// Every enricher before computes in metric units
+		units, err := tempest.NewUnitConverter(cfg)
+		if err != nil {
+			return nil, err
+		}
+		enrichers = append(enrichers, units)
+	}
+	if len(cfg.Field_Filters) > 0 {
+		// After everything that computes fields
+		enrichers = append(enrichers, fieldfilter.NewEnricher(cfg))
+	}
+	if cfg.Timestamp_Collisions != "" {
+		// After everything that adds points
+		enrichers = append(enrichers, collision.NewEnricher(cfg))
+	}
+	ws.enrichers = append(enrichers, ws.enrichers...)
 
-	// Set query arguments
-	query := influxURL.Query()
-	query.Set("org", ws.config.Influx_Org)
-	query.Set("precision", "s")
-	influxURL.RawQuery = query.Encode()
+	if cfg.State_Dir != "" {
+		var persistent []state.Persistent
+		for _, e := range ws.enrichers {
+			if p, ok := e.(state.Persistent); ok {
+				persistent = append(persistent, p)
+			}
+		}
+		if len(persistent) > 0 {
+			ws.state = state.Open(filepath.Join(cfg.State_Dir, state.FileName), appLogger, persistent)
+		}
+	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			ws.logger.Info("Weather service shutting down")
-			return ctx.Err()
-		default:
-			// Set read timeout to allow periodic context checking
-			ws.listener.SetReadDeadline(time.Now().Add(1 * time.Second))
+	// InfluxDB and each mirror get their own clients for their TLS
+	// settings unless one is given
+	influxClient := ws.httpClient
+	if ws.httpClient == nil {
+		// Optimized HTTP client with proper transport configuration
+		ws.httpClient = createOptimizedHTTPClient(cfg)
+	}
 
-			b := make([]byte, ws.config.Buffer)
-			n, addr, err := ws.listener.ReadFrom(b)
+	if ws.outputs == nil {
+		client := influxClient
+		if client == nil {
+			var err error
+			if client, err = createInfluxHTTPClient(cfg); err != nil {
+				return nil, err
+			}
+		}
+		writer, err := influx.NewWriter(cfg, client, appLogger)
+		if err != nil {
+			return nil, err
+		}
+		ws.outputs = []Output{writer}
+		if cfg.Spool_Dir != "" {
+			if ws.spool, err = spool.New(cfg, writer, ws.spoolRejected, appLogger); err != nil {
+				return nil, err
+			}
+			ws.outputs[0] = ws.spool
+		}
+		if cfg.Influx_Flush_Interval > 0 {
+			ws.outputs[0] = influx.NewBatcher(cfg, ws.outputs[0], ws.flushFailed(writer.Name(), true), appLogger)
+		}
+		for i := range cfg.Influx_Mirrors {
+			client := influxClient
+			if client == nil {
+				if client, err = createInfluxHTTPClient(cfg.ForMirror(i)); err != nil {
+					return nil, fmt.Errorf("mirror %s: %w", cfg.MirrorName(i), err)
+				}
+			}
+			var mirror influx.PointWriter
+			if mirror, err = influx.NewMirrorWriter(context.Background(), cfg, i, client, appLogger); err != nil {
+				return nil, err
+			}
+			if cfg.Influx_Flush_Interval > 0 {
+				mirror = influx.NewBatcher(cfg, mirror, ws.flushFailed(mirror.Name(), false), appLogger)
+			}
+			ws.outputs = append(ws.outputs, mirror)
+		}
 
+		if cfg.Mqtt_Publish_Topic != "" {
+			publisher, err := mqtt.NewPublisher(cfg, appLogger)
 			if err != nil {
-				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-					// Timeout is expected, continue to check context
-					continue
-				}
-				udpAddr, _ := addr.(*net.UDPAddr)
-				ws.logger.Error("Could not receive UDP packet",
-					"remote_addr", udpAddr.String(),
-					"error", err.Error())
-				continue
+				return nil, err
 			}
+			ws.outputs = append(ws.outputs, publisher)
+		}
 
-			if ws.config.Debug {
-				udpAddr, _ := addr.(*net.UDPAddr)
-				ws.logger.Debug("Received UDP packet",
-					"remote_addr", udpAddr.String(),
-					"bytes", n,
-					"data", string(b[:n]))
+		if cfg.Telegraf_Address != "" {
+			out, err := telegraf.New(cfg)
+			if err != nil {
+				return nil, err
 			}
+			ws.outputs = append(ws.outputs, out)
+		}
+
+		if cfg.Zabbix_Server != "" {
+			ws.outputs = append(ws.outputs, zabbix.New(cfg))
+		}
 
-			if ws.config.Raw_UDP {
-				udpAddr, _ := addr.(*net.UDPAddr)
-				// Print raw bytes in hex format for tcpdump-like output
-				fmt.Printf("RAW UDP: %d bytes from %s: %x\n", n, udpAddr.String(), b[:n])
+		if cfg.Archive_S3_Bucket != "" {
+			a := archive.New(cfg, ws.httpClient, appLogger)
+			ws.outputs = append(ws.outputs, a)
+			if cfg.Archive_Raw {
+				ws.packets = append(ws.packets, a)
 			}
+		}
 
-			// Process packet in goroutine with context
-			udpAddr, _ := addr.(*net.UDPAddr)
-			go processPacket(ctx, ws.config, ws.logger, influxURL, udpAddr, b, n)
+		if cfg.Pushgateway_URL != "" {
+			ws.outputs = append(ws.outputs, pushgateway.New(cfg, ws.httpClient))
 		}
+
+		if cfg.Webhook_URL != "" {
+			hook, err := webhook.New(cfg, ws.httpClient)
+			if err != nil {
+				return nil, err
+			}
+			ws.outputs = append(ws.outputs, hook)
+		}
+
+		if alert.Enabled(cfg) {
+			alerter, err := alert.New(cfg, ws.httpClient)
+			if err != nil {
+				return nil, err
+			}
+			ws.outputs = append(ws.outputs, alerter)
+		}
+	}
+
+	if cfg.Capture_Dir != "" {
+		c, err := capture.New(cfg.Capture_Dir, cfg.Capture_Rate)
+		if err != nil {
+			return nil, err
+		}
+		ws.capture = c
+	} else if cfg.Capture_File != "" {
+		c, err := capture.NewFile(cfg.Capture_File, cfg.Capture_Rate, int64(cfg.Capture_Max_Size_MB)<<20)
+		if err != nil {
+			return nil, err
+		}
+		ws.capture = c
+	}
+
+	if cfg.Quarantine_Dir != "" {
+		q, err := quarantine.New(cfg.Quarantine_Dir)
+		if err != nil {
+			return nil, err
+		}
+		ws.quarantine = q
+	}
+
+	if rules := cfg.RelayRules(); len(rules) > 0 {
+		r, err := relay.New(rules)
+		if err != nil {
+			return nil, err
+		}
+		ws.relay = r
+	}
+
+	if ws.source == nil {
+		switch cfg.Input {
+		case config.InputMQTT:
+			ws.source = mqtt.NewSource(cfg, appLogger)
+		case config.InputStdin:
+			ws.source = &lineSource{reader: os.Stdin, maxLine: cfg.Buffer}
+		case config.InputWebSocket:
+			cloud := weatherflow.NewClient(cfg.WeatherFlow_Token, ws.httpClient)
+			ws.source = websocket.NewSource(cfg, appLogger, cloud)
+		}
+	}
+
+	if ws.source == nil {
+		if ws.listener == nil {
+			// Create UDP listener
+			sourceAddr, err := net.ResolveUDPAddr("udp", cfg.Listen_Address)
+			if err != nil {
+				return nil, err
+			}
+
+			sourceConn, err := net.ListenUDP("udp", sourceAddr)
+			if err != nil {
+				return nil, err
+			}
+			ws.listener = sourceConn
+		}
+		ws.source = &udpSource{
+			config:   cfg,
+			logger:   appLogger,
+			listener: ws.listener,
+			clock:    ws.clock,
+		}
+	}
+
+	if cfg.Poll_Fallback_After > 0 {
+		cloud := weatherflow.NewClient(cfg.WeatherFlow_Token, ws.httpClient)
+		ws.source = poller.New(cfg, appLogger, ws.source, cloud)
+	}
+
+	return ws, nil
+}
+
+// Queue returns the number of packets received but not yet fully processed
+func (ws *WeatherService) Queue() int {
+	return int(ws.queued.Load())
+}
+
+// Start starts the weather service and blocks until ctx is cancelled or the
+// input source stops. Packets already received are processed before it
+// returns.
+func (ws *WeatherService) Start(ctx context.Context) error {
+	ws.logger.Info("Weather service started", "input", ws.source.Name())
+
+	if ws.relay != nil {
+		defer ws.relay.Close()
+	}
+	if ws.state != nil {
+		go ws.state.Run(ctx)
+	}
+	if ws.spool != nil {
+		go ws.spool.Run(ctx)
+	}
+
+	// Writes are not bound to ctx, so packets received before a handoff or
+	// shutdown are still delivered; they are cancelled after drainTimeout
+	writeCtx, cancelWrites := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWrites()
+
+	health.SetListening(true)
+	defer health.SetListening(false)
+	err := ws.source.Run(ctx, func(addr net.Addr, data []byte) {
+		id := newPacketID()
+
+		if ws.config.Debug {
+			ws.logger.Debug("Received packet",
+				"packet_id", id,
+				"input", ws.source.Name(),
+				"remote_addr", addr.String(),
+				"bytes", len(data),
+				"data", string(data))
+		}
+
+		// Process packet in goroutine with context
+		ws.inflight.Add(1)
+		ws.queued.Add(1)
+		go func() {
+			defer ws.inflight.Done()
+			defer ws.queued.Add(-1)
+			ws.processPacket(writeCtx, id, addr, data, len(data))
+		}()
+	})
+	// The drain timeout covers the packets in flight and the final flushes
+	drain := time.AfterFunc(drainTimeout, cancelWrites)
+	defer drain.Stop()
+	ws.inflight.Wait()
+	if ws.state != nil {
+		if err := ws.state.Save(); err != nil {
+			ws.logger.Error("Failed to save state", "error", err.Error())
+		}
+	}
+	for _, output := range ws.outputs {
+		switch o := output.(type) {
+		case interface{ Shutdown(context.Context) error }:
+			o.Shutdown(writeCtx)
+		case io.Closer:
+			o.Close()
+		}
+	}
+	if ctx.Err() != nil {
+		ws.logger.Info("Weather service shutting down")
 	}
+	return err
 }
@@ -6,12 +6,17 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
 	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 // Mock UDP connection for testing
@@ -122,7 +127,7 @@ func (m *mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
 }
 
 func TestCreateOptimizedHTTPClient(t *testing.T) {
-	client := createOptimizedHTTPClient()
+	client := createOptimizedHTTPClient(&config.Config{})
 
 	if client == nil {
 		t.Fatal("createOptimizedHTTPClient() returned nil")
@@ -152,6 +157,31 @@ func TestCreateOptimizedHTTPClient(t *testing.T) {
 		t.Errorf("Expected ExpectContinueTimeout 0, got %v",
 			transport.ExpectContinueTimeout)
 	}
+
+	if transport.ForceAttemptHTTP2 {
+		t.Error("Expected HTTP/2 to be off by default")
+	}
+
+	client = createOptimizedHTTPClient(&config.Config{Influx_Client_Timeout: 42 * time.Second})
+	if client.Timeout != 42*time.Second {
+		t.Errorf("Expected configured timeout 42s, got %v", client.Timeout)
+	}
+
+	client = createOptimizedHTTPClient(&config.Config{
+		Influx_Max_Idle_Conns:     4,
+		Influx_Max_Conns_Per_Host: 2,
+		Influx_Idle_Conn_Timeout:  15 * time.Second,
+		Influx_HTTP2:              true,
+	})
+	transport = client.Transport.(*http.Transport)
+	if transport.MaxIdleConns != 4 || transport.MaxConnsPerHost != 2 || transport.MaxIdleConnsPerHost != 2 {
+		t.Errorf("Unexpected connection limits %d/%d/%d",
+			transport.MaxIdleConns, transport.MaxConnsPerHost, transport.MaxIdleConnsPerHost)
+	}
+	if transport.IdleConnTimeout != 15*time.Second || !transport.ForceAttemptHTTP2 {
+		t.Errorf("Expected configured idle timeout and HTTP/2, got %v/%v",
+			transport.IdleConnTimeout, transport.ForceAttemptHTTP2)
+	}
 }
 
 func TestNewWeatherService(t *testing.T) {
@@ -317,6 +347,450 @@ func TestWeatherServiceContextCancellation(t *testing.T) {
 	}
 }
 
+// packetSource delivers packet once and then waits for ctx to be cancelled
+type packetSource struct{ packet string }
+
+func (s packetSource) Name() string { return "test" }
+
+func (s packetSource) Run(ctx context.Context, handle func(addr net.Addr, data []byte)) error {
+	handle(&net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 50222}, []byte(s.packet))
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// slowOutput blocks writes until release is closed and records the error of
+// their context
+type slowOutput struct {
+	started chan struct{}
+	release chan struct{}
+	err     chan error
+}
+
+func (o *slowOutput) Name() string { return "slow" }
+
+func (o *slowOutput) Write(ctx context.Context, points []*influx.Data) error {
+	close(o.started)
+	<-o.release
+	o.err <- ctx.Err()
+	return ctx.Err()
+}
+
+func TestStartFinishesWritesAfterCancel(t *testing.T) {
+	cfg := &config.Config{Influx_Bucket: "test-bucket", Buffer: 1024}
+	output := &slowOutput{started: make(chan struct{}), release: make(chan struct{}), err: make(chan error, 1)}
+	service, err := NewWeatherService(cfg, logger.New(&config.Config{}),
+		WithSource(packetSource{packet: testObsPacket}),
+		WithOutputs(output))
+	if err != nil {
+		t.Fatalf("NewWeatherService() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- service.Start(ctx)
+	}()
+
+	select {
+	case <-output.started:
+	case <-time.After(time.Second):
+		t.Fatal("Output did not receive point")
+	}
+	// A handoff or shutdown cancels the pipeline while the write is running
+	cancel()
+	select {
+	case <-errChan:
+		t.Fatal("Start returned before the write in flight finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(output.release)
+
+	if err := <-output.err; err != nil {
+		t.Errorf("Expected the write to finish after the pipeline was cancelled, got %v", err)
+	}
+	if err := <-errChan; err != context.Canceled {
+		t.Errorf("Expected context.Canceled error, got %v", err)
+	}
+}
+
+// Mock output recording written points
+type mockOutput struct {
+	points chan *influx.Data
+}
+
+func newMockOutput() *mockOutput {
+	return &mockOutput{points: make(chan *influx.Data, 16)}
+}
+
+func (m *mockOutput) Name() string { return "mock" }
+
+func (m *mockOutput) Write(ctx context.Context, points []*influx.Data) error {
+	for _, p := range points {
+		m.points <- p
+	}
+	return nil
+}
+
+// Fixed clock for testing
+type fixedClock struct{ t time.Time }
+
+func (c fixedClock) Now() time.Time { return c.t }
+
+const testObsPacket = `{"serial_number":"ST-123456","type":"obs_st","hub_sn":"HB-1","obs":[[1640995200,1.5,2.3,3.8,180,3,1013.25,25.5,65.0,50000,5.2,800,0.5,0,5,2,3.7,1]]}`
+
+func TestNewWeatherServiceOptions(t *testing.T) {
+	cfg := &config.Config{
+		Listen_Address: "invalid:address:format", // never bound when a listener is injected
+		Influx_URL:     "http://localhost:8086",
+		Influx_Token:   "test-token",
+		Influx_Bucket:  "test-bucket",
+		Buffer:         1024,
+	}
+	appLogger := logger.New(&config.Config{Debug: false})
+
+	conn := newMockUDPConn()
+	conn.addPacket([]byte(testObsPacket), &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 50222}, nil)
+	output := newMockOutput()
+	clock := fixedClock{t: time.Unix(1640995200, 0)}
+
+	service, err := NewWeatherService(cfg, appLogger,
+		WithListener(conn),
+		WithClock(clock),
+		WithOutputs(output))
+	if err != nil {
+		t.Fatalf("NewWeatherService() error = %v", err)
+	}
+
+	if service.listener != conn {
+		t.Error("Injected listener not used")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- service.Start(ctx)
+	}()
+
+	select {
+	case m := <-output.points:
+		if m.Tags["station"] != "ST-123456" {
+			t.Errorf("Expected station ST-123456, got %s", m.Tags["station"])
+		}
+		if len(m.ID) != 8 {
+			t.Errorf("Expected 8 character packet ID, got %q", m.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Output did not receive point")
+	}
+
+	cancel()
+	<-errChan
+	if !conn.deadline.Equal(clock.t.Add(time.Second)) {
+		t.Errorf("Expected read deadline from injected clock, got %v", conn.deadline)
+	}
+}
+
+func TestNewWeatherServiceInput(t *testing.T) {
+	cfg := &config.Config{
+		Input:          config.InputMQTT,
+		Listen_Address: "invalid:address:format", // never bound for the mqtt input
+		Influx_URL:     "http://localhost:8086",
+		Influx_Bucket:  "test-bucket",
+		Mqtt_Broker:    "tcp://localhost:1883",
+		Mqtt_Topic:     "tempest/udp",
+		Buffer:         1024,
+	}
+	appLogger := logger.New(&config.Config{Debug: false})
+
+	service, err := NewWeatherService(cfg, appLogger, WithOutputs(newMockOutput()))
+	if err != nil {
+		t.Fatalf("NewWeatherService() error = %v", err)
+	}
+	if service.source.Name() != "mqtt" {
+		t.Errorf("Expected mqtt source, got %s", service.source.Name())
+	}
+	if service.listener != nil {
+		t.Error("UDP listener must not be bound for the mqtt input")
+	}
+}
+
+func TestNewWeatherServiceHTTPClientOption(t *testing.T) {
+	cfg := &config.Config{
+		Influx_URL:      "http://localhost:8086",
+		Influx_API_Path: "/api/v2/write",
+		Influx_Org:      "test-org",
+		Influx_Token:    "test-token",
+		Influx_Bucket:   "test-bucket",
+		Buffer:          1024,
+	}
+	appLogger := logger.New(&config.Config{Debug: false})
+
+	client := newMockHTTPClient()
+	client.addResponse(&http.Response{StatusCode: http.StatusNoContent, Status: "204 No Content", Body: http.NoBody}, nil)
+
+	service, err := NewWeatherService(cfg, appLogger,
+		WithListener(newMockUDPConn()),
+		WithHTTPClient(client))
+	if err != nil {
+		t.Fatalf("NewWeatherService() error = %v", err)
+	}
+
+	addr := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 50222}
+	service.processPacket(context.Background(), "abcd1234", addr, []byte(testObsPacket), len(testObsPacket))
+
+	if len(client.requests) != 1 {
+		t.Fatalf("Expected 1 request through injected client, got %d", len(client.requests))
+	}
+	if got := client.requests[0].URL.Query().Get("bucket"); got != "test-bucket" {
+		t.Errorf("Expected bucket test-bucket, got %s", got)
+	}
+}
+
+func TestNewWeatherServiceMirrors(t *testing.T) {
+	cfg := &config.Config{
+		Influx_URL:      "http://localhost:8086",
+		Influx_API_Path: "/api/v2/write",
+		Influx_Org:      "test-org",
+		Influx_Token:    "test-token",
+		Influx_Bucket:   "test-bucket",
+		Buffer:          1024,
+		Influx_Mirrors: []config.InfluxMirror{{
+			Name:   "cloud",
+			URL:    "https://cloud.example.com",
+			Org:    "cloud-org",
+			Token:  "cloud-token",
+			Bucket: "cloud-bucket",
+		}},
+	}
+	appLogger := logger.New(&config.Config{Debug: false})
+
+	client := newMockHTTPClient()
+	for range 2 {
+		client.addResponse(&http.Response{StatusCode: http.StatusNoContent, Status: "204 No Content", Body: http.NoBody}, nil)
+	}
+
+	service, err := NewWeatherService(cfg, appLogger,
+		WithListener(newMockUDPConn()),
+		WithHTTPClient(client))
+	if err != nil {
+		t.Fatalf("NewWeatherService() error = %v", err)
+	}
+
+	addr := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 50222}
+	service.processPacket(context.Background(), "abcd1234", addr, []byte(testObsPacket), len(testObsPacket))
+
+	if len(client.requests) != 2 {
+		t.Fatalf("Expected a request to InfluxDB and the mirror, got %d", len(client.requests))
+	}
+	mirror := client.requests[1]
+	if mirror.URL.Host != "cloud.example.com" || mirror.URL.Query().Get("bucket") != "cloud-bucket" {
+		t.Errorf("Unexpected mirror request %s", mirror.URL)
+	}
+	if got := mirror.Header.Get("Authorization"); got != "Token cloud-token" {
+		t.Errorf("Expected the mirror token, got %q", got)
+	}
+}
+
+func TestProcessPacketCapturesRejected(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Influx_URL:    "http://localhost:8086",
+		Influx_Bucket: "test-bucket",
+		Buffer:        1024,
+		Capture_Dir:   dir,
+		Capture_Rate:  10,
+	}
+	appLogger := logger.New(&config.Config{Debug: false})
+
+	output := newMockOutput()
+	service, err := NewWeatherService(cfg, appLogger,
+		WithListener(newMockUDPConn()),
+		WithOutputs(output))
+	if err != nil {
+		t.Fatalf("NewWeatherService() error = %v", err)
+	}
+
+	packet := []byte(`{"type": "obs_st", "obs": [broken`)
+	addr := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 50222}
+	service.processPacket(context.Background(), "deadbeef", addr, packet, len(packet))
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !strings.Contains(entries[0].Name(), "deadbeef") {
+		t.Errorf("Expected one capture file for packet deadbeef, got %v", entries)
+	}
+	if len(output.points) != 0 {
+		t.Error("Rejected packet should not reach outputs")
+	}
+}
+
+func TestProcessPacketMetrics(t *testing.T) {
+	cfg := &config.Config{
+		Influx_URL:    "http://localhost:8086",
+		Influx_Bucket: "test-bucket",
+		Buffer:        1024,
+	}
+	output := newMockOutput()
+	service, err := NewWeatherService(cfg, logger.New(&config.Config{}),
+		WithListener(newMockUDPConn()),
+		WithOutputs(output))
+	if err != nil {
+		t.Fatalf("NewWeatherService() error = %v", err)
+	}
+
+	parsed := testutil.ToFloat64(metrics.PacketsParsed.WithLabelValues("obs_st"))
+	parseErrors := testutil.ToFloat64(metrics.ParseErrors.WithLabelValues("parse"))
+	writes := testutil.ToFloat64(metrics.OutputWrites.WithLabelValues(output.Name(), "success"))
+
+	addr := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 50222}
+	for _, packet := range []string{testObsPacket, `{"type": "obs_st", "obs": [broken`} {
+		service.processPacket(context.Background(), "deadbeef", addr, []byte(packet), len(packet))
+	}
+
+	if got := testutil.ToFloat64(metrics.PacketsParsed.WithLabelValues("obs_st")) - parsed; got != 1 {
+		t.Errorf("Expected one parsed obs_st packet, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.ParseErrors.WithLabelValues("parse")) - parseErrors; got != 1 {
+		t.Errorf("Expected one parse error, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.OutputWrites.WithLabelValues(output.Name(), "success")) - writes; got != 1 {
+		t.Errorf("Expected one successful write, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.LastSeen.WithLabelValues("ST-123456")); got < float64(time.Now().Add(-time.Minute).Unix()) {
+		t.Errorf("Expected the station to be seen just now, got %v", got)
+	}
+}
+
+// extraPointEnricher tags points and adds one point of its own
+type extraPointEnricher struct{}
+
+func (extraPointEnricher) Enrich(points []*influx.Data) []*influx.Data {
+	extra := influx.New()
+	extra.Name = "annotation"
+	extra.Fields["text"] = "hello"
+	for _, m := range points {
+		m.Tags["enriched"] = "true"
+	}
+	return append(points, extra)
+}
+
+func TestProcessPacketEnrichers(t *testing.T) {
+	cfg := &config.Config{
+		Influx_URL:    "http://localhost:8086",
+		Influx_Bucket: "test-bucket",
+		Buffer:        1024,
+		Daily_Stats:   true,
+	}
+	output := newMockOutput()
+	service, err := NewWeatherService(cfg, logger.New(&config.Config{}),
+		WithListener(newMockUDPConn()),
+		WithOutputs(output),
+		WithEnrichers(extraPointEnricher{}))
+	if err != nil {
+		t.Fatalf("NewWeatherService() error = %v", err)
+	}
+
+	addr := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 50222}
+	service.processPacket(context.Background(), "deadbeef", addr, []byte(testObsPacket), len(testObsPacket))
+
+	if len(output.points) != 2 {
+		t.Fatalf("Expected observation and added point, got %d points", len(output.points))
+	}
+	obs := <-output.points
+	if obs.Tags["enriched"] != "true" {
+		t.Error("Expected custom enricher to run on the observation")
+	}
+	if obs.Fields["rain_today"] != 0.5 {
+		t.Errorf("Expected daily stats to run first, rain_today = %q", obs.Fields["rain_today"])
+	}
+	if extra := <-output.points; extra.Name != "annotation" {
+		t.Errorf("Expected added point second, got %s", extra.Name)
+	}
+}
+
+// Output failing every write with a fixed error
+type failingOutput struct{ err error }
+
+func (f failingOutput) Name() string { return "failing" }
+
+func (f failingOutput) Write(ctx context.Context, points []*influx.Data) error { return f.err }
+
+func TestProcessPacketRelays(t *testing.T) {
+	dest, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dest.Close()
+
+	cfg := &config.Config{
+		Influx_URL:    "http://localhost:8086",
+		Influx_Bucket: "test-bucket",
+		Buffer:        1024,
+		Relay_To:      []string{dest.LocalAddr().String()},
+	}
+	appLogger := logger.New(&config.Config{Debug: false})
+
+	service, err := NewWeatherService(cfg, appLogger,
+		WithListener(newMockUDPConn()),
+		WithOutputs(newMockOutput()))
+	if err != nil {
+		t.Fatalf("NewWeatherService() error = %v", err)
+	}
+	defer service.relay.Close()
+
+	// Even packets the parser rejects are relayed unchanged
+	packet := []byte(`{"type": "future_type"}`)
+	addr := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 50222}
+	service.processPacket(context.Background(), "deadbeef", addr, packet, len(packet))
+
+	dest.SetReadDeadline(time.Now().Add(2 * time.Second))
+	b := make([]byte, 1024)
+	n, _, err := dest.ReadFromUDP(b)
+	if err != nil {
+		t.Fatalf("No relayed datagram: %v", err)
+	}
+	if string(b[:n]) != string(packet) {
+		t.Errorf("Relayed %q, want %q", b[:n], packet)
+	}
+}
+
+func TestWriteOutputsQuarantine(t *testing.T) {
+	tests := []struct {
+		name        string
+		outputs     []Output
+		quarantined bool
+	}{
+		{"all final", []Output{failingOutput{&influx.WriteError{StatusCode: 400}}}, true},
+		{"retryable", []Output{failingOutput{&influx.WriteError{StatusCode: 503}}}, false},
+		{"one succeeds", []Output{failingOutput{&influx.WriteError{StatusCode: 400}}, newMockOutput()}, false},
+		{"unknown error", []Output{failingOutput{errors.New("boom")}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfg := &config.Config{Buffer: 1024, Quarantine_Dir: dir}
+			service, err := NewWeatherService(cfg, logger.New(&config.Config{}),
+				WithListener(newMockUDPConn()),
+				WithOutputs(tt.outputs...))
+			if err != nil {
+				t.Fatalf("NewWeatherService() error = %v", err)
+			}
+
+			addr := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 50222}
+			service.processPacket(context.Background(), "abcd1234", addr, []byte(testObsPacket), len(testObsPacket))
+
+			entries, _ := os.ReadDir(dir)
+			if (len(entries) == 1) != tt.quarantined {
+				t.Errorf("Expected quarantined=%v, got %d entries", tt.quarantined, len(entries))
+			}
+		})
+	}
+}
+
 func TestBufferPool(t *testing.T) {
 	// Test that buffer pool works correctly
 	buf1 := bufferPool.Get().([]byte)
@@ -343,7 +817,7 @@ func TestBufferPool(t *testing.T) {
 func BenchmarkCreateOptimizedHTTPClient(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = createOptimizedHTTPClient()
+		_ = createOptimizedHTTPClient(&config.Config{})
 	}
 }
 
@@ -0,0 +1,113 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+// udpSource reads the hub's UDP broadcasts
+type udpSource struct {
+	config   *config.Config
+	logger   *logger.AppLogger
+	listener UDPListener
+	clock    Clock
+}
+
+// Name implements Source
+func (s *udpSource) Name() string { return "udp" }
+
+// Run implements Source
+func (s *udpSource) Run(ctx context.Context, handle func(addr net.Addr, data []byte)) error {
+	defer s.listener.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			// Set read timeout to allow periodic context checking
+			s.listener.SetReadDeadline(s.clock.Now().Add(1 * time.Second))
+
+			b := make([]byte, s.config.Buffer)
+			n, addr, err := s.listener.ReadFrom(b)
+
+			if err != nil {
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+					// Timeout is expected, continue to check context
+					continue
+				}
+				udpAddr, _ := addr.(*net.UDPAddr)
+				s.logger.Error("Could not receive UDP packet",
+					"remote_addr", udpAddr.String(),
+					"error", err.Error())
+				continue
+			}
+
+			if s.config.Raw_UDP {
+				udpAddr, _ := addr.(*net.UDPAddr)
+				// Print raw bytes in hex format for tcpdump-like output
+				fmt.Printf("RAW UDP: %d bytes from %s: %x\n", n, udpAddr.String(), b[:n])
+			}
+
+			handle(addr, b[:n])
+		}
+	}
+}
+
+// stdinAddr is the remote address reported for packets read from stdin
+type stdinAddr struct{}
+
+func (stdinAddr) Network() string { return "stdin" }
+func (stdinAddr) String() string  { return "stdin" }
+
+// lineSource reads newline-delimited Tempest JSON, one packet per line
+type lineSource struct {
+	reader  io.Reader
+	maxLine int
+}
+
+// Name implements Source
+func (s *lineSource) Name() string { return "stdin" }
+
+// Run implements Source. It returns nil at end of input.
+func (s *lineSource) Run(ctx context.Context, handle func(addr net.Addr, data []byte)) error {
+	lines := make(chan []byte)
+	errc := make(chan error, 1)
+
+	// Reads block, so scan in a goroutine to stay responsive to ctx
+	go func() {
+		scanner := bufio.NewScanner(s.reader)
+		scanner.Buffer(make([]byte, 0, 4096), max(s.maxLine, bufio.MaxScanTokenSize))
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			select {
+			case lines <- bytes.Clone(line):
+			case <-ctx.Done():
+				return
+			}
+		}
+		errc <- scanner.Err()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case line := <-lines:
+			handle(stdinAddr{}, line)
+		case err := <-errc:
+			return err
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package processor
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+func TestLineSource(t *testing.T) {
+	input := "{\"type\":\"a\"}\n\n  {\"type\":\"b\"}  \n{\"type\":\"c\"}"
+	source := &lineSource{reader: strings.NewReader(input), maxLine: 1024}
+
+	var got []string
+	err := source.Run(context.Background(), func(addr net.Addr, data []byte) {
+		if addr.Network() != "stdin" {
+			t.Errorf("Unexpected address network %s", addr.Network())
+		}
+		got = append(got, string(data))
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := []string{`{"type":"a"}`, `{"type":"b"}`, `{"type":"c"}`}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Got packets %v, want %v", got, want)
+	}
+}
+
+func TestStartWithLineSource(t *testing.T) {
+	cfg := &config.Config{
+		Input:         config.InputStdin,
+		Influx_URL:    "http://localhost:8086",
+		Influx_Bucket: "test-bucket",
+		Buffer:        1024,
+	}
+	appLogger := logger.New(&config.Config{Debug: false})
+
+	input := testObsPacket + "\n" + testObsPacket + "\n"
+	output := newMockOutput()
+	service, err := NewWeatherService(cfg, appLogger,
+		WithSource(&lineSource{reader: strings.NewReader(input), maxLine: cfg.Buffer}),
+		WithOutputs(output))
+	if err != nil {
+		t.Fatalf("NewWeatherService() error = %v", err)
+	}
+
+	// Start returns at end of input once every packet has been written
+	if err := service.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if len(output.points) != 2 {
+		t.Errorf("Expected 2 points written before Start returned, got %d", len(output.points))
+	}
+}
@@ -4,20 +4,35 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
 )
 
 // UDPListener interface for UDP operations
 type UDPListener interface {
-	ReadFromUDP([]byte) (int, *net.UDPAddr, error)
-	SetReadDeadline(deadline interface{}) error
+	ReadFrom([]byte) (int, net.Addr, error)
+	SetReadDeadline(t time.Time) error
 	Close() error
 }
 
+// Source delivers raw Tempest JSON packets to handle until ctx is cancelled.
+// Each data slice passed to handle must not be reused by the source.
+type Source interface {
+	Name() string
+	Run(ctx context.Context, handle func(addr net.Addr, data []byte)) error
+}
+
 // HTTPClient interface for HTTP operations
 type HTTPClient interface {
 	Do(*http.Request) (*http.Response, error)
 }
 
+// Clock interface for obtaining the current time
+type Clock interface {
+	Now() time.Time
+}
+
 // Logger interface for structured logging
 type Logger interface {
 	Info(msg string, args ...interface{})
@@ -31,6 +46,31 @@ type PacketProcessor interface {
 	ProcessPacket(ctx context.Context, addr *net.UDPAddr, data []byte, length int) error
 }
 
+// Output interface for destinations that receive parsed points
+type Output interface {
+	Name() string
+	Write(ctx context.Context, points []*influx.Data) error
+}
+
+// Enricher derives additional fields or points from parsed points before
+// they are observed and written. It returns the points to deliver, which may
+// include points it added.
+type Enricher interface {
+	Enrich(points []*influx.Data) []*influx.Data
+}
+
+// Observer receives every valid parsed point. Unlike outputs, observers
+// cannot fail and must not block; they never affect delivery or quarantine.
+type Observer interface {
+	Observe(points []*influx.Data)
+}
+
+// PacketObserver receives every raw packet before it is parsed, including
+// packets the parser ignores or rejects. It must not block.
+type PacketObserver interface {
+	ObservePacket(b []byte)
+}
+
 // ConfigValidator interface for configuration validation
 type ConfigValidator interface {
 	Validate() error
@@ -42,3 +82,8 @@ type WeatherStation struct {
 	Name     string
 	Location string
 }
+
+// systemClock implements Clock using the system time
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
@@ -0,0 +1,123 @@
+// Package pushgateway pushes the latest values of every station to a
+// Prometheus Pushgateway, for environments where Prometheus cannot scrape
+// the collector
+package pushgateway
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// Prefix starts the name of every pushed metric
+const Prefix = "tempest_"
+
+// labels identify the series of one field
+var labels = []string{"measurement", "station"}
+
+// Output keeps one gauge per numeric field, labeled with measurement and
+// station, e.g. tempest_temp{measurement="weather",station="ST-1"}, and
+// pushes all of them after every write. Pushes replace the whole group, so
+// the Pushgateway holds the latest value of every station seen since start.
+// tempest_observation_timestamp_seconds holds the time of the latest point,
+// since the Pushgateway itself only records the time of the push.
+type Output struct {
+	pusher *push.Pusher
+
+	mu        sync.Mutex
+	registry  *prometheus.Registry
+	gauges    map[string]*prometheus.GaugeVec
+	timestamp *prometheus.GaugeVec
+}
+
+// New creates an Output pushing to cfg.Pushgateway_URL with the
+// Pushgateway_Job and Pushgateway_Instance labels. The instance defaults
+// to the host name.
+func New(cfg *config.Config, client push.HTTPDoer) *Output {
+	job := cfg.Pushgateway_Job
+	if job == "" {
+		job = config.DefaultPushgatewayJob
+	}
+	instance := cfg.Pushgateway_Instance
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+
+	o := &Output{
+		registry: prometheus.NewRegistry(),
+		gauges:   make(map[string]*prometheus.GaugeVec),
+		timestamp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: Prefix + "observation_timestamp_seconds",
+			Help: "Time of the latest observation.",
+		}, labels),
+	}
+	o.registry.MustRegister(o.timestamp)
+
+	o.pusher = push.New(cfg.Pushgateway_URL, job).Gatherer(o.registry).Client(client)
+	if instance != "" {
+		o.pusher = o.pusher.Grouping("instance", instance)
+	}
+	return o
+}
+
+// Name implements processor.Output
+func (o *Output) Name() string {
+	return "pushgateway"
+}
+
+// Write updates the gauges from points and pushes them
+func (o *Output) Write(ctx context.Context, points []*influx.Data) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, m := range points {
+		station := m.Tags["station"]
+		for field := range m.Fields {
+			value, ok := m.Float(field)
+			if !ok {
+				continue
+			}
+			o.gauge(field).WithLabelValues(m.Name, station).Set(value)
+		}
+		ts := float64(m.Timestamp) + float64(m.Nanos)/1e9
+		o.timestamp.WithLabelValues(m.Name, station).Set(ts)
+	}
+
+	if err := o.pusher.PushContext(ctx); err != nil {
+		return fmt.Errorf("pushing to Pushgateway: %w", err)
+	}
+	return nil
+}
+
+// gauge returns the gauge of field, registering it on first use. Fields
+// whose names only differ in invalid characters share a gauge.
+func (o *Output) gauge(field string) *prometheus.GaugeVec {
+	name := Prefix + metricName(field)
+	if g, ok := o.gauges[name]; ok {
+		return g
+	}
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: name,
+		Help: "Latest value of the " + field + " field.",
+	}, labels)
+	o.registry.MustRegister(g)
+	o.gauges[name] = g
+	return g
+}
+
+// metricName replaces characters that are not valid in metric names
+func metricName(field string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, field)
+}
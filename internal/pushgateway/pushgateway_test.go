@@ -0,0 +1,123 @@
+package pushgateway
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+func testPoint(station string, temp float64) *influx.Data {
+	m := influx.New()
+	m.Name = "weather"
+	m.Timestamp = 1640995200
+	m.Tags["station"] = station
+	m.Fields["temp"] = temp
+	m.Fields["lightning_strike_count"] = int64(2)
+	m.Fields["conditions"] = "Clear"
+	return m
+}
+
+// capture records the path and body of the last push
+type capture struct {
+	method string
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// families decodes the pushed metric families by name
+func (c *capture) families(t *testing.T) map[string]*dto.MetricFamily {
+	t.Helper()
+	dec := expfmt.NewDecoder(bytes.NewReader(c.body), expfmt.ResponseFormat(c.header))
+	families := make(map[string]*dto.MetricFamily)
+	for {
+		var mf dto.MetricFamily
+		if err := dec.Decode(&mf); err == io.EOF {
+			return families
+		} else if err != nil {
+			t.Fatalf("decoding pushed metrics: %v", err)
+		}
+		families[mf.GetName()] = &mf
+	}
+}
+
+func fakeGateway(t *testing.T, status int) (*httptest.Server, *capture) {
+	t.Helper()
+	c := &capture{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		c.method, c.path, c.header, c.body = r.Method, r.URL.Path, r.Header, b
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, c
+}
+
+func TestWrite(t *testing.T) {
+	srv, pushed := fakeGateway(t, http.StatusOK)
+	cfg := &config.Config{Pushgateway_URL: srv.URL, Pushgateway_Job: "weather", Pushgateway_Instance: "garden"}
+	out := New(cfg, srv.Client())
+
+	if err := out.Write(context.Background(), []*influx.Data{testPoint("ST-1", 21.5)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := out.Write(context.Background(), []*influx.Data{testPoint("ST-2", 18)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if pushed.method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", pushed.method)
+	}
+	if pushed.path != "/metrics/job/weather/instance/garden" {
+		t.Errorf("path = %s", pushed.path)
+	}
+
+	families := pushed.families(t)
+	temp, ok := families["tempest_temp"]
+	if !ok {
+		t.Fatalf("tempest_temp not pushed, got %v", families)
+	}
+	// Both stations are pushed, the first one from the previous write
+	values := make(map[string]float64)
+	for _, m := range temp.GetMetric() {
+		for _, l := range m.GetLabel() {
+			if l.GetName() == "station" {
+				values[l.GetValue()] = m.GetGauge().GetValue()
+			}
+		}
+	}
+	if values["ST-1"] != 21.5 || values["ST-2"] != 18 {
+		t.Errorf("tempest_temp = %v", values)
+	}
+	if _, ok := families["tempest_lightning_strike_count"]; !ok {
+		t.Error("Expected integer fields to be pushed")
+	}
+	if _, ok := families["tempest_conditions"]; ok {
+		t.Error("Expected string fields to be skipped")
+	}
+	if _, ok := families["tempest_observation_timestamp_seconds"]; !ok {
+		t.Error("Expected the observation timestamp to be pushed")
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	srv, _ := fakeGateway(t, http.StatusBadRequest)
+	out := New(&config.Config{Pushgateway_URL: srv.URL}, srv.Client())
+	if err := out.Write(context.Background(), []*influx.Data{testPoint("ST-1", 21.5)}); err == nil {
+		t.Error("Expected an error when the Pushgateway rejects the push")
+	}
+}
+
+func TestMetricName(t *testing.T) {
+	if got := metricName("wind.avg-1"); got != "wind_avg_1" {
+		t.Errorf("metricName() = %s", got)
+	}
+}
@@ -0,0 +1,138 @@
+package quarantine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+// fileSuffix identifies quarantine entries in the directory
+const fileSuffix = ".json"
+
+// Entry is a set of points that could not be delivered, with the reason why
+type Entry struct {
+	Quarantined time.Time      `json:"quarantined"`
+	Reason      string         `json:"reason"`
+	Points      []*influx.Data `json:"points"`
+}
+
+// Store keeps quarantined points as JSON files in a directory
+type Store struct {
+	dir string
+	now func() time.Time
+}
+
+// New creates a Store in dir, creating the directory if needed
+func New(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating quarantine directory: %w", err)
+	}
+	return &Store{dir: dir, now: time.Now}, nil
+}
+
+// Dir returns the quarantine directory
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Save quarantines points with reason and returns the path of the new entry
+func (s *Store) Save(points []*influx.Data, reason string) (string, error) {
+	now := s.now().UTC()
+	entry := Entry{
+		Quarantined: now,
+		Reason:      reason,
+		Points:      points,
+	}
+
+	b, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encoding quarantine entry: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%08x%s", now.Format("20060102T150405.000000000Z"), rand.Uint32(), fileSuffix)
+	path := filepath.Join(s.dir, name)
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return "", fmt.Errorf("writing quarantine entry: %w", err)
+	}
+	return path, nil
+}
+
+// List returns the paths of all quarantine entries, oldest first
+func (s *Store) List() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasSuffix(e.Name(), fileSuffix) {
+			paths = append(paths, filepath.Join(s.dir, e.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Load reads the quarantine entry at path
+func (s *Store) Load(path string) (*Entry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return &entry, nil
+}
+
+// ReplayResult summarizes a Replay run
+type ReplayResult struct {
+	Replayed int
+	Failed   int
+	Points   int
+}
+
+// Replay writes every quarantined entry with write, removing entries that
+// were written successfully. Entries that fail stay in place for a later run.
+func (s *Store) Replay(ctx context.Context, write func(context.Context, []*influx.Data) error) (ReplayResult, error) {
+	var result ReplayResult
+
+	paths, err := s.List()
+	if err != nil {
+		return result, err
+	}
+
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		entry, err := s.Load(path)
+		if err != nil {
+			result.Failed++
+			continue
+		}
+
+		if err := write(ctx, entry.Points); err != nil {
+			result.Failed++
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			return result, err
+		}
+		result.Replayed++
+		result.Points += len(entry.Points)
+	}
+	return result, nil
+}
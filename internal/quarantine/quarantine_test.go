@@ -0,0 +1,76 @@
+package quarantine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func newPoint(temp float64) *influx.Data {
+	m := influx.New()
+	m.ID = "abcd1234"
+	m.Name = "weather"
+	m.Bucket = "test-bucket"
+	m.Tags["station"] = "ST-123"
+	m.Fields["temp"] = temp
+	m.Timestamp = 1640995200
+	return m
+}
+
+func TestStoreSaveLoad(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	path, err := s.Save([]*influx.Data{newPoint(25.5)}, "400 Bad Request: field type conflict")
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	entry, err := s.Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if entry.Reason != "400 Bad Request: field type conflict" {
+		t.Errorf("Unexpected reason %q", entry.Reason)
+	}
+	if len(entry.Points) != 1 || entry.Points[0].Marshal() != newPoint(25.5).Marshal() {
+		t.Errorf("Points did not round trip: %+v", entry.Points)
+	}
+}
+
+func TestStoreReplay(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for _, temp := range []float64{1, 2, 3} {
+		if _, err := s.Save([]*influx.Data{newPoint(temp)}, "rejected"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write := func(ctx context.Context, points []*influx.Data) error {
+		if points[0].Fields["temp"] == 2.0 {
+			return errors.New("still failing")
+		}
+		return nil
+	}
+
+	result, err := s.Replay(context.Background(), write)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if result.Replayed != 2 || result.Failed != 1 || result.Points != 2 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	remaining, _ := s.List()
+	if len(remaining) != 1 {
+		t.Errorf("Expected failed entry to remain, got %d entries", len(remaining))
+	}
+}
@@ -0,0 +1,129 @@
+// Package rain derives the rain rate and rolling rain totals from the
+// per-interval precipitation of obs_st
+package rain
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+// Names of the rain fields
+const (
+	RateField     = "rain_rate_mm_h"
+	LastHourField = "rain_last_hour"
+)
+
+const (
+	// window is the span of the rolling total in seconds
+	window = 3600
+
+	// defaultInterval is the obs_st interval in seconds assumed for the
+	// first observation and after a gap
+	defaultInterval = 60
+
+	// maxInterval is the longest time in seconds between two observations
+	// that is still taken as their interval
+	maxInterval = 600
+)
+
+// sample is the precipitation of one observation
+type sample struct {
+	Timestamp int64   `json:"timestamp"`
+	Rain      float64 `json:"rain"`
+}
+
+// Enricher adds the rain rate and the rain of the last hour to obs_st
+// points, keeping each station's observations of the last hour
+type Enricher struct {
+	mu      sync.Mutex
+	history map[string][]sample
+}
+
+// NewEnricher creates an Enricher with an empty history
+func NewEnricher() *Enricher {
+	return &Enricher{history: make(map[string][]sample)}
+}
+
+// Enrich adds the rain fields to every obs_st point with a precipitation
+func (e *Enricher) Enrich(points []*influx.Data) []*influx.Data {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, m := range points {
+		if m.ReportType != "obs_st" {
+			continue
+		}
+		rain, ok := m.Float("precipitation")
+		if !ok {
+			continue
+		}
+		rate, lastHour := e.add(m.Tags["station"], m.Timestamp, rain)
+		m.Fields[RateField] = influx.Round(rate, 2)
+		m.Fields[LastHourField] = influx.Round(lastHour, 2)
+	}
+	return points
+}
+
+// add records the rain of an observation and returns the rate in mm/h over
+// the observation's interval and the total of the hour up to timestamp.
+// Late and repeated observations are not recorded.
+func (e *Enricher) add(station string, timestamp int64, rain float64) (rate, lastHour float64) {
+	history := e.history[station]
+	interval := int64(defaultInterval)
+	if n := len(history); n > 0 {
+		last := history[n-1].Timestamp
+		if timestamp <= last {
+			return rain * 3600 / defaultInterval, total(history, timestamp)
+		}
+		if timestamp-last <= maxInterval {
+			interval = timestamp - last
+		}
+	}
+
+	history = append(history, sample{Timestamp: timestamp, Rain: rain})
+	for len(history) > 0 && history[0].Timestamp <= timestamp-window {
+		history = history[1:]
+	}
+	e.history[station] = history
+	return rain * 3600 / float64(interval), total(history, timestamp)
+}
+
+// total returns the rain of the samples within the hour up to timestamp
+func total(history []sample, timestamp int64) float64 {
+	var sum float64
+	for _, s := range history {
+		if s.Timestamp > timestamp-window && s.Timestamp <= timestamp {
+			sum += s.Rain
+		}
+	}
+	return sum
+}
+
+// StateKey implements state.Persistent
+func (e *Enricher) StateKey() string {
+	return "rain"
+}
+
+// MarshalState implements state.Persistent
+func (e *Enricher) MarshalState() (json.RawMessage, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return json.Marshal(e.history)
+}
+
+// UnmarshalState implements state.Persistent. Samples older than an hour
+// drop out with the next observation as usual.
+func (e *Enricher) UnmarshalState(b json.RawMessage) error {
+	var history map[string][]sample
+	if err := json.Unmarshal(b, &history); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for station, samples := range history {
+		e.history[station] = samples
+	}
+	return nil
+}
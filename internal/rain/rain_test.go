@@ -0,0 +1,62 @@
+package rain
+
+import (
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func observation(timestamp int64, precipitation float64) *influx.Data {
+	m := influx.New()
+	m.ReportType = "obs_st"
+	m.Timestamp = timestamp
+	m.Tags["station"] = "ST-00012345"
+	m.Fields["precipitation"] = precipitation
+	return m
+}
+
+func TestEnricher(t *testing.T) {
+	e := NewEnricher()
+
+	tests := []struct {
+		timestamp int64
+		rain      float64
+		rate      float64
+		lastHour  float64
+	}{
+		{1000, 0.1, 6.0, 0.1},
+		{1060, 0.2, 12.0, 0.3},
+		{1180, 0.4, 12.0, 0.7}, // two minutes since the last one
+		{1180, 0.4, 24.0, 0.7}, // repeated observation is not counted
+		{4660, 0.0, 0.0, 0.4},  // the first two dropped out
+		{9000, 1.0, 60.0, 1.0}, // after a gap
+	}
+	for _, tt := range tests {
+		m := observation(tt.timestamp, tt.rain)
+		e.Enrich([]*influx.Data{m})
+		if m.Fields[RateField] != tt.rate || m.Fields[LastHourField] != tt.lastHour {
+			t.Errorf("At %d: rate %v, last hour %v; want %v, %v",
+				tt.timestamp, m.Fields[RateField], m.Fields[LastHourField], tt.rate, tt.lastHour)
+		}
+	}
+}
+
+func TestEnricherState(t *testing.T) {
+	e := NewEnricher()
+	e.Enrich([]*influx.Data{observation(1000, 0.5)})
+
+	b, err := e.MarshalState()
+	if err != nil {
+		t.Fatalf("MarshalState() error = %v", err)
+	}
+	restored := NewEnricher()
+	if err := restored.UnmarshalState(b); err != nil {
+		t.Fatalf("UnmarshalState() error = %v", err)
+	}
+
+	m := observation(1060, 0.25)
+	restored.Enrich([]*influx.Data{m})
+	if m.Fields[LastHourField] != 0.75 {
+		t.Errorf("%s = %v after restore, want 0.75", LastHourField, m.Fields[LastHourField])
+	}
+}
@@ -0,0 +1,155 @@
+// Package rapidwind reduces the three-second rapid_wind stream, either by
+// forwarding every Nth report or by aggregating intervals into one point
+package rapidwind
+
+import (
+	"maps"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+// ReportType is the report type the Enricher reduces
+const ReportType = "rapid_wind"
+
+// Enabled reports whether rapid wind downsampling is configured
+func Enabled(cfg *config.Config) bool {
+	return cfg.Rapid_Wind_Every > 1 || cfg.Rapid_Wind_Interval > 0
+}
+
+// window is the aggregate of one station for one interval in progress
+type window struct {
+	start    int64
+	template *influx.Data // first point, supplying measurement, bucket and tags
+
+	count          int
+	sum, min, max  float64
+	gustDirection  float64
+	sinSum, cosSum float64 // speed-weighted direction components
+	directional    bool    // whether any reading was above calm
+}
+
+// Enricher downsamples rapid_wind points per station. With every set it
+// passes one report in every; with an interval it replaces the reports by
+// one point per interval holding the lowest, average and highest speed,
+// the direction of the gust and the vector average direction. Intervals
+// are aligned to the Unix epoch, and the point of an interval is emitted
+// with the first report of the next one and timestamped with its start.
+type Enricher struct {
+	every    int
+	interval int64
+
+	mu      sync.Mutex
+	counts  map[string]int
+	windows map[string]*window
+}
+
+// NewEnricher creates an Enricher for the rapid wind settings of cfg
+func NewEnricher(cfg *config.Config) *Enricher {
+	return &Enricher{
+		every:    cfg.Rapid_Wind_Every,
+		interval: int64(cfg.Rapid_Wind_Interval / time.Second),
+		counts:   make(map[string]int),
+		windows:  make(map[string]*window),
+	}
+}
+
+// Enrich drops or aggregates rapid_wind points, leaving others untouched
+func (e *Enricher) Enrich(points []*influx.Data) []*influx.Data {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	kept := points[:0]
+	var aggregates []*influx.Data
+	for _, m := range points {
+		switch {
+		case m.ReportType != ReportType:
+			kept = append(kept, m)
+		case e.interval > 0:
+			if a := e.add(m); a != nil {
+				aggregates = append(aggregates, a)
+			}
+		default:
+			station := m.Tags["station"]
+			if e.counts[station]%e.every == 0 {
+				kept = append(kept, m)
+			}
+			e.counts[station]++
+		}
+	}
+	return append(kept, aggregates...)
+}
+
+// add accumulates m into the window of its station, returning the
+// aggregate of the previous window when m starts a new one
+func (e *Enricher) add(m *influx.Data) *influx.Data {
+	speed, ok := m.Float("rapid_wind_speed")
+	if !ok {
+		return nil
+	}
+	direction, _ := m.Float("rapid_wind_direction")
+
+	station := m.Tags["station"]
+	start := m.Timestamp - m.Timestamp%e.interval
+
+	var done *influx.Data
+	w, ok := e.windows[station]
+	if ok && start < w.start {
+		// Late report of an interval already written
+		return nil
+	}
+	if ok && start > w.start {
+		done = w.aggregate()
+		ok = false
+	}
+	if !ok {
+		w = &window{start: start, template: m}
+		e.windows[station] = w
+	}
+
+	if w.count == 0 || speed < w.min {
+		w.min = speed
+	}
+	if w.count == 0 || speed > w.max {
+		w.max = speed
+		w.gustDirection = direction
+	}
+	w.sum += speed
+	w.count++
+	if speed > 0 {
+		rad := direction * math.Pi / 180
+		w.sinSum += speed * math.Sin(rad)
+		w.cosSum += speed * math.Cos(rad)
+		w.directional = true
+	}
+	return done
+}
+
+// aggregate builds the point of w
+func (w *window) aggregate() *influx.Data {
+	m := influx.New()
+	m.Name = w.template.Name
+	m.ReportType = ReportType
+	m.Bucket = w.template.Bucket
+	m.Timestamp = w.start
+	m.Tags = maps.Clone(w.template.Tags)
+	m.Fields = influx.Fields{
+		"rapid_wind_speed_min":      influx.Round(w.min, 2),
+		"rapid_wind_speed_avg":      influx.Round(w.sum/float64(w.count), 2),
+		"rapid_wind_speed_max":      influx.Round(w.max, 2),
+		"rapid_wind_gust_direction": int64(math.Round(w.gustDirection)),
+		"rapid_wind_samples":        int64(w.count),
+	}
+	if w.directional {
+		// Calm readings carry no direction
+		deg := math.Atan2(w.sinSum, w.cosSum) * 180 / math.Pi
+		if deg < 0 {
+			deg += 360
+		}
+		m.Fields["rapid_wind_direction"] = int64(math.Round(deg)) % 360
+	}
+	return m
+}
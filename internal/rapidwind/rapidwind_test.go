@@ -0,0 +1,85 @@
+package rapidwind
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func rapidWind(station string, timestamp int64, speed float64, direction int64) *influx.Data {
+	m := influx.New()
+	m.Name = "weather"
+	m.ReportType = ReportType
+	m.Bucket = "rapid_wind"
+	m.Timestamp = timestamp
+	m.Tags["station"] = station
+	m.Fields["rapid_wind_speed"] = speed
+	m.Fields["rapid_wind_direction"] = direction
+	return m
+}
+
+func TestEnricherEvery(t *testing.T) {
+	e := NewEnricher(&config.Config{Rapid_Wind_Every: 3})
+
+	obs := influx.New()
+	obs.ReportType = "obs_st"
+	var kept int
+	for i := int64(0); i < 6; i++ {
+		got := e.Enrich([]*influx.Data{rapidWind("ST-1", i*3, 1.0, 90), obs})
+		kept += len(got) - 1
+		if got[len(got)-1] != obs {
+			t.Fatal("Expected other report types to pass")
+		}
+	}
+	if kept != 2 {
+		t.Errorf("Expected 2 of 6 reports forwarded, got %d", kept)
+	}
+}
+
+func TestEnricherInterval(t *testing.T) {
+	e := NewEnricher(&config.Config{Rapid_Wind_Interval: time.Minute})
+
+	for _, m := range []*influx.Data{
+		rapidWind("ST-1", 600, 2.0, 350),
+		rapidWind("ST-1", 603, 6.0, 10),
+		rapidWind("ST-2", 603, 1.0, 180),
+		rapidWind("ST-1", 606, 1.0, 20),
+		rapidWind("ST-1", 609, 0.0, 0),
+	} {
+		if got := e.Enrich([]*influx.Data{m}); len(got) != 0 {
+			t.Fatalf("Expected reports to be held within the interval, got %d points", len(got))
+		}
+	}
+
+	got := e.Enrich([]*influx.Data{rapidWind("ST-1", 660, 3.0, 90)})
+	if len(got) != 1 {
+		t.Fatalf("Expected one aggregate, got %d points", len(got))
+	}
+	a := got[0]
+	if a.Name != "weather" || a.Bucket != "rapid_wind" || a.Timestamp != 600 || a.Tags["station"] != "ST-1" {
+		t.Errorf("Unexpected aggregate point %+v", a)
+	}
+	want := influx.Fields{
+		"rapid_wind_speed_min":      0.0,
+		"rapid_wind_speed_avg":      2.25,
+		"rapid_wind_speed_max":      6.0,
+		"rapid_wind_gust_direction": int64(10),
+		"rapid_wind_direction":      int64(7),
+		"rapid_wind_samples":        int64(4),
+	}
+	for field, value := range want {
+		if a.Fields[field] != value {
+			t.Errorf("%s = %v, want %v", field, a.Fields[field], value)
+		}
+	}
+	if _, ok := a.Fields["rapid_wind_speed"]; ok {
+		t.Error("Aggregate should not carry the raw speed")
+	}
+
+	// Late reports of a written interval are dropped
+	if got := e.Enrich([]*influx.Data{rapidWind("ST-1", 630, 9.0, 0)}); len(got) != 0 {
+		t.Errorf("Expected late report to be dropped, got %d points", len(got))
+	}
+}
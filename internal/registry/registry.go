@@ -0,0 +1,201 @@
+// Package registry remembers every device the collector has heard from
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+// FileName is the name of the registry file in the state directory
+const FileName = "stations.json"
+
+// SaveInterval limits how often last-seen updates are written to disk;
+// new devices and changed firmware or hubs are saved immediately
+const SaveInterval = time.Minute
+
+// Kinds of devices
+const (
+	KindHub    = "hub"
+	KindDevice = "device"
+)
+
+// Device is what is known about one serial number
+type Device struct {
+	Serial    string    `json:"serial"`
+	Kind      string    `json:"kind"`
+	Hub       string    `json:"hub,omitempty"`
+	Firmware  string    `json:"firmware,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// packet holds the fields of a broadcast that identify its sender
+type packet struct {
+	Type     string          `json:"type"`
+	Serial   string          `json:"serial_number"`
+	Hub      string          `json:"hub_sn"`
+	Firmware json.RawMessage `json:"firmware_revision"`
+}
+
+// Registry records devices from the packets it observes and optionally
+// persists them to a JSON file
+type Registry struct {
+	path   string
+	logger *logger.AppLogger
+	now    func() time.Time
+
+	mu      sync.RWMutex
+	devices map[string]*Device
+	dirty   bool
+	saved   time.Time
+}
+
+// New creates a Registry stored at path, loading the devices already in it.
+// An empty path keeps the registry in memory only.
+func New(path string, appLogger *logger.AppLogger) (*Registry, error) {
+	r := &Registry{
+		path:    path,
+		logger:  appLogger,
+		now:     time.Now,
+		devices: make(map[string]*Device),
+	}
+	if path == "" {
+		return r, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading station registry: %w", err)
+	}
+	var devices []*Device
+	if err := json.Unmarshal(b, &devices); err != nil {
+		return nil, fmt.Errorf("decoding station registry %s: %w", path, err)
+	}
+	for _, d := range devices {
+		r.devices[d.Serial] = d
+	}
+	return r, nil
+}
+
+// ObservePacket records the sender of a raw broadcast. Packets that cannot
+// be decoded are ignored; the parser reports them.
+func (r *Registry) ObservePacket(b []byte) {
+	var p packet
+	if err := json.Unmarshal(b, &p); err != nil || p.Serial == "" {
+		return
+	}
+	kind := KindDevice
+	if p.Type == "hub_status" {
+		kind = KindHub
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now().UTC()
+	d, ok := r.devices[p.Serial]
+	if !ok {
+		d = &Device{Serial: p.Serial, Kind: kind, FirstSeen: now}
+		r.devices[p.Serial] = d
+		r.logger.Info("Discovered new device",
+			slog.String("serial", p.Serial),
+			slog.String("kind", kind),
+			slog.String("hub", p.Hub))
+	}
+	changed := !ok
+	if p.Hub != "" && p.Hub != d.Hub {
+		d.Hub = p.Hub
+		changed = true
+	}
+	if firmware := strings.Trim(string(p.Firmware), `"`); firmware != "" && firmware != "null" && firmware != d.Firmware {
+		d.Firmware = firmware
+		changed = true
+	}
+	d.LastSeen = now
+	r.dirty = true
+
+	if changed || now.Sub(r.saved) >= SaveInterval {
+		r.save()
+	}
+}
+
+// Flush writes pending changes to disk
+func (r *Registry) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.dirty {
+		r.save()
+	}
+}
+
+// save writes the registry atomically. The caller must hold r.mu.
+func (r *Registry) save() {
+	if r.path == "" {
+		return
+	}
+	b, err := json.MarshalIndent(r.list(), "", "  ")
+	if err == nil {
+		err = writeFile(r.path, b)
+	}
+	if err != nil {
+		r.logger.Error("Failed to save station registry", slog.String("error", err.Error()))
+		return
+	}
+	r.dirty = false
+	r.saved = r.now()
+}
+
+// writeFile replaces path with b without leaving a partial file behind
+func writeFile(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// list returns copies of all devices sorted by serial. The caller must hold
+// r.mu.
+func (r *Registry) list() []Device {
+	devices := make([]Device, 0, len(r.devices))
+	for _, serial := range slices.Sorted(maps.Keys(r.devices)) {
+		devices = append(devices, *r.devices[serial])
+	}
+	return devices
+}
+
+// Devices returns all devices sorted by serial
+func (r *Registry) Devices() []Device {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.list()
+}
+
+// Device returns the device with serial
+func (r *Registry) Device(serial string) (Device, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.devices[serial]
+	if !ok {
+		return Device{}, false
+	}
+	return *d, true
+}
@@ -0,0 +1,72 @@
+package registry
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+func newRegistry(t *testing.T, path string, now *time.Time) *Registry {
+	t.Helper()
+	r, err := New(path, logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	r.now = func() time.Time { return *now }
+	return r
+}
+
+func TestRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", FileName)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := newRegistry(t, path, &now)
+
+	r.ObservePacket([]byte(`{"serial_number":"HB-1","type":"hub_status","firmware_revision":"177"}`))
+	r.ObservePacket([]byte(`{"serial_number":"ST-1","hub_sn":"HB-1","type":"obs_st","obs":[[1]]}`))
+	now = now.Add(time.Second)
+	r.ObservePacket([]byte(`{"serial_number":"ST-1","hub_sn":"HB-1","type":"device_status","firmware_revision":171}`))
+	r.ObservePacket([]byte(`not json`))
+
+	devices := r.Devices()
+	if len(devices) != 2 {
+		t.Fatalf("Expected 2 devices, got %v", devices)
+	}
+	hub, station := devices[0], devices[1]
+	if hub.Serial != "HB-1" || hub.Kind != KindHub || hub.Firmware != "177" {
+		t.Errorf("Unexpected hub %+v", hub)
+	}
+	if station.Kind != KindDevice || station.Hub != "HB-1" || station.Firmware != "171" {
+		t.Errorf("Unexpected station %+v", station)
+	}
+	if !station.FirstSeen.Equal(now.Add(-time.Second)) || !station.LastSeen.Equal(now) {
+		t.Errorf("Unexpected first/last seen %v/%v", station.FirstSeen, station.LastSeen)
+	}
+
+	// The firmware change was saved immediately, so a new registry sees it
+	reloaded := newRegistry(t, path, &now)
+	d, ok := reloaded.Device("ST-1")
+	if !ok || d.Firmware != "171" || !d.LastSeen.Equal(now) {
+		t.Errorf("Expected saved station, got %+v", d)
+	}
+}
+
+func TestRegistryFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := newRegistry(t, path, &now)
+
+	r.ObservePacket([]byte(`{"serial_number":"ST-1","hub_sn":"HB-1","type":"rapid_wind"}`))
+	now = now.Add(10 * time.Second)
+	r.ObservePacket([]byte(`{"serial_number":"ST-1","hub_sn":"HB-1","type":"rapid_wind"}`))
+
+	if d, _ := newRegistry(t, path, &now).Device("ST-1"); d.LastSeen.Equal(now) {
+		t.Error("Expected last seen update to wait for the save interval")
+	}
+	r.Flush()
+	if d, _ := newRegistry(t, path, &now).Device("ST-1"); !d.LastSeen.Equal(now) {
+		t.Errorf("Expected flushed last seen %v, got %v", now, d.LastSeen)
+	}
+}
@@ -0,0 +1,104 @@
+// Package relay re-broadcasts raw Tempest datagrams to other destinations so
+// consumers on different networks still receive the native UDP feed.
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+)
+
+// target is one resolved relay rule
+type target struct {
+	addr  *net.UDPAddr
+	types []string
+}
+
+// matches reports whether a datagram of reportType is forwarded to t
+func (t target) matches(reportType string) bool {
+	if len(t.types) == 0 {
+		return true
+	}
+	for _, pattern := range t.types {
+		if ok, _ := path.Match(pattern, reportType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Relay forwards datagrams unchanged to the destinations of matching rules
+type Relay struct {
+	conn     *net.UDPConn
+	targets  []target
+	filtered bool
+}
+
+// New resolves the rule destinations (host:port, unicast or broadcast) and
+// opens the socket used to send to them
+func New(rules []config.RelayRule) (*Relay, error) {
+	r := &Relay{}
+	for _, rule := range rules {
+		addr, err := net.ResolveUDPAddr("udp", rule.Destination)
+		if err != nil {
+			return nil, fmt.Errorf("resolving relay destination %q: %w", rule.Destination, err)
+		}
+		r.targets = append(r.targets, target{addr: addr, types: rule.Types})
+		if len(rule.Types) > 0 {
+			r.filtered = true
+		}
+	}
+
+	// Go enables SO_BROADCAST on UDP sockets, so broadcast targets work too
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, fmt.Errorf("opening relay socket: %w", err)
+	}
+	r.conn = conn
+	return r, nil
+}
+
+// Destinations returns the resolved destination addresses
+func (r *Relay) Destinations() []*net.UDPAddr {
+	addrs := make([]*net.UDPAddr, len(r.targets))
+	for i, t := range r.targets {
+		addrs[i] = t.addr
+	}
+	return addrs
+}
+
+// Forward sends data to every destination whose rule matches its report
+// type. A failing destination does not prevent delivery to the others; all
+// failures are returned joined.
+func (r *Relay) Forward(data []byte) error {
+	var reportType string
+	if r.filtered {
+		// Datagrams that are not JSON only reach unfiltered destinations
+		var header struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(data, &header) == nil {
+			reportType = header.Type
+		}
+	}
+
+	var errs []error
+	for _, t := range r.targets {
+		if !t.matches(reportType) {
+			continue
+		}
+		if _, err := r.conn.WriteToUDP(data, t.addr); err != nil {
+			errs = append(errs, fmt.Errorf("relay to %s: %w", t.addr, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the relay socket
+func (r *Relay) Close() error {
+	return r.conn.Close()
+}
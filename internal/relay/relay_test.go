@@ -0,0 +1,119 @@
+package relay
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+)
+
+func listen(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func receive(t *testing.T, conn *net.UDPConn) []byte {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	b := make([]byte, 1024)
+	n, _, err := conn.ReadFromUDP(b)
+	if err != nil {
+		t.Fatalf("No datagram received: %v", err)
+	}
+	return b[:n]
+}
+
+func TestForward(t *testing.T) {
+	a, b := listen(t), listen(t)
+
+	r, err := New([]config.RelayRule{
+		{Destination: a.LocalAddr().String()},
+		{Destination: b.LocalAddr().String()},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer r.Close()
+
+	packet := []byte(`{"type":"rapid_wind","ob":[1640995200,5.5,270]}`)
+	if err := r.Forward(packet); err != nil {
+		t.Fatalf("Forward() error = %v", err)
+	}
+
+	for _, conn := range []*net.UDPConn{a, b} {
+		if got := receive(t, conn); !bytes.Equal(got, packet) {
+			t.Errorf("Received %q, want %q", got, packet)
+		}
+	}
+}
+
+func TestNewInvalidDestination(t *testing.T) {
+	if _, err := New([]config.RelayRule{{Destination: "no-port"}}); err == nil {
+		t.Error("Expected error for destination without port")
+	}
+}
+
+func TestForwardFiltered(t *testing.T) {
+	remote, local := listen(t), listen(t)
+
+	r, err := New([]config.RelayRule{
+		{Destination: remote.LocalAddr().String(), Types: []string{"obs_st", "evt_*"}},
+		{Destination: local.LocalAddr().String()},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer r.Close()
+
+	packets := [][]byte{
+		[]byte(`{"type":"rapid_wind","ob":[1640995200,5.5,270]}`),
+		[]byte(`{"type":"evt_strike","evt":[1640995200,12,3000]}`),
+		[]byte(`not json`),
+		[]byte(`{"type":"obs_st","obs":[[1640995200]]}`),
+	}
+	for _, p := range packets {
+		if err := r.Forward(p); err != nil {
+			t.Fatalf("Forward() error = %v", err)
+		}
+	}
+
+	for _, want := range packets {
+		if got := receive(t, local); !bytes.Equal(got, want) {
+			t.Errorf("Local received %q, want %q", got, want)
+		}
+	}
+	for _, want := range [][]byte{packets[1], packets[3]} {
+		if got := receive(t, remote); !bytes.Equal(got, want) {
+			t.Errorf("Remote received %q, want %q", got, want)
+		}
+	}
+}
+
+func TestTargetMatches(t *testing.T) {
+	tests := []struct {
+		types      []string
+		reportType string
+		want       bool
+	}{
+		{nil, "rapid_wind", true},
+		{nil, "", true},
+		{[]string{"obs_st"}, "obs_st", true},
+		{[]string{"obs_st"}, "obs_sky", false},
+		{[]string{"evt_*"}, "evt_precip", true},
+		{[]string{"evt_*"}, "", false},
+	}
+
+	for _, tt := range tests {
+		got := target{types: tt.types}.matches(tt.reportType)
+		if got != tt.want {
+			t.Errorf("matches(%v, %q) = %v, want %v", tt.types, tt.reportType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Package retention removes old files from the directories the collector
+// writes to, so that long-running installs do not fill their disks
+package retention
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+// Dirs returns the directories managed for cfg
+func Dirs(cfg *config.Config) []string {
+	var dirs []string
+	for _, dir := range []string{cfg.Capture_Dir, cfg.Quarantine_Dir} {
+		if dir != "" && !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// Enabled reports whether cfg sets a limit and a directory to apply it to
+func Enabled(cfg *config.Config) bool {
+	return (cfg.Retention_Max_Age > 0 || cfg.Retention_Max_Size_MB > 0) && len(Dirs(cfg)) > 0
+}
+
+// Result counts the files removed by a cleanup
+type Result struct {
+	Files int
+	Bytes int64
+}
+
+// file is a regular file in a managed directory
+type file struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// Manager applies Retention_Max_Age and Retention_Max_Size_MB to every
+// directory of Dirs. Each directory is limited on its own: files older
+// than the maximum age are removed first, then the oldest files until the
+// directory is within the maximum size. Only regular files directly in a
+// directory are considered.
+type Manager struct {
+	cfg    *config.Config
+	logger *logger.AppLogger
+	now    func() time.Time
+}
+
+// New creates a Manager
+func New(cfg *config.Config, appLogger *logger.AppLogger) *Manager {
+	return &Manager{cfg: cfg, logger: appLogger, now: time.Now}
+}
+
+// Run cleans up immediately and then every Retention_Interval until ctx is
+// cancelled
+func (m *Manager) Run(ctx context.Context) {
+	m.cleanupAll()
+	ticker := time.NewTicker(m.cfg.Retention_Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.cleanupAll()
+		}
+	}
+}
+
+// cleanupAll cleans up every directory and logs what was removed
+func (m *Manager) cleanupAll() {
+	for _, dir := range Dirs(m.cfg) {
+		result, err := m.Cleanup(dir)
+		if err != nil {
+			m.logger.Warn("Failed to clean up directory", slog.String("dir", dir), slog.String("error", err.Error()))
+		}
+		if result.Files > 0 {
+			m.logger.Info("Removed old files",
+				slog.String("dir", dir),
+				slog.Int("files", result.Files),
+				slog.Int64("bytes", result.Bytes))
+		}
+	}
+}
+
+// Cleanup applies the limits to dir
+func (m *Manager) Cleanup(dir string) (Result, error) {
+	var result Result
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	var files []file
+	var total int64
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, file{path: filepath.Join(dir, e.Name()), size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+	slices.SortFunc(files, func(a, b file) int { return a.modTime.Compare(b.modTime) })
+
+	maxSize := int64(m.cfg.Retention_Max_Size_MB) << 20
+	cutoff := m.now().Add(-m.cfg.Retention_Max_Age)
+	var errs []error
+	for _, f := range files {
+		expired := m.cfg.Retention_Max_Age > 0 && f.modTime.Before(cutoff)
+		oversize := maxSize > 0 && total > maxSize
+		if !expired && !oversize {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		total -= f.size
+		result.Files++
+		result.Bytes += f.size
+	}
+	return result, errors.Join(errs...)
+}
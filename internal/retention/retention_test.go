@@ -0,0 +1,94 @@
+package retention
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+var now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// writeFile creates a file of size bytes last modified age before now
+func writeFile(t *testing.T, dir, name string, size int, age time.Duration) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestCleanupMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	old := writeFile(t, dir, "old.json", 10, 48*time.Hour)
+	recent := writeFile(t, dir, "recent.json", 10, time.Hour)
+	os.Mkdir(filepath.Join(dir, "sub"), 0o755)
+
+	m := New(&config.Config{Retention_Max_Age: 24 * time.Hour}, logger.New(&config.Config{}))
+	m.now = func() time.Time { return now }
+	result, err := m.Cleanup(dir)
+	if err != nil {
+		t.Fatalf("Cleanup() error = %v", err)
+	}
+	if result.Files != 1 || result.Bytes != 10 {
+		t.Errorf("Cleanup() = %+v, want one file of 10 bytes", result)
+	}
+	if exists(old) || !exists(recent) || !exists(filepath.Join(dir, "sub")) {
+		t.Error("Expected only the expired file to be removed")
+	}
+}
+
+func TestCleanupMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	oldest := writeFile(t, dir, "a.json", 600<<10, 3*time.Hour)
+	middle := writeFile(t, dir, "b.json", 600<<10, 2*time.Hour)
+	newest := writeFile(t, dir, "c.json", 300<<10, time.Hour)
+
+	m := New(&config.Config{Retention_Max_Size_MB: 1}, logger.New(&config.Config{}))
+	m.now = func() time.Time { return now }
+	result, err := m.Cleanup(dir)
+	if err != nil {
+		t.Fatalf("Cleanup() error = %v", err)
+	}
+	if result.Files != 1 {
+		t.Errorf("Cleanup() removed %d files, want 1", result.Files)
+	}
+	if exists(oldest) || !exists(middle) || !exists(newest) {
+		t.Error("Expected the oldest file to be removed until the directory fits")
+	}
+}
+
+func TestCleanupMissingDir(t *testing.T) {
+	m := New(&config.Config{Retention_Max_Age: time.Hour}, logger.New(&config.Config{}))
+	if _, err := m.Cleanup(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("Cleanup() error = %v for a missing directory", err)
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	for _, tt := range []struct {
+		cfg  config.Config
+		want bool
+	}{
+		{config.Config{Capture_Dir: "/c"}, false},
+		{config.Config{Retention_Max_Age: time.Hour}, false},
+		{config.Config{Capture_Dir: "/c", Retention_Max_Age: time.Hour}, true},
+		{config.Config{Quarantine_Dir: "/q", Retention_Max_Size_MB: 10}, true},
+	} {
+		if got := Enabled(&tt.cfg); got != tt.want {
+			t.Errorf("Enabled(%+v) = %v, want %v", tt.cfg, got, tt.want)
+		}
+	}
+}
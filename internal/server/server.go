@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/auth"
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/debugvars"
+	"github.com/jacaudi/tempest-influxdb/internal/health"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// shutdownTimeout bounds how long in-flight requests may take on shutdown
+const shutdownTimeout = 5 * time.Second
+
+// Server exposes the collector's own HTTP endpoints
+type Server struct {
+	config *config.Config
+	logger *logger.AppLogger
+	mux    *http.ServeMux
+}
+
+// New creates a Server with the metrics endpoint, and the expvar endpoint
+// when enabled, registered
+func New(cfg *config.Config, appLogger *logger.AppLogger) *Server {
+	s := &Server{
+		config: cfg,
+		logger: appLogger,
+		mux:    http.NewServeMux(),
+	}
+	s.mux.Handle("/metrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+	if cfg.HTTP_Expvar {
+		s.mux.Handle(debugvars.Path, expvar.Handler())
+	}
+	return s
+}
+
+// Handle registers handler for pattern
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Handler returns the root handler of the server, which applies the CORS
+// policy and requires authentication when they are configured. Health
+// probes are served without authentication.
+func (s *Server) Handler() http.Handler {
+	h := cors(s.config.HTTP_CORS_Origins, auth.Middleware(s.config, s.mux))
+	if !s.config.HTTP_Health {
+		return h
+	}
+	root := http.NewServeMux()
+	health.Register(root, s.config)
+	root.Handle("/", h)
+	return root
+}
+
+// Run serves HTTP, or HTTPS when TLS is configured, on the configured
+// address until ctx is cancelled
+func (s *Server) Run(ctx context.Context) error {
+	lis, err := net.Listen("tcp", s.config.HTTP_Listen_Address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, lis)
+}
+
+// Serve serves HTTP, or HTTPS when TLS is configured, on lis until ctx is
+// cancelled
+func (s *Server) Serve(ctx context.Context, lis net.Listener) error {
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		lis.Close()
+		return fmt.Errorf("configuring TLS: %w", err)
+	}
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         tlsConfig,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		s.logger.Info("HTTP server started", "address", lis.Addr().String(), "tls", tlsConfig != nil)
+		if tlsConfig != nil {
+			errCh <- srv.ServeTLS(lis, "", "")
+			return
+		}
+		errCh <- srv.Serve(lis)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/metrics"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	metrics.InfluxWriteDuration.WithLabelValues("test").Observe(0.01)
+	metrics.InfluxPayloadBytes.WithLabelValues("test").Observe(512)
+
+	s := New(&config.Config{}, logger.New(&config.Config{}))
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, name := range []string{
+		"tempest_influx_influx_write_duration_seconds_bucket",
+		"tempest_influx_influx_write_payload_bytes_bucket",
+	} {
+		if !strings.Contains(body, name) {
+			t.Errorf("Expected %s in metrics output", name)
+		}
+	}
+}
+
+func TestExpvarEndpoint(t *testing.T) {
+	s := New(&config.Config{}, logger.New(&config.Config{}))
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected /debug/vars to be disabled by default, got %d", rec.Code)
+	}
+
+	s = New(&config.Config{HTTP_Expvar: true}, logger.New(&config.Config{}))
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"queued_packets"`) {
+		t.Errorf("Expected expvar output, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	cfg := &config.Config{HTTP_Health: true, API_Token: "secret"}
+	s := New(cfg, logger.New(&config.Config{}))
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected /healthz without authentication to succeed, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected /metrics to require authentication, got %d", rec.Code)
+	}
+
+	s = New(&config.Config{}, logger.New(&config.Config{}))
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected /healthz to be disabled by default, got %d", rec.Code)
+	}
+}
+
+func TestServerRunShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	s := New(&config.Config{HTTP_Listen_Address: addr}, logger.New(&config.Config{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Run(ctx)
+	}()
+
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		resp, err = http.Get("http://" + addr + "/metrics")
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("GET /metrics error = %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Server did not shut down")
+	}
+}
+
+func TestServerRunSelfSignedTLS(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	s := New(&config.Config{HTTP_Listen_Address: addr, HTTP_TLS_Self_Signed: true}, logger.New(&config.Config{}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		resp, err = client.Get("https://" + addr + "/metrics")
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("GET /metrics over TLS error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		t.Fatal("Expected a TLS connection")
+	}
+	if cert := resp.TLS.PeerCertificates[0]; cert.VerifyHostname("localhost") != nil {
+		t.Errorf("Expected certificate valid for localhost, got %v", cert.DNSNames)
+	}
+}
+
+func TestServerRunInvalidCertificate(t *testing.T) {
+	s := New(&config.Config{HTTP_Listen_Address: "127.0.0.1:0", HTTP_TLS_Cert: "missing.pem", HTTP_TLS_Key: "missing.key"}, logger.New(&config.Config{}))
+	if err := s.Run(context.Background()); err == nil {
+		t.Error("Expected error for missing certificate files")
+	}
+}
+
+func TestCORS(t *testing.T) {
+	s := New(&config.Config{
+		HTTP_CORS_Origins: []string{"http://dashboard.local"},
+		API_Token:         "secret",
+	}, logger.New(&config.Config{}))
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		token       bool
+		wantStatus  int
+		wantAllowed string
+	}{
+		{"preflight without credentials", http.MethodOptions, "http://dashboard.local", true, false, http.StatusNoContent, "http://dashboard.local"},
+		{"request from allowed origin", http.MethodGet, "http://dashboard.local", false, true, http.StatusOK, "http://dashboard.local"},
+		{"request from other origin", http.MethodGet, "http://evil.example", false, true, http.StatusOK, ""},
+		{"request without credentials", http.MethodGet, "http://dashboard.local", false, false, http.StatusUnauthorized, "http://dashboard.local"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/metrics", nil)
+			req.Header.Set("Origin", tt.origin)
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			}
+			if tt.token {
+				req.Header.Set("Authorization", "Bearer secret")
+			}
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowed {
+				t.Errorf("Expected allowed origin %q, got %q", tt.wantAllowed, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package smoothing
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+// RawPressureField keeps the unfiltered pressure when the median filter
+// replaces p
+const RawPressureField = "p_raw"
+
+// window holds the most recent readings of one station
+type window struct {
+	Values    []float64 `json:"values"`
+	Timestamp int64     `json:"timestamp"`
+}
+
+// PressureFilter replaces the pressure of each point with the median of the
+// station's last Size readings, removing single-sample spikes while following
+// real changes with a lag of Size/2 readings. The unfiltered value is kept in
+// p_raw.
+type PressureFilter struct {
+	Size int
+
+	mu      sync.Mutex
+	windows map[string]*window
+}
+
+// NewPressureFilter creates a PressureFilter over size readings
+func NewPressureFilter(size int) *PressureFilter {
+	return &PressureFilter{
+		Size:    size,
+		windows: make(map[string]*window),
+	}
+}
+
+// Enrich filters the pressure of every point that has one
+func (f *PressureFilter) Enrich(points []*influx.Data) []*influx.Data {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, m := range points {
+		value, ok := m.Float("p")
+		if !ok {
+			continue
+		}
+		m.Fields[RawPressureField] = m.Fields["p"]
+		m.Fields["p"] = influx.Round(f.median(m.Tags["station"], value, m.Timestamp), 2)
+	}
+	return points
+}
+
+// median adds value to the station's window and returns the window's
+// median. Late readings are not added, and a gap longer than MaxGap empties
+// the window.
+func (f *PressureFilter) median(station string, value float64, timestamp int64) float64 {
+	w, ok := f.windows[station]
+	if !ok || timestamp-w.Timestamp > MaxGap {
+		w = &window{}
+		f.windows[station] = w
+	}
+	if timestamp >= w.Timestamp {
+		w.Values = append(w.Values, value)
+		if len(w.Values) > f.Size {
+			w.Values = w.Values[1:]
+		}
+		w.Timestamp = timestamp
+	}
+
+	sorted := slices.Clone(w.Values)
+	slices.Sort(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// StateKey implements state.Persistent
+func (f *PressureFilter) StateKey() string {
+	return "pressure_filter"
+}
+
+// MarshalState implements state.Persistent
+func (f *PressureFilter) MarshalState() (json.RawMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return json.Marshal(f.windows)
+}
+
+// UnmarshalState implements state.Persistent. Windows saved with a larger
+// Size are trimmed to the newest readings.
+func (f *PressureFilter) UnmarshalState(b json.RawMessage) error {
+	var windows map[string]*window
+	if err := json.Unmarshal(b, &windows); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for station, w := range windows {
+		if len(w.Values) > f.Size {
+			w.Values = w.Values[len(w.Values)-f.Size:]
+		}
+		f.windows[station] = w
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package smoothing
+
+import (
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func pressure(station string, timestamp int64, p float64) *influx.Data {
+	m := influx.New()
+	m.Tags["station"] = station
+	m.Timestamp = timestamp
+	m.Fields["p"] = p
+	return m
+}
+
+func TestPressureFilter(t *testing.T) {
+	f := NewPressureFilter(3)
+
+	tests := []struct {
+		name string
+		m    *influx.Data
+		want float64
+	}{
+		{"single reading", pressure("ST-1", 60, 1010.0), 1010.0},
+		{"two readings average", pressure("ST-1", 120, 1011.0), 1010.5},
+		{"spike is removed", pressure("ST-1", 180, 1019.0), 1011.0},
+		{"window slides", pressure("ST-1", 240, 1011.2), 1011.2},
+		{"other station is independent", pressure("ST-2", 240, 990.0), 990.0},
+		{"gap empties the window", pressure("ST-1", 240+MaxGap+1, 1005.0), 1005.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := tt.m.Fields["p"]
+			f.Enrich([]*influx.Data{tt.m})
+			if got := tt.m.Fields["p"]; got != tt.want {
+				t.Errorf("Expected filtered pressure %v, got %v", tt.want, got)
+			}
+			if got := tt.m.Fields[RawPressureField]; got != raw {
+				t.Errorf("Expected raw pressure %v, got %v", raw, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Package smoothing reduces sensor noise: exponentially weighted moving
+// averages of wind fields and a median filter for pressure
+package smoothing
+
+import (
+	"encoding/json"
+	"maps"
+	"sync"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+// Suffix is appended to the name of a field to name its smoothed value
+const Suffix = "_smoothed"
+
+// Fields are the fields that are smoothed
+var Fields = []string{"wind_avg", "rapid_wind_speed"}
+
+// MaxGap is the longest pause between readings that keeps the average; after
+// a longer gap it restarts from the next reading instead of blending in
+// stale data
+const MaxGap = 10 * 60 // seconds
+
+// average is the running state of one field of one station
+type average struct {
+	Value     float64 `json:"value"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// Enricher adds a <field>_smoothed field next to every smoothed field. Each
+// reading moves the average by Alpha of its distance to the reading, so
+// smaller values smooth more.
+type Enricher struct {
+	Alpha float64
+
+	mu       sync.Mutex
+	averages map[string]*average
+}
+
+// NewEnricher creates an Enricher with smoothing factor alpha in (0, 1]
+func NewEnricher(alpha float64) *Enricher {
+	return &Enricher{
+		Alpha:    alpha,
+		averages: make(map[string]*average),
+	}
+}
+
+// Enrich adds the smoothed fields, leaving the raw values untouched
+func (e *Enricher) Enrich(points []*influx.Data) []*influx.Data {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, m := range points {
+		for _, field := range Fields {
+			value, ok := m.Float(field)
+			if !ok {
+				continue
+			}
+			smoothed := e.update(m.Tags["station"]+"/"+field, value, m.Timestamp)
+			m.Fields[field+Suffix] = influx.Round(smoothed, 2)
+		}
+	}
+	return points
+}
+
+// update blends value into the average stored under key and returns the
+// new average. Readings older than the average are ignored.
+func (e *Enricher) update(key string, value float64, timestamp int64) float64 {
+	avg, ok := e.averages[key]
+	if !ok || timestamp-avg.Timestamp > MaxGap {
+		e.averages[key] = &average{Value: value, Timestamp: timestamp}
+		return value
+	}
+	if timestamp < avg.Timestamp {
+		return avg.Value
+	}
+	avg.Value += e.Alpha * (value - avg.Value)
+	avg.Timestamp = timestamp
+	return avg.Value
+}
+
+// StateKey implements state.Persistent
+func (e *Enricher) StateKey() string {
+	return "wind_smoothing"
+}
+
+// MarshalState implements state.Persistent
+func (e *Enricher) MarshalState() (json.RawMessage, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return json.Marshal(e.averages)
+}
+
+// UnmarshalState implements state.Persistent
+func (e *Enricher) UnmarshalState(b json.RawMessage) error {
+	var averages map[string]*average
+	if err := json.Unmarshal(b, &averages); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	maps.Copy(e.averages, averages)
+	return nil
+}
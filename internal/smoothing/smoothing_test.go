@@ -0,0 +1,54 @@
+package smoothing
+
+import (
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func rapidWind(station string, timestamp int64, speed float64) *influx.Data {
+	m := influx.New()
+	m.Tags["station"] = station
+	m.Timestamp = timestamp
+	m.Fields["rapid_wind_speed"] = speed
+	return m
+}
+
+func TestEnricher(t *testing.T) {
+	e := NewEnricher(0.5)
+
+	tests := []struct {
+		name string
+		m    *influx.Data
+		want float64
+	}{
+		{"first reading starts the average", rapidWind("ST-1", 100, 4.0), 4.0},
+		{"second reading moves halfway", rapidWind("ST-1", 103, 8.0), 6.0},
+		{"other station is independent", rapidWind("ST-2", 103, 1.0), 1.0},
+		{"late reading is ignored", rapidWind("ST-1", 101, 100.0), 6.0},
+		{"third reading moves halfway", rapidWind("ST-1", 106, 2.0), 4.0},
+		{"gap restarts the average", rapidWind("ST-1", 106+MaxGap+1, 9.0), 9.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := tt.m.Fields["rapid_wind_speed"]
+			e.Enrich([]*influx.Data{tt.m})
+			if got := tt.m.Fields["rapid_wind_speed_smoothed"]; got != tt.want {
+				t.Errorf("Expected smoothed value %v, got %v", tt.want, got)
+			}
+			if tt.m.Fields["rapid_wind_speed"] != raw {
+				t.Errorf("Expected raw value %v to be kept, got %v", raw, tt.m.Fields["rapid_wind_speed"])
+			}
+		})
+	}
+}
+
+func TestEnricherSkipsMissingFields(t *testing.T) {
+	m := influx.New()
+	m.Tags["station"] = "ST-1"
+	m.Fields["temp"] = 20.0
+	NewEnricher(0.3).Enrich([]*influx.Data{m})
+	if len(m.Fields) != 1 {
+		t.Errorf("Expected no smoothed fields, got %v", m.Fields)
+	}
+}
@@ -0,0 +1,57 @@
+// Package snow flags observations during which precipitation is likely to
+// be snow. The haptic rain sensor only reports rain and hail, so snow shows
+// up as one of those, or not at all when it is dry and light.
+package snow
+
+import (
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/meteo"
+)
+
+// Field is the name of the boolean field added to obs_st points
+const Field = "is_snow_likely"
+
+const (
+	// maxTemp is the air temperature in °C above which snow is not
+	// considered, however dry the air
+	maxTemp = 4.0
+
+	// maxWetBulb is the wet-bulb temperature in °C at or below which
+	// falling precipitation is most likely snow
+	maxWetBulb = 1.0
+)
+
+// Likely reports whether the precipitation of the obs_st point m is likely
+// snow: the sensor reports precipitation of any type, and the air is cold
+// and dry enough for snowflakes not to melt on the way down. ok is false
+// when temperature or dew point are missing.
+func Likely(m *influx.Data) (likely, ok bool) {
+	temp, okTemp := m.Float("temp")
+	dewPoint, okDewPoint := m.Float("dew_point")
+	if !okTemp || !okDewPoint {
+		return false, false
+	}
+	rain, _ := m.Float("precipitation")
+	kind, _ := m.Float("precipitation_type")
+	if rain <= 0 && kind == 0 {
+		return false, true
+	}
+	return temp <= maxTemp && meteo.WetBulb(temp, dewPoint) <= maxWetBulb, true
+}
+
+// Enricher adds the is_snow_likely field to obs_st points
+type Enricher struct{}
+
+// Enrich adds the is_snow_likely field to every obs_st point with a
+// temperature and dew point
+func (Enricher) Enrich(points []*influx.Data) []*influx.Data {
+	for _, m := range points {
+		if m.ReportType != "obs_st" {
+			continue
+		}
+		if likely, ok := Likely(m); ok {
+			m.Fields[Field] = likely
+		}
+	}
+	return points
+}
@@ -0,0 +1,78 @@
+package snow
+
+import (
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func TestLikely(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields influx.Fields
+		likely bool
+		ok     bool
+	}{
+		{
+			"rain below freezing",
+			influx.Fields{"temp": -2.0, "dew_point": -3.0, "precipitation": 0.05, "precipitation_type": int64(1)},
+			true, true,
+		},
+		{
+			"dry air above freezing",
+			influx.Fields{"temp": 3.0, "dew_point": -3.0, "precipitation": 0.02, "precipitation_type": int64(1)},
+			true, true,
+		},
+		{
+			"humid air above freezing",
+			influx.Fields{"temp": 3.0, "dew_point": 2.5, "precipitation": 0.02, "precipitation_type": int64(1)},
+			false, true,
+		},
+		{
+			"hail reported in the cold",
+			influx.Fields{"temp": 0.5, "dew_point": 0.0, "precipitation": 0.0, "precipitation_type": int64(2)},
+			true, true,
+		},
+		{
+			"warm and very dry",
+			influx.Fields{"temp": 6.0, "dew_point": -10.0, "precipitation": 0.02, "precipitation_type": int64(1)},
+			false, true,
+		},
+		{
+			"no precipitation",
+			influx.Fields{"temp": -5.0, "dew_point": -6.0, "precipitation": 0.0, "precipitation_type": int64(0)},
+			false, true,
+		},
+		{
+			"no dew point",
+			influx.Fields{"temp": -5.0, "precipitation": 0.05, "precipitation_type": int64(1)},
+			false, false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			likely, ok := Likely(&influx.Data{Fields: tt.fields})
+			if likely != tt.likely || ok != tt.ok {
+				t.Errorf("Likely() = %v, %v, want %v, %v", likely, ok, tt.likely, tt.ok)
+			}
+		})
+	}
+}
+
+func TestEnrich(t *testing.T) {
+	obs := influx.New()
+	obs.ReportType = "obs_st"
+	obs.Fields = influx.Fields{"temp": -1.0, "dew_point": -2.0, "precipitation": 0.01, "precipitation_type": int64(1)}
+	wind := influx.New()
+	wind.ReportType = "rapid_wind"
+	wind.Fields = influx.Fields{"wind_speed": 2.0}
+
+	Enricher{}.Enrich([]*influx.Data{obs, wind})
+	if obs.Fields[Field] != true {
+		t.Errorf("%s = %v, want true", Field, obs.Fields[Field])
+	}
+	if _, ok := wind.Fields[Field]; ok {
+		t.Errorf("Expected no %s on rapid_wind", Field)
+	}
+}
@@ -0,0 +1,114 @@
+package solar
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+// Measurement is the name of the sunrise and sunset annotation points
+const Measurement = "sun"
+
+// Enabled reports whether any station in cfg has coordinates
+func Enabled(cfg *config.Config) bool {
+	for _, station := range cfg.Stations {
+		if station.HasCoordinates() {
+			return true
+		}
+	}
+	return false
+}
+
+// Enricher adds the solar elevation and azimuth, the clear-sky irradiance and
+// an is_daytime field to obs_st points of stations with configured
+// coordinates. With the first
+// observation of each local day it also emits sunrise and sunset annotation
+// points for that day.
+type Enricher struct {
+	cfg *config.Config
+
+	mu        sync.Mutex
+	announced map[string]string
+}
+
+// NewEnricher creates an Enricher for the stations of cfg
+func NewEnricher(cfg *config.Config) *Enricher {
+	return &Enricher{
+		cfg:       cfg,
+		announced: make(map[string]string),
+	}
+}
+
+// Enrich adds solar fields and, once per station and day, annotation points
+func (e *Enricher) Enrich(points []*influx.Data) []*influx.Data {
+	for _, m := range points {
+		if m.ReportType != "obs_st" {
+			continue
+		}
+		serial := m.Tags["station"]
+		station := e.cfg.Station(serial)
+		if !station.HasCoordinates() {
+			continue
+		}
+
+		t := time.Unix(m.Timestamp, 0)
+		elevation, azimuth := Position(t, station.Latitude, station.Longitude)
+		m.Fields["solar_elevation"] = influx.Round(elevation, 2)
+		m.Fields["solar_azimuth"] = influx.Round(azimuth, 2)
+		m.Fields["is_daytime"] = elevation > horizon
+		m.Fields["clear_sky_radiation"] = influx.Round(ClearSky(elevation), 2)
+		if measured, ok := m.Float("solar_radiation"); ok {
+			if ratio, ok := ClearSkyRatio(measured, elevation); ok {
+				m.Fields["clear_sky_ratio"] = influx.Round(ratio, 3)
+			}
+		}
+
+		loc, err := e.cfg.Location(serial)
+		if err != nil {
+			loc = time.UTC
+		}
+		local := t.In(loc)
+		if e.announce(serial, local.Format(time.DateOnly)) {
+			points = append(points, annotations(m, SunTimes(local, station.Latitude, station.Longitude))...)
+		}
+	}
+	return points
+}
+
+// announce reports whether the annotations of day are still due for station
+func (e *Enricher) announce(station, day string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.announced[station] >= day {
+		return false
+	}
+	e.announced[station] = day
+	return true
+}
+
+// annotations returns the sunrise and sunset points of day for the station
+// of m. Days without sunrise or sunset have none.
+func annotations(m *influx.Data, day Day) []*influx.Data {
+	if day.PolarDay || day.PolarNight {
+		return nil
+	}
+	var points []*influx.Data
+	for _, event := range []struct {
+		name string
+		at   time.Time
+	}{{"sunrise", day.Sunrise}, {"sunset", day.Sunset}} {
+		p := influx.New()
+		p.ID = m.ID
+		p.ReportType = Measurement
+		p.Name = Measurement
+		p.Bucket = m.Bucket
+		p.Timestamp = event.at.Unix()
+		p.Tags["station"] = m.Tags["station"]
+		p.Tags["event"] = event.name
+		p.Fields["text"] = event.name + " " + event.at.Format("15:04")
+		points = append(points, p)
+	}
+	return points
+}
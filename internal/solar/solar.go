@@ -0,0 +1,157 @@
+// Package solar computes the position of the sun and the times of sunrise
+// and sunset, following the NOAA solar calculator equations, and the
+// irradiance expected under a clear sky
+package solar
+
+import (
+	"math"
+	"time"
+)
+
+// minClearSky is the smallest clear-sky irradiance in W/m² a measurement is
+// compared against; close to the horizon the ratio is dominated by noise
+const minClearSky = 10
+
+// horizon is the solar elevation at sunrise and sunset in degrees; it
+// accounts for atmospheric refraction and the radius of the solar disc
+const horizon = -0.833
+
+// Day describes sunrise and sunset on one date. At high latitudes the sun
+// may not rise or set at all, in which case Sunrise and Sunset are zero.
+type Day struct {
+	Sunrise    time.Time
+	Sunset     time.Time
+	PolarDay   bool
+	PolarNight bool
+}
+
+// sunParams holds the quantities that depend only on time
+type sunParams struct {
+	declination float64 // degrees
+	eqTime      float64 // equation of time in minutes
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
+
+// params computes the declination and equation of time at t
+func params(t time.Time) sunParams {
+	jd := float64(t.UnixNano())/float64(24*time.Hour) + 2440587.5
+	jc := (jd - 2451545) / 36525
+
+	meanLong := math.Mod(280.46646+jc*(36000.76983+jc*0.0003032), 360)
+	meanAnom := 357.52911 + jc*(35999.05029-0.0001537*jc)
+	ecc := 0.016708634 - jc*(0.000042037+0.0000001267*jc)
+	center := math.Sin(radians(meanAnom))*(1.914602-jc*(0.004817+0.000014*jc)) +
+		math.Sin(radians(2*meanAnom))*(0.019993-0.000101*jc) +
+		math.Sin(radians(3*meanAnom))*0.000289
+	omega := radians(125.04 - 1934.136*jc)
+	appLong := meanLong + center - 0.00569 - 0.00478*math.Sin(omega)
+	meanObliq := 23 + (26+(21.448-jc*(46.815+jc*(0.00059-jc*0.001813)))/60)/60
+	obliq := meanObliq + 0.00256*math.Cos(omega)
+
+	y := math.Pow(math.Tan(radians(obliq/2)), 2)
+	l, m := radians(meanLong), radians(meanAnom)
+	eqTime := 4 * degrees(y*math.Sin(2*l)-2*ecc*math.Sin(m)+4*ecc*y*math.Sin(m)*math.Cos(2*l)-
+		0.5*y*y*math.Sin(4*l)-1.25*ecc*ecc*math.Sin(2*m))
+
+	return sunParams{
+		declination: degrees(math.Asin(math.Sin(radians(obliq)) * math.Sin(radians(appLong)))),
+		eqTime:      eqTime,
+	}
+}
+
+// Position returns the elevation above the horizon and the azimuth clockwise
+// from north of the sun at t, both in degrees. The elevation is geometric,
+// without correction for atmospheric refraction.
+func Position(t time.Time, latitude, longitude float64) (elevation, azimuth float64) {
+	p := params(t)
+	utc := t.UTC()
+	minutes := float64(utc.Hour()*60+utc.Minute()) + float64(utc.Second())/60 + float64(utc.Nanosecond())/float64(time.Minute)
+	solarTime := math.Mod(minutes+p.eqTime+4*longitude, 1440)
+	if solarTime < 0 {
+		solarTime += 1440
+	}
+	hourAngle := solarTime/4 - 180
+
+	lat, decl := radians(latitude), radians(p.declination)
+	cosZenith := math.Sin(lat)*math.Sin(decl) + math.Cos(lat)*math.Cos(decl)*math.Cos(radians(hourAngle))
+	zenith := math.Acos(clamp(cosZenith))
+	elevation = 90 - degrees(zenith)
+
+	denom := math.Cos(lat) * math.Sin(zenith)
+	if denom == 0 {
+		// Sun at the zenith or observer at a pole; azimuth is undefined
+		return elevation, 180
+	}
+	az := degrees(math.Acos(clamp((math.Sin(lat)*math.Cos(zenith) - math.Sin(decl)) / denom)))
+	if hourAngle > 0 {
+		azimuth = math.Mod(az+180, 360)
+	} else {
+		azimuth = math.Mod(540-az, 360)
+	}
+	return elevation, azimuth
+}
+
+// IsDaytime reports whether the sun is above the horizon at t
+func IsDaytime(t time.Time, latitude, longitude float64) bool {
+	elevation, _ := Position(t, latitude, longitude)
+	return elevation > horizon
+}
+
+// ClearSky returns the theoretical global horizontal irradiance in W/m²
+// under a cloudless sky for a solar elevation in degrees, using the
+// Haurwitz model
+func ClearSky(elevation float64) float64 {
+	if elevation <= 0 {
+		return 0
+	}
+	cosZenith := math.Sin(radians(elevation))
+	return 1098 * cosZenith * math.Exp(-0.057/cosZenith)
+}
+
+// ClearSkyRatio returns measured divided by the clear-sky irradiance at
+// elevation. It reports false when the sun is too low for a meaningful ratio.
+func ClearSkyRatio(measured, elevation float64) (float64, bool) {
+	expected := ClearSky(elevation)
+	if expected < minClearSky {
+		return 0, false
+	}
+	return measured / expected, true
+}
+
+// SunTimes returns sunrise and sunset on the calendar date of date
+func SunTimes(date time.Time, latitude, longitude float64) Day {
+	y, m, d := date.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	// Solar noon in minutes after UTC midnight, refined once with the
+	// parameters at noon itself
+	noon := 720 - 4*longitude
+	p := params(midnight.Add(time.Duration(noon * float64(time.Minute))))
+	noon -= p.eqTime
+	p = params(midnight.Add(time.Duration(noon * float64(time.Minute))))
+
+	lat, decl := radians(latitude), radians(p.declination)
+	cosHA := math.Cos(radians(90-horizon))/(math.Cos(lat)*math.Cos(decl)) - math.Tan(lat)*math.Tan(decl)
+	switch {
+	case cosHA > 1:
+		return Day{PolarNight: true}
+	case cosHA < -1:
+		return Day{PolarDay: true}
+	}
+	ha := degrees(math.Acos(cosHA))
+
+	at := func(minutes float64) time.Time {
+		return midnight.Add(time.Duration(minutes * float64(time.Minute))).Truncate(time.Second).In(date.Location())
+	}
+	return Day{
+		Sunrise: at(noon - 4*ha),
+		Sunset:  at(noon + 4*ha),
+	}
+}
+
+// clamp limits v to the domain of acos
+func clamp(v float64) float64 {
+	return math.Max(-1, math.Min(1, v))
+}
@@ -0,0 +1,180 @@
+package solar
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+const (
+	boulderLat = 40.015
+	boulderLon = -105.2705
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func within(got, want time.Time, tolerance time.Duration) bool {
+	d := got.Sub(want)
+	return d >= -tolerance && d <= tolerance
+}
+
+func TestSunTimes(t *testing.T) {
+	denver := mustLoad(t, "America/Denver")
+	sydney := mustLoad(t, "Australia/Sydney")
+
+	tests := []struct {
+		name            string
+		date            time.Time
+		lat, lon        float64
+		sunrise, sunset time.Time
+	}{
+		{
+			"boulder solstice",
+			time.Date(2024, 6, 20, 12, 0, 0, 0, denver), boulderLat, boulderLon,
+			time.Date(2024, 6, 20, 5, 31, 0, 0, denver), time.Date(2024, 6, 20, 20, 33, 0, 0, denver),
+		},
+		{
+			"sydney summer",
+			time.Date(2024, 1, 15, 0, 30, 0, 0, sydney), -33.87, 151.21,
+			time.Date(2024, 1, 15, 6, 0, 0, 0, sydney), time.Date(2024, 1, 15, 20, 9, 0, 0, sydney),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day := SunTimes(tt.date, tt.lat, tt.lon)
+			if !within(day.Sunrise, tt.sunrise, 2*time.Minute) {
+				t.Errorf("Sunrise = %v, want about %v", day.Sunrise, tt.sunrise)
+			}
+			if !within(day.Sunset, tt.sunset, 2*time.Minute) {
+				t.Errorf("Sunset = %v, want about %v", day.Sunset, tt.sunset)
+			}
+			if day.Sunrise.Location() != tt.date.Location() {
+				t.Errorf("Expected times in %v, got %v", tt.date.Location(), day.Sunrise.Location())
+			}
+		})
+	}
+}
+
+func TestSunTimesPolar(t *testing.T) {
+	if day := SunTimes(time.Date(2024, 12, 21, 12, 0, 0, 0, time.UTC), 69.65, 18.96); !day.PolarNight {
+		t.Errorf("Expected polar night in Tromsø at the winter solstice, got %+v", day)
+	}
+	if day := SunTimes(time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC), 69.65, 18.96); !day.PolarDay {
+		t.Errorf("Expected polar day in Tromsø at the summer solstice, got %+v", day)
+	}
+}
+
+func TestPosition(t *testing.T) {
+	denver := mustLoad(t, "America/Denver")
+
+	// Solar noon at the summer solstice: 90 - latitude + 23.44
+	elevation, azimuth := Position(time.Date(2024, 6, 20, 13, 0, 0, 0, denver), boulderLat, boulderLon)
+	if math.Abs(elevation-73.4) > 0.5 || math.Abs(azimuth-180) > 5 {
+		t.Errorf("Position at noon = %.2f, %.2f; want about 73.4, 180", elevation, azimuth)
+	}
+
+	elevation, azimuth = Position(time.Date(2024, 6, 20, 8, 0, 0, 0, denver), boulderLat, boulderLon)
+	if elevation < 20 || elevation > 30 || azimuth < 70 || azimuth > 90 {
+		t.Errorf("Morning position = %.2f, %.2f; want low in the east", elevation, azimuth)
+	}
+
+	if IsDaytime(time.Date(2024, 6, 20, 2, 0, 0, 0, denver), boulderLat, boulderLon) {
+		t.Error("Expected night at 02:00")
+	}
+}
+
+func TestClearSky(t *testing.T) {
+	if got := ClearSky(-5); got != 0 {
+		t.Errorf("ClearSky below the horizon = %v, want 0", got)
+	}
+	// Haurwitz at the zenith: 1098 * exp(-0.057)
+	if got := ClearSky(90); math.Abs(got-1037.2) > 0.1 {
+		t.Errorf("ClearSky(90) = %.1f, want 1037.2", got)
+	}
+	if ClearSky(30) >= ClearSky(60) {
+		t.Error("Expected clear-sky irradiance to grow with elevation")
+	}
+
+	ratio, ok := ClearSkyRatio(ClearSky(60)/2, 60)
+	if !ok || math.Abs(ratio-0.5) > 1e-9 {
+		t.Errorf("ClearSkyRatio() = %v, %v; want 0.5, true", ratio, ok)
+	}
+	if _, ok := ClearSkyRatio(5, 0.2); ok {
+		t.Error("Expected no ratio with the sun at the horizon")
+	}
+}
+
+func TestEnricher(t *testing.T) {
+	cfg := &config.Config{
+		Stations: map[string]config.Station{
+			"ST-1": {Timezone: "America/Denver", Latitude: boulderLat, Longitude: boulderLon},
+		},
+	}
+	if !Enabled(cfg) || Enabled(&config.Config{}) {
+		t.Fatal("Expected Enabled only with station coordinates")
+	}
+	e := NewEnricher(cfg)
+
+	obs := func(station string, at time.Time) *influx.Data {
+		m := influx.New()
+		m.Name = "weather"
+		m.Bucket = "weather"
+		m.ReportType = "obs_st"
+		m.Timestamp = at.Unix()
+		m.Tags["station"] = station
+		m.Fields["temp"] = 20.0
+		m.Fields["solar_radiation"] = int64(500)
+		return m
+	}
+
+	denver := mustLoad(t, "America/Denver")
+	noon := time.Date(2024, 6, 20, 12, 0, 0, 0, denver)
+
+	points := e.Enrich([]*influx.Data{obs("ST-1", noon)})
+	if len(points) != 3 {
+		t.Fatalf("Expected observation plus sunrise and sunset, got %d points", len(points))
+	}
+	if points[0].Fields["is_daytime"] != true {
+		t.Errorf("Expected is_daytime=true at noon, got %v", points[0].Fields["is_daytime"])
+	}
+	if points[0].Fields["solar_elevation"] != 68.78 || points[0].Fields["solar_azimuth"] != 136.66 {
+		t.Errorf("Unexpected solar position elevation=%v azimuth=%v",
+			points[0].Fields["solar_elevation"], points[0].Fields["solar_azimuth"])
+	}
+	if points[0].Fields["clear_sky_radiation"] == nil || points[0].Fields["clear_sky_ratio"] == nil {
+		t.Errorf("Expected clear-sky fields, got %v", points[0].Fields)
+	}
+	sunrise := points[1]
+	if sunrise.Name != Measurement || sunrise.Tags["event"] != "sunrise" || sunrise.Bucket != "weather" {
+		t.Errorf("Unexpected sunrise point %+v", sunrise)
+	}
+	if sunrise.Fields["text"] != "sunrise 05:32" {
+		t.Errorf("Expected local sunrise text, got %q", sunrise.Fields["text"])
+	}
+
+	// Later the same day: no new annotations
+	points = e.Enrich([]*influx.Data{obs("ST-1", noon.Add(10*time.Hour))})
+	if len(points) != 1 || points[0].Fields["is_daytime"] != false {
+		t.Errorf("Expected one night observation, got %d points, is_daytime=%v", len(points), points[0].Fields["is_daytime"])
+	}
+	if _, ok := points[0].Fields["clear_sky_ratio"]; ok || points[0].Fields["clear_sky_radiation"] != 0.0 {
+		t.Errorf("Expected zero clear-sky radiation and no ratio at night, got %v", points[0].Fields)
+	}
+
+	// Stations without coordinates are left alone
+	other := obs("ST-2", noon)
+	if points = e.Enrich([]*influx.Data{other}); len(points) != 1 || other.Fields["is_daytime"] != nil {
+		t.Error("Expected station without coordinates to be skipped")
+	}
+}
@@ -0,0 +1,278 @@
+// Package spool keeps the points InfluxDB could not take on disk and
+// writes them again once it is reachable, so that an outage does not lose
+// data
+package spool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/metrics"
+)
+
+// fileSuffix identifies spooled writes in the directory
+const fileSuffix = ".json"
+
+// entry is one spooled write
+type entry struct {
+	Spooled time.Time      `json:"spooled"`
+	Reason  string         `json:"reason"`
+	Points  []*influx.Data `json:"points"`
+}
+
+// file is a spooled write on disk
+type file struct {
+	path string
+	size int64
+}
+
+// Spool writes points to the next writer and keeps those that fail with a
+// retryable error in Spool_Dir, one file per write. Spooled writes are
+// written again, oldest first, every Spool_Retry_Interval and as soon as a
+// write succeeds. Writes older than Spool_Max_Age are dropped, as are the
+// oldest writes when the spool would exceed Spool_Max_Size_MB. Spooled
+// points InfluxDB rejects outright are passed to the rejected callback.
+type Spool struct {
+	cfg      *config.Config
+	next     influx.PointWriter
+	logger   *logger.AppLogger
+	rejected func(points []*influx.Data, err error)
+	now      func() time.Time
+
+	mu    sync.Mutex
+	files []file
+	size  int64
+
+	// recovered wakes the replay after a successful write
+	recovered chan struct{}
+}
+
+// New creates a Spool in Spool_Dir, creating the directory if needed and
+// picking up the writes spooled by earlier runs
+func New(cfg *config.Config, next influx.PointWriter, rejected func(points []*influx.Data, err error), appLogger *logger.AppLogger) (*Spool, error) {
+	if err := os.MkdirAll(cfg.Spool_Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating spool directory: %w", err)
+	}
+	s := &Spool{
+		cfg:       cfg,
+		next:      next,
+		logger:    appLogger,
+		rejected:  rejected,
+		now:       time.Now,
+		recovered: make(chan struct{}, 1),
+	}
+	entries, err := os.ReadDir(cfg.Spool_Dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !strings.HasSuffix(e.Name(), fileSuffix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		s.files = append(s.files, file{path: filepath.Join(cfg.Spool_Dir, e.Name()), size: info.Size()})
+		s.size += info.Size()
+	}
+	slices.SortFunc(s.files, func(a, b file) int { return strings.Compare(a.path, b.path) })
+	s.report()
+	if len(s.files) > 0 {
+		appLogger.Info("Found spooled writes", slog.Int("writes", len(s.files)), slog.Int64("bytes", s.size))
+	}
+	return s, nil
+}
+
+// Name identifies the spool by the writer it keeps writes for
+func (s *Spool) Name() string {
+	return s.next.Name()
+}
+
+// Write writes points to the next writer. A retryable failure is spooled
+// and reported as success; other errors are returned.
+func (s *Spool) Write(ctx context.Context, points []*influx.Data) error {
+	err := s.next.Write(ctx, points)
+	if err == nil {
+		if s.Len() > 0 {
+			select {
+			case s.recovered <- struct{}{}:
+			default:
+			}
+		}
+		return nil
+	}
+	if !influx.Retryable(err) {
+		return err
+	}
+	if serr := s.save(points, err.Error()); serr != nil {
+		return errors.Join(err, serr)
+	}
+	s.logger.Warn("Spooled points InfluxDB did not take",
+		slog.Int("points", len(points)),
+		slog.String("error", err.Error()))
+	return nil
+}
+
+// Len returns the number of spooled writes
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.files)
+}
+
+// save writes points to a new spool file, dropping the oldest files to stay
+// within Spool_Max_Size_MB
+func (s *Spool) save(points []*influx.Data, reason string) error {
+	now := s.now().UTC()
+	b, err := json.Marshal(entry{Spooled: now, Reason: reason, Points: points})
+	if err != nil {
+		return fmt.Errorf("encoding spooled points: %w", err)
+	}
+	name := fmt.Sprintf("%s-%08x%s", now.Format("20060102T150405.000000000Z"), rand.Uint32(), fileSuffix)
+	path := filepath.Join(s.cfg.Spool_Dir, name)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if maxSize := int64(s.cfg.Spool_Max_Size_MB) << 20; maxSize > 0 {
+		for len(s.files) > 0 && s.size+int64(len(b)) > maxSize {
+			s.logger.Error("Spool is full, dropping oldest spooled points", slog.String("path", s.files[0].path))
+			s.remove(0)
+		}
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return fmt.Errorf("writing spool file: %w", err)
+	}
+	s.files = append(s.files, file{path: path, size: int64(len(b))})
+	s.size += int64(len(b))
+	s.report()
+	return nil
+}
+
+// remove deletes the spool file at index i. The caller holds mu.
+func (s *Spool) remove(i int) {
+	if err := os.Remove(s.files[i].path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		s.logger.Warn("Failed to remove spool file", slog.String("error", err.Error()))
+	}
+	s.size -= s.files[i].size
+	s.files = slices.Delete(s.files, i, i+1)
+	s.report()
+}
+
+// report updates the spool metrics. The caller holds mu or owns the spool.
+func (s *Spool) report() {
+	metrics.SpoolBytes.Set(float64(s.size))
+	metrics.SpoolWrites.Set(float64(len(s.files)))
+}
+
+// Run writes the spooled points again every Spool_Retry_Interval, and after
+// a successful write, until ctx is cancelled
+func (s *Spool) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Spool_Retry_Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.recovered:
+		}
+		s.Replay(ctx)
+	}
+}
+
+// Replay writes the spooled points oldest first, stopping at the first
+// write that fails with a retryable error. Expired writes and writes
+// rejected outright are dropped, the latter after passing them to the
+// rejected callback.
+func (s *Spool) Replay(ctx context.Context) {
+	cutoff := s.now().Add(-s.cfg.Spool_Max_Age)
+	var replayed, points int
+	for ctx.Err() == nil {
+		s.mu.Lock()
+		if len(s.files) == 0 {
+			s.mu.Unlock()
+			break
+		}
+		f := s.files[0]
+		s.mu.Unlock()
+
+		e, err := load(f.path)
+		if err != nil {
+			s.logger.Error("Dropping unreadable spool file", slog.String("path", f.path), slog.String("error", err.Error()))
+			s.drop(f.path)
+			continue
+		}
+		if s.cfg.Spool_Max_Age > 0 && e.Spooled.Before(cutoff) {
+			s.logger.Error("Dropping expired spooled points",
+				slog.String("path", f.path),
+				slog.Int("points", len(e.Points)))
+			s.drop(f.path)
+			continue
+		}
+		if err := s.next.Write(ctx, e.Points); err != nil {
+			if influx.Retryable(err) {
+				break
+			}
+			s.logger.Error("InfluxDB rejected spooled points, dropping them",
+				slog.String("path", f.path),
+				slog.String("error", err.Error()))
+			if s.rejected != nil {
+				s.rejected(e.Points, err)
+			}
+		} else {
+			replayed++
+			points += len(e.Points)
+		}
+		s.drop(f.path)
+	}
+	if replayed > 0 {
+		s.logger.Info("Wrote spooled points", slog.Int("writes", replayed), slog.Int("points", points))
+	}
+}
+
+// drop removes the spool file at path if it is still spooled
+func (s *Spool) drop(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := slices.IndexFunc(s.files, func(f file) bool { return f.path == path }); i >= 0 {
+		s.remove(i)
+	}
+}
+
+// Close closes the next writer if it is an io.Closer. Spooled points stay
+// on disk for the next run.
+func (s *Spool) Close() error {
+	if c, ok := s.next.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// load reads the spooled write at path
+func load(path string) (*entry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var e entry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return &e, nil
+}
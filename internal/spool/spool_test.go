@@ -0,0 +1,147 @@
+package spool
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+// fakeWriter fails writes with err and records the rest
+type fakeWriter struct {
+	err    error
+	writes [][]*influx.Data
+}
+
+func (w *fakeWriter) Name() string { return "influx" }
+
+func (w *fakeWriter) Write(ctx context.Context, points []*influx.Data) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.writes = append(w.writes, points)
+	return nil
+}
+
+func newPoint(temp float64) *influx.Data {
+	m := influx.New()
+	m.Name = "weather"
+	m.Bucket = "weather"
+	m.Tags["station"] = "ST-123"
+	m.Fields["temp"] = temp
+	m.Timestamp = 1640995200
+	return m
+}
+
+func newSpool(t *testing.T, cfg *config.Config, next influx.PointWriter) *Spool {
+	t.Helper()
+	if cfg.Spool_Dir == "" {
+		cfg.Spool_Dir = t.TempDir()
+	}
+	s, err := New(cfg, next, nil, logger.New(&config.Config{}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return s
+}
+
+func TestSpoolOutage(t *testing.T) {
+	next := &fakeWriter{err: errors.New("connection refused")}
+	cfg := &config.Config{}
+	s := newSpool(t, cfg, next)
+
+	for _, temp := range []float64{1, 2} {
+		if err := s.Write(context.Background(), []*influx.Data{newPoint(temp)}); err != nil {
+			t.Fatalf("Expected the write to be spooled, got %v", err)
+		}
+	}
+	if s.Len() != 2 {
+		t.Fatalf("Expected two spooled writes, got %d", s.Len())
+	}
+
+	// Still down: nothing is written or dropped
+	s.Replay(context.Background())
+	if s.Len() != 2 {
+		t.Errorf("Expected the writes to stay spooled, got %d", s.Len())
+	}
+
+	// A new run picks up the spool and writes it once InfluxDB is back
+	next.err = nil
+	s = newSpool(t, cfg, next)
+	s.Replay(context.Background())
+	if s.Len() != 0 || len(next.writes) != 2 {
+		t.Fatalf("Expected both writes replayed, got %d left and %v", s.Len(), next.writes)
+	}
+	if next.writes[0][0].Fields["temp"] != 1.0 || next.writes[1][0].Fields["temp"] != 2.0 {
+		t.Errorf("Expected the oldest write first, got %v", next.writes)
+	}
+	if entries, _ := os.ReadDir(cfg.Spool_Dir); len(entries) != 0 {
+		t.Errorf("Expected the spool directory to be empty, got %d files", len(entries))
+	}
+}
+
+func TestSpoolRejected(t *testing.T) {
+	next := &fakeWriter{err: &influx.WriteError{StatusCode: http.StatusBadRequest, Status: "400 Bad Request"}}
+	s := newSpool(t, &config.Config{}, next)
+	if err := s.Write(context.Background(), []*influx.Data{newPoint(1)}); err == nil {
+		t.Error("Expected a rejected write to return its error")
+	}
+	if s.Len() != 0 {
+		t.Errorf("Expected a rejected write not to be spooled, got %d", s.Len())
+	}
+}
+
+func TestSpoolReplayRejected(t *testing.T) {
+	next := &fakeWriter{err: errors.New("connection refused")}
+	s := newSpool(t, &config.Config{}, next)
+	s.Write(context.Background(), []*influx.Data{newPoint(1)})
+
+	var rejected []*influx.Data
+	s.rejected = func(points []*influx.Data, err error) { rejected = append(rejected, points...) }
+	next.err = &influx.WriteError{StatusCode: http.StatusBadRequest, Status: "400 Bad Request"}
+	s.Replay(context.Background())
+	if s.Len() != 0 {
+		t.Errorf("Expected the rejected write to be dropped from the spool, got %d", s.Len())
+	}
+	if len(rejected) != 1 || rejected[0].Fields["temp"] != 1.0 {
+		t.Errorf("Expected the rejected points to be passed on, got %v", rejected)
+	}
+}
+
+func TestSpoolMaxAge(t *testing.T) {
+	next := &fakeWriter{err: errors.New("connection refused")}
+	s := newSpool(t, &config.Config{Spool_Max_Age: time.Hour}, next)
+	s.Write(context.Background(), []*influx.Data{newPoint(1)})
+
+	next.err = nil
+	s.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	s.Replay(context.Background())
+	if s.Len() != 0 || len(next.writes) != 0 {
+		t.Errorf("Expected the expired write to be dropped, got %d left and %v", s.Len(), next.writes)
+	}
+}
+
+func TestSpoolMaxSize(t *testing.T) {
+	next := &fakeWriter{err: errors.New("connection refused")}
+	s := newSpool(t, &config.Config{Spool_Max_Size_MB: 1}, next)
+	points := make([]*influx.Data, 0, 4000)
+	for range 4000 {
+		points = append(points, newPoint(1))
+	}
+	// Each write is well over a third of the limit
+	for range 3 {
+		s.Write(context.Background(), points)
+	}
+	if s.size > 1<<20 {
+		t.Errorf("Expected the spool within 1 MB, got %d bytes", s.size)
+	}
+	if s.Len() == 0 || s.Len() == 3 {
+		t.Errorf("Expected the oldest writes to be dropped, got %d", s.Len())
+	}
+}
@@ -0,0 +1,122 @@
+// Package state persists the running state of enrichers, such as daily
+// totals and filter windows, so that a restart does not reset them
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+const (
+	// FileName is the name of the state file in State_Dir
+	FileName = "accumulators.json"
+
+	// SaveInterval is how often the state is saved while running
+	SaveInterval = time.Minute
+)
+
+// Persistent is implemented by components whose state is saved
+type Persistent interface {
+	// StateKey names the component's section of the state file
+	StateKey() string
+	// MarshalState returns the component's state
+	MarshalState() (json.RawMessage, error)
+	// UnmarshalState restores state returned by MarshalState
+	UnmarshalState(json.RawMessage) error
+}
+
+// File saves the state of a set of components to one JSON file, keyed by
+// StateKey
+type File struct {
+	path       string
+	logger     *logger.AppLogger
+	components []Persistent
+
+	mu sync.Mutex
+}
+
+// Open restores the components from the file at path, if it exists.
+// Sections that cannot be restored are logged and skipped, so a damaged
+// file only loses state instead of preventing startup.
+func Open(path string, appLogger *logger.AppLogger, components []Persistent) *File {
+	f := &File{path: path, logger: appLogger, components: components}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f
+	}
+	var sections map[string]json.RawMessage
+	if err == nil {
+		err = json.Unmarshal(b, &sections)
+	}
+	if err != nil {
+		appLogger.Warn("Failed to read saved state", slog.String("path", path), slog.String("error", err.Error()))
+		return f
+	}
+	for _, c := range components {
+		section, ok := sections[c.StateKey()]
+		if !ok {
+			continue
+		}
+		if err := c.UnmarshalState(section); err != nil {
+			appLogger.Warn("Failed to restore saved state",
+				slog.String("component", c.StateKey()),
+				slog.String("error", err.Error()))
+		}
+	}
+	appLogger.Info("Restored saved state", slog.String("path", path))
+	return f
+}
+
+// Save writes the state of all components atomically
+func (f *File) Save() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sections := make(map[string]json.RawMessage, len(f.components))
+	for _, c := range f.components {
+		section, err := c.MarshalState()
+		if err != nil {
+			return fmt.Errorf("saving state of %s: %w", c.StateKey(), err)
+		}
+		sections[c.StateKey()] = section
+	}
+	b, err := json.MarshalIndent(sections, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
+
+// Run saves the state every SaveInterval until ctx is cancelled
+func (f *File) Run(ctx context.Context) {
+	ticker := time.NewTicker(SaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := f.Save(); err != nil {
+				f.logger.Error("Failed to save state", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
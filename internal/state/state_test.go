@@ -0,0 +1,71 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/daily"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/smoothing"
+)
+
+func observation(at time.Time, temp, rain, p float64) *influx.Data {
+	m := influx.New()
+	m.ReportType = "obs_st"
+	m.Timestamp = at.Unix()
+	m.Tags["station"] = "ST-1"
+	m.Fields["temp"] = temp
+	m.Fields["precipitation"] = rain
+	m.Fields["p"] = p
+	return m
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	cfg := &config.Config{Timezone: "UTC"}
+	appLogger := logger.New(&config.Config{})
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	acc := daily.New(cfg, appLogger)
+	filter := smoothing.NewPressureFilter(3)
+	f := Open(path, appLogger, []Persistent{acc, filter})
+	for i, p := range []float64{1010, 1012} {
+		points := []*influx.Data{observation(start.Add(time.Duration(i)*time.Minute), 20, 1.5, p)}
+		filter.Enrich(points)
+		acc.Enrich(points)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// A new pipeline continues where the old one stopped
+	acc = daily.New(cfg, appLogger)
+	filter = smoothing.NewPressureFilter(3)
+	Open(path, appLogger, []Persistent{acc, filter})
+	m := observation(start.Add(2*time.Minute), 18, 0.5, 1030)
+	filter.Enrich([]*influx.Data{m})
+	acc.Enrich([]*influx.Data{m})
+
+	if m.Fields["rain_today"] != 3.5 || m.Fields["temp_min_today"] != 18.0 || m.Fields["temp_max_today"] != 20.0 {
+		t.Errorf("Expected daily stats to continue, got %v", m.Fields)
+	}
+	if m.Fields["p"] != 1012.0 {
+		t.Errorf("Expected the median of the restored window, got %v", m.Fields["p"])
+	}
+}
+
+func TestOpenDamagedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte(`{"daily": [1, 2]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	acc := daily.New(&config.Config{}, logger.New(&config.Config{}))
+	f := Open(path, logger.New(&config.Config{}), []Persistent{acc})
+	if err := f.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+// Package stationmeta fills in the coordinates, elevation and time zone of
+// stations from the WeatherFlow cloud API, so that features needing them
+// work without configuring every station by hand
+package stationmeta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/weatherflow"
+)
+
+// FileName is the name of the metadata cache in State_Dir
+const FileName = "station_metadata.json"
+
+// Metadata is what is known about a cloud station
+type Metadata struct {
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Elevation float64 `json:"elevation"`
+	Timezone  string  `json:"timezone"`
+}
+
+// Cloud fetches station metadata, see weatherflow.Client
+type Cloud interface {
+	Station(ctx context.Context, stationID int) (*weatherflow.Station, error)
+}
+
+// Fetch returns the metadata of every station_id in cfg, keyed by station
+// ID. Stations that cannot be fetched keep the metadata cached at path from
+// an earlier run, so a cloud outage does not lose the location at startup.
+// The cache is updated with what was fetched; an empty path disables it.
+func Fetch(ctx context.Context, cfg *config.Config, cloud Cloud, path string, appLogger *logger.AppLogger) map[int]Metadata {
+	metadata := load(path, appLogger)
+	fetched := false
+	// Devices of the same station share its ID
+	seen := make(map[int]bool)
+	for _, station := range cfg.Stations {
+		id := station.StationID
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		s, err := cloud.Station(ctx, id)
+		if err != nil {
+			_, cached := metadata[id]
+			appLogger.Warn("Failed to fetch station metadata",
+				slog.Int("station_id", id),
+				slog.Bool("cached", cached),
+				slog.String("error", err.Error()))
+			continue
+		}
+		metadata[id] = Metadata{
+			Name:      s.Name,
+			Latitude:  s.Latitude,
+			Longitude: s.Longitude,
+			Elevation: s.Meta.Elevation,
+			Timezone:  s.Timezone,
+		}
+		fetched = true
+		appLogger.Info("Fetched station metadata",
+			slog.Int("station_id", id),
+			slog.String("name", s.Name),
+			slog.Float64("elevation", s.Meta.Elevation))
+	}
+	if fetched {
+		save(path, metadata, appLogger)
+	}
+	return metadata
+}
+
+// Apply sets the coordinates, elevation and time zone of every station of
+// cfg with a station_id in metadata. Values configured explicitly win;
+// coordinates are only set when neither latitude nor longitude is.
+func Apply(cfg *config.Config, metadata map[int]Metadata) {
+	for serial, station := range cfg.Stations {
+		m, ok := metadata[station.StationID]
+		if !ok || station.StationID == 0 {
+			continue
+		}
+		if !station.HasCoordinates() {
+			station.Latitude = m.Latitude
+			station.Longitude = m.Longitude
+		}
+		if station.Elevation == 0 {
+			station.Elevation = m.Elevation
+		}
+		if station.Timezone == "" {
+			station.Timezone = m.Timezone
+		}
+		cfg.Stations[serial] = station
+	}
+}
+
+// load reads the cache at path, logging and ignoring a damaged one
+func load(path string, appLogger *logger.AppLogger) map[int]Metadata {
+	metadata := make(map[int]Metadata)
+	if path == "" {
+		return metadata
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return metadata
+	}
+	var cached map[string]Metadata
+	if err == nil {
+		err = json.Unmarshal(b, &cached)
+	}
+	if err != nil {
+		appLogger.Warn("Failed to read cached station metadata", slog.String("path", path), slog.String("error", err.Error()))
+		return metadata
+	}
+	for key, m := range cached {
+		if id, err := strconv.Atoi(key); err == nil {
+			metadata[id] = m
+		}
+	}
+	return metadata
+}
+
+// save replaces the cache at path without leaving a partial file behind
+func save(path string, metadata map[int]Metadata, appLogger *logger.AppLogger) {
+	if path == "" {
+		return
+	}
+	cached := make(map[string]Metadata, len(metadata))
+	for id, m := range metadata {
+		cached[strconv.Itoa(id)] = m
+	}
+	b, err := json.MarshalIndent(cached, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0o755)
+	}
+	if err == nil {
+		tmp := path + ".tmp"
+		if err = os.WriteFile(tmp, b, 0o644); err == nil {
+			err = os.Rename(tmp, path)
+		}
+	}
+	if err != nil {
+		appLogger.Error("Failed to save station metadata", slog.String("path", path), slog.String("error", err.Error()))
+	}
+}
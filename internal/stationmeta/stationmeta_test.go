@@ -0,0 +1,69 @@
+package stationmeta
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/weatherflow"
+)
+
+// fakeCloud serves the stations it holds and fails for any other
+type fakeCloud struct {
+	stations map[int]*weatherflow.Station
+	calls    int
+}
+
+func (c *fakeCloud) Station(ctx context.Context, stationID int) (*weatherflow.Station, error) {
+	c.calls++
+	if s, ok := c.stations[stationID]; ok {
+		return s, nil
+	}
+	return nil, errors.New("unavailable")
+}
+
+func denver() *weatherflow.Station {
+	s := &weatherflow.Station{StationID: 1234, Name: "Backyard", Latitude: 39.74, Longitude: -104.99, Timezone: "America/Denver"}
+	s.Meta.Elevation = 1609.3
+	return s
+}
+
+func TestFetchAndApply(t *testing.T) {
+	cfg := &config.Config{Stations: map[string]config.Station{
+		"ST-1": {StationID: 1234},
+		"ST-2": {StationID: 1234, Elevation: 1600, Timezone: "UTC"},
+		"ST-3": {},
+	}}
+	cloud := &fakeCloud{stations: map[int]*weatherflow.Station{1234: denver()}}
+
+	metadata := Fetch(context.Background(), cfg, cloud, "", logger.New(&config.Config{}))
+	if cloud.calls != 1 {
+		t.Errorf("Expected one request per station ID, got %d", cloud.calls)
+	}
+	Apply(cfg, metadata)
+
+	if s := cfg.Stations["ST-1"]; s.Latitude != 39.74 || s.Longitude != -104.99 || s.Elevation != 1609.3 || s.Timezone != "America/Denver" {
+		t.Errorf("Unexpected ST-1 %+v", s)
+	}
+	if s := cfg.Stations["ST-2"]; s.Elevation != 1600 || s.Timezone != "UTC" || s.Latitude != 39.74 {
+		t.Errorf("Expected configured values to win, got %+v", s)
+	}
+	if s := cfg.Stations["ST-3"]; s.HasCoordinates() {
+		t.Errorf("Expected a station without station_id to be left alone, got %+v", s)
+	}
+}
+
+func TestFetchFallsBackToCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	cfg := &config.Config{Stations: map[string]config.Station{"ST-1": {StationID: 1234}}}
+	appLogger := logger.New(&config.Config{})
+
+	Fetch(context.Background(), cfg, &fakeCloud{stations: map[int]*weatherflow.Station{1234: denver()}}, path, appLogger)
+	metadata := Fetch(context.Background(), cfg, &fakeCloud{}, path, appLogger)
+	if m := metadata[1234]; m.Elevation != 1609.3 || m.Name != "Backyard" {
+		t.Errorf("Expected cached metadata while the cloud is unavailable, got %+v", m)
+	}
+}
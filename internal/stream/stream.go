@@ -0,0 +1,118 @@
+// Package stream fans parsed points out to live subscribers such as gRPC
+// clients.
+package stream
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/metrics"
+)
+
+// DefaultBuffer is the number of points queued per subscriber before new
+// points are dropped
+const DefaultBuffer = 64
+
+// Filter selects the points delivered to a subscriber. Empty lists match
+// everything.
+type Filter struct {
+	Stations    []string
+	ReportTypes []string
+}
+
+// Match reports whether p passes the filter
+func (f Filter) Match(p *influx.Data) bool {
+	if len(f.Stations) > 0 && !slices.Contains(f.Stations, p.Tags["station"]) {
+		return false
+	}
+	if len(f.ReportTypes) > 0 && !slices.Contains(f.ReportTypes, p.ReportType) {
+		return false
+	}
+	return true
+}
+
+// Subscription receives matching points on C until it is closed
+type Subscription struct {
+	C <-chan *influx.Data
+
+	c       chan *influx.Data
+	filter  Filter
+	hub     *Hub
+	dropped atomic.Uint64
+}
+
+// Dropped returns the number of points lost because C was full
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes and closes C
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if _, ok := s.hub.subs[s]; ok {
+		delete(s.hub.subs, s)
+		close(s.c)
+	}
+}
+
+// Hub distributes observed points to subscriptions without ever blocking
+// the packet pipeline; slow subscribers lose points instead
+type Hub struct {
+	name   string
+	buffer int
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+}
+
+// NewHub creates a Hub. name labels the dropped points metric.
+func NewHub(name string, buffer int) *Hub {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	return &Hub{
+		name:   name,
+		buffer: buffer,
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe registers a subscription for points matching filter
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	c := make(chan *influx.Data, h.buffer)
+	s := &Subscription{C: c, c: c, filter: filter, hub: h}
+
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+// Subscribers returns the number of active subscriptions
+func (h *Hub) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
+
+// Observe implements processor.Observer
+func (h *Hub) Observe(points []*influx.Data) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for s := range h.subs {
+		for _, p := range points {
+			if !s.filter.Match(p) {
+				continue
+			}
+			select {
+			case s.c <- p:
+			default:
+				s.dropped.Add(1)
+				metrics.StreamDropped.WithLabelValues(h.name).Inc()
+			}
+		}
+	}
+}
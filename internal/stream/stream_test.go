@@ -0,0 +1,76 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func point(station, reportType string) *influx.Data {
+	p := influx.New()
+	p.Name = "weather"
+	p.ReportType = reportType
+	p.Tags["station"] = station
+	return p
+}
+
+func TestFilterMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		point  *influx.Data
+		want   bool
+	}{
+		{"empty filter", Filter{}, point("ST-1", "obs_st"), true},
+		{"station match", Filter{Stations: []string{"ST-1"}}, point("ST-1", "obs_st"), true},
+		{"station mismatch", Filter{Stations: []string{"ST-2"}}, point("ST-1", "obs_st"), false},
+		{"type match", Filter{ReportTypes: []string{"rapid_wind"}}, point("ST-1", "rapid_wind"), true},
+		{"type mismatch", Filter{Stations: []string{"ST-1"}, ReportTypes: []string{"rapid_wind"}}, point("ST-1", "obs_st"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(tt.point); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHubFanOut(t *testing.T) {
+	hub := NewHub("test", 4)
+	all := hub.Subscribe(Filter{})
+	one := hub.Subscribe(Filter{Stations: []string{"ST-2"}})
+
+	hub.Observe([]*influx.Data{point("ST-1", "obs_st"), point("ST-2", "obs_st")})
+
+	if len(all.C) != 2 {
+		t.Errorf("Expected 2 points for unfiltered subscriber, got %d", len(all.C))
+	}
+	if len(one.C) != 1 || (<-one.C).Tags["station"] != "ST-2" {
+		t.Error("Expected only the ST-2 point for filtered subscriber")
+	}
+
+	one.Close()
+	one.Close() // closing twice is harmless
+	if hub.Subscribers() != 1 {
+		t.Errorf("Expected 1 subscriber after close, got %d", hub.Subscribers())
+	}
+	if _, ok := <-one.C; ok {
+		t.Error("Expected closed channel")
+	}
+}
+
+func TestHubDropsForSlowSubscriber(t *testing.T) {
+	hub := NewHub("test", 1)
+	sub := hub.Subscribe(Filter{})
+
+	hub.Observe([]*influx.Data{point("ST-1", "obs_st"), point("ST-1", "obs_st"), point("ST-1", "obs_st")})
+
+	if len(sub.C) != 1 {
+		t.Errorf("Expected 1 queued point, got %d", len(sub.C))
+	}
+	if sub.Dropped() != 2 {
+		t.Errorf("Expected 2 dropped points, got %d", sub.Dropped())
+	}
+}
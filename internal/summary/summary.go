@@ -0,0 +1,146 @@
+// Package summary aggregates observations into periodic summary points that
+// can be kept far longer than the raw data
+package summary
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+// Measurement is the name of summary points
+const Measurement = "weather_summary"
+
+// ReportType marks summary points
+const ReportType = "summary"
+
+// Fields are summarized as <field>_avg, <field>_min and <field>_max
+var Fields = []string{
+	"temp", "relative_humidity", "dew_point", "p",
+	"wind_avg", "wind_gust", "solar_radiation", "uv", "illuminance",
+}
+
+// Totals are summed over the interval and written under their own name
+var Totals = []string{"precipitation", "strike_count"}
+
+// stat accumulates the values of one field
+type stat struct {
+	sum, min, max float64
+	count         int
+}
+
+func (s *stat) add(v float64) {
+	if s.count == 0 || v < s.min {
+		s.min = v
+	}
+	if s.count == 0 || v > s.max {
+		s.max = v
+	}
+	s.sum += v
+	s.count++
+}
+
+// window is the summary of one station for one interval in progress
+type window struct {
+	start  int64
+	stats  map[string]*stat
+	totals map[string]float64
+}
+
+// Aggregator adds a summary point for every station and interval. Intervals
+// are aligned to the Unix epoch; the summary of an interval is emitted with
+// the first observation of the next one and timestamped with the interval's
+// start.
+type Aggregator struct {
+	interval int64
+	bucket   string
+
+	mu      sync.Mutex
+	windows map[string]*window
+}
+
+// New creates an Aggregator for the summary settings of cfg. Summaries go to
+// Summary_Bucket, or the main bucket when it is empty.
+func New(cfg *config.Config) *Aggregator {
+	bucket := cfg.Summary_Bucket
+	if bucket == "" {
+		bucket = cfg.Influx_Bucket
+	}
+	return &Aggregator{
+		interval: int64(cfg.Summary_Interval / time.Second),
+		bucket:   bucket,
+		windows:  make(map[string]*window),
+	}
+}
+
+// Enrich accumulates obs_st points and appends summaries of completed
+// intervals
+func (a *Aggregator) Enrich(points []*influx.Data) []*influx.Data {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var summaries []*influx.Data
+	for _, m := range points {
+		if m.ReportType != "obs_st" {
+			continue
+		}
+		station := m.Tags["station"]
+		start := m.Timestamp - m.Timestamp%a.interval
+
+		w, ok := a.windows[station]
+		if ok && start < w.start {
+			// Late observation of an interval already summarized
+			continue
+		}
+		if ok && start > w.start {
+			summaries = append(summaries, a.summarize(station, w))
+			ok = false
+		}
+		if !ok {
+			w = &window{start: start, stats: make(map[string]*stat), totals: make(map[string]float64)}
+			a.windows[station] = w
+		}
+		a.add(w, m)
+	}
+	return append(points, summaries...)
+}
+
+// add accumulates the fields of m into w
+func (a *Aggregator) add(w *window, m *influx.Data) {
+	for _, field := range Fields {
+		if v, ok := m.Float(field); ok {
+			s, ok := w.stats[field]
+			if !ok {
+				s = &stat{}
+				w.stats[field] = s
+			}
+			s.add(v)
+		}
+	}
+	for _, field := range Totals {
+		if v, ok := m.Float(field); ok {
+			w.totals[field] += v
+		}
+	}
+}
+
+// summarize builds the summary point of w
+func (a *Aggregator) summarize(station string, w *window) *influx.Data {
+	m := influx.New()
+	m.Name = Measurement
+	m.ReportType = ReportType
+	m.Bucket = a.bucket
+	m.Timestamp = w.start
+	m.Tags["station"] = station
+	for field, s := range w.stats {
+		m.Fields[field+"_avg"] = influx.Round(s.sum/float64(s.count), 2)
+		m.Fields[field+"_min"] = influx.Round(s.min, 2)
+		m.Fields[field+"_max"] = influx.Round(s.max, 2)
+	}
+	for field, total := range w.totals {
+		m.Fields[field] = influx.Round(total, 2)
+	}
+	return m
+}
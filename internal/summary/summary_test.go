@@ -0,0 +1,76 @@
+package summary
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func observation(station string, timestamp int64, temp, rain float64) *influx.Data {
+	m := influx.New()
+	m.Name = "weather"
+	m.ReportType = "obs_st"
+	m.Bucket = "weather"
+	m.Timestamp = timestamp
+	m.Tags["station"] = station
+	m.Fields["temp"] = temp
+	m.Fields["precipitation"] = rain
+	return m
+}
+
+func TestAggregator(t *testing.T) {
+	a := New(&config.Config{
+		Influx_Bucket:    "weather",
+		Summary_Bucket:   "weather_longterm",
+		Summary_Interval: 5 * time.Minute,
+	})
+
+	for _, m := range []*influx.Data{
+		observation("ST-1", 600, 10.0, 0.1),
+		observation("ST-1", 660, 14.0, 0.2),
+		observation("ST-2", 660, 30.0, 0.0),
+		observation("ST-1", 840, 12.0, 0.0),
+	} {
+		if got := a.Enrich([]*influx.Data{m}); len(got) != 1 {
+			t.Fatalf("Expected no summary within the interval, got %d points", len(got))
+		}
+	}
+
+	got := a.Enrich([]*influx.Data{observation("ST-1", 900, 20.0, 0.0)})
+	if len(got) != 2 {
+		t.Fatalf("Expected observation and summary, got %d points", len(got))
+	}
+	s := got[1]
+	if s.Name != Measurement || s.Bucket != "weather_longterm" || s.Timestamp != 600 || s.Tags["station"] != "ST-1" {
+		t.Errorf("Unexpected summary point %+v", s)
+	}
+	want := map[string]float64{
+		"temp_avg":      12.0,
+		"temp_min":      10.0,
+		"temp_max":      14.0,
+		"precipitation": 0.3,
+	}
+	for field, value := range want {
+		if s.Fields[field] != value {
+			t.Errorf("Expected %s = %v, got %v", field, value, s.Fields[field])
+		}
+	}
+	if _, ok := s.Fields["wind_avg_avg"]; ok {
+		t.Error("Expected no summary of fields without data")
+	}
+
+	if got := a.Enrich([]*influx.Data{observation("ST-1", 700, 99.0, 0.0)}); len(got) != 1 {
+		t.Errorf("Expected late observation not to emit a summary, got %d points", len(got))
+	}
+}
+
+func TestAggregatorDefaultBucket(t *testing.T) {
+	a := New(&config.Config{Influx_Bucket: "weather", Summary_Interval: time.Minute})
+	a.Enrich([]*influx.Data{observation("ST-1", 0, 1.0, 0.0)})
+	got := a.Enrich([]*influx.Data{observation("ST-1", 60, 1.0, 0.0)})
+	if len(got) != 2 || got[1].Bucket != "weather" {
+		t.Errorf("Expected summary in the main bucket, got %v", got)
+	}
+}
@@ -0,0 +1,124 @@
+// Package telegraf sends points as line protocol to a Telegraf
+// socket_listener, which then handles buffering and fan-out
+package telegraf
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+// DefaultTimeout bounds a write when Influx_Write_Timeout is not set
+const DefaultTimeout = 5 * time.Second
+
+// Output writes points to Telegraf_Address, given as udp://host:port or
+// tcp://host:port like the service_address of the socket_listener. Over UDP
+// every point is sent as its own datagram; over TCP all points of a write
+// go over one connection, which is re-established after an error.
+// Timestamps are written in nanoseconds, the socket_listener's default
+// precision.
+type Output struct {
+	network string
+	address string
+	timeout time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// New creates an Output for cfg.Telegraf_Address. The connection is opened
+// on the first write.
+func New(cfg *config.Config) (*Output, error) {
+	network, address, err := parseAddress(cfg.Telegraf_Address)
+	if err != nil {
+		return nil, err
+	}
+	timeout := cfg.Influx_Write_Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Output{network: network, address: address, timeout: timeout}, nil
+}
+
+// parseAddress splits a udp:// or tcp:// address into network and host:port
+func parseAddress(address string) (network, hostport string, err error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", "", fmt.Errorf("parsing Telegraf address %q: %w", address, err)
+	}
+	switch u.Scheme {
+	case "udp", "tcp":
+	default:
+		return "", "", fmt.Errorf("telegraf address %q must start with udp:// or tcp://", address)
+	}
+	if u.Port() == "" {
+		return "", "", fmt.Errorf("telegraf address %q has no port", address)
+	}
+	return u.Scheme, u.Host, nil
+}
+
+// Name implements processor.Output
+func (o *Output) Name() string {
+	return "telegraf"
+}
+
+// Write sends points to Telegraf
+func (o *Output) Write(ctx context.Context, points []*influx.Data) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.conn == nil {
+		dialer := net.Dialer{Timeout: o.timeout}
+		conn, err := dialer.DialContext(ctx, o.network, o.address)
+		if err != nil {
+			return fmt.Errorf("connecting to Telegraf at %s: %w", o.address, err)
+		}
+		o.conn = conn
+	}
+
+	deadline := time.Now().Add(o.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	o.conn.SetWriteDeadline(deadline)
+
+	var err error
+	if strings.HasPrefix(o.network, "udp") {
+		for _, m := range points {
+			if _, err = o.conn.Write([]byte(m.MarshalPrecision(influx.PrecisionNanoseconds))); err != nil {
+				break
+			}
+		}
+	} else {
+		var body strings.Builder
+		for _, m := range points {
+			body.WriteString(m.MarshalPrecision(influx.PrecisionNanoseconds))
+		}
+		_, err = o.conn.Write([]byte(body.String()))
+	}
+	if err != nil {
+		o.conn.Close()
+		o.conn = nil
+		return fmt.Errorf("writing to Telegraf at %s: %w", o.address, err)
+	}
+	return nil
+}
+
+// Close closes the connection to Telegraf
+func (o *Output) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.conn == nil {
+		return nil
+	}
+	err := o.conn.Close()
+	o.conn = nil
+	return err
+}
@@ -0,0 +1,121 @@
+package telegraf
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func testPoints() []*influx.Data {
+	var points []*influx.Data
+	for i, temp := range []float64{1.5, 2.5} {
+		m := influx.New()
+		m.Name = "weather"
+		m.Tags["station"] = "ST-1"
+		m.Fields["temp"] = temp
+		m.Timestamp = 1640995200 + int64(i)
+		points = append(points, m)
+	}
+	return points
+}
+
+func TestWriteUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	out, err := New(&config.Config{Telegraf_Address: "udp://" + conn.LocalAddr().String()})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer out.Close()
+	if err := out.Write(context.Background(), testPoints()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1024)
+	for _, want := range []string{
+		"weather,station=ST-1 temp=1.5 1640995200000000000\n",
+		"weather,station=ST-1 temp=2.5 1640995201000000000\n",
+	} {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("ReadFrom() error = %v", err)
+		}
+		if got := string(buf[:n]); got != want {
+			t.Errorf("Expected datagram %q, got %q", want, got)
+		}
+	}
+}
+
+func TestWriteTCPReconnects(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+		}
+	}()
+
+	out, err := New(&config.Config{Telegraf_Address: "tcp://" + listener.Addr().String()})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer out.Close()
+	if err := out.Write(context.Background(), testPoints()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	for range 2 {
+		select {
+		case line := <-lines:
+			if !strings.HasPrefix(line, "weather,station=ST-1 temp=") {
+				t.Errorf("Unexpected line %q", line)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for lines")
+		}
+	}
+
+	// A closed connection is replaced on the next write
+	out.Close()
+	if err := out.Write(context.Background(), testPoints()[:1]); err != nil {
+		t.Fatalf("Write() after close error = %v", err)
+	}
+	select {
+	case <-lines:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the line after reconnecting")
+	}
+}
+
+func TestNewInvalidAddress(t *testing.T) {
+	for _, address := range []string{"127.0.0.1:8094", "http://telegraf:8094", "udp://telegraf"} {
+		if _, err := New(&config.Config{Telegraf_Address: address}); err == nil {
+			t.Errorf("Expected error for %q", address)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package tempest
+
+import (
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+var parseLog atomic.Pointer[logger.AppLogger]
+
+// SetLogger sets the logger of the parser, which otherwise logs to the
+// default slog logger
+func SetLogger(l *logger.AppLogger) {
+	parseLog.Store(l)
+}
+
+// parseLogger returns the logger of the parser
+func parseLogger() *logger.AppLogger {
+	if l := parseLog.Load(); l != nil {
+		return l
+	}
+	return &logger.AppLogger{Logger: slog.Default()}
+}
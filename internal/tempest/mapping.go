@@ -0,0 +1,70 @@
+package tempest
+
+import (
+	"fmt"
+
+	"github.com/de-wax/go-pkg/dewpoint"
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/meteo"
+)
+
+// mappedValues returns the observation array of a report: the first row of
+// obs, evt, or else ob
+func mappedValues(report Report) []float64 {
+	switch {
+	case len(report.Obs[0]) > 0:
+		return report.Obs[0]
+	case len(report.Evt) > 0:
+		return report.Evt
+	default:
+		return report.Ob[:]
+	}
+}
+
+// parseMapped parses a report whose observation array is laid out by a
+// configured field mapping instead of the built-in one. Values are stored
+// under their mapped names, converted to metric when a unit is given;
+// values without a name or mapping are ignored. The dew point is derived
+// when temperature and humidity are mapped.
+func parseMapped(cfg *config.Config, report Report, mapping []config.FieldMapping, m *influx.Data) error {
+	values := mappedValues(report)
+	parseLogger().Debug("Parsed mapped report",
+		"report_type", report.ReportType,
+		"station", report.StationSerial,
+		"values", values)
+	m.Fields = make(influx.Fields, len(mapping))
+	timestamp := false
+	for i, f := range mapping {
+		if i >= len(values) {
+			break
+		}
+		if f.Name == "" {
+			continue
+		}
+		v := values[i]
+		if f.Unit != "" {
+			v, _ = meteo.ToMetric(f.Unit, v)
+		}
+		if f.Name == "timestamp" {
+			m.Timestamp = int64(v)
+			timestamp = true
+			continue
+		}
+		m.Fields[f.Name] = v
+	}
+	if !timestamp {
+		return fmt.Errorf("%w: no timestamp among %d values", ErrInsufficientData, len(values))
+	}
+
+	if _, ok := m.Fields["dew_point"]; !ok {
+		temp, okTemp := m.Float("temp")
+		humidity, okHumidity := m.Float("relative_humidity")
+		if okTemp && okHumidity {
+			if dp, err := dewpoint.Calculate(temp, humidity); err == nil {
+				m.Fields["dew_point"] = influx.Round(dp, 2)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package tempest
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+)
+
+// airMapping lays out obs_air, the observation of the Air device
+var airMapping = []config.FieldMapping{
+	{Name: "timestamp"},
+	{Name: "p"},
+	{Name: "temp", Unit: "F"},
+	{Name: "relative_humidity"},
+	{Name: "strike_count"},
+	{Name: "strike_distance"},
+	{Name: "battery"},
+	{},
+}
+
+func TestParseMappedReportType(t *testing.T) {
+	cfg := &config.Config{
+		Influx_Bucket:  "weather",
+		Strict_Schema:  true,
+		Field_Mappings: map[string][]config.FieldMapping{"obs_air": airMapping},
+	}
+	data := `{"serial_number":"AR-00004049","type":"obs_air","hub_sn":"HB-00000001","obs":[[1493164835,835.0,50,45,0,0,3.46,1]]}`
+	addr, _ := net.ResolveUDPAddr("udp", "192.168.1.100:50222")
+
+	m, err := Parse(cfg, addr, []byte(data), len(data))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if m == nil || m.Name != "weather" || m.Timestamp != 1493164835 || m.Tags["station"] != "AR-00004049" {
+		t.Fatalf("Unexpected point %+v", m)
+	}
+	for field, want := range map[string]float64{"p": 835.0, "temp": 10.0, "relative_humidity": 45.0, "battery": 3.46, "dew_point": -1.4} {
+		if m.Fields[field] != want {
+			t.Errorf("%s = %v, want %v", field, m.Fields[field], want)
+		}
+	}
+	if len(m.Fields) != 7 {
+		t.Errorf("Expected unnamed values to be ignored, got %v", m.Fields)
+	}
+}
+
+func TestParseMappingOverridesBuiltin(t *testing.T) {
+	cfg := &config.Config{
+		Influx_Bucket: "weather",
+		Field_Mappings: map[string][]config.FieldMapping{
+			"obs_st": {{Name: "timestamp"}, {Name: "wind_avg", Unit: "mph"}},
+		},
+	}
+	data := `{"serial_number":"ST-1","type":"obs_st","obs":[[1640995200,10]]}`
+	addr, _ := net.ResolveUDPAddr("udp", "192.168.1.100:50222")
+
+	m, err := Parse(cfg, addr, []byte(data), len(data))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if m.Fields["wind_avg"] != 4.4704 || len(m.Fields) != 1 {
+		t.Errorf("Unexpected fields %v", m.Fields)
+	}
+}
+
+func TestParseMappingWithoutTimestamp(t *testing.T) {
+	cfg := &config.Config{
+		Field_Mappings: map[string][]config.FieldMapping{
+			"obs_sky": {{}, {Name: "illuminance"}, {Name: "timestamp"}},
+		},
+	}
+	data := `{"serial_number":"SK-1","type":"obs_sky","obs":[[1640995200,10000]]}`
+	addr, _ := net.ResolveUDPAddr("udp", "192.168.1.100:50222")
+
+	if _, err := Parse(cfg, addr, []byte(data), len(data)); !errors.Is(err, ErrInsufficientData) {
+		t.Errorf("Parse() error = %v, want ErrInsufficientData", err)
+	}
+}
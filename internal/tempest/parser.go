@@ -2,12 +2,14 @@ package tempest
 
 import (
 	"bytes"
+	"cmp"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"hash/fnv"
 	"math"
 	"net"
+	"sync"
 
 	"github.com/de-wax/go-pkg/dewpoint"
 	"github.com/jacaudi/tempest-influxdb/internal/config"
@@ -43,6 +45,12 @@ func (p PrecipType) String() string {
 // PrecipitationTypeStrings provides backward compatibility
 var PrecipitationTypeStrings = []string{"none", "rain", "hail", "rain+hail"}
 
+// knownObsFields is the number of obs_st values the parser understands
+const knownObsFields = 18
+
+// seenObsLengths records the oversized obs_st lengths that have been logged
+var seenObsLengths sync.Map
+
 // Report represents a weather report from Tempest station
 type Report struct {
 	StationSerial    string       `json:"serial_number,omitempty"`
@@ -50,6 +58,7 @@ type Report struct {
 	HubSerial        string       `json:"hub_sn,omitempty"`
 	Obs              [1][]float64 `json:"obs,omitempty"`
 	Ob               [3]float64   `json:"ob,omitempty"`
+	Evt              []float64    `json:"evt,omitempty"`
 	FirmwareRevision int
 	Uptime           int       `json:"uptime,omitempty"`
 	Timestamp        int       `json:"timestamp,omitempty"`
@@ -89,8 +98,8 @@ func parseObservation(cfg *config.Config, report Report, m *influx.Data) error {
 	}
 	var observation Obs
 
-	if len(report.Obs[0]) < 18 {
-		return fmt.Errorf("%w: expected 18 fields, got %d", ErrInsufficientData, len(report.Obs[0]))
+	if len(report.Obs[0]) < knownObsFields {
+		return fmt.Errorf("%w: expected %d fields, got %d", ErrInsufficientData, knownObsFields, len(report.Obs[0]))
 	}
 
 	data := report.Obs[0]
@@ -112,38 +121,76 @@ func parseObservation(cfg *config.Config, report Report, m *influx.Data) error {
 	observation.StrikeCount = int(math.Round(data[15]))
 	observation.Battery = data[16]
 	observation.Interval = int(math.Round(data[17]))
-	if cfg.Debug {
-		log.Printf("OBS_ST %+v %+v", report, observation)
-	}
+	parseLogger().Debug("Parsed observation",
+		"station", report.StationSerial,
+		"values", data)
 
 	// Calculate Dew Point from RH and Temp
 	dp, err := dewpoint.Calculate(observation.AirTemperature, observation.RelativeHumidity)
 	if err != nil {
-		log.Printf("dewpoint.Calculate(%f, %f): %v", observation.AirTemperature, observation.RelativeHumidity, err)
+		parseLogger().Warn("Could not calculate dew point",
+			"station", report.StationSerial,
+			"temp", observation.AirTemperature,
+			"relative_humidity", observation.RelativeHumidity,
+			"error", err.Error())
 	}
 
 	m.Timestamp = observation.Timestamp
 	// Set fields and sort into alphabetical order to keep InfluxDB happy
-	m.Fields = map[string]string{
-		"battery":            fmt.Sprintf("%.2f", observation.Battery),
-		"dew_point":          fmt.Sprintf("%.2f", dp),
-		"illuminance":        fmt.Sprintf("%d", observation.Illuminance),
-		"p":                  fmt.Sprintf("%.2f", observation.StationPressure),
-		"precipitation":      fmt.Sprintf("%.2f", observation.PrecipitationAccumulation),
-		"precipitation_type": fmt.Sprintf("%d", observation.PrecipitationType),
-		"solar_radiation":    fmt.Sprintf("%d", observation.SolarRadiation),
-		"strike_count":       fmt.Sprintf("%d", observation.StrikeCount),
-		"strike_distance":    fmt.Sprintf("%d", observation.StrikeAvgDistance),
-		"temp":               fmt.Sprintf("%.2f", observation.AirTemperature),
-		"uv":                 fmt.Sprintf("%.2f", observation.UV),
-		"wind_avg":           fmt.Sprintf("%.2f", observation.WindAvg),
-		"wind_direction":     fmt.Sprintf("%d", observation.WindDirection),
-		"wind_gust":          fmt.Sprintf("%.2f", observation.WindGust),
-		"wind_lull":          fmt.Sprintf("%.2f", observation.WindLull),
+	m.Fields = influx.Fields{
+		"battery":            influx.Round(observation.Battery, 2),
+		"dew_point":          influx.Round(dp, 2),
+		"illuminance":        int64(observation.Illuminance),
+		"p":                  influx.Round(observation.StationPressure, 2),
+		"precipitation":      influx.Round(observation.PrecipitationAccumulation, 2),
+		"precipitation_type": int64(observation.PrecipitationType),
+		"relative_humidity":  influx.Round(observation.RelativeHumidity, 2),
+		"solar_radiation":    int64(observation.SolarRadiation),
+		"strike_count":       int64(observation.StrikeCount),
+		"strike_distance":    float64(observation.StrikeAvgDistance),
+		"temp":               influx.Round(observation.AirTemperature, 2),
+		"uv":                 influx.Round(observation.UV, 2),
+		"wind_avg":           influx.Round(observation.WindAvg, 2),
+		"wind_direction":     int64(observation.WindDirection),
+		"wind_gust":          influx.Round(observation.WindGust, 2),
+		"wind_lull":          influx.Round(observation.WindLull, 2),
 	}
+
+	parseExtraObsFields(cfg, data, m)
 	return nil
 }
 
+// parseExtraObsFields handles values appended to obs_st by newer firmware.
+// They are stored as obs_<index> when enabled, and each new array length
+// is logged once so the data is never dropped silently.
+func parseExtraObsFields(cfg *config.Config, data []float64, m *influx.Data) {
+	extra := data[knownObsFields:]
+	if len(extra) == 0 {
+		return
+	}
+
+	if _, logged := seenObsLengths.LoadOrStore(len(data), true); !logged {
+		if cfg.Extra_Obs_Fields {
+			parseLogger().Info("obs_st has more values than known; storing them as obs_<index>",
+				"values", len(data),
+				"extra", len(extra),
+				"first", fmt.Sprintf("obs_%d", knownObsFields),
+				"last", fmt.Sprintf("obs_%d", len(data)-1))
+		} else {
+			parseLogger().Warn("obs_st has more values than known; ignoring them (enable extra_obs_fields to keep them)",
+				"values", len(data),
+				"extra", len(extra))
+		}
+	}
+
+	if !cfg.Extra_Obs_Fields {
+		return
+	}
+	for i, v := range extra {
+		m.Fields[fmt.Sprintf("obs_%d", knownObsFields+i)] = v
+	}
+}
+
 // parseRapidWind parses Tempest rapid wind data
 func parseRapidWind(cfg *config.Config, report Report, m *influx.Data) error {
 	type RapidWind struct {
@@ -161,20 +208,61 @@ func parseRapidWind(cfg *config.Config, report Report, m *influx.Data) error {
 	rapidWind.Timestamp = int64(report.Ob[0])
 	rapidWind.WindSpeed = report.Ob[1]
 	rapidWind.WindDirection = int(math.Round(report.Ob[2]))
-	if cfg.Debug {
-		log.Printf("RAPID_WIND %+v %+v", report, rapidWind)
-	}
+	parseLogger().Debug("Parsed rapid wind",
+		"station", report.StationSerial,
+		"values", report.Ob)
 
 	m.Timestamp = rapidWind.Timestamp
-	m.Fields = map[string]string{
-		"rapid_wind_speed":     fmt.Sprintf("%.2f", rapidWind.WindSpeed),
-		"rapid_wind_direction": fmt.Sprintf("%d", rapidWind.WindDirection),
+	if cfg.Rapid_Wind_Subsecond {
+		m.Nanos = subsecondOffset(report.StationSerial)
+	}
+	m.Fields = influx.Fields{
+		"rapid_wind_speed":     influx.Round(rapidWind.WindSpeed, 2),
+		"rapid_wind_direction": int64(rapidWind.WindDirection),
 	}
 	return nil
 }
 
+// parseStrike parses a lightning strike event: [timestamp, distance in km,
+// energy]
+func parseStrike(cfg *config.Config, report Report, m *influx.Data) error {
+	if len(report.Evt) < 3 {
+		return fmt.Errorf("%w: expected 3 event values, got %d", ErrInsufficientData, len(report.Evt))
+	}
+	parseLogger().Debug("Parsed strike",
+		"station", report.StationSerial,
+		"values", report.Evt)
+
+	m.Timestamp = int64(report.Evt[0])
+	m.Fields = influx.Fields{
+		"strike_distance": math.Round(report.Evt[1]),
+		"strike_energy":   int64(math.Round(report.Evt[2])),
+	}
+	return nil
+}
+
+// subsecondOffset returns a deterministic offset between 1µs and 1s for a
+// device serial. Readings from different devices that share a second then
+// get distinct timestamps, while a duplicate of the same reading (e.g.
+// heard through two hubs) still lands on the same point.
+func subsecondOffset(serial string) int64 {
+	h := fnv.New32a()
+	h.Write([]byte(serial))
+	return (int64(h.Sum32()%999_999) + 1) * 1000
+}
+
 // Parse parses weather data from Tempest station
-func Parse(cfg *config.Config, addr *net.UDPAddr, b []byte, n int) (m *influx.Data, err error) {
+func Parse(cfg *config.Config, addr net.Addr, b []byte, n int) (m *influx.Data, err error) {
+	if cfg.Strict_Schema {
+		// Mapped report types have a layout of their own, and unknown
+		// ones are passed through as they are when enabled
+		var schemaErr *SchemaError
+		if err = ValidateSchema(b[:n]); err != nil && !(errors.As(err, &schemaErr) && exemptFromSchema(cfg, schemaErr.ReportType)) {
+			return nil, err
+		}
+		err = nil
+	}
+
 	var report Report
 	decoder := json.NewDecoder(bytes.NewReader(b[:n]))
 	err = decoder.Decode(&report)
@@ -182,15 +270,28 @@ func Parse(cfg *config.Config, addr *net.UDPAddr, b []byte, n int) (m *influx.Da
 		err = fmt.Errorf("ERROR Could not Unmarshal %d bytes from %v: %v: %v", n, addr, err, string(b[:n]))
 		return
 	}
+	if !cfg.ReportTypeForwarded(report.ReportType) {
+		return nil, nil
+	}
 
 	m = influx.New()
 
 	m.Bucket = cfg.Influx_Bucket
+	m.ReportType = report.ReportType
+
+	// A configured field mapping replaces the built-in layout
+	mapping := cfg.Field_Mappings[report.ReportType]
+	parse := func(builtin func(*config.Config, Report, *influx.Data) error) error {
+		if len(mapping) > 0 {
+			return parseMapped(cfg, report, mapping, m)
+		}
+		return builtin(cfg, report, m)
+	}
 
 	switch report.ReportType {
 	case "obs_st":
 		m.Name = "weather"
-		if err = parseObservation(cfg, report, m); err != nil {
+		if err = parse(parseObservation); err != nil {
 			return nil, fmt.Errorf("parsing observation: %w", err)
 		}
 		m.Tags["station"] = report.StationSerial
@@ -199,19 +300,71 @@ func Parse(cfg *config.Config, addr *net.UDPAddr, b []byte, n int) (m *influx.Da
 			return nil, nil
 		}
 		m.Name = "weather"
-		if err = parseRapidWind(cfg, report, m); err != nil {
+		if err = parse(parseRapidWind); err != nil {
 			return nil, fmt.Errorf("parsing rapid wind: %w", err)
 		}
 		m.Tags["station"] = report.StationSerial
-		if cfg.Influx_Bucket_Rapid_Wind != "" {
-			m.Bucket = cfg.Influx_Bucket_Rapid_Wind
+
+	case "evt_strike":
+		if !cfg.Lightning {
+			return nil, nil
+		}
+		m.Name = "lightning"
+		if err = parse(parseStrike); err != nil {
+			return nil, fmt.Errorf("parsing strike: %w", err)
 		}
+		m.Tags["station"] = report.StationSerial
 
-	case "hub_status", "evt_precip", "evt_strike":
-		return nil, nil
 	default:
-		return nil, nil
+		// Other devices, such as Air and Sky, need a mapping
+		if len(mapping) > 0 {
+			m.Name = "weather"
+			if err = parseMapped(cfg, report, mapping, m); err != nil {
+				return nil, fmt.Errorf("parsing %s: %w", report.ReportType, err)
+			}
+		} else if cfg.Raw_Unknown_Types && !knownReportType(report.ReportType) {
+			m.Name = RawMeasurement
+			if err = parseRaw(b[:n], report, m); err != nil {
+				return nil, fmt.Errorf("passing through %s: %w", report.ReportType, err)
+			}
+			m.Tags["type"] = report.ReportType
+		} else {
+			return nil, nil
+		}
+		// Unknown reports need not come from a device
+		if report.StationSerial != "" {
+			m.Tags["station"] = report.StationSerial
+		}
 	}
 
+	if route, ok := cfg.InfluxRoute(report.ReportType, report.StationSerial); ok {
+		m.Bucket = cmp.Or(route.Bucket, m.Bucket)
+		m.Name = cmp.Or(route.Measurement, m.Name)
+	}
+	if cfg.Dedupe_Hubs && report.HubSerial != "" {
+		m.Tags["hub"] = report.HubSerial
+	}
+	addStationTags(cfg, m)
 	return
 }
+
+// exemptFromSchema reports whether packets of reportType are stored even
+// though they fail strict schema validation
+func exemptFromSchema(cfg *config.Config, reportType string) bool {
+	if len(cfg.Field_Mappings[reportType]) > 0 {
+		return true
+	}
+	return cfg.Raw_Unknown_Types && reportType != "unknown" && !knownReportType(reportType)
+}
+
+// addStationTags adds the configured name and location of the point's
+// station as tags
+func addStationTags(cfg *config.Config, m *influx.Data) {
+	station := cfg.Station(m.Tags["station"])
+	if station.Name != "" {
+		m.Tags["station_name"] = station.Name
+	}
+	if station.Location != "" {
+		m.Tags["location"] = station.Location
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/jacaudi/tempest-influxdb/internal/config"
 	"github.com/jacaudi/tempest-influxdb/internal/influx"
@@ -96,11 +97,11 @@ func TestParseObservationSuccess(t *testing.T) {
 		}
 	}
 
-	if m.Fields["temp"] != "25.50" {
-		t.Errorf("Expected temp=25.50, got %s", m.Fields["temp"])
+	if m.Fields["temp"] != 25.5 {
+		t.Errorf("Expected temp=25.5, got %v", m.Fields["temp"])
 	}
 
-	if m.Fields["wind_direction"] != "180" {
+	if m.Fields["wind_direction"] != int64(180) {
 		t.Errorf("Expected wind_direction=180, got %s", m.Fields["wind_direction"])
 	}
 }
@@ -126,6 +127,42 @@ func TestParseObservationInsufficientData(t *testing.T) {
 	}
 }
 
+func TestParseObservationExtraFields(t *testing.T) {
+	data := []float64{1640995200, 1.5, 2.3, 3.8, 180, 3, 1013.25, 25.5, 65.0, 50000, 5.2, 800, 0.5, 0, 5, 2, 3.7, 1, 42.5, 7}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		want    influx.Fields
+	}{
+		{"disabled", false, influx.Fields{}},
+		{"enabled", true, influx.Fields{"obs_18": 42.5, "obs_19": 7.0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Extra_Obs_Fields: tt.enabled}
+			report := Report{ReportType: "obs_st", Obs: [1][]float64{data}}
+
+			m := influx.New()
+			if err := parseObservation(cfg, report, m); err != nil {
+				t.Fatalf("parseObservation() error = %v", err)
+			}
+
+			for _, name := range []string{"obs_18", "obs_19"} {
+				want, ok := tt.want[name]
+				got, exists := m.Fields[name]
+				if ok != exists || got != want {
+					t.Errorf("Field %s = %v (present %v), want %v (present %v)", name, got, exists, want, ok)
+				}
+			}
+			if m.Fields["temp"] != 25.5 {
+				t.Errorf("Expected temp=25.5, got %v", m.Fields["temp"])
+			}
+		})
+	}
+}
+
 func TestParseRapidWindSuccess(t *testing.T) {
 	cfg := &config.Config{Debug: false}
 	report := Report{
@@ -144,15 +181,55 @@ func TestParseRapidWindSuccess(t *testing.T) {
 		t.Errorf("Expected timestamp 1640995200, got %d", m.Timestamp)
 	}
 
-	if m.Fields["rapid_wind_speed"] != "5.50" {
+	if m.Fields["rapid_wind_speed"] != 5.5 {
 		t.Errorf("Expected rapid_wind_speed=5.50, got %s", m.Fields["rapid_wind_speed"])
 	}
 
-	if m.Fields["rapid_wind_direction"] != "270" {
+	if m.Fields["rapid_wind_direction"] != int64(270) {
 		t.Errorf("Expected rapid_wind_direction=270, got %s", m.Fields["rapid_wind_direction"])
 	}
 }
 
+func TestParseRapidWindSubsecond(t *testing.T) {
+	report := Report{
+		ReportType:    "rapid_wind",
+		StationSerial: "ST-123456",
+		Ob:            [3]float64{1640995200, 5.5, 270},
+	}
+
+	m := influx.New()
+	if err := parseRapidWind(&config.Config{}, report, m); err != nil {
+		t.Fatalf("parseRapidWind() error = %v", err)
+	}
+	if m.Nanos != 0 {
+		t.Errorf("Expected no offset by default, got %d", m.Nanos)
+	}
+
+	cfg := &config.Config{Rapid_Wind_Subsecond: true}
+	first, second := influx.New(), influx.New()
+	if err := parseRapidWind(cfg, report, first); err != nil {
+		t.Fatalf("parseRapidWind() error = %v", err)
+	}
+	if err := parseRapidWind(cfg, report, second); err != nil {
+		t.Fatalf("parseRapidWind() error = %v", err)
+	}
+	if first.Nanos <= 0 || first.Nanos >= int64(time.Second) {
+		t.Errorf("Expected offset within the second, got %d", first.Nanos)
+	}
+	if first.Nanos != second.Nanos {
+		t.Errorf("Expected deterministic offset, got %d and %d", first.Nanos, second.Nanos)
+	}
+
+	other := influx.New()
+	report.StationSerial = "ST-654321"
+	if err := parseRapidWind(cfg, report, other); err != nil {
+		t.Fatalf("parseRapidWind() error = %v", err)
+	}
+	if other.Nanos == first.Nanos {
+		t.Errorf("Expected different devices to get different offsets")
+	}
+}
+
 func TestParseRapidWindInsufficientData(t *testing.T) {
 	// This test requires directly accessing the parser with a Report that has
 	// insufficient data. Since Ob is a fixed-size array [3]float64,
@@ -203,6 +280,30 @@ func TestParseValidObsStReport(t *testing.T) {
 	}
 }
 
+func TestParseStationTags(t *testing.T) {
+	cfg := &config.Config{
+		Rapid_Wind: true,
+		Stations: map[string]config.Station{
+			"st-123456": {Name: "Backyard", Location: "Boulder, CO"},
+		},
+	}
+	addr, _ := net.ResolveUDPAddr("udp", "192.168.1.100:50222")
+
+	data := `{"serial_number":"ST-123456","type":"rapid_wind","ob":[1640995200,2.5,180]}`
+	m, err := Parse(cfg, addr, []byte(data), len(data))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if m.Tags["station_name"] != "Backyard" || m.Tags["location"] != "Boulder, CO" {
+		t.Errorf("Expected name and location tags, got %v", m.Tags)
+	}
+
+	data = `{"serial_number":"ST-654321","type":"rapid_wind","ob":[1640995200,2.5,180]}`
+	if m, _ = Parse(cfg, addr, []byte(data), len(data)); len(m.Tags) != 1 {
+		t.Errorf("Expected only the station tag for an unnamed station, got %v", m.Tags)
+	}
+}
+
 func TestParseValidRapidWindReport(t *testing.T) {
 	cfg := &config.Config{
 		Debug:                    false,
@@ -264,6 +365,31 @@ func TestParseRapidWindDisabled(t *testing.T) {
 	}
 }
 
+func TestParseStrike(t *testing.T) {
+	cfg := &config.Config{Lightning: true, Influx_Bucket: "test-bucket"}
+	addr, _ := net.ResolveUDPAddr("udp", "192.168.1.100:50222")
+
+	jsonData := `{"serial_number": "ST-123456", "type": "evt_strike", "hub_sn": "HB-1", "evt": [1640995200, 12, 3848]}`
+	m, err := Parse(cfg, addr, []byte(jsonData), len(jsonData))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if m == nil {
+		t.Fatal("Expected non-nil InfluxData")
+	}
+	if m.Name != "lightning" || m.Timestamp != 1640995200 || m.Tags["station"] != "ST-123456" {
+		t.Errorf("Unexpected point %+v", m)
+	}
+	if m.Fields["strike_distance"] != 12.0 || m.Fields["strike_energy"] != int64(3848) {
+		t.Errorf("Unexpected fields %v", m.Fields)
+	}
+
+	short := `{"serial_number": "ST-123456", "type": "evt_strike", "evt": [1640995200]}`
+	if _, err := Parse(cfg, addr, []byte(short), len(short)); !errors.Is(err, ErrInsufficientData) {
+		t.Errorf("Expected ErrInsufficientData, got %v", err)
+	}
+}
+
 func TestParseIgnoredReportTypes(t *testing.T) {
 	cfg := &config.Config{Debug: false, Influx_Bucket: "test-bucket"}
 	addr, _ := net.ResolveUDPAddr("udp", "192.168.1.100:50222")
@@ -287,6 +413,78 @@ func TestParseIgnoredReportTypes(t *testing.T) {
 	}
 }
 
+func TestParseReportTypeFilter(t *testing.T) {
+	addr, _ := net.ResolveUDPAddr("udp", "192.168.1.100:50222")
+	obs := `{"serial_number": "ST-123456", "type": "obs_st", "obs": [[1640995200,0.5,1.2,2.1,270,3,1013.25,22.5,65,50000,3.5,500,0,0,0,0,2.6,1]]}`
+	strike := `{"serial_number": "ST-123456", "type": "evt_strike", "evt": [1640995200, 12, 3848]}`
+
+	tests := []struct {
+		name       string
+		include    []string
+		exclude    []string
+		wantObs    bool
+		wantStrike bool
+	}{
+		{"all", nil, nil, true, true},
+		{"include", []string{"evt_strike"}, nil, false, true},
+		{"exclude", nil, []string{"obs_st"}, false, true},
+		{"both", []string{"obs_st", "evt_strike"}, []string{"evt_strike"}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Lightning: true, Report_Types: tt.include, Exclude_Report_Types: tt.exclude}
+			for _, c := range []struct {
+				data string
+				want bool
+			}{{obs, tt.wantObs}, {strike, tt.wantStrike}} {
+				m, err := Parse(cfg, addr, []byte(c.data), len(c.data))
+				if err != nil {
+					t.Fatalf("Parse() error = %v", err)
+				}
+				if (m != nil) != c.want {
+					t.Errorf("Parse(%s) written = %v, want %v", c.data, m != nil, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	cfg := &config.Config{
+		Lightning:                true,
+		Rapid_Wind:               true,
+		Influx_Bucket:            "weather",
+		Influx_Bucket_Rapid_Wind: "rapid_wind",
+		Influx_Routes: []config.InfluxRoute{
+			{Types: []string{"evt_*"}, Bucket: "events", Org: "ops", Measurement: "strikes"},
+			{Stations: []string{"st-999999"}, Bucket: "garden"},
+		},
+	}
+	addr, _ := net.ResolveUDPAddr("udp", "192.168.1.100:50222")
+
+	tests := []struct {
+		data        string
+		bucket      string
+		measurement string
+	}{
+		{`{"serial_number": "ST-123456", "type": "evt_strike", "evt": [1640995200, 12, 3848]}`, "events", "strikes"},
+		{`{"serial_number": "ST-999999", "type": "rapid_wind", "ob": [1640995200, 5.5, 270]}`, "garden", "weather"},
+		{`{"serial_number": "ST-123456", "type": "rapid_wind", "ob": [1640995200, 5.5, 270]}`, "rapid_wind", "weather"},
+	}
+	for _, tt := range tests {
+		m, err := Parse(cfg, addr, []byte(tt.data), len(tt.data))
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if m.Bucket != tt.bucket || m.Name != tt.measurement {
+			t.Errorf("Parse(%s) = %s/%s, want %s/%s", tt.data, m.Bucket, m.Name, tt.bucket, tt.measurement)
+		}
+	}
+	if org := cfg.InfluxOrg("events"); org != "ops" {
+		t.Errorf("InfluxOrg(events) = %q, want ops", org)
+	}
+}
+
 func TestParseInvalidJSON(t *testing.T) {
 	cfg := &config.Config{Debug: false}
 	addr, _ := net.ResolveUDPAddr("udp", "192.168.1.100:50222")
@@ -356,3 +554,72 @@ func BenchmarkParseRapidWindReport(b *testing.B) {
 		_, _ = Parse(cfg, addr, []byte(jsonData), len(jsonData))
 	}
 }
+
+func TestParseHubTag(t *testing.T) {
+	jsonData := `{"serial_number":"ST-1","hub_sn":"HB-1","type":"rapid_wind","ob":[1640995200,5.5,270]}`
+	addr, _ := net.ResolveUDPAddr("udp", "192.168.1.100:50222")
+
+	for _, dedupe := range []bool{false, true} {
+		cfg := &config.Config{Rapid_Wind: true, Dedupe_Hubs: dedupe}
+		m, err := Parse(cfg, addr, []byte(jsonData), len(jsonData))
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if hub, ok := m.Tags["hub"]; ok != dedupe || (dedupe && hub != "HB-1") {
+			t.Errorf("With dedupe_hubs=%v expected hub tag present=%v, got %q", dedupe, dedupe, hub)
+		}
+	}
+}
+
+func TestParseRawUnknownTypes(t *testing.T) {
+	addr, _ := net.ResolveUDPAddr("udp", "192.168.1.100:50222")
+	data := `{"serial_number": "ST-123456", "type": "evt_future", "timestamp": 1640995200, "level": 3, "mode": "x"}`
+
+	m, err := Parse(&config.Config{Strict_Schema: true}, addr, []byte(data), len(data))
+	if err == nil || m != nil {
+		t.Fatalf("Expected unknown type to be rejected, got %v, %v", m, err)
+	}
+
+	m, err = Parse(&config.Config{Strict_Schema: true, Raw_Unknown_Types: true}, addr, []byte(data), len(data))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if m == nil {
+		t.Fatal("Expected a raw point")
+	}
+	if m.Name != RawMeasurement || m.Tags["type"] != "evt_future" || m.Tags["station"] != "ST-123456" {
+		t.Errorf("Unexpected point %s %v", m.Name, m.Tags)
+	}
+	if m.Timestamp != 1640995200 {
+		t.Errorf("Timestamp = %d, want 1640995200", m.Timestamp)
+	}
+	if m.Fields["level"] != 3.0 {
+		t.Errorf("level = %q, want 3", m.Fields["level"])
+	}
+	if _, ok := m.Fields["mode"]; ok {
+		t.Error("String values should only be kept in the payload")
+	}
+	want := `{"serial_number":"ST-123456","type":"evt_future","timestamp":1640995200,"level":3,"mode":"x"}`
+	if m.Fields["payload"] != want {
+		t.Errorf("payload = %s, want %s", m.Fields["payload"], want)
+	}
+
+	// Reports without a serial number are kept without a station tag
+	anonymous := `{"type": "evt_future", "timestamp": 1640995200}`
+	m, err = Parse(&config.Config{Raw_Unknown_Types: true}, addr, []byte(anonymous), len(anonymous))
+	if err != nil || m == nil {
+		t.Fatalf("Parse() = %v, %v, want a raw point", m, err)
+	}
+	if _, ok := m.Tags["station"]; ok {
+		t.Errorf("Expected no station tag, got %v", m.Tags)
+	}
+	if err := m.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	// Known report types without a layout are still dropped
+	hub := `{"serial_number": "HB-00000001", "type": "hub_status", "timestamp": 1640995200}`
+	if m, err := Parse(&config.Config{Raw_Unknown_Types: true}, addr, []byte(hub), len(hub)); err != nil || m != nil {
+		t.Errorf("Expected hub_status to be dropped, got %v, %v", m, err)
+	}
+}
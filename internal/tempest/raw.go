@@ -0,0 +1,59 @@
+package tempest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/ntp"
+)
+
+// RawMeasurement is the measurement of report types the parser does not know
+const RawMeasurement = "raw_tempest"
+
+// knownReportType reports whether reportType has a documented schema
+func knownReportType(reportType string) bool {
+	_, ok := schemas[reportType]
+	return ok
+}
+
+// parseRaw stores a report of an unknown type as it was received: the
+// compacted JSON is kept in the payload field, and top-level numbers are
+// extracted as fields of their own. The point is timestamped by the
+// timestamp key or the first observation value, or else by the time it
+// was parsed.
+func parseRaw(b []byte, report Report, m *influx.Data) error {
+	var payload bytes.Buffer
+	if err := json.Compact(&payload, b); err != nil {
+		return err
+	}
+	var values map[string]any
+	if err := json.Unmarshal(b, &values); err != nil {
+		return err
+	}
+	parseLogger().Debug("Passing through unknown report type",
+		"report_type", report.ReportType,
+		"station", report.StationSerial,
+		"bytes", payload.Len())
+
+	m.Fields = influx.Fields{"payload": payload.String()}
+	for key, v := range values {
+		if f, ok := v.(float64); ok && key != "timestamp" {
+			m.Fields[key] = f
+		}
+	}
+
+	switch v := mappedValues(report); {
+	case report.Timestamp > 0:
+		m.Timestamp = int64(report.Timestamp)
+	case len(v) > 0 && v[0] > 0:
+		m.Timestamp = int64(v[0])
+	default:
+		m.Timestamp = ntp.Now().Unix()
+	}
+	if m.Timestamp <= 0 {
+		return fmt.Errorf("%w: no timestamp", ErrInsufficientData)
+	}
+	return nil
+}
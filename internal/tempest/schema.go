@@ -0,0 +1,181 @@
+package tempest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrSchemaViolation is wrapped by SchemaError
+var ErrSchemaViolation = errors.New("report does not match schema")
+
+// SchemaError lists every problem found while validating a report
+type SchemaError struct {
+	ReportType string
+	Problems   []string
+}
+
+// Error implements error
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%v: %s: %s", ErrSchemaViolation, e.ReportType, strings.Join(e.Problems, "; "))
+}
+
+// Unwrap allows errors.Is(err, ErrSchemaViolation)
+func (e *SchemaError) Unwrap() error {
+	return ErrSchemaViolation
+}
+
+// valueRange is the plausible range of one value in an observation array
+type valueRange struct {
+	Name string
+	Min  float64
+	Max  float64
+}
+
+// reportSchema describes the expected shape of one report type
+type reportSchema struct {
+	// Required top-level keys
+	Required []string
+	// Key of the observation array, if any
+	Array string
+	// Nested is true when Array holds a list of observation arrays
+	Nested bool
+	// Values describes each element of the observation array in order
+	Values []valueRange
+}
+
+// Epoch seconds between 2000 and 2100
+var timestampRange = valueRange{"timestamp", 946684800, 4102444800}
+
+// schemas documents the UDP broadcast formats, see
+// https://weatherflow.github.io/Tempest/api/udp.html
+var schemas = map[string]reportSchema{
+	"obs_st": {
+		Required: []string{"serial_number", "hub_sn", "obs"},
+		Array:    "obs",
+		Nested:   true,
+		Values: []valueRange{
+			timestampRange,
+			{"wind_lull", 0, 100},
+			{"wind_avg", 0, 100},
+			{"wind_gust", 0, 100},
+			{"wind_direction", 0, 360},
+			{"wind_sample_interval", 0, 3600},
+			{"station_pressure", 300, 1100},
+			{"air_temperature", -60, 70},
+			{"relative_humidity", 0, 100},
+			{"illuminance", 0, 200000},
+			{"uv", 0, 20},
+			{"solar_radiation", 0, 1600},
+			{"precipitation", 0, 500},
+			{"precipitation_type", 0, 3},
+			{"strike_distance", 0, 100},
+			{"strike_count", 0, 100000},
+			{"battery", 0, 5},
+			{"report_interval", 0, 60},
+		},
+	},
+	"rapid_wind": {
+		Required: []string{"serial_number", "hub_sn", "ob"},
+		Array:    "ob",
+		Values: []valueRange{
+			timestampRange,
+			{"wind_speed", 0, 100},
+			{"wind_direction", 0, 360},
+		},
+	},
+	"evt_precip": {
+		Required: []string{"serial_number", "hub_sn", "evt"},
+		Array:    "evt",
+		Values:   []valueRange{timestampRange},
+	},
+	"evt_strike": {
+		Required: []string{"serial_number", "hub_sn", "evt"},
+		Array:    "evt",
+		Values: []valueRange{
+			timestampRange,
+			{"distance", 0, 100},
+			{"energy", 0, 1e9},
+		},
+	},
+	"hub_status": {
+		Required: []string{"serial_number", "firmware_revision", "uptime", "rssi", "timestamp", "reset_flags", "seq"},
+	},
+	"device_status": {
+		Required: []string{"serial_number", "hub_sn", "timestamp", "uptime", "voltage", "firmware_revision", "rssi", "hub_rssi", "sensor_status", "debug"},
+	},
+}
+
+// ValidateSchema checks a raw report against the schema of its type and
+// returns a *SchemaError describing every deviation
+func ValidateSchema(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return &SchemaError{ReportType: "unknown", Problems: []string{"not a JSON object: " + err.Error()}}
+	}
+
+	var reportType string
+	if t, ok := raw["type"]; !ok || json.Unmarshal(t, &reportType) != nil || reportType == "" {
+		return &SchemaError{ReportType: "unknown", Problems: []string{`missing or invalid "type" key`}}
+	}
+
+	schema, ok := schemas[reportType]
+	if !ok {
+		return &SchemaError{ReportType: reportType, Problems: []string{"no schema for report type"}}
+	}
+
+	var problems []string
+	for _, key := range schema.Required {
+		if _, ok := raw[key]; !ok {
+			problems = append(problems, fmt.Sprintf("missing required key %q", key))
+		}
+	}
+
+	if schema.Array != "" {
+		if values, ok := raw[schema.Array]; ok {
+			problems = append(problems, validateArray(schema, values)...)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &SchemaError{ReportType: reportType, Problems: problems}
+	}
+	return nil
+}
+
+// validateArray checks the observation array of a report
+func validateArray(schema reportSchema, data json.RawMessage) []string {
+	var values []*float64
+	if schema.Nested {
+		var rows [][]*float64
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return []string{fmt.Sprintf("%q is not a list of numeric arrays: %v", schema.Array, err)}
+		}
+		if len(rows) == 0 {
+			return []string{fmt.Sprintf("%q is empty", schema.Array)}
+		}
+		values = rows[0]
+	} else if err := json.Unmarshal(data, &values); err != nil {
+		return []string{fmt.Sprintf("%q is not a numeric array: %v", schema.Array, err)}
+	}
+
+	var problems []string
+	if len(values) < len(schema.Values) {
+		problems = append(problems, fmt.Sprintf("%q has %d values, expected %d", schema.Array, len(values), len(schema.Values)))
+	}
+
+	for i, r := range schema.Values {
+		if i >= len(values) {
+			break
+		}
+		v := values[i]
+		switch {
+		case v == nil:
+			problems = append(problems, fmt.Sprintf("%s (index %d) is null", r.Name, i))
+		case *v < r.Min || *v > r.Max:
+			problems = append(problems, fmt.Sprintf("%s (index %d) = %g outside [%g, %g]", r.Name, i, *v, r.Min, r.Max))
+		}
+	}
+	return problems
+}
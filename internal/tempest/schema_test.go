@@ -0,0 +1,108 @@
+package tempest
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+)
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		problems []string
+	}{
+		{
+			name: "valid obs_st",
+			data: `{"serial_number":"ST-1","hub_sn":"HB-1","type":"obs_st","obs":[[1640995200,1.5,2.3,3.8,180,3,1013.25,25.5,65.0,50000,5.2,800,0.5,0,5,2,3.7,1]]}`,
+		},
+		{
+			name: "valid rapid_wind",
+			data: `{"serial_number":"ST-1","hub_sn":"HB-1","type":"rapid_wind","ob":[1640995200,5.5,270]}`,
+		},
+		{
+			name:     "missing keys",
+			data:     `{"type":"rapid_wind","ob":[1640995200,5.5,270]}`,
+			problems: []string{`missing required key "serial_number"`, `missing required key "hub_sn"`},
+		},
+		{
+			name:     "short observation",
+			data:     `{"serial_number":"ST-1","hub_sn":"HB-1","type":"obs_st","obs":[[1640995200,1.5,2.3]]}`,
+			problems: []string{`"obs" has 3 values, expected 18`},
+		},
+		{
+			name: "out of range and null",
+			data: `{"serial_number":"ST-1","hub_sn":"HB-1","type":"obs_st","obs":[[1640995200,1.5,2.3,3.8,400,3,20,null,65.0,50000,5.2,800,0.5,0,5,2,3.7,1]]}`,
+			problems: []string{
+				"wind_direction (index 4) = 400 outside [0, 360]",
+				"station_pressure (index 6) = 20 outside [300, 1100]",
+				"air_temperature (index 7) is null",
+			},
+		},
+		{
+			name:     "wrong array type",
+			data:     `{"serial_number":"ST-1","hub_sn":"HB-1","type":"rapid_wind","ob":"fast"}`,
+			problems: []string{`"ob" is not a numeric array`},
+		},
+		{
+			name:     "unknown type",
+			data:     `{"type":"obs_future"}`,
+			problems: []string{"no schema for report type"},
+		},
+		{
+			name:     "missing type",
+			data:     `{"serial_number":"ST-1"}`,
+			problems: []string{`missing or invalid "type" key`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSchema([]byte(tt.data))
+			if len(tt.problems) == 0 {
+				if err != nil {
+					t.Fatalf("ValidateSchema() error = %v", err)
+				}
+				return
+			}
+
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("Expected *SchemaError, got %v", err)
+			}
+			if !errors.Is(err, ErrSchemaViolation) {
+				t.Error("Expected error to wrap ErrSchemaViolation")
+			}
+			if len(schemaErr.Problems) != len(tt.problems) {
+				t.Fatalf("Expected %d problems, got %v", len(tt.problems), schemaErr.Problems)
+			}
+			for i, want := range tt.problems {
+				if !strings.HasPrefix(schemaErr.Problems[i], want) {
+					t.Errorf("Problem %d = %q, want prefix %q", i, schemaErr.Problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseStrictSchema(t *testing.T) {
+	addr, _ := net.ResolveUDPAddr("udp", "192.168.1.100:50222")
+	jsonData := `{"serial_number":"ST-1","type":"obs_st","obs":[[1640995200,1.5,2.3,3.8,180,3,1013.25,25.5,65.0,50000,5.2,800,0.5,0,5,2,3.7,1]]}`
+
+	lenient := &config.Config{Influx_Bucket: "test-bucket"}
+	if m, err := Parse(lenient, addr, []byte(jsonData), len(jsonData)); err != nil || m == nil {
+		t.Fatalf("Expected lenient parse to succeed, got %v, %v", m, err)
+	}
+
+	strict := &config.Config{Influx_Bucket: "test-bucket", Strict_Schema: true}
+	m, err := Parse(strict, addr, []byte(jsonData), len(jsonData))
+	if !errors.Is(err, ErrSchemaViolation) {
+		t.Fatalf("Expected schema violation, got %v", err)
+	}
+	if m != nil {
+		t.Error("Expected nil InfluxData for rejected packet")
+	}
+}
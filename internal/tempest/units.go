@@ -0,0 +1,167 @@
+package tempest
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+	"github.com/jacaudi/tempest-influxdb/internal/meteo"
+)
+
+// quantity is a physical quantity whose fields share a unit
+type quantity int
+
+const (
+	temperature quantity = iota
+	speed
+	pressure
+	rainfall
+	distance
+	height
+)
+
+// quantityUnits lists the units each quantity can be written in, starting
+// with the metric unit the parser produces
+var quantityUnits = map[quantity][]string{
+	temperature: {"c", "f"},
+	speed:       {"m/s", "mph", "km/h", "kn"},
+	pressure:    {"hpa", "mb", "inhg"},
+	rainfall:    {"mm", "in"},
+	distance:    {"km", "mi"},
+	height:      {"m", "ft"},
+}
+
+// imperialUnits are the units of the imperial system
+var imperialUnits = map[quantity]string{
+	temperature: "f",
+	speed:       "mph",
+	pressure:    "inhg",
+	rainfall:    "in",
+	distance:    "mi",
+	height:      "ft",
+}
+
+// unitSymbols are the display symbols of the units
+var unitSymbols = map[string]string{
+	"c": "°C", "f": "°F",
+	"m/s": "m/s", "mph": "mph", "km/h": "km/h", "kn": "kn",
+	"hpa": "hPa", "mb": "mbar", "inhg": "inHg",
+	"mm": "mm", "in": "in",
+	"km": "km", "mi": "mi",
+	"m": "m", "ft": "ft",
+}
+
+// fieldQuantities are the fields that can be converted
+var fieldQuantities = map[string]quantity{
+	"temp":                      temperature,
+	"dew_point":                 temperature,
+	"humidex":                   temperature,
+	"feels_like":                temperature,
+	"heat_index":                temperature,
+	"wind_chill":                temperature,
+	"temp_min_today":            temperature,
+	"temp_max_today":            temperature,
+	"temp_min":                  temperature,
+	"temp_max":                  temperature,
+	"wind_avg":                  speed,
+	"wind_gust":                 speed,
+	"wind_lull":                 speed,
+	"rapid_wind_speed":          speed,
+	"rapid_wind_speed_min":      speed,
+	"rapid_wind_speed_avg":      speed,
+	"rapid_wind_speed_max":      speed,
+	"wind_avg_smoothed":         speed,
+	"rapid_wind_speed_smoothed": speed,
+	"p":                         pressure,
+	"p_raw":                     pressure,
+	"sea_level_pressure":        pressure,
+	"pressure_trend":            pressure,
+	"precipitation":             rainfall,
+	"rain_today":                rainfall,
+	"rain_last_hour":            rainfall,
+	"et0_rate":                  rainfall,
+	"et0_last_hour":             rainfall,
+	"et0_today":                 rainfall,
+	"strike_distance":           distance,
+	"cloud_base_height":         height,
+}
+
+// FieldUnits returns the unit, such as "f" or "mph", of every field cfg
+// writes in a unit other than metric
+func FieldUnits(cfg *config.Config) (map[string]string, error) {
+	units := make(map[string]string)
+	if cfg.Units == config.UnitsImperial {
+		for field, q := range fieldQuantities {
+			units[field] = imperialUnits[q]
+		}
+	}
+	for field, unit := range cfg.Field_Units {
+		q, ok := fieldQuantities[field]
+		if !ok {
+			return nil, fmt.Errorf("field %s in field_units cannot be converted", field)
+		}
+		unit = strings.ToLower(unit)
+		if !slices.Contains(quantityUnits[q], unit) {
+			return nil, fmt.Errorf("unit %q of field %s is not one of %s", unit, field, strings.Join(quantityUnits[q], ", "))
+		}
+		units[field] = unit
+	}
+	for field, unit := range units {
+		if unit == quantityUnits[fieldQuantities[field]][0] {
+			delete(units, field)
+		}
+	}
+	return units, nil
+}
+
+// UnitsEnabled reports whether cfg writes any field in a non-metric unit
+func UnitsEnabled(cfg *config.Config) bool {
+	return cfg.Units == config.UnitsImperial || len(cfg.Field_Units) > 0
+}
+
+// FieldUnit returns the symbol of the unit field is written in with cfg,
+// or false if the field has no convertible unit
+func FieldUnit(cfg *config.Config, field string) (string, bool) {
+	q, ok := fieldQuantities[field]
+	if !ok {
+		return "", false
+	}
+	unit := quantityUnits[q][0]
+	if units, err := FieldUnits(cfg); err == nil && units[field] != "" {
+		unit = units[field]
+	}
+	return unitSymbols[unit], true
+}
+
+// UnitConverter converts the metric fields of points to the configured
+// units. It runs after every enricher that computes with the metric values.
+type UnitConverter struct {
+	units map[string]string
+}
+
+// NewUnitConverter creates a UnitConverter for the units of cfg
+func NewUnitConverter(cfg *config.Config) (*UnitConverter, error) {
+	units, err := FieldUnits(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &UnitConverter{units: units}, nil
+}
+
+// Enrich converts the fields of every point in place
+func (c *UnitConverter) Enrich(points []*influx.Data) []*influx.Data {
+	for _, m := range points {
+		for field, unit := range c.units {
+			value, ok := m.Float(field)
+			if !ok {
+				continue
+			}
+			if converted, ok := meteo.FromMetric(unit, value); ok {
+				m.Fields[field] = influx.Round(converted, 2)
+			}
+		}
+	}
+	return points
+}
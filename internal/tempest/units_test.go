@@ -0,0 +1,73 @@
+package tempest
+
+import (
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+func TestUnitConverterImperial(t *testing.T) {
+	cfg := &config.Config{Units: config.UnitsImperial, Field_Units: map[string]string{"wind_gust": "kn", "p": "hPa"}}
+	c, err := NewUnitConverter(cfg)
+	if err != nil {
+		t.Fatalf("NewUnitConverter() error = %v", err)
+	}
+
+	m := influx.New()
+	m.Fields = influx.Fields{
+		"temp":              20.0,
+		"wind_avg":          10.0,
+		"wind_gust":         10.0,
+		"p":                 1013.25,
+		"precipitation":     25.4,
+		"strike_distance":   16.0,
+		"cloud_base_height": 1250.0,
+		"relative_humidity": 50.0,
+	}
+	c.Enrich([]*influx.Data{m})
+
+	want := map[string]float64{
+		"temp":              68.0,
+		"wind_avg":          22.37,
+		"wind_gust":         19.44,
+		"p":                 1013.25,
+		"precipitation":     1.0,
+		"strike_distance":   9.94,
+		"cloud_base_height": 4101.05,
+		"relative_humidity": 50.0,
+	}
+	for field, v := range want {
+		if m.Fields[field] != v {
+			t.Errorf("%s = %v, want %v", field, m.Fields[field], v)
+		}
+	}
+}
+
+func TestUnitConverterInvalid(t *testing.T) {
+	tests := map[string]map[string]string{
+		"unknown field":   {"uv": "f"},
+		"mismatched unit": {"temp": "mph"},
+		"unknown unit":    {"precipitation": "cubit"},
+	}
+	for name, units := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewUnitConverter(&config.Config{Units: config.UnitsCustom, Field_Units: units}); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestFieldUnit(t *testing.T) {
+	cfg := &config.Config{Units: config.UnitsImperial}
+	if unit, ok := FieldUnit(cfg, "temp"); !ok || unit != "°F" {
+		t.Errorf("FieldUnit(temp) = %q, %v", unit, ok)
+	}
+	if unit, ok := FieldUnit(&config.Config{}, "wind_avg"); !ok || unit != "m/s" {
+		t.Errorf("FieldUnit(wind_avg) = %q, %v", unit, ok)
+	}
+	if _, ok := FieldUnit(cfg, "uv"); ok {
+		t.Error("Expected uv to have no convertible unit")
+	}
+}
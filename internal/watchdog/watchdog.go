@@ -0,0 +1,115 @@
+// Package watchdog watches the collector's own resource usage so that leaks
+// on long-running installs are noticed, and optionally acted on, before the
+// host runs out of memory
+package watchdog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+// Strikes is the number of consecutive checks a limit must be exceeded
+// before the pipeline is restarted, so that short bursts are tolerated
+const Strikes = 3
+
+// Usage is one sample of the resources being watched
+type Usage struct {
+	Goroutines int
+	HeapBytes  uint64
+	Queue      int
+}
+
+// Watchdog periodically samples resource usage and compares it with the
+// configured limits. A limit of zero is not checked.
+type Watchdog struct {
+	cfg     *config.Config
+	logger  *logger.AppLogger
+	queue   func() int
+	restart func()
+	sample  func() Usage
+
+	strikes int
+}
+
+// New creates a Watchdog. queue reports the number of packets waiting to be
+// processed; restart, if not nil and Watchdog_Restart is set, is called when
+// a limit stays exceeded.
+func New(cfg *config.Config, appLogger *logger.AppLogger, queue func() int, restart func()) *Watchdog {
+	w := &Watchdog{
+		cfg:     cfg,
+		logger:  appLogger,
+		queue:   queue,
+		restart: restart,
+	}
+	w.sample = w.readUsage
+	return w
+}
+
+// Run checks usage every Watchdog_Interval until ctx is cancelled
+func (w *Watchdog) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Watchdog_Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Check()
+		}
+	}
+}
+
+// Check samples usage once, logs exceeded limits and restarts the pipeline
+// after Strikes consecutive breaches. It reports whether a limit was
+// exceeded.
+func (w *Watchdog) Check() bool {
+	usage := w.sample()
+	var exceeded []string
+	if limit := w.cfg.Watchdog_Max_Goroutines; limit > 0 && usage.Goroutines > limit {
+		exceeded = append(exceeded, "goroutines")
+	}
+	if limit := w.cfg.Watchdog_Max_Heap_MB; limit > 0 && usage.HeapBytes > uint64(limit)<<20 {
+		exceeded = append(exceeded, "heap")
+	}
+	if limit := w.cfg.Watchdog_Max_Queue; limit > 0 && usage.Queue > limit {
+		exceeded = append(exceeded, "queue")
+	}
+
+	attrs := []any{
+		slog.Int("goroutines", usage.Goroutines),
+		slog.Uint64("heap_mb", usage.HeapBytes>>20),
+		slog.Int("queue", usage.Queue),
+	}
+	if len(exceeded) == 0 {
+		w.strikes = 0
+		w.logger.Debug("Resource usage", attrs...)
+		return false
+	}
+
+	w.strikes++
+	w.logger.Warn("Resource limit exceeded", append(attrs,
+		slog.Any("limits", exceeded),
+		slog.Int("strikes", w.strikes))...)
+	if w.cfg.Watchdog_Restart && w.restart != nil && w.strikes >= Strikes {
+		w.logger.Error("Restarting pipeline after repeated resource limit breaches", slog.Any("limits", exceeded))
+		w.strikes = 0
+		w.restart()
+	}
+	return true
+}
+
+// readUsage samples the running process
+func (w *Watchdog) readUsage() Usage {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	usage := Usage{Goroutines: runtime.NumGoroutine(), HeapBytes: mem.HeapAlloc}
+	if w.queue != nil {
+		usage.Queue = w.queue()
+	}
+	return usage
+}
@@ -0,0 +1,61 @@
+package watchdog
+
+import (
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+)
+
+func TestCheck(t *testing.T) {
+	cfg := &config.Config{
+		Watchdog_Max_Goroutines: 100,
+		Watchdog_Max_Heap_MB:    64,
+		Watchdog_Restart:        true,
+	}
+	var restarts int
+	w := New(cfg, logger.New(&config.Config{}), nil, func() { restarts++ })
+
+	usage := Usage{Goroutines: 10, HeapBytes: 10 << 20, Queue: 5000}
+	w.sample = func() Usage { return usage }
+
+	if w.Check() {
+		t.Error("Expected usage within limits; the queue has no limit")
+	}
+
+	usage.HeapBytes = 65 << 20
+	for i := 1; i < Strikes; i++ {
+		if !w.Check() {
+			t.Fatal("Expected heap limit to be exceeded")
+		}
+	}
+	if restarts != 0 {
+		t.Fatalf("Expected no restart before %d strikes", Strikes)
+	}
+	w.Check()
+	if restarts != 1 {
+		t.Errorf("Expected a restart after %d strikes, got %d", Strikes, restarts)
+	}
+
+	// A good sample resets the count
+	usage.HeapBytes = 1 << 20
+	w.Check()
+	usage.Goroutines = 101
+	for range Strikes - 1 {
+		w.Check()
+	}
+	if restarts != 1 {
+		t.Errorf("Expected strikes to reset after usage recovered, got %d restarts", restarts)
+	}
+}
+
+func TestCheckWithoutRestart(t *testing.T) {
+	w := New(&config.Config{Watchdog_Max_Queue: 1}, logger.New(&config.Config{}), func() int { return 2 }, func() {
+		t.Error("Expected no restart without Watchdog_Restart")
+	})
+	for range Strikes + 1 {
+		if !w.Check() {
+			t.Fatal("Expected queue limit to be exceeded")
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Package weatherflow is a minimal client for the WeatherFlow Tempest cloud
+// REST API
+package weatherflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultBaseURL is the root of the WeatherFlow REST API
+const DefaultBaseURL = "https://swd.weatherflow.com/swd/rest"
+
+// HTTPClient is the subset of http.Client used by Client
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Client calls the REST API with a personal access token
+type Client struct {
+	BaseURL string
+	token   string
+	client  HTTPClient
+}
+
+// NewClient creates a Client authenticating with token
+func NewClient(token string, client HTTPClient) *Client {
+	return &Client{BaseURL: DefaultBaseURL, token: token, client: client}
+}
+
+// StationObservation is the latest observation of a station as derived by
+// the cloud, including Rain Check corrected rain totals. Values are metric.
+type StationObservation struct {
+	Timestamp int64 `json:"timestamp"`
+	// PrecipYesterdayFinal is yesterday's rain in mm after Rain Check
+	// analysis; nil until the analysis is available
+	PrecipYesterdayFinal *float64 `json:"precip_accum_local_yesterday_final"`
+	// PrecipAnalysisYesterday is 0 without Rain Check analysis, 1 when the
+	// corrected value is displayed and 2 when it is computed but not shown
+	PrecipAnalysisYesterday int `json:"precip_analysis_type_yesterday"`
+}
+
+// StationObservations is the response of /observations/station
+type StationObservations struct {
+	StationID int                  `json:"station_id"`
+	Timezone  string               `json:"timezone"`
+	Obs       []StationObservation `json:"obs"`
+}
+
+// LatestStationObservation returns the current observation of the station
+// with the cloud ID stationID
+func (c *Client) LatestStationObservation(ctx context.Context, stationID int) (*StationObservations, error) {
+	var result StationObservations
+	if err := c.get(ctx, fmt.Sprintf("/observations/station/%d", stationID), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Station is the metadata of a station as configured in the Tempest app
+type Station struct {
+	StationID int     `json:"station_id"`
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Timezone  string  `json:"timezone"`
+	Meta      struct {
+		// Elevation above sea level in meters
+		Elevation float64 `json:"elevation"`
+	} `json:"station_meta"`
+	Devices []Device `json:"devices"`
+}
+
+// Device is a device of a station, such as a Tempest or its hub
+type Device struct {
+	DeviceID     int    `json:"device_id"`
+	SerialNumber string `json:"serial_number"`
+	DeviceType   string `json:"device_type"`
+}
+
+// DeviceID returns the cloud ID of the station's device with serial, or 0
+// if the station has no such device
+func (s *Station) DeviceID(serial string) int {
+	for _, d := range s.Devices {
+		if strings.EqualFold(d.SerialNumber, serial) {
+			return d.DeviceID
+		}
+	}
+	return 0
+}
+
+// DeviceObservation is the response of /observations/device: the latest
+// observation of a device in the layout of its UDP broadcast
+type DeviceObservation struct {
+	DeviceID int         `json:"device_id"`
+	Type     string      `json:"type"`
+	Obs      [][]float64 `json:"obs"`
+}
+
+// LatestDeviceObservation returns the current observation of the device
+// with the cloud ID deviceID
+func (c *Client) LatestDeviceObservation(ctx context.Context, deviceID int) (*DeviceObservation, error) {
+	var result DeviceObservation
+	if err := c.get(ctx, fmt.Sprintf("/observations/device/%d", deviceID), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Stations is the response of /stations
+type Stations struct {
+	Stations []Station `json:"stations"`
+}
+
+// Station returns the metadata of the station with the cloud ID stationID
+func (c *Client) Station(ctx context.Context, stationID int) (*Station, error) {
+	var result Stations
+	if err := c.get(ctx, fmt.Sprintf("/stations/%d", stationID), nil, &result); err != nil {
+		return nil, err
+	}
+	for i, s := range result.Stations {
+		if s.StationID == stationID {
+			return &result.Stations[i], nil
+		}
+	}
+	return nil, fmt.Errorf("WeatherFlow API returned no station %d", stationID)
+}
+
+// get requests path and decodes the JSON response into v
+func (c *Client) get(ctx context.Context, path string, query url.Values, v any) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("token", c.token)
+	u := strings.TrimSuffix(c.BaseURL, "/") + path + "?" + query.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("calling WeatherFlow API %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("WeatherFlow API %s returned %s: %s", path, resp.Status, strings.TrimSpace(string(message)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding WeatherFlow API %s response: %w", path, err)
+	}
+	return nil
+}
+
+// HourlyForecast is one hour of a station forecast
+type HourlyForecast struct {
+	Time              int64    `json:"time"`
+	Conditions        string   `json:"conditions"`
+	AirTemperature    *float64 `json:"air_temperature"`
+	FeelsLike         *float64 `json:"feels_like"`
+	RelativeHumidity  *float64 `json:"relative_humidity"`
+	SeaLevelPressure  *float64 `json:"sea_level_pressure"`
+	Precip            *float64 `json:"precip"`
+	PrecipProbability *float64 `json:"precip_probability"`
+	WindAvg           *float64 `json:"wind_avg"`
+	WindDirection     *float64 `json:"wind_direction"`
+	WindGust          *float64 `json:"wind_gust"`
+	UV                *float64 `json:"uv"`
+}
+
+// DailyForecast is one day of a station forecast
+type DailyForecast struct {
+	DayStartLocal     int64    `json:"day_start_local"`
+	Conditions        string   `json:"conditions"`
+	AirTempHigh       *float64 `json:"air_temp_high"`
+	AirTempLow        *float64 `json:"air_temp_low"`
+	PrecipProbability *float64 `json:"precip_probability"`
+	Sunrise           int64    `json:"sunrise"`
+	Sunset            int64    `json:"sunset"`
+}
+
+// Forecast is the response of /better_forecast
+type Forecast struct {
+	Timezone string `json:"timezone"`
+	Forecast struct {
+		Daily  []DailyForecast  `json:"daily"`
+		Hourly []HourlyForecast `json:"hourly"`
+	} `json:"forecast"`
+}
+
+// BetterForecast returns the hourly and daily forecast of the station with
+// the cloud ID stationID in metric units (°C, m/s, hPa, mm)
+func (c *Client) BetterForecast(ctx context.Context, stationID int) (*Forecast, error) {
+	query := url.Values{
+		"station_id":     {fmt.Sprint(stationID)},
+		"units_temp":     {"c"},
+		"units_wind":     {"mps"},
+		"units_pressure": {"mb"},
+		"units_precip":   {"mm"},
+		"units_distance": {"km"},
+	}
+	var result Forecast
+	if err := c.get(ctx, "/better_forecast", query, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,89 @@
+package weatherflow
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLatestStationObservation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/observations/station/1234" || r.URL.Query().Get("token") != "tok" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"station_id":1234,"timezone":"America/Denver","obs":[{"timestamp":1700000000,"precip_accum_local_yesterday_final":3.25,"precip_analysis_type_yesterday":1}]}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient("tok", srv.Client())
+	c.BaseURL = srv.URL
+	result, err := c.LatestStationObservation(context.Background(), 1234)
+	if err != nil {
+		t.Fatalf("LatestStationObservation() error = %v", err)
+	}
+	if result.Timezone != "America/Denver" || len(result.Obs) != 1 {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+	if obs := result.Obs[0]; obs.PrecipYesterdayFinal == nil || *obs.PrecipYesterdayFinal != 3.25 || obs.PrecipAnalysisYesterday != 1 {
+		t.Errorf("Unexpected observation %+v", obs)
+	}
+
+	if _, err := c.LatestStationObservation(context.Background(), 99); err == nil {
+		t.Error("Expected error for unknown station")
+	}
+}
+
+func TestBetterForecast(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/better_forecast" || q.Get("station_id") != "1234" || q.Get("units_temp") != "c" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"timezone":"America/Denver","forecast":{"daily":[{"day_start_local":1700000000,"conditions":"Clear","air_temp_high":12.5}],"hourly":[{"time":1700003600,"air_temperature":8.1},{"time":1700007200}]}}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient("tok", srv.Client())
+	c.BaseURL = srv.URL
+	result, err := c.BetterForecast(context.Background(), 1234)
+	if err != nil {
+		t.Fatalf("BetterForecast() error = %v", err)
+	}
+	if len(result.Forecast.Daily) != 1 || len(result.Forecast.Hourly) != 2 {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+	if h := result.Forecast.Hourly[0]; h.AirTemperature == nil || *h.AirTemperature != 8.1 {
+		t.Errorf("Unexpected hour %+v", h)
+	}
+	if h := result.Forecast.Hourly[1]; h.AirTemperature != nil {
+		t.Errorf("Expected missing temperature to stay nil, got %v", *h.AirTemperature)
+	}
+}
+
+func TestStation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/stations/1234" || r.URL.Query().Get("token") != "tok" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"stations":[{"station_id":1234,"name":"Backyard","latitude":39.74,"longitude":-104.99,"timezone":"America/Denver","station_meta":{"elevation":1609.3}}]}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient("tok", srv.Client())
+	c.BaseURL = srv.URL
+	s, err := c.Station(context.Background(), 1234)
+	if err != nil {
+		t.Fatalf("Station() error = %v", err)
+	}
+	if s.Name != "Backyard" || s.Latitude != 39.74 || s.Longitude != -104.99 || s.Timezone != "America/Denver" || s.Meta.Elevation != 1609.3 {
+		t.Errorf("Unexpected station %+v", s)
+	}
+
+	if _, err := c.Station(context.Background(), 99); err == nil {
+		t.Error("Expected error for unknown station")
+	}
+}
@@ -0,0 +1,191 @@
+// Package webhook posts observations to an arbitrary HTTP endpoint with a
+// body rendered from a Go template
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/encoding"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+// HTTPClient is the subset of http.Client used by the webhook
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Point is the template data of a single observation
+type Point struct {
+	Station     string
+	ReportType  string
+	Measurement string
+	Timestamp   int64
+	Time        time.Time
+	Tags        map[string]string
+	Fields      influx.Fields
+}
+
+// Batch is the template data in batch mode
+type Batch struct {
+	Points []Point
+}
+
+// funcs are available to webhook templates in addition to the builtins
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Webhook is an output that POSTs observations to Webhook_URL, one request
+// per point or, with Webhook_Batch, one per batch of points
+type Webhook struct {
+	cfg      *config.Config
+	client   HTTPClient
+	template *template.Template
+	encoder  encoding.Encoder
+}
+
+// New creates a Webhook for the webhook settings of cfg
+func New(cfg *config.Config, client HTTPClient) (*Webhook, error) {
+	w := &Webhook{cfg: cfg, client: client}
+	if cfg.Webhook_Template == "" {
+		enc, err := encoding.New(config.EncodingJSON, cfg)
+		if err != nil {
+			return nil, err
+		}
+		w.encoder = enc
+		return w, nil
+	}
+
+	t, err := template.New("webhook").Funcs(funcs).Parse(cfg.Webhook_Template)
+	if err != nil {
+		return nil, fmt.Errorf("parsing webhook template: %w", err)
+	}
+	w.template = t
+	return w, nil
+}
+
+// Name implements processor.Output
+func (w *Webhook) Name() string { return "webhook" }
+
+// Write implements processor.Output
+func (w *Webhook) Write(ctx context.Context, points []*influx.Data) error {
+	var selected []*influx.Data
+	for _, p := range points {
+		if len(w.cfg.Webhook_Report_Types) == 0 || slices.Contains(w.cfg.Webhook_Report_Types, p.ReportType) {
+			selected = append(selected, p)
+		}
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+
+	if w.cfg.Webhook_Batch {
+		body, err := w.renderBatch(selected)
+		if err != nil {
+			return err
+		}
+		return w.post(ctx, body)
+	}
+	for _, p := range selected {
+		body, err := w.render(p)
+		if err != nil {
+			return err
+		}
+		if err := w.post(ctx, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// render returns the request body for a single point
+func (w *Webhook) render(p *influx.Data) ([]byte, error) {
+	if w.template == nil {
+		return w.encoder.Encode(p)
+	}
+	var b bytes.Buffer
+	if err := w.template.Execute(&b, newPoint(p)); err != nil {
+		return nil, fmt.Errorf("rendering webhook template: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// renderBatch returns the request body for a batch of points; without a
+// template it is a JSON array of observations
+func (w *Webhook) renderBatch(points []*influx.Data) ([]byte, error) {
+	if w.template == nil {
+		items := make([]json.RawMessage, 0, len(points))
+		for _, p := range points {
+			item, err := w.encoder.Encode(p)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return json.Marshal(items)
+	}
+
+	batch := Batch{Points: make([]Point, 0, len(points))}
+	for _, p := range points {
+		batch.Points = append(batch.Points, newPoint(p))
+	}
+	var b bytes.Buffer
+	if err := w.template.Execute(&b, batch); err != nil {
+		return nil, fmt.Errorf("rendering webhook template: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// post sends body to the webhook URL
+func (w *Webhook) post(ctx context.Context, body []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.Webhook_URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating webhook request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range w.cfg.Webhook_Headers {
+		request.Header.Set(name, value)
+	}
+	if w.cfg.Webhook_Username != "" {
+		request.SetBasicAuth(w.cfg.Webhook_Username, w.cfg.Webhook_Password)
+	}
+
+	resp, err := w.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("posting to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// newPoint converts p into template data
+func newPoint(p *influx.Data) Point {
+	return Point{
+		Station:     p.Tags["station"],
+		ReportType:  p.ReportType,
+		Measurement: p.Name,
+		Timestamp:   p.Timestamp,
+		Time:        time.Unix(p.Timestamp, p.Nanos).UTC(),
+		Tags:        p.Tags,
+		Fields:      p.Fields,
+	}
+}
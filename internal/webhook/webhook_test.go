@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+type request struct {
+	body   string
+	header http.Header
+}
+
+func newServer(t *testing.T, status int) (*httptest.Server, *[]request) {
+	t.Helper()
+	var requests []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		requests = append(requests, request{body: string(b), header: r.Header.Clone()})
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func point(reportType string, temp float64) *influx.Data {
+	m := influx.New()
+	m.Name = "weather"
+	m.ReportType = reportType
+	m.Timestamp = 1700000000
+	m.Tags["station"] = "ST-1"
+	m.Fields["temp"] = temp
+	return m
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	srv, requests := newServer(t, http.StatusOK)
+	w, err := New(&config.Config{
+		Webhook_URL:          srv.URL,
+		Webhook_Template:     `{"value1":"{{.Station}}","value2":"{{index .Fields "temp"}}","value3":"{{.Time.Format "15:04"}}"}`,
+		Webhook_Headers:      map[string]string{"Authorization": "Bearer secret"},
+		Webhook_Report_Types: []string{"obs_st"},
+	}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Write(context.Background(), []*influx.Data{point("obs_st", 21.5), point("rapid_wind", 0)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("Expected one request for the obs_st point, got %d", len(*requests))
+	}
+	got := (*requests)[0]
+	if want := `{"value1":"ST-1","value2":"21.5","value3":"22:13"}`; got.body != want {
+		t.Errorf("Expected body %s, got %s", want, got.body)
+	}
+	if got.header.Get("Authorization") != "Bearer secret" || got.header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected headers %v", got.header)
+	}
+}
+
+func TestWebhookBatchDefaultBody(t *testing.T) {
+	srv, requests := newServer(t, http.StatusOK)
+	w, err := New(&config.Config{
+		Webhook_URL:      srv.URL,
+		Webhook_Batch:    true,
+		Webhook_Username: "user",
+		Webhook_Password: "pass",
+	}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Write(context.Background(), []*influx.Data{point("obs_st", 1.0), point("obs_st", 2.0)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("Expected one batched request, got %d", len(*requests))
+	}
+	var body []map[string]any
+	if err := json.Unmarshal([]byte((*requests)[0].body), &body); err != nil || len(body) != 2 {
+		t.Fatalf("Expected JSON array of two observations, got %s", (*requests)[0].body)
+	}
+	if body[1]["station"] != "ST-1" {
+		t.Errorf("Unexpected observation %v", body[1])
+	}
+	if !strings.HasPrefix((*requests)[0].header.Get("Authorization"), "Basic ") {
+		t.Error("Expected basic auth")
+	}
+}
+
+func TestWebhookErrors(t *testing.T) {
+	if _, err := New(&config.Config{Webhook_URL: "http://example.com", Webhook_Template: "{{.Station"}, http.DefaultClient); err == nil {
+		t.Error("Expected invalid template to be rejected")
+	}
+
+	srv, _ := newServer(t, http.StatusBadRequest)
+	w, err := New(&config.Config{Webhook_URL: srv.URL}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(context.Background(), []*influx.Data{point("obs_st", 1.0)}); err == nil {
+		t.Error("Expected error for 400 response")
+	}
+}
@@ -0,0 +1,207 @@
+// Package websocket receives observations from the WeatherFlow WebSocket
+// API, replacing the UDP listener when the collector runs off-LAN
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/weatherflow"
+)
+
+// DefaultURL is the WeatherFlow WebSocket API endpoint
+const DefaultURL = "wss://ws.weatherflow.com/swd/data"
+
+// Reconnect delays grow from minBackoff to maxBackoff while connecting fails
+const (
+	minBackoff = 5 * time.Second
+	maxBackoff = 5 * time.Minute
+)
+
+// hubDeviceType is the cloud device type of hubs
+const hubDeviceType = "HB"
+
+// Cloud fetches stations, see weatherflow.Client
+type Cloud interface {
+	Station(ctx context.Context, stationID int) (*weatherflow.Station, error)
+}
+
+// Addr identifies the WebSocket endpoint a message was received from
+type Addr struct {
+	URL string
+}
+
+// Network implements net.Addr
+func (a Addr) Network() string { return "websocket" }
+
+// String implements net.Addr
+func (a Addr) String() string { return a.URL }
+
+// device is a Tempest subscribed to over the WebSocket
+type device struct {
+	serial string
+	hub    string
+}
+
+// Source subscribes to the observations, rapid wind and events of every
+// station with a station_id. Messages carry the cloud device ID rather than
+// the serial number, so the serial numbers of the device and its hub are
+// added and the messages are handed on in the UDP layout.
+type Source struct {
+	config *config.Config
+	logger *logger.AppLogger
+	cloud  Cloud
+	url    string
+}
+
+// NewSource creates a Source using cloud to look up device IDs
+func NewSource(cfg *config.Config, appLogger *logger.AppLogger, cloud Cloud) *Source {
+	return &Source{config: cfg, logger: appLogger, cloud: cloud, url: DefaultURL}
+}
+
+// Name implements processor.Source
+func (s *Source) Name() string { return "websocket" }
+
+// Run resolves the devices, then connects and delivers messages until ctx
+// is cancelled, reconnecting whenever the connection is lost
+func (s *Source) Run(ctx context.Context, handle func(addr net.Addr, data []byte)) error {
+	devices, err := s.devices(ctx)
+	if err != nil {
+		return err
+	}
+
+	backoff := minBackoff
+	for {
+		connected, err := s.listen(ctx, devices, handle)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if connected {
+			backoff = minBackoff
+		}
+		s.logger.Warn("WeatherFlow WebSocket disconnected",
+			slog.String("error", err.Error()),
+			slog.Duration("retry_in", backoff))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// devices returns the Tempests of the configured stations by device ID
+func (s *Source) devices(ctx context.Context) (map[int]device, error) {
+	devices := make(map[int]device)
+	for serial, station := range s.config.Stations {
+		if station.StationID == 0 {
+			continue
+		}
+		st, err := s.cloud.Station(ctx, station.StationID)
+		if err != nil {
+			return nil, fmt.Errorf("looking up station %d: %w", station.StationID, err)
+		}
+		id := st.DeviceID(serial)
+		if id == 0 {
+			s.logger.Warn("Station has no device with this serial number",
+				slog.String("station", serial),
+				slog.Int("station_id", station.StationID))
+			continue
+		}
+		d := device{serial: strings.ToUpper(serial)}
+		for _, dev := range st.Devices {
+			if dev.DeviceType == hubDeviceType {
+				d.hub = dev.SerialNumber
+			}
+		}
+		devices[id] = d
+	}
+	if len(devices) == 0 {
+		return nil, errors.New("no station with a station_id has a device to listen to")
+	}
+	return devices, nil
+}
+
+// listen connects once and delivers messages until the connection fails or
+// ctx is cancelled. It reports whether the connection was established.
+func (s *Source) listen(ctx context.Context, devices map[int]device, handle func(addr net.Addr, data []byte)) (bool, error) {
+	u, err := url.Parse(s.url)
+	if err != nil {
+		return false, err
+	}
+	q := u.Query()
+	q.Set("token", s.config.WeatherFlow_Token)
+	u.RawQuery = q.Encode()
+
+	conn, _, err := gorilla.DefaultDialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// Unblock ReadMessage when ctx is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	for id := range devices {
+		for _, msgType := range []string{"listen_start", "listen_rapid_start"} {
+			req := map[string]any{"type": msgType, "device_id": id, "id": fmt.Sprintf("%s-%d", msgType, id)}
+			if err := conn.WriteJSON(req); err != nil {
+				return true, err
+			}
+		}
+	}
+	s.logger.Info("Listening to WeatherFlow WebSocket", slog.Int("devices", len(devices)))
+
+	addr := Addr{URL: s.url}
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+		packet, ok := convert(msg, devices)
+		if !ok {
+			continue
+		}
+		handle(addr, packet)
+	}
+}
+
+// convert adds the serial numbers of the device and its hub to a device
+// message. Acknowledgements and messages of other devices are dropped.
+func convert(msg []byte, devices map[int]device) ([]byte, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(msg, &fields); err != nil {
+		return nil, false
+	}
+	var id int
+	if err := json.Unmarshal(fields["device_id"], &id); err != nil {
+		return nil, false
+	}
+	d, ok := devices[id]
+	if !ok {
+		return nil, false
+	}
+
+	fields["serial_number"], _ = json.Marshal(d.serial)
+	if _, ok := fields["hub_sn"]; !ok && d.hub != "" {
+		fields["hub_sn"], _ = json.Marshal(d.hub)
+	}
+	packet, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false
+	}
+	return packet, true
+}
@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/logger"
+	"github.com/jacaudi/tempest-influxdb/internal/weatherflow"
+)
+
+type stubCloud struct{}
+
+func (stubCloud) Station(ctx context.Context, stationID int) (*weatherflow.Station, error) {
+	return &weatherflow.Station{StationID: stationID, Devices: []weatherflow.Device{
+		{DeviceID: 11, SerialNumber: "HB-00000001", DeviceType: "HB"},
+		{DeviceID: 12, SerialNumber: "ST-00012345", DeviceType: "ST"},
+	}}, nil
+}
+
+func TestRun(t *testing.T) {
+	requests := make(chan map[string]any, 4)
+	var token string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.URL.Query().Get("token")
+		conn, err := (&gorilla.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		for range 2 {
+			var req map[string]any
+			if err := conn.ReadJSON(&req); err != nil {
+				t.Error(err)
+				return
+			}
+			requests <- req
+		}
+		for _, msg := range []string{
+			`{"type":"connection_opened"}`,
+			`{"type":"ack","id":"listen_start-12"}`,
+			`{"type":"rapid_wind","device_id":99,"ob":[1700000000,1.5,90]}`,
+			`{"type":"rapid_wind","device_id":12,"ob":[1700000000,1.5,90]}`,
+		} {
+			conn.WriteMessage(gorilla.TextMessage, []byte(msg))
+		}
+		conn.ReadMessage()
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{
+		WeatherFlow_Token: "secret",
+		Stations: map[string]config.Station{
+			"st-00012345": {StationID: 1000},
+			"ST-00099999": {},
+		},
+	}
+	s := NewSource(cfg, logger.New(&config.Config{}), stubCloud{})
+	s.url = "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	packets := make(chan []byte, 4)
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx, func(addr net.Addr, data []byte) { packets <- data })
+	}()
+
+	var packet map[string]any
+	select {
+	case data := <-packets:
+		if err := json.Unmarshal(data, &packet); err != nil {
+			t.Fatal(err)
+		}
+	case <-ctx.Done():
+		t.Fatal("no packet received")
+	}
+	cancel()
+	<-done
+
+	if token != "secret" {
+		t.Errorf("token = %q, want secret", token)
+	}
+	for _, want := range []string{"listen_start", "listen_rapid_start"} {
+		req := <-requests
+		if req["type"] != want || req["device_id"] != float64(12) {
+			t.Errorf("request = %v, want %s for device 12", req, want)
+		}
+	}
+	if packet["serial_number"] != "ST-00012345" || packet["hub_sn"] != "HB-00000001" || packet["type"] != "rapid_wind" {
+		t.Errorf("packet = %v", packet)
+	}
+	if len(packets) != 0 {
+		t.Errorf("%d unexpected packets", len(packets))
+	}
+}
+
+func TestRunWithoutDevices(t *testing.T) {
+	cfg := &config.Config{Stations: map[string]config.Station{"ST-00099999": {}}}
+	s := NewSource(cfg, logger.New(&config.Config{}), stubCloud{})
+	if err := s.Run(context.Background(), func(net.Addr, []byte) {}); err == nil {
+		t.Error("Run succeeded without devices")
+	}
+}
@@ -0,0 +1,193 @@
+// Package zabbix sends points to a Zabbix server or proxy with the
+// zabbix_sender (trapper) protocol
+package zabbix
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+const (
+	// DefaultPort is the trapper port of a Zabbix server
+	DefaultPort = "10051"
+
+	// DefaultTimeout bounds a request when Influx_Write_Timeout is not set
+	DefaultTimeout = 5 * time.Second
+
+	// maxResponse caps the size of a server response that is read
+	maxResponse = 64 << 10
+)
+
+// header starts every message of the protocol
+var header = []byte("ZBXD\x01")
+
+// Item is one value sent to Zabbix
+type Item struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+	NS    int64  `json:"ns,omitempty"`
+}
+
+// request is the body of a sender data message
+type request struct {
+	Request string `json:"request"`
+	Data    []Item `json:"data"`
+}
+
+// response is the server's answer to a request
+type response struct {
+	Response string `json:"response"`
+	Info     string `json:"info"`
+}
+
+// Sender is an output that sends every field of a point as a trapper item.
+// The item host is the station's zabbix_host, or its serial number, and the
+// item key is Zabbix_Key_Prefix followed by the field name, e.g.
+// tempest.temp. Values of items that do not exist in Zabbix are ignored by
+// the server.
+type Sender struct {
+	cfg     *config.Config
+	address string
+	timeout time.Duration
+}
+
+// New creates a Sender for cfg.Zabbix_Server, given as host or host:port
+func New(cfg *config.Config) *Sender {
+	address := cfg.Zabbix_Server
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, DefaultPort)
+	}
+	timeout := cfg.Influx_Write_Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Sender{cfg: cfg, address: address, timeout: timeout}
+}
+
+// Name implements processor.Output
+func (s *Sender) Name() string {
+	return "zabbix"
+}
+
+// Items converts points into trapper items, in field name order per point
+func (s *Sender) Items(points []*influx.Data) []Item {
+	var items []Item
+	for _, m := range points {
+		station := m.Tags["station"]
+		host := s.cfg.Station(station).ZabbixHost
+		if host == "" {
+			host = station
+		}
+		if host == "" {
+			continue
+		}
+		fields := make([]string, 0, len(m.Fields))
+		for field := range m.Fields {
+			fields = append(fields, field)
+		}
+		slices.Sort(fields)
+		for _, field := range fields {
+			items = append(items, Item{
+				Host:  host,
+				Key:   s.cfg.Zabbix_Key_Prefix + field,
+				Value: value(m.Fields[field]),
+				Clock: m.Timestamp,
+				NS:    m.Nanos,
+			})
+		}
+	}
+	return items
+}
+
+// value returns a field value as Zabbix item text
+func value(v any) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Write sends the fields of points in one request
+func (s *Sender) Write(ctx context.Context, points []*influx.Data) error {
+	items := s.Items(points)
+	if len(items) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(request{Request: "sender data", Data: items})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return fmt.Errorf("connecting to Zabbix at %s: %w", s.address, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(encode(body)); err != nil {
+		return fmt.Errorf("sending to Zabbix at %s: %w", s.address, err)
+	}
+	answer, err := decode(conn)
+	if err != nil {
+		return fmt.Errorf("reading Zabbix response: %w", err)
+	}
+	var resp response
+	if err := json.Unmarshal(answer, &resp); err != nil {
+		return fmt.Errorf("decoding Zabbix response: %w", err)
+	}
+	if resp.Response != "success" {
+		return fmt.Errorf("zabbix rejected the data: %s %s", resp.Response, resp.Info)
+	}
+	return nil
+}
+
+// encode frames data with the protocol header and its length
+func encode(data []byte) []byte {
+	msg := make([]byte, 0, len(header)+8+len(data))
+	msg = append(msg, header...)
+	msg = binary.LittleEndian.AppendUint64(msg, uint64(len(data)))
+	return append(msg, data...)
+}
+
+// decode reads one framed message from r
+func decode(r io.Reader) ([]byte, error) {
+	prefix := make([]byte, len(header)+8)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, err
+	}
+	if string(prefix[:4]) != "ZBXD" {
+		return nil, errors.New("invalid protocol header")
+	}
+	size := binary.LittleEndian.Uint32(prefix[len(header):])
+	if size > maxResponse {
+		return nil, fmt.Errorf("response of %d bytes is too large", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
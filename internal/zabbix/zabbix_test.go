@@ -0,0 +1,101 @@
+package zabbix
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/jacaudi/tempest-influxdb/internal/config"
+	"github.com/jacaudi/tempest-influxdb/internal/influx"
+)
+
+// fakeServer answers one sender request with answer and returns the request
+func fakeServer(t *testing.T, answer string) (string, <-chan request) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	requests := make(chan request, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		body, err := decode(conn)
+		if err != nil {
+			t.Errorf("decode() error = %v", err)
+			return
+		}
+		var req request
+		json.Unmarshal(body, &req)
+		requests <- req
+		conn.Write(encode([]byte(answer)))
+	}()
+	return listener.Addr().String(), requests
+}
+
+func testPoint() *influx.Data {
+	m := influx.New()
+	m.Name = "weather"
+	m.Timestamp = 1640995200
+	m.Tags["station"] = "ST-1"
+	m.Fields["temp"] = 21.5
+	m.Fields["rain_nc_analysis"] = int64(1)
+	m.Fields["summary"] = "Hi"
+	return m
+}
+
+func TestWrite(t *testing.T) {
+	addr, requests := fakeServer(t, `{"response":"success","info":"processed: 3; failed: 0; total: 3"}`)
+	cfg := &config.Config{
+		Zabbix_Server:     addr,
+		Zabbix_Key_Prefix: "tempest.",
+		Stations:          map[string]config.Station{"ST-1": {ZabbixHost: "garden"}},
+	}
+	if err := New(cfg).Write(context.Background(), []*influx.Data{testPoint()}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	req := <-requests
+	if req.Request != "sender data" || len(req.Data) != 3 {
+		t.Fatalf("Unexpected request %+v", req)
+	}
+	want := []Item{
+		{Host: "garden", Key: "tempest.rain_nc_analysis", Value: "1", Clock: 1640995200},
+		{Host: "garden", Key: "tempest.summary", Value: "Hi", Clock: 1640995200},
+		{Host: "garden", Key: "tempest.temp", Value: "21.5", Clock: 1640995200},
+	}
+	for i, item := range want {
+		if req.Data[i] != item {
+			t.Errorf("Item %d: expected %+v, got %+v", i, item, req.Data[i])
+		}
+	}
+}
+
+func TestWriteFailure(t *testing.T) {
+	addr, _ := fakeServer(t, `{"response":"failed","info":"invalid request"}`)
+	if err := New(&config.Config{Zabbix_Server: addr}).Write(context.Background(), []*influx.Data{testPoint()}); err == nil {
+		t.Error("Expected error when Zabbix does not report success")
+	}
+}
+
+func TestNewDefaultPort(t *testing.T) {
+	if s := New(&config.Config{Zabbix_Server: "zabbix.example.com"}); s.address != "zabbix.example.com:10051" {
+		t.Errorf("Expected default port, got %s", s.address)
+	}
+}
+
+func TestItemsStationCase(t *testing.T) {
+	// The config file loader lower-cases the serials under stations
+	cfg := &config.Config{Stations: map[string]config.Station{"st-1": {ZabbixHost: "garden"}}}
+	for _, item := range New(cfg).Items([]*influx.Data{testPoint()}) {
+		if item.Host != "garden" {
+			t.Errorf("Expected host garden for serial ST-1, got %+v", item)
+		}
+	}
+}